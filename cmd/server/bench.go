@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"s3-vault-proxy/internal/bench"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/vault"
+)
+
+var (
+	benchEndpoint    string
+	benchAdminURL    string
+	benchBucket      string
+	benchPrefix      string
+	benchKMSKey      string
+	benchMix         string
+	benchSize        int64
+	benchKeys        int
+	benchConcurrency int
+	benchDuration    time.Duration
+	benchKeep        bool
+)
+
+func benchFlags(fs *flag.FlagSet) {
+	fs.StringVar(&benchEndpoint, "endpoint", "", "proxy to benchmark (default: http://localhost:PORT)")
+	fs.StringVar(&benchAdminURL, "admin-url", "", "proxy admin listener for Vault operation counts (default: from ADMIN_ADDR, \"\" when unset)")
+	fs.StringVar(&benchBucket, "bucket", "", "bucket to benchmark against (required)")
+	fs.StringVar(&benchPrefix, "prefix", "s3-vault-proxy-bench/", "key prefix of the benchmark objects")
+	fs.StringVar(&benchKMSKey, "kms-key", "", "KMS key ARN for uploads (required)")
+	fs.StringVar(&benchMix, "mix", "put=50,get=40,list=10", "weighted operation mix")
+	fs.Int64Var(&benchSize, "size", 64<<10, "object size in bytes")
+	fs.IntVar(&benchKeys, "keys", 100, "number of distinct keys written and read")
+	fs.IntVar(&benchConcurrency, "concurrency", 16, "concurrent requests")
+	fs.DurationVar(&benchDuration, "duration", 30*time.Second, "how long to run")
+	fs.BoolVar(&benchKeep, "keep", false, "keep the benchmark objects instead of deleting them")
+}
+
+// benchCommand load-tests a running proxy and reports throughput, latency
+// percentiles and the Vault key operations the proxy performed meanwhile
+func benchCommand(fs *flag.FlagSet) error {
+	if benchBucket == "" || benchKMSKey == "" {
+		fmt.Fprintln(stderr, "bench requires --bucket and --kms-key")
+		return errReported
+	}
+	if _, err := new(vault.Client).ARNToVaultKey(benchKMSKey); err != nil {
+		return err
+	}
+	mix, err := bench.ParseMix(benchMix)
+	if err != nil {
+		return err
+	}
+	logging.InitGlobalLogger(logging.Config{Level: "error", Format: "console"})
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	endpoint := benchEndpoint
+	if endpoint == "" {
+		endpoint = "http://localhost:" + cfg.Port
+	}
+	adminURL := benchAdminURL
+	if adminURL == "" && cfg.AdminAddr != "" {
+		host, port, _ := net.SplitHostPort(cfg.AdminAddr)
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			host = "localhost"
+		}
+		adminURL = "http://" + net.JoinHostPort(host, port)
+	}
+	credentials, err := cfg.OperatorCredentials()
+	if err != nil {
+		return err
+	}
+	client, err := s3.NewSigningClient(s3.NewClient(endpoint, cfg.S3CACertPath, s3.DefaultTransportConfig()), endpoint, credentials)
+	if err != nil {
+		return err
+	}
+
+	var vaultBefore int64
+	if adminURL != "" {
+		if vaultBefore, err = bench.VaultOperations(adminURL, cfg.AdminToken); err != nil {
+			fmt.Fprintf(stderr, "Vault operation counts unavailable: %v\n", err)
+			adminURL = ""
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(stdout, "benchmarking %s for %s: mix %s, %d byte objects, %d keys, concurrency %d\n",
+		endpoint, benchDuration, benchMix, benchSize, benchKeys, benchConcurrency)
+	result, err := bench.Run(ctx, client, bench.Options{
+		Bucket:      benchBucket,
+		Prefix:      benchPrefix,
+		KMSKeyARN:   benchKMSKey,
+		ObjectSize:  benchSize,
+		Keys:        benchKeys,
+		Mix:         mix,
+		Concurrency: benchConcurrency,
+		Duration:    benchDuration,
+		Cleanup:     !benchKeep,
+	})
+	if err != nil {
+		return err
+	}
+	result.Write(stdout)
+
+	if adminURL != "" {
+		vaultAfter, err := bench.VaultOperations(adminURL, cfg.AdminToken)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "vault key operations: %d\n", vaultAfter-vaultBefore)
+	}
+	return nil
+}
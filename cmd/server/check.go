@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/server"
+	"s3-vault-proxy/internal/vault"
+)
+
+// checkTimeout bounds each connectivity check
+const checkTimeout = 10 * time.Second
+
+var (
+	checkKMSKeys stringList
+	checkBuckets stringList
+	checkShow    bool
+	checkOffline bool
+)
+
+func checkConfigFlags(fs *flag.FlagSet) {
+	fs.Var(&checkKMSKeys, "kms-key", "KMS key ARN whose transit key permissions are verified (repeatable)")
+	fs.Var(&checkBuckets, "bucket", "bucket whose reachability is verified (repeatable)")
+	fs.BoolVar(&checkShow, "show", false, "print the effective configuration with secrets redacted")
+	fs.BoolVar(&checkOffline, "offline", false, "only validate the configuration, without contacting Vault or backends")
+}
+
+// checkReport prints one line per check and counts failures
+type checkReport struct {
+	failures int
+	warnings int
+}
+
+func (r *checkReport) section(title string) {
+	fmt.Fprintf(stdout, "%s\n", title)
+}
+
+func (r *checkReport) ok(format string, args ...interface{}) {
+	fmt.Fprintf(stdout, "  [ok]   %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *checkReport) warn(format string, args ...interface{}) {
+	r.warnings++
+	fmt.Fprintf(stdout, "  [warn] %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *checkReport) fail(format string, args ...interface{}) {
+	r.failures++
+	fmt.Fprintf(stdout, "  [FAIL] %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *checkReport) skip(format string, args ...interface{}) {
+	fmt.Fprintf(stdout, "  [skip] %s\n", fmt.Sprintf(format, args...))
+}
+
+// checkConfigCommand validates configuration and dependencies, exiting
+// non-zero when any check fails so deployment pipelines can gate on it
+func checkConfigCommand(fs *flag.FlagSet) error {
+	// Keep the report readable: only problems are logged
+	logging.InitGlobalLogger(logging.Config{Level: "error", Format: "console"})
+
+	report := &checkReport{}
+	report.section("Configuration")
+	cfg, err := loadConfig()
+	if err != nil {
+		report.fail("%v", err)
+		return report.finish()
+	}
+	report.ok("configuration is valid")
+	if checkShow {
+		printRedacted(cfg)
+	}
+	if checkOffline {
+		report.skip("connectivity checks (--offline)")
+		return report.finish()
+	}
+
+	checkVault(report, cfg)
+	checkBackend(report, cfg)
+	return report.finish()
+}
+
+func checkVault(report *checkReport, cfg *config.Config) {
+	report.section(fmt.Sprintf("Vault (%s)", cfg.VaultAddr))
+	client, err := vault.NewClient(cfg.VaultAddr, cfg.VaultToken, cfg.VaultTokenPath)
+	if err != nil {
+		report.fail("%v", err)
+		return
+	}
+	client = client.WithMount(cfg.VaultTransitMount)
+	report.ok("token source: %s", client.TokenSource())
+
+	status := client.DependencyStatus()
+	switch {
+	case !status.Reachable:
+		report.fail("unreachable: %s", status.Error)
+		return
+	case !status.Initialized:
+		report.fail("not initialized")
+		return
+	case status.Sealed:
+		report.fail("sealed")
+		return
+	}
+	report.ok("reachable, initialized and unsealed (version %s, %s)", status.Version, status.Latency.Round(time.Millisecond))
+
+	if status.Error != "" {
+		report.fail("%s", status.Error)
+		return
+	}
+	switch {
+	case status.TokenTTL == 0:
+		report.ok("token does not expire")
+	case status.TokenTTL < time.Hour && !status.TokenRenewable:
+		report.warn("token expires in %s and is not renewable", status.TokenTTL)
+	default:
+		report.ok("token ttl %s (renewable: %t)", status.TokenTTL, status.TokenRenewable)
+	}
+
+	if len(checkKMSKeys) == 0 {
+		report.skip("transit key permissions (pass --kms-key)")
+		return
+	}
+	for _, arn := range checkKMSKeys {
+		transitKey, err := client.ARNToVaultKey(arn)
+		if err != nil {
+			report.fail("%v", err)
+			continue
+		}
+		canEncrypt, canDecrypt, err := client.TransitCapabilities(transitKey)
+		switch {
+		case err != nil:
+			report.fail("transit key %s: %v", transitKey, err)
+		case !canEncrypt || !canDecrypt:
+			report.fail("transit key %s: encrypt=%t decrypt=%t", transitKey, canEncrypt, canDecrypt)
+		default:
+			report.ok("transit key %s: encrypt and decrypt permitted", transitKey)
+		}
+	}
+}
+
+func checkBackend(report *checkReport, cfg *config.Config) {
+	report.section(fmt.Sprintf("Backend (%s)", cfg.S3Endpoint))
+	backend := server.NewBackend(cfg)
+
+	result := backend.Probe(checkTimeout)
+	if !result.Reachable || result.Error != "" {
+		report.fail("unreachable: %s", result.Error)
+		return
+	}
+	switch result.Auth {
+	case "anonymous_allowed":
+		report.warn("reachable in %s, but anonymous ListBuckets succeeded", result.Latency.Round(time.Millisecond))
+	default:
+		report.ok("reachable in %s (status %d, auth %s)", result.Latency.Round(time.Millisecond), result.StatusCode, result.Auth)
+	}
+
+	if len(checkBuckets) == 0 {
+		report.skip("bucket reachability (pass --bucket)")
+		return
+	}
+	for _, bucket := range checkBuckets {
+		result := backend.ProbeBucket(bucket, checkTimeout)
+		switch {
+		case !result.Reachable || result.Error != "":
+			report.fail("bucket %s: %s", bucket, result.Error)
+		case result.Auth == "anonymous_allowed":
+			report.warn("bucket %s allows anonymous access", bucket)
+		default:
+			report.ok("bucket %s reachable (status %d)", bucket, result.StatusCode)
+		}
+	}
+}
+
+// finish prints the summary and fails the command when any check failed
+func (r *checkReport) finish() error {
+	if r.failures > 0 {
+		fmt.Fprintf(stdout, "FAILED: %d check(s) failed, %d warning(s)\n", r.failures, r.warnings)
+		return errReported
+	}
+	fmt.Fprintf(stdout, "OK: all checks passed, %d warning(s)\n", r.warnings)
+	return nil
+}
+
+func printRedacted(cfg *config.Config) {
+	redacted := cfg.Redacted()
+	keys := make([]string, 0, len(redacted))
+	for key := range redacted {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, _ := json.Marshal(redacted[key])
+		fmt.Fprintf(stdout, "    %s=%s\n", key, value)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/server"
+)
+
+// command is a CLI subcommand. Commands with configFlags accept a flag for
+// every configuration environment variable.
+type command struct {
+	name        string
+	summary     string
+	configFlags bool
+	flags       func(fs *flag.FlagSet)
+	run         func(fs *flag.FlagSet) error
+}
+
+// errReported signals a failure that has already been reported to the user
+var errReported = errors.New("already reported")
+
+var stdout io.Writer = os.Stdout
+var stderr io.Writer = os.Stderr
+
+func commands() []command {
+	return []command{
+		{name: "serve", summary: "Run the proxy (default when no command is given)", configFlags: true, flags: serveFlags, run: serveCommand},
+		{name: "check-config", summary: "Validate the configuration and check Vault, transit keys and backends", configFlags: true, flags: checkConfigFlags, run: checkConfigCommand},
+		{name: "rewrap", summary: "Re-encrypt stored objects under data keys of the newest transit key version", configFlags: true, flags: rewrapFlags, run: rewrapCommand},
+		{name: "verify", summary: "Audit stored objects against their metadata and report discrepancies", configFlags: true, flags: verifyFlags, run: verifyCommand},
+		{name: "migrate-encrypt", summary: "Encrypt existing plaintext objects with a KMS key and write their metadata", configFlags: true, flags: migrateFlags, run: migrateCommand},
+		{name: "gc", summary: "Remove orphaned metadata objects and abandoned multipart uploads", configFlags: true, flags: gcFlags, run: gcCommand},
+		{name: "fsck", summary: "Check a bucket directory tree for truncated, mismatched or unsafe entries", flags: fsckFlags, run: fsckCommand},
+		{name: "export", summary: "Write a bucket to a sealed archive without storing plaintext", configFlags: true, flags: exportFlags, run: exportCommand},
+		{name: "restore", summary: "Upload the objects of an export archive", configFlags: true, flags: restoreFlags, run: restoreCommand},
+		{name: "sync", summary: "Copy a bucket to another backend or proxy, optionally under a new KMS key", configFlags: true, flags: syncFlags, run: syncCommand},
+		{name: "inventory", summary: "Write S3 Inventory style reports of objects and the KMS keys protecting them", configFlags: true, flags: inventoryFlags, run: inventoryCommand},
+		{name: "bench", summary: "Load-test a running proxy and report throughput and latency", configFlags: true, flags: benchFlags, run: benchCommand},
+		{name: "version", summary: "Print build information", run: versionCommand},
+	}
+}
+
+// run dispatches to a subcommand and returns the process exit code. With no
+// command, or when the first argument is a flag, it serves so existing
+// container entrypoints keep working.
+func run(args []string) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		printUsage(stdout)
+		return 0
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name != name {
+			continue
+		}
+		fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		if cmd.configFlags {
+			registerConfigFlags(fs)
+		}
+		if cmd.flags != nil {
+			cmd.flags(fs)
+		}
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 0
+			}
+			return 2
+		}
+		if err := cmd.run(fs); err != nil {
+			if !errors.Is(err, errReported) {
+				fmt.Fprintf(stderr, "Error: %v\n", err)
+			}
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "Unknown command %q\n\n", name)
+	printUsage(stderr)
+	return 2
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: s3-vault-proxy [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Every environment variable has a matching flag, e.g. S3_ENDPOINT is --s3-endpoint.")
+	fmt.Fprintln(w, "Flags take precedence over the environment. Run 's3-vault-proxy serve -h' for the full list.")
+}
+
+// envFlag sets its environment variable when the flag is given, so flags and
+// environment share a single loading path
+type envFlag struct {
+	variable config.Variable
+}
+
+func (f *envFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return os.Getenv(f.variable.Name)
+}
+
+func (f *envFlag) Set(value string) error {
+	return os.Setenv(f.variable.Name, value)
+}
+
+// IsBoolFlag allows boolean settings to be given as a bare --flag
+func (f *envFlag) IsBoolFlag() bool {
+	return f.variable.Kind == config.KindBool
+}
+
+// registerConfigFlags adds a flag for every configuration variable
+func registerConfigFlags(fs *flag.FlagSet) {
+	for _, variable := range config.Variables() {
+		usage := fmt.Sprintf("%s (env %s)", variable.Kind, variable.Name)
+		if variable.Default != "" {
+			usage += fmt.Sprintf(", default %q", variable.Default)
+		}
+		fs.Var(&envFlag{variable: variable}, flagName(variable.Name), usage)
+	}
+}
+
+// flagName converts an environment variable name to its flag name
+func flagName(env string) string {
+	return strings.ReplaceAll(strings.ToLower(env), "_", "-")
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// loadConfig loads configuration and applies build-time variables
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Override build-time variables if they were set
+	if version != "dev" {
+		cfg.Version = version
+	}
+	if commit != "none" {
+		cfg.Commit = commit
+	}
+	if date != "unknown" {
+		cfg.Date = date
+	}
+	if builtBy != "unknown" {
+		cfg.BuiltBy = builtBy
+	}
+	return cfg, nil
+}
+
+// serveFlags adds --dev as a shorthand for --dev-mode
+func serveFlags(fs *flag.FlagSet) {
+	dev := config.Variable{Name: "DEV_MODE", Kind: config.KindBool}
+	fs.Var(&envFlag{variable: dev}, "dev", "run without Vault using an in-memory transit engine (same as --dev-mode)")
+}
+
+func serveCommand(fs *flag.FlagSet) error {
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "serve takes no arguments, got %q\n", fs.Args())
+		return errReported
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	srv, err := server.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create server: %w", err)
+	}
+
+	if err := srv.Start(); err != nil {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	return nil
+}
+
+func versionCommand(fs *flag.FlagSet) error {
+	fmt.Fprintf(stdout, "s3-vault-proxy %s (commit %s, built %s by %s)\n", version, commit, date, builtBy)
+	return nil
+}
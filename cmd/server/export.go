@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/maintenance"
+	"s3-vault-proxy/internal/vault"
+)
+
+var (
+	exportBucket           string
+	exportPrefix           string
+	exportKMSKey           string
+	exportOutput           string
+	exportRate             float64
+	exportProgressInterval time.Duration
+
+	restoreInput            string
+	restoreBucket           string
+	restoreRate             float64
+	restoreProgressInterval time.Duration
+)
+
+func exportFlags(fs *flag.FlagSet) {
+	fs.StringVar(&exportBucket, "bucket", "", "bucket to export (required)")
+	fs.StringVar(&exportPrefix, "prefix", "", "only export objects under this key prefix")
+	fs.StringVar(&exportKMSKey, "kms-key", "", "KMS key ARN sealing the archive (required)")
+	fs.StringVar(&exportOutput, "output", "", "archive file to write (- is stdout, required)")
+	fs.Float64Var(&exportRate, "rate", 0, "maximum objects per second (0 is unlimited)")
+	fs.DurationVar(&exportProgressInterval, "progress-interval", 10*time.Second, "how often progress is printed to stderr")
+}
+
+func restoreFlags(fs *flag.FlagSet) {
+	fs.StringVar(&restoreInput, "input", "", "archive file to read (- is stdin, required)")
+	fs.StringVar(&restoreBucket, "bucket", "", "bucket to restore into (default: the exported bucket)")
+	fs.Float64Var(&restoreRate, "rate", 0, "maximum objects per second (0 is unlimited)")
+	fs.DurationVar(&restoreProgressInterval, "progress-interval", 10*time.Second, "how often progress is printed to stderr")
+}
+
+// exportCommand streams a bucket into a sealed archive for backups and moves
+func exportCommand(fs *flag.FlagSet) error {
+	if exportBucket == "" || exportKMSKey == "" || exportOutput == "" {
+		fmt.Fprintln(stderr, "export requires --bucket, --kms-key and --output")
+		return errReported
+	}
+	if _, err := new(vault.Client).ARNToVaultKey(exportKMSKey); err != nil {
+		return err
+	}
+	logging.InitGlobalLogger(logging.Config{Level: "error", Format: "console"})
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	client, err := operatorClient(cfg)
+	if err != nil {
+		return err
+	}
+	vaultClient, err := transitClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = stdout
+	if exportOutput != "-" {
+		file, err := os.Create(exportOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create archive: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	buffered := bufio.NewWriterSize(out, 1<<20)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	progress := maintenance.NewProgress(stderr, exportProgressInterval)
+	err = maintenance.Export(ctx, client, vaultClient, buffered, maintenance.ExportOptions{
+		Bucket:    exportBucket,
+		Prefix:    exportPrefix,
+		KMSKeyARN: exportKMSKey,
+		Rate:      exportRate,
+		Progress:  progress,
+	})
+	if err == nil {
+		err = buffered.Flush()
+	}
+	fmt.Fprintf(stderr, "done: %s\n", progress.Summary())
+	if err != nil {
+		return err
+	}
+	if failed := progress.Count(maintenance.OutcomeFailed); failed > 0 {
+		fmt.Fprintf(stderr, "%d object(s) could not be exported\n", failed)
+		return errReported
+	}
+	return nil
+}
+
+// restoreCommand uploads the objects of an export archive
+func restoreCommand(fs *flag.FlagSet) error {
+	if restoreInput == "" {
+		fmt.Fprintln(stderr, "restore requires --input")
+		return errReported
+	}
+	logging.InitGlobalLogger(logging.Config{Level: "error", Format: "console"})
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	client, err := operatorClient(cfg)
+	if err != nil {
+		return err
+	}
+	vaultClient, err := transitClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if restoreInput != "-" {
+		file, err := os.Open(restoreInput)
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx, release, err := lockBuckets(ctx, cfg, client, restoreBucket)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	progress := maintenance.NewProgress(stderr, restoreProgressInterval)
+	err = maintenance.Restore(ctx, client, vaultClient, bufio.NewReaderSize(in, 1<<20), maintenance.RestoreOptions{
+		Bucket:   restoreBucket,
+		Rate:     restoreRate,
+		Progress: progress,
+	})
+	fmt.Fprintf(stderr, "done: %s\n", progress.Summary())
+	if err != nil {
+		return err
+	}
+	if failed := progress.Count(maintenance.OutcomeFailed); failed > 0 {
+		fmt.Fprintf(stderr, "%d object(s) could not be restored\n", failed)
+		return errReported
+	}
+	return nil
+}
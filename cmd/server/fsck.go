@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"s3-vault-proxy/internal/maintenance"
+)
+
+var (
+	fsckPath             string
+	fsckQuarantine       bool
+	fsckOutput           string
+	fsckProgressInterval time.Duration
+)
+
+func fsckFlags(fs *flag.FlagSet) {
+	fs.StringVar(&fsckPath, "path", "", "directory holding one subdirectory per bucket (required)")
+	fs.BoolVar(&fsckQuarantine, "quarantine", false, "move bad entries under <path>/.quarantine")
+	fs.StringVar(&fsckOutput, "output", "-", "file receiving the JSON lines report (- is stdout)")
+	fs.DurationVar(&fsckProgressInterval, "progress-interval", 10*time.Second, "how often progress is printed to stderr")
+}
+
+// fsckCommand checks a bucket directory tree for truncated objects,
+// metadata mismatches, permission problems and unsanitary keys
+func fsckCommand(fs *flag.FlagSet) error {
+	if fsckPath == "" {
+		fmt.Fprintln(stderr, "fsck requires --path")
+		return errReported
+	}
+
+	opts := maintenance.FsckOptions{
+		Root:       fsckPath,
+		Quarantine: fsckQuarantine,
+		Report:     stdout,
+		Progress:   maintenance.NewProgress(stderr, fsckProgressInterval),
+	}
+	if fsckOutput != "-" {
+		file, err := os.Create(fsckOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		defer file.Close()
+		opts.Report = file
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := maintenance.Fsck(ctx, opts)
+	fmt.Fprintf(stderr, "done: %s\n", opts.Progress.Summary())
+	if err != nil {
+		return err
+	}
+	if problems := opts.Progress.Count(maintenance.OutcomeProblem); problems > 0 {
+		fmt.Fprintf(stderr, "%d entries have problems\n", problems)
+		return errReported
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/maintenance"
+)
+
+var (
+	gcBuckets          stringList
+	gcUploadsOlderThan time.Duration
+	gcDryRun           bool
+	gcRate             float64
+	gcProgressInterval time.Duration
+)
+
+func gcFlags(fs *flag.FlagSet) {
+	fs.Var(&gcBuckets, "bucket", "bucket to collect (repeatable, required)")
+	fs.DurationVar(&gcUploadsOlderThan, "uploads-older-than", 7*24*time.Hour, "abort multipart uploads initiated longer ago (0 leaves uploads alone)")
+	fs.BoolVar(&gcDryRun, "dry-run", false, "only report what would be removed")
+	fs.Float64Var(&gcRate, "rate", 50, "maximum deletes per second (0 is unlimited)")
+	fs.DurationVar(&gcProgressInterval, "progress-interval", 10*time.Second, "how often progress is printed")
+}
+
+// gcCommand removes orphaned metadata objects and abandoned multipart uploads
+func gcCommand(fs *flag.FlagSet) error {
+	if len(gcBuckets) == 0 {
+		fmt.Fprintln(stderr, "gc requires at least one --bucket")
+		return errReported
+	}
+	logging.InitGlobalLogger(logging.Config{Level: "error", Format: "console"})
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	client, err := operatorClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx, release, err := lockBuckets(ctx, cfg, client, gcBuckets...)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	progress := maintenance.NewProgress(stdout, gcProgressInterval)
+	err = maintenance.GC(ctx, client, maintenance.GCOptions{
+		Buckets:          gcBuckets,
+		UploadsOlderThan: gcUploadsOlderThan,
+		DryRun:           gcDryRun,
+		Rate:             gcRate,
+		Progress:         progress,
+	})
+	fmt.Fprintf(stdout, "done: %s\n", progress.Summary())
+	if err != nil {
+		return err
+	}
+	if failed := progress.Count(maintenance.OutcomeFailed); failed > 0 {
+		fmt.Fprintf(stderr, "%d item(s) could not be removed\n", failed)
+		return errReported
+	}
+	return nil
+}
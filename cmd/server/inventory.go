@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/maintenance"
+	"s3-vault-proxy/internal/vault"
+)
+
+var (
+	inventoryBuckets          stringList
+	inventoryPrefix           string
+	inventoryDestBucket       string
+	inventoryDestPrefix       string
+	inventoryID               string
+	inventoryKMSKey           string
+	inventoryRowsPerFile      int
+	inventoryRate             float64
+	inventoryProgressInterval time.Duration
+)
+
+func inventoryFlags(fs *flag.FlagSet) {
+	fs.Var(&inventoryBuckets, "bucket", "bucket to inventory (repeatable, required)")
+	fs.StringVar(&inventoryPrefix, "prefix", "", "only list objects under this key prefix")
+	fs.StringVar(&inventoryDestBucket, "dest-bucket", "", "bucket receiving the reports (required)")
+	fs.StringVar(&inventoryDestPrefix, "dest-prefix", "", "key prefix of the reports in the destination bucket")
+	fs.StringVar(&inventoryID, "id", "s3-vault-proxy", "inventory configuration ID used in report paths")
+	fs.StringVar(&inventoryKMSKey, "kms-key", "", "KMS key ARN encrypting the report files")
+	fs.IntVar(&inventoryRowsPerFile, "rows-per-file", 1000000, "objects per data file")
+	fs.Float64Var(&inventoryRate, "rate", 50, "maximum objects per second (0 is unlimited)")
+	fs.DurationVar(&inventoryProgressInterval, "progress-interval", 10*time.Second, "how often progress is printed")
+}
+
+// inventoryCommand writes S3 Inventory style reports of buckets' objects and their encryption
+func inventoryCommand(fs *flag.FlagSet) error {
+	if len(inventoryBuckets) == 0 || inventoryDestBucket == "" {
+		fmt.Fprintln(stderr, "inventory requires at least one --bucket and --dest-bucket")
+		return errReported
+	}
+	if inventoryKMSKey != "" {
+		if _, err := new(vault.Client).ARNToVaultKey(inventoryKMSKey); err != nil {
+			return err
+		}
+	}
+	logging.InitGlobalLogger(logging.Config{Level: "error", Format: "console"})
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	client, err := operatorClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	progress := maintenance.NewProgress(stderr, inventoryProgressInterval)
+	for _, bucket := range inventoryBuckets {
+		manifestKey, err := maintenance.Inventory(ctx, client, maintenance.InventoryOptions{
+			Bucket:      bucket,
+			Prefix:      inventoryPrefix,
+			DestBucket:  inventoryDestBucket,
+			DestPrefix:  inventoryDestPrefix,
+			ID:          inventoryID,
+			KMSKeyARN:   inventoryKMSKey,
+			RowsPerFile: inventoryRowsPerFile,
+			Rate:        inventoryRate,
+			Progress:    progress,
+		})
+		if err != nil {
+			fmt.Fprintf(stderr, "done: %s\n", progress.Summary())
+			return fmt.Errorf("bucket %s: %w", bucket, err)
+		}
+		fmt.Fprintf(stdout, "%s: s3://%s/%s\n", bucket, inventoryDestBucket, manifestKey)
+	}
+	fmt.Fprintf(stderr, "done: %s\n", progress.Summary())
+	if failed := progress.Count(maintenance.OutcomeFailed); failed > 0 {
+		fmt.Fprintf(stderr, "%d object(s) are listed without their encryption\n", failed)
+		return errReported
+	}
+	return nil
+}
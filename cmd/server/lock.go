@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+
+	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/lock"
+	"s3-vault-proxy/internal/s3"
+)
+
+// lockBuckets leases every bucket a maintenance job writes to when
+// LOCK_BUCKET is set, so the same job started on two hosts, or two jobs
+// rewriting metadata of one bucket, cannot interleave. The returned context is
+// cancelled when a lease is lost; release frees the leases.
+func lockBuckets(ctx context.Context, cfg *config.Config, client s3.Interface, buckets ...string) (context.Context, func(), error) {
+	if cfg.LockBucket == "" {
+		return ctx, func() {}, nil
+	}
+	return lock.NewLocker(client, cfg.LockBucket, cfg.LockTTL).TryAcquireBuckets(ctx, buckets...)
+}
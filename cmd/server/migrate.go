@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/maintenance"
+	"s3-vault-proxy/internal/vault"
+)
+
+var (
+	migrateBucket           string
+	migratePrefix           string
+	migrateDestBucket       string
+	migrateKMSKey           string
+	migrateDeleteSource     bool
+	migrateDryRun           bool
+	migrateRate             float64
+	migrateCheckpoint       string
+	migrateProgressInterval time.Duration
+)
+
+func migrateFlags(fs *flag.FlagSet) {
+	fs.StringVar(&migrateBucket, "bucket", "", "bucket holding the unencrypted objects (required)")
+	fs.StringVar(&migratePrefix, "prefix", "", "only migrate objects under this key prefix")
+	fs.StringVar(&migrateDestBucket, "dest-bucket", "", "bucket receiving the encrypted objects (default: rewrite in place)")
+	fs.StringVar(&migrateKMSKey, "kms-key", "", "KMS key ARN to encrypt with (required)")
+	fs.BoolVar(&migrateDeleteSource, "delete-source", false, "delete each plaintext original once it was migrated (requires --dest-bucket)")
+	fs.BoolVar(&migrateDryRun, "dry-run", false, "only report which objects would be migrated")
+	fs.Float64Var(&migrateRate, "rate", 20, "maximum objects per second (0 is unlimited)")
+	fs.StringVar(&migrateCheckpoint, "checkpoint", "", "file recording progress so an interrupted run resumes where it stopped")
+	fs.DurationVar(&migrateProgressInterval, "progress-interval", 10*time.Second, "how often progress is printed")
+}
+
+// migrateCommand encrypts existing plaintext objects for onboarding datasets
+func migrateCommand(fs *flag.FlagSet) error {
+	if migrateBucket == "" || migrateKMSKey == "" {
+		fmt.Fprintln(stderr, "migrate-encrypt requires --bucket and --kms-key")
+		return errReported
+	}
+	if _, err := new(vault.Client).ARNToVaultKey(migrateKMSKey); err != nil {
+		return err
+	}
+	logging.InitGlobalLogger(logging.Config{Level: "error", Format: "console"})
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	client, err := operatorClient(cfg)
+	if err != nil {
+		return err
+	}
+	checkpoint, err := maintenance.LoadCheckpoint(migrateCheckpoint)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx, release, err := lockBuckets(ctx, cfg, client, migrateBucket, migrateDestBucket)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	progress := maintenance.NewProgress(stdout, migrateProgressInterval)
+	err = maintenance.MigrateEncrypt(ctx, client, maintenance.MigrateOptions{
+		Bucket:       migrateBucket,
+		Prefix:       migratePrefix,
+		DestBucket:   migrateDestBucket,
+		KMSKeyARN:    migrateKMSKey,
+		DeleteSource: migrateDeleteSource,
+		DryRun:       migrateDryRun,
+		Rate:         migrateRate,
+		Checkpoint:   checkpoint,
+		Progress:     progress,
+	})
+	fmt.Fprintf(stdout, "done: %s\n", progress.Summary())
+	if err != nil {
+		return err
+	}
+	if failed := progress.Count(maintenance.OutcomeFailed); failed > 0 {
+		fmt.Fprintf(stderr, "%d object(s) could not be migrated\n", failed)
+		return errReported
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/maintenance"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/server"
+	"s3-vault-proxy/internal/vault"
+)
+
+var (
+	rewrapBuckets          stringList
+	rewrapPrefix           string
+	rewrapRate             float64
+	rewrapCheckpoint       string
+	rewrapProgressInterval time.Duration
+)
+
+func rewrapFlags(fs *flag.FlagSet) {
+	fs.Var(&rewrapBuckets, "bucket", "bucket to rewrap (repeatable, required)")
+	fs.StringVar(&rewrapPrefix, "prefix", "", "only re-encrypt objects under this key prefix")
+	fs.Float64Var(&rewrapRate, "rate", 50, "maximum objects per second (0 is unlimited)")
+	fs.StringVar(&rewrapCheckpoint, "checkpoint", "", "file recording progress so an interrupted run resumes where it stopped")
+	fs.DurationVar(&rewrapProgressInterval, "progress-interval", 10*time.Second, "how often progress is printed")
+}
+
+// rewrapCommand re-encrypts stored objects under new data keys, which the
+// backend's KMS wraps with the newest transit key version
+func rewrapCommand(fs *flag.FlagSet) error {
+	if len(rewrapBuckets) == 0 {
+		fmt.Fprintln(stderr, "rewrap requires at least one --bucket")
+		return errReported
+	}
+	logging.InitGlobalLogger(logging.Config{Level: "error", Format: "console"})
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	client, err := operatorClient(cfg)
+	if err != nil {
+		return err
+	}
+	checkpoint, err := maintenance.LoadCheckpoint(rewrapCheckpoint)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx, release, err := lockBuckets(ctx, cfg, client, rewrapBuckets...)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	progress := maintenance.NewProgress(stdout, rewrapProgressInterval)
+	err = maintenance.Rewrap(ctx, client, maintenance.RewrapOptions{
+		Buckets:    rewrapBuckets,
+		Prefix:     rewrapPrefix,
+		Rate:       rewrapRate,
+		Checkpoint: checkpoint,
+		Progress:   progress,
+	})
+	fmt.Fprintf(stdout, "done: %s\n", progress.Summary())
+	if err != nil {
+		return err
+	}
+	if failed := progress.Count(maintenance.OutcomeFailed); failed > 0 {
+		fmt.Fprintf(stderr, "%d object(s) could not be re-encrypted\n", failed)
+		return errReported
+	}
+	return nil
+}
+
+// operatorClient returns a backend client that signs with the operator credentials
+func operatorClient(cfg *config.Config) (s3.Interface, error) {
+	credentials, err := cfg.OperatorCredentials()
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewSigningClient(server.NewBackend(cfg), cfg.S3Endpoint, credentials)
+}
+
+// transitClient returns the Vault client for transit operations on the
+// configured mount, routing VAULT_CANARY_RATIO of encrypts to the canary mount
+func transitClient(cfg *config.Config) (*vault.Canary, error) {
+	client, err := vault.NewClient(cfg.VaultAddr, cfg.VaultToken, cfg.VaultTokenPath)
+	if err != nil {
+		return nil, err
+	}
+	primary := client.WithMount(cfg.VaultTransitMount)
+	var canary *vault.Client
+	if cfg.VaultCanaryMount != "" {
+		canary = client.WithMount(cfg.VaultCanaryMount)
+	}
+	return vault.NewCanary(primary, canary, cfg.VaultCanaryRatio), nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/maintenance"
+	"s3-vault-proxy/internal/vault"
+)
+
+var (
+	syncBucket           string
+	syncPrefix           string
+	syncDestEndpoint     string
+	syncDestRegion       string
+	syncDestCACert       string
+	syncDestBucket       string
+	syncDestKMSKey       string
+	syncWorkers          int
+	syncRate             float64
+	syncCheckpoint       string
+	syncProgressInterval time.Duration
+)
+
+func syncFlags(fs *flag.FlagSet) {
+	fs.StringVar(&syncBucket, "bucket", "", "bucket to copy (required)")
+	fs.StringVar(&syncPrefix, "prefix", "", "only copy objects under this key prefix")
+	fs.StringVar(&syncDestEndpoint, "dest-endpoint", "", "S3 endpoint of the destination backend or proxy (required)")
+	fs.StringVar(&syncDestRegion, "dest-region", "", "signing region of the destination (default: S3_REGION)")
+	fs.StringVar(&syncDestCACert, "dest-ca-cert", "", "CA certificate for the destination endpoint")
+	fs.StringVar(&syncDestBucket, "dest-bucket", "", "bucket receiving the objects (default: the source bucket name)")
+	fs.StringVar(&syncDestKMSKey, "dest-kms-key", "", "KMS key ARN to encrypt with at the destination (default: each object's key)")
+	fs.IntVar(&syncWorkers, "workers", 8, "objects copied concurrently")
+	fs.Float64Var(&syncRate, "rate", 0, "maximum objects per second (0 is unlimited)")
+	fs.StringVar(&syncCheckpoint, "checkpoint", "", "file recording progress so an interrupted run resumes where it stopped")
+	fs.DurationVar(&syncProgressInterval, "progress-interval", 10*time.Second, "how often progress is printed")
+}
+
+// syncCommand copies a bucket to another backend or proxy, optionally moving
+// its objects to a different KMS key, for region and cluster migrations.
+// Destination credentials come from SYNC_DEST_ACCESS_KEY_ID and
+// SYNC_DEST_SECRET_ACCESS_KEY, defaulting to the operator credentials.
+func syncCommand(fs *flag.FlagSet) error {
+	if syncBucket == "" || syncDestEndpoint == "" {
+		fmt.Fprintln(stderr, "sync requires --bucket and --dest-endpoint")
+		return errReported
+	}
+	if syncDestKMSKey != "" {
+		if _, err := new(vault.Client).ARNToVaultKey(syncDestKMSKey); err != nil {
+			return err
+		}
+	}
+	logging.InitGlobalLogger(logging.Config{Level: "error", Format: "console"})
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	source, err := operatorClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	destCfg := *cfg
+	destCfg.S3Endpoint = syncDestEndpoint
+	destCfg.S3CACertPath = syncDestCACert
+	if syncDestRegion != "" {
+		destCfg.S3Region = syncDestRegion
+	}
+	if accessKey := os.Getenv("SYNC_DEST_ACCESS_KEY_ID"); accessKey != "" {
+		destCfg.S3AccessKeyID = accessKey
+		destCfg.S3SecretAccessKey = os.Getenv("SYNC_DEST_SECRET_ACCESS_KEY")
+	}
+	dest, err := operatorClient(&destCfg)
+	if err != nil {
+		return err
+	}
+
+	checkpoint, err := maintenance.LoadCheckpoint(syncCheckpoint)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	progress := maintenance.NewProgress(stdout, syncProgressInterval)
+	err = maintenance.Sync(ctx, source, dest, maintenance.SyncOptions{
+		Bucket:        syncBucket,
+		Prefix:        syncPrefix,
+		DestBucket:    syncDestBucket,
+		DestKMSKeyARN: syncDestKMSKey,
+		Workers:       syncWorkers,
+		Rate:          syncRate,
+		Checkpoint:    checkpoint,
+		Progress:      progress,
+	})
+	fmt.Fprintf(stdout, "done: %s\n", progress.Summary())
+	if err != nil {
+		return err
+	}
+	if failed := progress.Count(maintenance.OutcomeFailed); failed > 0 {
+		fmt.Fprintf(stderr, "%d object(s) could not be copied\n", failed)
+		return errReported
+	}
+	return nil
+}
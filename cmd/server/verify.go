@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/maintenance"
+)
+
+var (
+	verifyBuckets          stringList
+	verifyPrefix           string
+	verifyRate             float64
+	verifyDecrypt          bool
+	verifyOutput           string
+	verifyProgressInterval time.Duration
+)
+
+func verifyFlags(fs *flag.FlagSet) {
+	fs.Var(&verifyBuckets, "bucket", "bucket to verify (repeatable, required)")
+	fs.StringVar(&verifyPrefix, "prefix", "", "only verify objects under this key prefix")
+	fs.Float64Var(&verifyRate, "rate", 50, "maximum objects per second (0 is unlimited)")
+	fs.BoolVar(&verifyDecrypt, "decrypt", true, "check that the backend decrypts every object, reading its first byte")
+	fs.StringVar(&verifyOutput, "output", "-", "file receiving the JSON lines report (- is stdout)")
+	fs.DurationVar(&verifyProgressInterval, "progress-interval", 10*time.Second, "how often progress is printed to stderr")
+}
+
+// verifyCommand audits stored objects against their metadata and reports
+// every discrepancy as a JSON line, exiting non-zero when any is found
+func verifyCommand(fs *flag.FlagSet) error {
+	if len(verifyBuckets) == 0 {
+		fmt.Fprintln(stderr, "verify requires at least one --bucket")
+		return errReported
+	}
+	logging.InitGlobalLogger(logging.Config{Level: "error", Format: "console"})
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	client, err := operatorClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	opts := maintenance.VerifyOptions{
+		Buckets:  verifyBuckets,
+		Prefix:   verifyPrefix,
+		Rate:     verifyRate,
+		Decrypt:  verifyDecrypt,
+		Report:   stdout,
+		Progress: maintenance.NewProgress(stderr, verifyProgressInterval),
+	}
+	if verifyOutput != "-" {
+		file, err := os.Create(verifyOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		defer file.Close()
+		opts.Report = file
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = maintenance.Verify(ctx, client, opts)
+	fmt.Fprintf(stderr, "done: %s\n", opts.Progress.Summary())
+	if err != nil {
+		return err
+	}
+	if problems := opts.Progress.Count(maintenance.OutcomeProblem); problems > 0 {
+		fmt.Fprintf(stderr, "%d object(s) have discrepancies\n", problems)
+		return errReported
+	}
+	return nil
+}
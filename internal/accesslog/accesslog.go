@@ -0,0 +1,177 @@
+package accesslog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeFormat is the timestamp layout used by S3 server access logs
+const timeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// Record holds the fields of one S3 server access log line.
+// Empty strings and negative sizes are written as "-".
+type Record struct {
+	BucketOwner        string
+	Bucket             string
+	Time               time.Time
+	RemoteIP           string
+	Requester          string
+	RequestID          string
+	Operation          string
+	Key                string
+	RequestURI         string
+	HTTPStatus         int
+	ErrorCode          string
+	BytesSent          int64
+	ObjectSize         int64
+	TotalTime          time.Duration
+	TurnAroundTime     time.Duration
+	Referer            string
+	UserAgent          string
+	VersionID          string
+	HostID             string
+	SignatureVersion   string
+	CipherSuite        string
+	AuthenticationType string
+	HostHeader         string
+	TLSVersion         string
+	AccessPointARN     string
+	ACLRequired        string
+}
+
+// Format renders r in the Amazon S3 server access log format
+func Format(r Record) string {
+	fields := []string{
+		field(r.BucketOwner),
+		field(r.Bucket),
+		"[" + r.Time.UTC().Format(timeFormat) + "]",
+		field(r.RemoteIP),
+		field(r.Requester),
+		field(r.RequestID),
+		field(r.Operation),
+		field(r.Key),
+		quoted(r.RequestURI),
+		strconv.Itoa(r.HTTPStatus),
+		field(r.ErrorCode),
+		size(r.BytesSent),
+		size(r.ObjectSize),
+		strconv.FormatInt(r.TotalTime.Milliseconds(), 10),
+		strconv.FormatInt(r.TurnAroundTime.Milliseconds(), 10),
+		quoted(r.Referer),
+		quoted(r.UserAgent),
+		field(r.VersionID),
+		field(r.HostID),
+		field(r.SignatureVersion),
+		field(r.CipherSuite),
+		field(r.AuthenticationType),
+		field(r.HostHeader),
+		field(r.TLSVersion),
+		field(r.AccessPointARN),
+		field(r.ACLRequired),
+	}
+	return strings.Join(fields, " ")
+}
+
+// Operation returns the S3 log operation name, e.g. REST.GET.OBJECT
+func Operation(method, bucket, key string) string {
+	resource := "OBJECT"
+	switch {
+	case bucket == "":
+		resource = "SERVICE"
+	case key == "":
+		resource = "BUCKET"
+	}
+	return "REST." + strings.ToUpper(method) + "." + resource
+}
+
+// NewRequestID returns a random request ID in the style S3 uses
+func NewRequestID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return strings.ToUpper(hex.EncodeToString(id))
+}
+
+// Logger writes access log lines to a sink
+type Logger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewLogger creates a logger writing to w
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{w: w}
+}
+
+// Open creates a logger for path, where "-" means standard output.
+// Files are opened in append mode so external log rotation keeps working.
+func Open(path string) (*Logger, error) {
+	if path == "-" {
+		return NewLogger(os.Stdout), nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open access log: %w", err)
+	}
+	return &Logger{w: file, closer: file}, nil
+}
+
+// Log writes one record
+func (l *Logger) Log(r Record) error {
+	line := Format(r) + "\n"
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := io.WriteString(l.w, line)
+	return err
+}
+
+// Close closes the underlying file, if any
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+func field(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func quoted(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+func size(value int64) string {
+	if value < 0 {
+		return "-"
+	}
+	return strconv.FormatInt(value, 10)
+}
+
+// ErrorCode extracts the <Code> element of an S3 XML error document
+func ErrorCode(body []byte) string {
+	text := string(body)
+	start := strings.Index(text, "<Code>")
+	if start < 0 {
+		return ""
+	}
+	text = text[start+len("<Code>"):]
+	end := strings.Index(text, "</Code>")
+	if end < 0 {
+		return ""
+	}
+	return text[:end]
+}
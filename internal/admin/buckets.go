@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// BucketUsage summarises data-plane traffic to one bucket
+type BucketUsage struct {
+	Bucket   string    `json:"bucket"`
+	Requests int64     `json:"requests"`
+	Writes   int64     `json:"writes"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// BucketTracker records which buckets clients are using
+type BucketTracker struct {
+	mu      sync.Mutex
+	buckets map[string]*BucketUsage
+}
+
+// NewBucketTracker creates an empty tracker
+func NewBucketTracker() *BucketTracker {
+	return &BucketTracker{buckets: make(map[string]*BucketUsage)}
+}
+
+// Observe records a request to bucket
+func (t *BucketTracker) Observe(bucket string, write bool) {
+	if bucket == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	usage, ok := t.buckets[bucket]
+	if !ok {
+		usage = &BucketUsage{Bucket: bucket}
+		t.buckets[bucket] = usage
+	}
+	usage.Requests++
+	if write {
+		usage.Writes++
+	}
+	usage.LastSeen = time.Now().UTC()
+}
+
+// Report returns usage for every bucket seen, sorted by name
+func (t *BucketTracker) Report() []BucketUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	report := make([]BucketUsage, 0, len(t.buckets))
+	for _, usage := range t.buckets {
+		report = append(report, *usage)
+	}
+	sort.Slice(report, func(i, k int) bool { return report[i].Bucket < report[k].Bucket })
+	return report
+}
@@ -0,0 +1,157 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+)
+
+// maxJobHistory bounds how many finished runs are kept for listing
+const maxJobHistory = 50
+
+// JobFunc is a background operation. The context is cancelled on shutdown.
+type JobFunc func(ctx context.Context) (interface{}, error)
+
+// JobRun is the state of one job execution
+type JobRun struct {
+	ID       int         `json:"id"`
+	Name     string      `json:"name"`
+	Status   string      `json:"status"`
+	Started  time.Time   `json:"started"`
+	Finished *time.Time  `json:"finished,omitempty"`
+	Result   interface{} `json:"result,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// Job run states
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Jobs runs registered operational jobs in the background, at most one run per job at a time
+type Jobs struct {
+	mu     sync.Mutex
+	funcs  map[string]JobFunc
+	runs   []*JobRun
+	nextID int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewJobs creates an empty job registry
+func NewJobs() *Jobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Jobs{
+		funcs:  make(map[string]JobFunc),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Register adds a job under name
+func (j *Jobs) Register(name string, fn JobFunc) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.funcs[name] = fn
+}
+
+// Names returns the registered job names
+func (j *Jobs) Names() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	names := make([]string, 0, len(j.funcs))
+	for name := range j.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Start runs a job in the background, refusing if it is unknown or already running
+func (j *Jobs) Start(name string) (JobRun, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	fn, ok := j.funcs[name]
+	if !ok {
+		return JobRun{}, fmt.Errorf("unknown job %q", name)
+	}
+	for _, run := range j.runs {
+		if run.Name == name && run.Status == JobRunning {
+			return JobRun{}, fmt.Errorf("job %q is already running (id %d)", name, run.ID)
+		}
+	}
+
+	j.nextID++
+	run := &JobRun{ID: j.nextID, Name: name, Status: JobRunning, Started: time.Now().UTC()}
+	j.runs = append(j.runs, run)
+	if len(j.runs) > maxJobHistory {
+		j.runs = j.runs[len(j.runs)-maxJobHistory:]
+	}
+
+	logging.Info().Str("job", name).Int("id", run.ID).Msg("Admin job started")
+	go j.execute(run, fn)
+	return *run, nil
+}
+
+func (j *Jobs) execute(run *JobRun, fn JobFunc) {
+	result, err := fn(j.ctx)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	finished := time.Now().UTC()
+	run.Finished = &finished
+	run.Result = result
+	run.Status = JobSucceeded
+	if err != nil {
+		run.Status = JobFailed
+		run.Error = err.Error()
+		logging.Error().Err(err).Str("job", run.Name).Int("id", run.ID).Msg("Admin job failed")
+		return
+	}
+	logging.Info().Str("job", run.Name).Int("id", run.ID).Dur("duration", finished.Sub(run.Started)).Msg("Admin job finished")
+}
+
+// List returns job runs, most recent first
+func (j *Jobs) List() []JobRun {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	runs := make([]JobRun, 0, len(j.runs))
+	for i := len(j.runs) - 1; i >= 0; i-- {
+		runs = append(runs, *j.runs[i])
+	}
+	return runs
+}
+
+// Cancel signals running jobs to stop
+func (j *Jobs) Cancel() {
+	j.cancel()
+}
+
+// ServeHTTP lists jobs and runs (GET) or starts a job (POST ?name=<job>)
+func (j *Jobs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"jobs": j.Names(),
+			"runs": j.List(),
+		})
+	case http.MethodPost:
+		run, err := j.Start(r.URL.Query().Get("name"))
+		if err != nil {
+			WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		WriteJSON(w, http.StatusAccepted, run)
+	default:
+		WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
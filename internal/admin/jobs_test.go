@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobsRunAndRecordResult(t *testing.T) {
+	jobs := NewJobs()
+	jobs.Register("ok", func(ctx context.Context) (interface{}, error) { return 42, nil })
+	jobs.Register("fail", func(ctx context.Context) (interface{}, error) { return nil, errors.New("boom") })
+
+	_, err := jobs.Start("missing")
+	assert.Error(t, err)
+
+	_, err = jobs.Start("ok")
+	require.NoError(t, err)
+	_, err = jobs.Start("fail")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		for _, run := range jobs.List() {
+			if run.Status == JobRunning {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	runs := jobs.List()
+	require.Len(t, runs, 2)
+	assert.Equal(t, JobFailed, runs[0].Status)
+	assert.Equal(t, "boom", runs[0].Error)
+	assert.Equal(t, JobSucceeded, runs[1].Status)
+	assert.Equal(t, 42, runs[1].Result)
+}
+
+func TestJobsRefuseConcurrentRuns(t *testing.T) {
+	jobs := NewJobs()
+	jobs.Register("slow", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	_, err := jobs.Start("slow")
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	jobs.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs?name=slow", nil))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	jobs.Cancel()
+	require.Eventually(t, func() bool { return jobs.List()[0].Status == JobFailed }, time.Second, 10*time.Millisecond)
+}
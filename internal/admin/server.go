@@ -0,0 +1,145 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+)
+
+// Server is the operational HTTP listener, kept apart from the S3 data plane
+type Server struct {
+	mux    *http.ServeMux
+	server *http.Server
+	jobs   *Jobs
+}
+
+// NewServer creates an admin server bound to addr once started
+func NewServer(addr string) *Server {
+	mux := http.NewServeMux()
+	s := &Server{
+		mux: mux,
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		jobs: NewJobs(),
+	}
+	mux.HandleFunc("/jobs", s.jobs.ServeHTTP)
+	return s
+}
+
+// RequireToken rejects requests that do not carry "Authorization: Bearer <token>"
+func (s *Server) RequireToken(token string) {
+	s.server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="s3-vault-proxy-admin"`)
+			WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		s.mux.ServeHTTP(w, r)
+	})
+}
+
+// RegisterJob makes a background job triggerable with POST /jobs?name=<name>
+func (s *Server) RegisterJob(name string, fn JobFunc) {
+	s.jobs.Register(name, fn)
+}
+
+// Handle registers a handler on the admin listener
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// HandleFunc registers a handler function on the admin listener
+func (s *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// EnableProfiling exposes net/http/pprof and runtime statistics under /debug/
+func (s *Server) EnableProfiling() {
+	s.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	s.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	s.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	s.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	s.mux.HandleFunc("/debug/runtime", runtimeStats)
+	s.mux.HandleFunc("/debug/gc", forceGC)
+}
+
+// Start listens in the background. Bind errors are returned immediately.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	logging.Info().Str("addr", listener.Addr().String()).Msg("Admin listener started")
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Error().Err(err).Msg("Admin listener failed")
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the admin listener and cancels running jobs
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.jobs.Cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// runtimeStats reports memory and GC statistics as JSON
+func runtimeStats(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"goroutines":      runtime.NumGoroutine(),
+		"gomaxprocs":      runtime.GOMAXPROCS(0),
+		"heap_alloc":      mem.HeapAlloc,
+		"heap_inuse":      mem.HeapInuse,
+		"heap_idle":       mem.HeapIdle,
+		"heap_released":   mem.HeapReleased,
+		"heap_objects":    mem.HeapObjects,
+		"stack_inuse":     mem.StackInuse,
+		"sys":             mem.Sys,
+		"total_alloc":     mem.TotalAlloc,
+		"mallocs":         mem.Mallocs,
+		"frees":           mem.Frees,
+		"num_gc":          mem.NumGC,
+		"gc_cpu_fraction": mem.GCCPUFraction,
+		"pause_total_ns":  mem.PauseTotalNs,
+		"last_gc":         time.Unix(0, int64(mem.LastGC)).UTC(),
+		"next_gc":         mem.NextGC,
+		"last_pause_ns":   mem.PauseNs[(mem.NumGC+255)%256],
+	})
+}
+
+// forceGC runs a collection and returns heap memory to the OS (POST only)
+func forceGC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+	debug.FreeOSMemory()
+	runtimeStats(w, r)
+}
+
+// WriteJSON writes v as a JSON response
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,86 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfilingDisabledByDefault(t *testing.T) {
+	server := NewServer("127.0.0.1:0")
+
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestEnableProfiling(t *testing.T) {
+	server := NewServer("127.0.0.1:0")
+	server.EnableProfiling()
+
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/runtime", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var stats map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Contains(t, stats, "heap_alloc")
+	assert.Contains(t, stats, "goroutines")
+}
+
+func TestForceGCRequiresPost(t *testing.T) {
+	server := NewServer("127.0.0.1:0")
+	server.EnableProfiling()
+
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/gc", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/gc", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRequireToken(t *testing.T) {
+	server := NewServer("127.0.0.1:0")
+	server.RequireToken("s3cret")
+
+	for _, auth := range []string{"", "Bearer wrong", "s3cret"} {
+		req := httptest.NewRequest(http.MethodGet, "/jobs", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		server.server.Handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "authorization %q", auth)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/jobs", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	server.server.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestBucketTracker(t *testing.T) {
+	tracker := NewBucketTracker()
+	tracker.Observe("logs", false)
+	tracker.Observe("backups", true)
+	tracker.Observe("logs", true)
+	tracker.Observe("", true)
+
+	report := tracker.Report()
+	require.Len(t, report, 2)
+	assert.Equal(t, "backups", report[0].Bucket)
+	assert.Equal(t, "logs", report[1].Bucket)
+	assert.Equal(t, int64(2), report[1].Requests)
+	assert.Equal(t, int64(1), report[1].Writes)
+}
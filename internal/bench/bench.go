@@ -0,0 +1,306 @@
+// Package bench drives a configurable mix of S3 operations against a running
+// proxy and summarizes throughput and latency for capacity planning.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/s3"
+)
+
+// Operations a benchmark can mix
+const (
+	OpPut  = "put"
+	OpGet  = "get"
+	OpList = "list"
+)
+
+// Mix weights each operation; ops are chosen at random in proportion
+type Mix map[string]int
+
+// ParseMix parses weights such as "put=50,get=40,list=10"
+func ParseMix(value string) (Mix, error) {
+	mix := make(Mix)
+	for _, part := range strings.Split(value, ",") {
+		op, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mix entry %q, expected op=weight", part)
+		}
+		if op != OpPut && op != OpGet && op != OpList {
+			return nil, fmt.Errorf("unknown operation %q in mix", op)
+		}
+		n, err := strconv.Atoi(weight)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid weight %q for %s", weight, op)
+		}
+		mix[op] = n
+	}
+	total := 0
+	for _, weight := range mix {
+		total += weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("mix %q has no weight", value)
+	}
+	return mix, nil
+}
+
+// pick chooses an operation for a random number in [0, total weight)
+func (m Mix) pick(r *mathrand.Rand) string {
+	ops := []string{OpPut, OpGet, OpList}
+	total := 0
+	for _, op := range ops {
+		total += m[op]
+	}
+	n := r.Intn(total)
+	for _, op := range ops {
+		if n < m[op] {
+			return op
+		}
+		n -= m[op]
+	}
+	return OpPut
+}
+
+// Options configures a benchmark run
+type Options struct {
+	Bucket      string
+	Prefix      string // keys are written as <prefix><n>
+	KMSKeyARN   string
+	ObjectSize  int64
+	Keys        int // size of the key space PUTs and GETs draw from
+	Mix         Mix
+	Concurrency int
+	Duration    time.Duration
+	Cleanup     bool // delete the key space afterwards
+}
+
+// OpStats summarizes one operation type
+type OpStats struct {
+	Count     int
+	Errors    int
+	Bytes     int64
+	latencies []time.Duration
+}
+
+// Percentile returns the latency at p (0-100) of successful operations
+func (s *OpStats) Percentile(p float64) time.Duration {
+	if len(s.latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(s.latencies)-1) * p / 100)
+	return s.latencies[i]
+}
+
+// Result holds the statistics of a run
+type Result struct {
+	Elapsed time.Duration
+	Ops     map[string]*OpStats
+}
+
+// Write prints a table of throughput and latency percentiles per operation
+func (r *Result) Write(w io.Writer) {
+	fmt.Fprintf(w, "%-6s %8s %7s %10s %10s %10s %10s %10s\n", "op", "count", "errors", "ops/s", "MiB/s", "p50", "p95", "p99")
+	seconds := r.Elapsed.Seconds()
+	for _, op := range []string{OpPut, OpGet, OpList} {
+		stats, ok := r.Ops[op]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%-6s %8d %7d %10.1f %10.2f %10s %10s %10s\n", op, stats.Count, stats.Errors,
+			float64(stats.Count)/seconds, float64(stats.Bytes)/seconds/(1<<20),
+			stats.Percentile(50).Round(time.Microsecond), stats.Percentile(95).Round(time.Microsecond),
+			stats.Percentile(99).Round(time.Microsecond))
+	}
+}
+
+// sample is the outcome of one operation
+type sample struct {
+	op      string
+	latency time.Duration
+	bytes   int64
+	err     error
+}
+
+// Run seeds the key space when the mix reads objects, then issues operations
+// from Concurrency workers until Duration has passed or ctx is cancelled
+func Run(ctx context.Context, client s3.Interface, opts Options) (*Result, error) {
+	if opts.Keys < 1 {
+		opts.Keys = 1
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	payload := make([]byte, opts.ObjectSize)
+	rand.Read(payload)
+
+	if opts.Mix[OpGet] > 0 {
+		for n := 0; n < opts.Keys; n++ {
+			if _, err := put(ctx, client, opts, payload, n); err != nil {
+				return nil, fmt.Errorf("failed to seed objects: %w", err)
+			}
+		}
+	}
+	if opts.Cleanup {
+		defer cleanup(context.WithoutCancel(ctx), client, opts)
+	}
+
+	// Operations in flight when the run ends are allowed to finish
+	run, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	samples := make(chan sample, opts.Concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := mathrand.New(mathrand.NewSource(seed))
+			for run.Err() == nil {
+				op := opts.Mix.pick(r)
+				start := time.Now()
+				var n int64
+				var err error
+				switch op {
+				case OpPut:
+					n, err = put(ctx, client, opts, payload, r.Intn(opts.Keys))
+				case OpGet:
+					n, err = get(ctx, client, opts, r.Intn(opts.Keys))
+				case OpList:
+					n, err = list(ctx, client, opts)
+				}
+				samples <- sample{op: op, latency: time.Since(start), bytes: n, err: err}
+			}
+		}(time.Now().UnixNano() + int64(i))
+	}
+	go func() {
+		wg.Wait()
+		close(samples)
+	}()
+
+	started := time.Now()
+	result := &Result{Ops: make(map[string]*OpStats)}
+	for s := range samples {
+		stats, ok := result.Ops[s.op]
+		if !ok {
+			stats = &OpStats{}
+			result.Ops[s.op] = stats
+		}
+		stats.Count++
+		if s.err != nil {
+			stats.Errors++
+			continue
+		}
+		stats.Bytes += s.bytes
+		stats.latencies = append(stats.latencies, s.latency)
+	}
+	result.Elapsed = time.Since(started)
+	for _, stats := range result.Ops {
+		sort.Slice(stats.latencies, func(i, j int) bool { return stats.latencies[i] < stats.latencies[j] })
+	}
+	return result, nil
+}
+
+func objectPath(opts Options, n int) string {
+	return s3.ObjectPath(opts.Bucket, opts.Prefix+strconv.Itoa(n))
+}
+
+func put(ctx context.Context, client s3.Interface, opts Options, payload []byte, n int) (int64, error) {
+	headers := http.Header{
+		"Content-Length":                              {strconv.Itoa(len(payload))},
+		"X-Amz-Server-Side-Encryption":                {"aws:kms"},
+		"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": {opts.KMSKeyARN},
+	}
+	resp, err := client.ForwardRequest(ctx, "PUT", objectPath(opts, n), bytes.NewReader(payload), headers, nil)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(payload)), drain(resp)
+}
+
+func get(ctx context.Context, client s3.Interface, opts Options, n int) (int64, error) {
+	resp, err := client.ForwardRequest(ctx, "GET", objectPath(opts, n), nil, http.Header{}, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	read, err := io.Copy(io.Discard, resp.Body)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return read, err
+}
+
+func list(ctx context.Context, client s3.Interface, opts Options) (int64, error) {
+	query := url.Values{"list-type": {"2"}, "prefix": {opts.Prefix}, "max-keys": {"100"}}
+	resp, err := client.ForwardRequest(ctx, "GET", "/"+opts.Bucket, nil, http.Header{}, []byte(query.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	return 0, drain(resp)
+}
+
+// drain discards a response body and turns error statuses into errors
+func drain(resp *http.Response) error {
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// cleanup deletes every key a run may have written
+func cleanup(ctx context.Context, client s3.Interface, opts Options) {
+	for n := 0; n < opts.Keys; n++ {
+		if resp, err := client.ForwardRequest(ctx, "DELETE", objectPath(opts, n), nil, http.Header{}, nil); err == nil {
+			drain(resp)
+		}
+	}
+}
+
+// VaultOperations sums the encrypt and decrypt operations a proxy reports on
+// its admin listener's /kms/keys endpoint
+func VaultOperations(adminURL, token string) (int64, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(adminURL, "/")+"/kms/keys", nil)
+	if err != nil {
+		return 0, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read key usage: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to read key usage: HTTP %d", resp.StatusCode)
+	}
+
+	var keys []struct {
+		Encrypts int64 `json:"encrypts"`
+		Decrypts int64 `json:"decrypts"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		return 0, fmt.Errorf("failed to decode key usage: %w", err)
+	}
+	var total int64
+	for _, key := range keys {
+		total += key.Encrypts + key.Decrypts
+	}
+	return total, nil
+}
@@ -0,0 +1,102 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/s3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMix(t *testing.T) {
+	mix, err := ParseMix("put=50, get=40,list=10")
+	require.NoError(t, err)
+	assert.Equal(t, Mix{OpPut: 50, OpGet: 40, OpList: 10}, mix)
+
+	for _, invalid := range []string{"put", "copy=1", "put=-1", "put=0,get=0"} {
+		_, err := ParseMix(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRun(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			assert.NotEmpty(t, r.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			if r.URL.Query().Get("list-type") == "2" {
+				w.Write([]byte("<ListBucketResult/>"))
+				return
+			}
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(body)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+	client := s3.NewClient(server.URL, "", s3.DefaultTransportConfig())
+
+	result, err := Run(context.Background(), client, Options{
+		Bucket:      "bucket",
+		Prefix:      "bench/",
+		KMSKeyARN:   "arn:aws:kms:us-east-1:123456789012:key/bench",
+		ObjectSize:  1024,
+		Keys:        4,
+		Mix:         Mix{OpPut: 1, OpGet: 1, OpList: 1},
+		Concurrency: 2,
+		Duration:    200 * time.Millisecond,
+		Cleanup:     true,
+	})
+	require.NoError(t, err)
+
+	for _, op := range []string{OpPut, OpGet, OpList} {
+		require.Contains(t, result.Ops, op)
+		assert.Positive(t, result.Ops[op].Count, op)
+		assert.Zero(t, result.Ops[op].Errors, op)
+	}
+	assert.Equal(t, int64(result.Ops[OpGet].Count)*1024, result.Ops[OpGet].Bytes)
+	assert.LessOrEqual(t, result.Ops[OpGet].Percentile(50), result.Ops[OpGet].Percentile(99))
+	assert.Empty(t, objects, "cleanup removes the key space")
+
+	var report bytes.Buffer
+	result.Write(&report)
+	assert.Contains(t, report.String(), "p99")
+}
+
+func TestVaultOperations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`[{"encrypts":3,"decrypts":4},{"encrypts":1,"decrypts":0}]`))
+	}))
+	defer server.Close()
+
+	total, err := VaultOperations(server.URL, "secret")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), total)
+
+	_, err = VaultOperations(server.URL, "wrong")
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+// Package bucketheaders adds configured response headers to object reads,
+// such as a default Cache-Control and browser security headers for buckets
+// serving static assets. Headers are configured per bucket, with Wildcard
+// supplying them for every bucket that does not set its own.
+package bucketheaders
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Wildcard configures the headers of buckets without their own
+const Wildcard = "*"
+
+// reserved are headers describing the object itself, which must come from
+// the backend and the object's metadata
+var reserved = map[string]bool{
+	"Accept-Ranges":     true,
+	"Content-Length":    true,
+	"Content-Range":     true,
+	"Content-Type":      true,
+	"Content-Encoding":  true,
+	"Etag":              true,
+	"Last-Modified":     true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+// Bucket is the headers added to reads of one bucket's objects
+type Bucket struct {
+	Bucket  string            `json:"bucket"`
+	Headers map[string]string `json:"headers"`
+}
+
+// Set holds the headers of every configured bucket
+type Set struct {
+	headers map[string]http.Header
+}
+
+// Load reads bucket headers from a JSON file of the form {"buckets": [...]}
+func Load(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bucket headers file: %w", err)
+	}
+	var file struct {
+		Buckets []Bucket `json:"buckets"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse bucket headers file %s: %w", path, err)
+	}
+	return New(file.Buckets)
+}
+
+// New validates buckets and indexes their headers by bucket
+func New(buckets []Bucket) (*Set, error) {
+	set := &Set{headers: make(map[string]http.Header, len(buckets))}
+	for _, bucket := range buckets {
+		if bucket.Bucket == "" {
+			return nil, fmt.Errorf("every bucket headers entry needs a bucket")
+		}
+		if _, ok := set.headers[bucket.Bucket]; ok {
+			return nil, fmt.Errorf("bucket %s is listed twice", bucket.Bucket)
+		}
+		header := make(http.Header, len(bucket.Headers))
+		for name, value := range bucket.Headers {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			switch {
+			case name == "":
+				return nil, fmt.Errorf("bucket %s has a header without a name", bucket.Bucket)
+			case reserved[name] || strings.HasPrefix(name, "X-Amz-"):
+				return nil, fmt.Errorf("bucket %s cannot set %s, which describes the object", bucket.Bucket, name)
+			case strings.ContainsAny(value, "\r\n"):
+				return nil, fmt.Errorf("bucket %s has a line break in %s", bucket.Bucket, name)
+			}
+			header.Set(name, value)
+		}
+		set.headers[bucket.Bucket] = header
+	}
+	return set, nil
+}
+
+// Len returns the number of configured buckets, including Wildcard
+func (s *Set) Len() int {
+	return len(s.headers)
+}
+
+// Apply adds the headers configured for bucket to header. Headers the
+// response already has, such as a Cache-Control stored with the object,
+// are kept.
+func (s *Set) Apply(bucket string, header http.Header) {
+	configured, ok := s.headers[bucket]
+	if !ok {
+		configured = s.headers[Wildcard]
+	}
+	for name, values := range configured {
+		if header.Get(name) == "" {
+			header[name] = values
+		}
+	}
+}
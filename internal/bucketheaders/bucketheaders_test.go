@@ -0,0 +1,55 @@
+package bucketheaders
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetApply(t *testing.T) {
+	set, err := New([]Bucket{
+		{Bucket: "assets", Headers: map[string]string{
+			"cache-control":           "public, max-age=3600",
+			"X-Content-Type-Options":  "nosniff",
+			"Content-Security-Policy": "default-src 'self'",
+		}},
+		{Bucket: Wildcard, Headers: map[string]string{"X-Content-Type-Options": "nosniff"}},
+	})
+	require.NoError(t, err)
+
+	header := http.Header{}
+	set.Apply("assets", header)
+	assert.Equal(t, "public, max-age=3600", header.Get("Cache-Control"))
+	assert.Equal(t, "default-src 'self'", header.Get("Content-Security-Policy"))
+
+	header = http.Header{"Cache-Control": {"no-store"}}
+	set.Apply("assets", header)
+	assert.Equal(t, "no-store", header.Get("Cache-Control"), "the object's own headers win")
+
+	header = http.Header{}
+	set.Apply("other", header)
+	assert.Equal(t, http.Header{"X-Content-Type-Options": {"nosniff"}}, header, "the wildcard covers other buckets")
+}
+
+func TestNewRejectsObjectHeaders(t *testing.T) {
+	for _, name := range []string{"Content-Length", "ETag", "x-amz-server-side-encryption", ""} {
+		_, err := New([]Bucket{{Bucket: "assets", Headers: map[string]string{name: "x"}}})
+		assert.Error(t, err, name)
+	}
+	_, err := New([]Bucket{{Bucket: "assets", Headers: map[string]string{"X-Frame-Options": "DENY\r\nSet-Cookie: a=b"}}})
+	assert.Error(t, err)
+	_, err = New([]Bucket{{Bucket: "assets"}, {Bucket: "assets"}})
+	assert.Error(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "headers.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"buckets": [{"bucket": "assets", "headers": {"X-Frame-Options": "DENY"}}]}`), 0o600))
+	set, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, set.Len())
+}
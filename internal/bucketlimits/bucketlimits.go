@@ -0,0 +1,218 @@
+// Package bucketlimits restricts the uploads a bucket accepts: the largest
+// object, the longest key and the number of keys it may hold. Limits are
+// configured per bucket, with Wildcard supplying them for every bucket that
+// does not set its own.
+package bucketlimits
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/s3"
+)
+
+// Wildcard configures the limits of buckets without their own
+const Wildcard = "*"
+
+var (
+	// ErrObjectTooLarge is returned for uploads over the bucket's maximum object size
+	ErrObjectTooLarge = errors.New("object exceeds the bucket's maximum size")
+
+	// ErrKeyTooLong is returned for keys over the bucket's maximum key length
+	ErrKeyTooLong = errors.New("key exceeds the bucket's maximum length")
+
+	// ErrTooManyKeys is returned for uploads to a bucket holding its maximum number of keys
+	ErrTooManyKeys = errors.New("bucket holds its maximum number of keys")
+)
+
+// Limits are the restrictions on uploads to one bucket. Zero means unlimited.
+type Limits struct {
+	MaxObjectSize int64 `json:"max_object_size,omitempty"`
+	MaxKeyLength  int   `json:"max_key_length,omitempty"`
+	MaxKeys       int64 `json:"max_keys,omitempty"`
+}
+
+// Set holds the limits of every configured bucket and the key counts of
+// buckets with a maximum number of keys
+type Set struct {
+	limits map[string]Limits
+
+	mu   sync.Mutex
+	keys map[string]int64
+}
+
+// Parse builds limits from bucket=value maps as read from the environment.
+// It returns nil when no limit is configured.
+func Parse(maxObjectSize, maxKeyLength, maxKeys map[string]string) (*Set, error) {
+	limits := make(map[string]Limits)
+	for bucket, value := range maxObjectSize {
+		size, err := parsePositive(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maximum object size for %s: %w", bucket, err)
+		}
+		l := limits[bucket]
+		l.MaxObjectSize = size
+		limits[bucket] = l
+	}
+	for bucket, value := range maxKeyLength {
+		length, err := parsePositive(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maximum key length for %s: %w", bucket, err)
+		}
+		l := limits[bucket]
+		l.MaxKeyLength = int(length)
+		limits[bucket] = l
+	}
+	for bucket, value := range maxKeys {
+		count, err := parsePositive(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maximum number of keys for %s: %w", bucket, err)
+		}
+		l := limits[bucket]
+		l.MaxKeys = count
+		limits[bucket] = l
+	}
+	if len(limits) == 0 {
+		return nil, nil
+	}
+	return &Set{limits: limits, keys: make(map[string]int64)}, nil
+}
+
+func parsePositive(value string) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q is not a positive integer", value)
+	}
+	return n, nil
+}
+
+// For returns the limits of bucket. Limits the bucket does not set come from
+// the wildcard.
+func (s *Set) For(bucket string) Limits {
+	if s == nil {
+		return Limits{}
+	}
+	l := s.limits[bucket]
+	wildcard := s.limits[Wildcard]
+	if l.MaxObjectSize == 0 {
+		l.MaxObjectSize = wildcard.MaxObjectSize
+	}
+	if l.MaxKeyLength == 0 {
+		l.MaxKeyLength = wildcard.MaxKeyLength
+	}
+	if l.MaxKeys == 0 {
+		l.MaxKeys = wildcard.MaxKeys
+	}
+	return l
+}
+
+// Check reports whether bucket accepts an upload of size bytes to key. A
+// negative size is unknown and not checked.
+func (s *Set) Check(bucket, key string, size int64) error {
+	l := s.For(bucket)
+	if l.MaxKeyLength > 0 && len(key) > l.MaxKeyLength {
+		return ErrKeyTooLong
+	}
+	if l.MaxObjectSize > 0 && size > l.MaxObjectSize {
+		return ErrObjectTooLarge
+	}
+	if l.MaxKeys > 0 {
+		s.mu.Lock()
+		count := s.keys[bucket]
+		s.mu.Unlock()
+		if count >= l.MaxKeys {
+			return ErrTooManyKeys
+		}
+	}
+	return nil
+}
+
+// CountsKeys reports whether any bucket limits its number of keys
+func (s *Set) CountsKeys() bool {
+	if s == nil {
+		return false
+	}
+	for _, l := range s.limits {
+		if l.MaxKeys > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// AddKey accounts for an upload to bucket until the next refresh recounts it.
+// Overwrites are counted as new keys, so the count errs on the high side.
+func (s *Set) AddKey(bucket string) {
+	if s.For(bucket).MaxKeys == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[bucket]++
+}
+
+// RefreshKeys recounts the keys of every bucket with a maximum number of
+// keys. Metadata objects are not counted.
+func (s *Set) RefreshKeys(ctx context.Context, client s3.Interface) error {
+	buckets, err := s3.ListBuckets(ctx, client)
+	if err != nil {
+		return err
+	}
+	counts := make(map[string]int64)
+	for _, bucket := range buckets {
+		if s.For(bucket).MaxKeys == 0 {
+			continue
+		}
+		var count int64
+		err := s3.WalkObjects(ctx, client, bucket, "", "", func(object s3.ObjectInfo) error {
+			if !metadata.IsMetadataKey(object.Key) {
+				count++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		counts[bucket] = count
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = counts
+	return nil
+}
+
+// BucketReport is the limits and key count of one bucket for the admin API
+type BucketReport struct {
+	Bucket string `json:"bucket"`
+	Limits
+	Keys *int64 `json:"keys,omitempty"`
+}
+
+// Report lists the limits of every configured or counted bucket, sorted by name
+func (s *Set) Report() []BucketReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buckets := make(map[string]bool, len(s.limits)+len(s.keys))
+	for bucket := range s.limits {
+		buckets[bucket] = true
+	}
+	for bucket := range s.keys {
+		buckets[bucket] = true
+	}
+	report := make([]BucketReport, 0, len(buckets))
+	for bucket := range buckets {
+		entry := BucketReport{Bucket: bucket, Limits: s.For(bucket)}
+		if count, ok := s.keys[bucket]; ok {
+			entry.Keys = &count
+		}
+		report = append(report, entry)
+	}
+	sort.Slice(report, func(i, k int) bool { return report[i].Bucket < report[k].Bucket })
+	return report
+}
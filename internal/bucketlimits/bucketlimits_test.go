@@ -0,0 +1,62 @@
+package bucketlimits
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	set, err := Parse(nil, nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, set)
+
+	_, err = Parse(map[string]string{"b": "ten"}, nil, nil)
+	assert.ErrorContains(t, err, "maximum object size for b")
+
+	_, err = Parse(nil, map[string]string{"b": "0"}, nil)
+	assert.ErrorContains(t, err, "maximum key length for b")
+}
+
+func TestFor(t *testing.T) {
+	set, err := Parse(
+		map[string]string{"*": "1000", "small": "10"},
+		map[string]string{"small": "5"},
+		map[string]string{"*": "3"},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, Limits{MaxObjectSize: 10, MaxKeyLength: 5, MaxKeys: 3}, set.For("small"))
+	assert.Equal(t, Limits{MaxObjectSize: 1000, MaxKeys: 3}, set.For("other"))
+
+	var unset *Set
+	assert.Equal(t, Limits{}, unset.For("small"))
+	assert.NoError(t, unset.Check("small", "key", 1<<40))
+}
+
+func TestCheck(t *testing.T) {
+	set, err := Parse(
+		map[string]string{"b": "10"},
+		map[string]string{"b": "5"},
+		map[string]string{"b": "2"},
+	)
+	require.NoError(t, err)
+	assert.True(t, set.CountsKeys())
+
+	assert.NoError(t, set.Check("b", "key", 10))
+	assert.NoError(t, set.Check("b", "key", -1), "unknown sizes are not checked")
+	assert.ErrorIs(t, set.Check("b", "key", 11), ErrObjectTooLarge)
+	assert.ErrorIs(t, set.Check("b", "toolong", 1), ErrKeyTooLong)
+	assert.NoError(t, set.Check("other", "toolong", 11))
+
+	set.AddKey("b")
+	set.AddKey("b")
+	set.AddKey("other")
+	assert.ErrorIs(t, set.Check("b", "key", 1), ErrTooManyKeys)
+
+	report := set.Report()
+	require.Len(t, report, 1)
+	require.NotNil(t, report[0].Keys)
+	assert.Equal(t, int64(2), *report[0].Keys)
+}
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"s3-vault-proxy/internal/logging"
+)
+
+// diskFileSuffix marks files owned by the disk cache inside its directory
+const diskFileSuffix = ".cache"
+
+// diskEntry is the in-memory index record for a body stored on disk
+type diskEntry struct {
+	key    string
+	etag   string
+	header http.Header
+	path   string
+	size   int64
+}
+
+// Disk is an LRU cache tier that keeps object bodies in local files.
+// Bodies are sealed with AES-GCM under a key generated at startup, so cache
+// files are unreadable by anything but the running process.
+type Disk struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	size     int64
+	aead     cipher.AEAD
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+// NewDisk creates a disk cache in dir holding at most maxBytes of object data.
+// Cache files left behind by a previous process are removed.
+func NewDisk(dir string, maxBytes int64) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	stale, err := filepath.Glob(filepath.Join(dir, "*"+diskFileSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan cache directory: %w", err)
+	}
+	for _, path := range stale {
+		_ = os.Remove(path)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate cache key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache cipher: %w", err)
+	}
+
+	return &Disk{
+		dir:      dir,
+		maxBytes: maxBytes,
+		aead:     aead,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}, nil
+}
+
+// Get reads and decrypts the entry for key
+func (d *Disk) Get(key string) (*Entry, bool) {
+	d.mu.Lock()
+	elem, ok := d.items[key]
+	if !ok {
+		d.mu.Unlock()
+		return nil, false
+	}
+	d.order.MoveToFront(elem)
+	record := *elem.Value.(*diskEntry)
+	d.mu.Unlock()
+
+	sealed, err := os.ReadFile(record.path)
+	if err != nil {
+		d.Delete(key)
+		return nil, false
+	}
+
+	nonceSize := d.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		d.Delete(key)
+		return nil, false
+	}
+	body, err := d.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], additionalData(key, record.etag))
+	if err != nil {
+		logging.Warn().Err(err).Str("cache_key", key).Msg("Discarding unreadable disk cache entry")
+		d.Delete(key)
+		return nil, false
+	}
+
+	return &Entry{ETag: record.etag, Header: record.header, Body: body, key: key}, true
+}
+
+// Set encrypts the entry body to disk, evicting least recently used files as needed
+func (d *Disk) Set(key string, entry *Entry) {
+	if entry.Size() > d.maxBytes {
+		return
+	}
+
+	nonce := make([]byte, d.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return
+	}
+	sealed := d.aead.Seal(nonce, nonce, entry.Body, additionalData(key, entry.ETag))
+
+	sum := sha256.Sum256([]byte(key))
+	path := filepath.Join(d.dir, hex.EncodeToString(sum[:])+diskFileSuffix)
+	tmp, err := os.CreateTemp(d.dir, "tmp-*")
+	if err != nil {
+		logging.Warn().Err(err).Msg("Failed to create disk cache file")
+		return
+	}
+	_, writeErr := tmp.Write(sealed)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if elem, ok := d.items[key]; ok {
+		d.removeElement(elem, false)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+
+	d.items[key] = d.order.PushFront(&diskEntry{
+		key:    key,
+		etag:   entry.ETag,
+		header: entry.Header,
+		path:   path,
+		size:   entry.Size(),
+	})
+	d.size += entry.Size()
+
+	for d.size > d.maxBytes {
+		d.removeElement(d.order.Back(), true)
+	}
+}
+
+// Delete removes the entry for key and its file
+func (d *Disk) Delete(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if elem, ok := d.items[key]; ok {
+		d.removeElement(elem, true)
+	}
+}
+
+// Len returns the number of cached entries
+func (d *Disk) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.items)
+}
+
+// Size returns the total number of cached plaintext bytes
+func (d *Disk) Size() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.size
+}
+
+func (d *Disk) removeElement(elem *list.Element, removeFile bool) {
+	record := elem.Value.(*diskEntry)
+	d.order.Remove(elem)
+	delete(d.items, record.key)
+	d.size -= record.size
+	if removeFile && strings.HasSuffix(record.path, diskFileSuffix) {
+		_ = os.Remove(record.path)
+	}
+}
+
+// additionalData binds a cache file to its key and ETag so a file can never be
+// served for a different object or object version
+func additionalData(key, etag string) []byte {
+	return []byte(key + "\x00" + etag)
+}
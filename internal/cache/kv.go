@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// KV is a byte-oriented key/value store with per-key expiry. It backs caches
+// that can be shared between proxy replicas.
+type KV interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+}
+
+type memoryValue struct {
+	value   []byte
+	expires time.Time
+}
+
+// MemoryKV is a process-local KV implementation
+type MemoryKV struct {
+	mu     sync.Mutex
+	values map[string]memoryValue
+	now    func() time.Time
+}
+
+// NewMemoryKV creates an empty in-memory KV store
+func NewMemoryKV() *MemoryKV {
+	return &MemoryKV{
+		values: make(map[string]memoryValue),
+		now:    time.Now,
+	}
+}
+
+// Get returns the value for key if present and not expired
+func (m *MemoryKV) Get(key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	v, ok := m.values[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !v.expires.IsZero() && m.now().After(v.expires) {
+		delete(m.values, key)
+		return nil, false, nil
+	}
+	return v.value, true, nil
+}
+
+// Set stores value under key; a zero ttl never expires
+func (m *MemoryKV) Set(key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	v := memoryValue{value: value}
+	if ttl > 0 {
+		v.expires = m.now().Add(ttl)
+	}
+	m.values[key] = v
+
+	// Opportunistically drop expired values so the map cannot grow without bound
+	if len(m.values)%1024 == 0 {
+		now := m.now()
+		for k, existing := range m.values {
+			if !existing.expires.IsZero() && now.After(existing.expires) {
+				delete(m.values, k)
+			}
+		}
+	}
+	return nil
+}
+
+// Delete removes key
+func (m *MemoryKV) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, key)
+	return nil
+}
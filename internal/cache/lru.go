@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Entry is a cached object body with the response headers it was served with
+type Entry struct {
+	ETag   string
+	Header http.Header
+	Body   []byte
+
+	key     string
+	expires time.Time
+}
+
+// Size returns the number of bytes the entry is accounted for
+func (e *Entry) Size() int64 {
+	return int64(len(e.Body))
+}
+
+// LRU is a size-bounded, TTL-aware least-recently-used object cache
+type LRU struct {
+	mu       sync.Mutex
+	maxBytes int64
+	ttl      time.Duration
+	size     int64
+	order    *list.List
+	items    map[string]*list.Element
+	now      func() time.Time
+}
+
+// NewLRU creates a cache holding at most maxBytes of object data.
+// Entries older than ttl are treated as missing; a zero ttl disables expiry.
+func NewLRU(maxBytes int64, ttl time.Duration) *LRU {
+	return &LRU{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// Get returns the entry for key and marks it as recently used
+func (c *LRU) Get(key string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*Entry)
+	if !entry.expires.IsZero() && c.now().After(entry.expires) {
+		c.removeElement(elem)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry, true
+}
+
+// Set stores an entry, evicting the least recently used entries to make room.
+// Entries larger than the whole cache are ignored.
+func (c *LRU) Set(key string, entry *Entry) {
+	if entry.Size() > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+
+	entry.key = key
+	if c.ttl > 0 {
+		entry.expires = c.now().Add(c.ttl)
+	}
+
+	c.items[key] = c.order.PushFront(entry)
+	c.size += entry.Size()
+
+	for c.size > c.maxBytes {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// Delete removes the entry for key if present
+func (c *LRU) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// Len returns the number of cached entries
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Size returns the total number of cached bytes
+func (c *LRU) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+func (c *LRU) removeElement(elem *list.Element) {
+	entry := elem.Value.(*Entry)
+	c.order.Remove(elem)
+	delete(c.items, entry.key)
+	c.size -= entry.Size()
+}
+
+// ObjectKey returns the cache key for an object
+func ObjectKey(bucket, key string) string {
+	return bucket + "/" + key
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func entry(size int, etag string) *Entry {
+	return &Entry{ETag: etag, Body: make([]byte, size)}
+}
+
+func TestLRU_GetSet(t *testing.T) {
+	c := NewLRU(100, 0)
+
+	c.Set("bucket/a", entry(10, `"a"`))
+	got, ok := c.Get("bucket/a")
+	assert.True(t, ok)
+	assert.Equal(t, `"a"`, got.ETag)
+
+	_, ok = c.Get("bucket/missing")
+	assert.False(t, ok)
+
+	// Replacing an entry keeps size accounting correct
+	c.Set("bucket/a", entry(20, `"a2"`))
+	assert.Equal(t, 1, c.Len())
+	assert.Equal(t, int64(20), c.Size())
+}
+
+func TestLRU_Eviction(t *testing.T) {
+	c := NewLRU(30, 0)
+
+	c.Set("a", entry(10, "a"))
+	c.Set("b", entry(10, "b"))
+	c.Set("c", entry(10, "c"))
+
+	// Touch "a" so "b" becomes the least recently used
+	_, _ = c.Get("a")
+	c.Set("d", entry(10, "d"))
+
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	for _, key := range []string{"a", "c", "d"} {
+		_, ok := c.Get(key)
+		assert.True(t, ok, key)
+	}
+	assert.Equal(t, int64(30), c.Size())
+
+	// Oversized entries are never stored
+	c.Set("huge", entry(31, "huge"))
+	_, ok = c.Get("huge")
+	assert.False(t, ok)
+}
+
+func TestLRU_TTL(t *testing.T) {
+	c := NewLRU(100, time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", entry(10, "a"))
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, int64(0), c.Size())
+}
+
+func TestLRU_Delete(t *testing.T) {
+	c := NewLRU(100, 0)
+	c.Set(ObjectKey("bucket", "key"), entry(10, "a"))
+	c.Delete(ObjectKey("bucket", "key"))
+
+	_, ok := c.Get("bucket/key")
+	assert.False(t, ok)
+	assert.Equal(t, int64(0), c.Size())
+}
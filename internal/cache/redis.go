@@ -0,0 +1,232 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RedisConfig holds connection settings for a Redis KV store
+type RedisConfig struct {
+	Addr      string
+	Password  string
+	DB        int
+	KeyPrefix string
+	Timeout   time.Duration
+	PoolSize  int
+}
+
+// Redis is a minimal RESP client implementing KV, sufficient for sharing
+// caches between proxy replicas
+type Redis struct {
+	config RedisConfig
+	conns  chan *redisConn
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// redisError is an error reply returned by the server
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// NewRedis creates a Redis KV store. Connections are established lazily.
+func NewRedis(cfg RedisConfig) *Redis {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = 8
+	}
+	return &Redis{
+		config: cfg,
+		conns:  make(chan *redisConn, cfg.PoolSize),
+	}
+}
+
+// Get returns the value for key
+func (r *Redis) Get(key string) ([]byte, bool, error) {
+	reply, err := r.do("GET", r.config.KeyPrefix+key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+// Set stores value under key with the given ttl
+func (r *Redis) Set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", r.config.KeyPrefix + key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := r.do(args...)
+	return err
+}
+
+// Delete removes key
+func (r *Redis) Delete(key string) error {
+	_, err := r.do("DEL", r.config.KeyPrefix+key)
+	return err
+}
+
+// Ping checks connectivity to the server
+func (r *Redis) Ping() error {
+	_, err := r.do("PING")
+	return err
+}
+
+// Close closes all pooled connections
+func (r *Redis) Close() {
+	for {
+		select {
+		case c := <-r.conns:
+			c.conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// do sends a command and returns its reply, reusing pooled connections
+func (r *Redis) do(args ...string) (interface{}, error) {
+	c, err := r.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := c.command(r.config.Timeout, args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection state is unknown after a network error
+		c.conn.Close()
+		return nil, err
+	}
+
+	r.release(c)
+	return reply, err
+}
+
+func (r *Redis) acquire() (*redisConn, error) {
+	select {
+	case c := <-r.conns:
+		return c, nil
+	default:
+	}
+
+	conn, err := net.DialTimeout("tcp", r.config.Addr, r.config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("redis: failed to connect: %w", err)
+	}
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	if r.config.Password != "" {
+		if _, err := c.command(r.config.Timeout, "AUTH", r.config.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if r.config.DB != 0 {
+		if _, err := c.command(r.config.Timeout, "SELECT", strconv.Itoa(r.config.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (r *Redis) release(c *redisConn) {
+	select {
+	case r.conns <- c:
+	default:
+		c.conn.Close()
+	}
+}
+
+func (c *redisConn) command(timeout time.Duration, args ...string) (interface{}, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+
+	return readReply(c.reader)
+}
+
+// readReply parses one RESP reply. Bulk strings are returned as []byte,
+// integers as int64, simple strings as string and nil bulk strings as nil.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply")
+	}
+	payload := line[1 : len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, redisError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length")
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:size], nil
+	case '*':
+		count, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length")
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("redis: unknown reply type %q", line[0])
+}
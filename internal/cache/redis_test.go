@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis implements just enough of the RESP protocol for GET/SET/DEL/PING/AUTH
+func fakeRedis(t *testing.T, password string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	var mu sync.Mutex
+	data := map[string]string{}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				authed := password == ""
+				for {
+					reply, err := readReply(reader)
+					if err != nil {
+						return
+					}
+					items := reply.([]interface{})
+					args := make([]string, len(items))
+					for i, item := range items {
+						args[i] = string(item.([]byte))
+					}
+
+					mu.Lock()
+					var out string
+					switch {
+					case strings.EqualFold(args[0], "AUTH"):
+						authed = args[1] == password
+						out = "+OK\r\n"
+						if !authed {
+							out = "-WRONGPASS invalid password\r\n"
+						}
+					case !authed:
+						out = "-NOAUTH Authentication required\r\n"
+					case args[0] == "PING":
+						out = "+PONG\r\n"
+					case args[0] == "SET":
+						data[args[1]] = args[2]
+						out = "+OK\r\n"
+					case args[0] == "GET":
+						if v, ok := data[args[1]]; ok {
+							out = "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+						} else {
+							out = "$-1\r\n"
+						}
+					case args[0] == "DEL":
+						delete(data, args[1])
+						out = ":1\r\n"
+					default:
+						out = "-ERR unknown command\r\n"
+					}
+					mu.Unlock()
+					conn.Write([]byte(out))
+				}
+			}(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestRedis_KV(t *testing.T) {
+	addr := fakeRedis(t, "secret")
+	r := NewRedis(RedisConfig{Addr: addr, Password: "secret", KeyPrefix: "test:", Timeout: time.Second})
+	defer r.Close()
+
+	require.NoError(t, r.Ping())
+
+	_, found, err := r.Get("missing")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, r.Set("key", []byte("value\r\nwith binary"), time.Minute))
+	value, found, err := r.Get("key")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "value\r\nwith binary", string(value))
+
+	require.NoError(t, r.Delete("key"))
+	_, found, err = r.Get("key")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestRedis_AuthFailure(t *testing.T) {
+	addr := fakeRedis(t, "secret")
+	r := NewRedis(RedisConfig{Addr: addr, Password: "wrong", Timeout: time.Second})
+	defer r.Close()
+
+	err := r.Ping()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "WRONGPASS")
+}
+
+func TestRedis_Unreachable(t *testing.T) {
+	r := NewRedis(RedisConfig{Addr: "127.0.0.1:1", Timeout: 100 * time.Millisecond})
+	_, _, err := r.Get("key")
+	assert.Error(t, err)
+}
+
+func TestMemoryKV(t *testing.T) {
+	kv := NewMemoryKV()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	kv.now = func() time.Time { return now }
+
+	require.NoError(t, kv.Set("a", []byte("1"), time.Minute))
+	value, found, _ := kv.Get("a")
+	assert.True(t, found)
+	assert.Equal(t, []byte("1"), value)
+
+	now = now.Add(time.Hour)
+	_, found, _ = kv.Get("a")
+	assert.False(t, found)
+}
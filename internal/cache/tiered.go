@@ -0,0 +1,104 @@
+package cache
+
+import "s3-vault-proxy/internal/metrics"
+
+var (
+	lookupsTotal = metrics.NewCounter(
+		"s3_vault_proxy_object_cache_lookups_total",
+		"Object cache lookups by tier and result.",
+		"tier", "result",
+	)
+	cachedBytes = metrics.NewGauge(
+		"s3_vault_proxy_object_cache_bytes",
+		"Bytes currently held by each object cache tier.",
+		"tier",
+	)
+	cachedEntries = metrics.NewGauge(
+		"s3_vault_proxy_object_cache_entries",
+		"Entries currently held by each object cache tier.",
+		"tier",
+	)
+)
+
+// Cache is the object cache interface used by handlers
+type Cache interface {
+	Get(key string) (*Entry, bool)
+	Set(key string, entry *Entry)
+	Delete(key string)
+}
+
+// Store is implemented by every cache tier
+type Store interface {
+	Cache
+	Len() int
+	Size() int64
+}
+
+// Tier is a cache store that accepts entries up to a maximum size
+type Tier struct {
+	Name         string
+	Store        Store
+	MaxEntrySize int64
+}
+
+// Tiered places each entry in the first tier that accepts its size and looks
+// entries up across all tiers in order
+type Tiered struct {
+	tiers []Tier
+}
+
+// NewTiered creates a cache from tiers ordered smallest entries first
+func NewTiered(tiers ...Tier) *Tiered {
+	return &Tiered{tiers: tiers}
+}
+
+// Get returns the entry for key from whichever tier holds it
+func (t *Tiered) Get(key string) (*Entry, bool) {
+	for _, tier := range t.tiers {
+		if entry, ok := tier.Store.Get(key); ok {
+			lookupsTotal.Inc(tier.Name, "hit")
+			return entry, true
+		}
+	}
+	lookupsTotal.Inc("all", "miss")
+	return nil, false
+}
+
+// Set stores the entry in the first tier that accepts its size
+func (t *Tiered) Set(key string, entry *Entry) {
+	placed := false
+	for _, tier := range t.tiers {
+		if !placed && entry.Size() <= tier.MaxEntrySize {
+			tier.Store.Set(key, entry)
+			placed = true
+		} else {
+			// Keep at most one copy so an object cannot be served from a stale tier
+			tier.Store.Delete(key)
+		}
+		t.updateGauges(tier)
+	}
+}
+
+// Delete removes key from every tier
+func (t *Tiered) Delete(key string) {
+	for _, tier := range t.tiers {
+		tier.Store.Delete(key)
+		t.updateGauges(tier)
+	}
+}
+
+// MaxEntrySize returns the largest entry any tier accepts
+func (t *Tiered) MaxEntrySize() int64 {
+	var max int64
+	for _, tier := range t.tiers {
+		if tier.MaxEntrySize > max {
+			max = tier.MaxEntrySize
+		}
+	}
+	return max
+}
+
+func (t *Tiered) updateGauges(tier Tier) {
+	cachedBytes.Set(float64(tier.Store.Size()), tier.Name)
+	cachedEntries.Set(float64(tier.Store.Len()), tier.Name)
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisk_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	disk, err := NewDisk(dir, 1024)
+	require.NoError(t, err)
+
+	body := bytes.Repeat([]byte("secret"), 50)
+	disk.Set("bucket/key", &Entry{ETag: `"abc"`, Body: body})
+
+	got, ok := disk.Get("bucket/key")
+	require.True(t, ok)
+	assert.Equal(t, body, got.Body)
+	assert.Equal(t, `"abc"`, got.ETag)
+
+	// Bodies must not be stored in plaintext
+	files, err := filepath.Glob(filepath.Join(dir, "*"+diskFileSuffix))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	raw, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(raw, []byte("secret")))
+
+	disk.Delete("bucket/key")
+	_, ok = disk.Get("bucket/key")
+	assert.False(t, ok)
+	files, _ = filepath.Glob(filepath.Join(dir, "*"+diskFileSuffix))
+	assert.Empty(t, files)
+}
+
+func TestDisk_EvictionAndStartupCleanup(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stale"+diskFileSuffix), []byte("old"), 0o600))
+
+	disk, err := NewDisk(dir, 100)
+	require.NoError(t, err)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+diskFileSuffix))
+	assert.Empty(t, files)
+
+	disk.Set("a", entry(60, "a"))
+	disk.Set("b", entry(60, "b"))
+
+	_, ok := disk.Get("a")
+	assert.False(t, ok)
+	_, ok = disk.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, int64(60), disk.Size())
+}
+
+func TestTiered(t *testing.T) {
+	memory := NewLRU(100, 0)
+	disk, err := NewDisk(t.TempDir(), 1000)
+	require.NoError(t, err)
+
+	tiered := NewTiered(
+		Tier{Name: "memory", Store: memory, MaxEntrySize: 10},
+		Tier{Name: "disk", Store: disk, MaxEntrySize: 500},
+	)
+	assert.Equal(t, int64(500), tiered.MaxEntrySize())
+
+	tiered.Set("small", entry(5, "s"))
+	tiered.Set("large", entry(200, "l"))
+	tiered.Set("huge", entry(600, "h"))
+
+	assert.Equal(t, 1, memory.Len())
+	assert.Equal(t, 1, disk.Len())
+
+	_, ok := tiered.Get("small")
+	assert.True(t, ok)
+	_, ok = tiered.Get("large")
+	assert.True(t, ok)
+	_, ok = tiered.Get("huge")
+	assert.False(t, ok)
+
+	// An object that grows moves tiers rather than being cached twice
+	tiered.Set("small", entry(50, "s2"))
+	assert.Equal(t, 0, memory.Len())
+	assert.Equal(t, 2, disk.Len())
+
+	tiered.Delete("large")
+	_, ok = tiered.Get("large")
+	assert.False(t, ok)
+}
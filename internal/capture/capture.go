@@ -0,0 +1,204 @@
+package capture
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxBodyCapture bounds how much of a backend error body is kept
+const maxBodyCapture = 4096
+
+// redactedHeaders hold credentials rather than request identity
+var redactedHeaders = []string{"X-Amz-Security-Token", "Cookie", "Proxy-Authorization"}
+
+// Capture is a recorded backend exchange used to debug signature failures
+type Capture struct {
+	Time             time.Time   `json:"time"`
+	Method           string      `json:"method"`
+	Path             string      `json:"path"`
+	Query            string      `json:"query,omitempty"`
+	AccessKey        string      `json:"access_key,omitempty"`
+	RequestHeaders   http.Header `json:"request_headers"`
+	CanonicalRequest string      `json:"canonical_request,omitempty"`
+	StringToSign     string      `json:"string_to_sign,omitempty"`
+	ParseError       string      `json:"parse_error,omitempty"`
+	BackendStatus    int         `json:"backend_status,omitempty"`
+	BackendHeaders   http.Header `json:"backend_headers,omitempty"`
+	BackendBody      string      `json:"backend_body,omitempty"`
+	BackendError     string      `json:"backend_error,omitempty"`
+
+	// What the backend computed, from a SignatureDoesNotMatch error body,
+	// and the first canonical request line where it differs from the proxy's
+	BackendCanonicalRequest string `json:"backend_canonical_request,omitempty"`
+	BackendStringToSign     string `json:"backend_string_to_sign,omitempty"`
+	Mismatch                string `json:"mismatch,omitempty"`
+}
+
+// Recorder decides which requests to capture and keeps the most recent
+// captures in a fixed-size ring buffer. Capturing is budgeted: once the armed
+// number of requests has been captured it stops until re-armed.
+type Recorder struct {
+	mu         sync.Mutex
+	headerFlag string
+	accessKeys map[string]bool
+	remaining  int
+
+	ring []Capture
+	next int
+	full bool
+}
+
+// NewRecorder creates a disarmed recorder holding up to size captures.
+// Requests carrying headerFlag are captured whenever budget remains.
+func NewRecorder(size int, headerFlag string) *Recorder {
+	if size < 1 {
+		size = 1
+	}
+	return &Recorder{
+		headerFlag: headerFlag,
+		accessKeys: make(map[string]bool),
+		ring:       make([]Capture, size),
+	}
+}
+
+// Arm allows count more captures. When access keys are given, all of their
+// requests are captured in addition to header-flagged ones.
+func (r *Recorder) Arm(count int, accessKeys ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remaining = count
+	r.accessKeys = make(map[string]bool, len(accessKeys))
+	for _, key := range accessKeys {
+		if key != "" {
+			r.accessKeys[key] = true
+		}
+	}
+}
+
+// Status reports the remaining budget and targeted access keys
+func (r *Recorder) Status() (remaining int, accessKeys []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.accessKeys {
+		accessKeys = append(accessKeys, key)
+	}
+	return r.remaining, accessKeys
+}
+
+// ShouldCapture reports whether a request is selected and consumes one unit of budget
+func (r *Recorder) ShouldCapture(accessKey string, headers http.Header) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.remaining <= 0 {
+		return false
+	}
+
+	selected := accessKey != "" && r.accessKeys[accessKey]
+	if !selected && r.headerFlag != "" {
+		for name := range headers {
+			if strings.EqualFold(name, r.headerFlag) {
+				selected = true
+				break
+			}
+		}
+	}
+	if selected {
+		r.remaining--
+	}
+	return selected
+}
+
+// Add stores a capture, overwriting the oldest once the buffer is full.
+// Credentials are masked wherever they appear, including canonical requests
+// that signed them.
+func (r *Recorder) Add(c Capture) {
+	for _, secret := range sensitiveValues(c.RequestHeaders) {
+		c.CanonicalRequest = strings.ReplaceAll(c.CanonicalRequest, secret, "[REDACTED]")
+		c.BackendCanonicalRequest = strings.ReplaceAll(c.BackendCanonicalRequest, secret, "[REDACTED]")
+		c.BackendBody = strings.ReplaceAll(c.BackendBody, secret, "[REDACTED]")
+	}
+	c.RequestHeaders = redact(c.RequestHeaders)
+	if len(c.BackendBody) > maxBodyCapture {
+		c.BackendBody = c.BackendBody[:maxBodyCapture]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ring[r.next] = c
+	r.next = (r.next + 1) % len(r.ring)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// List returns the buffered captures, oldest first
+func (r *Recorder) List() []Capture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]Capture(nil), r.ring[:r.next]...)
+	}
+	return append(append([]Capture(nil), r.ring[r.next:]...), r.ring[:r.next]...)
+}
+
+// Clear drops all buffered captures
+func (r *Recorder) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ring = make([]Capture, len(r.ring))
+	r.next = 0
+	r.full = false
+}
+
+// sensitiveValues returns the values of the headers redact masks
+func sensitiveValues(headers http.Header) []string {
+	var values []string
+	for name, headerValues := range headers {
+		for _, sensitive := range redactedHeaders {
+			if strings.EqualFold(name, sensitive) {
+				for _, value := range headerValues {
+					if value != "" {
+						values = append(values, value)
+					}
+				}
+			}
+		}
+	}
+	return values
+}
+
+// FirstDifference describes the first line where two canonical requests
+// differ, or returns "" when they are the same
+func FirstDifference(proxy, backend string) string {
+	proxyLines, backendLines := strings.Split(proxy, "\n"), strings.Split(backend, "\n")
+	for i := 0; i < len(proxyLines) || i < len(backendLines); i++ {
+		var proxyLine, backendLine string
+		if i < len(proxyLines) {
+			proxyLine = proxyLines[i]
+		}
+		if i < len(backendLines) {
+			backendLine = backendLines[i]
+		}
+		if proxyLine != backendLine {
+			return fmt.Sprintf("line %d: proxy %q, backend %q", i+1, proxyLine, backendLine)
+		}
+	}
+	return ""
+}
+
+// redact copies headers with credential values masked
+func redact(headers http.Header) http.Header {
+	copied := make(http.Header, len(headers))
+	for name, values := range headers {
+		copied[name] = append([]string(nil), values...)
+		for _, sensitive := range redactedHeaders {
+			if strings.EqualFold(name, sensitive) {
+				copied[name] = []string{"[REDACTED]"}
+			}
+		}
+	}
+	return copied
+}
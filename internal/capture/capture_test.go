@@ -0,0 +1,77 @@
+package capture
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldCapture(t *testing.T) {
+	r := NewRecorder(10, "X-Proxy-Capture")
+	flagged := http.Header{"x-proxy-capture": []string{"1"}}
+
+	assert.False(t, r.ShouldCapture("AKID", flagged), "disarmed recorder captures nothing")
+
+	r.Arm(2, "AKID")
+	assert.True(t, r.ShouldCapture("AKID", http.Header{}))
+	assert.False(t, r.ShouldCapture("OTHER", http.Header{}))
+	assert.True(t, r.ShouldCapture("OTHER", flagged))
+	assert.False(t, r.ShouldCapture("AKID", http.Header{}), "budget exhausted")
+
+	remaining, keys := r.Status()
+	assert.Equal(t, 0, remaining)
+	assert.Equal(t, []string{"AKID"}, keys)
+}
+
+func TestRingBuffer(t *testing.T) {
+	r := NewRecorder(3, "")
+	for _, path := range []string{"/a", "/b", "/c", "/d"} {
+		r.Add(Capture{Path: path})
+	}
+
+	captures := r.List()
+	assert.Len(t, captures, 3)
+	assert.Equal(t, "/b", captures[0].Path)
+	assert.Equal(t, "/d", captures[2].Path)
+
+	r.Clear()
+	assert.Empty(t, r.List())
+}
+
+func TestAddRedactsAndTruncates(t *testing.T) {
+	r := NewRecorder(1, "")
+	headers := http.Header{
+		"x-amz-security-token": []string{"session-secret"},
+		"Authorization":        []string{"AWS4-HMAC-SHA256 Credential=AKID/..."},
+	}
+	r.Add(Capture{RequestHeaders: headers, BackendBody: strings.Repeat("x", maxBodyCapture+10)})
+
+	captured := r.List()[0]
+	assert.Equal(t, []string{"[REDACTED]"}, captured.RequestHeaders["x-amz-security-token"])
+	assert.Equal(t, headers["Authorization"], captured.RequestHeaders["Authorization"])
+	assert.Equal(t, "session-secret", headers["x-amz-security-token"][0], "caller headers are not modified")
+	assert.Len(t, captured.BackendBody, maxBodyCapture)
+}
+
+func TestAddRedactsSignedCredentials(t *testing.T) {
+	r := NewRecorder(1, "")
+	r.Add(Capture{
+		RequestHeaders:          http.Header{"X-Amz-Security-Token": []string{"session-secret"}},
+		CanonicalRequest:        "GET\n/\n\nx-amz-security-token:session-secret\n",
+		BackendCanonicalRequest: "GET\n/\n\nx-amz-security-token:session-secret\n",
+		BackendBody:             "<CanonicalRequest>x-amz-security-token:session-secret</CanonicalRequest>",
+	})
+
+	captured := r.List()[0]
+	assert.NotContains(t, captured.CanonicalRequest, "session-secret")
+	assert.NotContains(t, captured.BackendCanonicalRequest, "session-secret")
+	assert.NotContains(t, captured.BackendBody, "session-secret")
+}
+
+func TestFirstDifference(t *testing.T) {
+	assert.Empty(t, FirstDifference("GET\n/a\n", "GET\n/a\n"))
+	assert.Equal(t, `line 2: proxy "/a%20b", backend "/a+b"`, FirstDifference("GET\n/a%20b\n", "GET\n/a+b\n"))
+	assert.Equal(t, `line 3: proxy "", backend "host:minio"`, FirstDifference("GET\n/\n", "GET\n/\nhost:minio"))
+}
@@ -0,0 +1,125 @@
+// Package chaos injects faults into the proxy so client retry behavior can be
+// tested against it. It is meant for test environments only.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/s3"
+)
+
+// Fault is a kind of injected failure
+type Fault string
+
+// Faults the injector knows
+const (
+	FaultLatency          Fault = "latency"
+	FaultVaultUnavailable Fault = "vault_unavailable"
+	FaultBackendReset     Fault = "backend_reset"
+	FaultTruncate         Fault = "truncate"
+)
+
+var faultsInjectedTotal = metrics.NewCounter(
+	"s3_vault_proxy_chaos_faults_injected_total",
+	"Faults injected by chaos testing, by fault.",
+	"fault",
+)
+
+// ErrBackendReset is returned by Backend in place of a backend response
+var ErrBackendReset = fmt.Errorf("injected fault: %w", syscall.ECONNRESET)
+
+// Config sets the probability (0 to 1) of each fault per request
+type Config struct {
+	Latency           time.Duration
+	LatencyRatio      float64
+	VaultErrorRatio   float64
+	BackendResetRatio float64
+	TruncateRatio     float64
+}
+
+// Injector decides which faults to inject
+type Injector struct {
+	config Config
+
+	mu     sync.Mutex
+	sample func() float64
+}
+
+// New creates an injector for config
+func New(config Config) *Injector {
+	return &Injector{
+		config: config,
+		sample: rand.Float64,
+	}
+}
+
+// Inject reports whether fault should be injected into the current request,
+// counting it if so
+func (i *Injector) Inject(fault Fault) bool {
+	if i == nil {
+		return false
+	}
+	var ratio float64
+	switch fault {
+	case FaultLatency:
+		ratio = i.config.LatencyRatio
+	case FaultVaultUnavailable:
+		ratio = i.config.VaultErrorRatio
+	case FaultBackendReset:
+		ratio = i.config.BackendResetRatio
+	case FaultTruncate:
+		ratio = i.config.TruncateRatio
+	}
+	if ratio <= 0 {
+		return false
+	}
+
+	i.mu.Lock()
+	injected := i.sample() < ratio
+	i.mu.Unlock()
+	if injected {
+		faultsInjectedTotal.Inc(string(fault))
+	}
+	return injected
+}
+
+// Latency returns the delay added by FaultLatency
+func (i *Injector) Latency() time.Duration {
+	return i.config.Latency
+}
+
+// Backend fails backend requests with a connection reset at the injector's
+// BackendResetRatio, as a backend dropping connections would
+type Backend struct {
+	inner    s3.Interface
+	injector *Injector
+}
+
+// NewBackend wraps an S3 client with injected connection resets
+func NewBackend(inner s3.Interface, injector *Injector) *Backend {
+	return &Backend{inner: inner, injector: injector}
+}
+
+// ForwardRequest forwards a request unless a reset is injected
+func (b *Backend) ForwardRequest(ctx context.Context, method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
+	if b.injector.Inject(FaultBackendReset) {
+		return nil, ErrBackendReset
+	}
+	return b.inner.ForwardRequest(ctx, method, path, body, headers, queryString)
+}
+
+// HeadObject heads an object unless a reset is injected
+func (b *Backend) HeadObject(ctx context.Context, bucket, key string, headers http.Header) (*http.Response, error) {
+	if b.injector.Inject(FaultBackendReset) {
+		return nil, ErrBackendReset
+	}
+	return b.inner.HeadObject(ctx, bucket, key, headers)
+}
@@ -0,0 +1,47 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"syscall"
+	"testing"
+
+	"s3-vault-proxy/tests/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInject(t *testing.T) {
+	injector := New(Config{LatencyRatio: 0.5, TruncateRatio: 1})
+	injector.sample = func() float64 { return 0.4 }
+
+	assert.True(t, injector.Inject(FaultLatency))
+	assert.True(t, injector.Inject(FaultTruncate))
+	assert.False(t, injector.Inject(FaultVaultUnavailable), "faults without a ratio are never injected")
+
+	injector.sample = func() float64 { return 0.6 }
+	assert.False(t, injector.Inject(FaultLatency))
+
+	var disabled *Injector
+	assert.False(t, disabled.Inject(FaultTruncate))
+}
+
+func TestBackend(t *testing.T) {
+	inner := mocks.NewMockS3Client()
+	inner.SetResponse("GET", "/bucket/key", http.StatusOK, "data", nil)
+	injector := New(Config{BackendResetRatio: 0.5})
+	backend := NewBackend(inner, injector)
+
+	injector.sample = func() float64 { return 0.1 }
+	_, err := backend.ForwardRequest(context.Background(), "GET", "/bucket/key", nil, http.Header{}, nil)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, syscall.ECONNRESET))
+	inner.AssertNotCalled(t, "ForwardRequest", "GET", "/bucket/key", nil, http.Header{}, nil)
+
+	injector.sample = func() float64 { return 0.9 }
+	resp, err := backend.ForwardRequest(context.Background(), "GET", "/bucket/key", nil, http.Header{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
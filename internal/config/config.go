@@ -4,7 +4,18 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"s3-vault-proxy/internal/bucketheaders"
+	"s3-vault-proxy/internal/bucketlimits"
+	"s3-vault-proxy/internal/extensions"
+	"s3-vault-proxy/internal/features"
+	"s3-vault-proxy/internal/provision"
+	"s3-vault-proxy/internal/scopedkeys"
+	"s3-vault-proxy/internal/sigv4"
+	"s3-vault-proxy/internal/tenancy"
+	"s3-vault-proxy/internal/vault"
 )
 
 // Config holds all application configuration
@@ -20,15 +31,292 @@ type Config struct {
 	WriteBufferSize     int
 	DisableStartupMsg   bool
 	
+	// Per-operation connection timeouts (0 keeps ReadTimeout/WriteTimeout)
+	MetadataTimeout time.Duration
+	DownloadTimeout time.Duration
+	UploadTimeout   time.Duration
+	
+	// How often a request's connection is checked for a client that went away
+	// so its backend and Vault work can be cancelled (0 disables)
+	DisconnectCheckInterval time.Duration
+	
+	// S3 API listeners ("" serves plain HTTP on Port, see ListenerSpecs)
+	Listeners string
+	
+	// Graceful shutdown: readiness fails for ShutdownDelay before the listener
+	// closes, then in-flight requests get up to ShutdownTimeout to finish
+	ShutdownDelay   time.Duration
+	ShutdownTimeout time.Duration
+	
+	// Checks /ready runs (see ReadyCheckSpecs)
+	ReadyChecks string
+	
+	// Request body spooling
+	SpoolEnabled   bool
+	SpoolThreshold int
+	SpoolDir       string
+	
+	// Key attributed to uploads without an SSE-KMS header, for clients such as
+	// restic that cannot send one. The backend bucket must encrypt by default.
+	DefaultKMSKeyARN string
+	
+	// What reads of objects stored without SSE-KMS do: "serve" them as they
+	// are, "deny" them, or serve them and "encrypt" them in place under
+	// LegacyKMSKeyARN (default DefaultKMSKeyARN) with the operator credentials
+	LegacyObjects              string
+	LegacyKMSKeyARN            string
+	LegacyMigrationConcurrency int
+	
+	// Development mode replaces Vault with an in-process transit engine
+	// whose keys are lost on restart
+	DevMode bool
+	
 	// Vault configuration
 	VaultAddr       string
-	VaultToken      string
+	VaultToken      string `secret:"true"`
 	VaultTokenPath  string
 	
+	// Transit engine mount, and a canary mount receiving VaultCanaryRatio of
+	// encrypt operations ("" disables) to de-risk new key types or mounts
+	VaultTransitMount string
+	VaultCanaryMount  string
+	VaultCanaryRatio  float64
+	
 	// S3/MinIO configuration
 	S3Endpoint      string
 	S3CACertPath    string
 	
+	// Credentials maintenance commands sign their own backend requests with.
+	// The proxy itself only forwards client signatures and never uses them.
+	S3AccessKeyID     string
+	S3SecretAccessKey string `secret:"true"`
+	S3Region          string
+	
+	// Headers removed before forwarding (nil uses s3.DefaultStrippedHeaders)
+	// and headers always forwarded even when in the stripped set
+	StripHeaders   []string
+	ForwardHeaders []string
+	
+	// Host header sent to the backend: preserve (client's, covered by SigV4) or rewrite
+	S3HostMode string
+	
+	// S3 backend transport tuning
+	S3MaxIdleConns          int
+	S3MaxIdleConnsPerHost   int
+	S3MaxConnsPerHost       int
+	S3IdleConnTimeout       time.Duration
+	S3DialTimeout           time.Duration
+	S3TLSHandshakeTimeout   time.Duration
+	S3ResponseHeaderTimeout time.Duration
+	S3RequestTimeout        time.Duration
+	S3KeepAlive             time.Duration
+	S3DisableKeepAlives     bool
+	S3EnableHTTP2           bool
+	S3BodyIdleTimeout       time.Duration
+	
+	// HTTP client for the backend: net/http, or fasthttp to skip converting
+	// between fasthttp and net/http on every request
+	S3Client string
+	
+	// DeleteObjectsConcurrency bounds the keys of a DeleteObjects request
+	// worked on at once
+	DeleteObjectsConcurrency int
+	
+	// Uploads processed at once, and the sum of their declared sizes (0 is
+	// unbounded); uploads beyond either wait up to UploadQueueTimeout, then
+	// are answered with SlowDown
+	MaxConcurrentUploads int
+	MaxUploadBytes       int
+	UploadQueueTimeout   time.Duration
+	
+	// Object cache configuration
+	ObjectCacheEnabled       bool
+	ObjectCacheMaxBytes      int
+	ObjectCacheMaxObjectSize int
+	ObjectCacheTTL           time.Duration
+	
+	// Time one listing page may spend looking up object metadata (0 is
+	// unbounded); entries left once it is spent keep the backend's values.
+	// Pages with a budget are enriched before they are sent so degraded ones
+	// can be marked.
+	ListMetadataBudget time.Duration
+	
+	// Disk cache tier for objects too large for the memory cache
+	DiskCacheEnabled       bool
+	DiskCachePath          string
+	DiskCacheMaxBytes      int
+	DiskCacheMaxObjectSize int
+	
+	// Metadata cache configuration
+	MetadataCache    string // "", memory, redis
+	MetadataCacheTTL time.Duration
+	
+	// Redis configuration for caches shared between replicas
+	RedisAddr      string
+	RedisPassword  string `secret:"true"`
+	RedisDB        int
+	RedisKeyPrefix string
+	
+	// Tracing configuration (OTLP/HTTP exporter)
+	TracingEnabled     bool
+	TracingSampleRatio float64
+	OTLPEndpoint       string
+	OTLPHeaders        map[string]string `secret:"true"`
+	OTelServiceName    string
+	
+	// Error reporting for panics and 5xx responses
+	ErrorReporting        string // "", sentry, otlp
+	ErrorReportSampleRate float64
+	SentryDSN             string `secret:"true"`
+	SentryEnvironment     string
+	
+	// Admin listener for operational endpoints ("" disables)
+	AdminAddr    string
+	AdminToken   string `secret:"true"`
+	PprofEnabled bool
+	
+	// Reject writes on the S3 port (toggleable from the admin API)
+	ReadOnly bool
+	
+	// Request capture for debugging signature failures (read via the admin listener)
+	CaptureEnabled    bool
+	CaptureBufferSize int
+	CaptureLimit      int
+	CaptureHeader     string
+	CaptureAccessKeys []string
+	
+	// Record requests the backend rejects with SignatureDoesNotMatch, for
+	// /debug/signature-failures on the admin listener
+	SignatureDiagnosticsEnabled bool
+	
+	// Requests slower than this are logged with a phase breakdown (0 disables)
+	SlowRequestThreshold time.Duration
+	
+	// S3 server access log sink ("" disables, "-" is stdout)
+	AccessLogPath string
+	
+	// Feature flag overrides (name=true|false, see internal/features)
+	FeatureFlags map[string]string
+	
+	// Tenant definitions ("" disables tenancy, see internal/tenancy), how
+	// often storage quotas recount the tenants' buckets, and the transit path
+	// template tenants' Vault capabilities are checked at ("" is Vault's own)
+	TenantsFile              string
+	TenantUsageRefresh       time.Duration
+	VaultTransitPathTemplate string
+	
+	// Access keys limited to buckets, key prefixes and read or write access
+	// ("" disables scoping, see internal/scopedkeys)
+	ScopedKeysFile string
+	
+	// Bucket holding leases that keep maintenance jobs on different hosts from
+	// working on the same bucket at once ("" disables locking), and how long a
+	// lease lasts without renewal
+	LockBucket string
+	LockTTL    time.Duration
+	
+	// Cross-region replication ("" endpoint disables, see internal/replication).
+	// Credentials default to the operator credentials; bucket replication
+	// configurations are kept in ReplicationConfigBucket.
+	ReplicationEndpoint        string
+	ReplicationRegion          string
+	ReplicationCACertPath      string
+	ReplicationAccessKeyID     string
+	ReplicationSecretAccessKey string `secret:"true"`
+	ReplicationConfigBucket    string
+	ReplicationWorkers         int
+	ReplicationQueueSize       int
+	
+	// Bucket holding per-bucket KMS key bindings ("" disables them), read and
+	// written with the operator credentials
+	KMSBindingsBucket string
+	
+	// Bucket recording the LocationConstraint of created buckets ("" leaves
+	// GetBucketLocation to the backend), written with the operator credentials
+	BucketLocationsBucket string
+	
+	// Bucket name patterns (path.Match syntax) created on their first upload
+	// with the operator credentials (empty disables)
+	AutoCreateBuckets []string
+	
+	// WebAssembly modules inspecting every S3 request in order (empty
+	// disables), each call aborted after ExtensionTimeout. The runtime is
+	// not built yet, so the proxy refuses to start with modules configured.
+	ExtensionModules []string
+	ExtensionTimeout time.Duration
+	
+	// Bucket event notifications to an SQS-compatible queue ("" URL disables).
+	// Every bucket's events are sent when NotificationBuckets is empty, and
+	// SendMessage is unsigned without an access key.
+	NotificationSQSURL             string
+	NotificationSQSRegion          string
+	NotificationSQSAccessKeyID     string
+	NotificationSQSSecretAccessKey string `secret:"true"`
+	NotificationBuckets            []string
+	NotificationEvents             []string
+	NotificationQueueSize          int
+	
+	// Traffic shadowing to a second backend or proxy stack ("" disables).
+	// Writes are only mirrored with ShadowWrites and up to ShadowMaxWriteSize bytes.
+	ShadowEndpoint     string
+	ShadowCACertPath   string
+	ShadowSampleRatio  float64
+	ShadowWrites       bool
+	ShadowMaxWriteSize int
+	
+	// Scheduled inventory reports of InventoryBuckets, written every
+	// InventoryInterval to InventoryDestination ("bucket" or "bucket/prefix")
+	InventoryBuckets     []string
+	InventoryDestination string
+	InventoryInterval    time.Duration
+	InventoryKMSKeyARN   string
+	
+	// Per-bucket upload limits as bucket=value pairs, "*" applying to every
+	// bucket without its own; key counts are refreshed every BucketKeyCountRefresh
+	BucketMaxObjectSize   map[string]string
+	BucketMaxKeyLength    map[string]string
+	BucketMaxKeys         map[string]string
+	BucketKeyCountRefresh time.Duration
+	
+	// Response headers added to object reads per bucket ("" disables them,
+	// see internal/bucketheaders)
+	BucketHeadersFile string
+	
+	// Soft delete: objects deleted from TrashBuckets are kept under .trash/
+	// for TrashRetention before they are purged
+	TrashBuckets   []string
+	TrashRetention time.Duration
+	
+	// Multipart uploads of AbortUploadsBuckets initiated more than
+	// AbortUploadsAfter ago are aborted in the background
+	AbortUploadsBuckets []string
+	AbortUploadsAfter   time.Duration
+	
+	// MaintenanceBuckets are rewrapped and collected by the rewrap and gc
+	// admin jobs
+	MaintenanceBuckets []string
+	
+	// Usage accounting for chargeback, saved to UsageFile every
+	// UsageFlushInterval; stored bytes are recounted every UsageStorageRefresh
+	// (0 disables) with the operator credentials
+	UsageEnabled        bool
+	UsageFile           string
+	UsageFlushInterval  time.Duration
+	UsageStorageRefresh time.Duration
+	
+	// Bucket statistics on the admin listener are computed with the operator
+	// credentials and recomputed once older than BucketStatsTTL
+	BucketStatsTTL time.Duration
+	
+	// Fault injection for testing client retries; never enable in production.
+	// Ratios are per-request probabilities from 0 to 1.
+	ChaosEnabled           bool
+	ChaosLatency           time.Duration
+	ChaosLatencyRatio      float64
+	ChaosVaultErrorRatio   float64
+	ChaosBackendResetRatio float64
+	ChaosTruncateRatio     float64
+	
 	// Logging configuration
 	LogLevel        string
 	LogFormat       string
@@ -43,27 +331,262 @@ type Config struct {
 
 // LoadConfig loads configuration from environment variables with sensible defaults
 func LoadConfig() (*Config, error) {
-	cfg := &Config{
+	cfg := load()
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	
+	return cfg, nil
+}
+
+// FromEnv loads configuration like LoadConfig but leaves validation to the
+// caller, for programs embedding the proxy whose injected subsystems replace
+// settings Validate requires
+func FromEnv() *Config {
+	return load()
+}
+
+// load reads every setting from the environment without validating
+func load() *Config {
+	return &Config{
 		// Server defaults
 		Port:              getEnv("PORT", "9000"),
 		ServerHeader:      "S3-Vault-Proxy/1.0",
-		ReadTimeout:       30 * time.Second,
-		WriteTimeout:      30 * time.Second,
+		ReadTimeout:       getDurationEnv("READ_TIMEOUT", 30*time.Second),
+		WriteTimeout:      getDurationEnv("WRITE_TIMEOUT", 30*time.Second),
 		IdleTimeout:       60 * time.Second,
 		BodyLimit:         100 * 1024 * 1024, // 100MB
 		ReadBufferSize:    16384,             // 16KB
 		WriteBufferSize:   16384,             // 16KB
 		DisableStartupMsg: getBoolEnv("DISABLE_STARTUP_MSG", true),
+		MetadataTimeout:   getDurationEnv("METADATA_TIMEOUT", 0),
+		DownloadTimeout:   getDurationEnv("DOWNLOAD_TIMEOUT", 0),
+		UploadTimeout:     getDurationEnv("UPLOAD_TIMEOUT", 0),
+		DisconnectCheckInterval: getDurationEnv("DISCONNECT_CHECK_INTERVAL", time.Second),
+		Listeners:         getEnv("LISTENERS", ""),
+		ShutdownDelay:     getDurationEnv("SHUTDOWN_DELAY", 0),
+		ShutdownTimeout:   getDurationEnv("SHUTDOWN_TIMEOUT", 30*time.Second),
+		ReadyChecks:       getEnv("READY_CHECKS", "vault"),
+		
+		// Request body spooling (bodies above the threshold go to disk)
+		SpoolEnabled:   getBoolEnv("SPOOL_ENABLED", false),
+		SpoolThreshold: getIntEnv("SPOOL_THRESHOLD", 8*1024*1024), // 8MB
+		SpoolDir:       getEnv("SPOOL_DIR", ""),
+		
+		// Compatibility with clients that cannot send SSE-KMS headers (disabled by default)
+		DefaultKMSKeyARN: getEnv("DEFAULT_KMS_KEY_ARN", ""),
+		
+		// Objects written before the proxy
+		LegacyObjects:              getEnv("LEGACY_OBJECTS", "serve"),
+		LegacyKMSKeyARN:            getEnv("LEGACY_KMS_KEY_ARN", ""),
+		LegacyMigrationConcurrency: getIntEnv("LEGACY_MIGRATION_CONCURRENCY", 4),
+		
+		// Development mode (never for production data)
+		DevMode: getBoolEnv("DEV_MODE", false),
 		
 		// Vault configuration
 		VaultAddr:      getEnv("VAULT_ADDR", ""),
 		VaultToken:     getEnv("VAULT_TOKEN", ""),
 		VaultTokenPath: getEnv("VAULT_TOKEN_PATH", "/vault/secrets/token"),
 		
+		// Transit mounts and canary routing
+		VaultTransitMount: getEnv("VAULT_TRANSIT_MOUNT", "transit"),
+		VaultCanaryMount:  getEnv("VAULT_CANARY_MOUNT", ""),
+		VaultCanaryRatio:  getFloatEnv("VAULT_CANARY_RATIO", 0),
+		
 		// S3 configuration
 		S3Endpoint:   getEnv("S3_ENDPOINT", ""),
 		S3CACertPath: getEnv("S3_CA_CERT_PATH", ""),
 		
+		// Operator credentials for maintenance commands (rewrap, verify, ...)
+		S3AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+		S3Region:          getEnv("S3_REGION", "us-east-1"),
+		
+		// Header forwarding (unset keeps the built-in CDN/load balancer list)
+		StripHeaders:   getListEnv("STRIP_HEADERS"),
+		ForwardHeaders: getListEnv("FORWARD_HEADERS"),
+		S3HostMode:     getEnv("S3_HOST_MODE", "preserve"),
+		
+		// S3 transport tuning (request timeout of 0 means no overall deadline)
+		S3MaxIdleConns:          getIntEnv("S3_MAX_IDLE_CONNS", 100),
+		S3MaxIdleConnsPerHost:   getIntEnv("S3_MAX_IDLE_CONNS_PER_HOST", 10),
+		S3MaxConnsPerHost:       getIntEnv("S3_MAX_CONNS_PER_HOST", 0),
+		S3IdleConnTimeout:       getDurationEnv("S3_IDLE_CONN_TIMEOUT", 90*time.Second),
+		S3DialTimeout:           getDurationEnv("S3_DIAL_TIMEOUT", 10*time.Second),
+		S3TLSHandshakeTimeout:   getDurationEnv("S3_TLS_HANDSHAKE_TIMEOUT", 10*time.Second),
+		S3ResponseHeaderTimeout: getDurationEnv("S3_RESPONSE_HEADER_TIMEOUT", 30*time.Second),
+		S3RequestTimeout:        getDurationEnv("S3_REQUEST_TIMEOUT", 0),
+		S3KeepAlive:             getDurationEnv("S3_KEEPALIVE", 30*time.Second),
+		S3DisableKeepAlives:     getBoolEnv("S3_DISABLE_KEEPALIVES", false),
+		S3EnableHTTP2:           getBoolEnv("S3_HTTP2", false),
+		S3BodyIdleTimeout:       getDurationEnv("S3_BODY_IDLE_TIMEOUT", 0),
+		S3Client:                getEnv("S3_CLIENT", "net/http"),
+		
+		DeleteObjectsConcurrency: getIntEnv("DELETE_OBJECTS_CONCURRENCY", 16),
+		
+		// Upload backpressure (disabled by default)
+		MaxConcurrentUploads: getIntEnv("MAX_CONCURRENT_UPLOADS", 0),
+		MaxUploadBytes:       getIntEnv("MAX_UPLOAD_BYTES", 0),
+		UploadQueueTimeout:   getDurationEnv("UPLOAD_QUEUE_TIMEOUT", 5*time.Second),
+		
+		// Object cache configuration (disabled by default)
+		ObjectCacheEnabled:       getBoolEnv("OBJECT_CACHE_ENABLED", false),
+		ObjectCacheMaxBytes:      getIntEnv("OBJECT_CACHE_MAX_BYTES", 64*1024*1024), // 64MB
+		ObjectCacheMaxObjectSize: getIntEnv("OBJECT_CACHE_MAX_OBJECT_SIZE", 1024*1024), // 1MB
+		ObjectCacheTTL:           getDurationEnv("OBJECT_CACHE_TTL", 5*time.Minute),
+		
+		// Listing metadata budget (unbounded by default)
+		ListMetadataBudget: getDurationEnv("LIST_METADATA_BUDGET", 0),
+		
+		// Disk cache tier (requires OBJECT_CACHE_ENABLED)
+		DiskCacheEnabled:       getBoolEnv("DISK_CACHE_ENABLED", false),
+		DiskCachePath:          getEnv("DISK_CACHE_PATH", "/tmp/s3-vault-proxy-cache"),
+		DiskCacheMaxBytes:      getIntEnv("DISK_CACHE_MAX_BYTES", 1024*1024*1024),      // 1GB
+		DiskCacheMaxObjectSize: getIntEnv("DISK_CACHE_MAX_OBJECT_SIZE", 100*1024*1024), // 100MB
+		
+		// Metadata cache configuration (disabled by default)
+		MetadataCache:    getEnv("METADATA_CACHE", ""),
+		MetadataCacheTTL: getDurationEnv("METADATA_CACHE_TTL", 5*time.Minute),
+		
+		// Redis configuration
+		RedisAddr:      getEnv("REDIS_ADDR", ""),
+		RedisPassword:  getEnv("REDIS_PASSWORD", ""),
+		RedisDB:        getIntEnv("REDIS_DB", 0),
+		RedisKeyPrefix: getEnv("REDIS_KEY_PREFIX", "s3-vault-proxy:"),
+		
+		// Tracing configuration (disabled by default, standard OTEL_* variables)
+		TracingEnabled:     getBoolEnv("TRACING_ENABLED", false),
+		TracingSampleRatio: getFloatEnv("TRACING_SAMPLE_RATIO", 1.0),
+		OTLPEndpoint:       getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318"),
+		OTLPHeaders:        getMapEnv("OTEL_EXPORTER_OTLP_HEADERS"),
+		OTelServiceName:    getEnv("OTEL_SERVICE_NAME", "s3-vault-proxy"),
+		
+		// Error reporting (disabled by default, OTLP reuses the tracing endpoint)
+		ErrorReporting:        getEnv("ERROR_REPORTING", ""),
+		ErrorReportSampleRate: getFloatEnv("ERROR_REPORT_SAMPLE_RATE", 1.0),
+		SentryDSN:             getEnv("SENTRY_DSN", ""),
+		SentryEnvironment:     getEnv("SENTRY_ENVIRONMENT", "production"),
+		
+		// Admin listener (disabled by default, bind to localhost in production)
+		AdminAddr:    getEnv("ADMIN_ADDR", ""),
+		AdminToken:   getEnv("ADMIN_TOKEN", ""),
+		PprofEnabled: getBoolEnv("DEBUG_PPROF_ENABLED", false),
+		ReadOnly:     getBoolEnv("READ_ONLY", false),
+		
+		// Request capture (disabled by default)
+		CaptureEnabled:    getBoolEnv("CAPTURE_ENABLED", false),
+		CaptureBufferSize: getIntEnv("CAPTURE_BUFFER_SIZE", 100),
+		CaptureLimit:      getIntEnv("CAPTURE_LIMIT", 20),
+		CaptureHeader:     getEnv("CAPTURE_HEADER", "X-Proxy-Capture"),
+		CaptureAccessKeys: getListEnv("CAPTURE_ACCESS_KEYS"),
+		
+		// Signature failure diagnostics (disabled by default)
+		SignatureDiagnosticsEnabled: getBoolEnv("SIGNATURE_DIAGNOSTICS_ENABLED", false),
+		
+		// Slow request logging (disabled by default)
+		SlowRequestThreshold: getDurationEnv("SLOW_REQUEST_THRESHOLD", 0),
+		
+		// S3-format access log (disabled by default)
+		AccessLogPath: getEnv("ACCESS_LOG_PATH", ""),
+		
+		// Feature flags for staged rollouts of risky behavior
+		FeatureFlags: getMapEnv("FEATURE_FLAGS"),
+		
+		// Multi-tenant isolation (disabled by default)
+		TenantsFile:              getEnv("TENANTS_FILE", ""),
+		TenantUsageRefresh:       getDurationEnv("TENANT_USAGE_REFRESH", 5*time.Minute),
+		VaultTransitPathTemplate: getEnv("VAULT_TRANSIT_PATH_TEMPLATE", ""),
+		
+		// Least-privilege access keys (disabled by default)
+		ScopedKeysFile: getEnv("SCOPED_KEYS_FILE", ""),
+		
+		// Cross-host job locking (disabled by default)
+		LockBucket: getEnv("LOCK_BUCKET", ""),
+		LockTTL:    getDurationEnv("LOCK_TTL", time.Minute),
+		
+		// Cross-region replication (disabled by default)
+		ReplicationEndpoint:        getEnv("REPLICATION_ENDPOINT", ""),
+		ReplicationRegion:          getEnv("REPLICATION_REGION", ""),
+		ReplicationCACertPath:      getEnv("REPLICATION_CA_CERT_PATH", ""),
+		ReplicationAccessKeyID:     getEnv("REPLICATION_ACCESS_KEY_ID", ""),
+		ReplicationSecretAccessKey: getEnv("REPLICATION_SECRET_ACCESS_KEY", ""),
+		ReplicationConfigBucket:    getEnv("REPLICATION_CONFIG_BUCKET", ""),
+		ReplicationWorkers:         getIntEnv("REPLICATION_WORKERS", 4),
+		ReplicationQueueSize:       getIntEnv("REPLICATION_QUEUE_SIZE", 10000),
+		
+		// Per-bucket KMS key bindings (disabled by default)
+		KMSBindingsBucket: getEnv("KMS_BINDINGS_BUCKET", ""),
+		
+		// Recorded bucket locations (disabled by default)
+		BucketLocationsBucket: getEnv("BUCKET_LOCATIONS_BUCKET", ""),
+		
+		// Bucket provisioning on first upload (disabled by default)
+		AutoCreateBuckets: getListEnv("AUTO_CREATE_BUCKETS"),
+		
+		// Request extensions (disabled by default)
+		ExtensionModules: getListEnv("EXTENSION_MODULES"),
+		ExtensionTimeout: getDurationEnv("EXTENSION_TIMEOUT", 100*time.Millisecond),
+		
+		// Event notifications (disabled by default)
+		NotificationSQSURL:             getEnv("NOTIFICATION_SQS_URL", ""),
+		NotificationSQSRegion:          getEnv("NOTIFICATION_SQS_REGION", ""),
+		NotificationSQSAccessKeyID:     getEnv("NOTIFICATION_SQS_ACCESS_KEY_ID", ""),
+		NotificationSQSSecretAccessKey: getEnv("NOTIFICATION_SQS_SECRET_ACCESS_KEY", ""),
+		NotificationBuckets:            getListEnv("NOTIFICATION_BUCKETS"),
+		NotificationEvents:             getListEnvDefault("NOTIFICATION_EVENTS", "s3:ObjectCreated:*,s3:ObjectRemoved:*"),
+		NotificationQueueSize:          getIntEnv("NOTIFICATION_QUEUE_SIZE", 10000),
+		
+		// Traffic shadowing (disabled by default)
+		ShadowEndpoint:     getEnv("SHADOW_ENDPOINT", ""),
+		ShadowCACertPath:   getEnv("SHADOW_CA_CERT_PATH", ""),
+		ShadowSampleRatio:  getFloatEnv("SHADOW_SAMPLE_RATIO", 0.01),
+		ShadowWrites:       getBoolEnv("SHADOW_WRITES", false),
+		ShadowMaxWriteSize: getIntEnv("SHADOW_MAX_WRITE_SIZE", 8*1024*1024),
+		
+		// Scheduled inventory reports (disabled by default)
+		InventoryBuckets:     getListEnv("INVENTORY_BUCKETS"),
+		InventoryDestination: getEnv("INVENTORY_DESTINATION", ""),
+		InventoryInterval:    getDurationEnv("INVENTORY_INTERVAL", 24*time.Hour),
+		InventoryKMSKeyARN:   getEnv("INVENTORY_KMS_KEY_ARN", ""),
+		
+		// Per-bucket upload limits (none by default)
+		BucketMaxObjectSize:   getMapEnv("BUCKET_MAX_OBJECT_SIZE"),
+		BucketMaxKeyLength:    getMapEnv("BUCKET_MAX_KEY_LENGTH"),
+		BucketMaxKeys:         getMapEnv("BUCKET_MAX_KEYS"),
+		BucketKeyCountRefresh: getDurationEnv("BUCKET_KEY_COUNT_REFRESH", 5*time.Minute),
+		
+		// Per-bucket response headers (none by default)
+		BucketHeadersFile: getEnv("BUCKET_HEADERS_FILE", ""),
+		
+		// Soft-delete trash (disabled by default)
+		TrashBuckets:   getListEnv("TRASH_BUCKETS"),
+		TrashRetention: getDurationEnv("TRASH_RETENTION", 7*24*time.Hour),
+		
+		// Abandoned multipart upload janitor (disabled by default)
+		AbortUploadsBuckets: getListEnv("ABORT_UPLOADS_BUCKETS"),
+		AbortUploadsAfter:   getDurationEnv("ABORT_UPLOADS_AFTER", 7*24*time.Hour),
+		
+		// Rewrap and gc admin jobs (disabled by default)
+		MaintenanceBuckets: getListEnv("MAINTENANCE_BUCKETS"),
+		
+		// Usage accounting (disabled by default)
+		UsageEnabled:        getBoolEnv("USAGE_ENABLED", false),
+		UsageFile:           getEnv("USAGE_FILE", ""),
+		UsageFlushInterval:  getDurationEnv("USAGE_FLUSH_INTERVAL", time.Minute),
+		UsageStorageRefresh: getDurationEnv("USAGE_STORAGE_REFRESH", time.Hour),
+		
+		// Bucket statistics
+		BucketStatsTTL: getDurationEnv("BUCKET_STATS_TTL", 15*time.Minute),
+		
+		// Fault injection (disabled by default)
+		ChaosEnabled:           getBoolEnv("CHAOS_ENABLED", false),
+		ChaosLatency:           getDurationEnv("CHAOS_LATENCY", time.Second),
+		ChaosLatencyRatio:      getFloatEnv("CHAOS_LATENCY_RATIO", 0),
+		ChaosVaultErrorRatio:   getFloatEnv("CHAOS_VAULT_ERROR_RATIO", 0),
+		ChaosBackendResetRatio: getFloatEnv("CHAOS_BACKEND_RESET_RATIO", 0),
+		ChaosTruncateRatio:     getFloatEnv("CHAOS_TRUNCATE_RATIO", 0),
+		
 		// Logging configuration
 		LogLevel:      getEnv("LOG_LEVEL", "info"),
 		LogFormat:     getEnv("LOG_FORMAT", "json"),
@@ -75,12 +598,6 @@ func LoadConfig() (*Config, error) {
 		Date:    getEnv("DATE", "unknown"),
 		BuiltBy: getEnv("BUILT_BY", "unknown"),
 	}
-	
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
-	}
-	
-	return cfg, nil
 }
 
 // Validate ensures all required configuration is present
@@ -89,24 +606,424 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("S3_ENDPOINT is required")
 	}
 	
-	if c.VaultAddr == "" && os.Getenv("VAULT_ADDR") == "" {
-		return fmt.Errorf("VAULT_ADDR is required")
+	if c.DevMode {
+		if c.TenantsFile != "" {
+			return fmt.Errorf("TENANTS_FILE needs Vault and cannot be used with DEV_MODE")
+		}
+	} else {
+		if c.VaultAddr == "" && os.Getenv("VAULT_ADDR") == "" {
+			return fmt.Errorf("VAULT_ADDR is required")
+		}
+		
+		// Check if we have any way to get a vault token
+		hasToken := c.VaultToken != ""
+		hasTokenFile := c.VaultTokenPath != ""
+		hasTokenEnv := os.Getenv("VAULT_TOKEN") != ""
+		
+		if !hasToken && !hasTokenFile && !hasTokenEnv {
+			return fmt.Errorf("either VAULT_TOKEN or VAULT_TOKEN_PATH must be set")
+		}
+	}
+	
+	if _, err := c.Features(); err != nil {
+		return err
+	}
+	
+	tenants, err := c.Tenants()
+	if err != nil {
+		return err
+	}
+	if _, err := c.TransitPathTemplate(); err != nil {
+		return err
+	}
+	if c.VaultTransitPathTemplate != "" && tenants == nil {
+		return fmt.Errorf("VAULT_TRANSIT_PATH_TEMPLATE applies to tenants' Vault identities and needs TENANTS_FILE")
+	}
+	if tenants != nil && tenants.HasQuotas() {
+		if _, err := c.OperatorCredentials(); err != nil {
+			return fmt.Errorf("tenant storage quotas need S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY to count usage")
+		}
+		if c.TenantUsageRefresh <= 0 {
+			return fmt.Errorf("TENANT_USAGE_REFRESH must be positive")
+		}
+	}
+	
+	if _, err := c.ScopedKeys(); err != nil {
+		return err
+	}
+	
+	if c.LockBucket != "" && c.LockTTL <= 0 {
+		return fmt.Errorf("LOCK_TTL must be positive")
+	}
+	
+	if c.ReplicationEndpoint != "" {
+		if c.ReplicationConfigBucket == "" {
+			return fmt.Errorf("REPLICATION_CONFIG_BUCKET is required when REPLICATION_ENDPOINT is set")
+		}
+		if _, err := c.OperatorCredentials(); err != nil {
+			return fmt.Errorf("replication needs S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY to read objects")
+		}
+		if c.ReplicationWorkers <= 0 || c.ReplicationQueueSize <= 0 {
+			return fmt.Errorf("REPLICATION_WORKERS and REPLICATION_QUEUE_SIZE must be positive")
+		}
+	}
+	
+	switch c.LegacyObjects {
+	case "", "serve", "deny":
+	case "encrypt":
+		if c.LegacyKMSKeyARN == "" && c.DefaultKMSKeyARN == "" {
+			return fmt.Errorf("LEGACY_OBJECTS=encrypt needs LEGACY_KMS_KEY_ARN or DEFAULT_KMS_KEY_ARN")
+		}
+		if _, err := c.OperatorCredentials(); err != nil {
+			return fmt.Errorf("LEGACY_OBJECTS=encrypt needs S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY to rewrite objects")
+		}
+		if c.LegacyMigrationConcurrency < 1 {
+			return fmt.Errorf("LEGACY_MIGRATION_CONCURRENCY must be positive")
+		}
+	default:
+		return fmt.Errorf("LEGACY_OBJECTS must be serve, deny or encrypt, got %q", c.LegacyObjects)
+	}
+	
+	if c.KMSBindingsBucket != "" {
+		if _, err := c.OperatorCredentials(); err != nil {
+			return fmt.Errorf("KMS_BINDINGS_BUCKET needs S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY to read bindings")
+		}
 	}
 	
-	// Check if we have any way to get a vault token
-	hasToken := c.VaultToken != ""
-	hasTokenFile := c.VaultTokenPath != ""
-	hasTokenEnv := os.Getenv("VAULT_TOKEN") != ""
+	if c.BucketLocationsBucket != "" {
+		if _, err := c.OperatorCredentials(); err != nil {
+			return fmt.Errorf("BUCKET_LOCATIONS_BUCKET needs S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY to record locations")
+		}
+	}
 	
-	if !hasToken && !hasTokenFile && !hasTokenEnv {
-		return fmt.Errorf("either VAULT_TOKEN or VAULT_TOKEN_PATH must be set")
+	if len(c.AutoCreateBuckets) > 0 {
+		if err := provision.ValidatePatterns(c.AutoCreateBuckets); err != nil {
+			return fmt.Errorf("AUTO_CREATE_BUCKETS: %w", err)
+		}
+		if _, err := c.OperatorCredentials(); err != nil {
+			return fmt.Errorf("AUTO_CREATE_BUCKETS needs S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY to create buckets")
+		}
+	}
+	
+	if len(c.ExtensionModules) > 0 {
+		if !extensions.Supported {
+			return fmt.Errorf("EXTENSION_MODULES is not supported yet: this build has no WebAssembly runtime")
+		}
+		if c.ExtensionTimeout <= 0 {
+			return fmt.Errorf("EXTENSION_TIMEOUT must be positive")
+		}
+	}
+	
+	if c.NotificationSQSURL != "" {
+		if c.NotificationQueueSize <= 0 {
+			return fmt.Errorf("NOTIFICATION_QUEUE_SIZE must be positive")
+		}
+		if (c.NotificationSQSAccessKeyID == "") != (c.NotificationSQSSecretAccessKey == "") {
+			return fmt.Errorf("NOTIFICATION_SQS_ACCESS_KEY_ID and NOTIFICATION_SQS_SECRET_ACCESS_KEY must be set together")
+		}
+	}
+	
+	limits, err := c.BucketLimits()
+	if err != nil {
+		return err
+	}
+	if limits.CountsKeys() {
+		if _, err := c.OperatorCredentials(); err != nil {
+			return fmt.Errorf("BUCKET_MAX_KEYS needs S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY to count keys")
+		}
+		if c.BucketKeyCountRefresh <= 0 {
+			return fmt.Errorf("BUCKET_KEY_COUNT_REFRESH must be positive")
+		}
+	}
+	
+	if len(c.TrashBuckets) > 0 {
+		if _, err := c.OperatorCredentials(); err != nil {
+			return fmt.Errorf("the trash needs S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY to copy deleted objects")
+		}
+		if c.TrashRetention <= 0 {
+			return fmt.Errorf("TRASH_RETENTION must be positive")
+		}
+	}
+	
+	if len(c.AbortUploadsBuckets) > 0 {
+		if _, err := c.OperatorCredentials(); err != nil {
+			return fmt.Errorf("aborting uploads needs S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY to list them")
+		}
+		if c.AbortUploadsAfter <= 0 {
+			return fmt.Errorf("ABORT_UPLOADS_AFTER must be positive")
+		}
+	}
+	
+	if len(c.MaintenanceBuckets) > 0 {
+		if c.AdminAddr == "" {
+			return fmt.Errorf("MAINTENANCE_BUCKETS requires ADMIN_ADDR")
+		}
+		if _, err := c.OperatorCredentials(); err != nil {
+			return fmt.Errorf("maintenance jobs need S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY to rewrite metadata")
+		}
+	}
+	
+	if c.UsageEnabled {
+		if c.UsageFlushInterval <= 0 {
+			return fmt.Errorf("USAGE_FLUSH_INTERVAL must be positive")
+		}
+		if c.UsageStorageRefresh < 0 {
+			return fmt.Errorf("USAGE_STORAGE_REFRESH cannot be negative")
+		}
+		if _, err := c.OperatorCredentials(); err != nil && c.UsageStorageRefresh > 0 {
+			return fmt.Errorf("counting stored bytes needs S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY; set USAGE_STORAGE_REFRESH=0 to skip it")
+		}
+	}
+	if c.BucketStatsTTL < 0 {
+		return fmt.Errorf("BUCKET_STATS_TTL cannot be negative")
+	}
+	
+	if c.S3HostMode != "preserve" && c.S3HostMode != "rewrite" {
+		return fmt.Errorf("invalid S3_HOST_MODE %q (expected preserve or rewrite)", c.S3HostMode)
+	}
+	
+	switch c.S3Client {
+	case "net/http":
+	case "fasthttp":
+		if c.S3EnableHTTP2 {
+			return fmt.Errorf("S3_HTTP2 is not supported with S3_CLIENT=fasthttp")
+		}
+		if c.S3BodyIdleTimeout > 0 {
+			return fmt.Errorf("S3_BODY_IDLE_TIMEOUT is not supported with S3_CLIENT=fasthttp")
+		}
+	default:
+		return fmt.Errorf("invalid S3_CLIENT %q (expected net/http or fasthttp)", c.S3Client)
+	}
+	
+	if _, err := c.ListenerSpecs(); err != nil {
+		return err
+	}
+	
+	if c.MetadataTimeout < 0 || c.DownloadTimeout < 0 || c.UploadTimeout < 0 || c.S3BodyIdleTimeout < 0 || c.DisconnectCheckInterval < 0 {
+		return fmt.Errorf("operation and idle timeouts cannot be negative")
+	}
+	
+	if c.ShutdownDelay < 0 || c.ShutdownTimeout < 0 {
+		return fmt.Errorf("SHUTDOWN_DELAY and SHUTDOWN_TIMEOUT cannot be negative")
+	}
+	
+	readyChecks, err := c.ReadyCheckSpecs()
+	if err != nil {
+		return err
+	}
+	for _, check := range readyChecks {
+		if check.Name == ReadyCheckDisk && len(c.LocalStorageDirs()) == 0 {
+			return fmt.Errorf("READY_CHECKS disk needs SPOOL_ENABLED or DISK_CACHE_ENABLED")
+		}
+	}
+	
+	if c.SpoolEnabled && c.SpoolThreshold < 1 {
+		return fmt.Errorf("SPOOL_THRESHOLD must be positive when spooling is enabled")
+	}
+	
+	if c.S3MaxIdleConns < 0 || c.S3MaxIdleConnsPerHost < 0 || c.S3MaxConnsPerHost < 0 {
+		return fmt.Errorf("S3 connection limits cannot be negative")
+	}
+	
+	if c.DeleteObjectsConcurrency < 1 {
+		return fmt.Errorf("DELETE_OBJECTS_CONCURRENCY must be at least 1")
+	}
+	
+	if c.MaxConcurrentUploads < 0 || c.MaxUploadBytes < 0 {
+		return fmt.Errorf("MAX_CONCURRENT_UPLOADS and MAX_UPLOAD_BYTES cannot be negative")
+	}
+	if c.UploadQueueTimeout < 0 {
+		return fmt.Errorf("UPLOAD_QUEUE_TIMEOUT cannot be negative")
+	}
+	
+	if c.ObjectCacheEnabled && c.ObjectCacheMaxObjectSize > c.ObjectCacheMaxBytes {
+		return fmt.Errorf("OBJECT_CACHE_MAX_OBJECT_SIZE cannot exceed OBJECT_CACHE_MAX_BYTES")
+	}
+	
+	if c.ListMetadataBudget < 0 {
+		return fmt.Errorf("LIST_METADATA_BUDGET cannot be negative")
+	}
+	
+	if c.DiskCacheEnabled {
+		if !c.ObjectCacheEnabled {
+			return fmt.Errorf("DISK_CACHE_ENABLED requires OBJECT_CACHE_ENABLED")
+		}
+		if c.DiskCachePath == "" {
+			return fmt.Errorf("DISK_CACHE_PATH is required when the disk cache is enabled")
+		}
+	}
+	
+	switch c.MetadataCache {
+	case "", "memory":
+	case "redis":
+		if c.RedisAddr == "" {
+			return fmt.Errorf("REDIS_ADDR is required when METADATA_CACHE is redis")
+		}
+	default:
+		return fmt.Errorf("invalid METADATA_CACHE %q (expected memory or redis)", c.MetadataCache)
+	}
+	
+	switch c.ErrorReporting {
+	case "", "otlp":
+	case "sentry":
+		if c.SentryDSN == "" {
+			return fmt.Errorf("SENTRY_DSN is required when ERROR_REPORTING is sentry")
+		}
+	default:
+		return fmt.Errorf("invalid ERROR_REPORTING %q (expected sentry or otlp)", c.ErrorReporting)
+	}
+	
+	if c.ErrorReportSampleRate < 0 || c.ErrorReportSampleRate > 1 {
+		return fmt.Errorf("ERROR_REPORT_SAMPLE_RATE must be between 0 and 1")
+	}
+	
+	if c.AdminAddr != "" && c.AdminToken == "" {
+		return fmt.Errorf("ADMIN_TOKEN is required when ADMIN_ADDR is set")
+	}
+	if c.PprofEnabled && c.AdminAddr == "" {
+		return fmt.Errorf("DEBUG_PPROF_ENABLED requires ADMIN_ADDR")
+	}
+	
+	if c.CaptureEnabled && c.AdminAddr == "" {
+		return fmt.Errorf("CAPTURE_ENABLED requires ADMIN_ADDR")
+	}
+	if c.SignatureDiagnosticsEnabled && c.AdminAddr == "" {
+		return fmt.Errorf("SIGNATURE_DIAGNOSTICS_ENABLED requires ADMIN_ADDR")
+	}
+	
+	if len(c.InventoryBuckets) > 0 {
+		if c.InventoryDestination == "" {
+			return fmt.Errorf("INVENTORY_DESTINATION is required when INVENTORY_BUCKETS is set")
+		}
+		if c.InventoryInterval <= 0 {
+			return fmt.Errorf("INVENTORY_INTERVAL must be positive")
+		}
+		if _, err := c.OperatorCredentials(); err != nil {
+			return fmt.Errorf("inventory reports need S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY to list buckets")
+		}
+	}
+	
+	if c.VaultCanaryRatio < 0 || c.VaultCanaryRatio > 1 {
+		return fmt.Errorf("VAULT_CANARY_RATIO must be between 0 and 1")
+	}
+	
+	if c.ChaosEnabled {
+		for name, ratio := range map[string]float64{
+			"CHAOS_LATENCY_RATIO":       c.ChaosLatencyRatio,
+			"CHAOS_VAULT_ERROR_RATIO":   c.ChaosVaultErrorRatio,
+			"CHAOS_BACKEND_RESET_RATIO": c.ChaosBackendResetRatio,
+			"CHAOS_TRUNCATE_RATIO":      c.ChaosTruncateRatio,
+		} {
+			if ratio < 0 || ratio > 1 {
+				return fmt.Errorf("%s must be between 0 and 1", name)
+			}
+		}
+		if c.ChaosLatency < 0 {
+			return fmt.Errorf("CHAOS_LATENCY cannot be negative")
+		}
+	}
+	
+	if c.ShadowEndpoint != "" && (c.ShadowSampleRatio < 0 || c.ShadowSampleRatio > 1) {
+		return fmt.Errorf("SHADOW_SAMPLE_RATIO must be between 0 and 1")
+	}
+	
+	if c.TracingEnabled && (c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1) {
+		return fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1")
 	}
 	
 	return nil
 }
 
+// Features resolves FEATURE_FLAGS against the known flags
+func (c *Config) Features() (*features.Set, error) {
+	return features.Parse(c.FeatureFlags)
+}
+
+// BucketLimits parses the per-bucket upload limits, returning nil when none are set
+func (c *Config) BucketLimits() (*bucketlimits.Set, error) {
+	return bucketlimits.Parse(c.BucketMaxObjectSize, c.BucketMaxKeyLength, c.BucketMaxKeys)
+}
+
+// BucketHeaders loads BUCKET_HEADERS_FILE, returning nil when no headers are configured
+func (c *Config) BucketHeaders() (*bucketheaders.Set, error) {
+	if c.BucketHeadersFile == "" {
+		return nil, nil
+	}
+	return bucketheaders.Load(c.BucketHeadersFile)
+}
+
+// Tenants loads TENANTS_FILE, returning nil when tenancy is disabled
+func (c *Config) Tenants() (*tenancy.Registry, error) {
+	if c.TenantsFile == "" {
+		return nil, nil
+	}
+	return tenancy.Load(c.TenantsFile)
+}
+
+// TransitPathTemplate parses VAULT_TRANSIT_PATH_TEMPLATE
+func (c *Config) TransitPathTemplate() (vault.PathTemplate, error) {
+	return vault.ParsePathTemplate(c.VaultTransitPathTemplate)
+}
+
+// ScopedKeys loads SCOPED_KEYS_FILE, returning nil when scoping is disabled
+func (c *Config) ScopedKeys() (*scopedkeys.Set, error) {
+	if c.ScopedKeysFile == "" {
+		return nil, nil
+	}
+	return scopedkeys.Load(c.ScopedKeysFile)
+}
+
+// OperatorCredentials returns the credentials maintenance commands sign with,
+// or an error naming the missing variables
+func (c *Config) OperatorCredentials() (sigv4.Credentials, error) {
+	if c.S3AccessKeyID == "" || c.S3SecretAccessKey == "" {
+		return sigv4.Credentials{}, fmt.Errorf("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required for this command")
+	}
+	return sigv4.Credentials{
+		AccessKey: c.S3AccessKeyID,
+		SecretKey: c.S3SecretAccessKey,
+		Region:    c.S3Region,
+	}, nil
+}
+
+// ReplicationCredentials returns the credentials that sign requests to the
+// replication endpoint, defaulting to the operator credentials
+func (c *Config) ReplicationCredentials() (sigv4.Credentials, error) {
+	credentials, err := c.OperatorCredentials()
+	if c.ReplicationAccessKeyID != "" {
+		credentials, err = sigv4.Credentials{
+			AccessKey: c.ReplicationAccessKeyID,
+			SecretKey: c.ReplicationSecretAccessKey,
+		}, nil
+	}
+	if err != nil {
+		return sigv4.Credentials{}, err
+	}
+	credentials.Region = c.S3Region
+	if c.ReplicationRegion != "" {
+		credentials.Region = c.ReplicationRegion
+	}
+	return credentials, nil
+}
+
+// NotificationCredentials returns the credentials that sign SendMessage
+// requests, in NOTIFICATION_SQS_REGION or else S3_REGION. Without an access
+// key requests are sent unsigned.
+func (c *Config) NotificationCredentials() sigv4.Credentials {
+	credentials := sigv4.Credentials{
+		AccessKey: c.NotificationSQSAccessKeyID,
+		SecretKey: c.NotificationSQSSecretAccessKey,
+		Region:    c.S3Region,
+	}
+	if c.NotificationSQSRegion != "" {
+		credentials.Region = c.NotificationSQSRegion
+	}
+	return credentials
+}
+
 // getEnv gets an environment variable with a fallback default
 func getEnv(key, defaultValue string) string {
+	register(key, KindString, defaultValue)
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
@@ -115,6 +1032,7 @@ func getEnv(key, defaultValue string) string {
 
 // getBoolEnv gets a boolean environment variable with a fallback default
 func getBoolEnv(key string, defaultValue bool) bool {
+	register(key, KindBool, strconv.FormatBool(defaultValue))
 	if value := os.Getenv(key); value != "" {
 		if parsed, err := strconv.ParseBool(value); err == nil {
 			return parsed
@@ -125,10 +1043,69 @@ func getBoolEnv(key string, defaultValue bool) bool {
 
 // getIntEnv gets an integer environment variable with a fallback default
 func getIntEnv(key string, defaultValue int) int {
+	register(key, KindInt, strconv.Itoa(defaultValue))
 	if value := os.Getenv(key); value != "" {
 		if parsed, err := strconv.Atoi(value); err == nil {
 			return parsed
 		}
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+// getDurationEnv gets a duration environment variable with a fallback default
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	register(key, KindDuration, defaultValue.String())
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getFloatEnv gets a float environment variable with a fallback default
+func getFloatEnv(key string, defaultValue float64) float64 {
+	register(key, KindFloat, strconv.FormatFloat(defaultValue, 'g', -1, 64))
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getMapEnv parses a comma separated list of key=value pairs
+func getMapEnv(key string) map[string]string {
+	register(key, KindMap, "")
+	result := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		name, value, found := strings.Cut(pair, "=")
+		if !found || strings.TrimSpace(name) == "" {
+			continue
+		}
+		result[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return result
+}
+
+// getListEnv parses a comma separated list, skipping empty entries
+func getListEnv(key string) []string {
+	return getListEnvDefault(key, "")
+}
+
+// getListEnvDefault parses a comma-separated list like getListEnv, using
+// defaultValue when the variable is unset
+func getListEnvDefault(key, defaultValue string) []string {
+	register(key, KindList, defaultValue)
+	value := os.Getenv(key)
+	if value == "" {
+		value = defaultValue
+	}
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
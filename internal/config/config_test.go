@@ -44,12 +44,19 @@ func TestLoadConfig(t *testing.T) {
 		assert.Equal(t, 16384, cfg.ReadBufferSize)
 		assert.Equal(t, 16384, cfg.WriteBufferSize)
 		assert.Equal(t, true, cfg.DisableStartupMsg)
+		assert.Equal(t, 16, cfg.DeleteObjectsConcurrency)
+		assert.Equal(t, 0, cfg.MaxConcurrentUploads)
+		assert.Equal(t, 5*time.Second, cfg.UploadQueueTimeout)
+		assert.Equal(t, false, cfg.ObjectCacheEnabled)
+		assert.Equal(t, 5*time.Minute, cfg.ObjectCacheTTL)
 
 		// Test environment values
 		assert.Equal(t, "http://localhost:9000", cfg.S3Endpoint)
 		assert.Equal(t, "http://localhost:8200", cfg.VaultAddr)
 		assert.Equal(t, "test-token", cfg.VaultToken)
 		assert.Equal(t, "/vault/secrets/token", cfg.VaultTokenPath)
+		assert.Equal(t, 30*time.Second, cfg.S3ResponseHeaderTimeout)
+		assert.Equal(t, time.Duration(0), cfg.S3RequestTimeout)
 
 		// Test build defaults
 		assert.Equal(t, "dev", cfg.Version)
@@ -132,13 +139,123 @@ func TestConfigValidation(t *testing.T) {
 			},
 			expectError: "",
 		},
+		{
+			name: "Dev mode needs no Vault",
+			setupEnv: func() {
+				os.Setenv("S3_ENDPOINT", "http://localhost:9000")
+				os.Setenv("DEV_MODE", "true")
+			},
+			expectError: "",
+		},
+		{
+			name: "Dev mode without tenancy",
+			setupEnv: func() {
+				os.Setenv("S3_ENDPOINT", "http://localhost:9000")
+				os.Setenv("DEV_MODE", "true")
+				os.Setenv("TENANTS_FILE", "/etc/tenants.yaml")
+			},
+			expectError: "cannot be used with DEV_MODE",
+		},
+		{
+			name: "Invalid S3_HOST_MODE",
+			setupEnv: func() {
+				os.Setenv("S3_ENDPOINT", "http://localhost:9000")
+				os.Setenv("VAULT_ADDR", "http://localhost:8200")
+				os.Setenv("VAULT_TOKEN", "test-token")
+				os.Setenv("S3_HOST_MODE", "resign")
+			},
+			expectError: "invalid S3_HOST_MODE",
+		},
+		{
+			name: "fasthttp client with HTTP/2",
+			setupEnv: func() {
+				os.Setenv("S3_ENDPOINT", "http://localhost:9000")
+				os.Setenv("VAULT_ADDR", "http://localhost:8200")
+				os.Setenv("VAULT_TOKEN", "test-token")
+				os.Setenv("S3_CLIENT", "fasthttp")
+				os.Setenv("S3_HTTP2", "true")
+			},
+			expectError: "S3_HTTP2 is not supported with S3_CLIENT=fasthttp",
+		},
+		{
+			name: "Transit path template without tenancy",
+			setupEnv: func() {
+				os.Setenv("S3_ENDPOINT", "http://localhost:9000")
+				os.Setenv("VAULT_ADDR", "http://localhost:8200")
+				os.Setenv("VAULT_TOKEN", "test-token")
+				os.Setenv("VAULT_TRANSIT_PATH_TEMPLATE", "transit/{{tenant}}/{{operation}}/{{key}}")
+			},
+			expectError: "needs TENANTS_FILE",
+		},
+		{
+			name: "Invalid transit path template",
+			setupEnv: func() {
+				os.Setenv("S3_ENDPOINT", "http://localhost:9000")
+				os.Setenv("VAULT_ADDR", "http://localhost:8200")
+				os.Setenv("VAULT_TOKEN", "test-token")
+				os.Setenv("VAULT_TRANSIT_PATH_TEMPLATE", "transit/{{tenant}}/encrypt/{{key}}")
+			},
+			expectError: "needs {{operation}} and {{key}}",
+		},
+		{
+			name: "Negative upload limit",
+			setupEnv: func() {
+				os.Setenv("S3_ENDPOINT", "http://localhost:9000")
+				os.Setenv("VAULT_ADDR", "http://localhost:8200")
+				os.Setenv("VAULT_TOKEN", "test-token")
+				os.Setenv("MAX_CONCURRENT_UPLOADS", "-1")
+			},
+			expectError: "MAX_CONCURRENT_UPLOADS and MAX_UPLOAD_BYTES cannot be negative",
+		},
+		{
+			name: "Invalid bucket provisioning pattern",
+			setupEnv: func() {
+				os.Setenv("S3_ENDPOINT", "http://localhost:9000")
+				os.Setenv("VAULT_ADDR", "http://localhost:8200")
+				os.Setenv("VAULT_TOKEN", "test-token")
+				os.Setenv("AUTO_CREATE_BUCKETS", "ci-[")
+			},
+			expectError: "AUTO_CREATE_BUCKETS",
+		},
+		{
+			name: "Extensions without a WASM runtime",
+			setupEnv: func() {
+				os.Setenv("S3_ENDPOINT", "http://localhost:9000")
+				os.Setenv("VAULT_ADDR", "http://localhost:8200")
+				os.Setenv("VAULT_TOKEN", "test-token")
+				os.Setenv("EXTENSION_MODULES", "/etc/s3-vault-proxy/policy.wasm")
+			},
+			expectError: "EXTENSION_MODULES",
+		},
+		{
+			name: "Admin listener without a token",
+			setupEnv: func() {
+				os.Setenv("S3_ENDPOINT", "http://localhost:9000")
+				os.Setenv("VAULT_ADDR", "http://localhost:8200")
+				os.Setenv("VAULT_TOKEN", "test-token")
+				os.Setenv("ADMIN_ADDR", "127.0.0.1:9091")
+			},
+			expectError: "ADMIN_TOKEN",
+		},
+		{
+			name: "Maintenance buckets without the admin listener",
+			setupEnv: func() {
+				os.Setenv("S3_ENDPOINT", "http://localhost:9000")
+				os.Setenv("VAULT_ADDR", "http://localhost:8200")
+				os.Setenv("VAULT_TOKEN", "test-token")
+				os.Setenv("MAINTENANCE_BUCKETS", "photos")
+			},
+			expectError: "MAINTENANCE_BUCKETS",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Clean environment
 			envVars := []string{
-				"S3_ENDPOINT", "VAULT_ADDR", "VAULT_TOKEN", "VAULT_TOKEN_PATH",
+				"S3_ENDPOINT", "VAULT_ADDR", "VAULT_TOKEN", "VAULT_TOKEN_PATH", "S3_HOST_MODE",
+				"DEV_MODE", "TENANTS_FILE", "S3_CLIENT", "S3_HTTP2", "VAULT_TRANSIT_PATH_TEMPLATE",
+				"AUTO_CREATE_BUCKETS", "MAX_CONCURRENT_UPLOADS", "EXTENSION_MODULES", "ADMIN_ADDR", "ADMIN_TOKEN", "MAINTENANCE_BUCKETS",
 			}
 			for _, env := range envVars {
 				os.Unsetenv(env)
@@ -253,4 +370,47 @@ func TestGetIntEnv(t *testing.T) {
 			assert.Equal(t, tt.expected, result)
 		})
 	}
-}
\ No newline at end of file
+}
+func TestGetDurationEnv(t *testing.T) {
+	tests := []struct {
+		name       string
+		envValue   string
+		defaultVal time.Duration
+		expected   time.Duration
+		shouldSet  bool
+	}{
+		{"valid duration", "90s", time.Minute, 90 * time.Second, true},
+		{"minutes", "5m", time.Minute, 5 * time.Minute, true},
+		{"invalid string", "invalid", time.Minute, time.Minute, true},
+		{"bare number", "30", time.Minute, time.Minute, true},
+		{"not set", "", time.Minute, time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Unsetenv("TEST_DURATION")
+
+			if tt.shouldSet {
+				os.Setenv("TEST_DURATION", tt.envValue)
+				defer os.Unsetenv("TEST_DURATION")
+			}
+
+			result := getDurationEnv("TEST_DURATION", tt.defaultVal)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestVariables(t *testing.T) {
+	variables := Variables()
+
+	byName := make(map[string]Variable, len(variables))
+	for _, v := range variables {
+		byName[v.Name] = v
+	}
+	assert.Equal(t, Variable{Name: "PORT", Kind: KindString, Default: "9000"}, byName["PORT"])
+	assert.Equal(t, Variable{Name: "SPOOL_ENABLED", Kind: KindBool, Default: "false"}, byName["SPOOL_ENABLED"])
+	assert.Equal(t, Variable{Name: "OBJECT_CACHE_TTL", Kind: KindDuration, Default: "5m0s"}, byName["OBJECT_CACHE_TTL"])
+	assert.Equal(t, KindMap, byName["OTEL_EXPORTER_OTLP_HEADERS"].Kind)
+	assert.Equal(t, "PORT", variables[0].Name, "variables keep declaration order")
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// buildFields identify the binary rather than its configuration, so they are
+// left out of the checksum
+var buildFields = []string{"Version", "Commit", "Date", "BuiltBy"}
+
+// Subsystems returns the optional subsystems the configuration enables, sorted
+func (c *Config) Subsystems() []string {
+	enabled := map[string]bool{
+		"abort_uploads":         len(c.AbortUploadsBuckets) > 0,
+		"access_log":            c.AccessLogPath != "",
+		"admin":                 c.AdminAddr != "",
+		"bucket_headers":        c.BucketHeadersFile != "",
+		"capture":               c.CaptureEnabled,
+		"chaos":                 c.ChaosEnabled,
+		"dev_mode":              c.DevMode,
+		"disk_cache":            c.DiskCacheEnabled,
+		"error_reporting":       c.ErrorReporting != "",
+		"inventory":             len(c.InventoryBuckets) > 0,
+		"kms_bindings":          c.KMSBindingsBucket != "",
+		"locking":               c.LockBucket != "",
+		"metadata_cache":        c.MetadataCache != "",
+		"notifications":         c.NotificationSQSURL != "",
+		"object_cache":          c.ObjectCacheEnabled,
+		"read_only":             c.ReadOnly,
+		"replication":           c.ReplicationEndpoint != "",
+		"scoped_keys":           c.ScopedKeysFile != "",
+		"shadow":                c.ShadowEndpoint != "",
+		"signature_diagnostics": c.SignatureDiagnosticsEnabled,
+		"spool":                 c.SpoolEnabled,
+		"tenancy":               c.TenantsFile != "",
+		"tracing":               c.TracingEnabled,
+		"trash":                 len(c.TrashBuckets) > 0,
+		"usage":                 c.UsageEnabled,
+	}
+	names := make([]string, 0, len(enabled))
+	for name, on := range enabled {
+		if on {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CryptoMode describes how objects are encrypted. The backend always applies
+// SSE-KMS; the keys are Vault transit keys, or those of the in-process
+// transit engine in development mode.
+func (c *Config) CryptoMode() map[string]string {
+	keys := "vault-transit"
+	if c.DevMode {
+		keys = "dev-transit"
+	}
+	return map[string]string{
+		"mode":          "sse-kms",
+		"keys":          keys,
+		"transit_mount": c.VaultTransitMount,
+	}
+}
+
+// Checksum returns a SHA-256 digest of the effective configuration and the
+// files it names (TENANTS_FILE, SCOPED_KEYS_FILE and BUCKET_HEADERS_FILE),
+// so replicas running the same configuration report the same value. Secrets
+// only count as set or unset, and build information is left out.
+func (c *Config) Checksum() (string, error) {
+	values := c.Redacted()
+	for _, field := range buildFields {
+		delete(values, field)
+	}
+	files := map[string]string{}
+	for _, path := range []string{c.TenantsFile, c.ScopedKeysFile, c.BucketHeadersFile} {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s for the configuration checksum: %w", path, err)
+		}
+		sum := sha256.Sum256(data)
+		files[path] = hex.EncodeToString(sum[:])
+	}
+	values["files"] = files
+
+	// Map keys are marshaled in sorted order, so equal configurations encode equally
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksum(t *testing.T) {
+	scopes := filepath.Join(t.TempDir(), "scopes.json")
+	require.NoError(t, os.WriteFile(scopes, []byte(`{"keys": []}`), 0o600))
+	cfg := &Config{S3Endpoint: "http://minio:9000", ScopedKeysFile: scopes, Version: "1.0.0"}
+	sum, err := cfg.Checksum()
+	require.NoError(t, err)
+
+	other := *cfg
+	other.Version = "1.0.1"
+	other.VaultToken = "hvs.other"
+	otherSum, err := other.Checksum()
+	require.NoError(t, err)
+	assert.NotEqual(t, sum, otherSum, "setting a secret changes the configuration")
+
+	cfg.VaultToken = "hvs.token"
+	sum, _ = cfg.Checksum()
+	assert.Equal(t, sum, otherSum, "build information and secret values are left out")
+
+	require.NoError(t, os.WriteFile(scopes, []byte(`{"keys": [{}]}`), 0o600))
+	changed, err := cfg.Checksum()
+	require.NoError(t, err)
+	assert.NotEqual(t, sum, changed, "the files the configuration names are part of it")
+
+	cfg.ScopedKeysFile = filepath.Join(t.TempDir(), "missing.json")
+	_, err = cfg.Checksum()
+	assert.Error(t, err)
+}
+
+func TestSubsystems(t *testing.T) {
+	cfg := &Config{TrashBuckets: []string{"data"}, LockBucket: "locks", DevMode: true}
+	assert.Equal(t, []string{"dev_mode", "locking", "trash"}, cfg.Subsystems())
+	assert.Equal(t, "dev-transit", cfg.CryptoMode()["keys"])
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Listener is one S3 API listener with its own TLS and authentication settings
+type Listener struct {
+	// Addr is host:port, unix:<path> for a unix socket, or systemd[:<name>]
+	// for a socket inherited through systemd socket activation
+	Addr string
+
+	// SocketMode sets unix socket permissions (0 keeps the umask default)
+	SocketMode uint32
+
+	// TLS is enabled when both files are set
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile requires clients to present a certificate signed by this CA
+	ClientCAFile string
+
+	// RequireAuth rejects anonymous (unsigned) S3 requests
+	RequireAuth bool
+}
+
+// TLS reports whether the listener serves HTTPS
+func (l Listener) TLS() bool {
+	return l.CertFile != ""
+}
+
+// ListenerSpecs returns the configured listeners, defaulting to plain HTTP on PORT.
+//
+// LISTENERS is a comma separated list of addresses, each optionally followed by
+// semicolon separated options, e.g.
+//
+//	127.0.0.1:9000,0.0.0.0:9443;cert=/tls/tls.crt;key=/tls/tls.key;client_ca=/tls/ca.crt;require_auth
+//	unix:/run/s3-vault-proxy/s3.sock;mode=0660
+//	systemd:s3
+func (c *Config) ListenerSpecs() ([]Listener, error) {
+	if strings.TrimSpace(c.Listeners) == "" {
+		return []Listener{{Addr: ":" + c.Port}}, nil
+	}
+
+	var listeners []Listener
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(c.Listeners, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ";")
+		listener := Listener{Addr: strings.TrimSpace(parts[0])}
+		if listener.Addr == "" {
+			return nil, fmt.Errorf("LISTENERS entry %q has no address", entry)
+		}
+		if seen[listener.Addr] {
+			return nil, fmt.Errorf("LISTENERS address %s is listed twice", listener.Addr)
+		}
+		seen[listener.Addr] = true
+
+		for _, option := range parts[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+			switch name {
+			case "cert":
+				listener.CertFile = value
+			case "key":
+				listener.KeyFile = value
+			case "client_ca":
+				listener.ClientCAFile = value
+			case "require_auth":
+				listener.RequireAuth = true
+			case "mode":
+				mode, err := strconv.ParseUint(value, 8, 32)
+				if err != nil || !strings.HasPrefix(listener.Addr, "unix:") {
+					return nil, fmt.Errorf("listener %s: mode must be an octal permission on a unix socket", listener.Addr)
+				}
+				listener.SocketMode = uint32(mode)
+			default:
+				return nil, fmt.Errorf("unknown LISTENERS option %q for %s", name, listener.Addr)
+			}
+		}
+
+		if (listener.CertFile == "") != (listener.KeyFile == "") {
+			return nil, fmt.Errorf("listener %s needs both cert and key for TLS", listener.Addr)
+		}
+		if listener.ClientCAFile != "" && !listener.TLS() {
+			return nil, fmt.Errorf("listener %s sets client_ca without TLS", listener.Addr)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
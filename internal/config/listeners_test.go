@@ -0,0 +1,59 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenerSpecsDefault(t *testing.T) {
+	cfg := &Config{Port: "9000"}
+	listeners, err := cfg.ListenerSpecs()
+	require.NoError(t, err)
+	assert.Equal(t, []Listener{{Addr: ":9000"}}, listeners)
+}
+
+func TestListenerSpecs(t *testing.T) {
+	cfg := &Config{Listeners: "127.0.0.1:9000, 0.0.0.0:9443;cert=/tls/tls.crt;key=/tls/tls.key;client_ca=/tls/ca.crt;require_auth"}
+	listeners, err := cfg.ListenerSpecs()
+	require.NoError(t, err)
+	require.Len(t, listeners, 2)
+
+	assert.Equal(t, Listener{Addr: "127.0.0.1:9000"}, listeners[0])
+	assert.False(t, listeners[0].TLS())
+	assert.Equal(t, Listener{
+		Addr:         "0.0.0.0:9443",
+		CertFile:     "/tls/tls.crt",
+		KeyFile:      "/tls/tls.key",
+		ClientCAFile: "/tls/ca.crt",
+		RequireAuth:  true,
+	}, listeners[1])
+	assert.True(t, listeners[1].TLS())
+}
+
+func TestListenerSpecsInvalid(t *testing.T) {
+	for _, value := range []string{
+		"0.0.0.0:9443;cert=/tls/tls.crt",
+		"0.0.0.0:9000;client_ca=/tls/ca.crt",
+		"0.0.0.0:9000;bogus",
+		":9000,:9000",
+		";require_auth",
+	} {
+		_, err := (&Config{Listeners: value}).ListenerSpecs()
+		assert.Error(t, err, value)
+	}
+}
+
+func TestListenerSpecsSockets(t *testing.T) {
+	cfg := &Config{Listeners: "unix:/run/proxy.sock;mode=0660,systemd:s3"}
+	listeners, err := cfg.ListenerSpecs()
+	require.NoError(t, err)
+	assert.Equal(t, []Listener{
+		{Addr: "unix:/run/proxy.sock", SocketMode: 0o660},
+		{Addr: "systemd:s3"},
+	}, listeners)
+
+	_, err = (&Config{Listeners: "127.0.0.1:9000;mode=0660"}).ListenerSpecs()
+	assert.Error(t, err, "mode only applies to unix sockets")
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Readiness checks /ready can run
+const (
+	ReadyCheckVault   = "vault"
+	ReadyCheckBackend = "backend"
+	ReadyCheckDisk    = "disk"
+	ReadyCheckToken   = "token"
+)
+
+// Defaults of a ReadyCheck. The timeout stays below the usual Kubernetes probe timeout.
+const (
+	DefaultReadyCheckTimeout = 2 * time.Second
+	DefaultReadyMinFree      = 1 << 30
+	DefaultReadyMinTokenTTL  = 5 * time.Minute
+)
+
+// ReadyCheck is one check /ready runs before reporting the proxy ready
+type ReadyCheck struct {
+	Name string
+
+	// Timeout fails the check when it takes longer
+	Timeout time.Duration
+
+	// MinFree is the free space in bytes the disk check requires in the
+	// spool and disk cache directories
+	MinFree uint64
+
+	// MinTokenTTL is the shortest Vault token lifetime the token check
+	// accepts; tokens without an expiry always pass
+	MinTokenTTL time.Duration
+}
+
+// ReadyCheckSpecs returns the checks /ready runs, defaulting to Vault alone.
+//
+// READY_CHECKS is a comma separated list of checks, each optionally followed
+// by semicolon separated options, or "none" to only fail while draining, e.g.
+//
+//	vault;timeout=1s,backend;timeout=3s,disk;min_free=536870912,token;min_ttl=10m
+func (c *Config) ReadyCheckSpecs() ([]ReadyCheck, error) {
+	spec := strings.TrimSpace(c.ReadyChecks)
+	if spec == "" {
+		spec = ReadyCheckVault
+	}
+	if spec == "none" {
+		return nil, nil
+	}
+
+	var checks []ReadyCheck
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ";")
+		check := ReadyCheck{
+			Name:        strings.TrimSpace(parts[0]),
+			Timeout:     DefaultReadyCheckTimeout,
+			MinFree:     DefaultReadyMinFree,
+			MinTokenTTL: DefaultReadyMinTokenTTL,
+		}
+		switch check.Name {
+		case ReadyCheckVault, ReadyCheckBackend, ReadyCheckDisk, ReadyCheckToken:
+		default:
+			return nil, fmt.Errorf("READY_CHECKS has unknown check %q (expected vault, backend, disk or token)", check.Name)
+		}
+		if seen[check.Name] {
+			return nil, fmt.Errorf("READY_CHECKS lists %s twice", check.Name)
+		}
+		seen[check.Name] = true
+
+		for _, option := range parts[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+			var err error
+			switch {
+			case name == "timeout":
+				check.Timeout, err = time.ParseDuration(value)
+				if err == nil && check.Timeout <= 0 {
+					err = fmt.Errorf("must be positive")
+				}
+			case name == "min_free" && check.Name == ReadyCheckDisk:
+				check.MinFree, err = strconv.ParseUint(value, 10, 64)
+			case name == "min_ttl" && check.Name == ReadyCheckToken:
+				check.MinTokenTTL, err = time.ParseDuration(value)
+			default:
+				return nil, fmt.Errorf("READY_CHECKS %s has unknown option %q", check.Name, name)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("READY_CHECKS %s has invalid %s %q: %v", check.Name, name, value, err)
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// LocalStorageDirs returns the directories the proxy writes object data to:
+// the spool directory and the disk cache, when enabled
+func (c *Config) LocalStorageDirs() []string {
+	var dirs []string
+	if c.SpoolEnabled {
+		dir := c.SpoolDir
+		if dir == "" {
+			dir = os.TempDir()
+		}
+		dirs = append(dirs, dir)
+	}
+	if c.DiskCacheEnabled {
+		dirs = append(dirs, c.DiskCachePath)
+	}
+	return dirs
+}
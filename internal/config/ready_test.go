@@ -0,0 +1,60 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadyCheckSpecsDefault(t *testing.T) {
+	checks, err := (&Config{}).ReadyCheckSpecs()
+	require.NoError(t, err)
+	require.Len(t, checks, 1)
+	assert.Equal(t, ReadyCheckVault, checks[0].Name)
+	assert.Equal(t, DefaultReadyCheckTimeout, checks[0].Timeout)
+
+	checks, err = (&Config{ReadyChecks: "none"}).ReadyCheckSpecs()
+	require.NoError(t, err)
+	assert.Empty(t, checks)
+}
+
+func TestReadyCheckSpecs(t *testing.T) {
+	cfg := &Config{ReadyChecks: "vault;timeout=1s, backend,disk;min_free=1024;timeout=500ms,token;min_ttl=10m"}
+	checks, err := cfg.ReadyCheckSpecs()
+	require.NoError(t, err)
+	require.Len(t, checks, 4)
+
+	assert.Equal(t, time.Second, checks[0].Timeout)
+	assert.Equal(t, ReadyCheckBackend, checks[1].Name)
+	assert.Equal(t, DefaultReadyCheckTimeout, checks[1].Timeout)
+	assert.Equal(t, uint64(1024), checks[2].MinFree)
+	assert.Equal(t, 500*time.Millisecond, checks[2].Timeout)
+	assert.Equal(t, 10*time.Minute, checks[3].MinTokenTTL)
+	assert.Equal(t, time.Duration(DefaultReadyMinTokenTTL), checks[1].MinTokenTTL)
+}
+
+func TestReadyCheckSpecsInvalid(t *testing.T) {
+	for _, value := range []string{
+		"vault,vault",
+		"redis",
+		"vault;timeout=0s",
+		"vault;timeout=soon",
+		"vault;min_free=1",
+		"disk;min_free=-1",
+	} {
+		_, err := (&Config{ReadyChecks: value}).ReadyCheckSpecs()
+		assert.Error(t, err, value)
+	}
+}
+
+func TestValidateReadyDiskNeedsLocalStorage(t *testing.T) {
+	cfg := FromEnv()
+	cfg.S3Endpoint = "http://localhost:9000"
+	cfg.DevMode = true
+	cfg.ReadyChecks = "disk"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "READY_CHECKS disk needs")
+}
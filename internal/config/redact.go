@@ -0,0 +1,39 @@
+package config
+
+import (
+	"reflect"
+	"time"
+)
+
+// redactedValue replaces secrets in configuration dumps
+const redactedValue = "[REDACTED]"
+
+// Redacted returns the configuration keyed by field name with every field
+// tagged `secret:"true"` masked. Empty secrets stay empty so a dump still
+// shows whether a value was provided.
+func (c *Config) Redacted() map[string]interface{} {
+	value := reflect.ValueOf(c).Elem()
+	fields := value.Type()
+
+	result := make(map[string]interface{}, fields.NumField())
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		fieldValue := value.Field(i)
+
+		if field.Tag.Get("secret") == "true" {
+			if fieldValue.IsZero() || (fieldValue.Kind() == reflect.Map && fieldValue.Len() == 0) {
+				result[field.Name] = ""
+			} else {
+				result[field.Name] = redactedValue
+			}
+			continue
+		}
+
+		if d, ok := fieldValue.Interface().(time.Duration); ok {
+			result[field.Name] = d.String()
+			continue
+		}
+		result[field.Name] = fieldValue.Interface()
+	}
+	return result
+}
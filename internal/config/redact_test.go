@@ -0,0 +1,38 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedacted(t *testing.T) {
+	cfg := &Config{
+		S3Endpoint:     "http://minio:9000",
+		VaultToken:     "hvs.super-secret",
+		VaultTokenPath: "/vault/secrets/token",
+		RedisPassword:  "",
+		OTLPHeaders:    map[string]string{"x-api-key": "secret"},
+		ReadTimeout:    30 * time.Second,
+	}
+
+	redacted := cfg.Redacted()
+	assert.Equal(t, "http://minio:9000", redacted["S3Endpoint"])
+	assert.Equal(t, "/vault/secrets/token", redacted["VaultTokenPath"])
+	assert.Equal(t, redactedValue, redacted["VaultToken"])
+	assert.Equal(t, redactedValue, redacted["OTLPHeaders"])
+	assert.Equal(t, "", redacted["RedisPassword"])
+	assert.Equal(t, "30s", redacted["ReadTimeout"])
+
+	data, err := json.Marshal(redacted)
+	assert.NoError(t, err)
+	assert.False(t, strings.Contains(string(data), "super-secret"))
+}
+
+func TestRedactedCoversAllFields(t *testing.T) {
+	assert.Len(t, (&Config{}).Redacted(), reflect.TypeOf(Config{}).NumField())
+}
@@ -0,0 +1,49 @@
+package config
+
+import "sync"
+
+// Kind describes how a configuration variable's value is parsed
+type Kind string
+
+const (
+	KindString   Kind = "string"
+	KindBool     Kind = "bool"
+	KindInt      Kind = "int"
+	KindDuration Kind = "duration"
+	KindFloat    Kind = "float"
+	KindList     Kind = "list"
+	KindMap      Kind = "map"
+)
+
+// Variable is an environment variable read by LoadConfig
+type Variable struct {
+	Name    string
+	Kind    Kind
+	Default string
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []Variable
+	registered = make(map[string]bool)
+)
+
+// register records a variable the first time it is read
+func register(name string, kind Kind, defaultValue string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if registered[name] {
+		return
+	}
+	registered[name] = true
+	registry = append(registry, Variable{Name: name, Kind: kind, Default: defaultValue})
+}
+
+// Variables returns every environment variable the configuration reads, in
+// declaration order, so command line flags can mirror them
+func Variables() []Variable {
+	load()
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return append([]Variable(nil), registry...)
+}
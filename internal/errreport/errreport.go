@@ -0,0 +1,156 @@
+package errreport
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metrics"
+)
+
+var reportsTotal = metrics.NewCounter(
+	"s3_vault_proxy_error_reports_total",
+	"Error reports by outcome (sent, sampled_out, dropped, failed).",
+	"outcome",
+)
+
+// Event describes a server error or recovered panic with its request context
+type Event struct {
+	Time      time.Time
+	Message   string
+	Panic     bool
+	Stack     string
+	Method    string
+	Path      string
+	Status    int
+	RemoteIP  string
+	UserAgent string
+	RequestID string
+	TraceID   string
+	SpanID    string
+	Tags      map[string]string
+}
+
+// Sender delivers events to an error tracking backend
+type Sender interface {
+	Send(events []Event) error
+}
+
+const (
+	queueSize     = 256
+	flushInterval = 2 * time.Second
+	maxBatch      = 32
+)
+
+// Reporter samples and queues events, delivering them in the background so
+// error reporting never adds latency to the failing request
+type Reporter struct {
+	sender     Sender
+	sampleRate float64
+
+	queue    chan Event
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewReporter creates a reporter. Panics are always reported; other errors are
+// kept with probability sampleRate (0..1).
+func NewReporter(sender Sender, sampleRate float64) *Reporter {
+	r := &Reporter{
+		sender:     sender,
+		sampleRate: sampleRate,
+		queue:      make(chan Event, queueSize),
+		done:       make(chan struct{}),
+	}
+	r.wg.Add(1)
+	go r.run()
+	return r
+}
+
+// Report queues an event. A nil *Reporter ignores all events.
+func (r *Reporter) Report(event Event) {
+	if r == nil {
+		return
+	}
+	if !event.Panic && !sampled(r.sampleRate) {
+		reportsTotal.Inc("sampled_out")
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	select {
+	case r.queue <- event:
+	default:
+		reportsTotal.Inc("dropped")
+	}
+}
+
+// Shutdown delivers queued events and stops the background worker
+func (r *Reporter) Shutdown() {
+	if r == nil {
+		return
+	}
+	r.stopOnce.Do(func() { close(r.done) })
+	r.wg.Wait()
+}
+
+func (r *Reporter) run() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []Event
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := r.sender.Send(batch); err != nil {
+			reportsTotal.Add(float64(len(batch)), "failed")
+			logging.Warn().Err(err).Int("events", len(batch)).Msg("Failed to send error reports")
+		} else {
+			reportsTotal.Add(float64(len(batch)), "sent")
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case event := <-r.queue:
+			batch = append(batch, event)
+			if len(batch) >= maxBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-r.done:
+			for {
+				select {
+				case event := <-r.queue:
+					batch = append(batch, event)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// sampled reports whether an event should be kept at the given rate
+func sampled(rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return float64(binary.BigEndian.Uint64(b[:])>>11)/(1<<53) < rate
+}
@@ -0,0 +1,119 @@
+package errreport
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSender struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recordingSender) Send(events []Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, events...)
+	return nil
+}
+
+func TestReporterSampling(t *testing.T) {
+	sender := &recordingSender{}
+	reporter := NewReporter(sender, 0)
+
+	reporter.Report(Event{Message: "backend failed", Status: 502})
+	reporter.Report(Event{Message: "nil pointer", Panic: true, Status: 500})
+	reporter.Shutdown()
+
+	require.Len(t, sender.events, 1)
+	assert.True(t, sender.events[0].Panic)
+	assert.False(t, sender.events[0].Time.IsZero())
+}
+
+func TestReporterDeliversAllAtFullRate(t *testing.T) {
+	sender := &recordingSender{}
+	reporter := NewReporter(sender, 1)
+	for i := 0; i < 5; i++ {
+		reporter.Report(Event{Message: "error", Status: 500})
+	}
+	reporter.Shutdown()
+	assert.Len(t, sender.events, 5)
+}
+
+func TestNilReporter(t *testing.T) {
+	var reporter *Reporter
+	reporter.Report(Event{Message: "ignored"})
+	reporter.Shutdown()
+}
+
+func TestNewSentrySender(t *testing.T) {
+	sender, err := NewSentrySender("https://abc123@o1.ingest.sentry.io/42", "prod", "v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "https://o1.ingest.sentry.io/api/42/envelope/", sender.endpoint)
+	assert.Contains(t, sender.auth, "sentry_key=abc123")
+
+	sender, err = NewSentrySender("https://abc123@sentry.example.com/prefix/7", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://sentry.example.com/prefix/api/7/envelope/", sender.endpoint)
+
+	_, err = NewSentrySender("https://sentry.example.com/7", "", "")
+	assert.Error(t, err)
+	_, err = NewSentrySender("https://abc@sentry.example.com/", "", "")
+	assert.Error(t, err)
+}
+
+func TestSentrySenderEnvelope(t *testing.T) {
+	var lines []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/42/envelope/", r.URL.Path)
+		assert.Contains(t, r.Header.Get("X-Sentry-Auth"), "sentry_key=public")
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+	}))
+	defer server.Close()
+
+	dsn := strings.Replace(server.URL, "http://", "http://public@", 1) + "/42"
+	sender, err := NewSentrySender(dsn, "test", "dev")
+	require.NoError(t, err)
+
+	require.NoError(t, sender.Send([]Event{{Message: "boom", Panic: true, Method: "PUT", Path: "/bucket/key", Status: 500, TraceID: "abc"}}))
+	require.Len(t, lines, 3)
+
+	var event map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &event))
+	assert.Equal(t, "fatal", event["level"])
+	assert.Equal(t, "test", event["environment"])
+	assert.Equal(t, "boom", event["message"].(map[string]interface{})["formatted"])
+	assert.Equal(t, "PUT", event["request"].(map[string]interface{})["method"])
+}
+
+func TestOTLPLogsSender(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/logs", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer server.Close()
+
+	sender := NewOTLPLogsSender(server.URL, "proxy", nil)
+	require.NoError(t, sender.Send([]Event{{Message: "backend failed", Status: 502, Method: "GET"}}))
+
+	resourceLogs := payload["resourceLogs"].([]interface{})
+	scopeLogs := resourceLogs[0].(map[string]interface{})["scopeLogs"].([]interface{})
+	records := scopeLogs[0].(map[string]interface{})["logRecords"].([]interface{})
+	require.Len(t, records, 1)
+
+	record := records[0].(map[string]interface{})
+	assert.Equal(t, "ERROR", record["severityText"])
+	assert.Equal(t, "backend failed", record["body"].(map[string]interface{})["stringValue"])
+}
@@ -0,0 +1,135 @@
+package errreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLP severity numbers, see opentelemetry-proto logs/v1/logs.proto
+const (
+	severityError = 17
+	severityFatal = 21
+)
+
+// OTLPLogsSender posts events as log records using OTLP/HTTP with JSON encoding
+type OTLPLogsSender struct {
+	url         string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+}
+
+// NewOTLPLogsSender creates a sender for endpoint (e.g. http://collector:4318).
+// The /v1/logs path is appended unless the endpoint already names it.
+func NewOTLPLogsSender(endpoint, serviceName string, headers map[string]string) *OTLPLogsSender {
+	url := strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/logs") {
+		url += "/v1/logs"
+	}
+	return &OTLPLogsSender{
+		url:         url,
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send posts a batch of events in one export request
+func (s *OTLPLogsSender) Send(events []Event) error {
+	payload, err := json.Marshal(s.encode(events))
+	if err != nil {
+		return fmt.Errorf("failed to encode log records: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create export request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send log records: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otlpValue      `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes"`
+	TraceID        string         `json:"traceId,omitempty"`
+	SpanID         string         `json:"spanId,omitempty"`
+}
+
+func (s *OTLPLogsSender) encode(events []Event) map[string]interface{} {
+	records := make([]otlpLogRecord, 0, len(events))
+	for _, event := range events {
+		record := otlpLogRecord{
+			TimeUnixNano:   strconv.FormatInt(event.Time.UnixNano(), 10),
+			SeverityNumber: severityError,
+			SeverityText:   "ERROR",
+			Body:           otlpValue{StringValue: event.Message},
+			TraceID:        event.TraceID,
+			SpanID:         event.SpanID,
+		}
+		if event.Panic {
+			record.SeverityNumber = severityFatal
+			record.SeverityText = "FATAL"
+		}
+
+		attributes := map[string]string{
+			"http.request.method":       event.Method,
+			"url.path":                  event.Path,
+			"http.response.status_code": strconv.Itoa(event.Status),
+			"client.address":            event.RemoteIP,
+			"user_agent.original":       event.UserAgent,
+			"request.id":                event.RequestID,
+			"exception.stacktrace":      event.Stack,
+		}
+		for k, v := range event.Tags {
+			attributes[k] = v
+		}
+		for k, v := range attributes {
+			if v != "" {
+				record.Attributes = append(record.Attributes, otlpKeyValue{Key: k, Value: otlpValue{StringValue: v}})
+			}
+		}
+		records = append(records, record)
+	}
+
+	return map[string]interface{}{
+		"resourceLogs": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []otlpKeyValue{{Key: "service.name", Value: otlpValue{StringValue: s.serviceName}}},
+			},
+			"scopeLogs": []interface{}{map[string]interface{}{
+				"scope":      map[string]string{"name": "s3-vault-proxy"},
+				"logRecords": records,
+			}},
+		}},
+	}
+}
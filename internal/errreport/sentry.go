@@ -0,0 +1,134 @@
+package errreport
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SentrySender posts events to Sentry's envelope endpoint
+type SentrySender struct {
+	endpoint    string
+	auth        string
+	environment string
+	release     string
+	client      *http.Client
+}
+
+// NewSentrySender parses a DSN of the form https://<key>@<host>/<project>
+func NewSentrySender(dsn, environment, release string) (*SentrySender, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN: %w", err)
+	}
+	if parsed.User == nil || parsed.User.Username() == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: missing public key")
+	}
+
+	path := strings.Trim(parsed.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	projectID := path[slash+1:]
+	if projectID == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: missing project ID")
+	}
+	prefix := ""
+	if slash >= 0 {
+		prefix = "/" + path[:slash]
+	}
+
+	return &SentrySender{
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/envelope/", parsed.Scheme, parsed.Host, prefix, projectID),
+		auth:        fmt.Sprintf("Sentry sentry_version=7, sentry_client=s3-vault-proxy, sentry_key=%s", parsed.User.Username()),
+		environment: environment,
+		release:     release,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Send posts each event as its own envelope
+func (s *SentrySender) Send(events []Event) error {
+	for _, event := range events {
+		body, err := s.envelope(event)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create Sentry request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-sentry-envelope")
+		req.Header.Set("X-Sentry-Auth", s.auth)
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send event to Sentry: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("sentry returned status %d", resp.StatusCode)
+		}
+	}
+	return nil
+}
+
+func (s *SentrySender) envelope(event Event) ([]byte, error) {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	eventID := hex.EncodeToString(id)
+
+	level := "error"
+	if event.Panic {
+		level = "fatal"
+	}
+
+	tags := map[string]string{"http.status_code": fmt.Sprint(event.Status)}
+	for k, v := range event.Tags {
+		tags[k] = v
+	}
+
+	payload := map[string]interface{}{
+		"event_id":    eventID,
+		"timestamp":   event.Time.UTC().Format(time.RFC3339Nano),
+		"platform":    "go",
+		"level":       level,
+		"logger":      "s3-vault-proxy",
+		"environment": s.environment,
+		"release":     s.release,
+		"message":     map[string]string{"formatted": event.Message},
+		"tags":        tags,
+		"request": map[string]interface{}{
+			"method":  event.Method,
+			"url":     event.Path,
+			"headers": map[string]string{"User-Agent": event.UserAgent},
+			"env":     map[string]string{"REMOTE_ADDR": event.RemoteIP},
+		},
+		"extra": map[string]string{"request_id": event.RequestID},
+	}
+	if event.Stack != "" {
+		payload["extra"] = map[string]string{"request_id": event.RequestID, "stack": event.Stack}
+	}
+	if event.TraceID != "" {
+		payload["contexts"] = map[string]interface{}{
+			"trace": map[string]string{"trace_id": event.TraceID, "span_id": event.SpanID},
+		}
+	}
+
+	eventJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Sentry event: %w", err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"event_id":%q,"sent_at":%q}`+"\n", eventID, time.Now().UTC().Format(time.RFC3339Nano))
+	fmt.Fprintf(&buf, `{"type":"event","length":%d}`+"\n", len(eventJSON))
+	buf.Write(eventJSON)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,136 @@
+// Package etag defines the ETags the proxy reports for objects, which sync
+// tools compare across PUT responses, HEAD, GET and listings: the quoted hex
+// MD5 of the plaintext for single-part uploads, and the MD5 of the
+// concatenated part digests followed by "-<parts>" for multipart uploads, as
+// S3 reports for unencrypted objects. Backends encrypting with SSE-KMS may
+// report ETags of their own, so the proxy records these in object metadata.
+package etag
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Hasher is a reader computing the ETag of the data read through it
+type Hasher interface {
+	io.Reader
+	ETag() string
+}
+
+// Reader computes the ETag of the data read through it
+type Reader struct {
+	r    io.Reader
+	hash hash.Hash
+}
+
+// NewReader returns a Reader hashing everything read from r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r, hash: md5.New()}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.hash.Write(p[:n])
+	return n, err
+}
+
+// Sum returns the MD5 digest of the data read so far
+func (r *Reader) Sum() []byte {
+	return r.hash.Sum(nil)
+}
+
+// ETag returns the single-part ETag of the data read so far
+func (r *Reader) ETag() string {
+	return FromMD5(r.Sum())
+}
+
+// FromMD5 returns the single-part ETag of data with the given MD5 digest
+func FromMD5(sum []byte) string {
+	return `"` + hex.EncodeToString(sum) + `"`
+}
+
+// Compute returns the single-part ETag of everything in r
+func Compute(r io.Reader) (string, error) {
+	reader := NewReader(r)
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return "", err
+	}
+	return reader.ETag(), nil
+}
+
+// Multipart returns the ETag of a multipart upload from the MD5 digests of
+// its parts, in part order
+func Multipart(partSums [][]byte) string {
+	hash := md5.New()
+	for _, sum := range partSums {
+		hash.Write(sum)
+	}
+	return fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(hash.Sum(nil)), len(partSums))
+}
+
+// PartReader computes the multipart ETag of the data read through it, as if
+// it had been uploaded in parts of a fixed size
+type PartReader struct {
+	r        io.Reader
+	partSize int64
+	part     hash.Hash
+	inPart   int64
+	sums     [][]byte
+}
+
+// NewPartReader returns a PartReader hashing r in parts of partSize bytes
+func NewPartReader(r io.Reader, partSize int64) *PartReader {
+	return &PartReader{r: r, partSize: partSize, part: md5.New()}
+}
+
+func (r *PartReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	for data := p[:n]; len(data) > 0; {
+		chunk := data
+		if rest := r.partSize - r.inPart; int64(len(chunk)) > rest {
+			chunk = chunk[:rest]
+		}
+		r.part.Write(chunk)
+		r.inPart += int64(len(chunk))
+		data = data[len(chunk):]
+		if r.inPart == r.partSize {
+			r.sums = append(r.sums, r.part.Sum(nil))
+			r.part.Reset()
+			r.inPart = 0
+		}
+	}
+	return n, err
+}
+
+// ETag returns the multipart ETag of the data read so far
+func (r *PartReader) ETag() string {
+	sums := r.sums
+	if r.inPart > 0 || len(sums) == 0 {
+		sums = append(sums[:len(sums):len(sums)], r.part.Sum(nil))
+	}
+	return Multipart(sums)
+}
+
+// Parts returns the number of parts of a multipart ETag, or 0 for any other ETag
+func Parts(etag string) int {
+	etag = Normalize(etag)
+	i := strings.LastIndexByte(etag, '-')
+	if i < 0 {
+		return 0
+	}
+	parts, err := strconv.Atoi(etag[i+1:])
+	if err != nil || parts < 1 {
+		return 0
+	}
+	return parts
+}
+
+// Normalize strips the quotes and weak prefix of an ETag for comparison
+func Normalize(etag string) string {
+	return strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+}
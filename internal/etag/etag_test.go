@@ -0,0 +1,66 @@
+package etag
+
+import (
+	"crypto/md5"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader(t *testing.T) {
+	reader := NewReader(strings.NewReader("hello world"))
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello world", string(data))
+	assert.Equal(t, `"5eb63bbbe01eeed093cb22bb8f5acdc3"`, reader.ETag())
+}
+
+func TestCompute(t *testing.T) {
+	etag, err := Compute(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Equal(t, `"d41d8cd98f00b204e9800998ecf8427e"`, etag)
+}
+
+func TestMultipart(t *testing.T) {
+	first := md5.Sum([]byte("part one"))
+	second := md5.Sum([]byte("part two"))
+
+	etag := Multipart([][]byte{first[:], second[:]})
+
+	combined := md5.Sum(append(first[:], second[:]...))
+	assert.Equal(t, FromMD5(combined[:])[:33]+`-2"`, etag)
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "abc", Normalize(`"abc"`))
+	assert.Equal(t, "abc", Normalize(`W/"abc"`))
+	assert.Equal(t, "abc-2", Normalize("abc-2"))
+}
+
+func TestPartReader(t *testing.T) {
+	reader := NewPartReader(strings.NewReader("part onepart twoend"), 8)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "part onepart twoend", string(data))
+
+	first := md5.Sum([]byte("part one"))
+	second := md5.Sum([]byte("part two"))
+	last := md5.Sum([]byte("end"))
+	assert.Equal(t, Multipart([][]byte{first[:], second[:], last[:]}), reader.ETag())
+
+	exact := NewPartReader(strings.NewReader("part onepart two"), 8)
+	_, err = io.Copy(io.Discard, exact)
+	require.NoError(t, err)
+	assert.Equal(t, Multipart([][]byte{first[:], second[:]}), exact.ETag(), "a full last part adds no empty part")
+}
+
+func TestParts(t *testing.T) {
+	assert.Equal(t, 3, Parts(`"9b2cf535f27731c974343645a3985328-3"`))
+	assert.Equal(t, 0, Parts(`"9b2cf535f27731c974343645a3985328"`))
+	assert.Equal(t, 0, Parts(`"abc-x"`))
+	assert.Equal(t, 0, Parts(""))
+}
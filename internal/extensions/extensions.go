@@ -0,0 +1,70 @@
+// Package extensions runs operator-supplied WebAssembly modules on every S3
+// request, so teams sharing a proxy can add their own policies without
+// forking it. A module sees the request's method, path, query and headers
+// and may deny the request or set headers it forwards. Modules run in a
+// sandbox with no filesystem or network access, and a fresh instance serves
+// every request.
+//
+// A module exports its linear memory as "memory", "alloc(size i32) i32"
+// returning a buffer the request is written to, and "inspect(ptr i32, len
+// i32) i64" reading the JSON Request from that buffer. inspect returns 0 to
+// let the request through unchanged, or the address of a JSON Decision in
+// its high 32 bits and its length in the low 32 bits.
+//
+// Only the interface is implemented so far. The runtime, wazero, is deferred
+// until it is added to go.mod, and until then Load always fails.
+package extensions
+
+import (
+	"context"
+	"net/http"
+)
+
+// Request is what an extension is given of an S3 request
+type Request struct {
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Query   string              `json:"query,omitempty"`
+	Headers map[string][]string `json:"headers"`
+}
+
+// Decision is an extension's answer to a request
+type Decision struct {
+	// Deny refuses the request with Status (default 403), Code (default
+	// AccessDenied) and Message
+	Deny    bool   `json:"deny,omitempty"`
+	Status  int    `json:"status,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	// SetHeaders adds or replaces request headers before the request is
+	// handled. Headers covered by the request's signature are left alone.
+	SetHeaders map[string]string `json:"set_headers,omitempty"`
+
+	// Module names the extension that denied the request
+	Module string `json:"-"`
+}
+
+// StatusCode returns the status a denied request is answered with
+func (d *Decision) StatusCode() int {
+	if d.Status < 400 || d.Status > 599 {
+		return http.StatusForbidden
+	}
+	return d.Status
+}
+
+// ErrorCode returns the S3 error code a denied request is answered with
+func (d *Decision) ErrorCode() string {
+	if d.Code == "" {
+		return "AccessDenied"
+	}
+	return d.Code
+}
+
+// Inspector runs the loaded extensions on requests
+type Inspector interface {
+	// Inspect runs every extension in order. The first denial is returned;
+	// otherwise the headers set by all of them, later ones winning.
+	Inspect(ctx context.Context, req Request) (*Decision, error)
+	// Close releases the runtime and compiled modules
+	Close(ctx context.Context) error
+}
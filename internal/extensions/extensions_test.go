@@ -0,0 +1,34 @@
+package extensions
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecision(t *testing.T) {
+	var decision Decision
+	require.NoError(t, json.Unmarshal([]byte(`{"deny":true,"message":"Uploads to this bucket are frozen"}`), &decision))
+	assert.True(t, decision.Deny)
+	assert.Equal(t, http.StatusForbidden, decision.StatusCode())
+	assert.Equal(t, "AccessDenied", decision.ErrorCode())
+
+	decision = Decision{}
+	require.NoError(t, json.Unmarshal([]byte(`{"deny":true,"status":503,"code":"SlowDown"}`), &decision))
+	assert.Equal(t, http.StatusServiceUnavailable, decision.StatusCode())
+	assert.Equal(t, "SlowDown", decision.ErrorCode())
+
+	decision.Status = http.StatusOK
+	assert.Equal(t, http.StatusForbidden, decision.StatusCode(), "denials are always errors")
+}
+
+func TestLoadUnsupported(t *testing.T) {
+	_, err := Load(context.Background(), []string{"policy.wasm"}, time.Second)
+	assert.Error(t, err)
+	assert.False(t, Supported)
+}
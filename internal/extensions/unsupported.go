@@ -0,0 +1,17 @@
+package extensions
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Supported reports whether this build can run extensions. No build can yet:
+// the WebAssembly runtime is deferred until github.com/tetratelabs/wazero is
+// added to the module.
+const Supported = false
+
+// Load fails: there is no WebAssembly runtime
+func Load(ctx context.Context, paths []string, timeout time.Duration) (Inspector, error) {
+	return nil, errors.New("extensions are not available: the WebAssembly runtime is not part of this build")
+}
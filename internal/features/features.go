@@ -0,0 +1,161 @@
+package features
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Stage describes how much a flagged behavior has been exercised in production
+type Stage string
+
+const (
+	Alpha  Stage = "alpha"
+	Beta   Stage = "beta"
+	Stable Stage = "stable"
+)
+
+// Flag is a behavior that can be switched on or off with FEATURE_FLAGS
+type Flag struct {
+	Name        string
+	Description string
+	Stage       Stage
+	Default     bool
+}
+
+// Known flags
+const (
+	// ValidateChunkedBody rejects malformed aws-chunked bodies before they reach the backend
+	ValidateChunkedBody = "validate_chunked_body"
+	// InjectTraceparent adds an unsigned traceparent header to backend requests
+	InjectTraceparent = "inject_traceparent"
+	// TranslateBackendErrors rewrites backend error responses into canonical S3 errors
+	TranslateBackendErrors = "translate_backend_errors"
+	// VerifyObjectChecksums checks full GETs against the plaintext MD5 in object metadata
+	VerifyObjectChecksums = "verify_object_checksums"
+	// EnforceEncryptionContext records the encryption context of uploads and requires reads to repeat it
+	EnforceEncryptionContext = "enforce_encryption_context"
+)
+
+// registry lists every flag. Add new risky behaviors here rather than as
+// standalone environment variables.
+var registry = []Flag{
+	{
+		Name:        ValidateChunkedBody,
+		Description: "Validate aws-chunked upload framing and decoded length before forwarding; chunk signatures are left to the backend",
+		Stage:       Stable,
+		Default:     true,
+	},
+	{
+		Name:        InjectTraceparent,
+		Description: "Propagate W3C trace context to the backend when tracing is enabled",
+		Stage:       Stable,
+		Default:     true,
+	},
+	{
+		Name:        TranslateBackendErrors,
+		Description: "Answer backend errors with the S3 error code and status AWS would use",
+		Stage:       Beta,
+		Default:     true,
+	},
+	{
+		Name:        VerifyObjectChecksums,
+		Description: "Refuse to serve objects whose data does not match the MD5 recorded in their metadata",
+		Stage:       Alpha,
+		Default:     false,
+	},
+	{
+		Name:        EnforceEncryptionContext,
+		Description: "Record the encryption context of uploads in object metadata and refuse reads that do not send it",
+		Stage:       Alpha,
+		Default:     false,
+	},
+}
+
+// Set is the resolved state of every flag. A nil Set reports defaults.
+type Set struct {
+	enabled map[string]bool
+}
+
+// State is a flag with its resolved value, as surfaced by /version and /debug/config
+type State struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Stage       Stage  `json:"stage"`
+	Default     bool   `json:"default"`
+	Enabled     bool   `json:"enabled"`
+}
+
+// Parse resolves flags from name=bool overrides, rejecting unknown names
+func Parse(overrides map[string]string) (*Set, error) {
+	set := &Set{enabled: make(map[string]bool, len(registry))}
+	for _, flag := range registry {
+		set.enabled[flag.Name] = flag.Default
+	}
+
+	for name, value := range overrides {
+		if _, known := set.enabled[name]; !known {
+			return nil, fmt.Errorf("unknown feature flag %q (known: %v)", name, Names())
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("feature flag %s: %q is not a boolean", name, value)
+		}
+		set.enabled[name] = enabled
+	}
+	return set, nil
+}
+
+// Enabled reports whether a flag is on
+func (s *Set) Enabled(name string) bool {
+	if s == nil {
+		return lookup(name).Default
+	}
+	return s.enabled[name]
+}
+
+// States returns every flag with its resolved value, sorted by name
+func (s *Set) States() []State {
+	states := make([]State, 0, len(registry))
+	for _, flag := range registry {
+		states = append(states, State{
+			Name:        flag.Name,
+			Description: flag.Description,
+			Stage:       flag.Stage,
+			Default:     flag.Default,
+			Enabled:     s.Enabled(flag.Name),
+		})
+	}
+	sort.Slice(states, func(i, k int) bool { return states[i].Name < states[k].Name })
+	return states
+}
+
+// Overridden returns the flags whose value differs from the default
+func (s *Set) Overridden() map[string]bool {
+	overridden := make(map[string]bool)
+	for _, state := range s.States() {
+		if state.Enabled != state.Default {
+			overridden[state.Name] = state.Enabled
+		}
+	}
+	return overridden
+}
+
+// Names returns the known flag names, sorted
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for _, flag := range registry {
+		names = append(names, flag.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookup(name string) Flag {
+	for _, flag := range registry {
+		if flag.Name == name {
+			return flag
+		}
+	}
+	return Flag{Name: name}
+}
@@ -0,0 +1,41 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDefaults(t *testing.T) {
+	set, err := Parse(nil)
+	require.NoError(t, err)
+	assert.True(t, set.Enabled(ValidateChunkedBody))
+	assert.Empty(t, set.Overridden())
+
+	var unset *Set
+	assert.True(t, unset.Enabled(ValidateChunkedBody), "nil set reports defaults")
+	assert.False(t, unset.Enabled("no_such_flag"))
+}
+
+func TestParseOverrides(t *testing.T) {
+	set, err := Parse(map[string]string{InjectTraceparent: "false"})
+	require.NoError(t, err)
+	assert.False(t, set.Enabled(InjectTraceparent))
+	assert.Equal(t, map[string]bool{InjectTraceparent: false}, set.Overridden())
+
+	states := set.States()
+	require.Len(t, states, len(registry))
+	assert.Equal(t, EnforceEncryptionContext, states[0].Name, "states are sorted by name")
+	assert.Equal(t, InjectTraceparent, states[1].Name)
+	assert.False(t, states[1].Enabled)
+	assert.True(t, states[1].Default)
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	_, err := Parse(map[string]string{"resign": "true"})
+	assert.ErrorContains(t, err, "unknown feature flag")
+
+	_, err = Parse(map[string]string{ValidateChunkedBody: "maybe"})
+	assert.ErrorContains(t, err, "not a boolean")
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/xml"
+
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// unsupportedConfigurations maps the bucket configuration subresources the
+// proxy does not implement to the root element of their list response.
+// Tools that enumerate bucket features read them and fail on the backend's
+// error, so they are answered as having no configurations instead.
+var unsupportedConfigurations = map[string]string{
+	"intelligent-tiering": "ListBucketIntelligentTieringConfigurationsOutput",
+	"analytics":           "ListBucketAnalyticsConfigurationResult",
+	"metrics":             "ListMetricsConfigurationsResult",
+}
+
+// emptyConfigurationList is the list response of a subresource without configurations
+type emptyConfigurationList struct {
+	XMLName     xml.Name
+	IsTruncated bool `xml:"IsTruncated"`
+}
+
+// unsupportedConfiguration returns the root element answering a GET for an
+// unsupported configuration subresource, or "" for other requests
+func unsupportedConfiguration(c *fiber.Ctx) string {
+	args := c.Request().URI().QueryArgs()
+	for subresource, root := range unsupportedConfigurations {
+		if args.Has(subresource) {
+			return root
+		}
+	}
+	return ""
+}
+
+// GetUnsupportedConfiguration handles GET /:bucket?intelligent-tiering,
+// ?analytics and ?metrics. Listings are empty, and a configuration asked
+// for by id does not exist.
+func (h *S3Handler) GetUnsupportedConfiguration(c *fiber.Ctx, root string) error {
+	if denied, err := h.authorizeBucketSubresource(c, nil); denied || err != nil {
+		return err
+	}
+	if c.Request().URI().QueryArgs().Has("id") {
+		return c.Status(404).XML(types.ErrorResponse{
+			Code:    "NoSuchConfiguration",
+			Message: "The specified configuration does not exist.",
+		})
+	}
+	return c.XML(emptyConfigurationList{
+		XMLName: xml.Name{Space: "http://s3.amazonaws.com/doc/2006-03-01/", Local: root},
+	})
+}
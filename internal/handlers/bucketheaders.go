@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"s3-vault-proxy/internal/bucketheaders"
+)
+
+// WithBucketHeaders adds the response headers configured for a bucket to
+// reads of its objects
+func WithBucketHeaders(set *bucketheaders.Set) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.bucketHeaders = set
+	}
+}
+
+// applyBucketHeaders adds the bucket's configured headers to a successful or
+// not modified read, after the object's own headers were reconciled
+func (h *S3Handler) applyBucketHeaders(bucket string, resp *http.Response) {
+	if h.bucketHeaders == nil || (resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified) {
+		return
+	}
+	h.bucketHeaders.Apply(bucket, resp.Header)
+}
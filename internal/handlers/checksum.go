@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"time"
+
+	"s3-vault-proxy/internal/etag"
+	"s3-vault-proxy/internal/features"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/pkg/types"
+)
+
+var checksumVerificationsTotal = metrics.NewCounter(
+	"s3_vault_proxy_checksum_verifications_total",
+	"Full GETs checked against the plaintext MD5 in object metadata, by result (verified, mismatch, stale_metadata).",
+	"result",
+)
+
+// checksumMismatchError answers reads of objects whose data does not match
+// their recorded MD5. It is a server error so SDKs retry, which helps when
+// only one backend replica is corrupted.
+var checksumMismatchError = types.ErrorResponse{
+	Code:    "InternalError",
+	Message: "The object's data does not match its recorded checksum",
+}
+
+// errChecksumMismatch ends the body of a verified object whose data does not
+// match its recorded MD5
+var errChecksumMismatch = errors.New("object data does not match its recorded checksum")
+
+// metadataClockSkew is how much newer than its metadata an object may be
+// before the metadata is taken for an overwrite's leftover. Uploads through
+// the proxy record their metadata after the backend stored them, so only
+// clock differences between the two make the object look newer.
+const metadataClockSkew = 5 * time.Second
+
+// verifyChecksum makes the body of a full GET check itself against the
+// single-part ETag in the object's metadata: it is hashed as it is read, and
+// its final read fails with errChecksumMismatch instead of io.EOF on a
+// mismatch. Ranged and part reads, multipart ETags, and metadata older than
+// the object or of another size, left behind by an overwrite outside the
+// proxy, are not verified. backendLastModified is the backend's Last-Modified.
+func (h *S3Handler) verifyChecksum(resp *http.Response, storedMeta *types.ObjectMetadata, backendLastModified string, part int) {
+	if !h.features.Enabled(features.VerifyObjectChecksums) || resp.StatusCode != http.StatusOK || part != 0 ||
+		storedMeta == nil || storedMeta.ETag == "" || etag.Parts(storedMeta.ETag) != 0 {
+		return
+	}
+	if metadataPredates(storedMeta, backendLastModified) {
+		checksumVerificationsTotal.Inc("stale_metadata")
+		return
+	}
+	resp.Body = &checksumReader{
+		ReadCloser: resp.Body,
+		hash:       md5.New(),
+		size:       storedMeta.ContentLength,
+		etag:       storedMeta.ETag,
+	}
+}
+
+// metadataPredates reports whether the object was written well after its
+// metadata. Unparseable timestamps are not compared.
+func metadataPredates(storedMeta *types.ObjectMetadata, backendLastModified string) bool {
+	recorded, err := types.ParseLastModified(storedMeta.LastModified)
+	if err != nil {
+		return false
+	}
+	written, err := http.ParseTime(backendLastModified)
+	if err != nil {
+		return false
+	}
+	return written.After(recorded.Add(metadataClockSkew))
+}
+
+// checksumReader hashes an object body as it is read and compares the result
+// with the recorded ETag at the end
+type checksumReader struct {
+	io.ReadCloser
+	hash hash.Hash
+	size int64
+	etag string
+	read int64
+	done bool
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	r.read += int64(n)
+	if err != io.EOF || r.done {
+		return n, err
+	}
+	r.done = true
+	if r.read != r.size {
+		checksumVerificationsTotal.Inc("stale_metadata")
+		return n, err
+	}
+	if got := etag.FromMD5(r.hash.Sum(nil)); etag.Normalize(got) != etag.Normalize(r.etag) {
+		checksumVerificationsTotal.Inc("mismatch")
+		return n, fmt.Errorf("%w: MD5 %s, recorded %s", errChecksumMismatch, got, r.etag)
+	}
+	checksumVerificationsTotal.Inc("verified")
+	return n, err
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"s3-vault-proxy/internal/sigv4"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// evaluateNotModified resolves If-None-Match and If-Modified-Since against the
+// plaintext ETag and Last-Modified in stored metadata. The backend would
+// compare them with the encrypted object's own values, which clients never
+// see, and never answer 304. Unsigned headers are taken off the request, and
+// the stored metadata is returned when the object is unchanged so the caller
+// can answer 304 once the backend has authorized the request. Objects without
+// metadata, and validators the metadata can't answer, are left to the backend.
+func (h *S3Handler) evaluateNotModified(c *fiber.Ctx, bucket, key string, headers http.Header) *types.ObjectMetadata {
+	ifNoneMatch := sigv4.HeaderValue(headers, "If-None-Match")
+	ifModifiedSince := sigv4.HeaderValue(headers, "If-Modified-Since")
+	if ifNoneMatch == "" && ifModifiedSince == "" {
+		return nil
+	}
+	storedMeta, err := h.storedMetadata(c.UserContext(), bucket, key)
+	if err != nil {
+		return nil
+	}
+
+	unchanged, known := notModified(ifNoneMatch, ifModifiedSince, storedMeta)
+	if !known {
+		return nil
+	}
+	for _, name := range []string{"If-None-Match", "If-Modified-Since"} {
+		if !isSignedRequestHeader(c, headers, name) {
+			deleteHeader(headers, name)
+		}
+	}
+	if !unchanged {
+		return nil
+	}
+	return storedMeta
+}
+
+// notModified evaluates the conditions of a GET or HEAD with stored metadata.
+// known is false when the metadata lacks the value a condition is compared
+// with. If-Modified-Since is ignored when If-None-Match is present, and
+// If-None-Match uses the weak comparison (RFC 9110 13.1.2, 13.1.3).
+func notModified(ifNoneMatch, ifModifiedSince string, storedMeta *types.ObjectMetadata) (unchanged, known bool) {
+	if ifNoneMatch != "" {
+		if strings.TrimSpace(ifNoneMatch) == "*" {
+			return true, true
+		}
+		if storedMeta.ETag == "" {
+			return false, false
+		}
+		stored := strings.TrimPrefix(storedMeta.ETag, "W/")
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == stored {
+				return true, true
+			}
+		}
+		return false, true
+	}
+
+	lastModified, err := types.ParseLastModified(storedMeta.LastModified)
+	if err != nil {
+		return false, false
+	}
+	date, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false, false
+	}
+	return !lastModified.Truncate(time.Second).After(date), true
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/notify"
+	"s3-vault-proxy/internal/phases"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/trash"
+	"s3-vault-proxy/internal/workpool"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// deleteRequest is the body of a DeleteObjects request
+type deleteRequest struct {
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+// deleteResult is a DeleteObjects response. Quiet requests only list errors,
+// so deleted keys are taken from the request.
+type deleteResult struct {
+	XMLName xml.Name        `xml:"DeleteResult"`
+	Xmlns   string          `xml:"xmlns,attr,omitempty"`
+	Deleted []deletedObject `xml:"Deleted"`
+	Errors  []deleteError   `xml:"Error"`
+}
+
+type deletedObject struct {
+	Key                   string `xml:"Key"`
+	VersionID             string `xml:"VersionId,omitempty"`
+	DeleteMarker          bool   `xml:"DeleteMarker,omitempty"`
+	DeleteMarkerVersionID string `xml:"DeleteMarkerVersionId,omitempty"`
+}
+
+type deleteError struct {
+	Key       string `xml:"Key"`
+	VersionID string `xml:"VersionId,omitempty"`
+	Code      string `xml:"Code"`
+	Message   string `xml:"Message"`
+}
+
+// defaultDeleteConcurrency bounds the keys of a DeleteObjects request worked
+// on at once when WithDeleteConcurrency is not given
+const defaultDeleteConcurrency = 16
+
+// WithDeleteConcurrency bounds how many keys of a DeleteObjects request are
+// moved to the trash or have their metadata deleted at once
+func WithDeleteConcurrency(n int) S3HandlerOption {
+	return func(h *S3Handler) {
+		if n > 0 {
+			h.deleteConcurrency = n
+		}
+	}
+}
+
+// PostBucket handles POST /:bucket. Only ?delete (DeleteObjects) is supported.
+func (h *S3Handler) PostBucket(c *fiber.Ctx) error {
+	if !c.Request().URI().QueryArgs().Has("delete") {
+		return c.Status(501).XML(types.ErrorResponse{
+			Code:    "NotImplemented",
+			Message: "Only the delete operation is supported on POST to a bucket",
+		})
+	}
+	return h.DeleteObjects(c)
+}
+
+// DeleteObjects handles POST /:bucket?delete - delete several objects and their
+// metadata. The objects go to the backend as one batch; the per-key work
+// around it, trash copies and metadata deletes, runs on a bounded pool of
+// workers. Keys whose metadata could not be deleted are reported as errors,
+// so the client retries them.
+func (h *S3Handler) DeleteObjects(c *fiber.Ctx) error {
+	bucket := strings.Clone(c.Params("bucket"))
+	body := append([]byte(nil), c.Body()...)
+	headers := h.extractHeaders(c)
+	ctx := c.UserContext()
+
+	var request deleteRequest
+	if err := xml.Unmarshal(body, &request); err != nil {
+		return c.Status(400).XML(types.ErrorResponse{
+			Code:    "MalformedXML",
+			Message: "The XML you provided was not well-formed or did not validate against our published schema",
+		})
+	}
+	objects := request.Objects
+
+	// Keep copies in the trash; entries of keys that are not deleted are discarded below.
+	// Workers must not use c, since fiber.Ctx is not safe for concurrent use.
+	trashEntries := make([]*trash.Entry, len(objects))
+	trashErrors := workpool.Run(len(objects), h.deleteConcurrency, func(i int) error {
+		if !h.keepsTrash(bucket, objects[i].Key) {
+			return nil
+		}
+		entry, err := h.trash.Move(ctx, bucket, objects[i].Key)
+		trashEntries[i] = entry
+		return err
+	})
+	discardAll := func() {
+		workpool.Run(len(objects), h.deleteConcurrency, func(i int) error {
+			h.discardTrash(ctx, trashEntries[i])
+			return nil
+		})
+	}
+	for i, err := range trashErrors {
+		if err != nil {
+			logging.FromContext(c.UserContext()).Error().Err(err).Str("key", objects[i].Key).Msg("Failed to move object to trash")
+			discardAll()
+			return c.Status(500).XML(types.ErrorResponse{
+				Code:    "InternalError",
+				Message: "Failed to move objects to trash",
+			})
+		}
+	}
+
+	resp, err := h.forward(c, "POST", fmt.Sprintf("/%s", bucket), bytes.NewReader(body), headers, c.Request().URI().QueryString())
+	if err != nil {
+		discardAll()
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to delete objects")
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to delete objects",
+		})
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to read delete response",
+		})
+	}
+	if resp.StatusCode >= 300 {
+		discardAll()
+		return h.forwardRawResponse(c, resp.StatusCode, resp.Header, respBody)
+	}
+
+	var result deleteResult
+	if err := xml.Unmarshal(respBody, &result); err != nil {
+		logging.FromContext(c.UserContext()).Warn().Err(err).Msg("Failed to parse delete response; metadata left in place")
+		return h.forwardRawResponse(c, resp.StatusCode, resp.Header, respBody)
+	}
+	failed := make(map[string]bool, len(result.Errors))
+	for _, e := range result.Errors {
+		failed[e.Key] = true
+	}
+
+	metadataHeaders := bodylessHeaders(headers)
+	start := time.Now()
+	metadataErrors := workpool.Run(len(objects), h.deleteConcurrency, func(i int) error {
+		key := objects[i].Key
+		if failed[key] {
+			h.discardTrash(ctx, trashEntries[i])
+			return nil
+		}
+		h.invalidateObject(bucket, key)
+		return h.deleteMetadata(ctx, bucket, key, metadataHeaders)
+	})
+	phases.FromContext(ctx).Since(phases.Backend, start)
+
+	var metadataFailed map[string]bool
+	for i, object := range objects {
+		if failed[object.Key] {
+			continue
+		}
+		h.publish(c, notify.Event{
+			Name:      notify.ObjectRemovedDelete,
+			Bucket:    bucket,
+			Key:       object.Key,
+			RequestID: resp.Header.Get("X-Amz-Request-Id"),
+		})
+		if err := metadataErrors[i]; err != nil {
+			logging.FromContext(c.UserContext()).Error().Err(err).Str("key", object.Key).Msg("Failed to delete metadata")
+			if metadataFailed == nil {
+				metadataFailed = make(map[string]bool)
+			}
+			if !metadataFailed[object.Key] {
+				metadataFailed[object.Key] = true
+				result.Errors = append(result.Errors, deleteError{
+					Key:     object.Key,
+					Code:    "InternalError",
+					Message: "The object was deleted but its encryption metadata was not. Retry the delete.",
+				})
+			}
+		}
+	}
+	if metadataFailed == nil {
+		return h.forwardRawResponse(c, resp.StatusCode, resp.Header, respBody)
+	}
+
+	deleted := result.Deleted[:0]
+	for _, object := range result.Deleted {
+		if !metadataFailed[object.Key] {
+			deleted = append(deleted, object)
+		}
+	}
+	result.Deleted = deleted
+	rewritten, err := xml.Marshal(result)
+	if err != nil {
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to encode delete response",
+		})
+	}
+	respHeaders := resp.Header.Clone()
+	respHeaders.Del("Content-Length")
+	return h.forwardRawResponse(c, resp.StatusCode, respHeaders, append([]byte(xml.Header), rewritten...))
+}
+
+// deleteMetadata removes the metadata object of key
+func (h *S3Handler) deleteMetadata(ctx context.Context, bucket, key string, headers http.Header) error {
+	resp, err := h.s3Client.ForwardRequest(ctx, "DELETE", s3.ObjectPath(bucket, key+".metadata"), nil, headers, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("backend returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// bodylessHeaders copies request headers without those describing a request
+// body. Names are matched case-insensitively since they are not canonicalized.
+func bodylessHeaders(headers http.Header) http.Header {
+	copied := make(http.Header, len(headers))
+	for name, values := range headers {
+		switch strings.ToLower(name) {
+		case "content-length", "content-md5", "content-type":
+			continue
+		}
+		copied[name] = values
+	}
+	return copied
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package handlers
+
+import "errors"
+
+// freeBytes cannot measure free space on this platform, so the disk
+// readiness check fails rather than passing blindly
+func freeBytes(path string) (uint64, error) {
+	return 0, errors.New("free space is not available on this platform")
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var encryptionContextChecksTotal = metrics.NewCounter(
+	"s3_vault_proxy_encryption_context_checks_total",
+	"Reads of objects checked against the encryption context they were uploaded with, by result (match, mismatch, error).",
+	"result",
+)
+
+var encryptionContextMismatchError = types.ErrorResponse{
+	Code:    "AccessDenied",
+	Message: "The encryption context does not match the context the object was stored with",
+}
+
+// WithEncryptionContexts refuses reads that do not repeat the
+// x-amz-server-side-encryption-context an object was uploaded with, as KMS
+// refuses decryptions under another context. Contexts are read from store,
+// where the metadata sidecars written by WithMetadataSidecars record them.
+func WithEncryptionContexts(store metadata.Interface) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.encryptionContexts = store
+	}
+}
+
+// requestEncryptionContext returns the encryption context header of a request,
+// "" without one
+func requestEncryptionContext(c *fiber.Ctx) string {
+	// Conflicting spellings of the header are rejected by checkSSEHeaders
+	sse, _ := s3.ParseSSEHeaders(&c.Request().Header)
+	return sse.Context
+}
+
+// checkEncryptionContext returns the status and error refusing a read of
+// bucket/key that does not repeat the encryption context the object was
+// uploaded with, or nil. Objects without recorded metadata have no context.
+func (h *S3Handler) checkEncryptionContext(c *fiber.Ctx, bucket, key string) (int, *types.ErrorResponse) {
+	storedMeta, err := h.encryptionContexts.Get(c.UserContext(), bucket, key, http.Header{})
+	if errors.Is(err, metadata.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		encryptionContextChecksTotal.Inc("error")
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to read the encryption context of an object")
+		return fiber.StatusInternalServerError, &types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to check the encryption context",
+		}
+	}
+	if len(storedMeta.EncryptionContext) == 0 {
+		return 0, nil
+	}
+
+	requested, err := s3.ParseEncryptionContext(requestEncryptionContext(c))
+	if err != nil || !reflect.DeepEqual(requested, storedMeta.EncryptionContext) {
+		encryptionContextChecksTotal.Inc("mismatch")
+		logging.FromContext(c.UserContext()).Warn().Msg("Refused read with a missing or different encryption context")
+		return fiber.StatusForbidden, &encryptionContextMismatchError
+	}
+	encryptionContextChecksTotal.Inc("match")
+	return 0, nil
+}
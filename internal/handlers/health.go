@@ -1,24 +1,92 @@
 package handlers
 
 import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
 	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/s3"
 	"s3-vault-proxy/internal/vault"
+	"s3-vault-proxy/internal/workpool"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // HealthHandler handles health check endpoints
 type HealthHandler struct {
-	config *config.Config
-	vault  vault.Interface
+	config   *config.Config
+	vault    vault.Interface
+	backends []namedBackend
+	drainer  Drainer
+
+	readyChecks []config.ReadyCheck
+
+	// configChecksum is taken when the configuration is loaded, so it
+	// describes what the process runs with even if its files change later
+	configChecksum string
+
+	backendTypeOnce sync.Once
+	backendType     string
+}
+
+// dependencyProbeTimeout bounds each dependency check
+const dependencyProbeTimeout = 5 * time.Second
+
+// BackendProber checks that an S3 backend is reachable
+type BackendProber interface {
+	Probe(timeout time.Duration) s3.ProbeResult
+}
+
+// Drainer reports whether the process is shutting down
+type Drainer interface {
+	Draining() bool
+}
+
+// vaultStatusReporter is implemented by Vault clients that report detailed status
+type vaultStatusReporter interface {
+	DependencyStatus() vault.DependencyStatus
+}
+
+type namedBackend struct {
+	name   string
+	prober BackendProber
+}
+
+// HealthHandlerOption configures optional HealthHandler behaviour
+type HealthHandlerOption func(*HealthHandler)
+
+// WithBackendProbe adds an S3 backend to the dependency report
+func WithBackendProbe(name string, prober BackendProber) HealthHandlerOption {
+	return func(h *HealthHandler) {
+		h.backends = append(h.backends, namedBackend{name: name, prober: prober})
+	}
+}
+
+// WithDrainer makes readiness fail as soon as shutdown begins
+func WithDrainer(drainer Drainer) HealthHandlerOption {
+	return func(h *HealthHandler) {
+		h.drainer = drainer
+	}
 }
 
 // NewHealthHandler creates a new health handler
-func NewHealthHandler(cfg *config.Config, vaultClient vault.Interface) *HealthHandler {
-	return &HealthHandler{
-		config: cfg,
-		vault:  vaultClient,
+func NewHealthHandler(cfg *config.Config, vaultClient vault.Interface, opts ...HealthHandlerOption) *HealthHandler {
+	// Checks were validated at startup, so a parse error leaves none
+	readyChecks, _ := cfg.ReadyCheckSpecs()
+	// The files it reads were loaded at startup, so a failure leaves it empty
+	configChecksum, _ := cfg.Checksum()
+	h := &HealthHandler{
+		config:         cfg,
+		vault:          vaultClient,
+		readyChecks:    readyChecks,
+		configChecksum: configChecksum,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // Health returns basic health information
@@ -27,20 +95,194 @@ func (h *HealthHandler) Health(c *fiber.Ctx) error {
 	return c.SendString(`{"status":"healthy","vault":"` + h.vault.Address() + `","version":"` + h.config.Version + `"}`)
 }
 
-// Ready checks if the service is ready to handle requests
+// Ready checks if the service is ready to handle requests. It runs the
+// checks READY_CHECKS selects concurrently, each within its own timeout, and
+// fails while the process drains.
 func (h *HealthHandler) Ready(c *fiber.Ctx) error {
-	if err := h.vault.HealthCheck(); err != nil {
-		return c.Status(503).SendString(`{"status":"not ready","error":"vault unreachable"}`)
+	if h.drainer != nil && h.drainer.Draining() {
+		return c.Status(503).JSON(fiber.Map{"status": "not ready", "error": "shutting down"})
+	}
+
+	errs := workpool.Run(len(h.readyChecks), len(h.readyChecks), func(i int) error {
+		return h.runReadyCheck(h.readyChecks[i])
+	})
+	checks := make(fiber.Map, len(h.readyChecks))
+	var failure error
+	for i, check := range h.readyChecks {
+		checks[check.Name] = "ok"
+		if errs[i] != nil {
+			checks[check.Name] = errs[i].Error()
+			if failure == nil {
+				failure = errs[i]
+			}
+		}
 	}
-	return c.SendString(`{"status":"ready","version":"` + h.config.Version + `"}`)
+	if failure != nil {
+		return c.Status(503).JSON(fiber.Map{"status": "not ready", "error": failure.Error(), "checks": checks})
+	}
+	return c.JSON(fiber.Map{"status": "ready", "version": h.config.Version, "checks": checks})
+}
+
+// runReadyCheck runs a readiness check, failing it once its timeout passes.
+// A check that times out keeps running in the background until it returns.
+func (h *HealthHandler) runReadyCheck(check config.ReadyCheck) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- h.readyCheck(check)
+	}()
+	timer := time.NewTimer(check.Timeout)
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("%s check timed out after %s", check.Name, check.Timeout)
+	}
+}
+
+func (h *HealthHandler) readyCheck(check config.ReadyCheck) error {
+	switch check.Name {
+	case config.ReadyCheckVault:
+		if err := h.vault.HealthCheck(); err != nil {
+			return fmt.Errorf("vault unreachable")
+		}
+	case config.ReadyCheckBackend:
+		for _, backend := range h.backends {
+			result := backend.prober.Probe(check.Timeout)
+			if !result.Reachable || result.Error != "" {
+				return fmt.Errorf("backend %s unreachable", backend.name)
+			}
+		}
+	case config.ReadyCheckDisk:
+		for _, dir := range h.config.LocalStorageDirs() {
+			free, err := freeBytes(dir)
+			if err != nil {
+				return fmt.Errorf("disk space of %s unknown: %v", dir, err)
+			}
+			if free < check.MinFree {
+				return fmt.Errorf("%s has %d bytes free, below %d", dir, free, check.MinFree)
+			}
+		}
+	case config.ReadyCheckToken:
+		reporter, ok := h.vault.(vaultStatusReporter)
+		if !ok {
+			return nil
+		}
+		status := reporter.DependencyStatus()
+		if status.Error != "" {
+			return fmt.Errorf("vault token lookup failed")
+		}
+		// A TTL of zero is a token that never expires
+		if status.TokenTTL > 0 && status.TokenTTL < check.MinTokenTTL {
+			return fmt.Errorf("vault token expires in %s", status.TokenTTL.Round(time.Second))
+		}
+	}
+	return nil
 }
 
 // Version returns version information
 func (h *HealthHandler) Version(c *fiber.Ctx) error {
+	// Flags were validated at startup, so a parse error leaves the defaults
+	featureSet, _ := h.config.Features()
 	return c.JSON(fiber.Map{
-		"version": h.config.Version,
-		"commit":  h.config.Commit,
-		"date":    h.config.Date,
-		"builtBy": h.config.BuiltBy,
+		"version":        h.config.Version,
+		"commit":         h.config.Commit,
+		"date":           h.config.Date,
+		"builtBy":        h.config.BuiltBy,
+		"goVersion":      runtime.Version(),
+		"platform":       runtime.GOOS + "/" + runtime.GOARCH,
+		"features":       featureSet.States(),
+		"subsystems":     h.config.Subsystems(),
+		"crypto":         h.config.CryptoMode(),
+		"configChecksum": h.configChecksum,
+		"backend": fiber.Map{
+			"type":   h.storageBackendType(),
+			"client": h.config.S3Client,
+		},
+	})
+}
+
+// storageBackendType names the S3 implementation behind the default backend,
+// probing it on first use. It is unknown without a backend probe or when the
+// backend does not identify itself.
+func (h *HealthHandler) storageBackendType() string {
+	h.backendTypeOnce.Do(func() {
+		h.backendType = "unknown"
+		if len(h.backends) > 0 {
+			if result := h.backends[0].prober.Probe(dependencyProbeTimeout); result.Type != "" {
+				h.backendType = result.Type
+			}
+		}
 	})
-}
\ No newline at end of file
+	return h.backendType
+}
+
+// Dependencies reports the status of Vault and every S3 backend, returning 503 if any is unhealthy
+func (h *HealthHandler) Dependencies(c *fiber.Ctx) error {
+	var vaultStatus vault.DependencyStatus
+	backendStatus := make([]fiber.Map, len(h.backends))
+
+	// Check all dependencies concurrently so one slow dependency bounds the response time
+	errs := workpool.Run(len(h.backends)+1, len(h.backends)+1, func(i int) error {
+		if i == len(h.backends) {
+			vaultStatus = h.vaultStatus()
+			return nil
+		}
+		result := h.backends[i].prober.Probe(dependencyProbeTimeout)
+		backendStatus[i] = fiber.Map{
+			"name":        h.backends[i].name,
+			"endpoint":    result.Endpoint,
+			"reachable":   result.Reachable,
+			"status_code": result.StatusCode,
+			"auth":        result.Auth,
+			"type":        result.Type,
+			"latency_ms":  result.Latency.Milliseconds(),
+			"error":       result.Error,
+		}
+		if !result.Reachable || result.Error != "" {
+			return fiber.ErrServiceUnavailable
+		}
+		return nil
+	})
+	status, code := "ok", fiber.StatusOK
+	if workpool.FirstError(errs) != nil || !vaultStatus.Healthy() {
+		status, code = "degraded", fiber.StatusServiceUnavailable
+	}
+
+	return c.Status(code).JSON(fiber.Map{
+		"status": status,
+		"vault": fiber.Map{
+			"address":           vaultStatus.Address,
+			"reachable":         vaultStatus.Reachable,
+			"initialized":       vaultStatus.Initialized,
+			"sealed":            vaultStatus.Sealed,
+			"standby":           vaultStatus.Standby,
+			"version":           vaultStatus.Version,
+			"token_source":      vaultStatus.TokenSource,
+			"token_ttl_seconds": int64(vaultStatus.TokenTTL.Seconds()),
+			"token_renewable":   vaultStatus.TokenRenewable,
+			"latency_ms":        vaultStatus.Latency.Milliseconds(),
+			"error":             vaultStatus.Error,
+		},
+		"backends": backendStatus,
+	})
+}
+
+// vaultStatus returns detailed Vault status, falling back to a plain health check
+func (h *HealthHandler) vaultStatus() vault.DependencyStatus {
+	if reporter, ok := h.vault.(vaultStatusReporter); ok {
+		return reporter.DependencyStatus()
+	}
+
+	status := vault.DependencyStatus{Address: h.vault.Address()}
+	start := time.Now()
+	err := h.vault.HealthCheck()
+	status.Latency = time.Since(start)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Reachable = true
+	status.Initialized = true
+	return status
+}
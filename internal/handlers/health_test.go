@@ -4,8 +4,11 @@ import (
 	"io"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/vault"
 	"s3-vault-proxy/tests/mocks"
 
 	"github.com/gofiber/fiber/v2"
@@ -103,8 +106,31 @@ func TestHealthHandler_Ready(t *testing.T) {
 		assert.Contains(t, bodyStr, `"status":"not ready"`)
 		assert.Contains(t, bodyStr, `"error":"vault unreachable"`)
 	})
+
+	t.Run("Draining", func(t *testing.T) {
+		cfg := &config.Config{Version: "1.0.0"}
+		handler := NewHealthHandler(cfg, mocks.NewMockVaultClient(), WithDrainer(drainingState(true)))
+
+		app := fiber.New(fiber.Config{
+			DisableStartupMessage: true,
+		})
+		app.Get("/ready", handler.Ready)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/ready", nil))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, 503, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"error":"shutting down"`)
+	})
 }
 
+type drainingState bool
+
+func (d drainingState) Draining() bool { return bool(d) }
+
 func TestHealthHandler_Version(t *testing.T) {
 	app, handler := setupHealthTest()
 	app.Get("/version", handler.Version)
@@ -125,6 +151,24 @@ func TestHealthHandler_Version(t *testing.T) {
 	assert.Contains(t, bodyStr, `"commit":"abc123"`)
 	assert.Contains(t, bodyStr, `"date":"2023-01-01"`)
 	assert.Contains(t, bodyStr, `"builtBy":"test"`)
+	assert.Contains(t, bodyStr, `"name":"validate_chunked_body"`)
+	assert.Contains(t, bodyStr, `"mode":"sse-kms"`)
+	assert.Regexp(t, `"configChecksum":"[0-9a-f]{64}"`, bodyStr)
+	assert.Contains(t, bodyStr, `"type":"unknown"`, "no backend is probed")
+}
+
+func TestHealthHandler_VersionBackendType(t *testing.T) {
+	cfg := &config.Config{Version: "1.0.0"}
+	prober := &fakeProber{result: s3.ProbeResult{Reachable: true, Type: "garage"}}
+	handler := NewHealthHandler(cfg, mocks.NewMockVaultClient(), WithBackendProbe("default", prober))
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/version", handler.Version)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/version", nil))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"backend":{"client":"","type":"garage"}`)
 }
 
 func TestNewHealthHandler(t *testing.T) {
@@ -136,4 +180,159 @@ func TestNewHealthHandler(t *testing.T) {
 	assert.NotNil(t, handler)
 	assert.Equal(t, cfg, handler.config)
 	assert.Equal(t, vaultClient, handler.vault)
-}
\ No newline at end of file
+}
+type fakeProber struct {
+	result s3.ProbeResult
+}
+
+func (f *fakeProber) Probe(timeout time.Duration) s3.ProbeResult {
+	return f.result
+}
+
+func TestHealthHandler_Dependencies(t *testing.T) {
+	t.Run("All dependencies healthy", func(t *testing.T) {
+		cfg := &config.Config{Version: "1.0.0"}
+		prober := &fakeProber{result: s3.ProbeResult{Endpoint: "http://minio:9000", Reachable: true, StatusCode: 403, Auth: "enforced"}}
+		handler := NewHealthHandler(cfg, mocks.NewMockVaultClient(), WithBackendProbe("default", prober))
+
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		app.Get("/health/dependencies", handler.Dependencies)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/health/dependencies", nil))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		bodyStr := string(body)
+		assert.Contains(t, bodyStr, `"status":"ok"`)
+		assert.Contains(t, bodyStr, `"auth":"enforced"`)
+		assert.Contains(t, bodyStr, `"endpoint":"http://minio:9000"`)
+	})
+
+	t.Run("Unreachable backend", func(t *testing.T) {
+		cfg := &config.Config{Version: "1.0.0"}
+		prober := &fakeProber{result: s3.ProbeResult{Endpoint: "http://minio:9000", Error: "connection refused"}}
+		handler := NewHealthHandler(cfg, mocks.NewMockVaultClient(), WithBackendProbe("default", prober))
+
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		app.Get("/health/dependencies", handler.Dependencies)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/health/dependencies", nil))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, 503, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"status":"degraded"`)
+		assert.Contains(t, string(body), `"error":"connection refused"`)
+	})
+
+	t.Run("Vault unreachable", func(t *testing.T) {
+		cfg := &config.Config{Version: "1.0.0"}
+		vaultClient := mocks.NewMockVaultClient()
+		vaultClient.ExpectedCalls = nil
+		vaultClient.On("Address").Return("http://localhost:8200")
+		vaultClient.On("HealthCheck").Return(assert.AnError)
+		handler := NewHealthHandler(cfg, vaultClient)
+
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		app.Get("/health/dependencies", handler.Dependencies)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/health/dependencies", nil))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, 503, resp.StatusCode)
+	})
+}
+
+// tokenVault reports a Vault token with a fixed TTL
+type tokenVault struct {
+	*mocks.VaultClient
+	ttl time.Duration
+}
+
+func (v tokenVault) DependencyStatus() vault.DependencyStatus {
+	return vault.DependencyStatus{Reachable: true, TokenTTL: v.ttl}
+}
+
+// slowProber answers probes after a delay
+type slowProber time.Duration
+
+func (p slowProber) Probe(time.Duration) s3.ProbeResult {
+	time.Sleep(time.Duration(p))
+	return s3.ProbeResult{Reachable: true}
+}
+
+func TestHealthHandler_ReadyChecks(t *testing.T) {
+	ready := func(t *testing.T, handler *HealthHandler) (int, string) {
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		app.Get("/ready", handler.Ready)
+		resp, err := app.Test(httptest.NewRequest("GET", "/ready", nil))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	t.Run("Failing Vault is ignored without the vault check", func(t *testing.T) {
+		vaultClient := mocks.NewMockVaultClient()
+		vaultClient.ExpectedCalls = nil
+		vaultClient.On("HealthCheck").Return(assert.AnError)
+		cfg := &config.Config{ReadyChecks: "backend"}
+		handler := NewHealthHandler(cfg, vaultClient, WithBackendProbe("default", &fakeProber{result: s3.ProbeResult{Reachable: true}}))
+
+		status, body := ready(t, handler)
+		assert.Equal(t, 200, status)
+		assert.Contains(t, body, `"checks":{"backend":"ok"}`)
+		vaultClient.AssertNotCalled(t, "HealthCheck")
+	})
+
+	t.Run("Unreachable backend", func(t *testing.T) {
+		cfg := &config.Config{ReadyChecks: "vault,backend"}
+		handler := NewHealthHandler(cfg, mocks.NewMockVaultClient(), WithBackendProbe("default", &fakeProber{result: s3.ProbeResult{Error: "connection refused"}}))
+
+		status, body := ready(t, handler)
+		assert.Equal(t, 503, status)
+		assert.Contains(t, body, `"error":"backend default unreachable"`)
+		assert.Contains(t, body, `"vault":"ok"`)
+	})
+
+	t.Run("Check timeout", func(t *testing.T) {
+		cfg := &config.Config{ReadyChecks: "backend;timeout=10ms"}
+		handler := NewHealthHandler(cfg, mocks.NewMockVaultClient(), WithBackendProbe("default", slowProber(time.Second)))
+
+		start := time.Now()
+		status, body := ready(t, handler)
+		assert.Equal(t, 503, status)
+		assert.Contains(t, body, "backend check timed out after 10ms")
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("Disk space", func(t *testing.T) {
+		cfg := &config.Config{ReadyChecks: "disk;min_free=1", SpoolEnabled: true, SpoolDir: t.TempDir()}
+		status, _ := ready(t, NewHealthHandler(cfg, mocks.NewMockVaultClient()))
+		assert.Equal(t, 200, status)
+
+		cfg.ReadyChecks = "disk;min_free=18446744073709551615"
+		status, body := ready(t, NewHealthHandler(cfg, mocks.NewMockVaultClient()))
+		assert.Equal(t, 503, status)
+		assert.Contains(t, body, "bytes free")
+	})
+
+	t.Run("Token TTL", func(t *testing.T) {
+		cfg := &config.Config{ReadyChecks: "token;min_ttl=10m"}
+		status, _ := ready(t, NewHealthHandler(cfg, tokenVault{VaultClient: mocks.NewMockVaultClient(), ttl: time.Hour}))
+		assert.Equal(t, 200, status)
+
+		status, body := ready(t, NewHealthHandler(cfg, tokenVault{VaultClient: mocks.NewMockVaultClient(), ttl: time.Minute}))
+		assert.Equal(t, 503, status)
+		assert.Contains(t, body, "vault token expires in 1m0s")
+
+		status, _ = ready(t, NewHealthHandler(cfg, tokenVault{VaultClient: mocks.NewMockVaultClient()}))
+		assert.Equal(t, 200, status, "tokens without a TTL never expire")
+	})
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"s3-vault-proxy/internal/kmsbindings"
+	"s3-vault-proxy/internal/logging"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WithKMSBindings rejects uploads and copies into a bucket with a KMS key
+// outside the bucket's binding
+func WithKMSBindings(store *kmsbindings.Store) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.kmsBindings = store
+	}
+}
+
+// checkKMSBinding checks that an upload or copy to bucket may use kmsKeyARN,
+// returning the status to reject the request with otherwise
+func (h *S3Handler) checkKMSBinding(c *fiber.Ctx, bucket, kmsKeyARN string) (int, error) {
+	if h.kmsBindings == nil {
+		return 0, nil
+	}
+	err := h.kmsBindings.Check(c.UserContext(), bucket, kmsKeyARN)
+	switch {
+	case err == nil:
+		return 0, nil
+	case errors.Is(err, kmsbindings.ErrKeyNotBound):
+		logging.FromContext(c.UserContext()).Warn().Str("kms_arn", kmsKeyARN).Msg("KMS key denied by bucket binding")
+		return fiber.StatusForbidden, fmt.Errorf("KMS key %s is not bound to bucket %s", kmsKeyARN, bucket)
+	default:
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to load KMS binding")
+		return fiber.StatusServiceUnavailable, fmt.Errorf("unable to check the bucket's KMS binding")
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var legacyObjectsTotal = metrics.NewCounter(
+	"s3_vault_proxy_legacy_objects_total",
+	"Reads of objects stored without SSE-KMS, by what was done (served, denied, migrated, migration_failed).",
+	"action",
+)
+
+// legacyDeniedError answers reads of unencrypted objects when they are denied
+var legacyDeniedError = types.ErrorResponse{
+	Code:    "AccessDenied",
+	Message: "The object is not encrypted with SSE-KMS",
+}
+
+// WithLegacyObjectsDenied refuses reads of objects stored without SSE-KMS,
+// such as those written to the bucket before the proxy was put in front of it
+func WithLegacyObjectsDenied() S3HandlerOption {
+	return func(h *S3Handler) {
+		h.denyLegacyObjects = true
+	}
+}
+
+// WithLegacyObjectsEncrypted serves objects stored without SSE-KMS and then
+// rewrites them encrypted under kmsKeyARN, with a server-side copy signed by
+// client. At most concurrency objects are rewritten at once; reads beyond
+// that are served without a rewrite and the next read tries again.
+func WithLegacyObjectsEncrypted(client s3.Interface, kmsKeyARN string, concurrency int) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.legacyMigrator = &legacyMigrator{
+			client:    client,
+			kmsKeyARN: kmsKeyARN,
+			slots:     make(chan struct{}, concurrency),
+			pending:   make(map[string]bool),
+		}
+	}
+}
+
+// isLegacyObject reports whether a successful GET or HEAD response is of an
+// object the backend does not hold under SSE-KMS
+func isLegacyObject(resp *http.Response) bool {
+	return resp.StatusCode < 300 && !strings.HasPrefix(resp.Header.Get("X-Amz-Server-Side-Encryption"), "aws:kms")
+}
+
+// handleLegacyObject applies the legacy object policy to a read of an
+// unencrypted object, reporting whether the read is denied. A full GET of
+// the current version starts a rewrite when objects are encrypted on read.
+func (h *S3Handler) handleLegacyObject(c *fiber.Ctx, bucket, key string, resp *http.Response) bool {
+	if !isLegacyObject(resp) {
+		return false
+	}
+	switch {
+	case h.denyLegacyObjects:
+		legacyObjectsTotal.Inc("denied")
+		logging.FromContext(c.UserContext()).Warn().Msg("Denied read of an object stored without SSE-KMS")
+		return true
+	case h.legacyMigrator != nil && c.Method() == fiber.MethodGet && resp.StatusCode == http.StatusOK && !hasSubresource(c.Request().URI().QueryString()):
+		h.legacyMigrator.start(bucket, key, resp.Header.Get("ETag"), h.invalidateObject)
+	}
+	legacyObjectsTotal.Inc("served")
+	return false
+}
+
+// legacyMigrator rewrites unencrypted objects under a KMS key in the background
+type legacyMigrator struct {
+	client    s3.Interface
+	kmsKeyARN string
+	slots     chan struct{}
+
+	mu      sync.Mutex
+	pending map[string]bool // object paths being rewritten
+}
+
+// start rewrites an object unless it is already being rewritten or every
+// slot is busy. done is called once the object was rewritten.
+func (m *legacyMigrator) start(bucket, key, etag string, done func(bucket, key string)) {
+	path := s3.ObjectPath(bucket, key)
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		return
+	}
+	m.mu.Lock()
+	if m.pending[path] {
+		m.mu.Unlock()
+		<-m.slots
+		return
+	}
+	m.pending[path] = true
+	bucket, key = strings.Clone(bucket), strings.Clone(key)
+	m.mu.Unlock()
+
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			delete(m.pending, path)
+			m.mu.Unlock()
+			<-m.slots
+		}()
+		if err := m.encrypt(context.Background(), path, etag); err != nil {
+			legacyObjectsTotal.Inc("migration_failed")
+			logging.Warn().Err(err).Str("bucket", bucket).Str("key", key).Msg("Failed to encrypt object stored without SSE-KMS")
+			return
+		}
+		legacyObjectsTotal.Inc("migrated")
+		logging.Info().Str("bucket", bucket).Str("key", key).Str("kms_arn", m.kmsKeyARN).Msg("Encrypted object stored without SSE-KMS")
+		done(bucket, key)
+	}()
+}
+
+// encrypt copies an object onto itself under SSE-KMS, keeping its metadata.
+// The copy only happens while the object still has etag, so a concurrent
+// overwrite is never replaced by the older data.
+func (m *legacyMigrator) encrypt(ctx context.Context, path, etag string) error {
+	headers := http.Header{
+		"X-Amz-Copy-Source":                           {path},
+		"X-Amz-Metadata-Directive":                    {"COPY"},
+		"X-Amz-Server-Side-Encryption":                {"aws:kms"},
+		"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": {m.kmsKeyARN},
+	}
+	if etag != "" {
+		headers.Set("X-Amz-Copy-Source-If-Match", etag)
+	}
+	resp, err := m.client.ForwardRequest(ctx, http.MethodPut, path, nil, headers, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	// CopyObject can fail after answering 200, with an error in the body
+	if resp.StatusCode >= 300 || bytes.Contains(body, []byte("<Error>")) {
+		return fmt.Errorf("copy failed with HTTP %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/pkg/types"
+)
+
+const (
+	// listBreakerThreshold is how many listing pages in a row may run out of
+	// budget or fail their lookups before listings skip metadata
+	listBreakerThreshold = 3
+	// listBreakerCooldown is how long listings skip metadata once it tripped
+	listBreakerCooldown = 30 * time.Second
+
+	// degradedHeader tells clients a listing carries the backend's sizes,
+	// ETags and dates instead of those in object metadata
+	degradedHeader = "X-S3-Vault-Proxy-Metadata"
+)
+
+var degradedListingsTotal = metrics.NewCounter(
+	"s3_vault_proxy_degraded_listings_total",
+	"Listing pages answered with backend values for some entries, by reason (breaker_open, budget_exhausted, lookup_failed, too_many_keys).",
+	"reason",
+)
+
+// WithListMetadataBudget bounds the time one listing page spends looking up
+// object metadata. Entries left once it is spent keep the backend's values,
+// and repeated overruns stop metadata lookups of listings for a while. Pages
+// are enriched before they are sent, so each degraded page is marked with
+// degradedHeader.
+func WithListMetadataBudget(budget time.Duration) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.listMetadataBudget = budget
+		h.listBreaker = metadata.NewBreaker("list", listBreakerThreshold, listBreakerCooldown)
+	}
+}
+
+// listEnrichment looks up the metadata of one listing page's entries
+type listEnrichment struct {
+	h       *S3Handler
+	ctx     context.Context
+	cancel  context.CancelFunc
+	skip    bool // entries keep the backend's values
+	failed  bool
+	limited bool // the page runs under a budget and reports to the breaker
+	lookups int
+}
+
+// newListEnrichment starts the metadata lookups of a listing page, reporting
+// whether they are skipped from the start because the breaker is open
+func (h *S3Handler) newListEnrichment(ctx context.Context) *listEnrichment {
+	if h.listMetadataBudget <= 0 {
+		return &listEnrichment{h: h, ctx: ctx, cancel: func() {}}
+	}
+	if !h.listBreaker.Allow() {
+		degradedListingsTotal.Inc("breaker_open")
+		return &listEnrichment{h: h, ctx: ctx, cancel: func() {}, skip: true}
+	}
+	budgetCtx, cancel := context.WithTimeout(ctx, h.listMetadataBudget)
+	return &listEnrichment{h: h, ctx: budgetCtx, cancel: cancel, limited: true}
+}
+
+// get returns the metadata of an entry, or nil when it has none or the page
+// no longer looks metadata up
+func (e *listEnrichment) get(bucket, key string) *types.ObjectMetadata {
+	if e.skip {
+		return nil
+	}
+	if e.lookups++; e.lookups > maxListKeys {
+		// A backend that does not cap max-keys does not get the proxy to
+		// look up more entries than one S3 page holds
+		e.skip = true
+		degradedListingsTotal.Inc("too_many_keys")
+		return nil
+	}
+	storedMeta, err := e.h.storedMetadata(e.ctx, bucket, key)
+	switch {
+	case err == nil:
+		return storedMeta
+	case errors.Is(err, metadata.ErrNotFound):
+	case e.limited && e.ctx.Err() != nil:
+		// The budget is spent, so the rest of the page is not looked up
+		e.skip, e.failed = true, true
+		degradedListingsTotal.Inc("budget_exhausted")
+		logging.FromContext(e.ctx).Warn().Str("bucket", bucket).Dur("budget", e.h.listMetadataBudget).Msg("Listing ran out of metadata budget; remaining entries keep backend values")
+	default:
+		if !e.failed {
+			degradedListingsTotal.Inc("lookup_failed")
+		}
+		e.failed = true
+	}
+	return nil
+}
+
+// degraded reports whether some entries kept the backend's values because
+// their metadata was skipped or could not be read
+func (e *listEnrichment) degraded() bool {
+	return e.skip || e.failed
+}
+
+// finish reports the page's outcome to the breaker
+func (e *listEnrichment) finish() {
+	e.cancel()
+	if e.limited {
+		e.h.listBreaker.Record(e.failed)
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/trash"
+	"s3-vault-proxy/pkg/types"
+)
+
+// listPeekSize is how much of a listing response is inspected to detect its root element
+const listPeekSize = 512
+
+// isListBucketResult reports whether a buffered backend response is a ListBucketResult document
+func isListBucketResult(reader *bufio.Reader) bool {
+	head, _ := reader.Peek(listPeekSize)
+	return bytes.Contains(head, []byte("<ListBucketResult"))
+}
+
+// streamListBucketResult copies a backend ListBucketResult document to w token by token.
+// Each <Contents> entry is decoded, filtered and enriched on its own, so memory use
+// does not grow with the number of objects in the listing.
+func (h *S3Handler) streamListBucketResult(enrichment *listEnrichment, w io.Writer, body io.Reader, bucket string) error {
+	decoder := xml.NewDecoder(body)
+	encoder := xml.NewEncoder(w)
+	depth := 0
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 && t.Name.Local == "Contents" {
+				entry, err := collectElement(decoder, t)
+				if err != nil {
+					return err
+				}
+				depth--
+				if h.enrichListEntry(enrichment, entry, bucket) {
+					for _, entryToken := range entry {
+						if err := encoder.EncodeToken(stripNamespace(entryToken)); err != nil {
+							return err
+						}
+					}
+				}
+				continue
+			}
+			if depth == 2 && t.Name.Local == "CommonPrefixes" && h.hidesTrash(bucket) {
+				entry, err := collectElement(decoder, t)
+				if err != nil {
+					return err
+				}
+				depth--
+				if childText(entry, "Prefix") != trash.Prefix {
+					for _, entryToken := range entry {
+						if err := encoder.EncodeToken(stripNamespace(entryToken)); err != nil {
+							return err
+						}
+					}
+				}
+				continue
+			}
+		case xml.EndElement:
+			depth--
+		}
+
+		if err := encoder.EncodeToken(stripNamespace(token)); err != nil {
+			return err
+		}
+	}
+
+	return encoder.Flush()
+}
+
+// collectElement reads tokens up to and including the end of the element opened by start
+func collectElement(decoder *xml.Decoder, start xml.StartElement) ([]xml.Token, error) {
+	tokens := []xml.Token{start.Copy()}
+	depth := 1
+	for depth > 0 {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		switch token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+		tokens = append(tokens, xml.CopyToken(token))
+	}
+	return tokens, nil
+}
+
+// enrichListEntry rewrites Size, ETag and LastModified of a <Contents> entry from stored metadata.
+// It returns false when the entry is a metadata or trash object that must be hidden.
+func (h *S3Handler) enrichListEntry(enrichment *listEnrichment, tokens []xml.Token, bucket string) bool {
+	key := childText(tokens, "Key")
+	if metadata.IsMetadataKey(key) || (h.hidesTrash(bucket) && trash.IsTrashKey(key)) {
+		return false
+	}
+
+	storedMeta := enrichment.get(bucket, key)
+	if storedMeta == nil {
+		return true
+	}
+
+	setChildText(tokens, "Size", strconv.FormatInt(storedMeta.ContentLength, 10))
+	if storedMeta.ETag != "" {
+		setChildText(tokens, "ETag", storedMeta.ETag)
+	}
+	if lastModified := types.ListLastModified(storedMeta.LastModified); lastModified != "" {
+		setChildText(tokens, "LastModified", lastModified)
+	}
+	return true
+}
+
+// childText returns the text of a direct child element of a collected element
+func childText(tokens []xml.Token, name string) string {
+	if i := childTextIndex(tokens, name); i >= 0 {
+		return string(tokens[i].(xml.CharData))
+	}
+	return ""
+}
+
+// setChildText replaces the text of a direct child element of a collected element
+func setChildText(tokens []xml.Token, name, value string) {
+	if i := childTextIndex(tokens, name); i >= 0 {
+		tokens[i] = xml.CharData(value)
+	}
+}
+
+func childTextIndex(tokens []xml.Token, name string) int {
+	depth := 0
+	for i, token := range tokens {
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 && t.Name.Local == name && i+1 < len(tokens) {
+				if _, ok := tokens[i+1].(xml.CharData); ok {
+					return i + 1
+				}
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return -1
+}
+
+// stripNamespace drops resolved namespace URLs from element names. The decoder
+// resolves xmlns into every name, and re-encoding them would repeat the
+// declaration on each element; the root's xmlns attribute is kept as-is.
+func stripNamespace(token xml.Token) xml.Token {
+	switch t := token.(type) {
+	case xml.StartElement:
+		t.Name.Space = ""
+		return t
+	case xml.EndElement:
+		t.Name.Space = ""
+		return t
+	}
+	return token
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/url"
+	"strconv"
+	"unicode/utf8"
+
+	"s3-vault-proxy/pkg/types"
+)
+
+const (
+	// maxListKeys is the most entries S3 returns in one listing page, and the
+	// most the proxy looks up metadata for, whatever max-keys asks for
+	maxListKeys = 1000
+	// maxListKeyLength bounds markers, prefixes and delimiters, which name keys
+	maxListKeyLength = 1024
+	// maxContinuationTokenLength bounds the opaque tokens backends hand out
+	maxContinuationTokenLength = 4096
+)
+
+// listKeyParameters name keys or parts of keys in listing queries
+var listKeyParameters = []string{"prefix", "delimiter", "marker", "start-after", "key-marker"}
+
+// validateListQuery checks the parameters of a listing query, returning the
+// error S3 answers abusive or malformed values with. max-keys above
+// maxListKeys stays in the forwarded query, as it is covered by the client's
+// signature; the backend caps the page, and the proxy never enriches more
+// than maxListKeys entries of it.
+func validateListQuery(queryString []byte) *types.ErrorResponse {
+	query, err := url.ParseQuery(string(queryString))
+	if err != nil {
+		return &types.ErrorResponse{Code: "InvalidArgument", Message: "Malformed query string"}
+	}
+
+	if query.Has("max-keys") {
+		maxKeys, err := strconv.ParseInt(query.Get("max-keys"), 10, 32)
+		if err != nil {
+			return &types.ErrorResponse{Code: "InvalidArgument", Message: "Provided max-keys not an integer or within integer range"}
+		}
+		if maxKeys < 0 {
+			return &types.ErrorResponse{Code: "InvalidArgument", Message: "Argument maxKeys must be an integer between 0 and 2147483647"}
+		}
+	}
+	for _, name := range listKeyParameters {
+		value := query.Get(name)
+		if len(value) > maxListKeyLength || !utf8.ValidString(value) {
+			return &types.ErrorResponse{Code: "InvalidArgument", Message: "Argument " + name + " must be valid UTF-8 of at most 1024 bytes"}
+		}
+	}
+	if query.Has("continuation-token") {
+		if token := query.Get("continuation-token"); token == "" || len(token) > maxContinuationTokenLength {
+			return &types.ErrorResponse{Code: "InvalidArgument", Message: "The continuation token provided is incorrect"}
+		}
+	}
+	if query.Has("encoding-type") && query.Get("encoding-type") != "url" {
+		return &types.ErrorResponse{Code: "InvalidArgument", Message: "Invalid Encoding Method specified in Request"}
+	}
+	if query.Has("list-type") && query.Get("list-type") != "2" {
+		return &types.ErrorResponse{Code: "InvalidArgument", Message: "Invalid List Type specified in Request"}
+	}
+	return nil
+}
+
+// uploadListCounts are the integer parameters of ListMultipartUploads and
+// ListParts
+var uploadListCounts = []string{"max-uploads", "max-parts", "part-number-marker"}
+
+// validateUploadListQuery checks the parameters of a ListMultipartUploads or
+// ListParts query like validateListQuery checks listings
+func validateUploadListQuery(queryString []byte) *types.ErrorResponse {
+	query, err := url.ParseQuery(string(queryString))
+	if err != nil {
+		return &types.ErrorResponse{Code: "InvalidArgument", Message: "Malformed query string"}
+	}
+	for _, name := range uploadListCounts {
+		if !query.Has(name) {
+			continue
+		}
+		if count, err := strconv.ParseInt(query.Get(name), 10, 32); err != nil || count < 0 {
+			return &types.ErrorResponse{Code: "InvalidArgument", Message: "Argument " + name + " must be an integer between 0 and 2147483647"}
+		}
+	}
+	for _, name := range []string{"prefix", "delimiter", "key-marker", "upload-id-marker"} {
+		value := query.Get(name)
+		if len(value) > maxListKeyLength || !utf8.ValidString(value) {
+			return &types.ErrorResponse{Code: "InvalidArgument", Message: "Argument " + name + " must be valid UTF-8 of at most 1024 bytes"}
+		}
+	}
+	if query.Has("encoding-type") && query.Get("encoding-type") != "url" {
+		return &types.ErrorResponse{Code: "InvalidArgument", Message: "Invalid Encoding Method specified in Request"}
+	}
+	return nil
+}
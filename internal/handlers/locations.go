@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"fmt"
+
+	"s3-vault-proxy/internal/locations"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WithRegion rejects bucket creations whose LocationConstraint names a region
+// other than the one the proxy serves, as a region endpoint of S3 does
+func WithRegion(region string) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.region = region
+	}
+}
+
+// WithBucketLocations records the location of created buckets in store and
+// answers GetBucketLocation from it
+func WithBucketLocations(store *locations.Store) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.locations = store
+	}
+}
+
+// bucketRegion returns the region a CreateBucket request with body asks for,
+// or the error to reject it with. An omitted constraint means the proxy's region.
+func (h *S3Handler) bucketRegion(body []byte) (string, *types.ErrorResponse) {
+	constraint, err := locations.ParseConfiguration(body)
+	if err != nil {
+		return "", &types.ErrorResponse{
+			Code:    "MalformedXML",
+			Message: "The XML you provided was not well-formed or did not validate against our published schema",
+		}
+	}
+	if h.region == "" {
+		if constraint == "" {
+			constraint = locations.DefaultRegion
+		}
+		return constraint, nil
+	}
+	if constraint == "" {
+		return h.region, nil
+	}
+	if constraint == "EU" {
+		constraint = "eu-west-1"
+	}
+	if constraint == locations.DefaultRegion {
+		return "", &types.ErrorResponse{
+			Code:    "InvalidLocationConstraint",
+			Message: "The specified location-constraint is not valid",
+		}
+	}
+	if constraint != h.region {
+		return "", &types.ErrorResponse{
+			Code:    "IllegalLocationConstraintException",
+			Message: fmt.Sprintf("The %s location constraint is incompatible for the region specific endpoint this request was sent to.", constraint),
+		}
+	}
+	return constraint, nil
+}
+
+// recordLocation stores the region of a created bucket. The bucket exists
+// either way, so a failure is only logged; GetBucketLocation then falls back
+// to the backend's answer.
+func (h *S3Handler) recordLocation(c *fiber.Ctx, bucket, region string) {
+	if h.locations == nil {
+		return
+	}
+	if err := h.locations.Put(c.UserContext(), bucket, region); err != nil {
+		logging.FromContext(c.UserContext()).Warn().Err(err).Str("region", region).Msg("Failed to record bucket location")
+	}
+}
+
+// forgetLocation removes the recorded region of a deleted bucket
+func (h *S3Handler) forgetLocation(c *fiber.Ctx, bucket string) {
+	if h.locations == nil {
+		return
+	}
+	if err := h.locations.Delete(c.UserContext(), bucket); err != nil {
+		logging.FromContext(c.UserContext()).Warn().Err(err).Msg("Failed to delete bucket location")
+	}
+}
+
+// GetBucketLocation handles GET /:bucket?location. The request is still
+// forwarded so the backend authorizes it; a recorded location replaces the
+// backend's answer, which for buckets created before recording began is
+// relayed unchanged.
+func (h *S3Handler) GetBucketLocation(c *fiber.Ctx) error {
+	bucket := c.Params("bucket")
+	resp, err := h.forward(c, "GET", fmt.Sprintf("/%s", bucket), nil, h.extractHeaders(c), c.Request().URI().QueryString())
+	if err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to get bucket location")
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to get bucket location",
+		})
+	}
+	defer resp.Body.Close()
+
+	if h.locations == nil || resp.StatusCode != fiber.StatusOK {
+		return h.forwardResponse(c, resp)
+	}
+	location, err := h.locations.Get(c.UserContext(), bucket)
+	if err != nil {
+		logging.FromContext(c.UserContext()).Warn().Err(err).Msg("Failed to load bucket location")
+	}
+	if location == nil {
+		return h.forwardResponse(c, resp)
+	}
+	return c.XML(locations.Response(location.Region))
+}
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"s3-vault-proxy/internal/etag"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/multipart"
+	"s3-vault-proxy/internal/notify"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/spool"
+	"s3-vault-proxy/internal/vault"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var partKeyMismatchError = types.ErrorResponse{
+	Code:    "InvalidArgument",
+	Message: "The KMS key of a part must be the key its multipart upload was created with",
+}
+
+// PostObject handles POST /:bucket/*, which starts (?uploads) and completes
+// (?uploadId) multipart uploads. Other POSTs, such as ?restore, are relayed
+// unchanged.
+func (h *S3Handler) PostObject(c *fiber.Ctx) error {
+	bucket := c.Params("bucket")
+	key, path, err := objectKey(c)
+	if err != nil {
+		return c.Status(400).XML(invalidKeyError)
+	}
+
+	args := c.Request().URI().QueryArgs()
+	switch {
+	case args.Has("uploads"):
+		return h.CreateMultipartUpload(c, bucket, key, path)
+	case args.Has("uploadId"):
+		return h.CompleteMultipartUpload(c, bucket, key, path, string(args.Peek("uploadId")))
+	}
+	return h.forwardObjectSubresource(c, path)
+}
+
+// CreateMultipartUpload handles POST /:bucket/*?uploads. The upload's KMS key
+// is resolved and authorized like a PUT's, and remembered so its parts are
+// held to the same key.
+func (h *S3Handler) CreateMultipartUpload(c *fiber.Ctx, bucket, key, path string) error {
+	sse, status, errResp := h.resolveUploadKey(c, bucket)
+	if errResp != nil {
+		return c.Status(status).XML(errResp)
+	}
+
+	headers := h.extractHeaders(c)
+	resp, status, errResp, err := h.forwardProvisioning(c, bucket, sse, func() (*http.Response, error) {
+		return h.forward(c, "POST", path, nil, headers, c.Request().URI().QueryString())
+	})
+	if errResp != nil {
+		return c.Status(status).XML(errResp)
+	}
+	if err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to create multipart upload")
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to create multipart upload",
+		})
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return h.forwardResponse(c, resp)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	result, err := multipart.ParseInitiateResult(body)
+	if err != nil {
+		logging.FromContext(c.UserContext()).Warn().Err(err).Msg("Multipart upload created without a readable upload ID; its parts are not tracked")
+	} else {
+		// Strings are copied since fasthttp reuses the request's buffers
+		h.uploads.Start(multipart.Upload{
+			ID:         result.UploadID,
+			Bucket:     strings.Clone(bucket),
+			Key:        strings.Clone(key),
+			KMSKeyARN:  strings.Clone(sse.kmsKeyARN),
+			TransitKey: strings.Clone(sse.transitKey),
+			Explicit:   sse.explicit,
+			// The context is checked on reads of the completed object
+			EncryptionContext: strings.Clone(sse.encryptionContext),
+		})
+		logging.FromContext(c.UserContext()).Debug().Str("upload_id", result.UploadID).Msg("Multipart upload created")
+	}
+
+	h.copyResponseHeaders(c, resp.Header)
+	h.setEncryptionHeaders(c, sse, resp)
+	return c.Status(resp.StatusCode).Send(body)
+}
+
+// UploadPart handles PUT /:bucket/*?partNumber&uploadId. Parts carry no
+// SSE-KMS headers of their own; the backend encrypts them under the key the
+// upload was created with, which the tenant must still be allowed to use.
+// Parts of uploads the proxy did not see created, such as before a restart,
+// are forwarded for the backend to judge.
+func (h *S3Handler) UploadPart(c *fiber.Ctx, bucket, key, path, uploadID string) error {
+	partNumber, err := strconv.Atoi(c.Query("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+		return c.Status(400).XML(invalidPartNumberArgument)
+	}
+	if errResp := checkSSEHeaders(c, s3.SSEPart); errResp != nil {
+		return c.Status(400).XML(errResp)
+	}
+
+	upload := h.uploads.Get(uploadID, bucket, key)
+	if upload == nil {
+		logging.FromContext(c.UserContext()).Debug().Str("upload_id", uploadID).Msg("Part of an untracked multipart upload")
+	} else {
+		if kmsKeyARN, err := h.getKMSKeyARN(c); err == nil && kmsKeyARN != upload.KMSKeyARN {
+			return c.Status(400).XML(partKeyMismatchError)
+		}
+		if status, err := h.authorizeTenantKey(c, upload.KMSKeyARN, true); err != nil {
+			return c.Status(status).XML(types.ErrorResponse{
+				Code:    tenantErrorCode(status),
+				Message: err.Error(),
+			})
+		}
+	}
+
+	headers := h.extractHeaders(c)
+	body, errResp := h.readUploadBody(c, headers)
+	if errResp != nil {
+		return c.Status(400).XML(errResp)
+	}
+	defer body.Close()
+
+	bodyReader, err := body.Reader()
+	if err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to read spooled request body")
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to store part",
+		})
+	}
+
+	resp, err := h.forward(c, "PUT", path, bodyReader, headers, c.Request().URI().QueryString())
+	if err != nil {
+		if upload != nil {
+			vault.RecordKeyUsage(upload.KMSKeyARN, upload.TransitKey, vault.OperationEncrypt, 0, err)
+		}
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to store part")
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to store part",
+		})
+	}
+	defer resp.Body.Close()
+
+	if upload == nil {
+		return h.forwardResponse(c, resp)
+	}
+	if resp.StatusCode >= 400 {
+		vault.RecordKeyUsage(upload.KMSKeyARN, upload.TransitKey, vault.OperationEncrypt, 0, fmt.Errorf("backend returned status %d", resp.StatusCode))
+		return h.forwardResponse(c, resp)
+	}
+
+	// The backend's ETag is relayed unchanged: clients list it in the signed
+	// body that completes the upload. The plaintext digest is kept for the
+	// completed object's ETag.
+	part := multipart.Part{ETag: resp.Header.Get("ETag")}
+	if c.Get("X-Amz-Copy-Source") == "" {
+		part.Size = objectSize(headers, body)
+		if part.Sum, err = plaintextSum(body, headers); err != nil {
+			logging.FromContext(c.UserContext()).Warn().Err(err).Msg("Failed to compute part ETag")
+		}
+	}
+	h.uploads.AddPart(uploadID, partNumber, part)
+	vault.RecordKeyUsage(upload.KMSKeyARN, upload.TransitKey, vault.OperationEncrypt, part.Size, nil)
+
+	h.setEncryptionHeaders(c, uploadKeyOf(upload), resp)
+	return h.forwardResponse(c, resp)
+}
+
+// CompleteMultipartUpload handles POST /:bucket/*?uploadId. The backend
+// assembles the object; the proxy replaces its ETag with the multipart ETag of
+// the plaintext when it saw every listed part uploaded.
+func (h *S3Handler) CompleteMultipartUpload(c *fiber.Ctx, bucket, key, path, uploadID string) error {
+	upload := h.uploads.Get(uploadID, bucket, key)
+	if upload != nil {
+		if status, err := h.authorizeTenantKey(c, upload.KMSKeyARN, true); err != nil {
+			return c.Status(status).XML(types.ErrorResponse{
+				Code:    tenantErrorCode(status),
+				Message: err.Error(),
+			})
+		}
+	}
+
+	requestBody := c.Body()
+	resp, err := h.forward(c, "POST", path, bytes.NewReader(requestBody), h.extractHeaders(c), c.Request().URI().QueryString())
+	if err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to complete multipart upload")
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to complete multipart upload",
+		})
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return h.forwardResponse(c, resp)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	result, err := multipart.ParseCompleteResult(body)
+	if err != nil {
+		// Backends may report a failed completion with 200, which clients retry
+		logging.FromContext(c.UserContext()).Warn().Err(err).Msg("Backend failed to complete multipart upload")
+		return h.forwardRawResponse(c, resp.StatusCode, resp.Header, body)
+	}
+	h.invalidateObject(bucket, key)
+
+	objectETag, size := result.ETag, int64(0)
+	kmsKeyARN := resp.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id")
+	if upload != nil {
+		kmsKeyARN = upload.KMSKeyARN
+		if plaintextETag, plaintextSize, err := h.completedETag(uploadID, requestBody); err != nil {
+			logging.FromContext(c.UserContext()).Warn().Err(err).Msg("Keeping the backend's ETag for the multipart upload")
+			// Without the plaintext size no sidecar can be written, and with it
+			// goes any context of the previous object
+			h.forgetSidecar(c, bucket, key)
+			if h.encryptionContexts != nil && upload.EncryptionContext != "" {
+				logging.FromContext(c.UserContext()).Warn().Msg("The multipart upload's encryption context is not recorded; reads of the object are not checked")
+			}
+		} else {
+			objectETag, size = plaintextETag, plaintextSize
+			body = multipart.ReplaceETag(body, objectETag)
+			h.recordUpload(c, bucket, key, uploadKeyOf(upload), types.ObjectMetadata{ContentLength: size, ETag: objectETag})
+		}
+		h.uploads.Remove(uploadID)
+	} else {
+		// The plaintext of an untracked upload is unknown, so reads report the backend's values
+		h.forgetSidecar(c, bucket, key)
+	}
+	if kmsKeyARN == "" {
+		kmsKeyARN = h.defaultKMSKeyARN
+	}
+	if h.replicator != nil && kmsKeyARN != "" {
+		h.replicator.Enqueue(bucket, key, kmsKeyARN)
+	}
+	h.publish(c, notify.Event{
+		Name:      notify.ObjectCreatedCompleteMultipartUpload,
+		Bucket:    bucket,
+		Key:       key,
+		Size:      size,
+		ETag:      objectETag,
+		RequestID: resp.Header.Get("X-Amz-Request-Id"),
+	})
+
+	h.copyResponseHeaders(c, resp.Header)
+	if upload != nil {
+		h.setEncryptionHeaders(c, uploadKeyOf(upload), resp)
+	}
+	return c.Status(resp.StatusCode).Send(body)
+}
+
+// completedETag returns the plaintext ETag and size of an upload completed
+// with requestBody
+func (h *S3Handler) completedETag(uploadID string, requestBody []byte) (string, int64, error) {
+	parts, err := multipart.ParseCompletion(requestBody)
+	if err != nil {
+		return "", 0, err
+	}
+	return h.uploads.PlaintextETag(uploadID, parts)
+}
+
+// ListMultipartUploads handles GET /:bucket?uploads. The backend lists every
+// upload in progress, including those the proxy does not track, so clients
+// can resume or abort them.
+func (h *S3Handler) ListMultipartUploads(c *fiber.Ctx) error {
+	queryString := c.Request().URI().QueryString()
+	if errResp := validateUploadListQuery(queryString); errResp != nil {
+		return c.Status(400).XML(errResp)
+	}
+	resp, err := h.forward(c, "GET", "/"+c.Params("bucket"), nil, h.extractHeaders(c), queryString)
+	if err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to list multipart uploads")
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to list multipart uploads",
+		})
+	}
+	defer resp.Body.Close()
+	return h.forwardResponse(c, resp)
+}
+
+// ListParts handles GET /:bucket/*?uploadId. Part ETags stay the backend's,
+// which clients complete the upload with. Parts uploaded through this replica
+// report their plaintext size, like the completed object will.
+func (h *S3Handler) ListParts(c *fiber.Ctx, bucket, key, path, uploadID string) error {
+	queryString := c.Request().URI().QueryString()
+	if errResp := validateUploadListQuery(queryString); errResp != nil {
+		return c.Status(400).XML(errResp)
+	}
+	resp, err := h.forward(c, "GET", path, nil, h.extractHeaders(c), queryString)
+	if err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to list parts")
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to list parts",
+		})
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || h.uploads.Get(uploadID, bucket, key) == nil {
+		return h.forwardResponse(c, resp)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return h.forwardRawResponse(c, resp.StatusCode, resp.Header, multipart.ReplacePartSizes(body, h.uploads.Parts(uploadID)))
+}
+
+// AbortMultipartUpload handles DELETE /:bucket/*?uploadId. It only discards
+// the upload's parts, so the object itself is neither trashed nor forgotten.
+func (h *S3Handler) AbortMultipartUpload(c *fiber.Ctx, path, uploadID string) error {
+	resp, err := h.forward(c, "DELETE", path, nil, h.extractHeaders(c), c.Request().URI().QueryString())
+	if err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to abort multipart upload")
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to abort multipart upload",
+		})
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 300 {
+		h.uploads.Remove(uploadID)
+	}
+	return h.forwardResponse(c, resp)
+}
+
+// uploadKeyOf returns the KMS key a tracked upload was created with
+func uploadKeyOf(upload *multipart.Upload) uploadKey {
+	return uploadKey{
+		kmsKeyARN:         upload.KMSKeyARN,
+		transitKey:        upload.TransitKey,
+		explicit:          upload.Explicit,
+		encryptionContext: upload.EncryptionContext,
+	}
+}
+
+// plaintextSum returns the MD5 digest of an uploaded body, decoding aws-chunked payloads
+func plaintextSum(body *spool.Body, headers http.Header) ([]byte, error) {
+	objectETag, err := plaintextETag(body, headers)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(etag.Normalize(objectETag))
+}
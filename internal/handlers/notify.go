@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"strings"
+
+	"s3-vault-proxy/internal/notify"
+	"s3-vault-proxy/internal/sigv4"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WithNotifications sends the events of uploads and deletes to the notifier's queue
+func WithNotifications(notifier *notify.Notifier) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.notifier = notifier
+	}
+}
+
+// publish sends an event about an object, attributed to the request's access
+// key. Strings are copied since fasthttp reuses the request's buffers before
+// the event is delivered.
+func (h *S3Handler) publish(c *fiber.Ctx, event notify.Event) {
+	if h.notifier == nil {
+		return
+	}
+	principalID, _, _ := strings.Cut(c.Query("X-Amz-Credential"), "/")
+	if auth, err := sigv4.ParseAuthorization(c.Get("Authorization")); err == nil {
+		principalID = auth.AccessKey
+	}
+	event.Bucket = strings.Clone(event.Bucket)
+	event.Key = strings.Clone(event.Key)
+	event.PrincipalID = strings.Clone(principalID)
+	event.SourceIP = strings.Clone(c.IP())
+	h.notifier.Publish(event)
+}
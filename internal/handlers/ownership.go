@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/xml"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// bucketOwnerEnforced is the only object ownership the proxy supports: ACLs
+// are not evaluated, and the bucket owner owns every object
+const bucketOwnerEnforced = "BucketOwnerEnforced"
+
+// isOwnershipControlsRequest reports whether a bucket request addresses the ?ownershipControls subresource
+func isOwnershipControlsRequest(c *fiber.Ctx) bool {
+	return c.Request().URI().QueryArgs().Has("ownershipControls")
+}
+
+// GetBucketOwnershipControls handles GET /:bucket?ownershipControls. Every
+// bucket reports BucketOwnerEnforced, so SDK helpers and Terraform that read
+// the controls while managing a bucket get a well-formed answer.
+func (h *S3Handler) GetBucketOwnershipControls(c *fiber.Ctx) error {
+	if denied, err := h.authorizeBucketSubresource(c, nil); denied || err != nil {
+		return err
+	}
+	return c.XML(types.OwnershipControls{
+		Rules: []types.OwnershipControlsRule{{ObjectOwnership: bucketOwnerEnforced}},
+	})
+}
+
+// PutBucketOwnershipControls handles PUT /:bucket?ownershipControls. Only
+// BucketOwnerEnforced is accepted, and as it is already in effect nothing is stored.
+func (h *S3Handler) PutBucketOwnershipControls(c *fiber.Ctx) error {
+	body := append([]byte(nil), c.Body()...)
+	if denied, err := h.authorizeBucketSubresource(c, body); denied || err != nil {
+		return err
+	}
+
+	// Decoded without the namespace, which clients do not always send
+	var controls struct {
+		Rules []types.OwnershipControlsRule `xml:"Rule"`
+	}
+	if err := xml.Unmarshal(body, &controls); err != nil || len(controls.Rules) != 1 {
+		return c.Status(400).XML(types.ErrorResponse{
+			Code:    "MalformedXML",
+			Message: "The XML you provided was not well-formed or did not validate against our published schema",
+		})
+	}
+	if ownership := controls.Rules[0].ObjectOwnership; ownership != bucketOwnerEnforced {
+		logging.FromContext(c.UserContext()).Warn().Str("bucket", c.Params("bucket")).Str("object_ownership", ownership).Msg("Unsupported object ownership requested")
+		return c.Status(400).XML(types.ErrorResponse{
+			Code:    "InvalidArgument",
+			Message: "Only BucketOwnerEnforced object ownership is supported",
+		})
+	}
+	return c.SendStatus(200)
+}
+
+// DeleteBucketOwnershipControls handles DELETE /:bucket?ownershipControls.
+// BucketOwnerEnforced stays in effect, so the delete only succeeds.
+func (h *S3Handler) DeleteBucketOwnershipControls(c *fiber.Ctx) error {
+	if denied, err := h.authorizeBucketSubresource(c, nil); denied || err != nil {
+		return err
+	}
+	return c.SendStatus(204)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"s3-vault-proxy/pkg/types"
+)
+
+// maxPartNumber is the highest part number S3 accepts
+const maxPartNumber = 10000
+
+var (
+	invalidPartNumberArgument = types.ErrorResponse{
+		Code:    "InvalidArgument",
+		Message: fmt.Sprintf("Part number must be an integer between 1 and %d, inclusive", maxPartNumber),
+	}
+	partNumberWithRangeError = types.ErrorResponse{
+		Code:    "InvalidRequest",
+		Message: "Cannot specify both Range header and partNumber query parameter",
+	}
+	invalidPartNumberError = types.ErrorResponse{
+		Code:    "InvalidPartNumber",
+		Message: "The requested partnumber is not satisfiable",
+	}
+)
+
+// partNumber returns the partNumber a HEAD or GET asks for, 0 when it asks
+// for the whole object, and the error to answer an invalid one with
+func partNumber(queryString []byte, rangeHeader string) (int, *types.ErrorResponse) {
+	query, err := url.ParseQuery(string(queryString))
+	if err != nil || !query.Has("partNumber") {
+		return 0, nil
+	}
+	part, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil || part < 1 || part > maxPartNumber {
+		return 0, &invalidPartNumberArgument
+	}
+	if rangeHeader != "" {
+		return 0, &partNumberWithRangeError
+	}
+	return part, nil
+}
+
+// applyPartNumber answers a read of one part of an object from a backend
+// response. Backends that serve parts answer 206 with the part's Content-Range
+// and x-amz-mp-parts-count, which are kept. Backends that ignore partNumber
+// answer with the whole object, which S3 treats as a single part: part 1 is
+// the whole object and any other part is not satisfiable, reported by false.
+func applyPartNumber(resp *http.Response, part int) bool {
+	if part == 0 || resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Range") != "" {
+		return true
+	}
+	if part > 1 {
+		return false
+	}
+	size, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	if err != nil || size <= 0 {
+		return true
+	}
+	resp.StatusCode = http.StatusPartialContent
+	resp.Status = "206 Partial Content"
+	resp.Header.Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", size-1, size))
+	return true
+}
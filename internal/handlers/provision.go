@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/provision"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var bucketsProvisionedTotal = metrics.NewCounter(
+	"s3_vault_proxy_buckets_provisioned_total",
+	"Buckets created on their first upload, by result (created, failed).",
+	"result",
+)
+
+// WithBucketProvisioning creates the buckets p matches on their first upload.
+// A created bucket is set up like one created through the proxy: its location
+// is recorded, the upload's KMS key becomes its default key and, with KMS
+// bindings, its only key.
+func WithBucketProvisioning(p *provision.Provisioner) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.provisioner = p
+	}
+}
+
+// forwardProvisioning sends an upload with send and, when the backend
+// answers that its bucket does not exist and the bucket matches the
+// provisioning patterns, creates the bucket and sends the upload again. The
+// proxy cannot verify signatures, so nothing is created with the operator
+// credentials until the backend has authenticated the client's own request
+// and only found the bucket missing. status and errResp reject the upload
+// when the bucket cannot be created.
+func (h *S3Handler) forwardProvisioning(c *fiber.Ctx, bucket string, sse uploadKey, send func() (*http.Response, error)) (resp *http.Response, status int, errResp *types.ErrorResponse, err error) {
+	resp, err = send()
+	if err != nil || !h.provisioner.Matches(bucket) || s3.ValidateBucketName(bucket) != nil || !isNoSuchBucket(resp) {
+		return resp, 0, nil, err
+	}
+	resp.Body.Close()
+	if status, errResp := h.provisionBucket(c, bucket, sse); errResp != nil {
+		return nil, status, errResp, nil
+	}
+	resp, err = send()
+	return resp, 0, nil, err
+}
+
+// isNoSuchBucket reports whether resp is a NoSuchBucket error, leaving its
+// body readable
+func isNoSuchBucket(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusNotFound {
+		return false
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	var errResp types.ErrorResponse
+	return xml.Unmarshal(body, &errResp) == nil && errResp.Code == "NoSuchBucket"
+}
+
+// provisionBucket creates a missing bucket and sets it up, returning the
+// status and error to reject the upload with when it cannot be created
+func (h *S3Handler) provisionBucket(c *fiber.Ctx, bucket string, sse uploadKey) (int, *types.ErrorResponse) {
+	logger := logging.FromContext(c.UserContext()).With().Str("bucket", bucket).Logger()
+	// A bucket half set up would outlive the client, so creating one is not cancelled
+	ctx := context.WithoutCancel(c.UserContext())
+	created, err := h.provisioner.Ensure(ctx, bucket)
+	if err != nil {
+		bucketsProvisionedTotal.Inc("failed")
+		logger.Error().Err(err).Msg("Failed to provision bucket")
+		return fiber.StatusServiceUnavailable, &types.ErrorResponse{
+			Code:    "ServiceUnavailable",
+			Message: "Unable to create the bucket for this upload",
+		}
+	}
+	if !created {
+		return 0, nil
+	}
+	bucketsProvisionedTotal.Inc("created")
+	logger.Info().Str("kms_arn", sse.kmsKeyARN).Msg("Provisioned bucket on its first upload")
+
+	if region, errResp := h.bucketRegion(nil); errResp == nil {
+		h.recordLocation(c, bucket, region)
+	}
+	if err := h.provisioner.SetDefaultKey(ctx, bucket, sse.kmsKeyARN); err != nil {
+		logger.Warn().Err(err).Msg("Failed to set the default KMS key of a provisioned bucket")
+	}
+	if h.kmsBindings != nil {
+		if _, err := h.kmsBindings.Put(ctx, bucket, []string{sse.kmsKeyARN}); err != nil {
+			logger.Error().Err(err).Msg("Failed to bind a provisioned bucket to its KMS key")
+		}
+	}
+	return 0, nil
+}
+
+// forgetProvisionedBucket lets a deleted bucket be provisioned again
+func (h *S3Handler) forgetProvisionedBucket(bucket string) {
+	if h.provisioner != nil {
+		h.provisioner.Forget(bucket)
+	}
+}
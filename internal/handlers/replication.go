@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/replication"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WithReplication serves the bucket replication API and replicates uploads
+// to the replicator's destination
+func WithReplication(replicator *replication.Replicator) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.replicator = replicator
+	}
+}
+
+// isReplicationRequest reports whether a bucket request addresses the ?replication subresource
+func (h *S3Handler) isReplicationRequest(c *fiber.Ctx) bool {
+	return h.replicator != nil && c.Request().URI().QueryArgs().Has("replication")
+}
+
+// DeleteBucket handles DELETE /:bucket - delete a bucket or its replication configuration
+func (h *S3Handler) DeleteBucket(c *fiber.Ctx) error {
+	if h.isReplicationRequest(c) {
+		return h.DeleteBucketReplication(c)
+	}
+	if isOwnershipControlsRequest(c) {
+		return h.DeleteBucketOwnershipControls(c)
+	}
+
+	bucket := c.Params("bucket")
+	resp, err := h.forward(c, "DELETE", fmt.Sprintf("/%s", bucket), nil, h.extractHeaders(c), c.Request().URI().QueryString())
+	if err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to delete bucket")
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to delete bucket",
+		})
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 300 && !hasSubresource(c.Request().URI().QueryString()) {
+		h.forgetLocation(c, bucket)
+		h.forgetProvisionedBucket(bucket)
+	}
+	return h.forwardResponse(c, resp)
+}
+
+// PutBucketReplication handles PUT /:bucket?replication
+func (h *S3Handler) PutBucketReplication(c *fiber.Ctx) error {
+	bucket := c.Params("bucket")
+	body := append([]byte(nil), c.Body()...)
+	if denied, err := h.authorizeBucketSubresource(c, body); denied || err != nil {
+		return err
+	}
+
+	config, err := replication.ParseConfiguration(bytes.NewReader(body))
+	if err != nil {
+		return c.Status(400).XML(types.ErrorResponse{
+			Code:    "MalformedXML",
+			Message: err.Error(),
+		})
+	}
+	if err := h.replicator.PutConfiguration(bucket, config); err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to store replication configuration")
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to store replication configuration",
+		})
+	}
+	logging.FromContext(c.UserContext()).Info().Int("rules", len(config.Rules)).Msg("Replication configuration updated")
+	return c.SendStatus(200)
+}
+
+// GetBucketReplication handles GET /:bucket?replication
+func (h *S3Handler) GetBucketReplication(c *fiber.Ctx) error {
+	bucket := c.Params("bucket")
+	if denied, err := h.authorizeBucketSubresource(c, nil); denied || err != nil {
+		return err
+	}
+
+	config, err := h.replicator.Configuration(bucket)
+	if errors.Is(err, replication.ErrNoConfiguration) {
+		return c.Status(404).XML(types.ErrorResponse{
+			Code:    "ReplicationConfigurationNotFoundError",
+			Message: "The replication configuration was not found",
+		})
+	}
+	if err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to load replication configuration")
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to load replication configuration",
+		})
+	}
+	return c.XML(config)
+}
+
+// DeleteBucketReplication handles DELETE /:bucket?replication
+func (h *S3Handler) DeleteBucketReplication(c *fiber.Ctx) error {
+	bucket := c.Params("bucket")
+	if denied, err := h.authorizeBucketSubresource(c, nil); denied || err != nil {
+		return err
+	}
+
+	if err := h.replicator.DeleteConfiguration(bucket); err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to delete replication configuration")
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to delete replication configuration",
+		})
+	}
+	return c.SendStatus(204)
+}
+
+// authorizeBucketSubresource forwards a subresource request the proxy serves
+// itself, so the backend still verifies the client's signature and access to
+// the bucket. Any answer other than 401 or 403 counts as authorized; denials
+// are relayed to the client and reported as denied.
+func (h *S3Handler) authorizeBucketSubresource(c *fiber.Ctx, body []byte) (bool, error) {
+	bucket := c.Params("bucket")
+	resp, err := h.forward(c, c.Method(), fmt.Sprintf("/%s", bucket), bytes.NewReader(body), h.extractHeaders(c), c.Request().URI().QueryString())
+	if err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to authorize bucket request")
+		return true, c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to authorize request",
+		})
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return true, h.forwardResponse(c, resp)
+	}
+	return false, nil
+}
+
+// setReplicationStatus reports the replication status of a replicated object
+func (h *S3Handler) setReplicationStatus(c *fiber.Ctx, bucket, key string) {
+	if h.replicator == nil {
+		return
+	}
+	if status := h.replicator.Status(c.UserContext(), bucket, key); status != "" {
+		c.Set("x-amz-replication-status", status)
+	}
+}
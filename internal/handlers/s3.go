@@ -1,45 +1,149 @@
 package handlers
 
 import (
+	"bufio"
 	"bytes"
-	"encoding/xml"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"s3-vault-proxy/internal/bucketheaders"
+	"s3-vault-proxy/internal/cache"
+	"s3-vault-proxy/internal/etag"
+	"s3-vault-proxy/internal/features"
+	"s3-vault-proxy/internal/kmsbindings"
+	"s3-vault-proxy/internal/locations"
 	"s3-vault-proxy/internal/logging"
 	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/multipart"
+	"s3-vault-proxy/internal/notify"
+	"s3-vault-proxy/internal/phases"
+	"s3-vault-proxy/internal/provision"
+	"s3-vault-proxy/internal/replication"
 	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/sigv4"
+	"s3-vault-proxy/internal/spool"
+	"s3-vault-proxy/internal/tenancy"
+	"s3-vault-proxy/internal/tracing"
+	"s3-vault-proxy/internal/trash"
 	"s3-vault-proxy/internal/vault"
 	"s3-vault-proxy/pkg/types"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+var notModifiedTotal = metrics.NewCounter(
+	"s3_vault_proxy_not_modified_total",
+	"Conditional requests answered with 304 Not Modified without transferring the object.",
+	"method",
+)
+
 // S3Handler handles S3 API operations
 type S3Handler struct {
 	s3Client        s3.Interface
 	vaultClient     vault.Interface
 	metadataService metadata.Interface
+
+	objectCache         cache.Cache
+	objectCacheMaxEntry int64
+
+	spoolThreshold int64
+	spoolDir       string
+
+	features *features.Set
+
+	replicator *replication.Replicator
+	notifier   *notify.Notifier
+
+	trash *trash.Trash
+
+	defaultKMSKeyARN   string
+	kmsBindings        *kmsbindings.Store
+	encryptionContexts metadata.Interface
+	sidecars           sidecarStore
+
+	denyLegacyObjects bool
+	legacyMigrator    *legacyMigrator
+
+	bucketHeaders *bucketheaders.Set
+
+	region    string
+	locations *locations.Store
+
+	listMetadataBudget time.Duration
+	listBreaker        *metadata.Breaker
+
+	deleteConcurrency int
+
+	uploads *multipart.Registry
+
+	provisioner *provision.Provisioner
+}
+
+// S3HandlerOption configures optional S3 handler behavior
+type S3HandlerOption func(*S3Handler)
+
+// WithObjectCache enables caching of object bodies up to maxEntrySize bytes
+func WithObjectCache(objectCache cache.Cache, maxEntrySize int64) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.objectCache = objectCache
+		h.objectCacheMaxEntry = maxEntrySize
+	}
+}
+
+// WithRequestSpooling spools request bodies larger than threshold bytes to dir.
+// It requires the server to stream request bodies.
+func WithRequestSpooling(threshold int64, dir string) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.spoolThreshold = threshold
+		h.spoolDir = dir
+	}
+}
+
+// WithFeatures applies resolved feature flags. Without it every flag takes its default.
+func WithFeatures(set *features.Set) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.features = set
+	}
+}
+
+// WithDefaultKMSKey accepts uploads without an SSE-KMS header, attributing them
+// to kmsKeyARN. The request is forwarded unchanged, so the backend bucket must
+// apply default SSE-KMS encryption itself.
+func WithDefaultKMSKey(kmsKeyARN string) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.defaultKMSKeyARN = kmsKeyARN
+	}
 }
 
 // NewS3Handler creates a new S3 handler
-func NewS3Handler(s3Client s3.Interface, vaultClient vault.Interface, metadataService metadata.Interface) *S3Handler {
-	return &S3Handler{
-		s3Client:        s3Client,
-		vaultClient:     vaultClient,
-		metadataService: metadataService,
+func NewS3Handler(s3Client s3.Interface, vaultClient vault.Interface, metadataService metadata.Interface, opts ...S3HandlerOption) *S3Handler {
+	h := &S3Handler{
+		s3Client:          s3Client,
+		vaultClient:       vaultClient,
+		metadataService:   metadataService,
+		deleteConcurrency: defaultDeleteConcurrency,
+		uploads:           multipart.NewRegistry(multipart.DefaultMaxAge, multipart.DefaultMaxUploads),
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // ListBuckets handles GET / - list all buckets
 func (h *S3Handler) ListBuckets(c *fiber.Ctx) error {
 	headers := h.extractHeaders(c)
-	resp, err := h.s3Client.ForwardRequest("GET", "/", nil, headers, c.Request().URI().QueryString())
+	resp, err := h.forward(c, "GET", "/", nil, headers, c.Request().URI().QueryString())
 	if err != nil {
-		logging.Error().Err(err).Msg("Failed to list buckets")
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to list buckets")
 		return c.Status(500).XML(types.ErrorResponse{
 			Code:    "InternalError",
 			Message: "Failed to list buckets",
@@ -52,13 +156,41 @@ func (h *S3Handler) ListBuckets(c *fiber.Ctx) error {
 
 // CreateBucket handles PUT /:bucket - create a bucket
 func (h *S3Handler) CreateBucket(c *fiber.Ctx) error {
+	if h.isReplicationRequest(c) {
+		return h.PutBucketReplication(c)
+	}
+	if isOwnershipControlsRequest(c) {
+		return h.PutBucketOwnershipControls(c)
+	}
+
+	// Sub-resource requests (?tagging, ?cors, ...) address existing buckets,
+	// which may predate these rules
 	bucket := c.Params("bucket")
+	queryString := c.Request().URI().QueryString()
+	creating := !hasSubresource(queryString)
+	region := ""
+	if creating {
+		if err := s3.ValidateBucketName(bucket); err != nil {
+			return c.Status(400).XML(types.ErrorResponse{
+				Code:    "InvalidBucketName",
+				Message: err.Error(),
+			})
+		}
+		var errResp *types.ErrorResponse
+		if region, errResp = h.bucketRegion(c.Body()); errResp != nil {
+			return c.Status(400).XML(errResp)
+		}
+	}
 	path := fmt.Sprintf("/%s", bucket)
 	headers := h.extractHeaders(c)
 
-	resp, err := h.s3Client.ForwardRequest("PUT", path, nil, headers, c.Request().URI().QueryString())
+	var body io.Reader
+	if len(c.Body()) > 0 {
+		body = bytes.NewReader(c.Body())
+	}
+	resp, err := h.forward(c, "PUT", path, body, headers, queryString)
 	if err != nil {
-		logging.Error().Err(err).Msg("Failed to create bucket")
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to create bucket")
 		return c.Status(500).XML(types.ErrorResponse{
 			Code:    "InternalError",
 			Message: "Failed to create bucket",
@@ -66,70 +198,134 @@ func (h *S3Handler) CreateBucket(c *fiber.Ctx) error {
 	}
 	defer resp.Body.Close()
 
+	if creating && resp.StatusCode < 300 {
+		h.recordLocation(c, bucket, region)
+	}
 	return h.forwardResponse(c, resp)
 }
 
 // ListObjects handles GET /:bucket - list objects in bucket
 func (h *S3Handler) ListObjects(c *fiber.Ctx) error {
+	if h.isReplicationRequest(c) {
+		return h.GetBucketReplication(c)
+	}
+	if isOwnershipControlsRequest(c) {
+		return h.GetBucketOwnershipControls(c)
+	}
+	if root := unsupportedConfiguration(c); root != "" {
+		return h.GetUnsupportedConfiguration(c, root)
+	}
+	if c.Request().URI().QueryArgs().Has("location") {
+		return h.GetBucketLocation(c)
+	}
+	if c.Request().URI().QueryArgs().Has("uploads") {
+		return h.ListMultipartUploads(c)
+	}
+
 	bucket := c.Params("bucket")
 	path := fmt.Sprintf("/%s", bucket)
 	headers := h.extractHeaders(c)
 	queryString := c.Request().URI().QueryString()
+	if errResp := validateListQuery(queryString); errResp != nil {
+		return c.Status(400).XML(errResp)
+	}
 
-	logging.Debug().
-		Str("bucket", bucket).
+	logging.FromContext(c.UserContext()).Debug().
 		Str("path", path).
 		Str("original_query", string(queryString)).
 		Str("original_host", c.Get("Host")).
 		Msg("ListObjects request details")
 
-	resp, err := h.s3Client.ForwardRequest("GET", path, nil, headers, queryString)
+	resp, err := h.forward(c, "GET", path, nil, headers, queryString)
 	if err != nil {
-		logging.Error().Err(err).Msg("Failed to list objects")
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to list objects")
 		return c.Status(500).XML(types.ErrorResponse{
 			Code:    "InternalError",
 			Message: "Failed to list objects",
 		})
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
 		return h.forwardResponse(c, resp)
 	}
 
-	// Parse and filter response to remove metadata files
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return c.Status(500).XML(types.ErrorResponse{
-			Code:    "InternalError",
-			Message: "Failed to read list response",
-		})
-	}
-
-	var listResult types.ListBucketResult
-	if err := xml.Unmarshal(body, &listResult); err != nil {
-		// If we can't parse it, just forward the original response
+	reader := bufio.NewReaderSize(resp.Body, listPeekSize)
+	if !isListBucketResult(reader) {
+		// If it isn't a listing we understand, just forward the original response
+		defer resp.Body.Close()
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			return c.Status(500).XML(types.ErrorResponse{
+				Code:    "InternalError",
+				Message: "Failed to read list response",
+			})
+		}
 		return h.forwardRawResponse(c, resp.StatusCode, resp.Header, body)
 	}
 
-	// Filter out .metadata files and enhance with stored metadata
-	filteredContents := metadata.FilterMetadataObjects(listResult.Contents)
-	for i := range filteredContents {
-		if storedMeta, metaErr := h.metadataService.Get(bucket, filteredContents[i].Key, headers); metaErr == nil {
-			filteredContents[i].Size = storedMeta.ContentLength
-			filteredContents[i].ETag = storedMeta.ETag
+	// Metadata objects are filtered out and entries enhanced with stored metadata.
+	// Under a budget the page is enriched before anything is sent, so the
+	// response can say whether any entry kept the backend's values.
+	enrichment := h.newListEnrichment(c.UserContext())
+	if enrichment.limited {
+		defer resp.Body.Close()
+		var page bytes.Buffer
+		err := h.streamListBucketResult(enrichment, &page, reader, bucket)
+		enrichment.finish()
+		if err != nil {
+			logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to read object listing")
+			return c.Status(500).XML(types.ErrorResponse{
+				Code:    "InternalError",
+				Message: "Failed to read list response",
+			})
 		}
+		if enrichment.degraded() {
+			c.Set(degradedHeader, "degraded")
+		}
+		c.Set("Content-Type", "application/xml")
+		return c.Status(resp.StatusCode).Send(page.Bytes())
 	}
 
-	listResult.Contents = filteredContents
+	// Otherwise stream the listing so large buckets are never materialized in
+	// memory. Pages started while metadata lookups are tripped say so up front.
+	if enrichment.skip {
+		c.Set(degradedHeader, "degraded")
+	}
 	c.Set("Content-Type", "application/xml")
-	return c.XML(listResult)
+	c.Status(resp.StatusCode)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer resp.Body.Close()
+		defer enrichment.finish()
+		if err := h.streamListBucketResult(enrichment, w, reader, bucket); err != nil {
+			logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to stream object listing")
+		}
+	})
+	return nil
+}
+
+// HeadBucket handles HEAD /:bucket - check that a bucket exists and is accessible
+func (h *S3Handler) HeadBucket(c *fiber.Ctx) error {
+	path := fmt.Sprintf("/%s", c.Params("bucket"))
+	headers := h.extractHeaders(c)
+
+	resp, err := h.forward(c, "HEAD", path, nil, headers, c.Request().URI().QueryString())
+	if err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to head bucket")
+		return c.SendStatus(500)
+	}
+	defer resp.Body.Close()
+
+	return h.forwardResponse(c, resp)
 }
 
 // PutObject handles PUT /:bucket/* - forward request directly for signature validation
 func (h *S3Handler) PutObject(c *fiber.Ctx) error {
 	bucket := c.Params("bucket")
-	key := c.Params("*")
+	key, path, err := objectKey(c)
+	if err != nil {
+		return c.Status(400).XML(invalidKeyError)
+	}
 
 	if bucket == "" || key == "" {
 		return c.Status(400).XML(types.ErrorResponse{
@@ -138,45 +334,46 @@ func (h *S3Handler) PutObject(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get KMS key from headers for logging purposes
-	kmsKeyARN, err := h.getKMSKeyARN(c)
-	if err != nil {
-		logging.Warn().Err(err).Msg("Missing KMS key in request")
-		return c.Status(400).XML(types.ErrorResponse{
-			Code:    "InvalidRequest",
-			Message: err.Error(),
-		})
+	if s3.ObjectSubresource(string(c.Request().URI().QueryString())) != "" {
+		return h.forwardObjectSubresource(c, path)
 	}
-
-	// Convert KMS ARN to Vault key for logging
-	transitKey, err := h.vaultClient.ARNToVaultKey(kmsKeyARN)
-	if err != nil {
-		logging.Error().Err(err).Str("kms_arn", kmsKeyARN).Msg("Invalid KMS ARN format")
-		return c.Status(400).XML(types.ErrorResponse{
-			Code:    "InvalidRequest",
-			Message: err.Error(),
-		})
+	if uploadID := c.Query("uploadId"); uploadID != "" {
+		return h.UploadPart(c, bucket, key, path, uploadID)
 	}
 
-	logging.Info().
-		Str("bucket", bucket).
-		Str("key", key).
-		Str("kms_arn", kmsKeyARN).
-		Str("transit_key", transitKey).
-		Msg("Mapped KMS ARN to Vault transit key")
+	sse, status, errResp := h.resolveUploadKey(c, bucket)
+	if errResp != nil {
+		return c.Status(status).XML(errResp)
+	}
+	kmsKeyARN, transitKey := sse.kmsKeyARN, sse.transitKey
 
 	// CRITICAL: Forward the original request body directly to preserve AWS signature validation
 	// This maintains compatibility with chunked encoding and streaming signatures
-	path := fmt.Sprintf("/%s/%s", bucket, key)
 	headers := h.extractHeaders(c)
 
-	// Use the raw Fiber request to preserve all original headers including Content-Length
-	// This is essential for AWS signature validation with chunked encoding
-	bodyReader := bytes.NewReader(c.Body())
+	body, errResp := h.readUploadBody(c, headers)
+	if errResp != nil {
+		return c.Status(400).XML(errResp)
+	}
+	defer body.Close()
 
-	resp, err := h.s3Client.ForwardRequest("PUT", path, bodyReader, headers, c.Request().URI().QueryString())
+	// Use the raw Fiber request to preserve all original headers including Content-Length
+	// This is essential for AWS signature validation with chunked encoding.
+	// The spooled body is read again when the upload is retried after
+	// provisioning its bucket.
+	resp, status, errResp, err := h.forwardProvisioning(c, bucket, sse, func() (*http.Response, error) {
+		bodyReader, err := body.Reader()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spooled request body: %w", err)
+		}
+		return h.forward(c, "PUT", path, bodyReader, headers, c.Request().URI().QueryString())
+	})
+	if errResp != nil {
+		return c.Status(status).XML(errResp)
+	}
 	if err != nil {
-		logging.Error().Err(err).Msg("Failed to store encrypted object")
+		vault.RecordKeyUsage(kmsKeyARN, transitKey, vault.OperationEncrypt, 0, err)
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to store encrypted object")
 		return c.Status(500).XML(types.ErrorResponse{
 			Code:    "InternalError",
 			Message: "Failed to store object",
@@ -185,9 +382,16 @@ func (h *S3Handler) PutObject(c *fiber.Ctx) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		logging.Error().Int("status_code", resp.StatusCode).Msg("S3 storage failed")
-		// Forward the error response from MinIO directly
-		return c.Status(resp.StatusCode).Send(nil)
+		vault.RecordKeyUsage(kmsKeyARN, transitKey, vault.OperationEncrypt, 0, fmt.Errorf("backend returned status %d", resp.StatusCode))
+		logging.FromContext(c.UserContext()).Error().Int("status_code", resp.StatusCode).Msg("S3 storage failed")
+		// Forward the backend's error so clients see its code
+		return h.forwardResponse(c, resp)
+	}
+
+	h.invalidateObject(bucket, key)
+	vault.RecordKeyUsage(kmsKeyARN, transitKey, vault.OperationEncrypt, objectSize(headers, body), nil)
+	if h.replicator != nil {
+		h.replicator.Enqueue(bucket, key, kmsKeyARN)
 	}
 
 	// Copy response headers from MinIO
@@ -197,24 +401,76 @@ func (h *S3Handler) PutObject(c *fiber.Ctx) error {
 		}
 	}
 
-	// Ensure KMS encryption headers are set for client compatibility
-	c.Set("x-amz-server-side-encryption", "aws:kms")
-	c.Set("x-amz-server-side-encryption-aws-kms-key-id", kmsKeyARN)
+	// Report the plaintext MD5 rather than whatever the backend derived from the ciphertext
+	if objectETag, err := plaintextETag(body, headers); err != nil {
+		logging.FromContext(c.UserContext()).Warn().Err(err).Msg("Failed to compute object ETag")
+	} else {
+		if backendETag := resp.Header.Get("ETag"); backendETag != "" && etag.Normalize(backendETag) != etag.Normalize(objectETag) {
+			logging.FromContext(c.UserContext()).Debug().Str("backend_etag", backendETag).Msg("Backend ETag is not the plaintext MD5")
+		}
+		c.Set("ETag", objectETag)
+	}
+	if copySource := c.Get("X-Amz-Copy-Source"); copySource != "" {
+		h.recordCopy(c, bucket, key, sse, copySource)
+	} else {
+		h.recordUpload(c, bucket, key, sse, types.ObjectMetadata{
+			ContentLength: objectSize(headers, body),
+			ContentType:   c.Get("Content-Type"),
+			ETag:          string(c.Response().Header.Peek("ETag")),
+		})
+	}
+	h.publish(c, notify.Event{
+		Name:      notify.ObjectCreatedPut,
+		Bucket:    bucket,
+		Key:       key,
+		Size:      objectSize(headers, body),
+		ETag:      string(c.Response().Header.Peek("ETag")),
+		RequestID: resp.Header.Get("X-Amz-Request-Id"),
+	})
 
+	h.setEncryptionHeaders(c, sse, resp)
 	return c.SendStatus(resp.StatusCode)
 }
 
 // GetObject handles GET /:bucket/* - download object directly from Garage
 func (h *S3Handler) GetObject(c *fiber.Ctx) error {
 	bucket := c.Params("bucket")
-	key := c.Params("*")
+	key, path, err := objectKey(c)
+	if err != nil {
+		return c.Status(400).XML(invalidKeyError)
+	}
+	queryString := c.Request().URI().QueryString()
+	if s3.ObjectSubresource(string(queryString)) != "" {
+		return h.forwardObjectSubresource(c, path)
+	}
+	if uploadID := c.Query("uploadId"); uploadID != "" {
+		return h.ListParts(c, bucket, key, path, uploadID)
+	}
+	part, partErr := partNumber(queryString, c.Get("Range"))
+	if partErr != nil {
+		return c.Status(400).XML(partErr)
+	}
+	if errResp := checkSSEHeaders(c, s3.SSERead); errResp != nil {
+		return c.Status(400).XML(errResp)
+	}
 	headers := h.extractHeaders(c)
-	path := fmt.Sprintf("/%s/%s", bucket, key)
+	h.evaluateIfRange(c, bucket, key, headers)
+	unchanged := h.evaluateNotModified(c, bucket, key, headers)
+
+	// Revalidate cached copies with the backend so it still authorizes every request
+	var cached *cache.Entry
+	cacheable := h.objectCache != nil && isCacheableGet(c, queryString)
+	if cacheable {
+		if entry, ok := h.objectCache.Get(cache.ObjectKey(bucket, key)); ok {
+			cached = entry
+			headers["If-None-Match"] = []string{entry.ETag}
+		}
+	}
 
 	// Forward the GET request directly to Garage - no encryption/metadata needed
-	resp, err := h.s3Client.ForwardRequest("GET", path, nil, headers, c.Request().URI().QueryString())
+	resp, err := h.forward(c, "GET", path, nil, headers, queryString)
 	if err != nil {
-		logging.Error().Err(err).Msg("Failed to get object")
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to get object")
 		return c.Status(500).XML(types.ErrorResponse{
 			Code:    "InternalError",
 			Message: "Failed to get object",
@@ -222,21 +478,96 @@ func (h *S3Handler) GetObject(c *fiber.Ctx) error {
 	}
 	defer resp.Body.Close()
 
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		if status, err := h.authorizeTenantKey(c, cached.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"), false); err != nil {
+			return c.Status(status).XML(types.ErrorResponse{Code: tenantErrorCode(status), Message: err.Error()})
+		}
+		if h.encryptionContexts != nil {
+			if status, errResp := h.checkEncryptionContext(c, bucket, key); errResp != nil {
+				return c.Status(status).XML(errResp)
+			}
+		}
+		logging.FromContext(c.UserContext()).Debug().Msg("Serving object from cache")
+		h.setReplicationStatus(c, bucket, key)
+		return h.forwardRawResponse(c, http.StatusOK, cached.Header, cached.Body)
+	}
+
+	if status, err := h.authorizeTenantKey(c, resp.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"), false); err != nil {
+		return c.Status(status).XML(types.ErrorResponse{Code: tenantErrorCode(status), Message: err.Error()})
+	}
+	if h.handleLegacyObject(c, bucket, key, resp) {
+		return c.Status(fiber.StatusForbidden).XML(legacyDeniedError)
+	}
+	if h.encryptionContexts != nil && resp.StatusCode < 400 {
+		if status, errResp := h.checkEncryptionContext(c, bucket, key); errResp != nil {
+			return c.Status(status).XML(errResp)
+		}
+	}
+
+	// Cached copies are revalidated against the backend's own ETag
+	backendETag := resp.Header.Get("ETag")
+	backendLastModified := resp.Header.Get("Last-Modified")
+	if resp.StatusCode < 300 {
+		h.setReplicationStatus(c, bucket, key)
+	}
+	storedMeta := h.reconcileObjectHeaders(c.UserContext(), bucket, key, resp)
+	h.applyBucketHeaders(bucket, resp)
+	if unchanged != nil && resp.StatusCode < 300 && !metadataPredates(unchanged, backendLastModified) {
+		return h.sendNotModified(c, resp)
+	}
+	if !applyPartNumber(resp, part) {
+		return c.Status(fiber.StatusRequestedRangeNotSatisfiable).XML(invalidPartNumberError)
+	}
+	h.verifyChecksum(resp, storedMeta, backendLastModified, part)
+
+	if cacheable && resp.StatusCode == http.StatusOK {
+		h.recordDecryptUsage(resp)
+		return h.refuseCorrupted(c, h.forwardAndCacheResponse(c, bucket, key, backendETag, resp))
+	}
+
+	// The backend evaluated conditional headers the proxy could not
+	if resp.StatusCode == http.StatusNotModified {
+		return h.sendNotModified(c, resp)
+	}
+
+	h.recordDecryptUsage(resp)
+
 	// Forward the response directly from Garage
-	return h.forwardResponse(c, resp)
+	return h.refuseCorrupted(c, h.forwardResponse(c, resp))
+}
+
+// refuseCorrupted answers a GET whose body failed checksum verification, which
+// is read in full before anything is sent
+func (h *S3Handler) refuseCorrupted(c *fiber.Ctx, err error) error {
+	if !errors.Is(err, errChecksumMismatch) {
+		return err
+	}
+	logging.FromContext(c.UserContext()).Error().Err(err).Msg("Refusing to serve corrupted object")
+	return c.Status(500).XML(checksumMismatchError)
 }
 
 // HeadObject handles HEAD /:bucket/* - get object metadata
 func (h *S3Handler) HeadObject(c *fiber.Ctx) error {
 	bucket := c.Params("bucket")
-	key := c.Params("*")
+	key, path, err := objectKey(c)
+	if err != nil {
+		return c.SendStatus(400)
+	}
+	queryString := c.Request().URI().QueryString()
+	part, partErr := partNumber(queryString, c.Get("Range"))
+	if partErr != nil {
+		return c.SendStatus(400)
+	}
+	if checkSSEHeaders(c, s3.SSERead) != nil {
+		return c.SendStatus(400)
+	}
 	headers := h.extractHeaders(c)
-	path := fmt.Sprintf("/%s/%s", bucket, key)
+	unchanged := h.evaluateNotModified(c, bucket, key, headers)
 
 	// Forward the HEAD request directly to Garage and return the response
-	resp, err := h.s3Client.ForwardRequest("HEAD", path, nil, headers, c.Request().URI().QueryString())
+	resp, err := h.forward(c, "HEAD", path, nil, headers, queryString)
 	if err != nil {
-		logging.Error().Err(err).Msg("Failed to head object")
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to head object")
 		return c.Status(500).XML(types.ErrorResponse{
 			Code:    "InternalError",
 			Message: "Failed to head object",
@@ -244,46 +575,116 @@ func (h *S3Handler) HeadObject(c *fiber.Ctx) error {
 	}
 	defer resp.Body.Close()
 
-	// Forward the response directly - no metadata service needed for plain storage
+	backendLastModified := resp.Header.Get("Last-Modified")
+	h.reconcileObjectHeaders(c.UserContext(), bucket, key, resp)
+	h.applyBucketHeaders(bucket, resp)
+	if resp.StatusCode == http.StatusNotModified {
+		return h.sendNotModified(c, resp)
+	}
+
+	if status, err := h.authorizeTenantKey(c, resp.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"), false); err != nil {
+		return c.Status(status).Send(nil)
+	}
+	if h.handleLegacyObject(c, bucket, key, resp) {
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+	if h.encryptionContexts != nil && resp.StatusCode < 400 {
+		if status, errResp := h.checkEncryptionContext(c, bucket, key); errResp != nil {
+			return c.SendStatus(status)
+		}
+	}
+	if unchanged != nil && resp.StatusCode < 300 && !metadataPredates(unchanged, backendLastModified) {
+		return h.sendNotModified(c, resp)
+	}
+
+	if !applyPartNumber(resp, part) {
+		return c.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+	}
+	if resp.StatusCode < 300 {
+		h.setReplicationStatus(c, bucket, key)
+	}
+
+	// Forward the response directly; only the length and ETag come from stored metadata
 	return h.forwardResponse(c, resp)
 }
 
 // DeleteObject handles DELETE /:bucket/* - delete object and metadata
 func (h *S3Handler) DeleteObject(c *fiber.Ctx) error {
 	bucket := c.Params("bucket")
-	key := c.Params("*")
+	key, path, err := objectKey(c)
+	if err != nil {
+		return c.Status(400).XML(invalidKeyError)
+	}
+	if s3.ObjectSubresource(string(c.Request().URI().QueryString())) != "" {
+		return h.forwardObjectSubresource(c, path)
+	}
+	if uploadID := c.Query("uploadId"); uploadID != "" {
+		return h.AbortMultipartUpload(c, path, uploadID)
+	}
 	headers := h.extractHeaders(c)
 
+	// Keep a copy in the trash before the delete makes it irreversible
+	var trashEntry *trash.Entry
+	if h.keepsTrash(bucket, key) {
+		trashEntry, err = h.trash.Move(c.UserContext(), bucket, key)
+		if err != nil {
+			logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to move object to trash")
+			return c.Status(500).XML(types.ErrorResponse{
+				Code:    "InternalError",
+				Message: "Failed to move object to trash",
+			})
+		}
+	}
+
 	// Delete the main object
-	path := fmt.Sprintf("/%s/%s", bucket, key)
-	resp, err := h.s3Client.ForwardRequest("DELETE", path, nil, headers, c.Request().URI().QueryString())
+	resp, err := h.forward(c, "DELETE", path, nil, headers, c.Request().URI().QueryString())
 	if err != nil {
-		logging.Error().Err(err).Msg("Failed to delete object")
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to delete object")
+		h.discardTrash(c.UserContext(), trashEntry)
 	} else {
 		defer resp.Body.Close()
 		if resp.StatusCode >= 400 {
-			logging.Error().Int("status_code", resp.StatusCode).Msg("Failed to delete object")
+			logging.FromContext(c.UserContext()).Error().Int("status_code", resp.StatusCode).Msg("Failed to delete object")
+			h.discardTrash(c.UserContext(), trashEntry)
+		} else {
+			h.publish(c, notify.Event{
+				Name:      notify.ObjectRemovedDelete,
+				Bucket:    bucket,
+				Key:       key,
+				RequestID: resp.Header.Get("X-Amz-Request-Id"),
+			})
 		}
 	}
 
+	h.invalidateObject(bucket, key)
+
 	// Delete the metadata object
-	metadataKey := key + ".metadata"
-	metadataPath := fmt.Sprintf("/%s/%s", bucket, metadataKey)
-	metaResp, err := h.s3Client.ForwardRequest("DELETE", metadataPath, nil, headers, nil)
-	if err != nil {
-		logging.Error().Err(err).Msg("Failed to delete metadata")
-	} else {
-		defer metaResp.Body.Close()
-		if metaResp.StatusCode >= 400 {
-			logging.Error().Int("status_code", metaResp.StatusCode).Msg("Failed to delete metadata")
-		}
+	start := time.Now()
+	if err := h.deleteMetadata(c.UserContext(), bucket, key, headers); err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to delete metadata")
 	}
+	phases.FromContext(c.UserContext()).Since(phases.Backend, start)
 
 	return c.SendStatus(204)
 }
 
 // Helper methods
 
+// invalidKeyError is returned for object paths that are not valid URI encodings
+var invalidKeyError = types.ErrorResponse{
+	Code:    "InvalidURI",
+	Message: "Couldn't parse the specified URI.",
+}
+
+// objectKey returns the unescaped key of an object request, which the proxy
+// uses for metadata, caching and replication, and the backend path of the
+// request, which keeps the client's own encoding because it is signed
+func objectKey(c *fiber.Ctx) (key, path string, err error) {
+	rawKey := c.Params("*")
+	key, err = s3.DecodeKey(rawKey)
+	return key, fmt.Sprintf("/%s/%s", c.Params("bucket"), rawKey), err
+}
+
 func (h *S3Handler) extractHeaders(c *fiber.Ctx) http.Header {
 	headers := make(http.Header)
 	c.Request().Header.VisitAll(func(key, value []byte) {
@@ -309,18 +710,240 @@ func (h *S3Handler) extractHeaders(c *fiber.Ctx) http.Header {
 			}
 		}
 	})
-	return headers
+
+	// Continue the request's trace on the backend
+	return s3.InjectTraceContext(headers, tracing.SpanFromContext(c.UserContext()).Context())
 }
 
-func (h *S3Handler) getKMSKeyARN(c *fiber.Ctx) (string, error) {
-	kmsKeyARN := c.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id")
-	if kmsKeyARN == "" {
-		kmsKeyARN = c.Get("x-amz-server-side-encryption-aws-kms-key-id")
+// recordDecryptUsage counts a successful read of an SSE-KMS object against its key
+func (h *S3Handler) recordDecryptUsage(resp *http.Response) {
+	kmsKeyARN := resp.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id")
+	if kmsKeyARN == "" || resp.StatusCode >= 300 {
+		return
+	}
+	transitKey, _ := h.vaultClient.ARNToVaultKey(kmsKeyARN)
+	vault.RecordKeyUsage(kmsKeyARN, transitKey, vault.OperationDecrypt, resp.ContentLength, nil)
+}
+
+// objectSize returns the decoded object size of an upload
+func objectSize(headers http.Header, body *spool.Body) int64 {
+	if decoded := sigv4.HeaderValue(headers, "X-Amz-Decoded-Content-Length"); decoded != "" {
+		if size, err := strconv.ParseInt(decoded, 10, 64); err == nil {
+			return size
+		}
+	}
+	return body.Size()
+}
+
+// authorizeTenantKey checks that the request's tenant may encrypt (or decrypt)
+// with kmsKeyARN, returning the status to reject the request with otherwise.
+// Requests without a tenant, and unencrypted objects, are always allowed.
+func (h *S3Handler) authorizeTenantKey(c *fiber.Ctx, kmsKeyARN string, encrypt bool) (int, error) {
+	tenant := tenancy.FromContext(c.UserContext())
+	if tenant == nil || kmsKeyARN == "" {
+		return 0, nil
+	}
+
+	transitKey, err := h.vaultClient.ARNToVaultKey(kmsKeyARN)
+	if err == nil {
+		err = tenant.Authorize(c.Params("bucket"), kmsKeyARN, transitKey, encrypt)
+	}
+	switch {
+	case err == nil:
+		return 0, nil
+	case errors.Is(err, tenancy.ErrKeyNotAllowed):
+		logging.FromContext(c.UserContext()).Warn().Str("tenant", tenant.Name).Str("kms_arn", kmsKeyARN).Bool("encrypt", encrypt).Msg("KMS key denied for tenant")
+		return fiber.StatusForbidden, err
+	default:
+		logging.FromContext(c.UserContext()).Error().Err(err).Str("tenant", tenant.Name).Str("kms_arn", kmsKeyARN).Msg("Failed to authorize KMS key for tenant")
+		return fiber.StatusServiceUnavailable, fmt.Errorf("unable to authorize KMS key")
+	}
+}
+
+// uploadKey is the KMS key an upload is encrypted under
+type uploadKey struct {
+	kmsKeyARN  string
+	transitKey string
+	// explicit is whether the client sent the key rather than relying on the default key
+	explicit bool
+	// encryptionContext is the upload's x-amz-server-side-encryption-context header
+	encryptionContext string
+}
+
+// resolveUploadKey returns the KMS key of an upload to bucket, from its
+// SSE-KMS header or the default key, once the request's tenant may encrypt
+// with it and the bucket's binding allows it. Otherwise it returns the status
+// and error to reject the upload with.
+func (h *S3Handler) resolveUploadKey(c *fiber.Ctx, bucket string) (uploadKey, int, *types.ErrorResponse) {
+	if errResp := checkSSEHeaders(c, s3.SSEUpload); errResp != nil {
+		return uploadKey{}, 400, errResp
+	}
+	kmsKeyARN, err := h.getKMSKeyARN(c)
+	explicitKey := err == nil
+	if !explicitKey && h.defaultKMSKeyARN != "" {
+		kmsKeyARN, err = h.defaultKMSKeyARN, nil
+	}
+	if err != nil {
+		logging.FromContext(c.UserContext()).Warn().Err(err).Msg("Missing KMS key in request")
+		return uploadKey{}, 400, &types.ErrorResponse{
+			Code:    "InvalidRequest",
+			Message: err.Error(),
+		}
+	}
+
+	// Convert KMS ARN to Vault key for logging
+	vaultStart := time.Now()
+	vaultSpan := tracing.StartChild(c.UserContext(), "vault resolve transit key", tracing.KindInternal)
+	transitKey, err := h.vaultClient.ARNToVaultKey(kmsKeyARN)
+	vaultSpan.SetError(err)
+	vaultSpan.End()
+	phases.FromContext(c.UserContext()).Since(phases.Vault, vaultStart)
+	if err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Str("kms_arn", kmsKeyARN).Msg("Invalid KMS ARN format")
+		return uploadKey{}, 400, &types.ErrorResponse{
+			Code:    "InvalidRequest",
+			Message: err.Error(),
+		}
 	}
-	if kmsKeyARN == "" {
+
+	logging.FromContext(c.UserContext()).Info().
+		Str("kms_arn", kmsKeyARN).
+		Str("transit_key", transitKey).
+		Msg("Mapped KMS ARN to Vault transit key")
+
+	if status, err := h.authorizeTenantKey(c, kmsKeyARN, true); err != nil {
+		return uploadKey{}, status, &types.ErrorResponse{
+			Code:    tenantErrorCode(status),
+			Message: err.Error(),
+		}
+	}
+	// Copies are PUTs too, so their destination is checked here as well
+	if status, err := h.checkKMSBinding(c, bucket, kmsKeyARN); err != nil {
+		return uploadKey{}, status, &types.ErrorResponse{
+			Code:    tenantErrorCode(status),
+			Message: err.Error(),
+		}
+	}
+
+	return uploadKey{
+		kmsKeyARN:         kmsKeyARN,
+		transitKey:        transitKey,
+		explicit:          explicitKey,
+		encryptionContext: requestEncryptionContext(c),
+	}, 0, nil
+}
+
+// setEncryptionHeaders reports the KMS key of a stored upload for client
+// compatibility. Uploads relying on the default key report whatever the
+// backend applied.
+func (h *S3Handler) setEncryptionHeaders(c *fiber.Ctx, sse uploadKey, resp *http.Response) {
+	if sse.encryptionContext != "" {
+		c.Set("x-amz-server-side-encryption-context", sse.encryptionContext)
+	}
+	if sse.explicit {
+		c.Set("x-amz-server-side-encryption", "aws:kms")
+		c.Set("x-amz-server-side-encryption-aws-kms-key-id", sse.kmsKeyARN)
+	} else if resp.Header.Get("X-Amz-Server-Side-Encryption") == "" {
+		logging.FromContext(c.UserContext()).Warn().Msg("Backend stored an upload without server-side encryption; configure default SSE-KMS on the bucket")
+	}
+}
+
+// tenantErrorCode is the S3 error code for an authorizeTenantKey status
+func tenantErrorCode(status int) string {
+	if status == fiber.StatusForbidden {
+		return "AccessDenied"
+	}
+	return "ServiceUnavailable"
+}
+
+func (h *S3Handler) getKMSKeyARN(c *fiber.Ctx) (string, error) {
+	// Conflicting spellings of the header are rejected by checkSSEHeaders
+	sse, _ := s3.ParseSSEHeaders(&c.Request().Header)
+	if sse.KMSKeyID == "" {
 		return "", fmt.Errorf("KMS key ARN is required (x-amz-server-side-encryption-aws-kms-key-id header)")
 	}
-	return kmsKeyARN, nil
+	return sse.KMSKeyID, nil
+}
+
+// checkSSEHeaders returns the error to reject a request with when its
+// encryption headers cannot all be honored for operation, or nil. Otherwise
+// the backend would apply the headers the proxy did not check, such as a
+// second spelling of the KMS key header or an SSE-C key.
+func checkSSEHeaders(c *fiber.Ctx, operation s3.SSEOperation) *types.ErrorResponse {
+	sse, err := s3.ParseSSEHeaders(&c.Request().Header)
+	if err == nil {
+		err = sse.Check(operation)
+	}
+	var sseErr *s3.SSEError
+	if !errors.As(err, &sseErr) {
+		return nil
+	}
+	logging.FromContext(c.UserContext()).Info().Err(err).Msg("Rejected request with unsupported encryption headers")
+	return &types.ErrorResponse{Code: sseErr.Code, Message: sseErr.Message}
+}
+
+// readRequestBody returns the request body, spooling streamed bodies above the
+// configured threshold to disk instead of holding them in memory
+func (h *S3Handler) readRequestBody(c *fiber.Ctx) (*spool.Body, error) {
+	if stream := c.Context().RequestBodyStream(); stream != nil && h.spoolThreshold > 0 {
+		return spool.Read(stream, h.spoolThreshold, h.spoolDir)
+	}
+	return spool.FromBytes(c.Body()), nil
+}
+
+// readUploadBody reads the body of an upload, rejecting malformed aws-chunked
+// bodies before they reach the backend
+func (h *S3Handler) readUploadBody(c *fiber.Ctx, headers http.Header) (*spool.Body, *types.ErrorResponse) {
+	bodyStart := time.Now()
+	body, err := h.readRequestBody(c)
+	phases.FromContext(c.UserContext()).Since(phases.RequestBody, bodyStart)
+	if err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Msg("Failed to read request body")
+		return nil, &types.ErrorResponse{
+			Code:    "IncompleteBody",
+			Message: "Failed to read request body",
+		}
+	}
+
+	if sigv4.IsStreamingPayload(headers) && h.features.Enabled(features.ValidateChunkedBody) {
+		authStart := time.Now()
+		err := h.validateChunkedBody(body, headers)
+		phases.FromContext(c.UserContext()).Since(phases.Auth, authStart)
+		if err != nil {
+			body.Close()
+			logging.FromContext(c.UserContext()).Warn().Err(err).Msg("Invalid aws-chunked request body")
+			return nil, &types.ErrorResponse{
+				Code:    "IncompleteBody",
+				Message: err.Error(),
+			}
+		}
+	}
+	return body, nil
+}
+
+// validateChunkedBody decodes an aws-chunked body and checks it against x-amz-decoded-content-length.
+// Chunk signatures cannot be verified here because the proxy does not hold client secrets.
+func (h *S3Handler) validateChunkedBody(body *spool.Body, headers http.Header) error {
+	raw, err := body.Reader()
+	if err != nil {
+		return err
+	}
+	reader := sigv4.NewChunkedDecoder(raw)
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return fmt.Errorf("failed to decode aws-chunked body: %w", err)
+	}
+
+	if declared := sigv4.HeaderValue(headers, "X-Amz-Decoded-Content-Length"); declared != "" {
+		length, err := strconv.ParseInt(declared, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid x-amz-decoded-content-length: %s", declared)
+		}
+		if length != reader.DecodedLength() {
+			return fmt.Errorf("decoded body length %d does not match x-amz-decoded-content-length %d", reader.DecodedLength(), length)
+		}
+	}
+
+	return nil
 }
 
 func (h *S3Handler) setObjectHeaders(c *fiber.Ctx, metadata *types.ObjectMetadata, isEncrypted bool) {
@@ -328,13 +951,8 @@ func (h *S3Handler) setObjectHeaders(c *fiber.Ctx, metadata *types.ObjectMetadat
 	c.Set("Content-Length", strconv.FormatInt(metadata.ContentLength, 10))
 	c.Set("ETag", metadata.ETag)
 
-	// Parse and set Last-Modified header
-	if parsedTime, err := time.Parse("Mon, 02 Jan 2006 15:04:05 GMT", metadata.LastModified); err == nil {
-		c.Set("Last-Modified", parsedTime.UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"))
-	} else if parsedTime, err := time.Parse(time.RFC1123, metadata.LastModified); err == nil {
-		c.Set("Last-Modified", parsedTime.UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"))
-	} else {
-		c.Set("Last-Modified", metadata.LastModified)
+	if lastModified := types.HTTPLastModified(metadata.LastModified); lastModified != "" {
+		c.Set("Last-Modified", lastModified)
 	}
 
 	if isEncrypted {
@@ -343,6 +961,67 @@ func (h *S3Handler) setObjectHeaders(c *fiber.Ctx, metadata *types.ObjectMetadat
 	}
 }
 
+// reconcileObjectHeaders replaces the backend's ETag, Last-Modified,
+// Content-Length and Content-Range size of a HEAD or GET response with the
+// values from stored metadata, adds the caching headers it recorded, so
+// clients never see values derived from the ciphertext and HEAD, GET and
+// listings agree. The metadata is only as current as its sidecar, which
+// WithMetadataSidecars rewrites on every write through the proxy. Objects
+// without metadata keep the backend's headers. The metadata is returned, or
+// nil without any.
+func (h *S3Handler) reconcileObjectHeaders(ctx context.Context, bucket, key string, resp *http.Response) *types.ObjectMetadata {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		resp.Header.Set("Accept-Ranges", "bytes")
+	case http.StatusNotModified:
+	default:
+		return nil
+	}
+
+	storedMeta, err := h.storedMetadata(ctx, bucket, key)
+	if err != nil {
+		return nil
+	}
+	if storedMeta.ETag != "" {
+		resp.Header.Set("ETag", storedMeta.ETag)
+	}
+	if lastModified := types.HTTPLastModified(storedMeta.LastModified); lastModified != "" {
+		resp.Header.Set("Last-Modified", lastModified)
+	}
+	storedMeta.WriteHTTPHeaders(resp.Header)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		resp.Header.Set("Content-Length", strconv.FormatInt(storedMeta.ContentLength, 10))
+	case http.StatusPartialContent:
+		if contentRange := resp.Header.Get("Content-Range"); contentRange != "" {
+			resp.Header.Set("Content-Range", withContentRangeSize(contentRange, storedMeta.ContentLength))
+		}
+	}
+	return storedMeta
+}
+
+// plaintextETag returns the ETag of an uploaded body, decoding aws-chunked payloads
+func plaintextETag(body *spool.Body, headers http.Header) (string, error) {
+	raw, err := body.Reader()
+	if err != nil {
+		return "", err
+	}
+	if sigv4.IsStreamingPayload(headers) {
+		raw = sigv4.NewChunkedDecoder(raw)
+	}
+	return etag.Compute(raw)
+}
+
+// withContentRangeSize replaces the complete length of a "bytes first-last/size"
+// Content-Range value, leaving values it can't parse unchanged
+func withContentRangeSize(contentRange string, size int64) string {
+	slash := strings.LastIndexByte(contentRange, '/')
+	if !strings.HasPrefix(contentRange, "bytes ") || slash < 0 {
+		return contentRange
+	}
+	return contentRange[:slash+1] + strconv.FormatInt(size, 10)
+}
+
 func (h *S3Handler) copyResponseHeaders(c *fiber.Ctx, headers http.Header) {
 	for key, values := range headers {
 		if len(values) > 0 {
@@ -351,7 +1030,42 @@ func (h *S3Handler) copyResponseHeaders(c *fiber.Ctx, headers http.Header) {
 	}
 }
 
+// forward sends a request to the backend, timing it as the backend phase
+func (h *S3Handler) forward(c *fiber.Ctx, method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
+	defer phases.FromContext(c.UserContext()).Since(phases.Backend, time.Now())
+	return h.s3Client.ForwardRequest(c.UserContext(), method, path, body, headers, queryString)
+}
+
+// forwardObjectSubresource relays a request for an object sub-resource such as
+// ?tagging unchanged. It carries no object data, so there is nothing to
+// encrypt, reconcile with metadata or invalidate.
+func (h *S3Handler) forwardObjectSubresource(c *fiber.Ctx, path string) error {
+	var body io.Reader
+	if len(c.Body()) > 0 {
+		body = bytes.NewReader(c.Body())
+	}
+	resp, err := h.forward(c, c.Method(), path, body, h.extractHeaders(c), c.Request().URI().QueryString())
+	if err != nil {
+		logging.FromContext(c.UserContext()).Error().Err(err).Str("path", path).Msg("Failed to forward object sub-resource request")
+		return c.Status(500).XML(types.ErrorResponse{
+			Code:    "InternalError",
+			Message: "Failed to forward request",
+		})
+	}
+	defer resp.Body.Close()
+
+	return h.forwardResponse(c, resp)
+}
+
 func (h *S3Handler) forwardResponse(c *fiber.Ctx, resp *http.Response) error {
+	defer phases.FromContext(c.UserContext()).Since(phases.Serialization, time.Now())
+
+	// The body is read first, so a failed read leaves the response untouched
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
 	// Copy response headers
 	for key, values := range resp.Header {
 		for _, value := range values {
@@ -361,12 +1075,91 @@ func (h *S3Handler) forwardResponse(c *fiber.Ctx, resp *http.Response) error {
 
 	// Set status and return body
 	c.Status(resp.StatusCode)
+	return c.Send(body)
+}
+
+// forwardAndCacheResponse forwards a successful GET response and caches small
+// bodies under the backend's ETag, which revalidation sends back to the backend
+func (h *S3Handler) forwardAndCacheResponse(c *fiber.Ctx, bucket, key, backendETag string, resp *http.Response) error {
+	defer phases.FromContext(c.UserContext()).Since(phases.Serialization, time.Now())
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return err
 	}
 
-	return c.Send(body)
+	if backendETag != "" && int64(len(body)) <= h.objectCacheMaxEntry {
+		h.objectCache.Set(cache.ObjectKey(bucket, key), &cache.Entry{
+			ETag:   backendETag,
+			Header: resp.Header.Clone(),
+			Body:   body,
+		})
+	}
+
+	return h.forwardRawResponse(c, resp.StatusCode, resp.Header, body)
+}
+
+// notModifiedHeaders are the only representation headers a 304 response may carry (RFC 9110 15.4.5)
+var notModifiedHeaders = []string{"ETag", "Last-Modified", "Cache-Control", "Expires", "Vary", "Content-Location", "Date"}
+
+// sendNotModified answers a conditional request with 304 and without a body or
+// body-describing headers. The body of an object found unchanged from its
+// metadata is left unread.
+func (h *S3Handler) sendNotModified(c *fiber.Ctx, resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotModified {
+		_, _ = io.Copy(io.Discard, resp.Body)
+	}
+
+	for _, name := range notModifiedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			c.Set(name, value)
+		}
+	}
+
+	notModifiedTotal.Inc(c.Method())
+	return c.SendStatus(http.StatusNotModified)
+}
+
+// metadataInvalidator is implemented by metadata services that cache results
+type metadataInvalidator interface {
+	Invalidate(bucket, key string)
+}
+
+// invalidateObject drops any cached copy of an object after it was modified
+func (h *S3Handler) invalidateObject(bucket, key string) {
+	if h.objectCache != nil {
+		h.objectCache.Delete(cache.ObjectKey(bucket, key))
+	}
+	if invalidator, ok := h.metadataService.(metadataInvalidator); ok {
+		invalidator.Invalidate(bucket, key)
+	}
+}
+
+// isCacheableGet reports whether a GET can be answered from the object cache.
+// Ranged, conditional and sub-resource requests always go to the backend.
+func isCacheableGet(c *fiber.Ctx, queryString []byte) bool {
+	for _, header := range []string{"Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"} {
+		if c.Get(header) != "" {
+			return false
+		}
+	}
+
+	return !hasSubresource(queryString)
+}
+
+// hasSubresource reports whether a query string selects a sub-resource or
+// option. Presigned URL authentication parameters do not change the request.
+func hasSubresource(queryString []byte) bool {
+	query, err := url.ParseQuery(string(queryString))
+	if err != nil {
+		return true
+	}
+	for name := range query {
+		if !strings.HasPrefix(strings.ToLower(name), "x-amz-") && name != "x-id" {
+			return true
+		}
+	}
+	return false
 }
 
 func (h *S3Handler) forwardRawResponse(c *fiber.Ctx, statusCode int, headers http.Header, body []byte) error {
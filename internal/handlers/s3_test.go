@@ -0,0 +1,1375 @@
+package handlers
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/bucketheaders"
+	"s3-vault-proxy/internal/etag"
+	"s3-vault-proxy/internal/features"
+	"s3-vault-proxy/internal/kmsbindings"
+	"s3-vault-proxy/internal/locations"
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/multipart"
+	"s3-vault-proxy/internal/notify"
+	"s3-vault-proxy/internal/provision"
+	"s3-vault-proxy/internal/sigv4"
+	"s3-vault-proxy/internal/trash"
+	"s3-vault-proxy/pkg/types"
+	"s3-vault-proxy/tests/mocks"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// clientAuthorization is a client's signature, which the backend checks and
+// the proxy forwards
+const clientAuthorization = "AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc"
+
+func setupObjectTest(s3Client *mocks.S3Client, metadataService *mocks.MetadataService) *fiber.App {
+	handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), metadataService)
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Put("/:bucket/*", handler.PutObject)
+	app.Head("/:bucket/*", handler.HeadObject)
+	app.Get("/:bucket/*", handler.GetObject)
+	return app
+}
+
+func TestObjectLengthFromMetadata(t *testing.T) {
+	t.Run("HEAD reports the plaintext length", func(t *testing.T) {
+		s3Client := mocks.NewMockS3Client()
+		s3Client.SetResponse("HEAD", "/bucket/key", http.StatusOK, "", map[string]string{"Content-Length": "1052"})
+		metadataService := mocks.NewMockMetadataService()
+		metadataService.On("Get", "bucket", "key", mock.Anything).Return(&types.ObjectMetadata{ContentLength: 1000, ETag: `"plaintext"`, LastModified: "2024-03-05T13:30:00Z"}, nil)
+
+		resp, err := setupObjectTest(s3Client, metadataService).Test(httptest.NewRequest("HEAD", "/bucket/key", nil))
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "1000", resp.Header.Get("Content-Length"))
+		assert.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+		assert.Equal(t, `"plaintext"`, resp.Header.Get("ETag"))
+		assert.Equal(t, "Tue, 05 Mar 2024 13:30:00 GMT", resp.Header.Get("Last-Modified"))
+	})
+
+	t.Run("HEAD after overwriting a migrated object reports the new object", func(t *testing.T) {
+		s3Client := mocks.NewMockS3Client()
+		s3Client.SetResponse("PUT", "/bucket/key", http.StatusOK, "", map[string]string{"ETag": `"ciphertext"`})
+		s3Client.SetResponse("HEAD", "/bucket/key", http.StatusOK, "", map[string]string{"Content-Length": "57"})
+		store := &memoryMetadata{}
+		migrated := types.ObjectMetadata{ContentLength: 1000, ETag: `"migrated"`, LastModified: "2024-03-05T13:30:00Z"}
+		require.NoError(t, store.Store(context.Background(), "bucket", "key", &migrated, nil))
+		handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), store, WithMetadataSidecars(store))
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		app.Put("/:bucket/*", handler.PutObject)
+		app.Head("/:bucket/*", handler.HeadObject)
+
+		req := httptest.NewRequest("PUT", "/bucket/key", strings.NewReader("hello"))
+		req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", "arn:aws:kms:us-east-1:123456789012:key/test")
+		req.Header.Set("Authorization", clientAuthorization)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		req = httptest.NewRequest("HEAD", "/bucket/key", nil)
+		req.Header.Set("Authorization", clientAuthorization)
+		resp, err = app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "5", resp.Header.Get("Content-Length"))
+		assert.Equal(t, `"5d41402abc4b2a76b9719d911017c592"`, resp.Header.Get("ETag"))
+		assert.NotEqual(t, "Tue, 05 Mar 2024 13:30:00 GMT", resp.Header.Get("Last-Modified"))
+	})
+
+	t.Run("ranged GET reports the plaintext size", func(t *testing.T) {
+		s3Client := mocks.NewMockS3Client()
+		s3Client.SetResponse("GET", "/bucket/key", http.StatusPartialContent, "0123456789", map[string]string{"Content-Range": "bytes 0-9/1052"})
+		metadataService := mocks.NewMockMetadataService()
+		metadataService.On("Get", "bucket", "key", mock.Anything).Return(&types.ObjectMetadata{ContentLength: 1000}, nil)
+
+		req := httptest.NewRequest("GET", "/bucket/key", nil)
+		req.Header.Set("Range", "bytes=0-9")
+		resp, err := setupObjectTest(s3Client, metadataService).Test(req)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		assert.Equal(t, "bytes 0-9/1000", resp.Header.Get("Content-Range"))
+		assert.Equal(t, "10", resp.Header.Get("Content-Length"))
+	})
+
+	t.Run("objects without metadata keep the backend length", func(t *testing.T) {
+		s3Client := mocks.NewMockS3Client()
+		s3Client.SetResponse("HEAD", "/bucket/key", http.StatusOK, "", map[string]string{"Content-Length": "1052"})
+		metadataService := mocks.NewMockMetadataService()
+		metadataService.On("Get", "bucket", "key", mock.Anything).Return((*types.ObjectMetadata)(nil), metadata.ErrNotFound)
+
+		resp, err := setupObjectTest(s3Client, metadataService).Test(httptest.NewRequest("HEAD", "/bucket/key", nil))
+		require.NoError(t, err)
+
+		assert.Equal(t, "1052", resp.Header.Get("Content-Length"))
+	})
+}
+
+func TestObjectHTTPHeadersFromMetadata(t *testing.T) {
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("HEAD", "/bucket/key", http.StatusOK, "", map[string]string{"Content-Length": "1052", "Cache-Control": "no-store"})
+	metadataService := mocks.NewMockMetadataService()
+	metadataService.On("Get", "bucket", "key", mock.Anything).Return(&types.ObjectMetadata{
+		ContentLength:      1000,
+		CacheControl:       "public, max-age=86400",
+		ContentDisposition: "inline",
+		Expires:            "Thu, 01 Dec 2044 16:00:00 GMT",
+	}, nil)
+
+	resp, err := setupObjectTest(s3Client, metadataService).Test(httptest.NewRequest("HEAD", "/bucket/key", nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, "public, max-age=86400", resp.Header.Get("Cache-Control"))
+	assert.Equal(t, "inline", resp.Header.Get("Content-Disposition"))
+	assert.Equal(t, "Thu, 01 Dec 2044 16:00:00 GMT", resp.Header.Get("Expires"))
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+}
+
+func TestPutObjectReportsPlaintextETag(t *testing.T) {
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("PUT", "/bucket/key", http.StatusOK, "", map[string]string{"ETag": `"ciphertext"`})
+
+	req := httptest.NewRequest("PUT", "/bucket/key", strings.NewReader("hello"))
+	req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", "arn:aws:kms:us-east-1:123456789012:key/test")
+	resp, err := setupObjectTest(s3Client, mocks.NewMockMetadataService()).Test(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"5d41402abc4b2a76b9719d911017c592"`, resp.Header.Get("ETag"))
+}
+
+func TestPutObjectDefaultKMSKey(t *testing.T) {
+	const defaultKey = "arn:aws:kms:us-east-1:123456789012:key/default"
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("PUT", "/bucket/key", http.StatusOK, "", map[string]string{"X-Amz-Server-Side-Encryption": "aws:kms"})
+
+	resp, err := setupObjectTest(s3Client, mocks.NewMockMetadataService()).Test(httptest.NewRequest("PUT", "/bucket/key", strings.NewReader("hello")))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "uploads need a key header without a default")
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Put("/:bucket/*", NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService(), WithDefaultKMSKey(defaultKey)).PutObject)
+	resp, err = app.Test(httptest.NewRequest("PUT", "/bucket/key", strings.NewReader("hello")))
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "aws:kms", resp.Header.Get("X-Amz-Server-Side-Encryption"))
+	assert.Empty(t, resp.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"), "the key the backend applied is not known")
+}
+
+func TestBucketProvisioning(t *testing.T) {
+	const kmsKey = "arn:aws:kms:us-east-1:123456789012:key/ci"
+	const noSuchBucket = "<Error><Code>NoSuchBucket</Code></Error>"
+	missing := func() *http.Response {
+		return &http.Response{StatusCode: http.StatusNotFound, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(noSuchBucket))}
+	}
+	s3Client := mocks.NewMockS3Client()
+	s3Client.On("ForwardRequest", "PUT", "/ci-main/artifact", mock.Anything, mock.Anything, mock.Anything).Return(missing(), nil).Once()
+	s3Client.SetResponse("HEAD", "/ci-main", http.StatusNotFound, "", nil)
+	s3Client.SetResponse("PUT", "/ci-main", http.StatusOK, "", nil)
+	s3Client.SetResponse("PUT", "/ci-main/artifact", http.StatusOK, "", nil)
+	s3Client.SetResponse("PUT", "/ci-broken/artifact", http.StatusNotFound, noSuchBucket, nil)
+	s3Client.SetResponse("HEAD", "/ci-broken", http.StatusInternalServerError, "", nil)
+	s3Client.SetResponse("PUT", "/ci-forged/artifact", http.StatusForbidden, "<Error><Code>SignatureDoesNotMatch</Code></Error>", nil)
+	s3Client.SetResponse("PUT", "/backups/artifact", http.StatusNotFound, noSuchBucket, nil)
+	s3Client.SetResponse("GET", "/config/.s3-vault-proxy/kms-bindings/ci-main.json", http.StatusNotFound, "", nil)
+	s3Client.SetResponse("GET", "/config/.s3-vault-proxy/kms-bindings/ci-broken.json", http.StatusNotFound, "", nil)
+	s3Client.SetResponse("GET", "/config/.s3-vault-proxy/kms-bindings/ci-forged.json", http.StatusNotFound, "", nil)
+	s3Client.SetResponse("GET", "/config/.s3-vault-proxy/kms-bindings/backups.json", http.StatusNotFound, "", nil)
+	s3Client.SetResponse("PUT", "/config/.s3-vault-proxy/kms-bindings/ci-main.json", http.StatusOK, "", nil)
+	s3Client.SetResponse("PUT", "/config/.s3-vault-proxy/locations/ci-main.json", http.StatusOK, "", nil)
+	handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService(),
+		WithRegion("eu-west-1"),
+		WithKMSBindings(kmsbindings.NewStore(s3Client, "config")),
+		WithBucketLocations(locations.NewStore(s3Client, "config")),
+		WithBucketProvisioning(provision.NewProvisioner(s3Client, []string{"ci-*"})))
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Put("/:bucket/*", handler.PutObject)
+
+	put := func(bucket string) int {
+		req := httptest.NewRequest("PUT", "/"+bucket+"/artifact", strings.NewReader("hello"))
+		req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", kmsKey)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+	calls := func(method, path string) int {
+		count := 0
+		for _, call := range s3Client.Calls {
+			if call.Arguments.String(0) == method && call.Arguments.String(1) == path {
+				count++
+			}
+		}
+		return count
+	}
+
+	assert.Equal(t, http.StatusOK, put("ci-main"))
+	assert.Equal(t, 1, calls("HEAD", "/ci-main"))
+	assert.Equal(t, 2, calls("PUT", "/ci-main/artifact"), "the upload is sent again once the bucket exists")
+	s3Client.AssertCalled(t, "ForwardRequest", "PUT", "/ci-main", mock.Anything, mock.Anything, []byte(nil))
+	s3Client.AssertCalled(t, "ForwardRequest", "PUT", "/ci-main", mock.Anything, mock.Anything, []byte("encryption"))
+	s3Client.AssertCalled(t, "ForwardRequest", "PUT", "/config/.s3-vault-proxy/kms-bindings/ci-main.json", mock.Anything, mock.Anything, mock.Anything)
+	s3Client.AssertCalled(t, "ForwardRequest", "PUT", "/config/.s3-vault-proxy/locations/ci-main.json", mock.Anything, mock.Anything, mock.Anything)
+
+	assert.Equal(t, http.StatusOK, put("ci-main"))
+	assert.Equal(t, 1, calls("HEAD", "/ci-main"), "existing buckets are not checked")
+	assert.Equal(t, 2, calls("PUT", "/ci-main"), "buckets are created once")
+
+	assert.Equal(t, http.StatusServiceUnavailable, put("ci-broken"))
+	assert.Equal(t, 1, calls("PUT", "/ci-broken/artifact"))
+
+	assert.Equal(t, http.StatusForbidden, put("ci-forged"), "uploads the backend refuses create nothing")
+	assert.Zero(t, calls("HEAD", "/ci-forged"))
+
+	assert.Equal(t, http.StatusNotFound, put("backups"), "other buckets are never created")
+	assert.Zero(t, calls("HEAD", "/backups"))
+}
+
+func TestPutObjectKMSBinding(t *testing.T) {
+	const (
+		boundKey = "arn:aws:kms:us-east-1:123456789012:key/bound"
+		otherKey = "arn:aws:kms:us-east-1:123456789012:key/other"
+	)
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("GET", "/config/.s3-vault-proxy/kms-bindings/bucket.json", http.StatusOK, `{"kms_keys":["`+boundKey+`"]}`, nil)
+	s3Client.SetResponse("PUT", "/bucket/key", http.StatusOK, "", nil)
+	handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService(),
+		WithDefaultKMSKey(otherKey), WithKMSBindings(kmsbindings.NewStore(s3Client, "config")))
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Put("/:bucket/*", handler.PutObject)
+
+	put := func(kmsKeyARN, copySource string) int {
+		req := httptest.NewRequest("PUT", "/bucket/key", strings.NewReader("hello"))
+		if kmsKeyARN != "" {
+			req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", kmsKeyARN)
+		}
+		if copySource != "" {
+			req.Header.Set("X-Amz-Copy-Source", copySource)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, put(boundKey, ""))
+	assert.Equal(t, http.StatusForbidden, put(otherKey, ""))
+	assert.Equal(t, http.StatusOK, put(boundKey, "/source/key"))
+	assert.Equal(t, http.StatusForbidden, put(otherKey, "/source/key"), "copy destinations are checked")
+	assert.Equal(t, http.StatusForbidden, put("", "/source/key"), "the default key is checked too")
+}
+
+func TestObjectEncryptionHeaders(t *testing.T) {
+	const (
+		defaultKey = "arn:aws:kms:us-east-1:123456789012:key/default"
+		clientKey  = "arn:aws:kms:us-east-1:123456789012:key/client"
+	)
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("PUT", "/bucket/key", http.StatusOK, "", nil)
+	handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService(), WithDefaultKMSKey(defaultKey))
+	// Header names reach the handlers as clients spelled them
+	app := fiber.New(fiber.Config{DisableStartupMessage: true, DisableHeaderNormalizing: true})
+	app.Put("/:bucket/*", handler.PutObject)
+	app.Get("/:bucket/*", handler.GetObject)
+
+	request := func(method string, headers map[string]string) (*http.Response, string) {
+		req := httptest.NewRequest(method, "/bucket/key", strings.NewReader("hello"))
+		for name, value := range headers {
+			req.Header[name] = []string{value}
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	resp, _ := request("PUT", map[string]string{"X-AMZ-SERVER-SIDE-ENCRYPTION-AWS-KMS-KEY-ID": clientKey})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, clientKey, resp.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"), "any spelling of the header names the key")
+
+	resp, body := request("PUT", map[string]string{
+		"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": clientKey,
+		"x-amz-server-side-encryption-aws-kms-key-id": defaultKey,
+	})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "<Code>InvalidArgument</Code>")
+
+	resp, _ = request("PUT", map[string]string{"X-Amz-Server-Side-Encryption": "AES256"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "only SSE-KMS is stored")
+	resp, _ = request("PUT", map[string]string{
+		"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id":                 clientKey,
+		"X-Amz-Copy-Source":                                           "/source/key",
+		"X-Amz-Copy-Source-Server-Side-Encryption-Customer-Algorithm": "AES256",
+	})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "copy sources are never SSE-C")
+
+	resp, body = request("GET", map[string]string{"X-Amz-Server-Side-Encryption-Customer-Algorithm": "AES256"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "<Code>InvalidRequest</Code>")
+}
+
+// memoryMetadata is a metadata store holding records in memory. Like the
+// operator-signed store, it refuses requests signed by a client, whose
+// signature covers the object rather than its metadata object.
+type memoryMetadata struct {
+	mu      sync.Mutex
+	records map[string]types.ObjectMetadata
+}
+
+// clientSigned fails requests carrying a client's credentials
+func clientSigned(headers http.Header) error {
+	if sigv4.HeaderValue(headers, "Authorization") != "" {
+		return errors.New("access denied reading metadata: HTTP 403")
+	}
+	return nil
+}
+
+func (m *memoryMetadata) Store(ctx context.Context, bucket, key string, meta *types.ObjectMetadata, headers http.Header) error {
+	if err := clientSigned(headers); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.records == nil {
+		m.records = make(map[string]types.ObjectMetadata)
+	}
+	m.records[bucket+"/"+key] = *meta
+	return nil
+}
+
+func (m *memoryMetadata) Get(ctx context.Context, bucket, key string, headers http.Header) (*types.ObjectMetadata, error) {
+	if err := clientSigned(headers); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	meta, ok := m.records[bucket+"/"+key]
+	if !ok {
+		return nil, metadata.ErrNotFound
+	}
+	return &meta, nil
+}
+
+func (m *memoryMetadata) Exists(ctx context.Context, bucket, key string, headers http.Header) bool {
+	_, err := m.Get(ctx, bucket, key, headers)
+	return err == nil
+}
+
+func (m *memoryMetadata) Delete(ctx context.Context, bucket, key string, headers http.Header) error {
+	if err := clientSigned(headers); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, bucket+"/"+key)
+	return nil
+}
+
+func TestUploadsRewriteSidecars(t *testing.T) {
+	const kmsKeyARN = "arn:aws:kms:us-east-1:123456789012:key/test"
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("PUT", "/bucket/key", http.StatusOK, "", map[string]string{"ETag": `"ciphertext"`})
+	s3Client.SetResponse("PUT", "/bucket/copy", http.StatusOK, "", nil)
+	store := &memoryMetadata{}
+	stale := types.ObjectMetadata{ContentLength: 100, ETag: `"migrated"`, KMSKeyARN: "arn:aws:kms:us-east-1:123456789012:key/old"}
+	require.NoError(t, store.Store(context.Background(), "bucket", "key", &stale, nil))
+	require.NoError(t, store.Store(context.Background(), "bucket", "copy", &stale, nil))
+	handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), store, WithMetadataSidecars(store))
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Put("/:bucket/*", handler.PutObject)
+
+	put := func(target, copySource string) {
+		req := httptest.NewRequest("PUT", target, strings.NewReader("hello"))
+		req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", kmsKeyARN)
+		req.Header.Set("Authorization", clientAuthorization)
+		if copySource != "" {
+			req.Header.Set("X-Amz-Copy-Source", copySource)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	put("/bucket/key", "")
+	meta, err := store.Get(context.Background(), "bucket", "key", nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), meta.ContentLength, "overwrites replace the previous object's sidecar")
+	assert.Equal(t, `"5d41402abc4b2a76b9719d911017c592"`, meta.ETag)
+	assert.Equal(t, kmsKeyARN, meta.KMSKeyARN)
+
+	put("/bucket/copy", "/other/untracked")
+	_, err = store.Get(context.Background(), "bucket", "copy", nil)
+	assert.ErrorIs(t, err, metadata.ErrNotFound, "copies of objects without a sidecar remove the destination's")
+}
+
+func TestEncryptionContext(t *testing.T) {
+	const (
+		kmsKeyARN = "arn:aws:kms:us-east-1:123456789012:key/test"
+		project   = "eyJwcm9qZWN0IjoiYSIsInRlYW0iOiJiIn0=" // {"project":"a","team":"b"}
+		reordered = "eyJ0ZWFtIjoiYiIsInByb2plY3QiOiJhIn0=" // {"team":"b","project":"a"}
+		other     = "eyJwcm9qZWN0IjoiYiJ9"                 // {"project":"b"}
+	)
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("PUT", "/bucket/key", http.StatusOK, "", nil)
+	s3Client.SetResponse("PUT", "/bucket/copy", http.StatusOK, "", nil)
+	s3Client.SetResponse("GET", "/bucket/key", http.StatusOK, "hello", nil)
+	s3Client.SetResponse("POST", "/bucket/key", http.StatusOK, `<CompleteMultipartUploadResult><ETag>"backend"</ETag></CompleteMultipartUploadResult>`, nil)
+	store := &memoryMetadata{}
+	handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), store, WithMetadataSidecars(store), WithEncryptionContexts(store))
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Put("/:bucket/*", handler.PutObject)
+	app.Post("/:bucket/*", handler.PostObject)
+	app.Get("/:bucket/*", handler.GetObject)
+
+	request := func(method, path, encryptionContext string, headers ...string) *http.Response {
+		req := httptest.NewRequest(method, path, strings.NewReader("hello"))
+		req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", kmsKeyARN)
+		req.Header.Set("Authorization", clientAuthorization)
+		if encryptionContext != "" {
+			req.Header.Set("X-Amz-Server-Side-Encryption-Context", encryptionContext)
+		}
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := request("PUT", "/bucket/key", project)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, project, resp.Header.Get("X-Amz-Server-Side-Encryption-Context"))
+	stored, err := store.Get(context.Background(), "bucket", "key", nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"project": "a", "team": "b"}, stored.EncryptionContext)
+	assert.Equal(t, int64(5), stored.ContentLength)
+	assert.Equal(t, kmsKeyARN, stored.KMSKeyARN)
+
+	assert.Equal(t, http.StatusForbidden, request("GET", "/bucket/key", "").StatusCode, "reads must repeat the context")
+	assert.Equal(t, http.StatusForbidden, request("GET", "/bucket/key", other).StatusCode)
+	assert.Equal(t, http.StatusOK, request("GET", "/bucket/key", reordered).StatusCode, "contexts compare as maps")
+
+	resp = request("PUT", "/bucket/copy", other, "X-Amz-Copy-Source", "/bucket/key")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	copied, err := store.Get(context.Background(), "bucket", "copy", nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"project": "b"}, copied.EncryptionContext, "copies take the context of the copy request")
+	assert.Equal(t, int64(5), copied.ContentLength)
+
+	assert.Equal(t, http.StatusBadRequest, request("PUT", "/bucket/key", "not-base64!").StatusCode)
+	require.Equal(t, http.StatusOK, request("PUT", "/bucket/key", "").StatusCode)
+	assert.Equal(t, http.StatusOK, request("GET", "/bucket/key", "").StatusCode, "overwrites drop the previous context")
+
+	require.Equal(t, http.StatusOK, request("PUT", "/bucket/key", project).StatusCode)
+	handler.uploads.Start(multipart.Upload{ID: "up-1", Bucket: "bucket", Key: "key", KMSKeyARN: kmsKeyARN, EncryptionContext: other})
+	resp = request("POST", "/bucket/key?uploadId=up-1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, http.StatusOK, request("GET", "/bucket/key", "").StatusCode,
+		"completions without a plaintext size drop the previous context")
+}
+
+// copyRecorder is a backend client recording the headers of the copies it is sent
+type copyRecorder chan http.Header
+
+func (r copyRecorder) ForwardRequest(ctx context.Context, method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
+	r <- headers
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("<CopyObjectResult/>"))}, nil
+}
+
+func (r copyRecorder) HeadObject(ctx context.Context, bucket, key string, headers http.Header) (*http.Response, error) {
+	return nil, errors.New("unexpected HEAD")
+}
+
+// multipartBackend is a backend client answering the requests of a multipart upload
+type multipartBackend struct{}
+
+func (multipartBackend) ForwardRequest(ctx context.Context, method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}
+	switch {
+	case method == "POST" && strings.Contains(string(queryString), "uploads"):
+		resp.Body = io.NopCloser(strings.NewReader(`<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><UploadId>up-1</UploadId></InitiateMultipartUploadResult>`))
+	case method == "POST":
+		resp.Body = io.NopCloser(strings.NewReader(`<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><ETag>&#34;backend-2&#34;</ETag></CompleteMultipartUploadResult>`))
+	case method == "PUT":
+		resp.Header.Set("ETag", `"backend-part"`)
+	case method == "DELETE":
+		resp.StatusCode = http.StatusNoContent
+	case method == "GET" && strings.Contains(string(queryString), "uploadId"):
+		resp.Body = io.NopCloser(strings.NewReader(`<ListPartsResult><Bucket>bucket</Bucket><Key>key</Key><UploadId>up-1</UploadId>` +
+			`<Part><PartNumber>1</PartNumber><ETag>&#34;backend-part&#34;</ETag><Size>22</Size></Part></ListPartsResult>`))
+	case method == "GET" && strings.Contains(string(queryString), "uploads"):
+		resp.Body = io.NopCloser(strings.NewReader(`<ListMultipartUploadsResult><Bucket>bucket</Bucket>` +
+			`<Upload><Key>key</Key><UploadId>up-1</UploadId></Upload></ListMultipartUploadsResult>`))
+	}
+	return resp, nil
+}
+
+func (multipartBackend) HeadObject(ctx context.Context, bucket, key string, headers http.Header) (*http.Response, error) {
+	return nil, errors.New("unexpected HEAD")
+}
+
+func TestMultipartUpload(t *testing.T) {
+	const (
+		kmsKey   = "arn:aws:kms:us-east-1:123456789012:key/upload"
+		otherKey = "arn:aws:kms:us-east-1:123456789012:key/other"
+	)
+	sidecars := &memoryMetadata{}
+	handler := NewS3Handler(multipartBackend{}, mocks.NewMockVaultClient(), mocks.NewMockMetadataService(), WithMetadataSidecars(sidecars))
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Put("/:bucket/*", handler.PutObject)
+	app.Post("/:bucket/*", handler.PostObject)
+	app.Delete("/:bucket/*", handler.DeleteObject)
+	app.Get("/:bucket", handler.ListObjects)
+	app.Get("/:bucket/*", handler.GetObject)
+
+	send := func(method, target, body, kmsKeyARN string) (*http.Response, string) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", clientAuthorization)
+		if kmsKeyARN != "" {
+			req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", kmsKeyARN)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	resp, _ := send("POST", "/bucket/key?uploads", "", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "uploads need a KMS key")
+
+	resp, body := send("POST", "/bucket/key?uploads", "", kmsKey)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "<UploadId>up-1</UploadId>")
+	assert.Equal(t, kmsKey, resp.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+
+	resp, _ = send("PUT", "/bucket/key?partNumber=1&uploadId=up-1", "hello ", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, "parts need no KMS key header")
+	assert.Equal(t, `"backend-part"`, resp.Header.Get("ETag"), "clients complete uploads with the backend's part ETags")
+	assert.Equal(t, kmsKey, resp.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+	resp, _ = send("PUT", "/bucket/key?partNumber=2&uploadId=up-1", "world", kmsKey)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = send("PUT", "/bucket/key?partNumber=3&uploadId=up-1", "other", otherKey)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "parts cannot switch keys")
+	resp, _ = send("PUT", "/bucket/key?partNumber=0&uploadId=up-1", "x", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, body = send("GET", "/bucket?uploads", "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "<UploadId>up-1</UploadId>")
+	resp, body = send("GET", "/bucket/key?uploadId=up-1", "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "<Size>6</Size>", "parts list their plaintext size")
+	assert.Contains(t, body, "backend-part", "parts list the backend's ETag")
+	resp, _ = send("GET", "/bucket/key?uploadId=up-1&max-parts=-1", "", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = send("PUT", "/bucket/key?partNumber=1&uploadId=unknown", "x", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "parts of untracked uploads are left to the backend")
+
+	resp, body = send("POST", "/bucket/key?uploadId=up-1", `<CompleteMultipartUpload>
+		<Part><PartNumber>1</PartNumber><ETag>"backend-part"</ETag></Part>
+		<Part><PartNumber>2</PartNumber><ETag>"backend-part"</ETag></Part>
+	</CompleteMultipartUpload>`, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	hello, world := md5.Sum([]byte("hello ")), md5.Sum([]byte("world"))
+	assert.Contains(t, body, "<ETag>"+strings.ReplaceAll(etag.Multipart([][]byte{hello[:], world[:]}), `"`, "&#34;")+"</ETag>")
+	assert.Nil(t, handler.uploads.Get("up-1", "bucket", "key"), "completed uploads are forgotten")
+	meta, err := sidecars.Get(context.Background(), "bucket", "key", nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), meta.ContentLength)
+
+	handler.uploads.Start(multipart.Upload{ID: "up-3", Bucket: "bucket", Key: "key", KMSKeyARN: kmsKey})
+	resp, _ = send("POST", "/bucket/key?uploadId=up-3", `<CompleteMultipartUpload>
+		<Part><PartNumber>1</PartNumber><ETag>"backend-part"</ETag></Part>
+	</CompleteMultipartUpload>`, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	_, err = sidecars.Get(context.Background(), "bucket", "key", nil)
+	assert.ErrorIs(t, err, metadata.ErrNotFound, "completions with unseen parts remove the previous object's sidecar")
+
+	require.NoError(t, sidecars.Store(context.Background(), "bucket", "key", meta, nil))
+	resp, _ = send("POST", "/bucket/key?uploadId=elsewhere", `<CompleteMultipartUpload></CompleteMultipartUpload>`, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	_, err = sidecars.Get(context.Background(), "bucket", "key", nil)
+	assert.ErrorIs(t, err, metadata.ErrNotFound, "completions of untracked uploads remove the previous object's sidecar")
+
+	handler.uploads.Start(multipart.Upload{ID: "up-2", Bucket: "bucket", Key: "key", KMSKeyARN: kmsKey})
+	resp, _ = send("DELETE", "/bucket/key?uploadId=up-2", "", "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Nil(t, handler.uploads.Get("up-2", "bucket", "key"), "aborted uploads are forgotten")
+}
+
+func TestLegacyObjects(t *testing.T) {
+	const kmsKey = "arn:aws:kms:us-east-1:123456789012:key/legacy"
+	newApp := func(opts ...S3HandlerOption) *fiber.App {
+		s3Client := mocks.NewMockS3Client()
+		s3Client.SetResponse("GET", "/bucket/old", http.StatusOK, "plain", map[string]string{"ETag": `"old"`})
+		s3Client.SetResponse("HEAD", "/bucket/old", http.StatusOK, "", nil)
+		s3Client.SetResponse("GET", "/bucket/new", http.StatusOK, "cipher", map[string]string{"X-Amz-Server-Side-Encryption": "aws:kms"})
+		metadataService := mocks.NewMockMetadataService()
+		metadataService.On("Get", mock.Anything, mock.Anything, mock.Anything).Return((*types.ObjectMetadata)(nil), metadata.ErrNotFound)
+		handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), metadataService, opts...)
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		app.Get("/:bucket/*", handler.GetObject)
+		app.Head("/:bucket/*", handler.HeadObject)
+		return app
+	}
+	status := func(app *fiber.App, method, path string) int {
+		resp, err := app.Test(httptest.NewRequest(method, path, nil))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	app := newApp()
+	assert.Equal(t, http.StatusOK, status(app, "GET", "/bucket/old"), "unencrypted objects are served by default")
+
+	app = newApp(WithLegacyObjectsDenied())
+	assert.Equal(t, http.StatusForbidden, status(app, "GET", "/bucket/old"))
+	assert.Equal(t, http.StatusForbidden, status(app, "HEAD", "/bucket/old"))
+	assert.Equal(t, http.StatusOK, status(app, "GET", "/bucket/new"))
+
+	copies := make(copyRecorder, 1)
+	app = newApp(WithLegacyObjectsEncrypted(copies, kmsKey, 1))
+	assert.Equal(t, http.StatusOK, status(app, "GET", "/bucket/old"))
+	select {
+	case headers := <-copies:
+		assert.Equal(t, "/bucket/old", headers.Get("X-Amz-Copy-Source"))
+		assert.Equal(t, `"old"`, headers.Get("X-Amz-Copy-Source-If-Match"))
+		assert.Equal(t, "aws:kms", headers.Get("X-Amz-Server-Side-Encryption"))
+		assert.Equal(t, kmsKey, headers.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("the object was not rewritten")
+	}
+	assert.Equal(t, http.StatusOK, status(app, "GET", "/bucket/new"))
+	assert.Empty(t, copies, "encrypted objects are not rewritten")
+}
+
+func TestObjectPartNumber(t *testing.T) {
+	newApp := func(headers map[string]string) *fiber.App {
+		s3Client := mocks.NewMockS3Client()
+		s3Client.SetResponse("GET", "/bucket/key", http.StatusOK, "hello", headers)
+		s3Client.SetResponse("HEAD", "/bucket/key", http.StatusOK, "", headers)
+		metadataService := mocks.NewMockMetadataService()
+		metadataService.On("Get", mock.Anything, mock.Anything, mock.Anything).Return((*types.ObjectMetadata)(nil), metadata.ErrNotFound)
+		handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), metadataService)
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		app.Get("/:bucket/*", handler.GetObject)
+		app.Head("/:bucket/*", handler.HeadObject)
+		return app
+	}
+	do := func(app *fiber.App, method, target, rangeHeader string) *http.Response {
+		req := httptest.NewRequest(method, target, nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+	sse := map[string]string{"X-Amz-Server-Side-Encryption": "aws:kms", "Content-Length": "5"}
+
+	resp := do(newApp(sse), "GET", "/bucket/key?partNumber=1", "")
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode, "a single-part object is its own part 1")
+	assert.Equal(t, "bytes 0-4/5", resp.Header.Get("Content-Range"))
+
+	resp = do(newApp(sse), "HEAD", "/bucket/key?partNumber=2", "")
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+
+	resp = do(newApp(sse), "GET", "/bucket/key?partNumber=0", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = do(newApp(sse), "GET", "/bucket/key?partNumber=1", "bytes=0-1")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "partNumber and Range exclude each other")
+
+	parts := map[string]string{
+		"X-Amz-Server-Side-Encryption": "aws:kms",
+		"Content-Range":                "bytes 8-15/20",
+		"X-Amz-Mp-Parts-Count":         "3",
+	}
+	resp = do(newApp(parts), "HEAD", "/bucket/key?partNumber=2", "")
+	assert.Equal(t, "bytes 8-15/20", resp.Header.Get("Content-Range"), "parts the backend serves are kept")
+	assert.Equal(t, "3", resp.Header.Get("X-Amz-Mp-Parts-Count"))
+}
+
+func TestBucketHeaders(t *testing.T) {
+	set, err := bucketheaders.New([]bucketheaders.Bucket{{Bucket: "assets", Headers: map[string]string{
+		"Cache-Control":          "public, max-age=3600",
+		"X-Content-Type-Options": "nosniff",
+	}}})
+	require.NoError(t, err)
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("GET", "/assets/app.js", http.StatusOK, "js", map[string]string{"X-Amz-Server-Side-Encryption": "aws:kms"})
+	s3Client.SetResponse("GET", "/assets/missing.js", http.StatusNotFound, "", nil)
+	metadataService := mocks.NewMockMetadataService()
+	metadataService.On("Get", mock.Anything, mock.Anything, mock.Anything).Return((*types.ObjectMetadata)(nil), metadata.ErrNotFound)
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/:bucket/*", NewS3Handler(s3Client, mocks.NewMockVaultClient(), metadataService, WithBucketHeaders(set)).GetObject)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/assets/app.js", nil))
+	require.NoError(t, err)
+	assert.Equal(t, "public, max-age=3600", resp.Header.Get("Cache-Control"))
+	assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/assets/missing.js", nil))
+	require.NoError(t, err)
+	assert.Empty(t, resp.Header.Get("Cache-Control"), "errors are not cacheable")
+}
+
+func TestGetObjectVerifiesChecksum(t *testing.T) {
+	flags, err := features.Parse(map[string]string{features.VerifyObjectChecksums: "true"})
+	require.NoError(t, err)
+	get := func(body string, meta *types.ObjectMetadata, opts ...S3HandlerOption) *http.Response {
+		s3Client := mocks.NewMockS3Client()
+		s3Client.SetResponse("GET", "/bucket/key", http.StatusOK, body, map[string]string{
+			"X-Amz-Server-Side-Encryption": "aws:kms",
+			"Last-Modified":                "Wed, 06 Mar 2024 10:00:00 GMT",
+		})
+		metadataService := mocks.NewMockMetadataService()
+		metadataService.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(meta, nil)
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		app.Get("/:bucket/*", NewS3Handler(s3Client, mocks.NewMockVaultClient(), metadataService, opts...).GetObject)
+		resp, err := app.Test(httptest.NewRequest("GET", "/bucket/key", nil))
+		require.NoError(t, err)
+		return resp
+	}
+	// MD5 of "hello"
+	meta := func() *types.ObjectMetadata {
+		return &types.ObjectMetadata{ContentLength: 5, ETag: `"5d41402abc4b2a76b9719d911017c592"`, LastModified: "2024-03-06T10:00:01Z"}
+	}
+
+	resp := get("hello", meta(), WithFeatures(flags))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "hello", string(body))
+
+	resp = get("jello", meta(), WithFeatures(flags))
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode, "corrupted data is never sent")
+	body, _ = io.ReadAll(resp.Body)
+	assert.NotContains(t, string(body), "jello")
+	assert.Empty(t, resp.Header.Get("ETag"), "the object's headers are not sent with the error")
+
+	assert.Equal(t, http.StatusOK, get("jello", meta()).StatusCode, "verification is off by default")
+	assert.Equal(t, http.StatusOK, get("hello!", meta(), WithFeatures(flags)).StatusCode, "stale metadata is not verified")
+
+	overwritten := meta()
+	overwritten.LastModified = "2024-03-01T00:00:00Z"
+	resp = get("jello", overwritten, WithFeatures(flags))
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "metadata older than a same-size overwrite is not verified")
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "jello", string(body))
+}
+
+func TestHeadBucket(t *testing.T) {
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("HEAD", "/bucket", http.StatusOK, "", map[string]string{"X-Amz-Bucket-Region": "us-east-1"})
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Head("/:bucket", NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService()).HeadBucket)
+
+	resp, err := app.Test(httptest.NewRequest("HEAD", "/bucket", nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "us-east-1", resp.Header.Get("X-Amz-Bucket-Region"))
+	s3Client.AssertExpectations(t)
+}
+
+func TestObjectKeyEncoding(t *testing.T) {
+	t.Run("forwards the client's path and looks up the unescaped key", func(t *testing.T) {
+		s3Client := mocks.NewMockS3Client()
+		s3Client.SetResponse("HEAD", "/bucket/dir%2F%2Fa%20b%2Bc%23%25.txt", http.StatusOK, "", nil)
+		metadataService := mocks.NewMockMetadataService()
+		metadataService.On("Get", "bucket", "dir//a b+c#%.txt", mock.Anything).Return(&types.ObjectMetadata{ContentLength: 3}, nil)
+
+		resp, err := setupObjectTest(s3Client, metadataService).Test(httptest.NewRequest("HEAD", "/bucket/dir%2F%2Fa%20b%2Bc%23%25.txt", nil))
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "3", resp.Header.Get("Content-Length"))
+		metadataService.AssertCalled(t, "Get", "bucket", "dir//a b+c#%.txt", mock.Anything)
+	})
+
+	t.Run("deletes the metadata of the unescaped key", func(t *testing.T) {
+		s3Client := mocks.NewMockS3Client()
+		s3Client.SetResponse("DELETE", "/bucket/%E6%97%A5%E6%9C%AC+1.txt", http.StatusNoContent, "", nil)
+		s3Client.SetResponse("DELETE", "/bucket/%E6%97%A5%E6%9C%AC%2B1.txt.metadata", http.StatusNoContent, "", nil)
+
+		app := setupObjectTest(s3Client, mocks.NewMockMetadataService())
+		app.Delete("/:bucket/*", NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService()).DeleteObject)
+		resp, err := app.Test(httptest.NewRequest("DELETE", "/bucket/%E6%97%A5%E6%9C%AC+1.txt", nil))
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		s3Client.AssertExpectations(t)
+	})
+
+	t.Run("rejects invalid escapes", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/bucket/key", nil)
+		req.RequestURI = "/bucket/100%zz"
+		resp, err := setupObjectTest(mocks.NewMockS3Client(), mocks.NewMockMetadataService()).Test(req)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestCreateBucketValidatesName(t *testing.T) {
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("PUT", "/my-bucket", http.StatusOK, "", nil)
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Put("/:bucket", NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService()).CreateBucket)
+
+	resp, err := app.Test(httptest.NewRequest("PUT", "/My_Bucket", nil))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, string(body), "<Code>InvalidBucketName</Code>")
+	s3Client.AssertNotCalled(t, "ForwardRequest", "PUT", "/My_Bucket", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err = app.Test(httptest.NewRequest("PUT", "/my-bucket", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	s3Client.SetResponse("PUT", "/Legacy_Bucket", http.StatusOK, "", nil)
+	resp, err = app.Test(httptest.NewRequest("PUT", "/Legacy_Bucket?tagging", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "sub-resources of existing buckets are not validated")
+}
+
+func TestBucketLocation(t *testing.T) {
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("PUT", "/new-bucket", http.StatusOK, "", nil)
+	s3Client.SetResponse("PUT", "/config/.s3-vault-proxy/locations/new-bucket.json", http.StatusOK, "", nil)
+	s3Client.SetResponse("GET", "/config/.s3-vault-proxy/locations/old-bucket.json", http.StatusNotFound, "", nil)
+	s3Client.SetResponse("GET", "/new-bucket", http.StatusOK, "<LocationConstraint>backend</LocationConstraint>", nil)
+	s3Client.SetResponse("GET", "/old-bucket", http.StatusOK, "<LocationConstraint>backend</LocationConstraint>", nil)
+	handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService(),
+		WithRegion("eu-west-1"), WithBucketLocations(locations.NewStore(s3Client, "config")))
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Put("/:bucket", handler.CreateBucket)
+	app.Get("/:bucket", handler.ListObjects)
+
+	create := func(constraint string) (int, string) {
+		body := ""
+		if constraint != "" {
+			body = "<CreateBucketConfiguration><LocationConstraint>" + constraint + "</LocationConstraint></CreateBucketConfiguration>"
+		}
+		resp, err := app.Test(httptest.NewRequest("PUT", "/new-bucket", strings.NewReader(body)))
+		require.NoError(t, err)
+		respBody, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(respBody)
+	}
+
+	status, body := create("us-west-2")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "<Code>IllegalLocationConstraintException</Code>")
+	status, body = create("us-east-1")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "<Code>InvalidLocationConstraint</Code>")
+	s3Client.AssertNotCalled(t, "ForwardRequest", "PUT", "/new-bucket", mock.Anything, mock.Anything, mock.Anything)
+
+	status, _ = create("EU")
+	assert.Equal(t, http.StatusOK, status)
+	s3Client.AssertCalled(t, "ForwardRequest", "PUT", "/config/.s3-vault-proxy/locations/new-bucket.json", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/new-bucket?location", nil))
+	require.NoError(t, err)
+	respBody, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(respBody), ">eu-west-1</LocationConstraint>")
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/old-bucket?location", nil))
+	require.NoError(t, err)
+	respBody, _ = io.ReadAll(resp.Body)
+	assert.Contains(t, string(respBody), ">backend</LocationConstraint>", "unrecorded buckets get the backend's answer")
+}
+
+func TestBucketOwnershipControls(t *testing.T) {
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("GET", "/bucket", http.StatusNotImplemented, "", nil)
+	s3Client.SetResponse("PUT", "/bucket", http.StatusNotImplemented, "", nil)
+	s3Client.SetResponse("DELETE", "/bucket", http.StatusNotImplemented, "", nil)
+	s3Client.SetResponse("GET", "/denied", http.StatusForbidden, "<Error><Code>AccessDenied</Code></Error>", nil)
+	handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService())
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/:bucket", handler.ListObjects)
+	app.Put("/:bucket", handler.CreateBucket)
+	app.Delete("/:bucket", handler.DeleteBucket)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/bucket?ownershipControls", nil))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "<Rule><ObjectOwnership>BucketOwnerEnforced</ObjectOwnership></Rule>")
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/denied?ownershipControls", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "the backend still authorizes the request")
+
+	put := func(ownership string) int {
+		body := "<OwnershipControls><Rule><ObjectOwnership>" + ownership + "</ObjectOwnership></Rule></OwnershipControls>"
+		resp, err := app.Test(httptest.NewRequest("PUT", "/bucket?ownershipControls", strings.NewReader(body)))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, put("BucketOwnerEnforced"))
+	assert.Equal(t, http.StatusBadRequest, put("ObjectWriter"))
+
+	resp, err = app.Test(httptest.NewRequest("DELETE", "/bucket?ownershipControls", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestUnsupportedBucketConfigurations(t *testing.T) {
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("GET", "/bucket", http.StatusNotImplemented, "", nil)
+	handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService())
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/:bucket", handler.ListObjects)
+
+	for subresource, root := range unsupportedConfigurations {
+		resp, err := app.Test(httptest.NewRequest("GET", "/bucket?"+subresource, nil))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, subresource)
+		assert.Contains(t, string(body), "<"+root+` xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><IsTruncated>false</IsTruncated></`+root+">", subresource)
+
+		resp, err = app.Test(httptest.NewRequest("GET", "/bucket?"+subresource+"&id=archive", nil))
+		require.NoError(t, err)
+		body, _ = io.ReadAll(resp.Body)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, subresource)
+		assert.Contains(t, string(body), "<Code>NoSuchConfiguration</Code>", subresource)
+	}
+}
+
+func TestWithContentRangeSize(t *testing.T) {
+	assert.Equal(t, "bytes 0-9/1000", withContentRangeSize("bytes 0-9/1052", 1000))
+	assert.Equal(t, "bytes */1000", withContentRangeSize("bytes */1052", 1000))
+	assert.Equal(t, "items 0-9/1052", withContentRangeSize("items 0-9/1052", 1000))
+}
+
+func TestObjectTagging(t *testing.T) {
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("PUT", "/bucket/key", http.StatusOK, "", nil)
+	s3Client.SetResponse("GET", "/bucket/key", http.StatusOK, "<Tagging><TagSet/></Tagging>", nil)
+	s3Client.SetResponse("DELETE", "/bucket/key", http.StatusNoContent, "", nil)
+	app := setupObjectTest(s3Client, mocks.NewMockMetadataService())
+	app.Delete("/:bucket/*", NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService()).DeleteObject)
+
+	resp, err := app.Test(httptest.NewRequest("PUT", "/bucket/key?tagging", strings.NewReader("<Tagging><TagSet/></Tagging>")))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "tagging needs no KMS key")
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/bucket/key?tagging", nil))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "<Tagging><TagSet/></Tagging>", string(body))
+
+	resp, err = app.Test(httptest.NewRequest("DELETE", "/bucket/key?tagging", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	s3Client.AssertNotCalled(t, "ForwardRequest", "DELETE", "/bucket/key.metadata", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeleteObjects(t *testing.T) {
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("POST", "/bucket", http.StatusOK, "<DeleteResult><Error><Key>b</Key><Code>AccessDenied</Code></Error></DeleteResult>", nil)
+	s3Client.SetResponse("DELETE", "/bucket/a%20b.metadata", http.StatusNoContent, "", nil)
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Post("/:bucket", NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService()).PostBucket)
+
+	req := httptest.NewRequest("POST", "/bucket?delete", strings.NewReader("<Delete><Quiet>true</Quiet><Object><Key>a b</Key></Object><Object><Key>b</Key></Object></Delete>"))
+	req.Header.Set("Content-MD5", "ignored")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "<Key>b</Key>")
+	s3Client.AssertExpectations(t)
+	s3Client.AssertNotCalled(t, "ForwardRequest", "DELETE", "/bucket/b.metadata", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err = app.Test(httptest.NewRequest("POST", "/bucket?delete", strings.NewReader("not xml")))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest("POST", "/bucket", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+}
+
+func TestDeleteObjectsFanOut(t *testing.T) {
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("POST", "/bucket", http.StatusOK, `<?xml version="1.0" encoding="UTF-8"?>`+
+		`<DeleteResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Deleted><Key>k0</Key></Deleted><Deleted><Key>k1</Key></Deleted>`+
+		`<Deleted><Key>k2</Key></Deleted><Error><Key>k3</Key><Code>AccessDenied</Code><Message>Denied</Message></Error></DeleteResult>`, nil)
+	// Metadata deletes block until all of them are in flight, proving they overlap
+	var inFlight sync.WaitGroup
+	inFlight.Add(3)
+	for _, key := range []string{"k0", "k1", "k2"} {
+		status := http.StatusNoContent
+		if key == "k1" {
+			status = http.StatusInternalServerError
+		}
+		s3Client.On("ForwardRequest", "DELETE", "/bucket/"+key+".metadata", mock.Anything, mock.Anything, mock.Anything).
+			Run(func(mock.Arguments) {
+				inFlight.Done()
+				inFlight.Wait()
+			}).
+			Return(&http.Response{StatusCode: status, Body: http.NoBody}, nil)
+	}
+	handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService(), WithDeleteConcurrency(3))
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Post("/:bucket", handler.PostBucket)
+
+	resp, err := app.Test(httptest.NewRequest("POST", "/bucket?delete", strings.NewReader(
+		"<Delete><Object><Key>k0</Key></Object><Object><Key>k1</Key></Object><Object><Key>k2</Key></Object><Object><Key>k3</Key></Object></Delete>")), 5000)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), `<DeleteResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`)
+	assert.Contains(t, string(body), "<Deleted><Key>k0</Key></Deleted><Deleted><Key>k2</Key></Deleted>")
+	assert.Contains(t, string(body), "<Error><Key>k3</Key><Code>AccessDenied</Code>")
+	assert.Contains(t, string(body), "<Error><Key>k1</Key><Code>InternalError</Code>", "keys left with metadata are retried")
+	s3Client.AssertNotCalled(t, "ForwardRequest", "DELETE", "/bucket/k3.metadata", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeleteObjectTrash(t *testing.T) {
+	isTrashPath := mock.MatchedBy(func(path string) bool { return strings.HasPrefix(path, "/bucket/.trash/") })
+	trashClient := mocks.NewMockS3Client()
+	trashClient.SetHeadResponse("bucket", "key", http.StatusOK, map[string]string{"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": "arn:aws:kms:us-east-1:123456789012:key/k"})
+	trashClient.SetHeadResponse("bucket", "key.metadata", http.StatusNotFound, nil)
+	trashClient.SetResponse("GET", "/bucket/key.metadata", http.StatusNotFound, "", nil)
+	trashClient.On("ForwardRequest", "PUT", isTrashPath, mock.Anything, mock.MatchedBy(func(headers http.Header) bool {
+		return headers.Get("X-Amz-Copy-Source") == "/bucket/key" &&
+			headers.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id") == "arn:aws:kms:us-east-1:123456789012:key/k"
+	}), mock.Anything).Return(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("<CopyObjectResult/>"))}, nil)
+	trashClient.On("ForwardRequest", "DELETE", isTrashPath, mock.Anything, mock.Anything, mock.Anything).
+		Return(&http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody}, nil)
+
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("DELETE", "/bucket/key", http.StatusForbidden, "<Error><Code>AccessDenied</Code></Error>", nil)
+	s3Client.SetResponse("DELETE", "/bucket/key.metadata", http.StatusNoContent, "", nil)
+	handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService(),
+		WithTrash(trash.New(trashClient, []string{"bucket"}, time.Hour)))
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Delete("/:bucket/*", handler.DeleteObject)
+
+	_, err := app.Test(httptest.NewRequest("DELETE", "/bucket/key", nil))
+	require.NoError(t, err)
+
+	// The copy is made first and discarded again because the delete was denied
+	trashClient.AssertNumberOfCalls(t, "ForwardRequest", 4)
+	trashClient.AssertCalled(t, "ForwardRequest", "DELETE", isTrashPath, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListingHidesTrash(t *testing.T) {
+	metadataService := mocks.NewMockMetadataService()
+	metadataService.On("Get", mock.Anything, mock.Anything, mock.Anything).Return((*types.ObjectMetadata)(nil), metadata.ErrNotFound)
+	handler := NewS3Handler(mocks.NewMockS3Client(), mocks.NewMockVaultClient(), metadataService,
+		WithTrash(trash.New(nil, []string{"bucket"}, time.Hour)))
+
+	listing := `<ListBucketResult><Contents><Key>.trash/20260301T120000.000000000Z/a</Key></Contents>` +
+		`<Contents><Key>a</Key></Contents><CommonPrefixes><Prefix>.trash/</Prefix></CommonPrefixes>` +
+		`<CommonPrefixes><Prefix>dir/</Prefix></CommonPrefixes></ListBucketResult>`
+	var out strings.Builder
+	require.NoError(t, handler.streamListBucketResult(handler.newListEnrichment(context.Background()), &out, strings.NewReader(listing), "bucket"))
+
+	assert.NotContains(t, out.String(), ".trash/")
+	assert.Contains(t, out.String(), "<Key>a</Key>")
+	assert.Contains(t, out.String(), "<Prefix>dir/</Prefix>")
+
+	out.Reset()
+	require.NoError(t, handler.streamListBucketResult(handler.newListEnrichment(context.Background()), &out, strings.NewReader(listing), "other"))
+	assert.Contains(t, out.String(), "<Prefix>.trash/</Prefix>")
+}
+
+// stalledMetadata answers lookups only once their context ends
+type stalledMetadata struct{ metadata.Interface }
+
+func (stalledMetadata) Get(ctx context.Context, bucket, key string, headers http.Header) (*types.ObjectMetadata, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestListingMetadataBudget(t *testing.T) {
+	handler := NewS3Handler(mocks.NewMockS3Client(), mocks.NewMockVaultClient(), stalledMetadata{},
+		WithListMetadataBudget(10*time.Millisecond))
+	listing := `<ListBucketResult><Contents><Key>a</Key><Size>1</Size></Contents>` +
+		`<Contents><Key>b</Key><Size>2</Size></Contents></ListBucketResult>`
+
+	for page := 0; page < listBreakerThreshold; page++ {
+		enrichment := handler.newListEnrichment(context.Background())
+		assert.False(t, enrichment.skip)
+		var out strings.Builder
+		start := time.Now()
+		require.NoError(t, handler.streamListBucketResult(enrichment, &out, strings.NewReader(listing), "bucket"))
+		enrichment.finish()
+		assert.Less(t, time.Since(start), time.Second, "a stalled store costs one budget per page")
+		assert.Contains(t, out.String(), "<Size>2</Size>", "entries keep the backend's values")
+	}
+
+	assert.True(t, handler.newListEnrichment(context.Background()).skip, "repeated overruns skip metadata")
+
+	t.Run("pages that run out of budget midway are marked", func(t *testing.T) {
+		s3Client := mocks.NewMockS3Client()
+		s3Client.SetResponse("GET", "/bucket", http.StatusOK, listing, nil)
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		app.Get("/:bucket", NewS3Handler(s3Client, mocks.NewMockVaultClient(), stalledMetadata{},
+			WithListMetadataBudget(10*time.Millisecond)).ListObjects)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/bucket", nil))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "degraded", resp.Header.Get(degradedHeader))
+		assert.Contains(t, string(body), "<Size>2</Size>")
+	})
+}
+
+// countingMetadata counts lookups and finds no metadata
+type countingMetadata struct {
+	metadata.Interface
+	lookups *int
+}
+
+func (m countingMetadata) Get(ctx context.Context, bucket, key string, headers http.Header) (*types.ObjectMetadata, error) {
+	*m.lookups++
+	return nil, metadata.ErrNotFound
+}
+
+func TestListObjectsValidatesQuery(t *testing.T) {
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("GET", "/bucket", http.StatusOK, "<ListBucketResult></ListBucketResult>", nil)
+	app := fiber.New(fiber.Config{DisableStartupMessage: true, ReadBufferSize: 16384})
+	app.Get("/:bucket", NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService()).ListObjects)
+
+	tests := []struct {
+		query   string
+		allowed bool
+	}{
+		{"list-type=2&max-keys=100", true},
+		{"max-keys=0", true},
+		{"max-keys=100000000", true},
+		{"max-keys=-1", false},
+		{"max-keys=abc", false},
+		{"max-keys=99999999999", false},
+		{"list-type=2&continuation-token=", false},
+		{"list-type=2&continuation-token=" + strings.Repeat("x", 5000), false},
+		{"marker=" + strings.Repeat("k", 1025), false},
+		{"prefix=%FF", false},
+		{"encoding-type=base64", false},
+		{"list-type=3", false},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest("GET", "/bucket?"+tt.query, nil))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		if tt.allowed {
+			assert.Equal(t, http.StatusOK, resp.StatusCode, tt.query)
+			continue
+		}
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, tt.query)
+		assert.Contains(t, string(body), "<Code>InvalidArgument</Code>", tt.query)
+	}
+}
+
+func TestListingEnrichesAtMostOnePage(t *testing.T) {
+	lookups := 0
+	handler := NewS3Handler(mocks.NewMockS3Client(), mocks.NewMockVaultClient(), countingMetadata{lookups: &lookups})
+	var listing strings.Builder
+	listing.WriteString("<ListBucketResult>")
+	for i := 0; i < maxListKeys+5; i++ {
+		listing.WriteString("<Contents><Key>" + strconv.Itoa(i) + "</Key><Size>1</Size></Contents>")
+	}
+	listing.WriteString("</ListBucketResult>")
+
+	var out strings.Builder
+	enrichment := handler.newListEnrichment(context.Background())
+	require.NoError(t, handler.streamListBucketResult(enrichment, &out, strings.NewReader(listing.String()), "bucket"))
+	enrichment.finish()
+	assert.Equal(t, maxListKeys, lookups)
+	assert.Equal(t, maxListKeys+5, strings.Count(out.String(), "<Contents>"), "every entry is still listed")
+}
+
+func TestGetObjectIfRange(t *testing.T) {
+	stored := &types.ObjectMetadata{ContentLength: 100, ETag: `"plaintext"`, LastModified: "2024-03-05T13:30:00Z"}
+	tests := []struct {
+		name      string
+		ifRange   string
+		wantRange bool
+	}{
+		{"matching ETag", `"plaintext"`, true},
+		{"stale ETag", `"changed"`, false},
+		{"weak ETag", `W/"plaintext"`, false},
+		{"matching date", "Tue, 05 Mar 2024 13:30:00 GMT", true},
+		{"stale date", "Mon, 04 Mar 2024 13:30:00 GMT", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3Client := mocks.NewMockS3Client()
+			s3Client.On("ForwardRequest", "GET", "/bucket/key", mock.Anything, mock.MatchedBy(func(headers http.Header) bool {
+				_, hasRange := headers["Range"]
+				_, hasIfRange := headers["If-Range"]
+				return hasRange == tt.wantRange && !hasIfRange
+			}), mock.Anything).Return(&http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: http.NoBody}, nil)
+			metadataService := mocks.NewMockMetadataService()
+			metadataService.On("Get", "bucket", "key", mock.Anything).Return(stored, nil)
+			app := setupObjectTest(s3Client, metadataService)
+
+			req := httptest.NewRequest("GET", "/bucket/key", nil)
+			req.Header.Set("Range", "bytes=50-")
+			req.Header.Set("If-Range", tt.ifRange)
+			_, err := app.Test(req)
+			require.NoError(t, err)
+			s3Client.AssertExpectations(t)
+		})
+	}
+
+	t.Run("signed headers are left to the backend", func(t *testing.T) {
+		s3Client := mocks.NewMockS3Client()
+		s3Client.On("ForwardRequest", "GET", "/bucket/key", mock.Anything, mock.MatchedBy(func(headers http.Header) bool {
+			_, hasIfRange := headers["If-Range"]
+			return hasIfRange
+		}), mock.Anything).Return(&http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: http.NoBody}, nil)
+		metadataService := mocks.NewMockMetadataService()
+		metadataService.On("Get", "bucket", "key", mock.Anything).Return(stored, nil)
+		app := setupObjectTest(s3Client, metadataService)
+
+		req := httptest.NewRequest("GET", "/bucket/key", nil)
+		req.Header.Set("Range", "bytes=50-")
+		req.Header.Set("If-Range", `"changed"`)
+		req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/s3/aws4_request, SignedHeaders=host;if-range;range, Signature=abc")
+		_, err := app.Test(req)
+		require.NoError(t, err)
+		s3Client.AssertExpectations(t)
+	})
+}
+
+func TestConditionalGetFromMetadata(t *testing.T) {
+	stored := &types.ObjectMetadata{ContentLength: 100, ETag: `"plaintext"`, LastModified: "2024-03-05T13:30:00Z"}
+	tests := []struct {
+		name       string
+		method     string
+		header     string
+		value      string
+		lastWrite  string
+		wantStatus int
+	}{
+		{"matching ETag", "GET", "If-None-Match", `"plaintext"`, "Tue, 05 Mar 2024 13:30:00 GMT", http.StatusNotModified},
+		{"matching ETag in a list", "GET", "If-None-Match", `"other", W/"plaintext"`, "Tue, 05 Mar 2024 13:30:00 GMT", http.StatusNotModified},
+		{"any ETag", "HEAD", "If-None-Match", "*", "Tue, 05 Mar 2024 13:30:00 GMT", http.StatusNotModified},
+		{"stale ETag", "GET", "If-None-Match", `"changed"`, "Tue, 05 Mar 2024 13:30:00 GMT", http.StatusOK},
+		{"unmodified date", "HEAD", "If-Modified-Since", "Tue, 05 Mar 2024 13:30:00 GMT", "Tue, 05 Mar 2024 13:30:00 GMT", http.StatusNotModified},
+		{"modified date", "GET", "If-Modified-Since", "Mon, 04 Mar 2024 13:30:00 GMT", "Tue, 05 Mar 2024 13:30:00 GMT", http.StatusOK},
+		{"overwritten outside the proxy", "GET", "If-None-Match", `"plaintext"`, "Wed, 06 Mar 2024 13:30:00 GMT", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3Client := mocks.NewMockS3Client()
+			s3Client.On("ForwardRequest", tt.method, "/bucket/key", mock.Anything, mock.MatchedBy(func(headers http.Header) bool {
+				_, forwarded := headers[tt.header]
+				return !forwarded
+			}), mock.Anything).Return(&http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Last-Modified": {tt.lastWrite}, "Etag": {`"ciphertext"`}},
+				Body:       io.NopCloser(strings.NewReader(strings.Repeat("x", 100))),
+			}, nil)
+			metadataService := mocks.NewMockMetadataService()
+			metadataService.On("Get", "bucket", "key", mock.Anything).Return(stored, nil)
+			app := setupObjectTest(s3Client, metadataService)
+
+			req := httptest.NewRequest(tt.method, "/bucket/key", nil)
+			req.Header.Set(tt.header, tt.value)
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			s3Client.AssertExpectations(t)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, `"plaintext"`, resp.Header.Get("ETag"))
+			if tt.wantStatus == http.StatusNotModified {
+				body, _ := io.ReadAll(resp.Body)
+				assert.Empty(t, body)
+				assert.Empty(t, resp.Header.Get("Content-Length"))
+			}
+		})
+	}
+
+	t.Run("signed headers are forwarded", func(t *testing.T) {
+		s3Client := mocks.NewMockS3Client()
+		s3Client.On("ForwardRequest", "GET", "/bucket/key", mock.Anything, mock.MatchedBy(func(headers http.Header) bool {
+			_, forwarded := headers["If-None-Match"]
+			return forwarded
+		}), mock.Anything).Return(&http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: http.NoBody}, nil)
+		metadataService := mocks.NewMockMetadataService()
+		metadataService.On("Get", "bucket", "key", mock.Anything).Return(stored, nil)
+		app := setupObjectTest(s3Client, metadataService)
+
+		req := httptest.NewRequest("GET", "/bucket/key", nil)
+		req.Header.Set("If-None-Match", `"plaintext"`)
+		req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/s3/aws4_request, SignedHeaders=host;if-none-match, Signature=abc")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		s3Client.AssertExpectations(t)
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode, "the backend authorized the request")
+	})
+}
+
+func TestNotifications(t *testing.T) {
+	var mu sync.Mutex
+	var records []notify.Record
+	queue := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message notify.Message
+		require.NoError(t, json.Unmarshal([]byte(r.FormValue("MessageBody")), &message))
+		mu.Lock()
+		records = append(records, message.Records...)
+		mu.Unlock()
+	}))
+	defer queue.Close()
+	notifier, err := notify.New(notify.Options{QueueURL: queue.URL, Events: []string{"s3:*"}, QueueSize: 10})
+	require.NoError(t, err)
+	notifier.Start()
+
+	s3Client := mocks.NewMockS3Client()
+	s3Client.SetResponse("PUT", "/bucket/key", http.StatusOK, "", nil)
+	s3Client.SetResponse("POST", "/bucket", http.StatusOK, "<DeleteResult><Error><Key>b</Key><Code>AccessDenied</Code></Error></DeleteResult>", nil)
+	s3Client.SetResponse("DELETE", "/bucket/a.metadata", http.StatusNoContent, "", nil)
+	handler := NewS3Handler(s3Client, mocks.NewMockVaultClient(), mocks.NewMockMetadataService(), WithNotifications(notifier))
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Put("/:bucket/*", handler.PutObject)
+	app.Post("/:bucket", handler.PostBucket)
+
+	req := httptest.NewRequest("PUT", "/bucket/key", strings.NewReader("hello"))
+	req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", "arn:aws:kms:us-east-1:123456789012:key/test")
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc")
+	_, err = app.Test(req)
+	require.NoError(t, err)
+	_, err = app.Test(httptest.NewRequest("POST", "/bucket?delete", strings.NewReader("<Delete><Object><Key>a</Key></Object><Object><Key>b</Key></Object></Delete>")))
+	require.NoError(t, err)
+	notifier.Close()
+
+	require.Len(t, records, 2, "the object that failed to delete has no event")
+	sort.Slice(records, func(i, k int) bool { return records[i].S3.Object.Sequencer < records[k].S3.Object.Sequencer })
+	assert.Equal(t, notify.ObjectCreatedPut, records[0].EventName)
+	assert.Equal(t, "key", records[0].S3.Object.Key)
+	assert.Equal(t, int64(5), records[0].S3.Object.Size)
+	assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", records[0].S3.Object.ETag, "events carry the plaintext ETag")
+	assert.Equal(t, "AKIDEXAMPLE", records[0].UserIdentity.PrincipalID)
+	assert.Equal(t, notify.ObjectRemovedDelete, records[1].EventName)
+	assert.Equal(t, "a", records[1].S3.Object.Key)
+}
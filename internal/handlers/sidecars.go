@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// sidecarStore writes and removes the <key>.metadata objects that HEAD, GET
+// and listings are reconciled with
+type sidecarStore interface {
+	metadata.Interface
+	Delete(ctx context.Context, bucket, key string, headers http.Header) error
+}
+
+// WithMetadataSidecars rewrites the metadata sidecar of every object written
+// through the proxy in store, so a sidecar left by migrate, rewrap, archive,
+// sync or replication never describes an object that was since overwritten.
+// store must write with the proxy's credentials: the client signed only its
+// own request.
+func WithMetadataSidecars(store sidecarStore) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.sidecars = store
+	}
+}
+
+// storedMetadata reads the metadata sidecar of an object from the store the
+// handler was created with, which signs with the proxy's own credentials: the
+// client signed its request for the object, not one for <key>.metadata. A
+// sidecar only reconciles a response the backend authorized, or shapes a
+// request the backend still authorizes, so nothing read reaches a client the
+// backend refused.
+func (h *S3Handler) storedMetadata(ctx context.Context, bucket, key string) (*types.ObjectMetadata, error) {
+	return h.metadataService.Get(ctx, bucket, key, http.Header{})
+}
+
+// recordUpload stores the metadata sidecar of an object uploaded through the
+// proxy, with the encryption context of its upload. Every upload is
+// recorded, so an overwrite never leaves the previous object's sizes, ETag or
+// context behind.
+func (h *S3Handler) recordUpload(c *fiber.Ctx, bucket, key string, sse uploadKey, meta types.ObjectMetadata) {
+	if h.sidecars == nil {
+		return
+	}
+	meta.KMSKeyARN = sse.kmsKeyARN
+	meta.LastModified = types.FormatLastModified(time.Now())
+	if sse.encryptionContext != "" {
+		// The header was validated with the upload's other SSE headers
+		meta.EncryptionContext, _ = s3.ParseEncryptionContext(sse.encryptionContext)
+	}
+	// The object is stored, so the record is written even when the client goes away
+	ctx := context.WithoutCancel(c.UserContext())
+	if err := h.sidecars.Store(ctx, bucket, key, &meta, http.Header{}); err != nil {
+		logging.FromContext(ctx).Error().Err(err).Msg("Failed to record the metadata of an upload")
+		h.forgetSidecar(c, bucket, key)
+		return
+	}
+	h.invalidateObject(bucket, key)
+}
+
+// recordCopy stores the metadata sidecar of a copied object: its source's,
+// under the copy's KMS key and encryption context. Copies of objects without
+// a sidecar lose the destination's, since the proxy never sees their data.
+func (h *S3Handler) recordCopy(c *fiber.Ctx, bucket, key string, sse uploadKey, copySource string) {
+	if h.sidecars == nil {
+		return
+	}
+	sourceBucket, sourceKey, ok := s3.ParseCopySource(copySource)
+	if !ok {
+		h.forgetSidecar(c, bucket, key)
+		return
+	}
+	sourceMeta, err := h.sidecars.Get(c.UserContext(), sourceBucket, sourceKey, http.Header{})
+	if err != nil {
+		logging.FromContext(c.UserContext()).Debug().Err(err).Msg("Copy source has no metadata; the copy's sidecar is removed")
+		h.forgetSidecar(c, bucket, key)
+		return
+	}
+	// The copy is encrypted anew and is not a replica
+	sourceMeta.EncryptionContext, sourceMeta.ReplicationStatus = nil, ""
+	h.recordUpload(c, bucket, key, sse, *sourceMeta)
+}
+
+// forgetSidecar removes the metadata sidecar of an object written without
+// metadata the proxy can describe, so reads report the backend's values
+func (h *S3Handler) forgetSidecar(c *fiber.Ctx, bucket, key string) {
+	if h.sidecars == nil {
+		return
+	}
+	ctx := context.WithoutCancel(c.UserContext())
+	if err := h.sidecars.Delete(ctx, bucket, key, http.Header{}); err != nil {
+		logging.FromContext(ctx).Error().Err(err).Msg("Failed to remove the metadata sidecar of an overwritten object")
+	}
+	h.invalidateObject(bucket, key)
+}
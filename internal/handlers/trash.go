@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"context"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/trash"
+)
+
+// WithTrash moves objects deleted from the trash's buckets into it first, and
+// hides the trash area from their listings
+func WithTrash(t *trash.Trash) S3HandlerOption {
+	return func(h *S3Handler) {
+		h.trash = t
+	}
+}
+
+// keepsTrash reports whether deleting key from bucket moves it to the trash.
+// Deleting an object that is already in the trash removes it for good.
+func (h *S3Handler) keepsTrash(bucket, key string) bool {
+	return h.trash.Enabled(bucket) && !trash.IsTrashKey(key)
+}
+
+// hidesTrash reports whether listings of bucket hide the trash area
+func (h *S3Handler) hidesTrash(bucket string) bool {
+	return h.trash.Enabled(bucket)
+}
+
+// discardTrash drops the trash entry of a delete that did not happen. It runs
+// even when the delete failed because the client went away.
+func (h *S3Handler) discardTrash(ctx context.Context, entry *trash.Entry) {
+	if entry == nil {
+		return
+	}
+	if err := h.trash.Discard(context.WithoutCancel(ctx), entry); err != nil {
+		logging.FromContext(ctx).Error().Err(err).Str("bucket", entry.Bucket).Str("key", entry.Key).Msg("Failed to discard trash entry")
+	}
+}
@@ -0,0 +1,113 @@
+package inflight
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"s3-vault-proxy/internal/metrics"
+)
+
+var inFlightRequests = metrics.NewGauge(
+	"s3_vault_proxy_in_flight_requests",
+	"Requests currently being processed.",
+)
+
+// Request describes a request that is being processed
+type Request struct {
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	RemoteIP string    `json:"remote_ip"`
+	Started  time.Time `json:"started"`
+}
+
+// Tracker follows in-flight requests so shutdown can wait for them and
+// report any that were cut off
+type Tracker struct {
+	mu       sync.Mutex
+	next     uint64
+	requests map[uint64]Request
+	idle     chan struct{}
+	draining atomic.Bool
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	return &Tracker{requests: make(map[uint64]Request)}
+}
+
+// Begin records a request and returns the function that marks it finished
+func (t *Tracker) Begin(r Request) func() {
+	t.mu.Lock()
+	t.next++
+	id := t.next
+	t.requests[id] = r
+	t.mu.Unlock()
+	inFlightRequests.Add(1)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			inFlightRequests.Add(-1)
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			delete(t.requests, id)
+			if len(t.requests) == 0 && t.idle != nil {
+				close(t.idle)
+				t.idle = nil
+			}
+		})
+	}
+}
+
+// Count returns the number of in-flight requests
+func (t *Tracker) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.requests)
+}
+
+// Snapshot returns the in-flight requests, oldest first
+func (t *Tracker) Snapshot() []Request {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	requests := make([]Request, 0, len(t.requests))
+	for _, r := range t.requests {
+		requests = append(requests, r)
+	}
+	sort.Slice(requests, func(i, k int) bool { return requests[i].Started.Before(requests[k].Started) })
+	return requests
+}
+
+// StartDrain marks the process as shutting down
+func (t *Tracker) StartDrain() {
+	t.draining.Store(true)
+}
+
+// Draining reports whether shutdown has begun
+func (t *Tracker) Draining() bool {
+	return t.draining.Load()
+}
+
+// Wait blocks until no requests are in flight or ctx is done
+func (t *Tracker) Wait(ctx context.Context) error {
+	t.mu.Lock()
+	if len(t.requests) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	if t.idle == nil {
+		t.idle = make(chan struct{})
+	}
+	idle := t.idle
+	t.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,51 @@
+package inflight
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerCountsAndSnapshots(t *testing.T) {
+	tracker := NewTracker()
+	now := time.Now()
+	doneB := tracker.Begin(Request{Path: "/b", Started: now})
+	doneA := tracker.Begin(Request{Path: "/a", Started: now.Add(-time.Minute)})
+
+	assert.Equal(t, 2, tracker.Count())
+	snapshot := tracker.Snapshot()
+	require.Len(t, snapshot, 2)
+	assert.Equal(t, "/a", snapshot[0].Path, "oldest first")
+
+	doneA()
+	doneA()
+	assert.Equal(t, 1, tracker.Count(), "finishing twice is harmless")
+	doneB()
+	assert.Equal(t, 0, tracker.Count())
+}
+
+func TestTrackerWait(t *testing.T) {
+	tracker := NewTracker()
+	require.NoError(t, tracker.Wait(context.Background()), "idle tracker returns immediately")
+
+	done := tracker.Begin(Request{Path: "/upload"})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, tracker.Wait(ctx), context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		done()
+	}()
+	assert.NoError(t, tracker.Wait(context.Background()))
+}
+
+func TestDraining(t *testing.T) {
+	tracker := NewTracker()
+	assert.False(t, tracker.Draining())
+	tracker.StartDrain()
+	assert.True(t, tracker.Draining())
+}
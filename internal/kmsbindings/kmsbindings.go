@@ -0,0 +1,170 @@
+// Package kmsbindings restricts each bucket to an exact set of KMS keys.
+// Bindings are kept as JSON objects in a configuration bucket, so every
+// proxy replica enforces the same ones, and are edited through the admin API.
+// Buckets without a binding accept any key the other checks allow.
+package kmsbindings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/s3"
+)
+
+// Prefix is where bindings are kept in the configuration bucket
+const Prefix = ".s3-vault-proxy/kms-bindings/"
+
+// bindingTTL bounds how long a loaded binding is reused, so changes made
+// through another proxy replica take effect
+const bindingTTL = 30 * time.Second
+
+// ErrKeyNotBound is returned for keys outside a bucket's binding
+var ErrKeyNotBound = errors.New("KMS key is not bound to the bucket")
+
+// Binding is the set of KMS keys a bucket's objects may use
+type Binding struct {
+	Bucket  string   `json:"bucket"`
+	KMSKeys []string `json:"kms_keys"`
+}
+
+// Allows reports whether the binding admits kmsKeyARN
+func (b *Binding) Allows(kmsKeyARN string) bool {
+	for _, key := range b.KMSKeys {
+		if key == kmsKeyARN {
+			return true
+		}
+	}
+	return false
+}
+
+// Store loads and saves bindings in a configuration bucket
+type Store struct {
+	client s3.Interface
+	bucket string
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+type entry struct {
+	binding *Binding // nil when the bucket has none
+	loaded  time.Time
+}
+
+// NewStore keeps bindings in bucket, reading and writing them with client
+func NewStore(client s3.Interface, bucket string) *Store {
+	return &Store{
+		client:  client,
+		bucket:  bucket,
+		now:     time.Now,
+		entries: make(map[string]entry),
+	}
+}
+
+// Check returns ErrKeyNotBound when bucket has a binding that does not admit kmsKeyARN
+func (s *Store) Check(ctx context.Context, bucket, kmsKeyARN string) error {
+	binding, err := s.Get(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if binding != nil && !binding.Allows(kmsKeyARN) {
+		return ErrKeyNotBound
+	}
+	return nil
+}
+
+// Get returns the binding of bucket, or nil when it has none
+func (s *Store) Get(ctx context.Context, bucket string) (*Binding, error) {
+	s.mu.Lock()
+	cached, ok := s.entries[bucket]
+	s.mu.Unlock()
+	if ok && s.now().Sub(cached.loaded) < bindingTTL {
+		return cached.binding, nil
+	}
+
+	binding, err := s.load(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	s.cache(bucket, binding)
+	return binding, nil
+}
+
+func (s *Store) load(ctx context.Context, bucket string) (*Binding, error) {
+	resp, err := s.client.ForwardRequest(ctx, http.MethodGet, s.path(bucket), nil, http.Header{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load KMS binding: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to load KMS binding: HTTP %d", resp.StatusCode)
+	}
+	var binding Binding
+	if err := json.NewDecoder(resp.Body).Decode(&binding); err != nil {
+		return nil, fmt.Errorf("invalid KMS binding of %s: %w", bucket, err)
+	}
+	binding.Bucket = bucket
+	return &binding, nil
+}
+
+// Put binds bucket to kmsKeys, replacing its previous binding
+func (s *Store) Put(ctx context.Context, bucket string, kmsKeys []string) (*Binding, error) {
+	if len(kmsKeys) == 0 {
+		return nil, fmt.Errorf("a binding needs at least one KMS key")
+	}
+	binding := &Binding{Bucket: bucket, KMSKeys: kmsKeys}
+	body, err := json.Marshal(binding)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.ForwardRequest(ctx, http.MethodPut, s.path(bucket), bytes.NewReader(body), http.Header{
+		"Content-Type":   {"application/json"},
+		"Content-Length": {fmt.Sprint(len(body))},
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store KMS binding: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("failed to store KMS binding: HTTP %d", resp.StatusCode)
+	}
+	s.cache(bucket, binding)
+	return binding, nil
+}
+
+// Delete removes the binding of bucket
+func (s *Store) Delete(ctx context.Context, bucket string) error {
+	resp, err := s.client.ForwardRequest(ctx, http.MethodDelete, s.path(bucket), nil, http.Header{}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete KMS binding: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete KMS binding: HTTP %d", resp.StatusCode)
+	}
+	s.cache(bucket, nil)
+	return nil
+}
+
+func (s *Store) cache(bucket string, binding *Binding) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[bucket] = entry{binding: binding, loaded: s.now()}
+}
+
+func (s *Store) path(bucket string) string {
+	return s3.ObjectPath(s.bucket, Prefix+bucket+".json")
+}
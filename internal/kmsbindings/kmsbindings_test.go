@@ -0,0 +1,101 @@
+package kmsbindings
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/s3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	keyA = "arn:aws:kms:us-east-1:123456789012:key/a"
+	keyB = "arn:aws:kms:us-east-1:123456789012:key/b"
+)
+
+// fakeS3 keeps object bodies by path
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]string
+	gets    int
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		f.gets++
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, body)
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = string(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newStore(t *testing.T) (*fakeS3, *Store) {
+	backend := &fakeS3{objects: make(map[string]string)}
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+	return backend, NewStore(s3.NewClient(server.URL, "", s3.DefaultTransportConfig()), "config")
+}
+
+func TestStore(t *testing.T) {
+	backend, store := newStore(t)
+	ctx := context.Background()
+
+	assert.NoError(t, store.Check(ctx, "data", keyB), "unbound buckets accept any key")
+
+	binding, err := store.Put(ctx, "data", []string{keyA})
+	require.NoError(t, err)
+	assert.Equal(t, "data", binding.Bucket)
+	assert.Contains(t, backend.objects, "/config/.s3-vault-proxy/kms-bindings/data.json")
+
+	assert.NoError(t, store.Check(ctx, "data", keyA))
+	assert.ErrorIs(t, store.Check(ctx, "data", keyB), ErrKeyNotBound)
+	assert.NoError(t, store.Check(ctx, "other", keyB))
+
+	// Another replica loads the binding from the configuration bucket
+	_, replica := newStore(t)
+	replica.client = store.client
+	assert.ErrorIs(t, replica.Check(ctx, "data", keyB), ErrKeyNotBound)
+
+	require.NoError(t, store.Delete(ctx, "data"))
+	assert.NoError(t, store.Check(ctx, "data", keyB))
+
+	_, err = store.Put(ctx, "data", nil)
+	assert.Error(t, err, "empty bindings are rejected")
+}
+
+func TestStoreReloadsAfterTTL(t *testing.T) {
+	backend, store := newStore(t)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, err := store.Get(ctx, "data")
+	require.NoError(t, err)
+	_, err = store.Get(ctx, "data")
+	require.NoError(t, err)
+	assert.Equal(t, 1, backend.gets, "bindings are cached")
+
+	now = now.Add(bindingTTL)
+	_, err = store.Get(ctx, "data")
+	require.NoError(t, err)
+	assert.Equal(t, 2, backend.gets)
+}
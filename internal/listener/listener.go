@@ -0,0 +1,137 @@
+package listener
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"s3-vault-proxy/internal/config"
+)
+
+// Listen binds a TCP or unix socket listener, or takes one inherited from
+// systemd, wrapping it in TLS when configured. Connections accepted from it
+// remember their listener settings (see FromConn).
+func Listen(spec config.Listener) (net.Listener, error) {
+	var tlsConfig *tls.Config
+	if spec.TLS() {
+		var err error
+		if tlsConfig, err = newTLSConfig(spec); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := bind(spec)
+	if err != nil {
+		return nil, err
+	}
+	tagged := &taggedListener{Listener: ln, spec: spec}
+	if tlsConfig != nil {
+		return tls.NewListener(tagged, tlsConfig), nil
+	}
+	return tagged, nil
+}
+
+func bind(spec config.Listener) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(spec.Addr, "unix:"); ok {
+		return listenUnix(path, os.FileMode(spec.SocketMode))
+	}
+	if spec.Addr == "systemd" {
+		return systemdListener("")
+	}
+	if name, ok := strings.CutPrefix(spec.Addr, "systemd:"); ok {
+		return systemdListener(name)
+	}
+	return net.Listen("tcp", spec.Addr)
+}
+
+// listenUnix binds a unix socket, replacing a stale socket left by an unclean exit
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+	return ln, nil
+}
+
+// FromConn returns the settings of the listener that accepted conn
+func FromConn(conn net.Conn) (config.Listener, bool) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	if tagged, ok := conn.(*taggedConn); ok {
+		return tagged.spec, true
+	}
+	return config.Listener{}, false
+}
+
+// RawConn returns the network connection beneath TLS and listener tagging
+func RawConn(conn net.Conn) net.Conn {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	if tagged, ok := conn.(*taggedConn); ok {
+		conn = tagged.Conn
+	}
+	return conn
+}
+
+func newTLSConfig(spec config.Listener) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(spec.CertFile, spec.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate for %s: %w", spec.Addr, err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if spec.ClientCAFile != "" {
+		pem, err := os.ReadFile(spec.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA for %s: %w", spec.Addr, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", spec.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+type taggedListener struct {
+	net.Listener
+	spec config.Listener
+}
+
+func (l *taggedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &taggedConn{Conn: conn, spec: l.spec}, nil
+}
+
+type taggedConn struct {
+	net.Conn
+	spec config.Listener
+}
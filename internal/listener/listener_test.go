@@ -0,0 +1,74 @@
+package listener
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"s3-vault-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromConn(t *testing.T) {
+	spec := config.Listener{Addr: "127.0.0.1:0", RequireAuth: true}
+	ln, err := Listen(spec)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	got, ok := FromConn(conn)
+	require.True(t, ok)
+	assert.Equal(t, spec, got)
+	assert.IsType(t, &net.TCPConn{}, RawConn(conn))
+
+	_, ok = FromConn(&net.TCPConn{})
+	assert.False(t, ok)
+}
+
+func TestListenRejectsMissingCertificate(t *testing.T) {
+	_, err := Listen(config.Listener{Addr: "127.0.0.1:0", CertFile: "/nonexistent.crt", KeyFile: "/nonexistent.key"})
+	assert.Error(t, err)
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.sock")
+	spec := config.Listener{Addr: "unix:" + path, SocketMode: 0o600}
+
+	ln, err := Listen(spec)
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// A stale socket from an unclean exit is replaced
+	ln.(*taggedListener).Listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	ln, err = Listen(spec)
+	require.NoError(t, err)
+	ln.Close()
+
+	// Regular files are never removed
+	regular := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(regular, nil, 0o600))
+	_, err = Listen(config.Listener{Addr: "unix:" + regular})
+	assert.Error(t, err)
+}
+
+func TestSystemdWithoutActivation(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	_, err := listenFDs()
+	assert.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package listener
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd (SD_LISTEN_FDS_START)
+const listenFDsStart = 3
+
+var (
+	inheritedOnce sync.Once
+	inheritedMu   sync.Mutex
+	inherited     []namedListener
+	inheritedErr  error
+)
+
+type namedListener struct {
+	name     string
+	listener net.Listener
+}
+
+// systemdListener takes a socket passed by systemd socket activation. An
+// empty name takes the next unclaimed socket; otherwise the socket whose
+// FileDescriptorName matches.
+func systemdListener(name string) (net.Listener, error) {
+	inheritedOnce.Do(func() {
+		inherited, inheritedErr = listenFDs()
+	})
+	if inheritedErr != nil {
+		return nil, inheritedErr
+	}
+
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+	for i, candidate := range inherited {
+		if candidate.listener != nil && (name == "" || candidate.name == name) {
+			inherited[i].listener = nil
+			return candidate.listener, nil
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no unclaimed systemd socket (LISTEN_FDS)")
+	}
+	return nil, fmt.Errorf("no systemd socket named %q (LISTEN_FDNAMES)", name)
+}
+
+// listenFDs converts the sockets described by LISTEN_PID, LISTEN_FDS and
+// LISTEN_FDNAMES into listeners, following sd_listen_fds(3)
+func listenFDs() ([]namedListener, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, fmt.Errorf("no sockets passed by systemd (LISTEN_PID is not this process)")
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("no sockets passed by systemd (LISTEN_FDS)")
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	// Children must not inherit the activation environment
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]namedListener, 0, count)
+	for i := 0; i < count; i++ {
+		fd := listenFDsStart + i
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("systemd socket %d (%s) is not a listening socket: %w", fd, name, err)
+		}
+		listeners = append(listeners, namedListener{name: name, listener: ln})
+	}
+	return listeners, nil
+}
@@ -0,0 +1,163 @@
+// Package locations records the LocationConstraint each bucket was created
+// with. Records are kept as JSON objects in a configuration bucket, so every
+// proxy replica answers GetBucketLocation the same way, whichever backend
+// region the buckets actually live in.
+package locations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"s3-vault-proxy/internal/s3"
+)
+
+// Prefix is where locations are kept in the configuration bucket
+const Prefix = ".s3-vault-proxy/locations/"
+
+// DefaultRegion is the region S3 reports as an empty LocationConstraint
+const DefaultRegion = "us-east-1"
+
+// CreateBucketConfiguration is the optional body of a CreateBucket request
+type CreateBucketConfiguration struct {
+	XMLName            xml.Name `xml:"CreateBucketConfiguration"`
+	LocationConstraint string   `xml:"LocationConstraint"`
+}
+
+// LocationConstraint is the GetBucketLocation response
+type LocationConstraint struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+	Location string   `xml:",chardata"`
+}
+
+// ParseConfiguration returns the LocationConstraint of a CreateBucket body,
+// or "" when the body is empty or names none
+func ParseConfiguration(body []byte) (string, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return "", nil
+	}
+	var config CreateBucketConfiguration
+	if err := xml.Unmarshal(body, &config); err != nil {
+		return "", fmt.Errorf("invalid CreateBucketConfiguration: %w", err)
+	}
+	return strings.TrimSpace(config.LocationConstraint), nil
+}
+
+// Response returns the GetBucketLocation answer for region, which S3 leaves
+// empty for us-east-1
+func Response(region string) LocationConstraint {
+	if region == DefaultRegion {
+		region = ""
+	}
+	return LocationConstraint{Location: region}
+}
+
+// Location is the recorded location of a bucket
+type Location struct {
+	Bucket string `json:"bucket"`
+	Region string `json:"region"`
+}
+
+// Store loads and saves locations in a configuration bucket. A bucket's
+// location never changes, so loaded records are cached until deleted.
+type Store struct {
+	client s3.Interface
+	bucket string
+
+	mu      sync.Mutex
+	entries map[string]*Location
+}
+
+// NewStore keeps locations in bucket, reading and writing them with client
+func NewStore(client s3.Interface, bucket string) *Store {
+	return &Store{
+		client:  client,
+		bucket:  bucket,
+		entries: make(map[string]*Location),
+	}
+}
+
+// Get returns the location of bucket, or nil when none was recorded
+func (s *Store) Get(ctx context.Context, bucket string) (*Location, error) {
+	s.mu.Lock()
+	cached, ok := s.entries[bucket]
+	s.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	resp, err := s.client.ForwardRequest(ctx, http.MethodGet, s.path(bucket), nil, http.Header{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bucket location: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to load bucket location: HTTP %d", resp.StatusCode)
+	}
+	var location Location
+	if err := json.NewDecoder(resp.Body).Decode(&location); err != nil {
+		return nil, fmt.Errorf("invalid location of %s: %w", bucket, err)
+	}
+	location.Bucket = bucket
+	s.cache(bucket, &location)
+	return &location, nil
+}
+
+// Put records that bucket was created in region
+func (s *Store) Put(ctx context.Context, bucket, region string) error {
+	location := &Location{Bucket: bucket, Region: region}
+	body, err := json.Marshal(location)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.ForwardRequest(ctx, http.MethodPut, s.path(bucket), bytes.NewReader(body), http.Header{
+		"Content-Type":   {"application/json"},
+		"Content-Length": {fmt.Sprint(len(body))},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to store bucket location: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to store bucket location: HTTP %d", resp.StatusCode)
+	}
+	s.cache(bucket, location)
+	return nil
+}
+
+// Delete removes the location of bucket
+func (s *Store) Delete(ctx context.Context, bucket string) error {
+	resp, err := s.client.ForwardRequest(ctx, http.MethodDelete, s.path(bucket), nil, http.Header{}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete bucket location: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete bucket location: HTTP %d", resp.StatusCode)
+	}
+	s.mu.Lock()
+	delete(s.entries, bucket)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Store) cache(bucket string, location *Location) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[bucket] = location
+}
+
+func (s *Store) path(bucket string) string {
+	return s3.ObjectPath(s.bucket, Prefix+bucket+".json")
+}
@@ -0,0 +1,104 @@
+package locations
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"s3-vault-proxy/internal/s3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 keeps object bodies by path
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]string
+	gets    int
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		f.gets++
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, body)
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = string(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestStore(t *testing.T) {
+	backend := &fakeS3{objects: make(map[string]string)}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	store := NewStore(s3.NewClient(server.URL, "", s3.DefaultTransportConfig()), "config")
+	ctx := context.Background()
+
+	location, err := store.Get(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Nil(t, location, "buckets without a record have no location")
+
+	require.NoError(t, store.Put(ctx, "bucket", "eu-west-1"))
+	assert.Contains(t, backend.objects, "/config/"+Prefix+"bucket.json")
+
+	location, err = store.Get(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Equal(t, &Location{Bucket: "bucket", Region: "eu-west-1"}, location)
+
+	fresh := NewStore(s3.NewClient(server.URL, "", s3.DefaultTransportConfig()), "config")
+	location, err = fresh.Get(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-1", location.Region, "other replicas read the record")
+	gets := backend.gets
+	_, err = fresh.Get(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Equal(t, gets, backend.gets, "loaded locations are cached")
+
+	require.NoError(t, store.Delete(ctx, "bucket"))
+	location, err = store.Get(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Nil(t, location)
+}
+
+func TestParseConfiguration(t *testing.T) {
+	constraint, err := ParseConfiguration(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "", constraint)
+
+	constraint, err = ParseConfiguration([]byte(`<CreateBucketConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <LocationConstraint>eu-west-1</LocationConstraint>
+</CreateBucketConfiguration>`))
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-1", constraint)
+
+	_, err = ParseConfiguration([]byte("<CreateBucketConfiguration>"))
+	assert.Error(t, err)
+	_, err = ParseConfiguration([]byte("<Tagging/>"))
+	assert.Error(t, err, "other documents are not bucket configurations")
+}
+
+func TestResponse(t *testing.T) {
+	body, err := xml.Marshal(Response("us-east-1"))
+	require.NoError(t, err)
+	assert.Equal(t, `<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></LocationConstraint>`, string(body))
+
+	body, err = xml.Marshal(Response("eu-west-1"))
+	require.NoError(t, err)
+	assert.Contains(t, string(body), ">eu-west-1</LocationConstraint>")
+}
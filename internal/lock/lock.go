@@ -0,0 +1,258 @@
+// Package lock provides leases stored as objects on the S3 backend, so proxy
+// replicas and maintenance jobs can coordinate without another service. Mutual
+// exclusion relies on conditional writes: a lease is created with
+// If-None-Match: * and renewed, released or taken over with If-Match.
+package lock
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/s3"
+)
+
+var (
+	// ErrLocked is returned when another owner holds an unexpired lease
+	ErrLocked = errors.New("lock is held by another owner")
+
+	// ErrLost is returned when a lease was taken over or changed behind its holder's back
+	ErrLost = errors.New("lease was lost")
+)
+
+// DefaultPrefix is where lease objects are kept in the lock bucket
+const DefaultPrefix = ".s3-vault-proxy/locks/"
+
+// record is the content of a lease object
+type record struct {
+	Owner    string    `json:"owner"`
+	Acquired time.Time `json:"acquired"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Locker hands out leases on named locks kept in one bucket
+type Locker struct {
+	client s3.Interface
+	bucket string
+	prefix string
+	owner  string
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewLocker stores leases in bucket. Leases expire after ttl unless renewed.
+func NewLocker(client s3.Interface, bucket string, ttl time.Duration) *Locker {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return &Locker{
+		client: client,
+		bucket: bucket,
+		prefix: DefaultPrefix,
+		owner:  fmt.Sprintf("%s/%d/%s", host, os.Getpid(), hex.EncodeToString(suffix)),
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// Owner identifies this locker in lease objects
+func (l *Locker) Owner() string {
+	return l.owner
+}
+
+// TryAcquire takes the named lock if it is free or its lease has expired,
+// returning ErrLocked (wrapped with the holder) otherwise
+func (l *Locker) TryAcquire(name string) (*Lease, error) {
+	current, etag, err := l.read(name)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && l.now().Before(current.Expires) {
+		return nil, fmt.Errorf("%w: %s until %s", ErrLocked, current.Owner, current.Expires.Format(time.RFC3339))
+	}
+
+	// Create a new lease, or take over the expired one only if it is unchanged
+	condition := http.Header{"If-None-Match": {"*"}}
+	if current != nil {
+		condition = http.Header{"If-Match": {etag}}
+	}
+	lease := &Lease{locker: l, name: name, acquired: l.now()}
+	if err := lease.write(condition); err != nil {
+		if errors.Is(err, ErrLost) {
+			return nil, fmt.Errorf("%w: acquired concurrently by another owner", ErrLocked)
+		}
+		return nil, err
+	}
+	return lease, nil
+}
+
+// Acquire waits until the named lock can be taken, polling every interval
+func (l *Locker) Acquire(ctx context.Context, name string, interval time.Duration) (*Lease, error) {
+	for {
+		lease, err := l.TryAcquire(name)
+		if !errors.Is(err, ErrLocked) {
+			return lease, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// TryAcquireBuckets leases every bucket a maintenance job writes to, so the
+// same job started twice, or two jobs rewriting metadata of one bucket,
+// cannot interleave. The returned context is cancelled when a lease is lost;
+// release frees the leases.
+func (l *Locker) TryAcquireBuckets(ctx context.Context, buckets ...string) (context.Context, func(), error) {
+	// A sorted, duplicate-free order keeps two jobs from each holding half
+	sorted := append([]string(nil), buckets...)
+	sort.Strings(sorted)
+	var leases []*Lease
+	release := func() {
+		for _, lease := range leases {
+			lease.Release()
+		}
+	}
+	for i, bucket := range sorted {
+		if bucket == "" || (i > 0 && bucket == sorted[i-1]) {
+			continue
+		}
+		lease, err := l.TryAcquire("buckets/" + bucket)
+		if err != nil {
+			release()
+			return ctx, nil, fmt.Errorf("bucket %s: %w", bucket, err)
+		}
+		leases = append(leases, lease)
+		ctx = lease.KeepAlive(ctx)
+	}
+	return ctx, release, nil
+}
+
+// read returns the current lease record and its ETag, or nil when there is none
+func (l *Locker) read(name string) (*record, string, error) {
+	resp, err := l.client.ForwardRequest(context.Background(), "GET", l.path(name), nil, http.Header{}, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read lock %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to read lock %s: HTTP %d", name, resp.StatusCode)
+	}
+
+	var current record
+	if err := json.NewDecoder(resp.Body).Decode(&current); err != nil {
+		// An unreadable lease is treated as expired so it cannot wedge the lock
+		return &record{}, resp.Header.Get("ETag"), nil
+	}
+	return &current, resp.Header.Get("ETag"), nil
+}
+
+func (l *Locker) path(name string) string {
+	return s3.ObjectPath(l.bucket, l.prefix+name)
+}
+
+// Lease is a held lock
+type Lease struct {
+	locker   *Locker
+	name     string
+	acquired time.Time
+
+	mu      sync.Mutex
+	etag    string
+	expires time.Time
+}
+
+// Expires returns when the lease lapses unless renewed
+func (l *Lease) Expires() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.expires
+}
+
+// Renew extends the lease by the locker's TTL, returning ErrLost when
+// another owner took it over
+func (l *Lease) Renew() error {
+	l.mu.Lock()
+	condition := http.Header{"If-Match": {l.etag}}
+	l.mu.Unlock()
+	return l.write(condition)
+}
+
+// Release gives the lock up by expiring the lease, so the next owner takes
+// it over with a conditional write like any expired lease
+func (l *Lease) Release() error {
+	l.mu.Lock()
+	condition := http.Header{"If-Match": {l.etag}}
+	l.mu.Unlock()
+	return l.writeRecord(condition, l.locker.now())
+}
+
+// KeepAlive renews the lease every third of its TTL until ctx is done. The
+// returned context is cancelled as soon as a renewal fails, so work guarded
+// by the lease stops once exclusivity can no longer be guaranteed.
+func (l *Lease) KeepAlive(ctx context.Context) context.Context {
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		ticker := time.NewTicker(l.locker.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := l.Renew(); err != nil {
+					cancel(fmt.Errorf("lock %s: %w", l.name, err))
+					return
+				}
+			}
+		}
+	}()
+	return ctx
+}
+
+func (l *Lease) write(condition http.Header) error {
+	return l.writeRecord(condition, l.locker.now().Add(l.locker.ttl))
+}
+
+func (l *Lease) writeRecord(condition http.Header, expires time.Time) error {
+	data, _ := json.Marshal(record{Owner: l.locker.owner, Acquired: l.acquired, Expires: expires})
+	headers := condition.Clone()
+	headers.Set("Content-Type", "application/json")
+	headers.Set("Content-Length", strconv.Itoa(len(data)))
+
+	// Not bound to a job's context, so a lease can be released after the job is cancelled
+	resp, err := l.locker.client.ForwardRequest(context.Background(), "PUT", l.locker.path(l.name), bytes.NewReader(data), headers, nil)
+	if err != nil {
+		return fmt.Errorf("failed to write lock %s: %w", l.name, err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed || resp.StatusCode == http.StatusConflict:
+		return ErrLost
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("failed to write lock %s: HTTP %d", l.name, resp.StatusCode)
+	}
+
+	l.mu.Lock()
+	l.etag = resp.Header.Get("ETag")
+	l.expires = expires
+	l.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,188 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/s3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// conditionalBackend stores objects with ETags and honors If-None-Match: *
+// and If-Match on PUT, like S3 conditional writes
+type conditionalBackend struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	etags   map[string]string
+	version int
+}
+
+func newConditionalBackend(t *testing.T) s3.Interface {
+	backend := &conditionalBackend{objects: make(map[string][]byte), etags: make(map[string]string)}
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+	return s3.NewClient(server.URL, "", s3.DefaultTransportConfig())
+}
+
+func (b *conditionalBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	etag, exists := b.etags[r.URL.Path]
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write(b.objects[r.URL.Path])
+	case http.MethodPut:
+		if (r.Header.Get("If-None-Match") == "*" && exists) ||
+			(r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != etag) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		b.version++
+		b.objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		b.etags[r.URL.Path] = fmt.Sprintf(`"v%d"`, b.version)
+		w.Header().Set("ETag", b.etags[r.URL.Path])
+	}
+}
+
+// clock is a manually advanced time source shared by test lockers
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestLocker returns a locker driven by the given clock
+func newTestLocker(client s3.Interface, owner string, clk *clock) *Locker {
+	locker := NewLocker(client, "locks", time.Minute)
+	locker.owner = owner
+	locker.now = clk.Now
+	return locker
+}
+
+func TestTryAcquire(t *testing.T) {
+	client := newConditionalBackend(t)
+	clk := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	a := newTestLocker(client, "a", clk)
+	b := newTestLocker(client, "b", clk)
+
+	lease, err := a.TryAcquire("job")
+	require.NoError(t, err)
+	assert.Equal(t, clk.Now().Add(time.Minute), lease.Expires())
+
+	_, err = b.TryAcquire("job")
+	assert.ErrorIs(t, err, ErrLocked)
+	assert.ErrorContains(t, err, "a until")
+
+	_, err = b.TryAcquire("other-job")
+	assert.NoError(t, err, "locks are independent")
+
+	// Renewing keeps the lease past its original expiry
+	clk.Advance(50 * time.Second)
+	require.NoError(t, lease.Renew())
+	clk.Advance(50 * time.Second)
+	_, err = b.TryAcquire("job")
+	assert.ErrorIs(t, err, ErrLocked)
+
+	// Once expired, another owner takes over and the old holder has lost it
+	clk.Advance(time.Minute)
+	taken, err := b.TryAcquire("job")
+	require.NoError(t, err)
+	assert.ErrorIs(t, lease.Renew(), ErrLost)
+
+	// Releasing frees the lock at once
+	require.NoError(t, taken.Release())
+	_, err = a.TryAcquire("job")
+	assert.NoError(t, err)
+}
+
+func TestTryAcquireBuckets(t *testing.T) {
+	client := newConditionalBackend(t)
+	clk := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	a := newTestLocker(client, "a", clk)
+	b := newTestLocker(client, "b", clk)
+
+	_, release, err := a.TryAcquireBuckets(context.Background(), "photos", "logs", "photos")
+	require.NoError(t, err, "duplicate buckets are leased once")
+
+	_, _, err = b.TryAcquireBuckets(context.Background(), "archive", "photos")
+	assert.ErrorIs(t, err, ErrLocked)
+	assert.ErrorContains(t, err, "bucket photos")
+	_, err = b.TryAcquire("buckets/archive")
+	assert.NoError(t, err, "leases taken before the conflict are released")
+
+	release()
+	_, _, err = b.TryAcquireBuckets(context.Background(), "logs", "photos")
+	assert.NoError(t, err)
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	client := newConditionalBackend(t)
+	a := NewLocker(client, "locks", time.Minute)
+	b := NewLocker(client, "locks", time.Minute)
+
+	lease, err := a.TryAcquire("job")
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		lease.Release()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = b.Acquire(ctx, "job", 10*time.Millisecond)
+	assert.NoError(t, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = a.Acquire(ctx, "job", 10*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestKeepAliveCancelsWhenLost(t *testing.T) {
+	client := newConditionalBackend(t)
+	clk := &clock{now: time.Now()}
+	a := newTestLocker(client, "a", clk)
+	a.ttl = 30 * time.Millisecond
+	b := newTestLocker(client, "b", clk)
+
+	lease, err := a.TryAcquire("job")
+	require.NoError(t, err)
+	ctx := lease.KeepAlive(context.Background())
+
+	// Another owner takes the lease over behind the holder's back
+	clk.Advance(time.Hour)
+	_, err = b.TryAcquire("job")
+	require.NoError(t, err)
+
+	select {
+	case <-ctx.Done():
+		assert.ErrorIs(t, context.Cause(ctx), ErrLost)
+	case <-time.After(5 * time.Second):
+		t.Fatal("keep-alive did not notice the lost lease")
+	}
+}
@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+type contextKey struct{}
+
+// WithContext returns a context carrying logger, so everything logged while
+// serving a request shares the request's fields
+func WithContext(ctx context.Context, logger zerolog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &logger)
+}
+
+// FromContext returns the request-scoped logger of ctx, or the global logger
+// outside a request
+func FromContext(ctx context.Context) *zerolog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*zerolog.Logger); ok {
+			return logger
+		}
+	}
+	return GetLogger().Logger
+}
+
+// WithField returns ctx with its logger extended by a field, for details
+// learned while the request is served (such as its tenant)
+func WithField(ctx context.Context, name, value string) context.Context {
+	return WithContext(ctx, FromContext(ctx).With().Str(name, value).Logger())
+}
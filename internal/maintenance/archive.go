@@ -0,0 +1,330 @@
+package maintenance
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"s3-vault-proxy/internal/etag"
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/pkg/types"
+)
+
+// Export and restore outcomes counted by the progress reporter
+const (
+	OutcomeExported = "exported"
+	OutcomeRestored = "restored"
+)
+
+// Archive layout: manifest.json comes first, then for every object an
+// optional metadata/<key> entry holding its ObjectMetadata followed by an
+// objects/<key> entry holding its sealed body
+const (
+	archiveVersion   = 1
+	manifestEntry    = "manifest.json"
+	metadataPrefix   = "metadata/"
+	objectsPrefix    = "objects/"
+	paxNonce         = "S3VAULTPROXY.nonce"
+	paxPlaintextSize = "S3VAULTPROXY.size"
+)
+
+// Encrypter encrypts data keys with Vault's transit engine
+type Encrypter interface {
+	ARNToVaultKey(arn string) (string, error)
+	Encrypt(ctx context.Context, data []byte, transitKey string) (string, error)
+}
+
+// Manifest describes an export archive. The archive data key is only stored
+// wrapped by the transit key behind KMSKeyARN.
+type Manifest struct {
+	Version    int    `json:"version"`
+	Bucket     string `json:"bucket"`
+	Prefix     string `json:"prefix,omitempty"`
+	KMSKeyARN  string `json:"kms_key_arn"`
+	WrappedKey string `json:"wrapped_key"`
+	Created    string `json:"created"`
+}
+
+// ExportOptions configures an export run
+type ExportOptions struct {
+	Bucket    string
+	Prefix    string
+	KMSKeyARN string // key sealing the archive
+	Rate      float64
+	Progress  *Progress
+}
+
+// Export writes every object of a bucket with its metadata into a tar
+// archive on out. Object bodies are sealed with a fresh data key as they
+// stream through, so the archive never holds plaintext; restoring it needs
+// Vault access to the KMS key the archive was sealed with.
+func Export(ctx context.Context, client s3.Interface, encrypter Encrypter, out io.Writer, opts ExportOptions) error {
+	transitKey, err := encrypter.ARNToVaultKey(opts.KMSKeyARN)
+	if err != nil {
+		return err
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return fmt.Errorf("failed to generate data key: %w", err)
+	}
+	wrappedKey, err := encrypter.Encrypt(ctx, dataKey, transitKey)
+	if err != nil {
+		return fmt.Errorf("failed to wrap the archive data key: %w", err)
+	}
+
+	limiter := newLimiter(opts.Rate)
+	progress := opts.Progress
+	if progress == nil {
+		progress = NewProgress(nil, 0)
+	}
+	metadataService := metadata.NewService(client)
+
+	archive := tar.NewWriter(out)
+	manifest, _ := json.Marshal(Manifest{
+		Version:    archiveVersion,
+		Bucket:     opts.Bucket,
+		Prefix:     opts.Prefix,
+		KMSKeyARN:  opts.KMSKeyARN,
+		WrappedKey: wrappedKey,
+		Created:    time.Now().UTC().Format(time.RFC3339),
+	})
+	if err := writeArchiveFile(archive, manifestEntry, manifest); err != nil {
+		return err
+	}
+
+	err = s3.WalkObjects(ctx, client, opts.Bucket, opts.Prefix, "", func(object s3.ObjectInfo) error {
+		if metadata.IsMetadataKey(object.Key) {
+			return nil
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		outcome, err := exportObject(ctx, client, metadataService, archive, dataKey, opts.Bucket, object.Key)
+		if err != nil {
+			progress.Logf("FAILED %s/%s: %v", opts.Bucket, object.Key, err)
+		}
+		progress.Add(outcome)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// exportObject appends one object to the archive. Failures before the body
+// starts streaming skip the object; a failure mid-body corrupts the archive
+// and is returned through the tar writer on the next write.
+func exportObject(ctx context.Context, client s3.Interface, metadataService *metadata.Service, archive *tar.Writer, dataKey []byte, bucket, key string) (string, error) {
+	meta, err := metadataService.Get(ctx, bucket, key, http.Header{})
+	if err != nil && !errors.Is(err, metadata.ErrNotFound) {
+		return OutcomeFailed, err
+	}
+
+	resp, err := client.ForwardRequest(ctx, "GET", s3.ObjectPath(bucket, key), nil, http.Header{}, nil)
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return OutcomeFailed, fmt.Errorf("GET returned HTTP %d", resp.StatusCode)
+	}
+	if resp.ContentLength < 0 {
+		return OutcomeFailed, fmt.Errorf("backend did not report the object size")
+	}
+
+	if meta != nil {
+		data, _ := json.Marshal(meta)
+		if err := writeArchiveFile(archive, metadataPrefix+key, data); err != nil {
+			return OutcomeFailed, err
+		}
+	}
+
+	nonce := make([]byte, sealNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return OutcomeFailed, err
+	}
+	err = archive.WriteHeader(&tar.Header{
+		Name:    objectsPrefix + key,
+		Mode:    0o600,
+		Size:    sealedSize(resp.ContentLength),
+		ModTime: time.Now(),
+		Format:  tar.FormatPAX,
+		PAXRecords: map[string]string{
+			paxNonce:         base64.StdEncoding.EncodeToString(nonce),
+			paxPlaintextSize: strconv.FormatInt(resp.ContentLength, 10),
+		},
+	})
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	sealer, err := newSealWriter(archive, dataKey, nonce)
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	if _, err := io.Copy(sealer, resp.Body); err != nil {
+		return OutcomeFailed, err
+	}
+	if err := sealer.Close(); err != nil {
+		return OutcomeFailed, err
+	}
+	return OutcomeExported, nil
+}
+
+func writeArchiveFile(archive *tar.Writer, name string, data []byte) error {
+	err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: time.Now(), Format: tar.FormatPAX})
+	if err != nil {
+		return err
+	}
+	_, err = archive.Write(data)
+	return err
+}
+
+// Decrypter decrypts the data keys archives are sealed with with Vault's transit engine
+type Decrypter interface {
+	ARNToVaultKey(arn string) (string, error)
+	Decrypt(ctx context.Context, ciphertext string, transitKey string) ([]byte, error)
+}
+
+// RestoreOptions configures a restore run
+type RestoreOptions struct {
+	Bucket   string // "" restores into the bucket the archive was exported from
+	Rate     float64
+	Progress *Progress
+}
+
+// Restore uploads every object of an export archive read from in, encrypting
+// it under the KMS key named in its metadata as the proxy does for uploads,
+// and stores the metadata alongside. Objects exported without metadata are
+// restored without server-side encryption, as they were found.
+func Restore(ctx context.Context, client s3.Interface, decrypter Decrypter, in io.Reader, opts RestoreOptions) error {
+	archive := tar.NewReader(in)
+	header, err := archive.Next()
+	if err != nil || header.Name != manifestEntry {
+		return fmt.Errorf("not an export archive: missing %s", manifestEntry)
+	}
+	var manifest Manifest
+	if err := json.NewDecoder(archive).Decode(&manifest); err != nil {
+		return fmt.Errorf("invalid archive manifest: %w", err)
+	}
+	if manifest.Version != archiveVersion {
+		return fmt.Errorf("unsupported archive version %d", manifest.Version)
+	}
+	transitKey, err := decrypter.ARNToVaultKey(manifest.KMSKeyARN)
+	if err != nil {
+		return err
+	}
+	dataKey, err := decrypter.Decrypt(ctx, manifest.WrappedKey, transitKey)
+	if err != nil {
+		return fmt.Errorf("failed to unwrap the archive data key: %w", err)
+	}
+
+	bucket := opts.Bucket
+	if bucket == "" {
+		bucket = manifest.Bucket
+	}
+	limiter := newLimiter(opts.Rate)
+	progress := opts.Progress
+	if progress == nil {
+		progress = NewProgress(nil, 0)
+	}
+	metadataService := metadata.NewService(client)
+
+	var pending *types.ObjectMetadata
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		switch {
+		case strings.HasPrefix(header.Name, metadataPrefix):
+			pending = new(types.ObjectMetadata)
+			if err := json.NewDecoder(archive).Decode(pending); err != nil {
+				return fmt.Errorf("invalid metadata entry %s: %w", header.Name, err)
+			}
+		case strings.HasPrefix(header.Name, objectsPrefix):
+			key := strings.TrimPrefix(header.Name, objectsPrefix)
+			meta := pending
+			pending = nil
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+			if err := restoreObject(ctx, client, metadataService, archive, header, dataKey, meta, bucket, key); err != nil {
+				progress.Logf("FAILED %s/%s: %v", bucket, key, err)
+				progress.Add(OutcomeFailed)
+				continue
+			}
+			progress.Add(OutcomeRestored)
+		default:
+			return fmt.Errorf("unexpected archive entry %s", path.Clean(header.Name))
+		}
+	}
+}
+
+// restoreObject uploads one sealed object body and stores its metadata
+func restoreObject(ctx context.Context, client s3.Interface, metadataService *metadata.Service, body io.Reader, header *tar.Header, dataKey []byte, meta *types.ObjectMetadata, bucket, key string) error {
+	nonce, err := base64.StdEncoding.DecodeString(header.PAXRecords[paxNonce])
+	if err != nil || len(nonce) != sealNonceSize {
+		return fmt.Errorf("missing or invalid nonce")
+	}
+	size, err := strconv.ParseInt(header.PAXRecords[paxPlaintextSize], 10, 64)
+	if err != nil || sealedSize(size) != header.Size {
+		return fmt.Errorf("missing or invalid plaintext size")
+	}
+	opened, err := newOpenReader(body, dataKey, nonce)
+	if err != nil {
+		return err
+	}
+	plaintext := etag.NewReader(opened)
+
+	headers := http.Header{"Content-Length": {strconv.FormatInt(size, 10)}}
+	if meta != nil {
+		if meta.ContentType != "" {
+			headers.Set("Content-Type", meta.ContentType)
+		}
+		meta.WriteHTTPHeaders(headers)
+		if meta.KMSKeyARN != "" {
+			headers.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+			headers.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", meta.KMSKeyARN)
+			if len(meta.EncryptionContext) > 0 {
+				headers.Set("X-Amz-Server-Side-Encryption-Context", s3.FormatEncryptionContext(meta.EncryptionContext))
+			}
+		}
+	}
+	put, err := client.ForwardRequest(ctx, "PUT", s3.ObjectPath(bucket, key), plaintext, headers, nil)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, put.Body)
+	put.Body.Close()
+	if put.StatusCode >= 300 {
+		return fmt.Errorf("PUT returned HTTP %d", put.StatusCode)
+	}
+	// The upload must have consumed and authenticated the whole sealed body
+	if _, err := io.Copy(io.Discard, plaintext); err != nil {
+		return err
+	}
+
+	if meta == nil {
+		return nil
+	}
+	meta.ETag = plaintext.ETag()
+	meta.LastModified = types.NormalizeLastModified(meta.LastModified)
+	return metadataService.Store(ctx, bucket, key, meta, http.Header{})
+}
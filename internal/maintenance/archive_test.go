@@ -0,0 +1,78 @@
+package maintenance
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVault "wraps" data keys by base64 encoding them
+type fakeVault struct{}
+
+func (fakeVault) ARNToVaultKey(arn string) (string, error) { return "transit-key", nil }
+
+func (fakeVault) Encrypt(ctx context.Context, data []byte, transitKey string) (string, error) {
+	return "vault:v1:" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+func (fakeVault) Decrypt(ctx context.Context, ciphertext, transitKey string) ([]byte, error) {
+	if !strings.HasPrefix(ciphertext, "vault:v1:") {
+		return nil, errors.New("invalid ciphertext")
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, "vault:v1:"))
+}
+
+func TestExportRestore(t *testing.T) {
+	source, sourceClient := newFakeBackend(t)
+	putMetadata(t, source, "bucket", "docs/a.txt", types.ObjectMetadata{ContentLength: 10, ContentType: "text/plain", KMSKeyARN: testARN, EncryptionContext: map[string]string{"tenant": "a"}})
+	source.put("bucket", "plain", "no metadata")
+
+	var archive bytes.Buffer
+	progress := NewProgress(nil, 0)
+	err := Export(context.Background(), sourceClient, fakeVault{}, &archive, ExportOptions{
+		Bucket:    "bucket",
+		KMSKeyARN: testARN,
+		Progress:  progress,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, progress.Count(OutcomeExported))
+	assert.NotContains(t, archive.String(), "ciphertext", "object bodies are sealed")
+	assert.NotContains(t, archive.String(), "no metadata")
+
+	dest, destClient := newFakeBackend(t)
+	progress = NewProgress(nil, 0)
+	err = Restore(context.Background(), destClient, fakeVault{}, &archive, RestoreOptions{Bucket: "restored", Progress: progress})
+	require.NoError(t, err)
+	assert.Equal(t, 2, progress.Count(OutcomeRestored))
+
+	body, ok := dest.get("restored", "docs/a.txt")
+	require.True(t, ok)
+	assert.Equal(t, "ciphertext", body)
+	assert.Equal(t, testARN, dest.sse["restored/docs/a.txt"])
+	assert.Equal(t, s3.FormatEncryptionContext(map[string]string{"tenant": "a"}), dest.contexts["restored/docs/a.txt"])
+	meta := readMetadata(t, dest, "restored", "docs/a.txt")
+	assert.Equal(t, "text/plain", meta.ContentType)
+	assert.Equal(t, `"cb54616748fddc2fb607b9eb4312ee3d"`, meta.ETag, "the plaintext MD5, not the backend's ETag")
+
+	body, ok = dest.get("restored", "plain")
+	require.True(t, ok)
+	assert.Equal(t, "no metadata", body)
+	assert.NotContains(t, dest.sse, "restored/plain")
+	_, ok = dest.get("restored", "plain.metadata")
+	assert.False(t, ok)
+}
+
+func TestRestoreRejectsOtherArchives(t *testing.T) {
+	_, client := newFakeBackend(t)
+	err := Restore(context.Background(), client, fakeVault{}, strings.NewReader("not a tar"), RestoreOptions{})
+	assert.ErrorContains(t, err, "not an export archive")
+}
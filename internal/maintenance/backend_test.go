@@ -0,0 +1,123 @@
+package maintenance
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"s3-vault-proxy/internal/s3"
+)
+
+// fakeBackend is an in-memory path-style S3 backend supporting object
+// GET/PUT/HEAD/DELETE, CopyObject and ListObjectsV2
+type fakeBackend struct {
+	mu       sync.Mutex
+	objects  map[string][]byte      // "bucket/key"
+	sse      map[string]string      // SSE-KMS key of objects uploaded with one
+	copies   map[string]http.Header // headers of the last copy to each object
+	contexts map[string]string      // encryption contexts of the last upload to each object
+	errors   map[string]string      // messages of the InternalErrors GETs are answered with
+	puts     int
+}
+
+func newFakeBackend(t *testing.T) (*fakeBackend, s3.Interface) {
+	backend := &fakeBackend{objects: make(map[string][]byte), sse: make(map[string]string), copies: make(map[string]http.Header), contexts: make(map[string]string), errors: make(map[string]string)}
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+	return backend, s3.NewClient(server.URL, "", s3.DefaultTransportConfig())
+}
+
+func (b *fakeBackend) put(bucket, key, body string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.objects[bucket+"/"+key] = []byte(body)
+}
+
+func (b *fakeBackend) get(bucket, key string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	body, ok := b.objects[bucket+"/"+key]
+	return string(body), ok
+}
+
+func (b *fakeBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if key == "" {
+		b.list(w, r, bucket)
+		return
+	}
+
+	name := bucket + "/" + key
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			body = b.objects[strings.TrimPrefix(source, "/")]
+			b.copies[name] = r.Header.Clone()
+		}
+		b.objects[name] = body
+		b.contexts[name] = r.Header.Get("X-Amz-Server-Side-Encryption-Context")
+		b.puts++
+		if kmsKey := r.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); kmsKey != "" {
+			b.sse[name] = kmsKey
+		} else {
+			delete(b.sse, name)
+		}
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet, http.MethodHead:
+		body, ok := b.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if message, ok := b.errors[name]; ok && r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "<Error><Code>InternalError</Code><Message>%s</Message></Error>", message)
+			return
+		}
+		if kmsKey, ok := b.sse[name]; ok {
+			w.Header().Set("X-Amz-Server-Side-Encryption", "aws:kms")
+			w.Header().Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", kmsKey)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	case http.MethodDelete:
+		delete(b.objects, name)
+		delete(b.sse, name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (b *fakeBackend) list(w http.ResponseWriter, r *http.Request, bucket string) {
+	type content struct {
+		Key  string `xml:"Key"`
+		Size int    `xml:"Size"`
+	}
+	var result struct {
+		XMLName  xml.Name  `xml:"ListBucketResult"`
+		Contents []content `xml:"Contents"`
+	}
+
+	query := r.URL.Query()
+	for name, body := range b.objects {
+		key, ok := strings.CutPrefix(name, bucket+"/")
+		if !ok || !strings.HasPrefix(key, query.Get("prefix")) || key <= query.Get("start-after") {
+			continue
+		}
+		result.Contents = append(result.Contents, content{Key: key, Size: len(body)})
+	}
+	sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+	xml.NewEncoder(w).Encode(result)
+}
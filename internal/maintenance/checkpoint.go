@@ -0,0 +1,80 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Checkpoint records the last key processed in each bucket so an interrupted
+// run resumes where it stopped. A Checkpoint without a path keeps nothing.
+type Checkpoint struct {
+	mu   sync.Mutex
+	path string
+	last map[string]string
+}
+
+// LoadCheckpoint reads a checkpoint file. A missing file starts from the beginning.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{path: path, last: make(map[string]string)}
+	if path == "" {
+		return checkpoint, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &checkpoint.last); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	return checkpoint, nil
+}
+
+// StartAfter returns the last key recorded for bucket, or "" to start at the beginning
+func (c *Checkpoint) StartAfter(bucket string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last[bucket]
+}
+
+// Record marks key as processed. Keys must be recorded in listing order.
+func (c *Checkpoint) Record(bucket, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.last[bucket] = key
+}
+
+// Save writes the checkpoint atomically so a crash never leaves a torn file
+func (c *Checkpoint) Save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c.last, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".checkpoint-*")
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
@@ -0,0 +1,191 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/pkg/types"
+)
+
+// Problems reported by Fsck in addition to those shared with Verify
+const (
+	ProblemTruncated     = "truncated"
+	ProblemPermission    = "permission_denied"
+	ProblemUnsanitaryKey = "unsanitary_key"
+)
+
+// quarantineDir is created under the root to hold quarantined entries
+const quarantineDir = ".quarantine"
+
+// maxKeyLength is the S3 limit on object key length in bytes
+const maxKeyLength = 1024
+
+// FsckOptions configures a filesystem consistency check
+type FsckOptions struct {
+	Root       string    // directory holding one subdirectory per bucket
+	Quarantine bool      // move bad entries under Root/.quarantine
+	Report     io.Writer // receives one JSON Discrepancy per line
+	Progress   *Progress
+}
+
+// Fsck checks a bucket directory tree laid out as <root>/<bucket>/<key>, with
+// each object's metadata in a <key>.metadata sibling. It reports objects that
+// are shorter than their metadata says, metadata without an object and the
+// reverse, unreadable entries and keys that would not survive sanitization.
+func Fsck(ctx context.Context, opts FsckOptions) error {
+	progress := opts.Progress
+	if progress == nil {
+		progress = NewProgress(nil, 0)
+	}
+	report := json.NewEncoder(opts.Report)
+
+	buckets, err := os.ReadDir(opts.Root)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", opts.Root, err)
+	}
+	for _, bucket := range buckets {
+		if !bucket.IsDir() || bucket.Name() == quarantineDir {
+			continue
+		}
+		bucketDir := filepath.Join(opts.Root, bucket.Name())
+		err := filepath.WalkDir(bucketDir, func(path string, entry fs.DirEntry, err error) error {
+			key := filepath.ToSlash(strings.TrimPrefix(path, bucketDir+string(filepath.Separator)))
+			if err != nil {
+				if errors.Is(err, fs.ErrPermission) {
+					return fsckReport(report, progress, opts, bucket.Name(), key, ProblemPermission, err.Error())
+				}
+				return err
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			if _, err := os.Lstat(path); errors.Is(err, fs.ErrNotExist) {
+				// Quarantined along with its object earlier in the walk
+				return nil
+			}
+
+			problem, detail := fsckEntry(path, key)
+			if problem == "" {
+				progress.Add(OutcomeOK)
+				return nil
+			}
+			return fsckReport(report, progress, opts, bucket.Name(), key, problem, detail)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fsckEntry checks a single file, returning the first problem found
+func fsckEntry(path, key string) (problem, detail string) {
+	if reason := keyProblem(strings.TrimSuffix(key, ".metadata")); reason != "" {
+		return ProblemUnsanitaryKey, reason
+	}
+
+	if metadata.IsMetadataKey(key) {
+		if _, err := os.Lstat(strings.TrimSuffix(path, ".metadata")); errors.Is(err, fs.ErrNotExist) {
+			return ProblemOrphanedMetadata, ""
+		}
+		return "", ""
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return ProblemUnreadable, err.Error()
+	}
+	data, err := os.ReadFile(path + ".metadata")
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return ProblemMissingMetadata, ""
+	case errors.Is(err, fs.ErrPermission):
+		return ProblemPermission, err.Error()
+	case err != nil:
+		return ProblemUnreadable, err.Error()
+	}
+	if file, err := os.Open(path); err != nil {
+		return ProblemPermission, err.Error()
+	} else {
+		file.Close()
+	}
+
+	var meta types.ObjectMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return ProblemInvalidMetadata, err.Error()
+	}
+	switch {
+	case info.Size() < meta.ContentLength:
+		return ProblemTruncated, fmt.Sprintf("metadata %d bytes, file %d bytes", meta.ContentLength, info.Size())
+	case info.Size() > meta.ContentLength:
+		return ProblemSizeMismatch, fmt.Sprintf("metadata %d bytes, file %d bytes", meta.ContentLength, info.Size())
+	}
+	return "", ""
+}
+
+// keyProblem explains why a key could not be stored safely, or returns ""
+func keyProblem(key string) string {
+	switch {
+	case len(key) > maxKeyLength:
+		return fmt.Sprintf("longer than %d bytes", maxKeyLength)
+	case !utf8.ValidString(key):
+		return "not valid UTF-8"
+	case strings.IndexFunc(key, unicode.IsControl) >= 0:
+		return "contains control characters"
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "." || segment == ".." {
+			return "contains a relative path segment"
+		}
+	}
+	return ""
+}
+
+// fsckReport writes a discrepancy and quarantines the entry when requested
+func fsckReport(report *json.Encoder, progress *Progress, opts FsckOptions, bucket, key, problem, detail string) error {
+	progress.Add(OutcomeProblem)
+	if opts.Quarantine && problem != ProblemPermission {
+		if err := quarantine(opts.Root, bucket, key); err != nil {
+			detail = strings.TrimSpace(detail + " (quarantine failed: " + err.Error() + ")")
+		} else {
+			detail = strings.TrimSpace(detail + " (quarantined)")
+		}
+	}
+	if err := report.Encode(Discrepancy{Bucket: bucket, Key: key, Problem: problem, Detail: detail}); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// quarantine moves an entry to Root/.quarantine/<bucket>/<key>, keeping its
+// path, and takes an object's metadata along so the pair stays together
+func quarantine(root, bucket, key string) error {
+	source := filepath.Join(root, bucket, filepath.FromSlash(key))
+	target := filepath.Join(root, quarantineDir, bucket, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return err
+	}
+	if err := os.Rename(source, target); err != nil {
+		return err
+	}
+	if metadata.IsMetadataKey(key) {
+		return nil
+	}
+	if err := os.Rename(source+".metadata", target+".metadata"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
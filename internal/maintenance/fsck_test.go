@@ -0,0 +1,82 @@
+package maintenance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, root, path, content string) {
+	t.Helper()
+	path = filepath.Join(root, filepath.FromSlash(path))
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestFsck(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "bucket/good", "ciphertext")
+	writeFile(t, root, "bucket/good.metadata", `{"content_length":10}`)
+	writeFile(t, root, "bucket/dir/short", "cipher")
+	writeFile(t, root, "bucket/dir/short.metadata", `{"content_length":10}`)
+	writeFile(t, root, "bucket/long", "ciphertext and more")
+	writeFile(t, root, "bucket/long.metadata", `{"content_length":10}`)
+	writeFile(t, root, "bucket/corrupt", "ciphertext")
+	writeFile(t, root, "bucket/corrupt.metadata", "{not json")
+	writeFile(t, root, "bucket/bare", "ciphertext")
+	writeFile(t, root, "bucket/gone.metadata", "{}")
+	writeFile(t, root, "bucket/bad\x01key", "ciphertext")
+
+	var report bytes.Buffer
+	progress := NewProgress(nil, 0)
+	err := Fsck(context.Background(), FsckOptions{Root: root, Quarantine: true, Report: &report, Progress: progress})
+	require.NoError(t, err)
+
+	problems := make(map[string]string)
+	decoder := json.NewDecoder(&report)
+	for decoder.More() {
+		var discrepancy Discrepancy
+		require.NoError(t, decoder.Decode(&discrepancy))
+		assert.Equal(t, "bucket", discrepancy.Bucket)
+		problems[discrepancy.Key] = discrepancy.Problem
+	}
+	assert.Equal(t, map[string]string{
+		"bad\x01key":    ProblemUnsanitaryKey,
+		"bare":          ProblemMissingMetadata,
+		"corrupt":       ProblemInvalidMetadata,
+		"dir/short":     ProblemTruncated,
+		"gone.metadata": ProblemOrphanedMetadata,
+		"long":          ProblemSizeMismatch,
+	}, problems)
+	assert.Equal(t, 2, progress.Count(OutcomeOK))
+
+	// Bad entries were moved aside together with their metadata
+	assert.FileExists(t, filepath.Join(root, quarantineDir, "bucket", "dir", "short"))
+	assert.FileExists(t, filepath.Join(root, quarantineDir, "bucket", "dir", "short.metadata"))
+	assert.NoFileExists(t, filepath.Join(root, "bucket", "dir", "short"))
+	assert.FileExists(t, filepath.Join(root, "bucket", "good"))
+}
+
+func TestFsck_QuarantineIsSkippedOnRerun(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "bucket/bare", "ciphertext")
+
+	require.NoError(t, Fsck(context.Background(), FsckOptions{Root: root, Quarantine: true, Report: &bytes.Buffer{}}))
+
+	var report bytes.Buffer
+	require.NoError(t, Fsck(context.Background(), FsckOptions{Root: root, Report: &report}))
+	assert.Empty(t, report.String())
+}
+
+func TestKeyProblem(t *testing.T) {
+	assert.Empty(t, keyProblem("photos/2024/cat.jpg"))
+	assert.NotEmpty(t, keyProblem("a/../b"))
+	assert.NotEmpty(t, keyProblem("tab\tkey"))
+	assert.NotEmpty(t, keyProblem("\xff"))
+}
@@ -0,0 +1,152 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/s3"
+
+	"golang.org/x/time/rate"
+)
+
+// GC outcomes counted by the progress reporter
+const (
+	OutcomeOrphanDeleted     = "orphans_deleted"
+	OutcomeWouldDeleteOrphan = "orphans_found"
+	OutcomeUploadAborted     = "uploads_aborted"
+	OutcomeWouldAbortUpload  = "uploads_found"
+)
+
+// GCOptions configures a garbage collection run
+type GCOptions struct {
+	Buckets []string
+	// UploadsOlderThan aborts multipart uploads initiated longer ago; 0 leaves uploads alone
+	UploadsOlderThan time.Duration
+	DryRun           bool
+	Rate             float64 // backend deletes per second, 0 is unlimited
+	Progress         *Progress
+}
+
+// GC deletes metadata objects whose object no longer exists and aborts
+// abandoned multipart uploads, releasing their stored parts
+func GC(ctx context.Context, client s3.Interface, opts GCOptions) error {
+	limiter := newLimiter(opts.Rate)
+	progress := opts.Progress
+	if progress == nil {
+		progress = NewProgress(nil, 0)
+	}
+
+	for _, bucket := range opts.Buckets {
+		err := s3.WalkObjects(ctx, client, bucket, "", "", func(object s3.ObjectInfo) error {
+			if !metadata.IsMetadataKey(object.Key) {
+				return nil
+			}
+			key := strings.TrimSuffix(object.Key, ".metadata")
+			orphaned, err := isOrphaned(ctx, client, bucket, key)
+			if err != nil {
+				progress.Logf("FAILED %s/%s: %v", bucket, object.Key, err)
+				progress.Add(OutcomeFailed)
+				return nil
+			}
+			if !orphaned {
+				return nil
+			}
+			if opts.DryRun {
+				progress.Logf("orphaned metadata %s/%s", bucket, object.Key)
+				progress.Add(OutcomeWouldDeleteOrphan)
+				return nil
+			}
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+			return gcDelete(ctx, client, progress, OutcomeOrphanDeleted, bucket, object.Key, nil)
+		})
+		if err != nil {
+			return err
+		}
+
+		if opts.UploadsOlderThan <= 0 {
+			continue
+		}
+		if err := abortUploads(ctx, client, bucket, opts, limiter, progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AbortUploads aborts the multipart uploads of opts.Buckets initiated more
+// than opts.UploadsOlderThan ago, releasing their stored parts, without
+// looking at metadata objects
+func AbortUploads(ctx context.Context, client s3.Interface, opts GCOptions) error {
+	limiter := newLimiter(opts.Rate)
+	progress := opts.Progress
+	if progress == nil {
+		progress = NewProgress(nil, 0)
+	}
+	for _, bucket := range opts.Buckets {
+		if err := abortUploads(ctx, client, bucket, opts, limiter, progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// abortUploads aborts the abandoned multipart uploads of one bucket
+func abortUploads(ctx context.Context, client s3.Interface, bucket string, opts GCOptions, limiter *rate.Limiter, progress *Progress) error {
+	cutoff := time.Now().Add(-opts.UploadsOlderThan)
+	return s3.WalkMultipartUploads(ctx, client, bucket, func(upload s3.MultipartUpload) error {
+		if upload.Initiated.After(cutoff) {
+			return nil
+		}
+		if opts.DryRun {
+			progress.Logf("abandoned upload %s/%s (%s, initiated %s)", bucket, upload.Key, upload.UploadID, upload.Initiated.Format(time.RFC3339))
+			progress.Add(OutcomeWouldAbortUpload)
+			return nil
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+		query := url.Values{"uploadId": {upload.UploadID}}
+		return gcDelete(ctx, client, progress, OutcomeUploadAborted, bucket, upload.Key, []byte(query.Encode()))
+	})
+}
+
+// isOrphaned reports whether the object a metadata object describes is gone
+func isOrphaned(ctx context.Context, client s3.Interface, bucket, key string) (bool, error) {
+	resp, err := client.HeadObject(ctx, bucket, key, http.Header{})
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return true, nil
+	case resp.StatusCode >= 300:
+		return false, fmt.Errorf("HEAD returned HTTP %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// gcDelete sends a DELETE and counts it as outcome when it succeeds
+func gcDelete(ctx context.Context, client s3.Interface, progress *Progress, outcome, bucket, key string, query []byte) error {
+	resp, err := client.ForwardRequest(ctx, "DELETE", s3.ObjectPath(bucket, key), nil, http.Header{}, query)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+			err = fmt.Errorf("DELETE returned HTTP %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		progress.Logf("FAILED %s/%s: %v", bucket, key, err)
+		progress.Add(OutcomeFailed)
+		return nil
+	}
+	progress.Add(outcome)
+	return nil
+}
@@ -0,0 +1,96 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/s3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGCDeletesOrphanedMetadata(t *testing.T) {
+	backend, client := newFakeBackend(t)
+	backend.put("bucket", "live", "data")
+	backend.put("bucket", "live.metadata", "{}")
+	backend.put("bucket", "gone.metadata", "{}")
+
+	progress := NewProgress(nil, 0)
+	require.NoError(t, GC(context.Background(), client, GCOptions{Buckets: []string{"bucket"}, DryRun: true, Progress: progress}))
+	assert.Equal(t, 1, progress.Count(OutcomeWouldDeleteOrphan))
+	_, ok := backend.get("bucket", "gone.metadata")
+	assert.True(t, ok, "dry run deletes nothing")
+
+	require.NoError(t, GC(context.Background(), client, GCOptions{Buckets: []string{"bucket"}}))
+	_, ok = backend.get("bucket", "gone.metadata")
+	assert.False(t, ok)
+	_, ok = backend.get("bucket", "live.metadata")
+	assert.True(t, ok)
+}
+
+func TestGCAbortsAbandonedUploads(t *testing.T) {
+	var aborted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Has("uploads"):
+			fmt.Fprintf(w, `<ListMultipartUploadsResult>`+
+				`<Upload><Key>old</Key><UploadId>u1</UploadId><Initiated>%s</Initiated></Upload>`+
+				`<Upload><Key>fresh</Key><UploadId>u2</UploadId><Initiated>%s</Initiated></Upload>`+
+				`</ListMultipartUploadsResult>`,
+				time.Now().Add(-48*time.Hour).UTC().Format(time.RFC3339), time.Now().UTC().Format(time.RFC3339))
+		case r.Method == http.MethodGet:
+			fmt.Fprint(w, `<ListBucketResult></ListBucketResult>`)
+		case r.Method == http.MethodDelete:
+			aborted = append(aborted, strings.TrimPrefix(r.URL.Path, "/bucket/")+"?"+r.URL.RawQuery)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	client := s3.NewClient(server.URL, "", s3.DefaultTransportConfig())
+	progress := NewProgress(nil, 0)
+	require.NoError(t, GC(context.Background(), client, GCOptions{
+		Buckets:          []string{"bucket"},
+		UploadsOlderThan: 24 * time.Hour,
+		Progress:         progress,
+	}))
+	assert.Equal(t, []string{"old?uploadId=u1"}, aborted)
+	assert.Equal(t, 1, progress.Count(OutcomeUploadAborted))
+}
+
+func TestAbortUploadsLeavesMetadataAlone(t *testing.T) {
+	var aborted []string
+	listings := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Has("uploads"):
+			fmt.Fprintf(w, `<ListMultipartUploadsResult>`+
+				`<Upload><Key>old</Key><UploadId>u1</UploadId><Initiated>%s</Initiated></Upload>`+
+				`</ListMultipartUploadsResult>`, time.Now().Add(-48*time.Hour).UTC().Format(time.RFC3339))
+		case r.Method == http.MethodGet:
+			listings++
+			fmt.Fprint(w, `<ListBucketResult></ListBucketResult>`)
+		case r.Method == http.MethodDelete:
+			aborted = append(aborted, strings.TrimPrefix(r.URL.Path, "/bucket/")+"?"+r.URL.RawQuery)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	client := s3.NewClient(server.URL, "", s3.DefaultTransportConfig())
+	progress := NewProgress(nil, 0)
+	require.NoError(t, AbortUploads(context.Background(), client, GCOptions{
+		Buckets:          []string{"bucket"},
+		UploadsOlderThan: 24 * time.Hour,
+		Progress:         progress,
+	}))
+	assert.Equal(t, []string{"old?uploadId=u1"}, aborted)
+	assert.Equal(t, 1, progress.Count(OutcomeUploadAborted))
+	assert.Zero(t, listings, "objects are not walked")
+}
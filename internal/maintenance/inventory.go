@@ -0,0 +1,295 @@
+package maintenance
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/s3"
+)
+
+// Inventory outcomes counted by the progress reporter
+const (
+	OutcomeEncrypted   = "encrypted"
+	OutcomeUnencrypted = "unencrypted"
+)
+
+// Inventory encryption statuses, as in S3 Inventory reports
+const (
+	EncryptionSSEKMS = "SSE-KMS"
+	EncryptionNone   = "NOT-SSE"
+)
+
+// InventoryFormatCSV is the only report format; data files are gzip-compressed
+// CSV without a header row, described by the manifest's fileSchema
+const InventoryFormatCSV = "CSV"
+
+// inventorySchema lists the columns of every data file row
+const inventorySchema = "Bucket, Key, Size, LastModifiedDate, ETag, EncryptionStatus, KMSKeyArn"
+
+// inventoryManifestVersion is the S3 Inventory manifest version the output mirrors
+const inventoryManifestVersion = "2016-11-30"
+
+// InventoryOptions configures an inventory run
+type InventoryOptions struct {
+	Bucket string
+	Prefix string
+	// Reports are written under <DestPrefix>/<Bucket>/<ID>/ in DestBucket, as S3 Inventory lays them out
+	DestBucket  string
+	DestPrefix  string
+	ID          string  // "" is "s3-vault-proxy"
+	KMSKeyARN   string  // encrypts the report files with SSE-KMS when set
+	RowsPerFile int     // rows per data file, 0 is 1,000,000
+	Rate        float64 // objects per second, 0 is unlimited
+	Progress    *Progress
+}
+
+// InventoryManifest is the manifest.json of a report
+type InventoryManifest struct {
+	SourceBucket      string              `json:"sourceBucket"`
+	DestinationBucket string              `json:"destinationBucket"`
+	Version           string              `json:"version"`
+	CreationTimestamp string              `json:"creationTimestamp"`
+	FileFormat        string              `json:"fileFormat"`
+	FileSchema        string              `json:"fileSchema"`
+	Files             []InventoryDataFile `json:"files"`
+}
+
+// InventoryDataFile is one data file listed in a manifest
+type InventoryDataFile struct {
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	MD5Checksum string `json:"MD5checksum"`
+}
+
+// Inventory lists every object of a bucket with its size, ETag and the KMS
+// key protecting it, and writes the list as an S3
+// Inventory style report: data files under data/ and a dated manifest.json
+// with its manifest.checksum. It returns the key of the manifest.
+func Inventory(ctx context.Context, client s3.Interface, opts InventoryOptions) (string, error) {
+	if opts.ID == "" {
+		opts.ID = "s3-vault-proxy"
+	}
+	if opts.RowsPerFile <= 0 {
+		opts.RowsPerFile = 1000000
+	}
+	limiter := newLimiter(opts.Rate)
+	progress := opts.Progress
+	if progress == nil {
+		progress = NewProgress(nil, 0)
+	}
+	metadataService := metadata.NewService(client)
+
+	created := time.Now().UTC()
+	base := path.Join(opts.DestPrefix, opts.Bucket, opts.ID)
+	manifest := InventoryManifest{
+		SourceBucket:      opts.Bucket,
+		DestinationBucket: "arn:aws:s3:::" + opts.DestBucket,
+		Version:           inventoryManifestVersion,
+		CreationTimestamp: strconv.FormatInt(created.UnixMilli(), 10),
+		FileFormat:        InventoryFormatCSV,
+		FileSchema:        inventorySchema,
+		Files:             []InventoryDataFile{},
+	}
+
+	var file *inventoryFile
+	flush := func() error {
+		if file == nil {
+			return nil
+		}
+		key := path.Join(base, "data", fmt.Sprintf("%s-%d.csv.gz", created.Format("20060102T150405Z"), len(manifest.Files)))
+		dataFile, err := file.upload(ctx, client, opts.DestBucket, key, opts.KMSKeyARN)
+		file = nil
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, dataFile)
+		return nil
+	}
+	defer func() {
+		if file != nil {
+			file.discard()
+		}
+	}()
+
+	rows := 0
+	err := s3.WalkObjects(ctx, client, opts.Bucket, opts.Prefix, "", func(object s3.ObjectInfo) error {
+		if metadata.IsMetadataKey(object.Key) {
+			return nil
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		row, err := inventoryRow(ctx, client, metadataService, opts.Bucket, object)
+		if err != nil {
+			progress.Logf("FAILED %s/%s: %v", opts.Bucket, object.Key, err)
+			progress.Add(OutcomeFailed)
+		} else if row[5] == EncryptionNone {
+			progress.Add(OutcomeUnencrypted)
+		} else {
+			progress.Add(OutcomeEncrypted)
+		}
+
+		if file == nil {
+			if file, err = newInventoryFile(); err != nil {
+				return err
+			}
+		}
+		if err := file.write(row); err != nil {
+			return err
+		}
+		if rows++; rows%opts.RowsPerFile == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	body, _ := json.MarshalIndent(manifest, "", "  ")
+	manifestKey := path.Join(base, created.Format("2006-01-02T15-04Z"), "manifest.json")
+	if err := putReportObject(ctx, client, opts.DestBucket, manifestKey, bytes.NewReader(body), int64(len(body)), "application/json", opts.KMSKeyARN); err != nil {
+		return "", err
+	}
+	checksum := md5.Sum(body)
+	checksumHex := []byte(hex.EncodeToString(checksum[:]))
+	checksumKey := path.Join(path.Dir(manifestKey), "manifest.checksum")
+	if err := putReportObject(ctx, client, opts.DestBucket, checksumKey, bytes.NewReader(checksumHex), int64(len(checksumHex)), "text/plain", opts.KMSKeyARN); err != nil {
+		return "", err
+	}
+	return manifestKey, nil
+}
+
+// inventoryRow describes one object. Objects without metadata are asked for
+// their encryption headers. An object whose encryption cannot be determined
+// is still listed, with an empty encryption status.
+func inventoryRow(ctx context.Context, client s3.Interface, metadataService *metadata.Service, bucket string, object s3.ObjectInfo) ([]string, error) {
+	row := []string{bucket, object.Key, strconv.FormatInt(object.Size, 10), object.LastModified.UTC().Format(time.RFC3339), trimETag(object.ETag), "", ""}
+
+	meta, err := metadataService.Get(ctx, bucket, object.Key, http.Header{})
+	switch {
+	case err == nil && meta.KMSKeyARN != "":
+		row[5], row[6] = EncryptionSSEKMS, meta.KMSKeyARN
+		return row, nil
+	case err != nil && !errors.Is(err, metadata.ErrNotFound):
+		return row, err
+	}
+
+	resp, err := client.HeadObject(ctx, bucket, object.Key, http.Header{})
+	if err != nil {
+		return row, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return row, fmt.Errorf("HEAD returned HTTP %d", resp.StatusCode)
+	}
+	row[5] = EncryptionNone
+	if resp.Header.Get("X-Amz-Server-Side-Encryption") == "aws:kms" {
+		row[5], row[6] = EncryptionSSEKMS, resp.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id")
+	}
+	return row, nil
+}
+
+func trimETag(etag string) string {
+	if len(etag) >= 2 && etag[0] == '"' && etag[len(etag)-1] == '"' {
+		return etag[1 : len(etag)-1]
+	}
+	return etag
+}
+
+// inventoryFile spools one gzip-compressed CSV data file to disk
+type inventoryFile struct {
+	tmp *os.File
+	gz  *gzip.Writer
+	csv *csv.Writer
+}
+
+func newInventoryFile() (*inventoryFile, error) {
+	tmp, err := os.CreateTemp("", "inventory-*.csv.gz")
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(tmp)
+	return &inventoryFile{tmp: tmp, gz: gz, csv: csv.NewWriter(gz)}, nil
+}
+
+func (f *inventoryFile) write(row []string) error {
+	return f.csv.Write(row)
+}
+
+// upload finishes the file, stores it under key and removes the spooled copy
+func (f *inventoryFile) upload(ctx context.Context, client s3.Interface, bucket, key, kmsKeyARN string) (InventoryDataFile, error) {
+	defer f.discard()
+
+	f.csv.Flush()
+	if err := f.csv.Error(); err != nil {
+		return InventoryDataFile{}, err
+	}
+	if err := f.gz.Close(); err != nil {
+		return InventoryDataFile{}, err
+	}
+	size, err := f.tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return InventoryDataFile{}, err
+	}
+	if _, err := f.tmp.Seek(0, io.SeekStart); err != nil {
+		return InventoryDataFile{}, err
+	}
+	hash := md5.New()
+	if _, err := io.Copy(hash, f.tmp); err != nil {
+		return InventoryDataFile{}, err
+	}
+	if _, err := f.tmp.Seek(0, io.SeekStart); err != nil {
+		return InventoryDataFile{}, err
+	}
+
+	if err := putReportObject(ctx, client, bucket, key, f.tmp, size, "application/gzip", kmsKeyARN); err != nil {
+		return InventoryDataFile{}, err
+	}
+	return InventoryDataFile{Key: key, Size: size, MD5Checksum: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+func (f *inventoryFile) discard() {
+	f.tmp.Close()
+	os.Remove(f.tmp.Name())
+}
+
+// putReportObject stores one report object, with SSE-KMS when kmsKeyARN is set
+func putReportObject(ctx context.Context, client s3.Interface, bucket, key string, body io.Reader, size int64, contentType, kmsKeyARN string) error {
+	headers := http.Header{
+		"Content-Length": {strconv.FormatInt(size, 10)},
+		"Content-Type":   {contentType},
+	}
+	if kmsKeyARN != "" {
+		headers.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+		headers.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", kmsKeyARN)
+	}
+	resp, err := client.ForwardRequest(ctx, "PUT", s3.ObjectPath(bucket, key), body, headers, nil)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("storing %s returned HTTP %d", key, resp.StatusCode)
+	}
+	return nil
+}
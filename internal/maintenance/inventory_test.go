@@ -0,0 +1,73 @@
+package maintenance
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventory(t *testing.T) {
+	backend, client := newFakeBackend(t)
+	putMetadata(t, backend, "data", "a/tracked.bin", types.ObjectMetadata{KMSKeyARN: testARN})
+	backend.put("data", "b/headed.bin", "sse")
+	backend.sse["data/b/headed.bin"] = testARN
+	backend.put("data", "c/plain.txt", "plaintext")
+
+	manifestKey, err := Inventory(context.Background(), client, InventoryOptions{
+		Bucket:      "data",
+		DestBucket:  "reports",
+		DestPrefix:  "inventory",
+		KMSKeyARN:   testARN,
+		RowsPerFile: 2,
+	})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(manifestKey, "inventory/data/s3-vault-proxy/"))
+	assert.True(t, strings.HasSuffix(manifestKey, "/manifest.json"))
+
+	body, ok := backend.get("reports", manifestKey)
+	require.True(t, ok)
+	var manifest InventoryManifest
+	require.NoError(t, json.Unmarshal([]byte(body), &manifest))
+	assert.Equal(t, "data", manifest.SourceBucket)
+	assert.Equal(t, "arn:aws:s3:::reports", manifest.DestinationBucket)
+	assert.Equal(t, InventoryFormatCSV, manifest.FileFormat)
+	require.Len(t, manifest.Files, 2, "rows are split across data files")
+
+	checksum, ok := backend.get("reports", strings.TrimSuffix(manifestKey, "manifest.json")+"manifest.checksum")
+	require.True(t, ok)
+	sum := md5.Sum([]byte(body))
+	assert.Equal(t, hex.EncodeToString(sum[:]), checksum)
+	assert.Equal(t, testARN, backend.sse["reports/"+manifestKey], "reports are encrypted with the given key")
+
+	var rows [][]string
+	for _, file := range manifest.Files {
+		data, ok := backend.get("reports", file.Key)
+		require.True(t, ok)
+		assert.Equal(t, int64(len(data)), file.Size)
+		sum := md5.Sum([]byte(data))
+		assert.Equal(t, hex.EncodeToString(sum[:]), file.MD5Checksum)
+
+		gz, err := gzip.NewReader(strings.NewReader(data))
+		require.NoError(t, err)
+		records, err := csv.NewReader(gz).ReadAll()
+		require.NoError(t, err)
+		rows = append(rows, records...)
+	}
+
+	require.Len(t, rows, 3, "metadata objects are not listed")
+	assert.Equal(t, []string{"data", "a/tracked.bin", "10", EncryptionSSEKMS, testARN}, []string{rows[0][0], rows[0][1], rows[0][2], rows[0][5], rows[0][6]})
+	assert.Equal(t, []string{"b/headed.bin", EncryptionSSEKMS, testARN},
+		[]string{rows[1][1], rows[1][5], rows[1][6]}, "objects without metadata are asked for their encryption")
+	assert.Len(t, rows[0], 7)
+	assert.Equal(t, []string{"c/plain.txt", EncryptionNone, ""}, []string{rows[2][1], rows[2][5], rows[2][6]})
+}
@@ -0,0 +1,16 @@
+// Package maintenance implements the offline operator commands that walk
+// buckets through the backend, signing requests with the operator credentials.
+package maintenance
+
+import "golang.org/x/time/rate"
+
+// checkpointEvery bounds how much work is repeated after an interruption
+const checkpointEvery = 100
+
+// newLimiter allows perSecond operations per second, or any number when it is 0
+func newLimiter(perSecond float64) *rate.Limiter {
+	if perSecond <= 0 {
+		return rate.NewLimiter(rate.Inf, 1)
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), 1)
+}
@@ -0,0 +1,171 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/pkg/types"
+)
+
+// Migrate outcomes counted by the progress reporter
+const (
+	OutcomeMigrated         = "migrated"
+	OutcomeWouldMigrate     = "would_migrate"
+	OutcomeAlreadyEncrypted = "already_encrypted"
+)
+
+// MigrateOptions configures a migrate-encrypt run
+type MigrateOptions struct {
+	Bucket       string
+	Prefix       string
+	DestBucket   string // "" rewrites objects in place
+	KMSKeyARN    string
+	DeleteSource bool // only valid with a different DestBucket
+	DryRun       bool
+	Rate         float64 // objects per second, 0 is unlimited
+	Checkpoint   *Checkpoint
+	Progress     *Progress
+}
+
+// MigrateEncrypt rewrites the unencrypted objects of a bucket with SSE-KMS
+// under KMSKeyARN and stores their metadata, as the proxy does for uploads.
+// Objects that are already encrypted or have metadata are left alone.
+func MigrateEncrypt(ctx context.Context, client s3.Interface, opts MigrateOptions) error {
+	dest := opts.DestBucket
+	if dest == "" {
+		dest = opts.Bucket
+	}
+	if opts.DeleteSource && dest == opts.Bucket {
+		return fmt.Errorf("deleting the source requires a different destination bucket")
+	}
+
+	limiter := newLimiter(opts.Rate)
+	checkpoint := opts.Checkpoint
+	if checkpoint == nil {
+		checkpoint, _ = LoadCheckpoint("")
+	}
+	progress := opts.Progress
+	if progress == nil {
+		progress = NewProgress(nil, 0)
+	}
+	metadataService := metadata.NewService(client)
+
+	processed := 0
+	err := s3.WalkObjects(ctx, client, opts.Bucket, opts.Prefix, checkpoint.StartAfter(opts.Bucket), func(object s3.ObjectInfo) error {
+		if metadata.IsMetadataKey(object.Key) {
+			return nil
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		outcome, err := migrateObject(ctx, client, metadataService, opts, dest, object.Key)
+		if err != nil {
+			progress.Logf("FAILED %s/%s: %v", opts.Bucket, object.Key, err)
+		}
+		progress.Add(outcome)
+
+		checkpoint.Record(opts.Bucket, object.Key)
+		if processed++; processed%checkpointEvery == 0 {
+			return checkpoint.Save()
+		}
+		return nil
+	})
+
+	if saveErr := checkpoint.Save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// migrateObject copies one object through an SSE-KMS upload and writes its metadata
+func migrateObject(ctx context.Context, client s3.Interface, metadataService *metadata.Service, opts MigrateOptions, dest, key string) (string, error) {
+	if metadataService.Exists(ctx, opts.Bucket, key+".metadata", http.Header{}) {
+		return OutcomeAlreadyEncrypted, nil
+	}
+
+	resp, err := client.ForwardRequest(ctx, "GET", s3.ObjectPath(opts.Bucket, key), nil, http.Header{}, nil)
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return OutcomeFailed, fmt.Errorf("GET returned HTTP %d", resp.StatusCode)
+	}
+	if resp.Header.Get("X-Amz-Server-Side-Encryption") != "" {
+		return OutcomeAlreadyEncrypted, nil
+	}
+	if opts.DryRun {
+		return OutcomeWouldMigrate, nil
+	}
+	if resp.ContentLength < 0 {
+		return OutcomeFailed, fmt.Errorf("backend did not report the object size")
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	headers := http.Header{
+		"Content-Length":                              {strconv.FormatInt(resp.ContentLength, 10)},
+		"X-Amz-Server-Side-Encryption":                {"aws:kms"},
+		"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": {opts.KMSKeyARN},
+	}
+	if contentType != "" {
+		headers["Content-Type"] = []string{contentType}
+	}
+	var httpHeaders types.ObjectMetadata
+	httpHeaders.ReadHTTPHeaders(resp.Header)
+	httpHeaders.WriteHTTPHeaders(headers)
+	body := s3.NewETagReader(ctx, client, opts.Bucket, key, resp)
+	put, err := client.ForwardRequest(ctx, "PUT", s3.ObjectPath(dest, key), body, headers, nil)
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	io.Copy(io.Discard, put.Body)
+	put.Body.Close()
+	if put.StatusCode >= 300 {
+		return OutcomeFailed, fmt.Errorf("encrypted PUT returned HTTP %d", put.StatusCode)
+	}
+
+	meta := &types.ObjectMetadata{
+		ContentLength: resp.ContentLength,
+		ContentType:   contentType,
+		ETag:          body.ETag(),
+		LastModified:  storedLastModified(ctx, client, dest, key),
+		KMSKeyARN:     opts.KMSKeyARN,
+	}
+	meta.ReadHTTPHeaders(headers)
+	if err := metadataService.Store(ctx, dest, key, meta, http.Header{}); err != nil {
+		return OutcomeFailed, err
+	}
+
+	if opts.DeleteSource {
+		del, err := client.ForwardRequest(ctx, "DELETE", s3.ObjectPath(opts.Bucket, key), nil, http.Header{}, nil)
+		if err != nil {
+			return OutcomeFailed, fmt.Errorf("migrated but failed to delete the source: %w", err)
+		}
+		del.Body.Close()
+		if del.StatusCode >= 300 {
+			return OutcomeFailed, fmt.Errorf("migrated but deleting the source returned HTTP %d", del.StatusCode)
+		}
+	}
+	return OutcomeMigrated, nil
+}
+
+// storedLastModified returns the backend's timestamp of a freshly written
+// object in the ObjectMetadata format, or the current time when the backend
+// doesn't report one
+func storedLastModified(ctx context.Context, client s3.Interface, bucket, key string) string {
+	lastModified := time.Now()
+	if head, err := client.HeadObject(ctx, bucket, key, http.Header{}); err == nil {
+		head.Body.Close()
+		if t, err := http.ParseTime(head.Header.Get("Last-Modified")); err == nil {
+			lastModified = t
+		}
+	}
+	return types.FormatLastModified(lastModified)
+}
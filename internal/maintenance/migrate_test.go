@@ -0,0 +1,61 @@
+package maintenance
+
+import (
+	"context"
+	"testing"
+
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateEncryptInPlace(t *testing.T) {
+	backend, client := newFakeBackend(t)
+	backend.put("bucket", "plain.txt", "hello")
+	putMetadata(t, backend, "bucket", "done.txt", types.ObjectMetadata{KMSKeyARN: testARN})
+
+	progress := NewProgress(nil, 0)
+	require.NoError(t, MigrateEncrypt(context.Background(), client, MigrateOptions{
+		Bucket:    "bucket",
+		KMSKeyARN: testARN,
+		Progress:  progress,
+	}))
+
+	body, _ := backend.get("bucket", "plain.txt")
+	assert.Equal(t, "hello", body)
+	assert.Equal(t, testARN, backend.sse["bucket/plain.txt"])
+	assert.Equal(t, "", backend.sse["bucket/done.txt"], "objects with metadata are left alone")
+	assert.Equal(t, 1, progress.Count(OutcomeMigrated))
+	assert.Equal(t, 1, progress.Count(OutcomeAlreadyEncrypted))
+
+	meta := readMetadata(t, backend, "bucket", "plain.txt")
+	assert.Equal(t, int64(5), meta.ContentLength)
+	assert.Equal(t, testARN, meta.KMSKeyARN)
+	assert.Equal(t, `"5d41402abc4b2a76b9719d911017c592"`, meta.ETag, "the plaintext MD5, not the backend's ETag")
+}
+
+func TestMigrateEncryptToOtherBucket(t *testing.T) {
+	backend, client := newFakeBackend(t)
+	backend.put("legacy", "a", "data")
+
+	require.NoError(t, MigrateEncrypt(context.Background(), client, MigrateOptions{
+		Bucket: "legacy", DestBucket: "vaulted", KMSKeyARN: testARN, DeleteSource: true, DryRun: true,
+	}))
+	_, ok := backend.get("vaulted", "a")
+	assert.False(t, ok, "dry run writes nothing")
+
+	require.NoError(t, MigrateEncrypt(context.Background(), client, MigrateOptions{
+		Bucket: "legacy", DestBucket: "vaulted", KMSKeyARN: testARN, DeleteSource: true,
+	}))
+	_, ok = backend.get("legacy", "a")
+	assert.False(t, ok, "source deleted")
+	assert.Equal(t, testARN, backend.sse["vaulted/a"])
+	assert.Equal(t, testARN, readMetadata(t, backend, "vaulted", "a").KMSKeyARN)
+}
+
+func TestMigrateEncryptRefusesInPlaceDelete(t *testing.T) {
+	_, client := newFakeBackend(t)
+	err := MigrateEncrypt(context.Background(), client, MigrateOptions{Bucket: "bucket", KMSKeyARN: testARN, DeleteSource: true})
+	assert.ErrorContains(t, err, "different destination")
+}
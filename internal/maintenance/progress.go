@@ -0,0 +1,82 @@
+package maintenance
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress counts outcomes of a long-running command and prints a summary
+// line at most once per interval
+type Progress struct {
+	mu       sync.Mutex
+	out      io.Writer
+	interval time.Duration
+	started  time.Time
+	printed  time.Time
+	counts   map[string]int
+}
+
+// NewProgress reports to out every interval. A nil out disables reporting.
+func NewProgress(out io.Writer, interval time.Duration) *Progress {
+	now := time.Now()
+	return &Progress{
+		out:      out,
+		interval: interval,
+		started:  now,
+		printed:  now,
+		counts:   make(map[string]int),
+	}
+}
+
+// Add counts one outcome and prints a progress line when the interval has passed
+func (p *Progress) Add(outcome string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.counts[outcome]++
+	if p.out != nil && time.Since(p.printed) >= p.interval {
+		p.printed = time.Now()
+		fmt.Fprintf(p.out, "progress: %s\n", p.summaryLocked())
+	}
+}
+
+// Logf prints a message, such as a per-object failure, alongside progress lines
+func (p *Progress) Logf(format string, args ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.out != nil {
+		fmt.Fprintf(p.out, format+"\n", args...)
+	}
+}
+
+// Count returns how many times outcome was recorded
+func (p *Progress) Count(outcome string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.counts[outcome]
+}
+
+// Summary formats every count with the elapsed time
+func (p *Progress) Summary() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.summaryLocked()
+}
+
+func (p *Progress) summaryLocked() string {
+	outcomes := make([]string, 0, len(p.counts))
+	for outcome := range p.counts {
+		outcomes = append(outcomes, outcome)
+	}
+	sort.Strings(outcomes)
+
+	parts := make([]string, 0, len(outcomes)+1)
+	for _, outcome := range outcomes {
+		parts = append(parts, fmt.Sprintf("%s=%d", outcome, p.counts[outcome]))
+	}
+	parts = append(parts, fmt.Sprintf("elapsed=%s", time.Since(p.started).Round(time.Second)))
+	return strings.Join(parts, " ")
+}
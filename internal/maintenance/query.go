@@ -0,0 +1,102 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/s3"
+)
+
+// EncryptionQuery selects the objects of a bucket by the key protecting
+// them. Unencrypted selects objects without a KMS key. Otherwise KMSKeyARN,
+// when set, selects objects under that key. Objects cannot be selected by
+// transit key version: the backend wraps and keeps their data keys.
+type EncryptionQuery struct {
+	Bucket      string
+	Prefix      string
+	StartAfter  string
+	KMSKeyARN   string
+	Unencrypted bool
+	Limit       int // matches returned, 0 is unlimited
+}
+
+// EncryptedObject is an object matched by an EncryptionQuery. Objects without
+// metadata have no KMS key and their stored size as their plaintext size.
+type EncryptedObject struct {
+	Key           string    `json:"key"`
+	PlaintextSize int64     `json:"plaintext_size"`
+	LastModified  time.Time `json:"last_modified"`
+	KMSKeyARN     string    `json:"kms_key_arn,omitempty"`
+}
+
+// EncryptionQueryResult lists the matches of a query in key order.
+// NextStartAfter is set when Limit cut the listing short; passing it as
+// StartAfter continues the query.
+type EncryptionQueryResult struct {
+	Objects        []EncryptedObject `json:"objects"`
+	Scanned        int64             `json:"scanned"`
+	NextStartAfter string            `json:"next_start_after,omitempty"`
+}
+
+// errQueryLimit stops a walk once a query has its limit of matches
+var errQueryLimit = errors.New("query limit reached")
+
+// FindObjects lists a bucket and reads each object's metadata to return the
+// objects matching query
+func FindObjects(ctx context.Context, client s3.Interface, query EncryptionQuery) (*EncryptionQueryResult, error) {
+	metadataService := metadata.NewService(client)
+	result := &EncryptionQueryResult{Objects: []EncryptedObject{}}
+	lastScanned := ""
+
+	err := s3.WalkObjects(ctx, client, query.Bucket, query.Prefix, query.StartAfter, func(object s3.ObjectInfo) error {
+		if metadata.IsMetadataKey(object.Key) {
+			return nil
+		}
+		if query.Limit > 0 && len(result.Objects) == query.Limit {
+			result.NextStartAfter = lastScanned
+			return errQueryLimit
+		}
+
+		encryption, err := objectEncryption(ctx, metadataService, query.Bucket, object)
+		if err != nil {
+			return err
+		}
+		result.Scanned++
+		lastScanned = object.Key
+		if query.matches(encryption) {
+			result.Objects = append(result.Objects, encryption)
+		}
+		return ctx.Err()
+	})
+	if err != nil && !errors.Is(err, errQueryLimit) {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (q EncryptionQuery) matches(object EncryptedObject) bool {
+	if q.Unencrypted {
+		return object.KMSKeyARN == ""
+	}
+	if object.KMSKeyARN == "" {
+		return false
+	}
+	return q.KMSKeyARN == "" || object.KMSKeyARN == q.KMSKeyARN
+}
+
+// objectEncryption reads the KMS key of an object from its metadata
+func objectEncryption(ctx context.Context, metadataService *metadata.Service, bucket string, object s3.ObjectInfo) (EncryptedObject, error) {
+	encryption := EncryptedObject{Key: object.Key, PlaintextSize: object.Size, LastModified: object.LastModified}
+	meta, err := metadataService.Get(ctx, bucket, object.Key, http.Header{})
+	switch {
+	case errors.Is(err, metadata.ErrNotFound):
+		return encryption, nil
+	case err != nil:
+		return encryption, err
+	}
+	encryption.PlaintextSize, encryption.KMSKeyARN = meta.ContentLength, meta.KMSKeyARN
+	return encryption, nil
+}
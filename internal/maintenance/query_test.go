@@ -0,0 +1,48 @@
+package maintenance
+
+import (
+	"context"
+	"testing"
+
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindObjects(t *testing.T) {
+	const otherARN = "arn:aws:kms:us-east-1:123456789012:key/other"
+	backend, client := newFakeBackend(t)
+	putMetadata(t, backend, "data", "a.bin", types.ObjectMetadata{ContentLength: 4, KMSKeyARN: testARN})
+	putMetadata(t, backend, "data", "b.bin", types.ObjectMetadata{ContentLength: 4, KMSKeyARN: testARN})
+	putMetadata(t, backend, "data", "c.bin", types.ObjectMetadata{ContentLength: 4, KMSKeyARN: otherARN})
+	backend.put("data", "d.txt", "plaintext")
+
+	keys := func(query EncryptionQuery) []string {
+		query.Bucket = "data"
+		result, err := FindObjects(context.Background(), client, query)
+		require.NoError(t, err)
+		keys := []string{}
+		for _, object := range result.Objects {
+			keys = append(keys, object.Key)
+		}
+		return keys
+	}
+
+	assert.Equal(t, []string{"a.bin", "b.bin"}, keys(EncryptionQuery{KMSKeyARN: testARN}))
+	assert.Equal(t, []string{"c.bin"}, keys(EncryptionQuery{KMSKeyARN: otherARN}))
+	assert.Equal(t, []string{"d.txt"}, keys(EncryptionQuery{Unencrypted: true}))
+	assert.Equal(t, []string{"a.bin", "b.bin", "c.bin"}, keys(EncryptionQuery{}))
+
+	// A limited query continues from where it stopped
+	result, err := FindObjects(context.Background(), client, EncryptionQuery{Bucket: "data", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 1)
+	assert.Equal(t, "a.bin", result.NextStartAfter)
+	assert.Equal(t, []string{"b.bin", "c.bin"}, keys(EncryptionQuery{StartAfter: result.NextStartAfter}))
+
+	result, err = FindObjects(context.Background(), client, EncryptionQuery{Bucket: "data", Limit: 4})
+	require.NoError(t, err)
+	assert.Empty(t, result.NextStartAfter, "the limit was not reached")
+	assert.Equal(t, int64(4), result.Scanned)
+}
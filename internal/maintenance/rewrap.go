@@ -0,0 +1,151 @@
+package maintenance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/pkg/types"
+)
+
+// Rewrap outcomes counted by the progress reporter, besides OutcomeUnencrypted
+const (
+	OutcomeReencrypted = "reencrypted"
+	OutcomeFailed      = "failed"
+)
+
+// RewrapOptions configures a rewrap run
+type RewrapOptions struct {
+	Buckets    []string
+	Prefix     string
+	Rate       float64 // objects per second, 0 is unlimited
+	Checkpoint *Checkpoint
+	Progress   *Progress
+}
+
+// Rewrap re-encrypts every SSE-KMS object of the buckets under a new data key,
+// which the backend's KMS wraps with the newest version of the object's
+// transit key. The backend keeps the wrapped data keys, not the proxy, so each
+// object is copied onto itself with its KMS key, metadata and encryption
+// context, and every run copies every object. Objects stored without SSE-KMS
+// are counted and left to migrate-encrypt. Per-object failures are reported
+// and counted without stopping the run; the checkpoint is saved on return.
+func Rewrap(ctx context.Context, client s3.Interface, opts RewrapOptions) error {
+	limiter := newLimiter(opts.Rate)
+	checkpoint := opts.Checkpoint
+	if checkpoint == nil {
+		checkpoint, _ = LoadCheckpoint("")
+	}
+	progress := opts.Progress
+	if progress == nil {
+		progress = NewProgress(nil, 0)
+	}
+	metadataService := metadata.NewService(client)
+
+	err := func() error {
+		for _, bucket := range opts.Buckets {
+			processed := 0
+			err := s3.WalkObjects(ctx, client, bucket, opts.Prefix, checkpoint.StartAfter(bucket), func(object s3.ObjectInfo) error {
+				if metadata.IsMetadataKey(object.Key) {
+					return nil
+				}
+				if err := limiter.Wait(ctx); err != nil {
+					return err
+				}
+
+				outcome, err := reencryptObject(ctx, client, metadataService, bucket, object)
+				if err != nil {
+					progress.Logf("FAILED %s/%s: %v", bucket, object.Key, err)
+				}
+				progress.Add(outcome)
+
+				checkpoint.Record(bucket, object.Key)
+				if processed++; processed%checkpointEvery == 0 {
+					return checkpoint.Save()
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+
+	if saveErr := checkpoint.Save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// reencryptObject copies a single object onto itself under its own KMS key.
+// The copy only happens while the object still has its listed ETag, so a
+// concurrent overwrite is never replaced by the older data. The object's
+// metadata is rewritten with the time of the copy, or it would look like the
+// leftover of an overwrite outside the proxy.
+func reencryptObject(ctx context.Context, client s3.Interface, metadataService *metadata.Service, bucket string, object s3.ObjectInfo) (string, error) {
+	resp, err := client.HeadObject(ctx, bucket, object.Key, http.Header{})
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return OutcomeFailed, fmt.Errorf("HEAD returned HTTP %d", resp.StatusCode)
+	}
+	kmsKeyARN := resp.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id")
+	if resp.Header.Get("X-Amz-Server-Side-Encryption") != "aws:kms" || kmsKeyARN == "" {
+		return OutcomeUnencrypted, nil
+	}
+
+	meta, err := metadataService.Get(ctx, bucket, object.Key, http.Header{})
+	switch {
+	case errors.Is(err, metadata.ErrNotFound):
+		meta = nil
+	case err != nil:
+		// Copying without the recorded encryption context would drop it
+		return OutcomeFailed, err
+	}
+
+	path := s3.ObjectPath(bucket, object.Key)
+	headers := http.Header{
+		"X-Amz-Copy-Source":                           {path},
+		"X-Amz-Metadata-Directive":                    {"COPY"},
+		"X-Amz-Server-Side-Encryption":                {"aws:kms"},
+		"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": {kmsKeyARN},
+	}
+	if object.ETag != "" {
+		headers.Set("X-Amz-Copy-Source-If-Match", object.ETag)
+	}
+	if bucketKey := resp.Header.Get("X-Amz-Server-Side-Encryption-Bucket-Key-Enabled"); bucketKey != "" {
+		headers.Set("X-Amz-Server-Side-Encryption-Bucket-Key-Enabled", bucketKey)
+	}
+	if meta != nil && len(meta.EncryptionContext) > 0 {
+		headers.Set("X-Amz-Server-Side-Encryption-Context", s3.FormatEncryptionContext(meta.EncryptionContext))
+	}
+
+	copyResp, err := client.ForwardRequest(ctx, http.MethodPut, path, nil, headers, nil)
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	body, _ := io.ReadAll(copyResp.Body)
+	copyResp.Body.Close()
+	// CopyObject can fail after answering 200, with an error in the body
+	if copyResp.StatusCode >= 300 || bytes.Contains(body, []byte("<Error>")) {
+		return OutcomeFailed, fmt.Errorf("copy failed with HTTP %d: %s", copyResp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if meta != nil {
+		meta.LastModified = types.FormatLastModified(time.Now())
+		if err := metadataService.Store(ctx, bucket, object.Key, meta, http.Header{}); err != nil {
+			return OutcomeFailed, fmt.Errorf("re-encrypted but failed to store metadata: %w", err)
+		}
+	}
+	return OutcomeReencrypted, nil
+}
@@ -0,0 +1,99 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testARN = "arn:aws:kms:us-east-1:123456789012:key/12345678-1234-1234-1234-123456789012"
+
+func putMetadata(t *testing.T, backend *fakeBackend, bucket, key string, meta types.ObjectMetadata) {
+	data, err := json.Marshal(meta)
+	require.NoError(t, err)
+	backend.put(bucket, key, "ciphertext")
+	backend.put(bucket, key+".metadata", string(data))
+}
+
+func readMetadata(t *testing.T, backend *fakeBackend, bucket, key string) types.ObjectMetadata {
+	data, ok := backend.get(bucket, key+".metadata")
+	require.True(t, ok)
+	var meta types.ObjectMetadata
+	require.NoError(t, json.Unmarshal([]byte(data), &meta))
+	return meta
+}
+
+// putEncrypted stores an object under SSE-KMS with its metadata
+func putEncrypted(t *testing.T, backend *fakeBackend, bucket, key string, meta types.ObjectMetadata) {
+	putMetadata(t, backend, bucket, key, meta)
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	backend.sse[bucket+"/"+key] = testARN
+}
+
+func TestRewrap(t *testing.T) {
+	backend, client := newFakeBackend(t)
+	putEncrypted(t, backend, "bucket", "tagged", types.ObjectMetadata{
+		KMSKeyARN:         testARN,
+		LastModified:      "2020-01-01T00:00:00Z",
+		EncryptionContext: map[string]string{"project": "a"},
+	})
+	putEncrypted(t, backend, "bucket", "bare", types.ObjectMetadata{})
+	backend.put("bucket", "plain", "legacy")
+	backend.puts = 0
+
+	progress := NewProgress(nil, 0)
+	err := Rewrap(context.Background(), client, RewrapOptions{
+		Buckets:  []string{"bucket"},
+		Progress: progress,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, progress.Count(OutcomeReencrypted))
+	assert.Equal(t, 1, progress.Count(OutcomeUnencrypted))
+	assert.Zero(t, progress.Count(OutcomeFailed))
+
+	copied := backend.copies["bucket/tagged"]
+	require.NotNil(t, copied, "objects are copied onto themselves")
+	assert.Equal(t, "/bucket/tagged", copied.Get("X-Amz-Copy-Source"))
+	assert.Equal(t, "COPY", copied.Get("X-Amz-Metadata-Directive"))
+	assert.Equal(t, testARN, copied.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+	assert.Equal(t, "eyJwcm9qZWN0IjoiYSJ9", copied.Get("X-Amz-Server-Side-Encryption-Context"), "the recorded context is kept")
+	assert.NotContains(t, backend.copies, "bucket/plain")
+
+	meta := readMetadata(t, backend, "bucket", "tagged")
+	assert.NotEqual(t, "2020-01-01T00:00:00Z", meta.LastModified, "metadata is dated after the copy")
+	assert.Equal(t, map[string]string{"project": "a"}, meta.EncryptionContext)
+}
+
+func TestRewrapResumesFromCheckpoint(t *testing.T) {
+	backend, client := newFakeBackend(t)
+	putEncrypted(t, backend, "bucket", "a", types.ObjectMetadata{KMSKeyARN: testARN})
+	putEncrypted(t, backend, "bucket", "b", types.ObjectMetadata{KMSKeyARN: testARN})
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	checkpoint, err := LoadCheckpoint(path)
+	require.NoError(t, err)
+	checkpoint.Record("bucket", "a")
+	require.NoError(t, checkpoint.Save())
+
+	checkpoint, err = LoadCheckpoint(path)
+	require.NoError(t, err)
+	require.NoError(t, Rewrap(context.Background(), client, RewrapOptions{
+		Buckets:    []string{"bucket"},
+		Checkpoint: checkpoint,
+	}))
+
+	assert.NotContains(t, backend.copies, "bucket/a", "skipped by the checkpoint")
+	assert.Contains(t, backend.copies, "bucket/b")
+
+	resumed, err := LoadCheckpoint(path)
+	require.NoError(t, err)
+	assert.Equal(t, "b", resumed.StartAfter("bucket"))
+}
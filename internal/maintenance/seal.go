@@ -0,0 +1,152 @@
+package maintenance
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Sealed streams are split into chunks of sealChunkSize plaintext bytes, each
+// encrypted with AES-256-GCM. The chunk counter is folded into the nonce and
+// the final chunk is marked in its additional data, so reordered, dropped or
+// truncated chunks fail to open.
+const (
+	sealChunkSize = 64 << 10
+	sealOverhead  = 16
+	sealNonceSize = 12
+)
+
+var errSealTruncated = errors.New("sealed stream is truncated")
+
+// sealedSize returns the sealed length of n plaintext bytes. The final chunk
+// is always shorter than sealChunkSize, so it may be empty.
+func sealedSize(n int64) int64 {
+	return n + (n/sealChunkSize+1)*sealOverhead
+}
+
+func newSealAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce derives the nonce of chunk n from the stream's base nonce
+func chunkNonce(base []byte, n uint64) []byte {
+	nonce := make([]byte, sealNonceSize)
+	copy(nonce, base)
+	counter := binary.BigEndian.Uint64(nonce[4:])
+	binary.BigEndian.PutUint64(nonce[4:], counter^n)
+	return nonce
+}
+
+func chunkAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// sealWriter encrypts everything written to it onto out; Close writes the final chunk
+type sealWriter struct {
+	aead  cipher.AEAD
+	nonce []byte
+	out   io.Writer
+	buf   []byte
+	n     uint64
+}
+
+func newSealWriter(out io.Writer, key, nonce []byte) (*sealWriter, error) {
+	aead, err := newSealAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &sealWriter{aead: aead, nonce: nonce, out: out, buf: make([]byte, 0, sealChunkSize)}, nil
+}
+
+func (w *sealWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+		if len(w.buf) == sealChunkSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (w *sealWriter) Close() error {
+	return w.flush(true)
+}
+
+func (w *sealWriter) flush(final bool) error {
+	sealed := w.aead.Seal(nil, chunkNonce(w.nonce, w.n), w.buf, chunkAD(final))
+	w.n++
+	w.buf = w.buf[:0]
+	_, err := w.out.Write(sealed)
+	return err
+}
+
+// openReader decrypts a stream written by sealWriter
+type openReader struct {
+	aead  cipher.AEAD
+	nonce []byte
+	in    io.Reader
+	chunk []byte
+	plain []byte
+	n     uint64
+	done  bool
+}
+
+func newOpenReader(in io.Reader, key, nonce []byte) (*openReader, error) {
+	aead, err := newSealAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &openReader{aead: aead, nonce: nonce, in: in, chunk: make([]byte, sealChunkSize+sealOverhead)}, nil
+}
+
+func (r *openReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+func (r *openReader) next() error {
+	n, err := io.ReadFull(r.in, r.chunk)
+	final := false
+	switch {
+	case err == io.EOF:
+		return errSealTruncated
+	case err == io.ErrUnexpectedEOF:
+		final = true
+	case err != nil:
+		return err
+	}
+
+	plain, err := r.aead.Open(r.chunk[:0], chunkNonce(r.nonce, r.n), r.chunk[:n], chunkAD(final))
+	if err != nil {
+		return fmt.Errorf("sealed chunk %d failed authentication: %w", r.n, err)
+	}
+	r.n++
+	r.plain = plain
+	r.done = final
+	return nil
+}
@@ -0,0 +1,68 @@
+package maintenance
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seal(t *testing.T, key, nonce, plaintext []byte) []byte {
+	var sealed bytes.Buffer
+	writer, err := newSealWriter(&sealed, key, nonce)
+	require.NoError(t, err)
+	_, err = writer.Write(plaintext)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return sealed.Bytes()
+}
+
+func TestSealRoundTrip(t *testing.T) {
+	key := make([]byte, 32)
+	nonce := make([]byte, sealNonceSize)
+	for _, size := range []int{0, 1, sealChunkSize - 1, sealChunkSize, sealChunkSize + 1, 3 * sealChunkSize} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+
+		sealed := seal(t, key, nonce, plaintext)
+		assert.Equal(t, sealedSize(int64(size)), int64(len(sealed)), "size %d", size)
+
+		reader, err := newOpenReader(bytes.NewReader(sealed), key, nonce)
+		require.NoError(t, err)
+		opened, err := io.ReadAll(reader)
+		require.NoError(t, err, "size %d", size)
+		assert.Equal(t, plaintext, opened, "size %d", size)
+	}
+}
+
+func TestSealDetectsTampering(t *testing.T) {
+	key := make([]byte, 32)
+	nonce := make([]byte, sealNonceSize)
+	sealed := seal(t, key, nonce, make([]byte, 2*sealChunkSize+10))
+
+	open := func(data []byte) error {
+		reader, err := newOpenReader(bytes.NewReader(data), key, nonce)
+		require.NoError(t, err)
+		_, err = io.ReadAll(reader)
+		return err
+	}
+
+	// Dropping the final chunk leaves a stream ending on a full chunk
+	assert.ErrorIs(t, open(sealed[:2*(sealChunkSize+sealOverhead)]), errSealTruncated)
+	// Cutting into a full chunk makes it look final, which fails authentication
+	assert.Error(t, open(sealed[:sealChunkSize]))
+
+	flipped := bytes.Clone(sealed)
+	flipped[100] ^= 1
+	assert.Error(t, open(flipped))
+
+	wrongKey := make([]byte, 32)
+	wrongKey[0] = 1
+	reader, err := newOpenReader(bytes.NewReader(sealed), wrongKey, nonce)
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package maintenance
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/s3"
+)
+
+// BucketStats summarises the objects of a bucket. Objects without metadata
+// were not encrypted by the proxy and are counted under an empty KMS key,
+// with their stored size as their plaintext size.
+type BucketStats struct {
+	Bucket          string     `json:"bucket"`
+	Objects         int64      `json:"objects"`
+	PlaintextBytes  int64      `json:"plaintext_bytes"`
+	CiphertextBytes int64      `json:"ciphertext_bytes"`
+	MetadataBytes   int64      `json:"metadata_bytes"`
+	Keys            []KeyStats `json:"keys"`
+	LastModified    []AgeRange `json:"last_modified"`
+	ComputedAt      time.Time  `json:"computed_at"`
+}
+
+// KeyStats counts the objects protected by one KMS key. The backend keeps
+// their data keys, so the transit key versions wrapping them are not known.
+type KeyStats struct {
+	KMSKeyARN      string `json:"kms_key_arn"`
+	Objects        int64  `json:"objects"`
+	PlaintextBytes int64  `json:"plaintext_bytes"`
+}
+
+// AgeRange counts the objects last modified within an age range before
+// ComputedAt, such as "7-30d"
+type AgeRange struct {
+	Label          string `json:"label"`
+	Objects        int64  `json:"objects"`
+	PlaintextBytes int64  `json:"plaintext_bytes"`
+}
+
+// statsAgeRanges are the upper bounds of the last-modified distribution
+var statsAgeRanges = []struct {
+	label string
+	below time.Duration
+}{
+	{"<1d", 24 * time.Hour},
+	{"1-7d", 7 * 24 * time.Hour},
+	{"7-30d", 30 * 24 * time.Hour},
+	{"30-90d", 90 * 24 * time.Hour},
+	{"90-365d", 365 * 24 * time.Hour},
+	{">365d", 0},
+}
+
+// Stats lists a bucket and reads each object's metadata to count its objects
+// and bytes, by KMS key and by age
+func Stats(ctx context.Context, client s3.Interface, bucket string) (*BucketStats, error) {
+	metadataService := metadata.NewService(client)
+	stats := &BucketStats{Bucket: bucket, ComputedAt: time.Now().UTC()}
+	for _, r := range statsAgeRanges {
+		stats.LastModified = append(stats.LastModified, AgeRange{Label: r.label})
+	}
+	keys := make(map[string]*KeyStats)
+
+	err := s3.WalkObjects(ctx, client, bucket, "", "", func(object s3.ObjectInfo) error {
+		if metadata.IsMetadataKey(object.Key) {
+			stats.MetadataBytes += object.Size
+			return nil
+		}
+
+		encryption, err := objectEncryption(ctx, metadataService, bucket, object)
+		if err != nil {
+			return err
+		}
+		plaintext, kmsKeyARN := encryption.PlaintextSize, encryption.KMSKeyARN
+
+		stats.Objects++
+		stats.PlaintextBytes += plaintext
+		stats.CiphertextBytes += object.Size
+
+		key, ok := keys[kmsKeyARN]
+		if !ok {
+			key = &KeyStats{KMSKeyARN: kmsKeyARN}
+			keys[kmsKeyARN] = key
+		}
+		key.Objects++
+		key.PlaintextBytes += plaintext
+
+		age := stats.ComputedAt.Sub(object.LastModified)
+		for i, r := range statsAgeRanges {
+			if r.below == 0 || age < r.below {
+				stats.LastModified[i].Objects++
+				stats.LastModified[i].PlaintextBytes += plaintext
+				break
+			}
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Keys = make([]KeyStats, 0, len(keys))
+	for _, key := range keys {
+		stats.Keys = append(stats.Keys, *key)
+	}
+	sort.Slice(stats.Keys, func(i, k int) bool { return stats.Keys[i].KMSKeyARN < stats.Keys[k].KMSKeyARN })
+	return stats, nil
+}
@@ -0,0 +1,45 @@
+package maintenance
+
+import (
+	"context"
+	"testing"
+
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	backend, client := newFakeBackend(t)
+	putMetadata(t, backend, "data", "a.bin", types.ObjectMetadata{ContentLength: 4, KMSKeyARN: testARN})
+	putMetadata(t, backend, "data", "b.bin", types.ObjectMetadata{ContentLength: 6, KMSKeyARN: testARN})
+	backend.put("data", "plain.txt", "plaintext")
+
+	stats, err := Stats(context.Background(), client, "data")
+	require.NoError(t, err)
+	assert.Equal(t, "data", stats.Bucket)
+	assert.Equal(t, int64(3), stats.Objects)
+	assert.Equal(t, int64(4+6+9), stats.PlaintextBytes)
+	assert.Equal(t, int64(2*len("ciphertext")+9), stats.CiphertextBytes)
+	assert.Positive(t, stats.MetadataBytes)
+
+	require.Len(t, stats.Keys, 2)
+	assert.Equal(t, KeyStats{KMSKeyARN: "", Objects: 1, PlaintextBytes: 9}, stats.Keys[0], "objects without metadata")
+	assert.Equal(t, KeyStats{KMSKeyARN: testARN, Objects: 2, PlaintextBytes: 10}, stats.Keys[1])
+
+	require.Len(t, stats.LastModified, len(statsAgeRanges))
+	var objects int64
+	for _, r := range stats.LastModified {
+		objects += r.Objects
+	}
+	assert.Equal(t, stats.Objects, objects, "every object falls in one age range")
+}
+
+func TestStatsMissingBucket(t *testing.T) {
+	_, client := newFakeBackend(t)
+	stats, err := Stats(context.Background(), client, "empty")
+	require.NoError(t, err)
+	assert.Zero(t, stats.Objects)
+	assert.Empty(t, stats.Keys)
+}
@@ -0,0 +1,227 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/pkg/types"
+)
+
+// Sync outcomes counted by the progress reporter
+const (
+	OutcomeCopied   = "copied"
+	OutcomeUpToDate = "up_to_date"
+)
+
+// SyncOptions configures a sync run
+type SyncOptions struct {
+	Bucket        string
+	Prefix        string
+	DestBucket    string // "" uses the source bucket name
+	DestKMSKeyARN string // "" keeps each object's KMS key
+	Workers       int    // objects copied concurrently, at least 1
+	Rate          float64
+	Checkpoint    *Checkpoint
+	Progress      *Progress
+}
+
+// syncJob is one object moving through the worker pool
+type syncJob struct {
+	seq     int
+	key     string
+	outcome string
+	err     error
+}
+
+// Sync copies the objects of a bucket and their metadata from source to
+// dest, which may be backends or other proxies. The source backend decrypts
+// each object as it is read and the destination encrypts it again under
+// DestKMSKeyARN, so objects can move between Vault transit keys. Objects whose
+// destination metadata already matches are skipped.
+func Sync(ctx context.Context, source, dest s3.Interface, opts SyncOptions) error {
+	destBucket := opts.DestBucket
+	if destBucket == "" {
+		destBucket = opts.Bucket
+	}
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	limiter := newLimiter(opts.Rate)
+	checkpoint := opts.Checkpoint
+	if checkpoint == nil {
+		checkpoint, _ = LoadCheckpoint("")
+	}
+	progress := opts.Progress
+	if progress == nil {
+		progress = NewProgress(nil, 0)
+	}
+	sourceMetadata := metadata.NewService(source)
+	destMetadata := metadata.NewService(dest)
+
+	jobs := make(chan syncJob)
+	results := make(chan syncJob)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				job.outcome, job.err = syncObject(ctx, source, dest, sourceMetadata, destMetadata, opts, destBucket, job.key)
+				results <- job
+			}
+		}()
+	}
+
+	var walkErr error
+	go func() {
+		defer close(jobs)
+		seq := 0
+		walkErr = s3.WalkObjects(ctx, source, opts.Bucket, opts.Prefix, checkpoint.StartAfter(opts.Bucket), func(object s3.ObjectInfo) error {
+			if metadata.IsMetadataKey(object.Key) {
+				return nil
+			}
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+			select {
+			case jobs <- syncJob{seq: seq, key: object.Key}:
+				seq++
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Workers finish out of order; the checkpoint only advances past a key
+	// once every key listed before it is done
+	finished := make(map[int]string)
+	next, processed := 0, 0
+	var saveErr error
+	for job := range results {
+		if job.err != nil {
+			progress.Logf("FAILED %s/%s: %v", opts.Bucket, job.key, job.err)
+		}
+		progress.Add(job.outcome)
+
+		finished[job.seq] = job.key
+		for key, ok := finished[next]; ok; key, ok = finished[next] {
+			checkpoint.Record(opts.Bucket, key)
+			delete(finished, next)
+			next++
+			if processed++; processed%checkpointEvery == 0 && saveErr == nil {
+				saveErr = checkpoint.Save()
+			}
+		}
+	}
+
+	err := walkErr
+	if err == nil {
+		err = saveErr
+	}
+	if saveErr := checkpoint.Save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// syncObject copies one object unless the destination already has it
+func syncObject(ctx context.Context, source, dest s3.Interface, sourceMetadata, destMetadata *metadata.Service, opts SyncOptions, destBucket, key string) (string, error) {
+	meta, err := sourceMetadata.Get(ctx, opts.Bucket, key, http.Header{})
+	if err != nil && !errors.Is(err, metadata.ErrNotFound) {
+		return OutcomeFailed, err
+	}
+	kmsKeyARN := opts.DestKMSKeyARN
+	if kmsKeyARN == "" && meta != nil {
+		kmsKeyARN = meta.KMSKeyARN
+	}
+
+	if meta != nil {
+		existing, err := destMetadata.Get(ctx, destBucket, key, http.Header{})
+		if err == nil && existing.ContentLength == meta.ContentLength &&
+			sameLastModified(existing.LastModified, meta.LastModified) && existing.KMSKeyARN == kmsKeyARN {
+			return OutcomeUpToDate, nil
+		}
+	}
+
+	resp, err := source.ForwardRequest(ctx, "GET", s3.ObjectPath(opts.Bucket, key), nil, http.Header{}, nil)
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return OutcomeFailed, fmt.Errorf("GET returned HTTP %d", resp.StatusCode)
+	}
+	if resp.ContentLength < 0 {
+		return OutcomeFailed, fmt.Errorf("source did not report the object size")
+	}
+
+	headers := http.Header{"Content-Length": {strconv.FormatInt(resp.ContentLength, 10)}}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+	var httpHeaders types.ObjectMetadata
+	httpHeaders.ReadHTTPHeaders(resp.Header)
+	httpHeaders.WriteHTTPHeaders(headers)
+	// Metadata objects carry the same SSE headers, which a destination proxy requires
+	sseHeaders := http.Header{}
+	if kmsKeyARN != "" {
+		sseHeaders.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+		sseHeaders.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", kmsKeyARN)
+		for name, values := range sseHeaders {
+			headers[name] = values
+		}
+		// The destination's KMS binds the copy to the context reads repeat
+		if meta != nil && len(meta.EncryptionContext) > 0 {
+			headers.Set("X-Amz-Server-Side-Encryption-Context", s3.FormatEncryptionContext(meta.EncryptionContext))
+		}
+	}
+
+	body := s3.NewETagReader(ctx, source, opts.Bucket, key, resp)
+	put, err := dest.ForwardRequest(ctx, "PUT", s3.ObjectPath(destBucket, key), body, headers, nil)
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	io.Copy(io.Discard, put.Body)
+	put.Body.Close()
+	if put.StatusCode >= 300 {
+		return OutcomeFailed, fmt.Errorf("destination PUT returned HTTP %d", put.StatusCode)
+	}
+
+	if meta == nil {
+		if kmsKeyARN == "" {
+			return OutcomeCopied, nil
+		}
+		meta = &types.ObjectMetadata{
+			ContentLength: resp.ContentLength,
+			ContentType:   resp.Header.Get("Content-Type"),
+			LastModified:  types.NormalizeLastModified(resp.Header.Get("Last-Modified")),
+		}
+		meta.ReadHTTPHeaders(resp.Header)
+	}
+	copied := *meta
+	copied.KMSKeyARN = kmsKeyARN
+	copied.LastModified = types.NormalizeLastModified(meta.LastModified)
+	copied.ETag = body.ETag()
+	if err := destMetadata.Store(ctx, destBucket, key, &copied, sseHeaders); err != nil {
+		return OutcomeFailed, err
+	}
+	return OutcomeCopied, nil
+}
+
+// sameLastModified compares stored timestamps, which older metadata holds as HTTP dates
+func sameLastModified(a, b string) bool {
+	return types.NormalizeLastModified(a) == types.NormalizeLastModified(b)
+}
@@ -0,0 +1,78 @@
+package maintenance
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const otherARN = "arn:aws:kms:eu-west-1:123456789012:key/87654321-4321-4321-4321-210987654321"
+
+func TestSync(t *testing.T) {
+	source, sourceClient := newFakeBackend(t)
+	dest, destClient := newFakeBackend(t)
+	putMetadata(t, source, "bucket", "a", types.ObjectMetadata{ContentLength: 10, KMSKeyARN: testARN, LastModified: "Tue, 05 Mar 2024 13:30:00 GMT"})
+	putMetadata(t, source, "bucket", "b", types.ObjectMetadata{ContentLength: 10, KMSKeyARN: testARN, LastModified: "then"})
+	source.put("bucket", "plain", "no metadata")
+
+	progress := NewProgress(nil, 0)
+	err := Sync(context.Background(), sourceClient, destClient, SyncOptions{
+		Bucket:        "bucket",
+		DestBucket:    "copy",
+		DestKMSKeyARN: otherARN,
+		Workers:       4,
+		Progress:      progress,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, progress.Count(OutcomeCopied))
+
+	body, ok := dest.get("copy", "a")
+	require.True(t, ok)
+	assert.Equal(t, "ciphertext", body)
+	assert.Equal(t, otherARN, dest.sse["copy/a"])
+	meta := readMetadata(t, dest, "copy", "a")
+	assert.Equal(t, otherARN, meta.KMSKeyARN)
+	assert.Equal(t, "2024-03-05T13:30:00Z", meta.LastModified, "the source's timestamp, stored as RFC 3339")
+
+	// A second run finds the destination up to date
+	dest.puts = 0
+	progress = NewProgress(nil, 0)
+	err = Sync(context.Background(), sourceClient, destClient, SyncOptions{
+		Bucket:        "bucket",
+		DestBucket:    "copy",
+		DestKMSKeyARN: otherARN,
+		Progress:      progress,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, progress.Count(OutcomeUpToDate))
+	assert.Equal(t, 2, dest.puts, "only the object without source metadata and its new metadata are written again")
+}
+
+func TestSyncKeepsKMSKeyAndResumes(t *testing.T) {
+	source, sourceClient := newFakeBackend(t)
+	dest, destClient := newFakeBackend(t)
+	for _, key := range []string{"a", "b"} {
+		putMetadata(t, source, "bucket", key, types.ObjectMetadata{ContentLength: 10, KMSKeyARN: testARN})
+	}
+	putMetadata(t, source, "bucket", "c", types.ObjectMetadata{ContentLength: 10, KMSKeyARN: testARN, EncryptionContext: map[string]string{"tenant": "a"}})
+
+	checkpoint, err := LoadCheckpoint(filepath.Join(t.TempDir(), "checkpoint.json"))
+	require.NoError(t, err)
+	checkpoint.Record("bucket", "a")
+
+	err = Sync(context.Background(), sourceClient, destClient, SyncOptions{Bucket: "bucket", Workers: 2, Checkpoint: checkpoint})
+	require.NoError(t, err)
+
+	_, ok := dest.get("bucket", "a")
+	assert.False(t, ok, "keys before the checkpoint are skipped")
+	assert.Equal(t, testARN, dest.sse["bucket/b"])
+	assert.Equal(t, testARN, dest.sse["bucket/c"])
+	assert.Equal(t, s3.FormatEncryptionContext(map[string]string{"tenant": "a"}), dest.contexts["bucket/c"], "the copy is bound to the recorded context")
+	assert.Equal(t, "c", checkpoint.StartAfter("bucket"))
+}
@@ -0,0 +1,163 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"s3-vault-proxy/internal/etag"
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/vault"
+)
+
+// Problems reported by Verify
+const (
+	ProblemMissingMetadata  = "missing_metadata"
+	ProblemInvalidMetadata  = "invalid_metadata"
+	ProblemUnreadable       = "unreadable"
+	ProblemSizeMismatch     = "size_mismatch"
+	ProblemETagMismatch     = "etag_mismatch"
+	ProblemDecryptFailed    = "decrypt_failed"
+	ProblemOrphanedMetadata = "orphaned_metadata"
+	// ProblemKeyVersionDisallowed is an object whose data key is wrapped by a
+	// key version below the transit key's min_decryption_version
+	ProblemKeyVersionDisallowed = "key_version_disallowed"
+)
+
+// Verify outcomes counted by the progress reporter
+const (
+	OutcomeOK      = "ok"
+	OutcomeProblem = "problem"
+)
+
+// Discrepancy is one line of the verify report
+type Discrepancy struct {
+	Bucket  string `json:"bucket"`
+	Key     string `json:"key"`
+	Problem string `json:"problem"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// VerifyOptions configures a verify run
+type VerifyOptions struct {
+	Buckets  []string
+	Prefix   string
+	Rate     float64   // objects per second, 0 is unlimited
+	Decrypt  bool      // reads the first byte of every object with metadata
+	Report   io.Writer // receives one JSON Discrepancy per line
+	Progress *Progress
+}
+
+// Verify audits every object in the given buckets: its metadata must exist
+// and parse, its size and ETag must match the metadata, and with Decrypt the
+// backend must be able to decrypt it. Metadata objects without an object are
+// reported as orphans.
+func Verify(ctx context.Context, client s3.Interface, opts VerifyOptions) error {
+	limiter := newLimiter(opts.Rate)
+	progress := opts.Progress
+	if progress == nil {
+		progress = NewProgress(nil, 0)
+	}
+	report := json.NewEncoder(opts.Report)
+	metadataService := metadata.NewService(client)
+
+	for _, bucket := range opts.Buckets {
+		err := s3.WalkObjects(ctx, client, bucket, opts.Prefix, "", func(object s3.ObjectInfo) error {
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+
+			var problems []Discrepancy
+			if metadata.IsMetadataKey(object.Key) {
+				problems = verifyMetadataObject(ctx, client, bucket, object)
+			} else {
+				problems = verifyObject(ctx, client, metadataService, opts.Decrypt, bucket, object)
+			}
+
+			if len(problems) == 0 {
+				progress.Add(OutcomeOK)
+				return nil
+			}
+			progress.Add(OutcomeProblem)
+			for _, problem := range problems {
+				if err := report.Encode(problem); err != nil {
+					return fmt.Errorf("failed to write report: %w", err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyObject checks an object against its metadata
+func verifyObject(ctx context.Context, client s3.Interface, metadataService *metadata.Service, decrypt bool, bucket string, object s3.ObjectInfo) []Discrepancy {
+	problem := func(kind, format string, args ...interface{}) Discrepancy {
+		return Discrepancy{Bucket: bucket, Key: object.Key, Problem: kind, Detail: fmt.Sprintf(format, args...)}
+	}
+
+	meta, err := metadataService.Get(ctx, bucket, object.Key, http.Header{})
+	switch {
+	case errors.Is(err, metadata.ErrNotFound):
+		return []Discrepancy{problem(ProblemMissingMetadata, "")}
+	case errors.Is(err, metadata.ErrInvalid):
+		return []Discrepancy{problem(ProblemInvalidMetadata, "%v", err)}
+	case err != nil:
+		return []Discrepancy{problem(ProblemUnreadable, "%v", err)}
+	}
+
+	var problems []Discrepancy
+	if meta.ContentLength != object.Size {
+		problems = append(problems, problem(ProblemSizeMismatch, "metadata %d, stored %d", meta.ContentLength, object.Size))
+	}
+	if meta.ETag != "" && etag.Normalize(meta.ETag) != etag.Normalize(object.ETag) {
+		problems = append(problems, problem(ProblemETagMismatch, "metadata %s, stored %s", meta.ETag, object.ETag))
+	}
+	if decrypt && object.Size > 0 {
+		err := readFirstByte(ctx, client, bucket, object.Key)
+		switch {
+		case vault.IsKeyVersionDisallowed(err):
+			problems = append(problems, problem(ProblemKeyVersionDisallowed, "%v", err))
+		case err != nil:
+			problems = append(problems, problem(ProblemDecryptFailed, "%v", err))
+		}
+	}
+	return problems
+}
+
+// readFirstByte reads the first byte of an object. The backend holds the
+// object's data key and must unwrap it with its KMS to answer, so a refused
+// key version or a damaged object surfaces as the error of the read.
+func readFirstByte(ctx context.Context, client s3.Interface, bucket, key string) error {
+	resp, err := client.ForwardRequest(ctx, http.MethodGet, s3.ObjectPath(bucket, key), nil, http.Header{"Range": {"bytes=0-0"}}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("read returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return err
+}
+
+// verifyMetadataObject reports metadata whose object no longer exists
+func verifyMetadataObject(ctx context.Context, client s3.Interface, bucket string, object s3.ObjectInfo) []Discrepancy {
+	key := strings.TrimSuffix(object.Key, ".metadata")
+	orphaned, err := isOrphaned(ctx, client, bucket, key)
+	switch {
+	case err != nil:
+		return []Discrepancy{{Bucket: bucket, Key: key, Problem: ProblemUnreadable, Detail: err.Error()}}
+	case orphaned:
+		return []Discrepancy{{Bucket: bucket, Key: key, Problem: ProblemOrphanedMetadata}}
+	}
+	return nil
+}
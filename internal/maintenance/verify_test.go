@@ -0,0 +1,55 @@
+package maintenance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	backend, client := newFakeBackend(t)
+	// putMetadata stores the 10 byte object body "ciphertext"
+	putMetadata(t, backend, "bucket", "good", types.ObjectMetadata{ContentLength: 10, KMSKeyARN: testARN})
+	putMetadata(t, backend, "bucket", "resized", types.ObjectMetadata{ContentLength: 99, KMSKeyARN: testARN})
+	putMetadata(t, backend, "bucket", "tampered", types.ObjectMetadata{ContentLength: 10, KMSKeyARN: testARN})
+	putMetadata(t, backend, "bucket", "stale", types.ObjectMetadata{ContentLength: 10, KMSKeyARN: testARN})
+	backend.errors["bucket/tampered"] = "cipher: message authentication failed"
+	backend.errors["bucket/stale"] = "vault: transit decrypt: ciphertext or signature version is disallowed by policy (too old)"
+	backend.put("bucket", "bare", "ciphertext")
+	backend.put("bucket", "corrupt", "ciphertext")
+	backend.put("bucket", "corrupt.metadata", "{not json")
+	backend.put("bucket", "gone.metadata", "{}")
+
+	var report bytes.Buffer
+	progress := NewProgress(nil, 0)
+	err := Verify(context.Background(), client, VerifyOptions{
+		Buckets:  []string{"bucket"},
+		Decrypt:  true,
+		Report:   &report,
+		Progress: progress,
+	})
+	require.NoError(t, err)
+
+	problems := make(map[string]string)
+	decoder := json.NewDecoder(&report)
+	for decoder.More() {
+		var discrepancy Discrepancy
+		require.NoError(t, decoder.Decode(&discrepancy))
+		problems[discrepancy.Key] = discrepancy.Problem
+	}
+	assert.Equal(t, map[string]string{
+		"bare":     ProblemMissingMetadata,
+		"corrupt":  ProblemInvalidMetadata,
+		"gone":     ProblemOrphanedMetadata,
+		"resized":  ProblemSizeMismatch,
+		"tampered": ProblemDecryptFailed,
+		"stale":    ProblemKeyVersionDisallowed,
+	}, problems)
+	assert.Equal(t, 6, progress.Count(OutcomeProblem))
+}
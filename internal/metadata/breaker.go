@@ -0,0 +1,59 @@
+package metadata
+
+import (
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/metrics"
+)
+
+var breakerOpen = metrics.NewGauge(
+	"s3_vault_proxy_metadata_breaker_open",
+	"Whether metadata lookups of an operation are skipped after repeated failures (1) or not (0).",
+	"operation",
+)
+
+// Breaker stops the metadata lookups of one operation once several runs in a
+// row failed or ran out of time, so requests answer with the backend's values
+// instead of waiting on a struggling metadata store. After the cooldown the
+// next run tries again, and one success closes the breaker.
+type Breaker struct {
+	operation string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// NewBreaker opens for cooldown after threshold failed runs of operation
+func NewBreaker(operation string, threshold int, cooldown time.Duration) *Breaker {
+	breakerOpen.Set(0, operation)
+	return &Breaker{operation: operation, threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Allow reports whether a run may look up metadata
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.now().Before(b.openUntil)
+}
+
+// Record counts the outcome of a run that was allowed
+func (b *Breaker) Record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !failed {
+		b.failures = 0
+		breakerOpen.Set(0, b.operation)
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.failures = 0
+		b.openUntil = b.now().Add(b.cooldown)
+		breakerOpen.Set(1, b.operation)
+	}
+}
@@ -0,0 +1,26 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	breaker := NewBreaker("test", 2, time.Minute)
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+
+	assert.True(t, breaker.Allow())
+	breaker.Record(true)
+	breaker.Record(false)
+	breaker.Record(true)
+	assert.True(t, breaker.Allow(), "a success resets the count")
+
+	breaker.Record(true)
+	assert.False(t, breaker.Allow(), "opens after threshold failures in a row")
+
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.Allow(), "tries again after the cooldown")
+}
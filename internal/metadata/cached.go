@@ -0,0 +1,88 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"s3-vault-proxy/internal/cache"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/pkg/types"
+)
+
+// CachedService caches object metadata in a KV store shared by all replicas
+type CachedService struct {
+	inner Interface
+	kv    cache.KV
+	ttl   time.Duration
+}
+
+// NewCachedService wraps a metadata service with a read-through cache
+func NewCachedService(inner Interface, kv cache.KV, ttl time.Duration) *CachedService {
+	return &CachedService{
+		inner: inner,
+		kv:    kv,
+		ttl:   ttl,
+	}
+}
+
+// Store saves metadata and refreshes the cached copy
+func (s *CachedService) Store(ctx context.Context, bucket, key string, metadata *types.ObjectMetadata, headers http.Header) error {
+	if err := s.inner.Store(ctx, bucket, key, metadata, headers); err != nil {
+		s.Invalidate(bucket, key)
+		return err
+	}
+	s.put(bucket, key, metadata)
+	return nil
+}
+
+// Get returns cached metadata, falling back to the wrapped service
+func (s *CachedService) Get(ctx context.Context, bucket, key string, headers http.Header) (*types.ObjectMetadata, error) {
+	cacheKey := s.cacheKey(bucket, key)
+
+	data, found, err := s.kv.Get(cacheKey)
+	if err != nil {
+		// A cache outage must never fail requests
+		logging.Warn().Err(err).Msg("Metadata cache lookup failed")
+	} else if found {
+		var metadata types.ObjectMetadata
+		if err := json.Unmarshal(data, &metadata); err == nil {
+			return &metadata, nil
+		}
+		_ = s.kv.Delete(cacheKey)
+	}
+
+	metadata, err := s.inner.Get(ctx, bucket, key, headers)
+	if err != nil {
+		return nil, err
+	}
+	s.put(bucket, key, metadata)
+	return metadata, nil
+}
+
+// Exists checks object existence against the wrapped service
+func (s *CachedService) Exists(ctx context.Context, bucket, key string, headers http.Header) bool {
+	return s.inner.Exists(ctx, bucket, key, headers)
+}
+
+// Invalidate removes cached metadata for an object
+func (s *CachedService) Invalidate(bucket, key string) {
+	if err := s.kv.Delete(s.cacheKey(bucket, key)); err != nil {
+		logging.Warn().Err(err).Str("bucket", bucket).Str("key", key).Msg("Failed to invalidate cached metadata")
+	}
+}
+
+func (s *CachedService) put(bucket, key string, metadata *types.ObjectMetadata) {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return
+	}
+	if err := s.kv.Set(s.cacheKey(bucket, key), data, s.ttl); err != nil {
+		logging.Warn().Err(err).Msg("Failed to cache metadata")
+	}
+}
+
+func (s *CachedService) cacheKey(bucket, key string) string {
+	return "meta:" + cache.ObjectKey(bucket, key)
+}
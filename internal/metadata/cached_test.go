@@ -0,0 +1,52 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/cache"
+	"s3-vault-proxy/pkg/types"
+	"s3-vault-proxy/tests/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedService_Get(t *testing.T) {
+	inner := &mocks.MetadataService{}
+	stored := &types.ObjectMetadata{ContentLength: 42, ETag: `"abc"`}
+	inner.On("Get", "bucket", "key", mock.Anything).Return(stored, nil)
+
+	service := NewCachedService(inner, cache.NewMemoryKV(), time.Minute)
+
+	first, err := service.Get(context.Background(), "bucket", "key", nil)
+	require.NoError(t, err)
+	second, err := service.Get(context.Background(), "bucket", "key", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(42), first.ContentLength)
+	assert.Equal(t, first, second)
+	inner.AssertNumberOfCalls(t, "Get", 1)
+
+	// Invalidation forces the next lookup through to the wrapped service
+	service.Invalidate("bucket", "key")
+	_, err = service.Get(context.Background(), "bucket", "key", nil)
+	require.NoError(t, err)
+	inner.AssertNumberOfCalls(t, "Get", 2)
+}
+
+func TestCachedService_ErrorsAreNotCached(t *testing.T) {
+	inner := &mocks.MetadataService{}
+	inner.On("Get", "bucket", "missing", mock.Anything).Return((*types.ObjectMetadata)(nil), errors.New("not found"))
+
+	service := NewCachedService(inner, cache.NewMemoryKV(), time.Minute)
+
+	_, err := service.Get(context.Background(), "bucket", "missing", nil)
+	assert.Error(t, err)
+	_, err = service.Get(context.Background(), "bucket", "missing", nil)
+	assert.Error(t, err)
+	inner.AssertNumberOfCalls(t, "Get", 2)
+}
@@ -0,0 +1,33 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"s3-vault-proxy/pkg/types"
+)
+
+// ErrUnavailable is returned by None when asked to store metadata
+var ErrUnavailable = errors.New("metadata store unavailable")
+
+// None is a metadata store holding nothing, for proxies without credentials
+// of their own to read metadata objects with: every object is reported
+// without metadata, so responses keep the backend's values
+type None struct{}
+
+// Store fails: there is nowhere to keep metadata
+func (None) Store(ctx context.Context, bucket, key string, metadata *types.ObjectMetadata, headers http.Header) error {
+	return ErrUnavailable
+}
+
+// Get reports that the object has no metadata
+func (None) Get(ctx context.Context, bucket, key string, headers http.Header) (*types.ObjectMetadata, error) {
+	return nil, fmt.Errorf("%w for object %s/%s", ErrNotFound, bucket, key)
+}
+
+// Exists reports that no metadata object exists
+func (None) Exists(ctx context.Context, bucket, key string, headers http.Header) bool {
+	return false
+}
@@ -2,7 +2,9 @@ package metadata
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,6 +15,13 @@ import (
 	"s3-vault-proxy/pkg/types"
 )
 
+var (
+	// ErrNotFound is returned by Get when an object has no metadata object
+	ErrNotFound = errors.New("metadata not found")
+	// ErrInvalid is returned by Get when the metadata object is not valid JSON
+	ErrInvalid = errors.New("invalid metadata")
+)
+
 // Service handles object metadata operations
 type Service struct {
 	s3Client s3.Interface
@@ -20,9 +29,9 @@ type Service struct {
 
 // Interface defines operations for metadata service
 type Interface interface {
-	Store(bucket, key string, metadata *types.ObjectMetadata, headers http.Header) error
-	Get(bucket, key string, headers http.Header) (*types.ObjectMetadata, error)
-	Exists(bucket, key string, headers http.Header) bool
+	Store(ctx context.Context, bucket, key string, metadata *types.ObjectMetadata, headers http.Header) error
+	Get(ctx context.Context, bucket, key string, headers http.Header) (*types.ObjectMetadata, error)
+	Exists(ctx context.Context, bucket, key string, headers http.Header) bool
 }
 
 // NewService creates a new metadata service
@@ -33,14 +42,14 @@ func NewService(s3Client s3.Interface) *Service {
 }
 
 // Store saves object metadata as a separate S3 object
-func (s *Service) Store(bucket, key string, metadata *types.ObjectMetadata, headers http.Header) error {
+func (s *Service) Store(ctx context.Context, bucket, key string, metadata *types.ObjectMetadata, headers http.Header) error {
 	metadataBytes, err := json.Marshal(metadata)
 	if err != nil {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
 	metadataKey := s.getMetadataKey(key)
-	path := fmt.Sprintf("/%s/%s", bucket, metadataKey)
+	path := s3.ObjectPath(bucket, metadataKey)
 
 	logging.Debug().
 		Str("bucket", bucket).
@@ -48,7 +57,7 @@ func (s *Service) Store(bucket, key string, metadata *types.ObjectMetadata, head
 		Str("path", path).
 		Msg("Storing object metadata")
 
-	resp, err := s.s3Client.ForwardRequest("PUT", path, bytes.NewReader(metadataBytes), headers, nil)
+	resp, err := s.s3Client.ForwardRequest(ctx, "PUT", path, bytes.NewReader(metadataBytes), headers, nil)
 	if err != nil {
 		return fmt.Errorf("failed to store metadata: %w", err)
 	}
@@ -73,9 +82,9 @@ func (s *Service) Store(bucket, key string, metadata *types.ObjectMetadata, head
 }
 
 // Get retrieves object metadata from S3
-func (s *Service) Get(bucket, key string, headers http.Header) (*types.ObjectMetadata, error) {
+func (s *Service) Get(ctx context.Context, bucket, key string, headers http.Header) (*types.ObjectMetadata, error) {
 	metadataKey := s.getMetadataKey(key)
-	path := fmt.Sprintf("/%s/%s", bucket, metadataKey)
+	path := s3.ObjectPath(bucket, metadataKey)
 
 	logging.Debug().
 		Str("bucket", bucket).
@@ -83,7 +92,7 @@ func (s *Service) Get(bucket, key string, headers http.Header) (*types.ObjectMet
 		Str("path", path).
 		Msg("Retrieving object metadata")
 
-	resp, err := s.s3Client.ForwardRequest("GET", path, nil, headers, nil)
+	resp, err := s.s3Client.ForwardRequest(ctx, "GET", path, nil, headers, nil)
 	if err != nil {
 		logging.Error().
 			Err(err).
@@ -99,7 +108,7 @@ func (s *Service) Get(bucket, key string, headers http.Header) (*types.ObjectMet
 		logging.Debug().
 			Str("path", path).
 			Msg("Metadata file not found - object may not have encryption metadata")
-		return nil, fmt.Errorf("metadata not found for object %s/%s", bucket, key)
+		return nil, fmt.Errorf("%w for object %s/%s", ErrNotFound, bucket, key)
 	case 403:
 		body, _ := io.ReadAll(resp.Body)
 		logging.Warn().
@@ -129,15 +138,30 @@ func (s *Service) Get(bucket, key string, headers http.Header) (*types.ObjectMet
 
 	var metadata types.ObjectMetadata
 	if err := json.Unmarshal(metadataBytes, &metadata); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		return nil, fmt.Errorf("%w for object %s/%s: %v", ErrInvalid, bucket, key, err)
 	}
 
 	return &metadata, nil
 }
 
+// Delete removes the metadata object of an object. Objects without one
+// succeed.
+func (s *Service) Delete(ctx context.Context, bucket, key string, headers http.Header) error {
+	resp, err := s.s3Client.ForwardRequest(ctx, "DELETE", s3.ObjectPath(bucket, s.getMetadataKey(key)), nil, headers, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete metadata: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete metadata: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // Exists checks if an object exists by performing a HEAD request
-func (s *Service) Exists(bucket, key string, headers http.Header) bool {
-	resp, err := s.s3Client.HeadObject(bucket, key, headers)
+func (s *Service) Exists(ctx context.Context, bucket, key string, headers http.Header) bool {
+	resp, err := s.s3Client.HeadObject(ctx, bucket, key, headers)
 	if err != nil {
 		logging.Debug().
 			Err(err).
@@ -163,11 +187,16 @@ func (s *Service) getMetadataKey(objectKey string) string {
 	return objectKey + ".metadata"
 }
 
+// IsMetadataKey reports whether an object key holds metadata for another object
+func IsMetadataKey(key string) bool {
+	return strings.HasSuffix(key, ".metadata")
+}
+
 // FilterMetadataObjects removes metadata files from object listings
 func FilterMetadataObjects(contents []types.Content) []types.Content {
 	filtered := make([]types.Content, 0, len(contents))
 	for _, obj := range contents {
-		if !strings.HasSuffix(obj.Key, ".metadata") {
+		if !IsMetadataKey(obj.Key) {
 			filtered = append(filtered, obj)
 		}
 	}
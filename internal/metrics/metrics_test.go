@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	registry := &Registry{}
+
+	requests := &Counter{vec: newVec("test_requests_total", "Total requests", []string{"method", "status"})}
+	inflight := &Gauge{vec: newVec("test_inflight", "In-flight requests", nil)}
+	registry.register(requests)
+	registry.register(inflight)
+
+	requests.Inc("GET", "200")
+	requests.Inc("GET", "200")
+	requests.Add(3, "PUT", "500")
+	requests.Add(-1, "PUT", "500") // ignored
+	inflight.Set(5)
+	inflight.Add(-2)
+
+	assert.Equal(t, float64(2), requests.Value("GET", "200"))
+	assert.Equal(t, float64(3), inflight.Value())
+
+	var buf bytes.Buffer
+	require.NoError(t, registry.Write(&buf))
+
+	expected := `# HELP test_inflight In-flight requests
+# TYPE test_inflight gauge
+test_inflight 3
+# HELP test_requests_total Total requests
+# TYPE test_requests_total counter
+test_requests_total{method="GET",status="200"} 2
+test_requests_total{method="PUT",status="500"} 3
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestCounter_LabelMismatchPanics(t *testing.T) {
+	counter := &Counter{vec: newVec("test_total", "Test", []string{"a"})}
+	assert.Panics(t, func() { counter.Inc() })
+}
@@ -0,0 +1,282 @@
+package multipart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/etag"
+)
+
+// DefaultMaxAge is how long an upload is tracked when it is neither
+// completed nor aborted, matching the default age at which abandoned
+// uploads are aborted
+const DefaultMaxAge = 7 * 24 * time.Hour
+
+// DefaultMaxUploads bounds how many uploads are tracked at once, so clients
+// creating uploads they never complete cannot grow the registry without end
+const DefaultMaxUploads = 10000
+
+// Upload is a multipart upload started through the proxy
+type Upload struct {
+	ID         string
+	Bucket     string
+	Key        string
+	KMSKeyARN  string
+	TransitKey string
+	// Explicit is whether the client named the KMS key rather than relying
+	// on the default key
+	Explicit bool
+	// EncryptionContext is the x-amz-server-side-encryption-context the
+	// upload was created with
+	EncryptionContext string
+	Initiated         time.Time
+
+	parts map[int]Part
+}
+
+// Part is an uploaded part of a multipart upload
+type Part struct {
+	// ETag is the backend's ETag, which clients list when completing the upload
+	ETag string
+	// Sum is the MD5 digest of the part's plaintext, nil when unknown (such
+	// as for parts copied from other objects)
+	Sum  []byte
+	Size int64
+}
+
+// Registry tracks the multipart uploads in progress, so parts are encrypted
+// under the key their upload was started with and completions can report the
+// plaintext ETag. It is held in memory: uploads started before a restart, or
+// through another replica, are unknown.
+type Registry struct {
+	maxAge     time.Duration
+	maxUploads int
+
+	mu      sync.Mutex
+	uploads map[string]*Upload
+}
+
+// NewRegistry creates an empty registry forgetting uploads after maxAge, and
+// the oldest upload when more than maxUploads are in progress
+func NewRegistry(maxAge time.Duration, maxUploads int) *Registry {
+	return &Registry{maxAge: maxAge, maxUploads: maxUploads, uploads: make(map[string]*Upload)}
+}
+
+// Start records a new upload, dropping uploads older than the registry's
+// maximum age and, when it is full, the oldest upload. Completions of
+// dropped uploads keep the backend's ETag.
+func (r *Registry) Start(upload Upload) {
+	upload.parts = make(map[int]Part)
+	if upload.Initiated.IsZero() {
+		upload.Initiated = time.Now()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	oldest := ""
+	for id, tracked := range r.uploads {
+		if time.Since(tracked.Initiated) > r.maxAge {
+			delete(r.uploads, id)
+		} else if oldest == "" || tracked.Initiated.Before(r.uploads[oldest].Initiated) {
+			oldest = id
+		}
+	}
+	if _, replaced := r.uploads[upload.ID]; !replaced && len(r.uploads) >= r.maxUploads && oldest != "" {
+		delete(r.uploads, oldest)
+	}
+	r.uploads[upload.ID] = &upload
+}
+
+// Get returns a copy of the upload with id of bucket and key, without its
+// parts, or nil when it is not tracked
+func (r *Registry) Get(id, bucket, key string) *Upload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upload, ok := r.uploads[id]
+	if !ok || upload.Bucket != bucket || upload.Key != key {
+		return nil
+	}
+	copied := *upload
+	copied.parts = nil
+	return &copied
+}
+
+// AddPart records an uploaded part, replacing an earlier upload of the same
+// part number. Parts of untracked uploads are ignored.
+func (r *Registry) AddPart(id string, number int, part Part) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if upload, ok := r.uploads[id]; ok {
+		upload.parts[number] = part
+	}
+}
+
+// Remove forgets a completed or aborted upload
+func (r *Registry) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.uploads, id)
+}
+
+// Parts returns the recorded parts of an upload by part number, or nil when
+// the upload is not tracked
+func (r *Registry) Parts(id string) map[int]Part {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upload, ok := r.uploads[id]
+	if !ok {
+		return nil
+	}
+	parts := make(map[int]Part, len(upload.parts))
+	for number, part := range upload.parts {
+		parts[number] = part
+	}
+	return parts
+}
+
+// PlaintextETag returns the multipart ETag of the plaintext of an upload
+// completed with completed, and its size. It fails when a listed part was not
+// recorded, was replaced since the client listed it, or has no digest.
+func (r *Registry) PlaintextETag(id string, completed []CompletedPart) (string, int64, error) {
+	parts := r.Parts(id)
+	if parts == nil {
+		return "", 0, fmt.Errorf("upload %s is not tracked", id)
+	}
+	sums := make([][]byte, 0, len(completed))
+	var size int64
+	for _, listed := range completed {
+		part, ok := parts[listed.PartNumber]
+		if !ok || part.Sum == nil || etag.Normalize(part.ETag) != etag.Normalize(listed.ETag) {
+			return "", 0, fmt.Errorf("part %d of upload %s is not recorded", listed.PartNumber, id)
+		}
+		sums = append(sums, part.Sum)
+		size += part.Size
+	}
+	return etag.Multipart(sums), size, nil
+}
+
+// InitiateResult is the response to CreateMultipartUpload
+type InitiateResult struct {
+	Bucket   string `xml:"Bucket"`
+	Key      string `xml:"Key"`
+	UploadID string `xml:"UploadId"`
+}
+
+// ParseInitiateResult reads the upload ID from a CreateMultipartUpload response
+func ParseInitiateResult(body []byte) (*InitiateResult, error) {
+	var result InitiateResult
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("invalid InitiateMultipartUploadResult: %w", err)
+	}
+	if result.UploadID == "" {
+		return nil, fmt.Errorf("InitiateMultipartUploadResult has no UploadId")
+	}
+	return &result, nil
+}
+
+// CompletedPart is a part listed in a CompleteMultipartUpload request
+type CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// ParseCompletion reads the parts of a CompleteMultipartUpload request body,
+// sorted by part number
+func ParseCompletion(body []byte) ([]CompletedPart, error) {
+	var request struct {
+		Parts []CompletedPart `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("invalid CompleteMultipartUpload: %w", err)
+	}
+	sort.SliceStable(request.Parts, func(i, j int) bool {
+		return request.Parts[i].PartNumber < request.Parts[j].PartNumber
+	})
+	return request.Parts, nil
+}
+
+// CompleteResult is the response to a successful CompleteMultipartUpload
+type CompleteResult struct {
+	XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+	ETag    string   `xml:"ETag"`
+}
+
+// ParseCompleteResult reads a CompleteMultipartUpload response. S3 may answer
+// 200 and report a failure in the body, which is returned as an error.
+func ParseCompleteResult(body []byte) (*CompleteResult, error) {
+	var result CompleteResult
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("upload was not completed: %w", err)
+	}
+	return &result, nil
+}
+
+// ReplaceETag returns a CompleteMultipartUploadResult body with its ETag
+// element set to value, leaving the other elements as the backend wrote them
+func ReplaceETag(body []byte, value string) []byte {
+	return replaceElement(body, "ETag", value)
+}
+
+// ReplacePartSizes returns a ListPartsResult body with the Size of each
+// recorded part set to its plaintext size. Parts without a digest, replaced
+// since, or not recorded keep the backend's size, as do the other elements.
+func ReplacePartSizes(body []byte, parts map[int]Part) []byte {
+	var replaced bytes.Buffer
+	rest := body
+	for {
+		start := bytes.Index(rest, []byte("<Part>"))
+		if start < 0 {
+			break
+		}
+		end := bytes.Index(rest[start:], []byte("</Part>"))
+		if end < 0 {
+			break
+		}
+		end += start + len("</Part>")
+		replaced.Write(rest[:start])
+		replaced.Write(replacePartSize(rest[start:end], parts))
+		rest = rest[end:]
+	}
+	replaced.Write(rest)
+	return replaced.Bytes()
+}
+
+// replacePartSize sets the Size of one listed <Part> element
+func replacePartSize(element []byte, parts map[int]Part) []byte {
+	var listed CompletedPart
+	if err := xml.Unmarshal(element, &listed); err != nil {
+		return element
+	}
+	part, ok := parts[listed.PartNumber]
+	if !ok || part.Sum == nil || etag.Normalize(part.ETag) != etag.Normalize(listed.ETag) {
+		return element
+	}
+	return replaceElement(element, "Size", strconv.FormatInt(part.Size, 10))
+}
+
+// replaceElement returns body with the text of its first element named name
+// set to value
+func replaceElement(body []byte, name, value string) []byte {
+	open, closing := []byte("<"+name+">"), []byte("</"+name+">")
+	start := bytes.Index(body, open)
+	if start < 0 {
+		return body
+	}
+	start += len(open)
+	end := bytes.Index(body[start:], closing)
+	if end < 0 {
+		return body
+	}
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(value))
+
+	replaced := make([]byte, 0, len(body)+escaped.Len())
+	replaced = append(replaced, body[:start]...)
+	replaced = append(replaced, escaped.Bytes()...)
+	return append(replaced, body[start+end:]...)
+}
@@ -0,0 +1,120 @@
+package multipart
+
+import (
+	"crypto/md5"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/etag"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sum(data string) []byte {
+	digest := md5.Sum([]byte(data))
+	return digest[:]
+}
+
+func TestRegistryTracksUploads(t *testing.T) {
+	registry := NewRegistry(time.Hour, 10)
+	registry.Start(Upload{ID: "old", Bucket: "b", Key: "k", Initiated: time.Now().Add(-2 * time.Hour)})
+	registry.Start(Upload{ID: "up", Bucket: "b", Key: "k", KMSKeyARN: "arn"})
+
+	assert.Nil(t, registry.Get("old", "b", "k"), "expired uploads are dropped")
+	assert.Nil(t, registry.Get("up", "b", "other"), "uploads belong to one key")
+	upload := registry.Get("up", "b", "k")
+	require.NotNil(t, upload)
+	assert.Equal(t, "arn", upload.KMSKeyARN)
+
+	registry.AddPart("up", 2, Part{ETag: `"backend-2"`, Sum: sum("world"), Size: 5})
+	registry.AddPart("up", 1, Part{ETag: `"stale"`, Sum: sum("x"), Size: 1})
+	registry.AddPart("up", 1, Part{ETag: `"backend-1"`, Sum: sum("hello "), Size: 6})
+	registry.AddPart("unknown", 1, Part{ETag: `"ignored"`})
+
+	plaintextETag, size, err := registry.PlaintextETag("up", []CompletedPart{{1, `"backend-1"`}, {2, "backend-2"}})
+	require.NoError(t, err)
+	assert.Equal(t, etag.Multipart([][]byte{sum("hello "), sum("world")}), plaintextETag)
+	assert.Equal(t, int64(11), size)
+
+	_, _, err = registry.PlaintextETag("up", []CompletedPart{{1, `"stale"`}})
+	assert.Error(t, err, "replaced parts cannot be completed")
+	_, _, err = registry.PlaintextETag("up", []CompletedPart{{3, `"backend-3"`}})
+	assert.Error(t, err)
+
+	registry.Remove("up")
+	assert.Nil(t, registry.Get("up", "b", "k"))
+	_, _, err = registry.PlaintextETag("up", nil)
+	assert.Error(t, err)
+}
+
+func TestRegistryDropsOldestUploadWhenFull(t *testing.T) {
+	registry := NewRegistry(time.Hour, 2)
+	registry.Start(Upload{ID: "first", Bucket: "b", Key: "k", Initiated: time.Now().Add(-time.Minute)})
+	registry.Start(Upload{ID: "second", Bucket: "b", Key: "k"})
+	registry.Start(Upload{ID: "second", Bucket: "b", Key: "k"})
+	require.NotNil(t, registry.Get("first", "b", "k"), "restarting a tracked upload makes no room")
+
+	registry.Start(Upload{ID: "third", Bucket: "b", Key: "k"})
+	assert.Nil(t, registry.Get("first", "b", "k"))
+	assert.NotNil(t, registry.Get("second", "b", "k"))
+	assert.NotNil(t, registry.Get("third", "b", "k"))
+}
+
+func TestParseCompletion(t *testing.T) {
+	parts, err := ParseCompletion([]byte(`<CompleteMultipartUpload xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+		<Part><ETag>"b"</ETag><PartNumber>2</PartNumber></Part>
+		<Part><ETag>"a"</ETag><PartNumber>1</PartNumber></Part>
+	</CompleteMultipartUpload>`))
+	require.NoError(t, err)
+	assert.Equal(t, []CompletedPart{{1, `"a"`}, {2, `"b"`}}, parts)
+
+	_, err = ParseCompletion([]byte("not xml"))
+	assert.Error(t, err)
+}
+
+func TestCompleteResult(t *testing.T) {
+	body := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<CompleteMultipartUploadResult><Location>http://b/k</Location><Bucket>b</Bucket><Key>k</Key><ETag>&#34;backend-2&#34;</ETag></CompleteMultipartUploadResult>`)
+	result, err := ParseCompleteResult(body)
+	require.NoError(t, err)
+	assert.Equal(t, `"backend-2"`, result.ETag)
+
+	replaced := ReplaceETag(body, `"plain-2"`)
+	result, err = ParseCompleteResult(replaced)
+	require.NoError(t, err)
+	assert.Equal(t, `"plain-2"`, result.ETag)
+	assert.Contains(t, string(replaced), "<Location>http://b/k</Location>")
+
+	_, err = ParseCompleteResult([]byte(`<Error><Code>InternalError</Code></Error>`))
+	assert.Error(t, err, "errors reported with 200 are failures")
+}
+
+func TestParseInitiateResult(t *testing.T) {
+	result, err := ParseInitiateResult([]byte(`<InitiateMultipartUploadResult><Bucket>b</Bucket><Key>k</Key><UploadId>abc</UploadId></InitiateMultipartUploadResult>`))
+	require.NoError(t, err)
+	assert.Equal(t, "abc", result.UploadID)
+
+	_, err = ParseInitiateResult([]byte(`<InitiateMultipartUploadResult></InitiateMultipartUploadResult>`))
+	assert.Error(t, err)
+}
+
+func TestReplacePartSizes(t *testing.T) {
+	body := []byte(`<ListPartsResult><Bucket>b</Bucket><UploadId>up</UploadId>` +
+		`<Part><PartNumber>1</PartNumber><ETag>"backend-1"</ETag><Size>22</Size></Part>` +
+		`<Part><PartNumber>2</PartNumber><ETag>"replaced"</ETag><Size>21</Size></Part>` +
+		`<Part><PartNumber>3</PartNumber><ETag>"copied"</ETag><Size>20</Size></Part>` +
+		`<IsTruncated>false</IsTruncated></ListPartsResult>`)
+	parts := map[int]Part{
+		1: {ETag: `"backend-1"`, Sum: sum("hello "), Size: 6},
+		2: {ETag: `"backend-2"`, Sum: sum("world"), Size: 5},
+		3: {ETag: `"copied"`},
+	}
+
+	replaced := string(ReplacePartSizes(body, parts))
+	assert.Contains(t, replaced, `<PartNumber>1</PartNumber><ETag>"backend-1"</ETag><Size>6</Size>`)
+	assert.Contains(t, replaced, `<Size>21</Size>`, "parts replaced since keep the backend's size")
+	assert.Contains(t, replaced, `<Size>20</Size>`, "parts without a digest keep the backend's size")
+	assert.Contains(t, replaced, `<UploadId>up</UploadId>`)
+	assert.Contains(t, replaced, `<IsTruncated>false</IsTruncated></ListPartsResult>`)
+}
@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Message is the body of a notification, in the S3 event message structure
+type Message struct {
+	Records []Record `json:"Records"`
+}
+
+// Record describes one event
+type Record struct {
+	EventVersion      string            `json:"eventVersion"`
+	EventSource       string            `json:"eventSource"`
+	AWSRegion         string            `json:"awsRegion"`
+	EventTime         string            `json:"eventTime"`
+	EventName         string            `json:"eventName"`
+	UserIdentity      Identity          `json:"userIdentity"`
+	RequestParameters map[string]string `json:"requestParameters"`
+	ResponseElements  map[string]string `json:"responseElements"`
+	S3                Entity            `json:"s3"`
+}
+
+// Identity identifies who caused an event or owns a bucket
+type Identity struct {
+	PrincipalID string `json:"principalId"`
+}
+
+// Entity is the bucket and object an event concerns
+type Entity struct {
+	SchemaVersion   string `json:"s3SchemaVersion"`
+	ConfigurationID string `json:"configurationId"`
+	Bucket          Bucket `json:"bucket"`
+	Object          Object `json:"object"`
+}
+
+// Bucket is the bucket of an event
+type Bucket struct {
+	Name          string   `json:"name"`
+	OwnerIdentity Identity `json:"ownerIdentity"`
+	ARN           string   `json:"arn"`
+}
+
+// Object is the object of an event. Key is URL-encoded, as S3 sends it.
+type Object struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+// configurationID names the notification configuration in every record
+const configurationID = "s3-vault-proxy"
+
+// message builds the S3 event message of an event
+func (n *Notifier) message(event Event) Message {
+	return Message{Records: []Record{{
+		EventVersion:      "2.1",
+		EventSource:       "aws:s3",
+		AWSRegion:         n.opts.Credentials.Region,
+		EventTime:         event.Time.UTC().Format("2006-01-02T15:04:05.000Z"),
+		EventName:         event.Name,
+		UserIdentity:      Identity{PrincipalID: event.PrincipalID},
+		RequestParameters: map[string]string{"sourceIPAddress": event.SourceIP},
+		ResponseElements:  map[string]string{"x-amz-request-id": event.RequestID},
+		S3: Entity{
+			SchemaVersion:   "1.0",
+			ConfigurationID: configurationID,
+			Bucket: Bucket{
+				Name: event.Bucket,
+				ARN:  "arn:aws:s3:::" + event.Bucket,
+			},
+			Object: Object{
+				Key:       url.QueryEscape(event.Key),
+				Size:      event.Size,
+				ETag:      trimQuotes(event.ETag),
+				Sequencer: fmt.Sprintf("%016X", event.sequence),
+			},
+		},
+	}}}
+}
+
+func trimQuotes(etag string) string {
+	if len(etag) >= 2 && etag[0] == '"' && etag[len(etag)-1] == '"' {
+		return etag[1 : len(etag)-1]
+	}
+	return etag
+}
@@ -0,0 +1,247 @@
+// Package notify delivers bucket events to an SQS-compatible queue (Amazon
+// SQS, ElasticMQ) as S3 event notification JSON, so consumers of S3 events
+// work unchanged.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/sigv4"
+)
+
+// Event names, as in the eventName of S3 event records
+const (
+	ObjectCreatedPut                     = "ObjectCreated:Put"
+	ObjectCreatedCompleteMultipartUpload = "ObjectCreated:CompleteMultipartUpload"
+	ObjectRemovedDelete                  = "ObjectRemoved:Delete"
+)
+
+var (
+	notificationsTotal = metrics.NewCounter(
+		"s3_vault_proxy_notifications_total",
+		"Bucket event notifications by outcome (sent, failed, dropped).",
+		"status",
+	)
+	notificationQueueDepth = metrics.NewGauge(
+		"s3_vault_proxy_notification_queue_depth",
+		"Bucket events waiting to be delivered.",
+	)
+)
+
+// Options configures a Notifier
+type Options struct {
+	QueueURL string
+	// Credentials sign SendMessage with SigV4 for the "sqs" service. An empty
+	// access key sends unsigned requests, which ElasticMQ accepts.
+	Credentials sigv4.Credentials
+	// Buckets whose events are sent; empty sends every bucket's
+	Buckets []string
+	// Events sent, such as "s3:ObjectCreated:*" or "s3:ObjectRemoved:Delete"
+	Events    []string
+	Workers   int
+	QueueSize int
+	// Attempts per event before it is dropped, retried after RetryDelay and
+	// then twice as long each time
+	Attempts   int
+	RetryDelay time.Duration
+	Client     *http.Client
+}
+
+// Event is a change to an object. Time defaults to when it is published.
+type Event struct {
+	Name        string
+	Bucket      string
+	Key         string
+	Size        int64
+	ETag        string
+	Time        time.Time
+	PrincipalID string
+	SourceIP    string
+	RequestID   string
+
+	sequence uint64
+}
+
+// Notifier sends published events to the queue in the background
+type Notifier struct {
+	opts    Options
+	queue   chan Event
+	wg      sync.WaitGroup
+	buckets map[string]bool
+	seq     atomic.Uint64
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// New returns a notifier for the queue at opts.QueueURL. Start runs its workers.
+func New(opts Options) (*Notifier, error) {
+	if u, err := url.Parse(opts.QueueURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid SQS queue URL %q", opts.QueueURL)
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.Attempts <= 0 {
+		opts.Attempts = 3
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = time.Second
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	n := &Notifier{opts: opts, queue: make(chan Event, opts.QueueSize)}
+	// Sequencers start from the clock so they keep increasing across restarts
+	n.seq.Store(uint64(time.Now().UnixNano()))
+	if len(opts.Buckets) > 0 {
+		n.buckets = make(map[string]bool, len(opts.Buckets))
+		for _, bucket := range opts.Buckets {
+			n.buckets[bucket] = true
+		}
+	}
+	return n, nil
+}
+
+// Start runs the delivery workers until Close
+func (n *Notifier) Start() {
+	for i := 0; i < n.opts.Workers; i++ {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			for event := range n.queue {
+				notificationQueueDepth.Add(-1)
+				n.deliver(event)
+			}
+		}()
+	}
+}
+
+// Close stops accepting events and waits for the queued ones to be delivered
+func (n *Notifier) Close() {
+	n.mu.Lock()
+	n.closed = true
+	close(n.queue)
+	n.mu.Unlock()
+	n.wg.Wait()
+}
+
+// Publish queues an event when its bucket and name are configured. Events
+// are dropped when the queue is full rather than delaying the request.
+func (n *Notifier) Publish(event Event) {
+	if !n.Wants(event.Bucket, event.Name) {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	// Sequencers increase in publish order, so consumers can order the
+	// events of a key however the workers deliver them
+	event.sequence = n.seq.Add(1)
+
+	n.mu.Lock()
+	queued := false
+	if !n.closed {
+		select {
+		case n.queue <- event:
+			queued = true
+			notificationQueueDepth.Add(1)
+		default:
+		}
+	}
+	n.mu.Unlock()
+	if !queued {
+		notificationsTotal.Inc("dropped")
+		logging.Error().Str("bucket", event.Bucket).Str("key", event.Key).Str("event", event.Name).Msg("Notification queue is full or closed")
+	}
+}
+
+// Wants reports whether events named name in bucket are sent
+func (n *Notifier) Wants(bucket, name string) bool {
+	if n.buckets != nil && !n.buckets[bucket] {
+		return false
+	}
+	for _, pattern := range n.opts.Events {
+		if matchEvent(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchEvent matches an event name against a configured event such as
+// "s3:ObjectCreated:*"
+func matchEvent(pattern, name string) bool {
+	pattern = strings.TrimPrefix(pattern, "s3:")
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(name, prefix)
+	}
+	return pattern == name
+}
+
+// deliver sends one event, retrying failures
+func (n *Notifier) deliver(event Event) {
+	message, err := json.Marshal(n.message(event))
+	if err != nil {
+		notificationsTotal.Inc("failed")
+		logging.Error().Err(err).Msg("Failed to encode notification")
+		return
+	}
+
+	delay := n.opts.RetryDelay
+	for attempt := 1; ; attempt++ {
+		err := n.send(message)
+		if err == nil {
+			notificationsTotal.Inc("sent")
+			return
+		}
+		logging.Warn().Err(err).Str("bucket", event.Bucket).Str("key", event.Key).Int("attempt", attempt).Msg("Notification attempt failed")
+		if attempt == n.opts.Attempts {
+			notificationsTotal.Inc("failed")
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// send calls SendMessage with the query protocol, which Amazon SQS and
+// ElasticMQ both accept
+func (n *Notifier) send(message []byte) error {
+	form := url.Values{
+		"Action":      {"SendMessage"},
+		"MessageBody": {string(message)},
+		"Version":     {"2012-11-05"},
+	}
+	body := []byte(form.Encode())
+	req, err := http.NewRequest(http.MethodPost, n.opts.QueueURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if n.opts.Credentials.AccessKey != "" {
+		n.opts.Credentials.SignPayload("sqs", req.Method, req.URL.Host, req.URL.EscapedPath(), req.URL.RawQuery, req.Header, body, time.Now())
+	}
+
+	resp, err := n.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("SendMessage returned HTTP %d: %s", resp.StatusCode, detail)
+	}
+	return nil
+}
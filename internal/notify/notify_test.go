@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/sigv4"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQueue records the messages sent to it, failing the first failures requests
+type fakeQueue struct {
+	mu       sync.Mutex
+	failures int
+	messages []Message
+	auth     []string
+}
+
+func (q *fakeQueue) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.failures > 0 {
+		q.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if r.FormValue("Action") != "SendMessage" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var message Message
+	if err := json.Unmarshal([]byte(r.FormValue("MessageBody")), &message); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	q.messages = append(q.messages, message)
+	q.auth = append(q.auth, r.Header.Get("Authorization"))
+	w.Write([]byte("<SendMessageResponse/>"))
+}
+
+func (q *fakeQueue) received() []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]Message(nil), q.messages...)
+}
+
+func TestNotifier(t *testing.T) {
+	queue := &fakeQueue{failures: 1}
+	server := httptest.NewServer(queue)
+	defer server.Close()
+
+	notifier, err := New(Options{
+		QueueURL:    server.URL + "/000000000000/events",
+		Credentials: sigv4.Credentials{AccessKey: "AK", SecretKey: "SK", Region: "eu-west-1"},
+		Buckets:     []string{"photos"},
+		Events:      []string{"s3:ObjectCreated:*"},
+		QueueSize:   10,
+		RetryDelay:  time.Millisecond,
+	})
+	require.NoError(t, err)
+	notifier.Start()
+
+	notifier.Publish(Event{Name: ObjectCreatedPut, Bucket: "photos", Key: "2024/cat photo.jpg", Size: 42, ETag: `"abc"`, PrincipalID: "AK", SourceIP: "10.0.0.1"})
+	notifier.Publish(Event{Name: ObjectRemovedDelete, Bucket: "photos", Key: "removed.jpg"})
+	notifier.Publish(Event{Name: ObjectCreatedPut, Bucket: "other", Key: "ignored.jpg"})
+	notifier.Close()
+
+	messages := queue.received()
+	require.Len(t, messages, 1, "only configured buckets and events are sent, after a retry")
+	require.Len(t, messages[0].Records, 1)
+	record := messages[0].Records[0]
+	assert.Equal(t, "aws:s3", record.EventSource)
+	assert.Equal(t, "eu-west-1", record.AWSRegion)
+	assert.Equal(t, ObjectCreatedPut, record.EventName)
+	assert.Equal(t, "AK", record.UserIdentity.PrincipalID)
+	assert.Equal(t, "10.0.0.1", record.RequestParameters["sourceIPAddress"])
+	assert.Equal(t, "photos", record.S3.Bucket.Name)
+	assert.Equal(t, "arn:aws:s3:::photos", record.S3.Bucket.ARN)
+	assert.Equal(t, "2024%2Fcat+photo.jpg", record.S3.Object.Key)
+	assert.Equal(t, int64(42), record.S3.Object.Size)
+	assert.Equal(t, "abc", record.S3.Object.ETag)
+	assert.NotEmpty(t, record.S3.Object.Sequencer)
+
+	auth, err := sigv4.ParseAuthorization(queue.auth[0])
+	require.NoError(t, err)
+	assert.Equal(t, "sqs", auth.Service)
+}
+
+func TestNotifierSequencers(t *testing.T) {
+	notifier, err := New(Options{QueueURL: "http://elasticmq:9324/queue/events", Events: []string{"s3:*"}, QueueSize: 2})
+	require.NoError(t, err)
+	notifier.Publish(Event{Name: ObjectCreatedPut, Bucket: "b", Key: "k"})
+	notifier.Publish(Event{Name: ObjectRemovedDelete, Bucket: "b", Key: "k"})
+
+	first := notifier.message(<-notifier.queue).Records[0].S3.Object.Sequencer
+	second := notifier.message(<-notifier.queue).Records[0].S3.Object.Sequencer
+	assert.Len(t, second, len(first))
+	assert.Less(t, first, second, "later events of a key sort after earlier ones")
+}
+
+func TestMatchEvent(t *testing.T) {
+	assert.True(t, matchEvent("s3:ObjectCreated:*", ObjectCreatedPut))
+	assert.True(t, matchEvent("s3:ObjectRemoved:Delete", ObjectRemovedDelete))
+	assert.True(t, matchEvent("s3:*", ObjectRemovedDelete))
+	assert.False(t, matchEvent("s3:ObjectCreated:Copy", ObjectCreatedPut))
+	assert.False(t, matchEvent("s3:ObjectRemoved:*", ObjectCreatedPut))
+}
+
+func TestNewRejectsInvalidQueueURL(t *testing.T) {
+	_, err := New(Options{QueueURL: "sqs/events"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,70 @@
+package phases
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Request phases timed for slow request reports
+const (
+	Auth          = "auth"
+	Vault         = "vault"
+	Backend       = "backend"
+	RequestBody   = "request_body"
+	Serialization = "serialization"
+)
+
+// Recorder accumulates time spent in each phase of one request.
+// A nil *Recorder is valid and records nothing.
+type Recorder struct {
+	mu     sync.Mutex
+	phases map[string]time.Duration
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{phases: make(map[string]time.Duration)}
+}
+
+// Since adds the time elapsed since start to phase. It is meant to be deferred
+// or called right after the timed operation.
+func (r *Recorder) Since(phase string, start time.Time) {
+	if r == nil {
+		return
+	}
+	elapsed := time.Since(start)
+	r.mu.Lock()
+	r.phases[phase] += elapsed
+	r.mu.Unlock()
+}
+
+// Durations returns a copy of the recorded phase durations
+func (r *Recorder) Durations() map[string]time.Duration {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	durations := make(map[string]time.Duration, len(r.phases))
+	for phase, d := range r.phases {
+		durations[phase] = d
+	}
+	return durations
+}
+
+type recorderContextKey struct{}
+
+// ContextWithRecorder returns a context carrying r
+func ContextWithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderContextKey{}, r)
+}
+
+// FromContext returns the recorder carried by ctx, or nil
+func FromContext(ctx context.Context) *Recorder {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(recorderContextKey{}).(*Recorder)
+	return r
+}
@@ -0,0 +1,39 @@
+package phases
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorderAccumulates(t *testing.T) {
+	r := NewRecorder()
+	start := time.Now().Add(-10 * time.Millisecond)
+	r.Since(Backend, start)
+	r.Since(Backend, start)
+	r.Since(Vault, time.Now())
+
+	durations := r.Durations()
+	assert.GreaterOrEqual(t, durations[Backend], 20*time.Millisecond)
+	assert.Contains(t, durations, Vault)
+	assert.NotContains(t, durations, Auth)
+
+	// Durations returns a copy
+	durations[Auth] = time.Second
+	assert.NotContains(t, r.Durations(), Auth)
+}
+
+func TestNilRecorder(t *testing.T) {
+	var r *Recorder
+	r.Since(Backend, time.Now())
+	assert.Nil(t, r.Durations())
+	assert.Nil(t, FromContext(context.Background()))
+}
+
+func TestContextRoundTrip(t *testing.T) {
+	r := NewRecorder()
+	ctx := ContextWithRecorder(context.Background(), r)
+	assert.Same(t, r, FromContext(ctx))
+}
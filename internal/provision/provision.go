@@ -0,0 +1,199 @@
+// Package provision creates buckets on their first upload, for workloads
+// that write to a changing set of buckets, such as per-branch CI artifacts.
+// Only bucket names matching the configured patterns are created, with the
+// operator credentials: the client signed its upload, not a CreateBucket.
+package provision
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/s3"
+)
+
+// knownTTL bounds how long a bucket is assumed to exist without asking the
+// backend again, so buckets deleted through another replica are recreated
+const knownTTL = 5 * time.Minute
+
+// ValidatePatterns checks that every pattern is a valid path.Match pattern
+func ValidatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid bucket pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Provisioner creates the buckets matching its patterns when they are missing
+type Provisioner struct {
+	client   s3.Interface
+	patterns []string
+	now      func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucketState
+}
+
+type bucketState struct {
+	// mu serializes the checks of one bucket, so concurrent first uploads
+	// create it once
+	mu    sync.Mutex
+	known time.Time
+}
+
+// NewProvisioner creates buckets whose names match one of patterns
+// (path.Match syntax) with client
+func NewProvisioner(client s3.Interface, patterns []string) *Provisioner {
+	return &Provisioner{
+		client:   client,
+		patterns: patterns,
+		now:      time.Now,
+		buckets:  make(map[string]*bucketState),
+	}
+}
+
+// Matches reports whether bucket may be created on its first upload
+func (p *Provisioner) Matches(bucket string) bool {
+	if p == nil {
+		return false
+	}
+	for _, pattern := range p.patterns {
+		if matched, _ := path.Match(pattern, bucket); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Ensure creates bucket when the backend does not have it, reporting whether
+// it was created. Buckets created concurrently by another replica count as
+// existing.
+func (p *Provisioner) Ensure(ctx context.Context, bucket string) (bool, error) {
+	state := p.state(bucket)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if p.now().Sub(state.known) < knownTTL {
+		return false, nil
+	}
+
+	exists, err := p.exists(ctx, bucket)
+	if err != nil {
+		return false, err
+	}
+	created := false
+	if !exists {
+		if created, err = p.create(ctx, bucket); err != nil {
+			return false, err
+		}
+	}
+	state.known = p.now()
+	return created, nil
+}
+
+// Forget drops what is known of bucket, so it is created again on its next
+// upload after being deleted
+func (p *Provisioner) Forget(bucket string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.buckets, bucket)
+}
+
+func (p *Provisioner) state(bucket string) *bucketState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state, ok := p.buckets[bucket]
+	if !ok {
+		state = &bucketState{}
+		p.buckets[bucket] = state
+	}
+	return state
+}
+
+// exists asks the backend for bucket. A bucket the operator may not read
+// exists too: it is someone else's, and the upload is left to the backend.
+func (p *Provisioner) exists(ctx context.Context, bucket string) (bool, error) {
+	resp, err := p.client.ForwardRequest(ctx, http.MethodHead, "/"+bucket, nil, http.Header{}, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to check bucket %s: %w", bucket, err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode < 300, resp.StatusCode == http.StatusForbidden:
+		return true, nil
+	default:
+		return false, fmt.Errorf("failed to check bucket %s: HTTP %d", bucket, resp.StatusCode)
+	}
+}
+
+func (p *Provisioner) create(ctx context.Context, bucket string) (bool, error) {
+	resp, err := p.client.ForwardRequest(ctx, http.MethodPut, "/"+bucket, nil, http.Header{"Content-Length": {"0"}}, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create bucket %s: %w", bucket, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 {
+		return true, nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var errResp struct {
+		Code string `xml:"Code"`
+	}
+	xml.Unmarshal(body, &errResp)
+	if errResp.Code == "BucketAlreadyOwnedByYou" {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to create bucket %s: HTTP %d %s", bucket, resp.StatusCode, errResp.Code)
+}
+
+// encryptionConfiguration is the PutBucketEncryption body setting a bucket's
+// default SSE-KMS key
+type encryptionConfiguration struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ServerSideEncryptionConfiguration"`
+	Rule    struct {
+		Default struct {
+			Algorithm string `xml:"SSEAlgorithm"`
+			KeyID     string `xml:"KMSMasterKeyID"`
+		} `xml:"ApplyServerSideEncryptionByDefault"`
+	} `xml:"Rule"`
+}
+
+// SetDefaultKey makes kmsKeyARN the default encryption of bucket on the
+// backend, so uploads without SSE-KMS headers are encrypted with it.
+// Backends without PutBucketEncryption answer an error.
+func (p *Provisioner) SetDefaultKey(ctx context.Context, bucket, kmsKeyARN string) error {
+	var config encryptionConfiguration
+	config.Rule.Default.Algorithm = "aws:kms"
+	config.Rule.Default.KeyID = kmsKeyARN
+	body, err := xml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	digest := md5.Sum(body)
+	resp, err := p.client.ForwardRequest(ctx, http.MethodPut, "/"+bucket, bytes.NewReader(body), http.Header{
+		"Content-Type":   {"application/xml"},
+		"Content-Length": {fmt.Sprint(len(body))},
+		"Content-Md5":    {base64.StdEncoding.EncodeToString(digest[:])},
+	}, []byte("encryption"))
+	if err != nil {
+		return fmt.Errorf("failed to set default encryption of %s: %w", bucket, err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to set default encryption of %s: HTTP %d", bucket, resp.StatusCode)
+	}
+	return nil
+}
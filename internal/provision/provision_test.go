@@ -0,0 +1,104 @@
+package provision
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/s3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 keeps the buckets created on it and counts requests by method
+type fakeS3 struct {
+	mu         sync.Mutex
+	buckets    map[string]bool
+	requests   map[string]int
+	encryption map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests[r.Method]++
+	switch {
+	case r.Method == http.MethodHead && !f.buckets[r.URL.Path]:
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodPut && r.URL.Query().Has("encryption"):
+		body, _ := io.ReadAll(r.Body)
+		f.encryption[r.URL.Path] = string(body)
+	case r.Method == http.MethodPut && f.buckets[r.URL.Path]:
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, `<Error><Code>BucketAlreadyOwnedByYou</Code></Error>`)
+	case r.Method == http.MethodPut:
+		f.buckets[r.URL.Path] = true
+	}
+}
+
+func newProvisioner(t *testing.T, patterns ...string) (*fakeS3, *Provisioner) {
+	backend := &fakeS3{buckets: make(map[string]bool), requests: make(map[string]int), encryption: make(map[string]string)}
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+	return backend, NewProvisioner(s3.NewClient(server.URL, "", s3.DefaultTransportConfig()), patterns)
+}
+
+func TestMatches(t *testing.T) {
+	_, provisioner := newProvisioner(t, "ci-*", "scratch")
+	assert.True(t, provisioner.Matches("ci-main"))
+	assert.True(t, provisioner.Matches("scratch"))
+	assert.False(t, provisioner.Matches("scratch-2"))
+	assert.False(t, provisioner.Matches("backups"))
+	assert.False(t, (*Provisioner)(nil).Matches("ci-main"), "a nil provisioner creates nothing")
+
+	assert.NoError(t, ValidatePatterns([]string{"ci-*", "build-[0-9]*"}))
+	assert.Error(t, ValidatePatterns([]string{"ci-["}))
+}
+
+func TestEnsure(t *testing.T) {
+	backend, provisioner := newProvisioner(t, "ci-*")
+	now := time.Now()
+	provisioner.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	created, err := provisioner.Ensure(ctx, "ci-main")
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.True(t, backend.buckets["/ci-main"])
+
+	created, err = provisioner.Ensure(ctx, "ci-main")
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, 1, backend.requests[http.MethodHead], "known buckets are not checked again")
+
+	now = now.Add(knownTTL)
+	_, err = provisioner.Ensure(ctx, "ci-main")
+	require.NoError(t, err)
+	assert.Equal(t, 2, backend.requests[http.MethodHead], "buckets are checked again after the TTL")
+
+	// Another replica created the bucket between the check and the create
+	_, replica := newProvisioner(t, "ci-*")
+	replica.client = provisioner.client
+	backend.buckets["/ci-race"] = true
+	created, err = replica.create(ctx, "ci-race")
+	require.NoError(t, err)
+	assert.False(t, created)
+
+	provisioner.Forget("ci-main")
+	delete(backend.buckets, "/ci-main")
+	created, err = provisioner.Ensure(ctx, "ci-main")
+	require.NoError(t, err)
+	assert.True(t, created, "forgotten buckets are created again")
+}
+
+func TestSetDefaultKey(t *testing.T) {
+	backend, provisioner := newProvisioner(t, "ci-*")
+	const kmsKey = "arn:aws:kms:us-east-1:123456789012:key/ci"
+	require.NoError(t, provisioner.SetDefaultKey(context.Background(), "ci-main", kmsKey))
+	assert.Contains(t, backend.encryption["/ci-main"], "<SSEAlgorithm>aws:kms</SSEAlgorithm><KMSMasterKeyID>"+kmsKey+"</KMSMasterKeyID>")
+}
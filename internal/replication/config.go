@@ -0,0 +1,139 @@
+package replication
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"s3-vault-proxy/internal/vault"
+)
+
+// Rule statuses
+const (
+	StatusEnabled  = "Enabled"
+	StatusDisabled = "Disabled"
+)
+
+// maxConfigurationSize bounds a PutBucketReplication body
+const maxConfigurationSize = 1 << 20
+
+// ErrInvalidConfiguration is returned for replication configurations that cannot be applied
+var ErrInvalidConfiguration = errors.New("invalid replication configuration")
+
+// Configuration is the S3 ReplicationConfiguration document of a bucket
+type Configuration struct {
+	XMLName xml.Name `xml:"ReplicationConfiguration"`
+	Role    string   `xml:"Role,omitempty"`
+	Rules   []Rule   `xml:"Rule"`
+}
+
+// Rule replicates the objects under a prefix to one destination bucket
+type Rule struct {
+	ID       string `xml:"ID,omitempty"`
+	Priority int    `xml:"Priority,omitempty"`
+	Status   string `xml:"Status"`
+	// Prefix is the legacy filter; Filter.Prefix is used when both are absent or empty
+	Prefix      string      `xml:"Prefix,omitempty"`
+	Filter      *Filter     `xml:"Filter,omitempty"`
+	Destination Destination `xml:"Destination"`
+}
+
+// Filter selects the objects a rule applies to
+type Filter struct {
+	Prefix string `xml:"Prefix,omitempty"`
+}
+
+// Destination names the bucket replicas are written to
+type Destination struct {
+	// Bucket is a bucket ARN (arn:aws:s3:::name) or a plain bucket name
+	Bucket                  string                   `xml:"Bucket"`
+	StorageClass            string                   `xml:"StorageClass,omitempty"`
+	EncryptionConfiguration *EncryptionConfiguration `xml:"EncryptionConfiguration,omitempty"`
+}
+
+// EncryptionConfiguration re-encrypts replicas under a destination KMS key
+type EncryptionConfiguration struct {
+	ReplicaKmsKeyID string `xml:"ReplicaKmsKeyID"`
+}
+
+// ParseConfiguration decodes and validates a PutBucketReplication body
+func ParseConfiguration(r io.Reader) (*Configuration, error) {
+	var config Configuration
+	if err := xml.NewDecoder(io.LimitReader(r, maxConfigurationSize)).Decode(&config); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfiguration, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate checks that every rule can be applied
+func (c *Configuration) Validate() error {
+	if len(c.Rules) == 0 {
+		return fmt.Errorf("%w: at least one rule is required", ErrInvalidConfiguration)
+	}
+	ids := make(map[string]bool)
+	for i, rule := range c.Rules {
+		name := rule.ID
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		} else if ids[rule.ID] {
+			return fmt.Errorf("%w: duplicate rule ID %q", ErrInvalidConfiguration, rule.ID)
+		}
+		ids[rule.ID] = true
+
+		if rule.Status != StatusEnabled && rule.Status != StatusDisabled {
+			return fmt.Errorf("%w: rule %s: status must be %s or %s", ErrInvalidConfiguration, name, StatusEnabled, StatusDisabled)
+		}
+		if rule.DestinationBucket() == "" {
+			return fmt.Errorf("%w: rule %s: destination bucket is required", ErrInvalidConfiguration, name)
+		}
+		if key := rule.ReplicaKMSKeyARN(); key != "" {
+			if _, err := new(vault.Client).ARNToVaultKey(key); err != nil {
+				return fmt.Errorf("%w: rule %s: %v", ErrInvalidConfiguration, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Match returns the enabled rule with the highest priority whose prefix
+// covers key, or nil when the object is not replicated
+func (c *Configuration) Match(key string) *Rule {
+	var match *Rule
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.Status != StatusEnabled || !strings.HasPrefix(key, rule.KeyPrefix()) {
+			continue
+		}
+		if match == nil || rule.Priority > match.Priority {
+			match = rule
+		}
+	}
+	return match
+}
+
+// KeyPrefix returns the prefix of the objects the rule applies to
+func (r *Rule) KeyPrefix() string {
+	if r.Filter != nil && r.Filter.Prefix != "" {
+		return r.Filter.Prefix
+	}
+	return r.Prefix
+}
+
+// DestinationBucket returns the bucket name of the rule's destination
+func (r *Rule) DestinationBucket() string {
+	return strings.TrimPrefix(r.Destination.Bucket, "arn:aws:s3:::")
+}
+
+// ReplicaKMSKeyARN returns the KMS key replicas are re-encrypted with, or ""
+// to keep the source object's key
+func (r *Rule) ReplicaKMSKeyARN() string {
+	if r.Destination.EncryptionConfiguration == nil {
+		return ""
+	}
+	return r.Destination.EncryptionConfiguration.ReplicaKmsKeyID
+}
@@ -0,0 +1,77 @@
+package replication
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const replicaARN = "arn:aws:kms:eu-west-1:123456789012:key/87654321-4321-4321-4321-210987654321"
+
+func TestParseConfiguration(t *testing.T) {
+	config, err := ParseConfiguration(strings.NewReader(`
+<ReplicationConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Role>arn:aws:iam::123456789012:role/replication</Role>
+  <Rule>
+    <ID>logs</ID>
+    <Priority>2</Priority>
+    <Status>Enabled</Status>
+    <Filter><Prefix>logs/</Prefix></Filter>
+    <Destination>
+      <Bucket>arn:aws:s3:::logs-replica</Bucket>
+      <EncryptionConfiguration><ReplicaKmsKeyID>` + replicaARN + `</ReplicaKmsKeyID></EncryptionConfiguration>
+    </Destination>
+  </Rule>
+  <Rule>
+    <ID>all</ID>
+    <Priority>1</Priority>
+    <Status>Enabled</Status>
+    <Prefix></Prefix>
+    <Destination><Bucket>replica</Bucket></Destination>
+  </Rule>
+  <Rule>
+    <ID>paused</ID>
+    <Priority>3</Priority>
+    <Status>Disabled</Status>
+    <Destination><Bucket>paused</Bucket></Destination>
+  </Rule>
+</ReplicationConfiguration>`))
+	require.NoError(t, err)
+	require.Len(t, config.Rules, 3)
+
+	rule := config.Match("logs/today")
+	require.NotNil(t, rule)
+	assert.Equal(t, "logs", rule.ID, "the highest priority enabled rule wins")
+	assert.Equal(t, "logs-replica", rule.DestinationBucket())
+	assert.Equal(t, replicaARN, rule.ReplicaKMSKeyARN())
+
+	rule = config.Match("images/cat.png")
+	require.NotNil(t, rule)
+	assert.Equal(t, "all", rule.ID)
+	assert.Equal(t, "replica", rule.DestinationBucket())
+	assert.Empty(t, rule.ReplicaKMSKeyARN())
+}
+
+func TestParseConfigurationRejectsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"not xml":        `{"rules": []}`,
+		"no rules":       `<ReplicationConfiguration></ReplicationConfiguration>`,
+		"bad status":     `<ReplicationConfiguration><Rule><Status>On</Status><Destination><Bucket>b</Bucket></Destination></Rule></ReplicationConfiguration>`,
+		"no destination": `<ReplicationConfiguration><Rule><Status>Enabled</Status></Rule></ReplicationConfiguration>`,
+		"duplicate id": `<ReplicationConfiguration>
+			<Rule><ID>a</ID><Status>Enabled</Status><Destination><Bucket>b</Bucket></Destination></Rule>
+			<Rule><ID>a</ID><Status>Enabled</Status><Destination><Bucket>c</Bucket></Destination></Rule>
+		</ReplicationConfiguration>`,
+		"bad replica key": `<ReplicationConfiguration><Rule><Status>Enabled</Status><Destination><Bucket>b</Bucket>
+			<EncryptionConfiguration><ReplicaKmsKeyID>not-an-arn</ReplicaKmsKeyID></EncryptionConfiguration>
+		</Destination></Rule></ReplicationConfiguration>`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseConfiguration(strings.NewReader(body))
+			assert.ErrorIs(t, err, ErrInvalidConfiguration)
+		})
+	}
+}
@@ -0,0 +1,341 @@
+// Package replication implements bucket replication configurations
+// (PutBucketReplication) and the asynchronous worker that copies new objects
+// to a remote S3 endpoint, optionally re-encrypting them under a key of the
+// destination region. Replication status is kept in the object metadata.
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/pkg/types"
+)
+
+// Replication statuses, as reported in x-amz-replication-status
+const (
+	Pending   = "PENDING"
+	Completed = "COMPLETED"
+	Failed    = "FAILED"
+	Replica   = "REPLICA"
+)
+
+var (
+	replicationsTotal = metrics.NewCounter(
+		"s3_vault_proxy_replications_total",
+		"Objects replicated per final status.",
+		"status",
+	)
+	replicationQueueDepth = metrics.NewGauge(
+		"s3_vault_proxy_replication_queue_depth",
+		"Objects waiting to be replicated.",
+	)
+)
+
+// Options configures a Replicator
+type Options struct {
+	// ConfigBucket holds the replication configurations of every bucket
+	ConfigBucket string
+	Workers      int
+	QueueSize    int
+	// Attempts per object before it is marked FAILED, retried after
+	// RetryDelay and then twice as long each time
+	Attempts   int
+	RetryDelay time.Duration
+}
+
+// task is an object waiting to be replicated
+type task struct {
+	bucket    string
+	key       string
+	kmsKeyARN string // key the object was uploaded with
+}
+
+// Replicator copies objects of buckets with a replication configuration to
+// the destination endpoint in the background
+type Replicator struct {
+	source         s3.Interface
+	dest           s3.Interface
+	sourceMetadata *metadata.Service
+	destMetadata   *metadata.Service
+	configs        *store
+	opts           Options
+
+	queue chan task
+	wg    sync.WaitGroup
+
+	mu      sync.Mutex
+	closed  bool
+	pending map[string]int
+}
+
+// New returns a replicator reading from source, the backend signed with
+// operator credentials, and writing to dest. Start runs its workers.
+func New(source, dest s3.Interface, opts Options) *Replicator {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.Attempts <= 0 {
+		opts.Attempts = 3
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = time.Second
+	}
+	return &Replicator{
+		source:         source,
+		dest:           dest,
+		sourceMetadata: metadata.NewService(source),
+		destMetadata:   metadata.NewService(dest),
+		configs:        newStore(source, opts.ConfigBucket),
+		opts:           opts,
+		queue:          make(chan task, opts.QueueSize),
+		pending:        make(map[string]int),
+	}
+}
+
+// Start runs the replication workers until Close
+func (r *Replicator) Start() {
+	for i := 0; i < r.opts.Workers; i++ {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			for t := range r.queue {
+				replicationQueueDepth.Add(-1)
+				r.replicate(t)
+			}
+		}()
+	}
+}
+
+// Close stops accepting objects and waits for the queued ones to be replicated
+func (r *Replicator) Close() {
+	r.mu.Lock()
+	r.closed = true
+	close(r.queue)
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+// Configuration returns the replication configuration of bucket, or ErrNoConfiguration
+func (r *Replicator) Configuration(bucket string) (*Configuration, error) {
+	return r.configs.get(bucket)
+}
+
+// PutConfiguration replaces the replication configuration of bucket
+func (r *Replicator) PutConfiguration(bucket string, config *Configuration) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	return r.configs.put(bucket, config)
+}
+
+// DeleteConfiguration stops replicating bucket. Queued objects are dropped.
+func (r *Replicator) DeleteConfiguration(bucket string) error {
+	return r.configs.delete(bucket)
+}
+
+// Enqueue schedules an uploaded object for replication when a rule of its
+// bucket's configuration covers it
+func (r *Replicator) Enqueue(bucket, key, kmsKeyARN string) {
+	config, err := r.configs.get(bucket)
+	if err != nil {
+		if !errors.Is(err, ErrNoConfiguration) {
+			logging.Error().Err(err).Str("bucket", bucket).Str("key", key).Msg("Cannot tell whether the object is replicated")
+		}
+		return
+	}
+	if config.Match(key) == nil {
+		return
+	}
+
+	r.mu.Lock()
+	queued := false
+	if !r.closed {
+		select {
+		case r.queue <- task{bucket: bucket, key: key, kmsKeyARN: kmsKeyARN}:
+			queued = true
+			r.pending[bucket+"/"+key]++
+			replicationQueueDepth.Add(1)
+		default:
+		}
+	}
+	r.mu.Unlock()
+	if !queued {
+		logging.Error().Str("bucket", bucket).Str("key", key).Msg("Replication queue is full or closed")
+		r.finish(bucket, key, Failed)
+	}
+}
+
+// Status returns the replication status of an object, or "" when its bucket
+// is not replicated or the object has none
+func (r *Replicator) Status(ctx context.Context, bucket, key string) string {
+	if _, err := r.configs.get(bucket); err != nil {
+		return ""
+	}
+	r.mu.Lock()
+	pending := r.pending[bucket+"/"+key] > 0
+	r.mu.Unlock()
+	if pending {
+		return Pending
+	}
+	meta, err := r.sourceMetadata.Get(ctx, bucket, key, http.Header{})
+	if err != nil {
+		return ""
+	}
+	return meta.ReplicationStatus
+}
+
+// done drops one queued replication of an object from the pending set
+func (r *Replicator) done(bucket, key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := bucket + "/" + key
+	if r.pending[name]--; r.pending[name] <= 0 {
+		delete(r.pending, name)
+	}
+}
+
+// replicate copies one object, retrying failures, and records the outcome
+func (r *Replicator) replicate(t task) {
+	defer r.done(t.bucket, t.key)
+	// Replication outlives the request that enqueued it, so it is never cancelled
+	ctx := context.Background()
+
+	delay := r.opts.RetryDelay
+	for attempt := 1; ; attempt++ {
+		config, err := r.configs.get(t.bucket)
+		if errors.Is(err, ErrNoConfiguration) {
+			return
+		}
+		var rule *Rule
+		if err == nil {
+			if rule = config.Match(t.key); rule == nil {
+				return
+			}
+			err = r.copyObject(ctx, t, rule)
+		}
+		if err == nil || errors.Is(err, errObjectGone) {
+			r.finish(t.bucket, t.key, Completed)
+			return
+		}
+
+		logging.Warn().Err(err).Str("bucket", t.bucket).Str("key", t.key).Int("attempt", attempt).Msg("Replication attempt failed")
+		if attempt == r.opts.Attempts {
+			r.finish(t.bucket, t.key, Failed)
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// errObjectGone is returned when an object was deleted before it was replicated
+var errObjectGone = errors.New("object no longer exists")
+
+// copyObject writes the current version of an object and its metadata to the
+// rule's destination bucket
+func (r *Replicator) copyObject(ctx context.Context, t task, rule *Rule) error {
+	resp, err := r.source.ForwardRequest(ctx, "GET", s3.ObjectPath(t.bucket, t.key), nil, http.Header{}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errObjectGone
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("source GET returned HTTP %d", resp.StatusCode)
+	}
+	if resp.ContentLength < 0 {
+		return fmt.Errorf("source did not report the object size")
+	}
+
+	kmsKeyARN := rule.ReplicaKMSKeyARN()
+	if kmsKeyARN == "" {
+		kmsKeyARN = resp.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id")
+	}
+	if kmsKeyARN == "" {
+		kmsKeyARN = t.kmsKeyARN
+	}
+
+	headers := http.Header{"Content-Length": {strconv.FormatInt(resp.ContentLength, 10)}}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+	if storageClass := rule.Destination.StorageClass; storageClass != "" {
+		headers.Set("X-Amz-Storage-Class", storageClass)
+	}
+	// Metadata objects carry the same SSE headers, which a destination proxy requires
+	sseHeaders := http.Header{}
+	if kmsKeyARN != "" {
+		sseHeaders.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+		sseHeaders.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", kmsKeyARN)
+		for name, values := range sseHeaders {
+			headers[name] = values
+		}
+	}
+
+	destBucket := rule.DestinationBucket()
+	body := s3.NewETagReader(ctx, r.source, t.bucket, t.key, resp)
+	put, err := r.dest.ForwardRequest(ctx, "PUT", s3.ObjectPath(destBucket, t.key), body, headers, nil)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, put.Body)
+	put.Body.Close()
+	if put.StatusCode >= 300 {
+		return fmt.Errorf("destination PUT returned HTTP %d", put.StatusCode)
+	}
+
+	replica := &types.ObjectMetadata{
+		ContentLength:     resp.ContentLength,
+		ContentType:       resp.Header.Get("Content-Type"),
+		ETag:              body.ETag(),
+		LastModified:      types.NormalizeLastModified(resp.Header.Get("Last-Modified")),
+		KMSKeyARN:         kmsKeyARN,
+		ReplicationStatus: Replica,
+	}
+	return r.destMetadata.Store(ctx, destBucket, t.key, replica, sseHeaders)
+}
+
+// finish records the final replication status in the source object's metadata
+func (r *Replicator) finish(bucket, key, status string) {
+	replicationsTotal.Inc(status)
+	ctx := context.Background()
+
+	meta, err := r.sourceMetadata.Get(ctx, bucket, key, http.Header{})
+	if errors.Is(err, metadata.ErrNotFound) {
+		head, headErr := r.source.HeadObject(ctx, bucket, key, http.Header{})
+		if headErr != nil {
+			err = headErr
+		} else {
+			head.Body.Close()
+			if head.StatusCode == http.StatusNotFound {
+				return
+			}
+			meta, err = &types.ObjectMetadata{
+				ContentLength: head.ContentLength,
+				ContentType:   head.Header.Get("Content-Type"),
+				ETag:          head.Header.Get("ETag"),
+				LastModified:  types.NormalizeLastModified(head.Header.Get("Last-Modified")),
+				KMSKeyARN:     head.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"),
+			}, nil
+		}
+	}
+	if err == nil {
+		meta.ReplicationStatus = status
+		err = r.sourceMetadata.Store(ctx, bucket, key, meta, http.Header{})
+	}
+	if err != nil {
+		logging.Error().Err(err).Str("bucket", bucket).Str("key", key).Str("status", status).Msg("Failed to record replication status")
+	}
+}
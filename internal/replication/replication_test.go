@@ -0,0 +1,197 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sourceARN = "arn:aws:kms:us-east-1:123456789012:key/12345678-1234-1234-1234-123456789012"
+
+// fakeS3 stores objects by path with the headers a backend would return
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+	failPut bool
+}
+
+type fakeObject struct {
+	body   []byte
+	header http.Header
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, s3.Interface) {
+	backend := &fakeS3{objects: make(map[string]fakeObject)}
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+	return backend, s3.NewClient(server.URL, "", s3.DefaultTransportConfig())
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	object, exists := f.objects[r.URL.Path]
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for name, values := range object.header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(object.body)))
+		if r.Method == http.MethodGet {
+			w.Write(object.body)
+		}
+	case http.MethodPut:
+		if f.failPut {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		header := http.Header{
+			"Etag":          {fmt.Sprintf(`"%x"`, len(body))},
+			"Last-Modified": {"Mon, 01 Jan 2024 00:00:00 GMT"},
+		}
+		if contentType := r.Header.Get("Content-Type"); contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+		if kmsKey := r.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); kmsKey != "" {
+			header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", kmsKey)
+		}
+		f.objects[r.URL.Path] = fakeObject{body: body, header: header}
+		w.Header().Set("ETag", header.Get("Etag"))
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeS3) put(path, body, kmsKeyARN string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[path] = fakeObject{body: []byte(body), header: http.Header{
+		"Content-Type":  {"text/plain"},
+		"Last-Modified": {"Mon, 01 Jan 2024 00:00:00 GMT"},
+		"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": {kmsKeyARN},
+	}}
+}
+
+func (f *fakeS3) get(path string) (fakeObject, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	object, ok := f.objects[path]
+	return object, ok
+}
+
+func (f *fakeS3) metadata(t *testing.T, path string) *types.ObjectMetadata {
+	object, ok := f.get(path + ".metadata")
+	require.True(t, ok, "no metadata for %s", path)
+	var meta types.ObjectMetadata
+	require.NoError(t, json.Unmarshal(object.body, &meta))
+	return &meta
+}
+
+func newTestReplicator(t *testing.T) (*Replicator, *fakeS3, *fakeS3) {
+	sourceBackend, source := newFakeS3(t)
+	destBackend, dest := newFakeS3(t)
+	replicator := New(source, dest, Options{ConfigBucket: "config", Workers: 2, QueueSize: 10, RetryDelay: time.Millisecond})
+
+	require.NoError(t, replicator.PutConfiguration("photos", &Configuration{Rules: []Rule{{
+		Status: StatusEnabled,
+		Filter: &Filter{Prefix: "albums/"},
+		Destination: Destination{
+			Bucket:                  "arn:aws:s3:::photos-replica",
+			EncryptionConfiguration: &EncryptionConfiguration{ReplicaKmsKeyID: replicaARN},
+		},
+	}}}))
+	return replicator, sourceBackend, destBackend
+}
+
+func TestReplicateObject(t *testing.T) {
+	replicator, source, dest := newTestReplicator(t)
+	source.put("/photos/albums/cat.jpg", "meow", sourceARN)
+	source.put("/photos/drafts/dog.jpg", "woof", sourceARN)
+
+	replicator.Enqueue("photos", "albums/cat.jpg", sourceARN)
+	replicator.Enqueue("photos", "drafts/dog.jpg", sourceARN)
+	assert.Equal(t, Pending, replicator.Status(context.Background(), "photos", "albums/cat.jpg"))
+
+	replicator.Start()
+	replicator.Close()
+
+	replica, ok := dest.get("/photos-replica/albums/cat.jpg")
+	require.True(t, ok)
+	assert.Equal(t, "meow", string(replica.body))
+	assert.Equal(t, replicaARN, replica.header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"), "replicas are re-encrypted with the destination key")
+
+	replicaMeta := dest.metadata(t, "/photos-replica/albums/cat.jpg")
+	assert.Equal(t, Replica, replicaMeta.ReplicationStatus)
+	assert.Equal(t, replicaARN, replicaMeta.KMSKeyARN)
+	assert.Equal(t, int64(4), replicaMeta.ContentLength)
+	assert.Equal(t, "2024-01-01T00:00:00Z", replicaMeta.LastModified, "stored as RFC 3339")
+
+	assert.Equal(t, Completed, source.metadata(t, "/photos/albums/cat.jpg").ReplicationStatus)
+	assert.Equal(t, Completed, replicator.Status(context.Background(), "photos", "albums/cat.jpg"))
+
+	_, ok = dest.get("/photos-replica/drafts/dog.jpg")
+	assert.False(t, ok, "objects outside the rule's prefix are not replicated")
+	assert.Empty(t, replicator.Status(context.Background(), "photos", "drafts/dog.jpg"))
+	assert.Empty(t, replicator.Status(context.Background(), "other", "albums/cat.jpg"), "buckets without a configuration have no status")
+}
+
+func TestReplicateObjectFailure(t *testing.T) {
+	replicator, source, dest := newTestReplicator(t)
+	dest.mu.Lock()
+	dest.failPut = true
+	dest.mu.Unlock()
+	source.put("/photos/albums/cat.jpg", "meow", sourceARN)
+
+	replicator.Start()
+	replicator.Enqueue("photos", "albums/cat.jpg", sourceARN)
+	replicator.Close()
+
+	assert.Equal(t, Failed, replicator.Status(context.Background(), "photos", "albums/cat.jpg"))
+}
+
+func TestDeleteConfiguration(t *testing.T) {
+	replicator, source, dest := newTestReplicator(t)
+	source.put("/photos/albums/cat.jpg", "meow", sourceARN)
+
+	config, err := replicator.Configuration("photos")
+	require.NoError(t, err)
+	assert.Equal(t, "photos-replica", config.Rules[0].DestinationBucket())
+
+	require.NoError(t, replicator.DeleteConfiguration("photos"))
+	_, err = replicator.Configuration("photos")
+	assert.ErrorIs(t, err, ErrNoConfiguration)
+
+	replicator.Start()
+	replicator.Enqueue("photos", "albums/cat.jpg", sourceARN)
+	replicator.Close()
+	_, ok := dest.get("/photos-replica/albums/cat.jpg")
+	assert.False(t, ok)
+}
+
+func TestConfigurationIsSharedThroughTheBackend(t *testing.T) {
+	replicator, _, _ := newTestReplicator(t)
+	// A second replica reading the same backend sees the stored configuration
+	other := New(replicator.source, replicator.dest, Options{ConfigBucket: "config"})
+	config, err := other.Configuration("photos")
+	require.NoError(t, err)
+	assert.Equal(t, "photos-replica", config.Match("albums/x").DestinationBucket())
+}
@@ -0,0 +1,129 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/s3"
+)
+
+// ErrNoConfiguration is returned for buckets without a replication configuration
+var ErrNoConfiguration = errors.New("bucket has no replication configuration")
+
+// ConfigPrefix is where bucket configurations are kept in the configuration bucket
+const ConfigPrefix = ".s3-vault-proxy/replication/"
+
+// configTTL bounds how long a loaded configuration is reused, so changes made
+// through another proxy replica take effect
+const configTTL = 30 * time.Second
+
+// store keeps bucket configurations as objects in one backend bucket
+type store struct {
+	client s3.Interface
+	bucket string
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]storeEntry
+}
+
+type storeEntry struct {
+	config *Configuration // nil when the bucket has none
+	loaded time.Time
+}
+
+func newStore(client s3.Interface, bucket string) *store {
+	return &store{
+		client:  client,
+		bucket:  bucket,
+		now:     time.Now,
+		entries: make(map[string]storeEntry),
+	}
+}
+
+// get returns the configuration of bucket, or ErrNoConfiguration
+func (s *store) get(bucket string) (*Configuration, error) {
+	s.mu.Lock()
+	entry, ok := s.entries[bucket]
+	s.mu.Unlock()
+	if !ok || s.now().Sub(entry.loaded) >= configTTL {
+		config, err := s.load(bucket)
+		if err != nil {
+			return nil, err
+		}
+		entry = storeEntry{config: config, loaded: s.now()}
+		s.mu.Lock()
+		s.entries[bucket] = entry
+		s.mu.Unlock()
+	}
+	if entry.config == nil {
+		return nil, ErrNoConfiguration
+	}
+	return entry.config, nil
+}
+
+func (s *store) load(bucket string) (*Configuration, error) {
+	resp, err := s.client.ForwardRequest(context.Background(), "GET", s.path(bucket), nil, http.Header{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load replication configuration: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to load replication configuration: HTTP %d", resp.StatusCode)
+	}
+	return ParseConfiguration(resp.Body)
+}
+
+// put stores the configuration of bucket
+func (s *store) put(bucket string, config *Configuration) error {
+	body, err := xml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.ForwardRequest(context.Background(), "PUT", s.path(bucket), bytes.NewReader(body), http.Header{"Content-Type": {"application/xml"}}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to store replication configuration: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to store replication configuration: HTTP %d", resp.StatusCode)
+	}
+	s.cache(bucket, config)
+	return nil
+}
+
+// delete removes the configuration of bucket
+func (s *store) delete(bucket string) error {
+	resp, err := s.client.ForwardRequest(context.Background(), "DELETE", s.path(bucket), nil, http.Header{}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete replication configuration: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete replication configuration: HTTP %d", resp.StatusCode)
+	}
+	s.cache(bucket, nil)
+	return nil
+}
+
+func (s *store) cache(bucket string, config *Configuration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[bucket] = storeEntry{config: config, loaded: s.now()}
+}
+
+func (s *store) path(bucket string) string {
+	return s3.ObjectPath(s.bucket, ConfigPrefix+bucket+".xml")
+}
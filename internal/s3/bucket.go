@@ -0,0 +1,58 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ErrInvalidBucketName is returned by ValidateBucketName
+var ErrInvalidBucketName = errors.New("invalid bucket name")
+
+// reservedBucketPrefixes and reservedBucketSuffixes are reserved by S3 for
+// its own naming schemes
+var (
+	reservedBucketPrefixes = []string{"xn--", "sthree-", "amzn-s3-demo-"}
+	reservedBucketSuffixes = []string{"-s3alias", "--ol-s3", ".mrap", "--x-s3", "--table-s3"}
+)
+
+// ValidateBucketName checks a name against the S3 rules for general purpose
+// buckets: 3 to 63 lowercase letters, digits, hyphens and dots, starting and
+// ending with a letter or digit, without adjacent dots, not formatted as an IP
+// address and without a reserved prefix or suffix.
+func ValidateBucketName(name string) error {
+	if len(name) < 3 || len(name) > 63 {
+		return fmt.Errorf("%w: must be between 3 and 63 characters long", ErrInvalidBucketName)
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '-' && c != '.' {
+			return fmt.Errorf("%w: may only contain lowercase letters, digits, hyphens and dots", ErrInvalidBucketName)
+		}
+	}
+	if !isAlphanumeric(name[0]) || !isAlphanumeric(name[len(name)-1]) {
+		return fmt.Errorf("%w: must begin and end with a letter or digit", ErrInvalidBucketName)
+	}
+	if strings.Contains(name, "..") {
+		return fmt.Errorf("%w: must not contain adjacent dots", ErrInvalidBucketName)
+	}
+	if net.ParseIP(name) != nil {
+		return fmt.Errorf("%w: must not be formatted as an IP address", ErrInvalidBucketName)
+	}
+	for _, prefix := range reservedBucketPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return fmt.Errorf("%w: prefix %q is reserved", ErrInvalidBucketName, prefix)
+		}
+	}
+	for _, suffix := range reservedBucketSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return fmt.Errorf("%w: suffix %q is reserved", ErrInvalidBucketName, suffix)
+		}
+	}
+	return nil
+}
+
+func isAlphanumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
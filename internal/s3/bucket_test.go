@@ -0,0 +1,33 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBucketName(t *testing.T) {
+	for _, name := range []string{"abc", "my-bucket", "logs.example.com", "bucket-2024", "0-start", "a23456789012345678901234567890123456789012345678901234567890123"} {
+		assert.NoError(t, ValidateBucketName(name), name)
+	}
+
+	for _, name := range []string{
+		"ab",
+		"a234567890123456789012345678901234567890123456789012345678901234",
+		"MyBucket",
+		"my_bucket",
+		"my/bucket",
+		"my bucket",
+		"-bucket",
+		"bucket-",
+		"bucket.",
+		"my..bucket",
+		"192.168.1.10",
+		"xn--bucket",
+		"sthree-bucket",
+		"bucket-s3alias",
+		"bucket--ol-s3",
+	} {
+		assert.ErrorIs(t, ValidateBucketName(name), ErrInvalidBucketName, name)
+	}
+}
@@ -0,0 +1,165 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"s3-vault-proxy/internal/capture"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/sigv4"
+)
+
+// CapturingClient records selected backend exchanges, with the reconstructed
+// canonical request, so signature mismatches can be diagnosed from the admin API
+type CapturingClient struct {
+	inner    Interface
+	recorder *capture.Recorder
+}
+
+// NewCapturingClient wraps an S3 client with request capture
+func NewCapturingClient(inner Interface, recorder *capture.Recorder) *CapturingClient {
+	return &CapturingClient{
+		inner:    inner,
+		recorder: recorder,
+	}
+}
+
+// ForwardRequest forwards a request, capturing it when the recorder selects it
+func (c *CapturingClient) ForwardRequest(ctx context.Context, method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
+	record := signatureContext(method, path, headers, string(queryString))
+	if !c.recorder.ShouldCapture(record.AccessKey, headers) {
+		return c.inner.ForwardRequest(ctx, method, path, body, headers, queryString)
+	}
+	record.Time = time.Now().UTC()
+	record.RequestHeaders = headers
+
+	resp, err := c.inner.ForwardRequest(ctx, method, path, body, headers, queryString)
+	if err != nil {
+		record.BackendError = err.Error()
+		c.recorder.Add(record)
+		return nil, err
+	}
+
+	record.BackendStatus = resp.StatusCode
+	record.BackendHeaders = resp.Header.Clone()
+	if resp.StatusCode >= 400 {
+		// Error bodies are small and carry the backend's view of the signature
+		data, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		if readErr == nil {
+			record.BackendBody = string(data)
+		}
+	}
+	c.recorder.Add(record)
+
+	return resp, nil
+}
+
+// HeadObject performs a HEAD request for an object through the capturing path
+func (c *CapturingClient) HeadObject(ctx context.Context, bucket, key string, headers http.Header) (*http.Response, error) {
+	return c.ForwardRequest(ctx, "HEAD", ObjectPath(bucket, key), nil, headers, nil)
+}
+
+// signatureContext reconstructs what the client signed, from either the
+// Authorization header or presigned URL query parameters
+func signatureContext(method, path string, headers http.Header, rawQuery string) capture.Capture {
+	record := capture.Capture{Method: method, Path: path, Query: rawQuery}
+
+	if authHeader := sigv4.HeaderValue(headers, "Authorization"); authHeader != "" {
+		auth, err := sigv4.ParseAuthorization(authHeader)
+		if err != nil {
+			record.ParseError = err.Error()
+			return record
+		}
+		record.AccessKey = auth.AccessKey
+		record.CanonicalRequest = sigv4.CanonicalRequest(method, path, rawQuery, headers, auth.SignedHeaders,
+			sigv4.HeaderValue(headers, "X-Amz-Content-Sha256"))
+		record.StringToSign = sigv4.StringToSign(sigv4.HeaderValue(headers, "X-Amz-Date"), auth.Scope(), record.CanonicalRequest)
+		return record
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil || query.Get("X-Amz-Credential") == "" {
+		return record
+	}
+	credential := query.Get("X-Amz-Credential")
+	accessKey, scope, _ := strings.Cut(credential, "/")
+	record.AccessKey = accessKey
+	record.CanonicalRequest = sigv4.CanonicalRequest(method, path, rawQuery, headers,
+		strings.Split(query.Get("X-Amz-SignedHeaders"), ";"), sigv4.UnsignedPayload)
+	record.StringToSign = sigv4.StringToSign(query.Get("X-Amz-Date"), scope, record.CanonicalRequest)
+	return record
+}
+
+// signatureErrorBody is the part of a SignatureDoesNotMatch error body that
+// shows what the backend signed. AWS and MinIO both include it.
+type signatureErrorBody struct {
+	Code             string `xml:"Code"`
+	StringToSign     string `xml:"StringToSign"`
+	CanonicalRequest string `xml:"CanonicalRequest"`
+}
+
+// SignatureDiagnosticsClient records every request the backend rejects with
+// SignatureDoesNotMatch, with the canonical request and string to sign the
+// proxy reconstructed next to those the backend reports
+type SignatureDiagnosticsClient struct {
+	inner    Interface
+	recorder *capture.Recorder
+}
+
+// NewSignatureDiagnosticsClient wraps an S3 client with signature failure recording
+func NewSignatureDiagnosticsClient(inner Interface, recorder *capture.Recorder) *SignatureDiagnosticsClient {
+	return &SignatureDiagnosticsClient{
+		inner:    inner,
+		recorder: recorder,
+	}
+}
+
+// ForwardRequest forwards a request, recording it when its signature is rejected
+func (c *SignatureDiagnosticsClient) ForwardRequest(ctx context.Context, method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
+	resp, err := c.inner.ForwardRequest(ctx, method, path, body, headers, queryString)
+	if err != nil || resp.StatusCode != http.StatusForbidden {
+		return resp, err
+	}
+
+	data, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	var errorBody signatureErrorBody
+	if readErr != nil || xml.Unmarshal(data, &errorBody) != nil || errorBody.Code != "SignatureDoesNotMatch" {
+		return resp, nil
+	}
+
+	record := signatureContext(method, path, headers, string(queryString))
+	record.Time = time.Now().UTC()
+	record.RequestHeaders = headers
+	record.BackendStatus = resp.StatusCode
+	record.BackendHeaders = resp.Header.Clone()
+	record.BackendBody = string(data)
+	record.BackendCanonicalRequest = errorBody.CanonicalRequest
+	record.BackendStringToSign = errorBody.StringToSign
+	if errorBody.CanonicalRequest != "" {
+		record.Mismatch = capture.FirstDifference(record.CanonicalRequest, errorBody.CanonicalRequest)
+	}
+	c.recorder.Add(record)
+	logging.FromContext(ctx).Info().
+		Str("method", method).
+		Str("path", path).
+		Str("access_key", record.AccessKey).
+		Str("mismatch", record.Mismatch).
+		Msg("Backend rejected the request signature")
+	return resp, nil
+}
+
+// HeadObject performs a HEAD request for an object. HEAD errors have no body,
+// so their signature failures are not recorded.
+func (c *SignatureDiagnosticsClient) HeadObject(ctx context.Context, bucket, key string, headers http.Header) (*http.Response, error) {
+	return c.inner.HeadObject(ctx, bucket, key, headers)
+}
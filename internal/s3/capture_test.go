@@ -0,0 +1,58 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"s3-vault-proxy/internal/capture"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureDiagnosticsClient(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bucket/signed":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<Error><Code>SignatureDoesNotMatch</Code>` +
+				`<StringToSign>AWS4-HMAC-SHA256&#xA;20240101T000000Z</StringToSign>` +
+				`<CanonicalRequest>GET&#xA;/bucket/signed&#xA;&#xA;host:backend:9000&#xA;x-amz-date:20240101T000000Z&#xA;&#xA;host;x-amz-date&#xA;UNSIGNED-PAYLOAD</CanonicalRequest>` +
+				`</Error>`))
+		case "/bucket/denied":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<Error><Code>AccessDenied</Code></Error>`))
+		}
+	}))
+	defer backend.Close()
+
+	recorder := capture.NewRecorder(10, "")
+	client := NewSignatureDiagnosticsClient(NewClient(backend.URL, "", DefaultTransportConfig()), recorder)
+	headers := http.Header{
+		"Authorization":        []string{"AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-date, Signature=abc"},
+		"Host":                 []string{"proxy:9000"},
+		"X-Amz-Date":           []string{"20240101T000000Z"},
+		"X-Amz-Content-Sha256": []string{"UNSIGNED-PAYLOAD"},
+	}
+
+	resp, err := client.ForwardRequest(context.Background(), "GET", "/bucket/signed", nil, headers, nil)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), "SignatureDoesNotMatch", "the client still gets the backend's error")
+
+	_, err = client.ForwardRequest(context.Background(), "GET", "/bucket/denied", nil, headers, nil)
+	require.NoError(t, err)
+
+	failures := recorder.List()
+	require.Len(t, failures, 1, "only signature failures are recorded")
+	failure := failures[0]
+	assert.Equal(t, "AKID", failure.AccessKey)
+	assert.Contains(t, failure.CanonicalRequest, "host:proxy:9000")
+	assert.Contains(t, failure.BackendCanonicalRequest, "host:backend:9000")
+	assert.Equal(t, `line 4: proxy "host:proxy:9000", backend "host:backend:9000"`, failure.Mismatch)
+	assert.NotEmpty(t, failure.StringToSign)
+	assert.NotEmpty(t, failure.BackendStringToSign)
+}
@@ -2,19 +2,46 @@ package s3
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/sigv4"
 )
 
+var (
+	backendRequestsTotal = metrics.NewCounter(
+		"s3_vault_proxy_backend_requests_total",
+		"Requests sent to the S3 backend by method and negotiated protocol.",
+		"method", "protocol",
+	)
+	backendConnectionsTotal = metrics.NewCounter(
+		"s3_vault_proxy_backend_connections_total",
+		"Backend connections obtained for requests, by whether an idle connection was reused.",
+		"reused",
+	)
+)
+
+// withConnectionTrace records whether each backend request reused a pooled connection
+func withConnectionTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			backendConnectionsTotal.Inc(strconv.FormatBool(info.Reused))
+		},
+	})
+}
+
 // minInt returns the minimum of two integers
 func minInt(a, b int) int {
 	if a < b {
@@ -27,23 +54,93 @@ func minInt(a, b int) int {
 type Client struct {
 	endpoint   string
 	httpClient *http.Client
+	stripped   map[string]bool
+	forwarded  map[string]bool
+	hostMode   HostMode
+
+	bodyIdleTimeout time.Duration
 }
 
+// HostMode selects the Host header sent to the backend
+type HostMode string
+
+const (
+	// HostPreserve forwards the client's Host, which SigV4 signatures cover
+	HostPreserve HostMode = "preserve"
+	// HostRewrite sends the backend endpoint's host, for requests the proxy
+	// re-signs or backends that route on their own hostname
+	HostRewrite HostMode = "rewrite"
+)
+
 // Interface defines operations for S3 client
 type Interface interface {
-	ForwardRequest(method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error)
-	HeadObject(bucket, key string, headers http.Header) (*http.Response, error)
+	ForwardRequest(ctx context.Context, method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error)
+	HeadObject(ctx context.Context, bucket, key string, headers http.Header) (*http.Response, error)
+}
+
+// TransportConfig tunes the HTTP transport used to reach the backend
+type TransportConfig struct {
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int // 0 means unlimited
+	IdleConnTimeout       time.Duration
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	RequestTimeout        time.Duration // 0 disables the overall request deadline
+	KeepAlive             time.Duration
+	DisableKeepAlives     bool
+	EnableHTTP2           bool          // negotiated via ALPN, so only effective for https endpoints
+	BodyIdleTimeout       time.Duration // 0 disables; aborts transfers that stop making progress
+}
+
+// DefaultTransportConfig returns transport settings suitable for most deployments.
+// There is no overall request timeout so large transfers are not cut off;
+// ResponseHeaderTimeout still bounds how long an unresponsive backend can stall.
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		DialTimeout:           10 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		KeepAlive:             30 * time.Second,
+	}
 }
 
 // NewClient creates a new S3 client with connection pooling
-func NewClient(endpoint string, caCertPath string) *Client {
+func NewClient(endpoint string, caCertPath string, transportCfg TransportConfig, opts ...ClientOption) *Client {
 	transport := &http.Transport{
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 10,
-		IdleConnTimeout:     90 * time.Second,
-		DisableCompression:  true,
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   transportCfg.DialTimeout,
+			KeepAlive: transportCfg.KeepAlive,
+		}).DialContext,
+		MaxIdleConns:          transportCfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   transportCfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       transportCfg.MaxConnsPerHost,
+		IdleConnTimeout:       transportCfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   transportCfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: transportCfg.ResponseHeaderTimeout,
+		DisableKeepAlives:     transportCfg.DisableKeepAlives,
+		DisableCompression:    true,
+		// A custom TLS config or dialer disables automatic HTTP/2, so opt in explicitly
+		ForceAttemptHTTP2: transportCfg.EnableHTTP2,
 	}
 
+	logging.Debug().
+		Int("max_idle_conns", transportCfg.MaxIdleConns).
+		Int("max_idle_conns_per_host", transportCfg.MaxIdleConnsPerHost).
+		Int("max_conns_per_host", transportCfg.MaxConnsPerHost).
+		Dur("dial_timeout", transportCfg.DialTimeout).
+		Dur("tls_handshake_timeout", transportCfg.TLSHandshakeTimeout).
+		Dur("response_header_timeout", transportCfg.ResponseHeaderTimeout).
+		Dur("request_timeout", transportCfg.RequestTimeout).
+		Bool("http2", transportCfg.EnableHTTP2).
+		Bool("keepalives_disabled", transportCfg.DisableKeepAlives).
+		Msg("S3 client transport configuration")
+
 	// Configure custom CA for internal MinIO if provided
 	logging.Debug().
 		Str("ca_path", caCertPath).
@@ -72,6 +169,9 @@ func NewClient(endpoint string, caCertPath string) *Client {
 				transport.TLSClientConfig = &tls.Config{
 					RootCAs: caCertPool,
 				}
+				if transportCfg.EnableHTTP2 {
+					transport.TLSClientConfig.NextProtos = []string{"h2", "http/1.1"}
+				}
 				logging.Info().
 					Str("endpoint", endpoint).
 					Str("ca_path", caCertPath).
@@ -92,26 +192,48 @@ func NewClient(endpoint string, caCertPath string) *Client {
 		}
 	}
 
-	return &Client{
+	client := &Client{
 		endpoint: endpoint,
 		httpClient: &http.Client{
-			Timeout:   30 * time.Second,
+			Timeout:   transportCfg.RequestTimeout,
 			Transport: transport,
 		},
+		stripped: headerSet(DefaultStrippedHeaders),
+		hostMode: HostPreserve,
+
+		bodyIdleTimeout: transportCfg.BodyIdleTimeout,
 	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
 }
 
 // ForwardRequest forwards an HTTP request to the S3 backend
-func (c *Client) ForwardRequest(method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
+func (c *Client) ForwardRequest(ctx context.Context, method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
 	// Always use the configured endpoint for the actual request
 	fullURL := c.endpoint + path
 	if queryString != nil && len(queryString) > 0 {
 		fullURL += "?" + string(queryString)
 	}
 
-	// Create HTTP request
-	req, err := http.NewRequest(method, fullURL, body)
+	// Stalled transfers are cancelled once neither body moves for the idle timeout,
+	// and every transfer once ctx is done
+	ctx = withConnectionTrace(ctx)
+	cancel := context.CancelFunc(func() {})
+	var idle *watchdog
+	if c.bodyIdleTimeout > 0 {
+		ctx, cancel = context.WithCancel(ctx)
+		idle = newWatchdog(c.bodyIdleTimeout, cancel)
+		if body != nil {
+			body = &progressReader{Reader: body, watchdog: idle}
+		}
+	}
+
+	// Create HTTP request, tracing connection reuse for metrics
+	req, err := http.NewRequestWithContext(ctx, method, fullURL, body)
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
@@ -128,11 +250,8 @@ func (c *Client) ForwardRequest(method, path string, body io.Reader, headers htt
 		}
 	}
 
-	// For HTTP backend with HTTPS frontend, ensure MinIO receives correct signature context
-	// Remove any forwarded proto headers that might confuse MinIO's signature validation
-	req.Header.Del("X-Forwarded-Proto")
-	req.Header.Del("X-Forwarded-Scheme")
-	req.Header.Del("X-Scheme")
+	// Forwarded proto headers are in DefaultStrippedHeaders; Accept-Encoding
+	// is only forwarded in the exact case the client sent it
 	req.Header.Del("Accept-Encoding")
 	if acceptEncoding := headers.Get("accept-encoding"); acceptEncoding != "" {
 		req.Header["accept-encoding"] = []string{acceptEncoding}
@@ -152,7 +271,7 @@ func (c *Client) ForwardRequest(method, path string, body io.Reader, headers htt
 		return ""
 	}
 
-	logging.Debug().
+	logging.FromContext(ctx).Debug().
 		Str("method", method).
 		Str("url", fullURL).
 		Str("final_host", req.Host).
@@ -162,52 +281,58 @@ func (c *Client) ForwardRequest(method, path string, body io.Reader, headers htt
 		Str("x-amz-content-sha256", getHeaderCaseInsensitive("X-Amz-Content-Sha256")).
 		Msg("Forwarding request to S3 with headers")
 
-	// Dump all headers being sent to MinIO
-	logging.Debug().
-		Interface("all_headers", req.Header).
-		Str("request_uri", req.URL.RequestURI()).
-		Str("raw_query", req.URL.RawQuery).
-		Msg("Complete request dump to MinIO")
-
 	// Make the request
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		if idle != nil {
+			idle.stop()
+			err = idle.err(err)
+		}
+		cancel()
 		return nil, fmt.Errorf("failed to forward request to S3: %w", err)
 	}
+	if idle != nil {
+		idle.touch()
+		resp.Body = &watchedBody{ReadCloser: resp.Body, watchdog: idle, cancel: cancel}
+	}
 
-	if resp.StatusCode >= 400 {
-		// Read error response for debugging
-		if body, readErr := io.ReadAll(resp.Body); readErr == nil {
-			resp.Body.Close()
-			logging.Warn().
-				Int("status_code", resp.StatusCode).
-				Str("method", method).
-				Str("error_body", string(body)).
-				Msg("S3 error response")
-			// Create a new reader for the response body so it can be read again
-			resp.Body = io.NopCloser(bytes.NewReader(body))
-		}
-	} else {
-		logging.Debug().
+	backendRequestsTotal.Inc(method, resp.Proto)
+	logResponse(ctx, resp, method)
+	return resp, nil
+}
+
+// logResponse logs a backend response, buffering the body of errors so it
+// can be logged and still read by the caller
+func logResponse(ctx context.Context, resp *http.Response, method string) {
+	if resp.StatusCode < 400 {
+		logging.FromContext(ctx).Debug().
 			Int("status_code", resp.StatusCode).
 			Str("method", method).
 			Msg("S3 response received")
+		return
+	}
+	if body, readErr := io.ReadAll(resp.Body); readErr == nil {
+		resp.Body.Close()
+		logging.FromContext(ctx).Warn().
+			Int("status_code", resp.StatusCode).
+			Str("method", method).
+			Str("error_body", string(body)).
+			Msg("S3 error response")
+		// Create a new reader for the response body so it can be read again
+		resp.Body = io.NopCloser(bytes.NewReader(body))
 	}
-
-	return resp, nil
 }
 
 // HeadObject performs a HEAD request for an object
-func (c *Client) HeadObject(bucket, key string, headers http.Header) (*http.Response, error) {
-	path := fmt.Sprintf("/%s/%s", bucket, key)
-	return c.ForwardRequest("HEAD", path, nil, headers, nil)
+func (c *Client) HeadObject(ctx context.Context, bucket, key string, headers http.Header) (*http.Response, error) {
+	return c.ForwardRequest(ctx, "HEAD", ObjectPath(bucket, key), nil, headers, nil)
 }
 
 // copyHeaders copies headers from source to destination request, handling special cases
 func (c *Client) copyHeaders(req *http.Request, headers http.Header) {
 	var originalHost string
 
-	logger := logging.Debug()
+	logger := logging.FromContext(req.Context()).Debug()
 	headerCount := 0
 
 	for key, values := range headers {
@@ -220,15 +345,19 @@ func (c *Client) copyHeaders(req *http.Request, headers http.Header) {
 
 		// Capture the original Host header for later
 		if strings.EqualFold(key, "host") {
+			if c.hostMode == HostRewrite {
+				// net/http derives Host from the backend URL
+				continue
+			}
 			originalHost = value
 		}
 
-		// Skip hop-by-hop headers
-		if c.isHopByHopHeader(key) {
-			logging.Debug().
+		// Skip hop-by-hop and proxy-added headers
+		if c.shouldStrip(headers, key) {
+			logging.FromContext(req.Context()).Debug().
 				Str("header", key).
 				Str("value", value).
-				Msg("Skipping hop-by-hop header")
+				Msg("Skipping stripped header")
 			continue
 		}
 
@@ -246,7 +375,7 @@ func (c *Client) copyHeaders(req *http.Request, headers http.Header) {
 	if originalHost != "" {
 		req.Host = originalHost
 		req.Header["Host"] = []string{originalHost}
-		logging.Debug().
+		logging.FromContext(req.Context()).Debug().
 			Str("host", originalHost).
 			Str("endpoint", c.endpoint).
 			Msg("Preserved original Host header for AWS signature validation")
@@ -257,35 +386,83 @@ func (c *Client) copyHeaders(req *http.Request, headers http.Header) {
 		Msg("Headers processed for S3 request")
 }
 
-// isHopByHopHeader checks if a header is hop-by-hop and should not be forwarded
-func (c *Client) isHopByHopHeader(header string) bool {
-	hopByHopHeaders := []string{
-		"Connection",
-		"Transfer-Encoding",
-		"Upgrade",
-		"Proxy-Connection",
-		"TE",
-		"Trailer",
-		"Keep-Alive",
-		// Note: X-Forwarded-Proto and related headers are handled explicitly in ForwardRequest
-		"Cf-Visitor",
-		"X-Forwarded-Host",
-		"X-Forwarded-Port",
-		"X-Forwarded-For",
-		"X-Real-Ip",
-		"X-Request-Id",
-		"Cf-Connecting-Ip",
-		"Cf-Ipcountry",
-		"Cf-Ray",
-		"Cdn-Loop",
+// hopByHopHeaders are connection-scoped (RFC 7230 section 6.1) and never forwarded
+var hopByHopHeaders = []string{
+	"Connection",
+	"Transfer-Encoding",
+	"Upgrade",
+	"Proxy-Connection",
+	"TE",
+	"Trailer",
+	"Keep-Alive",
+}
+
+// DefaultStrippedHeaders are added by CDNs and load balancers in front of the
+// proxy. Forwarded proto headers would also confuse the backend's signature
+// validation when TLS terminates before the proxy.
+var DefaultStrippedHeaders = []string{
+	"X-Forwarded-Proto",
+	"X-Forwarded-Scheme",
+	"X-Scheme",
+	"X-Forwarded-Host",
+	"X-Forwarded-Port",
+	"X-Forwarded-For",
+	"X-Real-Ip",
+	"X-Request-Id",
+	"Cf-Visitor",
+	"Cf-Connecting-Ip",
+	"Cf-Ipcountry",
+	"Cf-Ray",
+	"Cdn-Loop",
+}
+
+// ClientOption configures optional Client behaviour
+type ClientOption func(*Client)
+
+// WithStrippedHeaders replaces DefaultStrippedHeaders with headers
+func WithStrippedHeaders(headers []string) ClientOption {
+	return func(c *Client) {
+		c.stripped = headerSet(headers)
+	}
+}
+
+// WithForwardedHeaders always forwards headers, even when they are in the stripped set
+func WithForwardedHeaders(headers []string) ClientOption {
+	return func(c *Client) {
+		c.forwarded = headerSet(headers)
+	}
+}
+
+// WithHostMode selects whether the client's Host header is preserved or rewritten
+func WithHostMode(mode HostMode) ClientOption {
+	return func(c *Client) {
+		c.hostMode = mode
 	}
+}
+
+// headerSet lower-cases header names for case-insensitive lookup
+func headerSet(headers []string) map[string]bool {
+	set := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		set[strings.ToLower(header)] = true
+	}
+	return set
+}
 
+// shouldStrip reports whether a header must not be forwarded. Headers the
+// client signed are kept, since removing them would break the signature.
+func (c *Client) shouldStrip(headers http.Header, header string) bool {
 	for _, hopHeader := range hopByHopHeaders {
 		if strings.EqualFold(header, hopHeader) {
 			return true
 		}
 	}
-	return false
+
+	name := strings.ToLower(header)
+	if !c.stripped[name] || c.forwarded[name] {
+		return false
+	}
+	return !sigv4.IsSignedHeader(headers, header)
 }
 
 // Close closes the HTTP client and cleans up resources
@@ -294,3 +471,86 @@ func (c *Client) Close() {
 		c.httpClient.CloseIdleConnections()
 	}
 }
+
+// ProbeResult describes the backend as seen from the proxy
+type ProbeResult struct {
+	Endpoint   string
+	Reachable  bool
+	StatusCode int
+	Auth       string // enforced, anonymous_allowed or unknown
+	Latency    time.Duration
+	Error      string
+	Type       string // backend software, see BackendType
+}
+
+// BackendType names the S3 implementation that answered a request from its
+// Server header: minio, garage, ceph, seaweedfs, aws or unknown
+func BackendType(header http.Header) string {
+	server := strings.ToLower(header.Get("Server"))
+	for _, known := range []struct{ marker, name string }{
+		{"minio", "minio"},
+		{"garage", "garage"},
+		{"ceph", "ceph"},
+		{"seaweedfs", "seaweedfs"},
+		{"amazons3", "aws"},
+	} {
+		if strings.Contains(server, known.marker) {
+			return known.name
+		}
+	}
+	return "unknown"
+}
+
+// Probe sends an unsigned ListBuckets request. The proxy holds no client
+// credentials, so a healthy backend is expected to reject it with 403; a 200
+// means anonymous access is allowed, which is worth surfacing.
+func (c *Client) Probe(timeout time.Duration) ProbeResult {
+	return c.probe(http.MethodGet, "/", timeout)
+}
+
+// ProbeBucket sends an unsigned HeadBucket request. A 404 means the bucket is
+// missing; a 403 only shows the backend is reachable, since existence cannot
+// be confirmed without credentials.
+func (c *Client) ProbeBucket(bucket string, timeout time.Duration) ProbeResult {
+	result := c.probe(http.MethodHead, "/"+bucket, timeout)
+	if result.StatusCode == http.StatusNotFound {
+		result.Error = fmt.Sprintf("bucket %s does not exist", bucket)
+	}
+	return result
+}
+
+func (c *Client) probe(method, path string, timeout time.Duration) ProbeResult {
+	result := ProbeResult{Endpoint: c.endpoint, Auth: "unknown"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	result.Reachable = true
+	result.StatusCode = resp.StatusCode
+	result.Type = BackendType(resp.Header)
+	switch {
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized:
+		result.Auth = "enforced"
+	case resp.StatusCode < 300:
+		result.Auth = "anonymous_allowed"
+	case resp.StatusCode >= 500:
+		result.Error = fmt.Sprintf("backend returned status %d", resp.StatusCode)
+	}
+	return result
+}
@@ -0,0 +1,151 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func forwardedHeaders(client *Client, headers http.Header) http.Header {
+	req, _ := http.NewRequest("GET", "http://backend/bucket/key", nil)
+	client.copyHeaders(req, headers)
+	return req.Header
+}
+
+func TestCopyHeadersStripsDefaults(t *testing.T) {
+	client := NewClient("http://backend", "", DefaultTransportConfig())
+	got := forwardedHeaders(client, http.Header{
+		"connection":        []string{"keep-alive"},
+		"cf-ray":            []string{"abc"},
+		"x-forwarded-proto": []string{"https"},
+		"x-amz-date":        []string{"20240101T000000Z"},
+	})
+
+	assert.Equal(t, []string{"20240101T000000Z"}, got["x-amz-date"])
+	assert.NotContains(t, got, "connection")
+	assert.NotContains(t, got, "cf-ray")
+	assert.NotContains(t, got, "x-forwarded-proto", "stripped regardless of case")
+}
+
+func TestCopyHeadersConfigurable(t *testing.T) {
+	client := NewClient("http://backend", "", DefaultTransportConfig(),
+		WithStrippedHeaders([]string{"X-Envoy-Attempt-Count", "X-Request-Id"}),
+		WithForwardedHeaders([]string{"x-request-id"}))
+	got := forwardedHeaders(client, http.Header{
+		"Cf-Ray":                []string{"abc"},
+		"X-Envoy-Attempt-Count": []string{"1"},
+		"X-Request-Id":          []string{"req-1"},
+		"Transfer-Encoding":     []string{"chunked"},
+	})
+
+	assert.Equal(t, []string{"abc"}, got["Cf-Ray"], "replaced list no longer strips Cloudflare headers")
+	assert.NotContains(t, got, "X-Envoy-Attempt-Count")
+	assert.Equal(t, []string{"req-1"}, got["X-Request-Id"])
+	assert.NotContains(t, got, "Transfer-Encoding", "hop-by-hop headers are always stripped")
+}
+
+func TestCopyHeadersKeepsSignedHeaders(t *testing.T) {
+	client := NewClient("http://backend", "", DefaultTransportConfig())
+	got := forwardedHeaders(client, http.Header{
+		"Authorization": []string{"AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/s3/aws4_request, " +
+			"SignedHeaders=host;x-amz-date;x-forwarded-for, Signature=abc"},
+		"X-Forwarded-For": []string{"10.0.0.1"},
+		"X-Real-Ip":       []string{"10.0.0.1"},
+	})
+
+	assert.Equal(t, []string{"10.0.0.1"}, got["X-Forwarded-For"])
+	assert.NotContains(t, got, "X-Real-Ip")
+}
+
+func TestCopyHeadersHostMode(t *testing.T) {
+	headers := http.Header{"host": []string{"s3.example.com"}}
+
+	req, _ := http.NewRequest("GET", "http://backend:9000/bucket/key", nil)
+	NewClient("http://backend:9000", "", DefaultTransportConfig()).copyHeaders(req, headers)
+	assert.Equal(t, "s3.example.com", req.Host, "client Host is preserved by default")
+
+	req, _ = http.NewRequest("GET", "http://backend:9000/bucket/key", nil)
+	NewClient("http://backend:9000", "", DefaultTransportConfig(), WithHostMode(HostRewrite)).copyHeaders(req, headers)
+	assert.Equal(t, "backend:9000", req.Host)
+	assert.NotContains(t, req.Header, "host")
+}
+
+func TestBodyIdleTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer backend.Close()
+
+	cfg := DefaultTransportConfig()
+	cfg.BodyIdleTimeout = 50 * time.Millisecond
+	client := NewClient(backend.URL, "", cfg)
+
+	resp, err := client.ForwardRequest(context.Background(), "GET", "/bucket/key", nil, http.Header{}, nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	assert.Equal(t, "partial", string(data))
+	assert.ErrorIs(t, err, errNoProgress)
+}
+
+func TestBodyIdleTimeoutAllowsSlowProgress(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 4; i++ {
+			w.Write([]byte("x"))
+			w.(http.Flusher).Flush()
+			time.Sleep(30 * time.Millisecond)
+		}
+	}))
+	defer backend.Close()
+
+	cfg := DefaultTransportConfig()
+	cfg.BodyIdleTimeout = 100 * time.Millisecond
+	client := NewClient(backend.URL, "", cfg)
+
+	resp, err := client.ForwardRequest(context.Background(), "GET", "/bucket/key", nil, http.Header{}, nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "xxxx", string(data))
+}
+
+func TestProbeBucket(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer backend.Close()
+	client := NewClient(backend.URL, "", DefaultTransportConfig())
+
+	result := client.ProbeBucket("data", time.Second)
+	assert.True(t, result.Reachable)
+	assert.Equal(t, "enforced", result.Auth)
+	assert.Empty(t, result.Error)
+
+	result = client.ProbeBucket("missing", time.Second)
+	assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	assert.Contains(t, result.Error, "does not exist")
+}
+
+func TestBackendType(t *testing.T) {
+	assert.Equal(t, "minio", BackendType(http.Header{"Server": {"MinIO"}}))
+	assert.Equal(t, "garage", BackendType(http.Header{"Server": {"Garage/v1.0.1"}}))
+	assert.Equal(t, "aws", BackendType(http.Header{"Server": {"AmazonS3"}}))
+	assert.Equal(t, "unknown", BackendType(http.Header{}))
+}
@@ -0,0 +1,289 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+
+	"github.com/valyala/fasthttp"
+)
+
+// FastClient forwards requests to the backend with fasthttp, the HTTP stack
+// the proxy already serves clients with. It skips net/http's transport and
+// request parsing and pools its request and response buffers. Responses are
+// still returned as *http.Response, so it is a drop-in Interface.
+//
+// It speaks HTTP/1.1 only and does not observe context cancellation once a
+// request is sent; a context deadline still bounds the whole exchange.
+// Header names and paths are sent exactly as the client signed them.
+type FastClient struct {
+	endpoint          string
+	client            *fasthttp.HostClient
+	requestTimeout    time.Duration
+	disableKeepAlives bool
+
+	// headers holds the header forwarding policy the ClientOptions configure
+	headers *Client
+}
+
+// NewFastClient creates a fasthttp client for endpoint. It takes the same
+// settings as NewClient; EnableHTTP2, BodyIdleTimeout, TLSHandshakeTimeout
+// and ResponseHeaderTimeout are not supported and ignored.
+func NewFastClient(endpoint string, caCertPath string, transportCfg TransportConfig, opts ...ClientOption) (*FastClient, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+	isTLS := u.Scheme == "https"
+
+	hostClient := &fasthttp.HostClient{
+		Addr:                          fasthttp.AddMissingPort(u.Host, isTLS),
+		IsTLS:                         isTLS,
+		MaxConns:                      transportCfg.MaxConnsPerHost,
+		MaxIdleConnDuration:           transportCfg.IdleConnTimeout,
+		NoDefaultUserAgentHeader:      true,
+		DisableHeaderNamesNormalizing: true,
+		DisablePathNormalizing:        true,
+		StreamResponseBody:            true,
+		// Requests are not retried: bodies are streamed and cannot be replayed
+		MaxIdemponentCallAttempts: 1,
+	}
+	if transportCfg.DialTimeout > 0 {
+		dialer := &net.Dialer{Timeout: transportCfg.DialTimeout, KeepAlive: transportCfg.KeepAlive}
+		hostClient.Dial = func(addr string) (net.Conn, error) {
+			return dialer.Dial("tcp", addr)
+		}
+	}
+	if transportCfg.MaxConnsPerHost == 0 {
+		// Match net/http's unlimited default rather than fasthttp's 512
+		hostClient.MaxConns = int(^uint(0) >> 1)
+	}
+	if isTLS && caCertPath != "" {
+		pool, err := readCACertPool(caCertPath)
+		if err != nil {
+			return nil, err
+		}
+		hostClient.TLSConfig = &tls.Config{RootCAs: pool}
+	}
+
+	headers := &Client{
+		endpoint: endpoint,
+		stripped: headerSet(DefaultStrippedHeaders),
+		hostMode: HostPreserve,
+	}
+	for _, opt := range opts {
+		opt(headers)
+	}
+
+	logging.Debug().
+		Str("endpoint", endpoint).
+		Int("max_conns_per_host", transportCfg.MaxConnsPerHost).
+		Dur("dial_timeout", transportCfg.DialTimeout).
+		Dur("request_timeout", transportCfg.RequestTimeout).
+		Msg("S3 fasthttp client configuration")
+
+	return &FastClient{
+		endpoint:          endpoint,
+		client:            hostClient,
+		requestTimeout:    transportCfg.RequestTimeout,
+		disableKeepAlives: transportCfg.DisableKeepAlives,
+		headers:           headers,
+	}, nil
+}
+
+// readCACertPool reads a PEM bundle of CA certificates
+func readCACertPool(path string) (*x509.CertPool, error) {
+	caCert, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("failed to parse CA certificate %s: invalid PEM format", path)
+	}
+	return pool, nil
+}
+
+// ForwardRequest forwards an HTTP request to the S3 backend
+func (c *FastClient) ForwardRequest(ctx context.Context, method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+
+	req.Header.DisableNormalizing()
+	req.Header.SetNoDefaultContentType(true)
+	req.Header.SetMethod(method)
+	req.SetRequestURI(c.endpoint + path)
+	if len(queryString) > 0 {
+		req.URI().SetQueryStringBytes(queryString)
+	}
+	req.UseHostHeader = true
+	if c.disableKeepAlives {
+		req.SetConnectionClose()
+	}
+	c.copyHeaders(req, headers)
+
+	// The client's Content-Length is kept as-is, since aws-chunked bodies sign it
+	contentLength := -1
+	if value := headers.Get("Content-Length"); value != "" {
+		if length, err := strconv.Atoi(value); err == nil {
+			contentLength = length
+		}
+	}
+	switch {
+	case body != nil:
+		req.SetBodyStream(body, contentLength)
+	case contentLength >= 0:
+		req.Header.SetContentLength(contentLength)
+	}
+	resp.SkipBody = method == http.MethodHead
+
+	err := c.do(ctx, req, resp)
+	if err != nil {
+		fasthttp.ReleaseResponse(resp)
+		return nil, fmt.Errorf("failed to forward request to S3: %w", err)
+	}
+
+	backendRequestsTotal.Inc(method, "HTTP/1.1")
+	converted := toHTTPResponse(resp, method)
+	logResponse(ctx, converted, method)
+	return converted, nil
+}
+
+// HeadObject performs a HEAD request for an object
+func (c *FastClient) HeadObject(ctx context.Context, bucket, key string, headers http.Header) (*http.Response, error) {
+	return c.ForwardRequest(ctx, http.MethodHead, ObjectPath(bucket, key), nil, headers, nil)
+}
+
+// do sends a request, bounded by the context deadline and the request timeout
+func (c *FastClient) do(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if c.requestTimeout > 0 {
+		if timeout := time.Now().Add(c.requestTimeout); !ok || timeout.Before(deadline) {
+			deadline, ok = timeout, true
+		}
+	}
+	if ok {
+		return c.client.DoDeadline(req, resp, deadline)
+	}
+	return c.client.Do(req, resp)
+}
+
+// copyHeaders copies the forwarded request headers, keeping their case
+func (c *FastClient) copyHeaders(req *fasthttp.Request, headers http.Header) {
+	host := ""
+	for key, values := range headers {
+		if len(values) == 0 {
+			continue
+		}
+		switch {
+		case strings.EqualFold(key, "host"):
+			if c.headers.hostMode == HostPreserve {
+				host = values[0]
+			}
+			continue
+		case strings.EqualFold(key, "content-length"):
+			// Set with the body
+			continue
+		case c.headers.shouldStrip(headers, key):
+			continue
+		}
+		req.Header.Set(key, values[0])
+	}
+	if host != "" {
+		req.Header.SetHost(host)
+	}
+}
+
+// toHTTPResponse converts a fasthttp response, streaming its body. Closing
+// the body returns the response and its connection to fasthttp's pools.
+func toHTTPResponse(resp *fasthttp.Response, method string) *http.Response {
+	status := resp.StatusCode()
+	header := make(http.Header)
+	resp.Header.VisitAll(func(key, value []byte) {
+		header.Add(string(key), string(value))
+	})
+
+	contentLength := int64(resp.Header.ContentLength())
+	if contentLength < 0 {
+		contentLength = -1
+	}
+	var body io.ReadCloser
+	switch {
+	case method == http.MethodHead:
+		body = http.NoBody
+		fasthttp.ReleaseResponse(resp)
+	case resp.BodyStream() != nil:
+		body = &fastBody{Reader: resp.BodyStream(), resp: resp}
+	default:
+		data := append([]byte(nil), resp.Body()...)
+		fasthttp.ReleaseResponse(resp)
+		body = io.NopCloser(bytes.NewReader(data))
+		contentLength = int64(len(data))
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: contentLength,
+	}
+}
+
+// fastBody is a streamed fasthttp response body
+type fastBody struct {
+	io.Reader
+	resp *fasthttp.Response
+	eof  bool
+}
+
+func (b *fastBody) Read(p []byte) (int, error) {
+	if b.resp == nil {
+		return 0, errors.New("read on closed response body")
+	}
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+// Close releases the response. A body that was not read to the end closes
+// its connection rather than returning it to the pool.
+func (b *fastBody) Close() error {
+	if b.resp == nil {
+		return nil
+	}
+	if !b.eof {
+		b.resp.SetConnectionClose()
+	}
+	err := b.resp.CloseBodyStream()
+	fasthttp.ReleaseResponse(b.resp)
+	b.resp = nil
+	return err
+}
+
+// Close closes idle backend connections
+func (c *FastClient) Close() {
+	c.client.CloseIdleConnections()
+}
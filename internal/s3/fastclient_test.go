@@ -0,0 +1,166 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFastClientForwardRequest(t *testing.T) {
+	var got *http.Request
+	var gotBody string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		switch r.URL.Path {
+		case "/bucket/missing":
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "<Error><Code>NoSuchKey</Code></Error>")
+		default:
+			w.Header().Set("ETag", `"etag"`)
+			w.Header().Set("Content-Length", "5")
+			if r.Method != http.MethodHead {
+				io.WriteString(w, "hello")
+			}
+		}
+	}))
+	defer backend.Close()
+	client, err := NewFastClient(backend.URL, "", DefaultTransportConfig())
+	require.NoError(t, err)
+	defer client.Close()
+	ctx := context.Background()
+
+	headers := http.Header{
+		"host":                 {"s3.example.com"},
+		"x-amz-content-sha256": {"UNSIGNED-PAYLOAD"},
+		"Content-Length":       {"4"},
+		"Cf-Ray":               {"abc"},
+	}
+	resp, err := client.ForwardRequest(ctx, http.MethodPut, "/bucket/a%20b", strings.NewReader("data"), headers, []byte("x=1"))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	require.NoError(t, resp.Body.Close())
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", string(body))
+	assert.Equal(t, `"etag"`, resp.Header.Get("ETag"))
+	assert.Equal(t, "s3.example.com", got.Host, "the signed Host is preserved")
+	assert.Equal(t, "/bucket/a%20b?x=1", got.RequestURI, "the signed path encoding is preserved")
+	assert.Equal(t, "data", gotBody)
+	assert.Equal(t, int64(4), got.ContentLength)
+	assert.Equal(t, "UNSIGNED-PAYLOAD", got.Header.Get("X-Amz-Content-Sha256"))
+	assert.Empty(t, got.Header.Get("Cf-Ray"), "stripped headers are not forwarded")
+	assert.Empty(t, got.Header.Get("User-Agent"))
+
+	resp, err = client.HeadObject(ctx, "bucket", "key", http.Header{})
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "5", resp.Header.Get("Content-Length"))
+
+	resp, err = client.ForwardRequest(ctx, http.MethodGet, "/bucket/missing", nil, http.Header{}, nil)
+	require.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, string(body), "NoSuchKey")
+}
+
+func TestFastClientUnreadBody(t *testing.T) {
+	large := bytes.Repeat([]byte("x"), 1<<20)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bucket/large" {
+			w.Write(large)
+			return
+		}
+		io.WriteString(w, "small")
+	}))
+	defer backend.Close()
+	client, err := NewFastClient(backend.URL, "", DefaultTransportConfig())
+	require.NoError(t, err)
+
+	// A body closed early must not leave its remainder for the next request
+	resp, err := client.ForwardRequest(context.Background(), http.MethodGet, "/bucket/large", nil, http.Header{}, nil)
+	require.NoError(t, err)
+	_, err = resp.Body.Read(make([]byte, 10))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	resp, err = client.ForwardRequest(context.Background(), http.MethodGet, "/bucket/small", nil, http.Header{}, nil)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "small", string(body))
+}
+
+// BenchmarkForwardRequest compares the net/http and fasthttp clients on a
+// small GET and a 1MB PUT, the shapes of metadata lookups and uploads
+func BenchmarkForwardRequest(b *testing.B) {
+	object := bytes.Repeat([]byte("x"), 1<<20)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("X-Amz-Request-Id", "req")
+		if r.Method == http.MethodGet {
+			io.WriteString(w, `{"kms_key_id":"arn:aws:kms:us-east-1:123456789012:key/k"}`)
+		}
+	}))
+	defer backend.Close()
+
+	fastClient, err := NewFastClient(backend.URL, "", DefaultTransportConfig())
+	require.NoError(b, err)
+	clients := []struct {
+		name   string
+		client Interface
+	}{
+		{"net/http", NewClient(backend.URL, "", DefaultTransportConfig())},
+		{"fasthttp", fastClient},
+	}
+	headers := http.Header{
+		"Host":                 {"s3.example.com"},
+		"Authorization":        {"AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-date, Signature=abc"},
+		"X-Amz-Date":           {"20240101T000000Z"},
+		"X-Amz-Content-Sha256": {"UNSIGNED-PAYLOAD"},
+	}
+	putHeaders := headers.Clone()
+	putHeaders.Set("Content-Length", strconv.Itoa(len(object)))
+
+	for _, c := range clients {
+		b.Run(c.name+"/get", func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					resp, err := c.client.ForwardRequest(context.Background(), http.MethodGet, "/bucket/key.metadata", nil, headers, nil)
+					if err != nil {
+						b.Fatal(err)
+					}
+					_, _ = io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+			})
+		})
+		b.Run(c.name+"/put-1MB", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(object)))
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					resp, err := c.client.ForwardRequest(context.Background(), http.MethodPut, "/bucket/key", bytes.NewReader(object), putHeaders, nil)
+					if err != nil {
+						b.Fatal(err)
+					}
+					_, _ = io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package s3
+
+import (
+	"net/url"
+	"strings"
+
+	"s3-vault-proxy/internal/sigv4"
+)
+
+// Object keys are handled unescaped everywhere in the proxy and are only
+// encoded at the edge, when a request path for the backend is built. Paths
+// given to ForwardRequest are always URI-encoded.
+
+// ObjectPath returns the URI-encoded request path of an object. Every byte of
+// the key except RFC 3986 unreserved characters and '/' is percent-encoded, as
+// in SigV4 canonical requests, so spaces, '+', '%', '#', '?', unicode and
+// consecutive slashes survive the trip to the backend.
+func ObjectPath(bucket, key string) string {
+	return "/" + bucket + "/" + sigv4.EncodePath(key)
+}
+
+// DecodeKey returns the unescaped key of a URI-encoded request path segment.
+// '+' is a literal plus in paths, never a space.
+func DecodeKey(rawKey string) (string, error) {
+	return url.PathUnescape(rawKey)
+}
+
+// ParseCopySource splits an x-amz-copy-source header ("/bucket/key", URL
+// encoded, with an optional ?versionId=) into its bucket and key
+func ParseCopySource(source string) (bucket, key string, ok bool) {
+	source, _, _ = strings.Cut(source, "?")
+	decoded, err := url.PathUnescape(strings.TrimPrefix(source, "/"))
+	if err != nil {
+		return "", "", false
+	}
+	bucket, key, ok = strings.Cut(decoded, "/")
+	return bucket, key, ok && bucket != "" && key != ""
+}
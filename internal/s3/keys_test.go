@@ -0,0 +1,77 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// specialKeys are key names written by tools such as rclone and restic, and
+// keys built to break naive path handling
+var specialKeys = []string{
+	"plain.txt",
+	"with space.txt",
+	"a+b=c.txt",
+	"100%.txt",
+	"%41 not an escape",
+	"issue#12?.txt",
+	"données/日本語/😀.bin",
+	"double//slash/",
+	"/leading-slash",
+	"restic/data/3f/3f1e2d4c5b6a79880f1e2d4c5b6a79880f1e2d4c5b6a79880f1e2d4c5b6a7988",
+	"rclone/Backup 2024-01-01 (copy) [1] ~ & $ ! ' , ; @.tar.gz",
+}
+
+func TestObjectPathRoundTrip(t *testing.T) {
+	for _, key := range specialKeys {
+		path := ObjectPath("bucket", key)
+		require.Regexp(t, `^/bucket/[A-Za-z0-9\-._~/%]*$`, path, key)
+
+		decoded, err := DecodeKey(path[len("/bucket/"):])
+		require.NoError(t, err)
+		assert.Equal(t, key, decoded)
+	}
+}
+
+func TestObjectPathReachesBackendUnchanged(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.URL.Path
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "", TransportConfig{})
+	for _, key := range specialKeys {
+		resp, err := client.HeadObject(context.Background(), "bucket", key, http.Header{})
+		require.NoError(t, err, key)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "/bucket/"+key, received)
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	key, err := DecodeKey("a+b%20c%2Bd")
+	require.NoError(t, err)
+	assert.Equal(t, "a+b c+d", key)
+
+	_, err = DecodeKey("100%")
+	assert.Error(t, err)
+}
+
+func TestParseCopySource(t *testing.T) {
+	bucket, key, ok := ParseCopySource("/source/dir/a%20b.txt?versionId=1")
+	assert.True(t, ok)
+	assert.Equal(t, "source", bucket)
+	assert.Equal(t, "dir/a b.txt", key)
+
+	for _, invalid := range []string{"", "/source", "source/", "/source/100%"} {
+		_, _, ok := ParseCopySource(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
@@ -0,0 +1,42 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"s3-vault-proxy/internal/etag"
+)
+
+// PartSize returns the size of the first part of a multipart object, which
+// the upload used for every part but the last. It returns 0 when the backend
+// does not answer reads of single parts.
+func PartSize(ctx context.Context, client Interface, bucket, key string) (int64, error) {
+	resp, err := client.ForwardRequest(ctx, http.MethodHead, ObjectPath(bucket, key), nil, http.Header{}, []byte("partNumber=1"))
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return 0, nil
+	}
+	return resp.ContentLength, nil
+}
+
+// NewETagReader returns a reader computing the ETag of an object's body, read
+// from resp. Objects the source uploaded in parts keep a multipart ETag of the
+// same layout, so tools that compare ETags do not see them as changed after a
+// copy. Other objects, and multipart objects whose layout cannot be read,
+// get the single-part ETag.
+func NewETagReader(ctx context.Context, client Interface, bucket, key string, resp *http.Response) etag.Hasher {
+	parts := etag.Parts(resp.Header.Get("ETag"))
+	if parts == 0 || resp.ContentLength < 0 {
+		return etag.NewReader(resp.Body)
+	}
+	partSize, err := PartSize(ctx, client, bucket, key)
+	if err != nil || partSize <= 0 || (resp.ContentLength+partSize-1)/partSize != int64(parts) {
+		return etag.NewReader(resp.Body)
+	}
+	return etag.NewPartReader(resp.Body, partSize)
+}
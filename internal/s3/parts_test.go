@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"s3-vault-proxy/internal/etag"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// multipartObject serves one object uploaded in parts of partSize bytes, and
+// its first part when partNumber=1 is asked for
+func multipartObject(data string, partSize int, partsETag string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", partsETag)
+		body := data
+		if r.URL.Query().Get("partNumber") == "1" && partSize > 0 {
+			body = data[:partSize]
+			w.Header().Set("X-Amz-Mp-Parts-Count", "2")
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if r.Method == http.MethodGet {
+			io.WriteString(w, body)
+		}
+	}
+}
+
+func readETag(t *testing.T, handler http.HandlerFunc) string {
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	client := NewClient(server.URL, "", DefaultTransportConfig())
+	ctx := context.Background()
+
+	resp, err := client.ForwardRequest(ctx, http.MethodGet, "/bucket/key", nil, http.Header{}, nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	reader := NewETagReader(ctx, client, "bucket", "key", resp)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "part onepart two", string(data))
+	return reader.ETag()
+}
+
+func TestNewETagReader(t *testing.T) {
+	const data = "part onepart two"
+	first := md5.Sum([]byte("part one"))
+	second := md5.Sum([]byte("part two"))
+	partsETag := etag.Multipart([][]byte{first[:], second[:]})
+	whole := md5.Sum([]byte(data))
+
+	assert.Equal(t, partsETag, readETag(t, multipartObject(data, 8, partsETag)), "the source's part layout is kept")
+	assert.Equal(t, etag.FromMD5(whole[:]), readETag(t, multipartObject(data, 8, `"etag"`)))
+	assert.Equal(t, etag.FromMD5(whole[:]), readETag(t, multipartObject(data, 0, partsETag)),
+		"backends that ignore partNumber give the single-part ETag")
+}
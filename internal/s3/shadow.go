@@ -0,0 +1,218 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"math/rand"
+	"net/http"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metrics"
+)
+
+var shadowRequestsTotal = metrics.NewCounter(
+	"s3_vault_proxy_shadow_requests_total",
+	"Requests mirrored to the shadow backend per method and comparison result (match, mismatch, error, skipped).",
+	"method", "result",
+)
+
+// ShadowOptions configures which requests a ShadowClient mirrors
+type ShadowOptions struct {
+	// SampleRatio is the fraction of eligible requests mirrored, from 0 to 1
+	SampleRatio float64
+	// Writes also mirrors PUT, POST and DELETE requests whose body is at most MaxWriteSize bytes
+	Writes       bool
+	MaxWriteSize int64
+	// MaxInFlight bounds concurrent shadow requests; sampled requests beyond it are skipped
+	MaxInFlight int
+}
+
+// ShadowClient mirrors a sample of backend requests to a second backend and
+// compares the responses, so a migration target can be validated with real
+// traffic before cutover. Only the primary response reaches the client.
+type ShadowClient struct {
+	inner    Interface
+	shadow   Interface
+	opts     ShadowOptions
+	inFlight chan struct{}
+	sample   func() float64
+}
+
+// NewShadowClient wraps an S3 client, mirroring requests to shadow
+func NewShadowClient(inner, shadow Interface, opts ShadowOptions) *ShadowClient {
+	if opts.MaxInFlight <= 0 {
+		opts.MaxInFlight = 64
+	}
+	return &ShadowClient{
+		inner:    inner,
+		shadow:   shadow,
+		opts:     opts,
+		inFlight: make(chan struct{}, opts.MaxInFlight),
+		sample:   rand.Float64,
+	}
+}
+
+// shadowResult is what is compared between the primary and shadow responses
+type shadowResult struct {
+	status   int
+	length   int64
+	bodyHash []byte // nil when the body was not read to the end
+	err      error
+}
+
+// ForwardRequest forwards a request to the primary backend and, when it is
+// sampled, to the shadow backend in the background
+func (s *ShadowClient) ForwardRequest(ctx context.Context, method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
+	write := method != http.MethodGet && method != http.MethodHead
+	if (write && !s.opts.Writes) || s.sample() >= s.opts.SampleRatio {
+		return s.inner.ForwardRequest(ctx, method, path, body, headers, queryString)
+	}
+
+	// Writes need their body twice, so only bodies that fit in memory are mirrored
+	var payload []byte
+	if write && body != nil {
+		buffered, err := io.ReadAll(io.LimitReader(body, s.opts.MaxWriteSize+1))
+		if err != nil {
+			return nil, err
+		}
+		body = io.MultiReader(bytes.NewReader(buffered), body)
+		if int64(len(buffered)) > s.opts.MaxWriteSize {
+			shadowRequestsTotal.Inc(method, "skipped")
+			return s.inner.ForwardRequest(ctx, method, path, body, headers, queryString)
+		}
+		payload = buffered
+	}
+
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		shadowRequestsTotal.Inc(method, "skipped")
+		return s.inner.ForwardRequest(ctx, method, path, body, headers, queryString)
+	}
+
+	shadowDone := make(chan shadowResult, 1)
+	shadowHeaders := headers.Clone()
+	go func() {
+		defer func() { <-s.inFlight }()
+		shadowDone <- s.forwardShadow(ctx, method, path, payload, shadowHeaders, queryString)
+	}()
+
+	resp, err := s.inner.ForwardRequest(ctx, method, path, body, headers, queryString)
+	if err != nil {
+		go s.compare(ctx, method, path, shadowResult{err: err}, shadowDone)
+		return nil, err
+	}
+	resp.Body = &comparingBody{
+		ReadCloser: resp.Body,
+		hash:       sha256.New(),
+		readBody:   method != http.MethodHead,
+		result:     shadowResult{status: resp.StatusCode, length: resp.ContentLength},
+		done: func(primary shadowResult) {
+			go s.compare(ctx, method, path, primary, shadowDone)
+		},
+	}
+	return resp, nil
+}
+
+// HeadObject performs a HEAD request for an object through the shadowing path
+func (s *ShadowClient) HeadObject(ctx context.Context, bucket, key string, headers http.Header) (*http.Response, error) {
+	return s.ForwardRequest(ctx, "HEAD", ObjectPath(bucket, key), nil, headers, nil)
+}
+
+func (s *ShadowClient) forwardShadow(ctx context.Context, method, path string, payload []byte, headers http.Header, queryString []byte) shadowResult {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	resp, err := s.shadow.ForwardRequest(ctx, method, path, body, headers, queryString)
+	if err != nil {
+		return shadowResult{err: err}
+	}
+	defer resp.Body.Close()
+
+	result := shadowResult{status: resp.StatusCode, length: resp.ContentLength}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		result.err = err
+		return result
+	}
+	result.bodyHash = hash.Sum(nil)
+	return result
+}
+
+// compare waits for the shadow response and records whether it matched the
+// primary one. Successful bodies are compared when both were read to the
+// end; error bodies carry request IDs and ETags are derived differently for
+// encrypted objects, so neither is compared.
+func (s *ShadowClient) compare(ctx context.Context, method, path string, primary shadowResult, shadowDone <-chan shadowResult) {
+	shadow := <-shadowDone
+
+	var mismatch string
+	switch {
+	case primary.err != nil || shadow.err != nil:
+		logging.FromContext(ctx).Warn().
+			AnErr("primary_error", primary.err).
+			AnErr("shadow_error", shadow.err).
+			Str("method", method).
+			Str("path", path).
+			Msg("Shadow request failed")
+		shadowRequestsTotal.Inc(method, "error")
+		return
+	case primary.status != shadow.status:
+		mismatch = "status"
+	case primary.length >= 0 && shadow.length >= 0 && primary.length != shadow.length:
+		mismatch = "content_length"
+	case primary.status < 300 && primary.bodyHash != nil && shadow.bodyHash != nil && !bytes.Equal(primary.bodyHash, shadow.bodyHash):
+		mismatch = "body"
+	}
+
+	if mismatch == "" {
+		shadowRequestsTotal.Inc(method, "match")
+		return
+	}
+	logging.FromContext(ctx).Warn().
+		Str("method", method).
+		Str("path", path).
+		Str("mismatch", mismatch).
+		Int("primary_status", primary.status).
+		Int("shadow_status", shadow.status).
+		Int64("primary_length", primary.length).
+		Int64("shadow_length", shadow.length).
+		Msg("Shadow response differs from primary")
+	shadowRequestsTotal.Inc(method, "mismatch")
+}
+
+// comparingBody hashes the primary response body as the client reads it and
+// starts the comparison once it is closed
+type comparingBody struct {
+	io.ReadCloser
+	hash     hash.Hash
+	readBody bool
+	eof      bool
+	result   shadowResult
+	done     func(shadowResult)
+	closed   bool
+}
+
+func (b *comparingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+func (b *comparingBody) Close() error {
+	if !b.closed {
+		b.closed = true
+		if b.readBody && b.eof {
+			b.result.bodyHash = b.hash.Sum(nil)
+		}
+		b.done(b.result)
+	}
+	return b.ReadCloser.Close()
+}
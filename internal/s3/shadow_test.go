@@ -0,0 +1,105 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingBackend answers every request with a fixed body and remembers what it received
+type recordingBackend struct {
+	body string
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func (b *recordingBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	payload, _ := io.ReadAll(r.Body)
+	b.mu.Lock()
+	b.requests = append(b.requests, r.Method+" "+r.URL.Path+" "+string(payload))
+	b.mu.Unlock()
+	io.WriteString(w, b.body)
+}
+
+func (b *recordingBackend) received() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.requests...)
+}
+
+func newShadowTest(t *testing.T, primaryBody, shadowBody string, opts ShadowOptions) (*ShadowClient, *recordingBackend) {
+	primary := httptest.NewServer(&recordingBackend{body: primaryBody})
+	t.Cleanup(primary.Close)
+	shadowBackend := &recordingBackend{body: shadowBody}
+	shadow := httptest.NewServer(shadowBackend)
+	t.Cleanup(shadow.Close)
+
+	client := NewShadowClient(
+		NewClient(primary.URL, "", DefaultTransportConfig()),
+		NewClient(shadow.URL, "", DefaultTransportConfig()),
+		opts)
+	return client, shadowBackend
+}
+
+func readAll(t *testing.T, client *ShadowClient, method, path, body string) string {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	resp, err := client.ForwardRequest(context.Background(), method, path, reader, http.Header{}, nil)
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	return string(data)
+}
+
+func TestShadowClientComparesReads(t *testing.T) {
+	client, shadow := newShadowTest(t, "hello", "hello", ShadowOptions{SampleRatio: 1})
+	matches := shadowRequestsTotal.Value("GET", "match")
+
+	assert.Equal(t, "hello", readAll(t, client, "GET", "/bucket/same", ""))
+	assert.Eventually(t, func() bool { return shadowRequestsTotal.Value("GET", "match") == matches+1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"GET /bucket/same "}, shadow.received())
+
+	client, _ = newShadowTest(t, "hello", "jello", ShadowOptions{SampleRatio: 1})
+	mismatches := shadowRequestsTotal.Value("GET", "mismatch")
+	assert.Equal(t, "hello", readAll(t, client, "GET", "/bucket/differs", ""), "the client only ever sees the primary response")
+	assert.Eventually(t, func() bool { return shadowRequestsTotal.Value("GET", "mismatch") == mismatches+1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestShadowClientWrites(t *testing.T) {
+	client, shadow := newShadowTest(t, "", "", ShadowOptions{SampleRatio: 1})
+	readAll(t, client, "PUT", "/bucket/key", "data")
+	assert.Empty(t, shadow.received(), "writes are not mirrored unless enabled")
+
+	client, shadow = newShadowTest(t, "", "", ShadowOptions{SampleRatio: 1, Writes: true, MaxWriteSize: 4})
+	readAll(t, client, "PUT", "/bucket/small", "data")
+	readAll(t, client, "PUT", "/bucket/large", "too large")
+	assert.Eventually(t, func() bool { return len(shadow.received()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"PUT /bucket/small data"}, shadow.received(), "bodies over the limit are not mirrored")
+}
+
+func TestShadowClientSampling(t *testing.T) {
+	client, shadow := newShadowTest(t, "hello", "hello", ShadowOptions{SampleRatio: 0.5})
+	samples := []float64{0.7, 0.2}
+	client.sample = func() float64 {
+		sample := samples[0]
+		samples = samples[1:]
+		return sample
+	}
+
+	readAll(t, client, "GET", "/bucket/skipped", "")
+	readAll(t, client, "GET", "/bucket/sampled", "")
+	assert.Eventually(t, func() bool { return len(shadow.received()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"GET /bucket/sampled "}, shadow.received())
+}
@@ -0,0 +1,52 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"s3-vault-proxy/internal/sigv4"
+)
+
+// SigningClient signs requests with the proxy's own credentials. It is used by
+// maintenance commands, which have no client request to forward.
+type SigningClient struct {
+	inner       Interface
+	host        string
+	credentials sigv4.Credentials
+	now         func() time.Time
+}
+
+// NewSigningClient wraps an S3 client so every request is signed for endpoint
+func NewSigningClient(inner Interface, endpoint string, credentials sigv4.Credentials) (*SigningClient, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	return &SigningClient{
+		inner:       inner,
+		host:        parsed.Host,
+		credentials: credentials,
+		now:         time.Now,
+	}, nil
+}
+
+// ForwardRequest signs and sends a request. path must be URI-encoded, as
+// built by ObjectPath, and is signed as given.
+func (s *SigningClient) ForwardRequest(ctx context.Context, method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
+	signed := make(http.Header, len(headers)+4)
+	for key, values := range headers {
+		signed[key] = values
+	}
+
+	s.credentials.Sign(method, s.host, path, string(queryString), signed, s.now())
+	return s.inner.ForwardRequest(ctx, method, path, body, signed, queryString)
+}
+
+// HeadObject performs a signed HEAD request for an object
+func (s *SigningClient) HeadObject(ctx context.Context, bucket, key string, headers http.Header) (*http.Response, error) {
+	return s.ForwardRequest(ctx, "HEAD", ObjectPath(bucket, key), nil, headers, nil)
+}
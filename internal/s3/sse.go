@@ -0,0 +1,177 @@
+package s3
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Server-side encryption request headers
+const (
+	HeaderSSE          = "X-Amz-Server-Side-Encryption"
+	HeaderSSEKMSKeyID  = "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"
+	HeaderSSEContext   = "X-Amz-Server-Side-Encryption-Context"
+	HeaderSSEBucketKey = "X-Amz-Server-Side-Encryption-Bucket-Key-Enabled"
+
+	// Prefixes of the SSE-C headers of an object and of a copy's source
+	headerSSECustomer     = "X-Amz-Server-Side-Encryption-Customer-"
+	headerCopySSECustomer = "X-Amz-Copy-Source-Server-Side-Encryption-Customer-"
+)
+
+const (
+	sseAlgorithmKMS        = "aws:kms"
+	notApplicableSSEReason = "The encryption parameters are not applicable to this object."
+)
+
+// SSEOperation is the kind of request whose headers SSEHeaders.Check validates
+type SSEOperation int
+
+// SSE operations
+const (
+	// SSEUpload is a PUT, copy or CreateMultipartUpload, which chooses the
+	// object's encryption
+	SSEUpload SSEOperation = iota
+	// SSEPart is an UploadPart or UploadPartCopy, encrypted under its upload's key
+	SSEPart
+	// SSERead is a GET or HEAD
+	SSERead
+)
+
+// SSEError is a combination of encryption headers the proxy cannot honor in
+// full, with the S3 error code it is answered with
+type SSEError struct {
+	Code    string
+	Message string
+}
+
+func (e *SSEError) Error() string {
+	return e.Message
+}
+
+// SSEHeaders are the server-side encryption headers of a request
+type SSEHeaders struct {
+	Algorithm        string
+	KMSKeyID         string
+	Context          string
+	BucketKeyEnabled string
+	// Customer is whether SSE-C headers name a key for the object itself
+	Customer bool
+	// CopySourceCustomer is whether SSE-C headers name a key for a copy's source
+	CopySourceCustomer bool
+}
+
+// ParseSSEHeaders reads a request's encryption headers. The proxy keeps
+// header names as clients spelled them, so names are matched regardless of
+// case. A header sent more than once with different values is an error:
+// the proxy would authorize one key while the backend encrypted with another.
+func ParseSSEHeaders(header *fasthttp.RequestHeader) (SSEHeaders, error) {
+	var sse SSEHeaders
+	var conflict string
+	header.VisitAll(func(key, value []byte) {
+		name := string(key)
+		var field *string
+		switch {
+		case strings.EqualFold(name, HeaderSSE):
+			field = &sse.Algorithm
+		case strings.EqualFold(name, HeaderSSEKMSKeyID):
+			field = &sse.KMSKeyID
+		case strings.EqualFold(name, HeaderSSEContext):
+			field = &sse.Context
+		case strings.EqualFold(name, HeaderSSEBucketKey):
+			field = &sse.BucketKeyEnabled
+		case hasPrefixFold(name, headerSSECustomer):
+			sse.Customer = true
+		case hasPrefixFold(name, headerCopySSECustomer):
+			sse.CopySourceCustomer = true
+		}
+		if field == nil {
+			return
+		}
+		if *field != "" && *field != string(value) && conflict == "" {
+			conflict = name
+		}
+		if *field == "" {
+			*field = string(value)
+		}
+	})
+	if conflict != "" {
+		return sse, &SSEError{
+			Code:    "InvalidArgument",
+			Message: "The " + strings.ToLower(conflict) + " header was sent more than once with different values",
+		}
+	}
+	return sse, nil
+}
+
+// Check returns an SSEError when the headers ask for encryption an operation
+// cannot honor, or nil. Objects are only ever stored with SSE-KMS, so
+// customer-provided keys cannot apply to any object or copy source.
+func (h SSEHeaders) Check(operation SSEOperation) error {
+	if h.CopySourceCustomer {
+		return &SSEError{Code: "InvalidRequest", Message: notApplicableSSEReason}
+	}
+	if operation == SSERead {
+		if h.Customer {
+			return &SSEError{Code: "InvalidRequest", Message: notApplicableSSEReason}
+		}
+		return nil
+	}
+	if h.Customer {
+		return &SSEError{
+			Code:    "InvalidArgument",
+			Message: "Server-side encryption with customer-provided keys is not supported; objects are stored with aws:kms",
+		}
+	}
+	if operation != SSEUpload {
+		return nil
+	}
+	if h.Algorithm != "" && h.Algorithm != sseAlgorithmKMS {
+		return &SSEError{
+			Code:    "InvalidArgument",
+			Message: "The encryption method " + h.Algorithm + " is not supported; objects are stored with aws:kms",
+		}
+	}
+	if h.Context != "" {
+		if _, err := ParseEncryptionContext(h.Context); err != nil {
+			return &SSEError{Code: "InvalidArgument", Message: err.Error()}
+		}
+	}
+	if h.BucketKeyEnabled != "" {
+		if _, err := strconv.ParseBool(h.BucketKeyEnabled); err != nil {
+			return &SSEError{
+				Code:    "InvalidArgument",
+				Message: "x-amz-server-side-encryption-bucket-key-enabled must be true or false",
+			}
+		}
+	}
+	return nil
+}
+
+// ParseEncryptionContext decodes an x-amz-server-side-encryption-context
+// value: a base64-encoded JSON object of string keys and values
+func ParseEncryptionContext(value string) (map[string]string, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("x-amz-server-side-encryption-context must be base64-encoded")
+	}
+	var context map[string]string
+	if err := json.Unmarshal(data, &context); err != nil || context == nil {
+		return nil, fmt.Errorf("x-amz-server-side-encryption-context must be a JSON object of string keys and values")
+	}
+	return context, nil
+}
+
+// FormatEncryptionContext encodes an encryption context as an
+// x-amz-server-side-encryption-context value
+func FormatEncryptionContext(context map[string]string) string {
+	data, _ := json.Marshal(context)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
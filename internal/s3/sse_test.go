@@ -0,0 +1,96 @@
+package s3
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func sseHeader(headers ...string) *fasthttp.RequestHeader {
+	header := new(fasthttp.RequestHeader)
+	header.DisableNormalizing()
+	for i := 0; i < len(headers); i += 2 {
+		header.Add(headers[i], headers[i+1])
+	}
+	return header
+}
+
+func sseErrorCode(err error) string {
+	var sseErr *SSEError
+	if errors.As(err, &sseErr) {
+		return sseErr.Code
+	}
+	return ""
+}
+
+func TestParseSSEHeaders(t *testing.T) {
+	sse, err := ParseSSEHeaders(sseHeader(
+		"x-amz-server-side-encryption", "aws:kms",
+		"X-AMZ-SERVER-SIDE-ENCRYPTION-AWS-KMS-KEY-ID", "arn",
+		"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", "arn",
+		"x-amz-server-side-encryption-context", "e30=",
+		"x-amz-server-side-encryption-bucket-key-enabled", "true",
+	))
+	require.NoError(t, err, "repeating a header with the same value is harmless")
+	assert.Equal(t, SSEHeaders{Algorithm: "aws:kms", KMSKeyID: "arn", Context: "e30=", BucketKeyEnabled: "true"}, sse)
+	assert.NoError(t, sse.Check(SSEUpload))
+
+	_, err = ParseSSEHeaders(sseHeader(
+		"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", "arn-a",
+		"x-amz-server-side-encryption-aws-kms-key-id", "arn-b",
+	))
+	assert.Equal(t, "InvalidArgument", sseErrorCode(err), "spellings naming different keys conflict")
+
+	sse, err = ParseSSEHeaders(sseHeader(
+		"x-amz-server-side-encryption-customer-algorithm", "AES256",
+		"X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key", "a2V5",
+	))
+	require.NoError(t, err)
+	assert.True(t, sse.Customer)
+	assert.True(t, sse.CopySourceCustomer)
+}
+
+func TestSSEHeadersCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		sse       SSEHeaders
+		operation SSEOperation
+		wantCode  string
+	}{
+		{"KMS key without algorithm", SSEHeaders{KMSKeyID: "arn"}, SSEUpload, ""},
+		{"SSE-S3", SSEHeaders{Algorithm: "AES256"}, SSEUpload, "InvalidArgument"},
+		{"DSSE", SSEHeaders{Algorithm: "aws:kms:dsse", KMSKeyID: "arn"}, SSEUpload, "InvalidArgument"},
+		{"SSE-C upload", SSEHeaders{Customer: true}, SSEUpload, "InvalidArgument"},
+		{"SSE-C part", SSEHeaders{Customer: true}, SSEPart, "InvalidArgument"},
+		{"SSE-C read", SSEHeaders{Customer: true}, SSERead, "InvalidRequest"},
+		{"SSE-C copy source", SSEHeaders{KMSKeyID: "arn", CopySourceCustomer: true}, SSEUpload, "InvalidRequest"},
+		{"encryption context", SSEHeaders{Context: "eyJwcm9qZWN0IjoiYSJ9"}, SSEUpload, ""},
+		{"encryption context not base64", SSEHeaders{Context: "{}"}, SSEUpload, "InvalidArgument"},
+		{"encryption context not an object", SSEHeaders{Context: "WzFd"}, SSEUpload, "InvalidArgument"},
+		{"invalid bucket key flag", SSEHeaders{BucketKeyEnabled: "yes please"}, SSEUpload, "InvalidArgument"},
+		{"algorithm on a read", SSEHeaders{Algorithm: "aws:kms"}, SSERead, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantCode, sseErrorCode(tt.sse.Check(tt.operation)))
+		})
+	}
+}
+
+func TestParseEncryptionContext(t *testing.T) {
+	context, err := ParseEncryptionContext("eyJwcm9qZWN0IjoiYSIsInRlYW0iOiJiIn0=")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"project": "a", "team": "b"}, context)
+
+	for _, invalid := range []string{"not base64!", "bnVsbA==", "eyJhIjoxfQ=="} {
+		_, err := ParseEncryptionContext(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestFormatEncryptionContext(t *testing.T) {
+	assert.Equal(t, "eyJwcm9qZWN0IjoiYSIsInRlYW0iOiJiIn0=", FormatEncryptionContext(map[string]string{"team": "b", "project": "a"}))
+}
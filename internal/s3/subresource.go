@@ -0,0 +1,22 @@
+package s3
+
+import "net/url"
+
+// objectSubresources address something attached to an existing object rather
+// than its data, so requests for them carry no body to encrypt
+var objectSubresources = []string{"tagging", "acl", "retention", "legal-hold", "attributes"}
+
+// ObjectSubresource returns the object sub-resource a query string selects, or
+// "" when the request addresses the object's data
+func ObjectSubresource(query string) string {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return ""
+	}
+	for _, name := range objectSubresources {
+		if _, ok := values[name]; ok {
+			return name
+		}
+	}
+	return ""
+}
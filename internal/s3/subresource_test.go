@@ -0,0 +1,16 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectSubresource(t *testing.T) {
+	assert.Equal(t, "tagging", ObjectSubresource("tagging"))
+	assert.Equal(t, "tagging", ObjectSubresource("tagging=&versionId=abc"))
+	assert.Equal(t, "legal-hold", ObjectSubresource("legal-hold"))
+	assert.Equal(t, "", ObjectSubresource(""))
+	assert.Equal(t, "", ObjectSubresource("partNumber=1&uploadId=abc"), "multipart parts carry object data")
+	assert.Equal(t, "", ObjectSubresource("X-Amz-Signature=abc"))
+}
@@ -0,0 +1,84 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"s3-vault-proxy/internal/sigv4"
+	"s3-vault-proxy/internal/tracing"
+)
+
+// TraceparentHeader is the W3C trace context header propagated to the backend
+const TraceparentHeader = "traceparent"
+
+// TracingClient records a client span around every backend request
+type TracingClient struct {
+	inner     Interface
+	tracer    *tracing.Tracer
+	propagate bool
+}
+
+// NewTracingClient wraps an S3 client with tracing. The parent span is taken
+// from the traceparent header the handler attaches to the forwarded headers.
+// With propagate unset the client span is recorded but no traceparent is
+// sent to the backend.
+func NewTracingClient(inner Interface, tracer *tracing.Tracer, propagate bool) *TracingClient {
+	return &TracingClient{
+		inner:     inner,
+		tracer:    tracer,
+		propagate: propagate,
+	}
+}
+
+// ForwardRequest forwards a request to the backend inside a client span
+func (t *TracingClient) ForwardRequest(ctx context.Context, method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
+	parent, _ := tracing.ParseTraceparent(sigv4.HeaderValue(headers, TraceparentHeader))
+	span := t.tracer.Start(parent, "s3 "+method, tracing.KindClient)
+	defer span.End()
+
+	span.SetAttribute("http.method", method)
+	span.SetAttribute("url.path", path)
+
+	if t.propagate {
+		headers = InjectTraceContext(headers, span.Context())
+	}
+	resp, err := t.inner.ForwardRequest(ctx, method, path, body, headers, queryString)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	span.SetAttribute("network.protocol.version", resp.Proto)
+	if resp.StatusCode >= 500 {
+		span.SetError(fmt.Errorf("backend returned %s", resp.Status))
+	}
+	return resp, nil
+}
+
+// HeadObject performs a HEAD request for an object inside a client span
+func (t *TracingClient) HeadObject(ctx context.Context, bucket, key string, headers http.Header) (*http.Response, error) {
+	return t.ForwardRequest(ctx, "HEAD", ObjectPath(bucket, key), nil, headers, nil)
+}
+
+// InjectTraceContext returns a copy of headers carrying sc as the traceparent.
+// Headers are returned unchanged when sc is invalid or the client signed its
+// own traceparent, since rewriting a signed header would break the signature.
+func InjectTraceContext(headers http.Header, sc tracing.SpanContext) http.Header {
+	if !sc.IsValid() || sigv4.IsSignedHeader(headers, TraceparentHeader) {
+		return headers
+	}
+
+	injected := make(http.Header, len(headers)+1)
+	for key, values := range headers {
+		if strings.EqualFold(key, TraceparentHeader) {
+			continue
+		}
+		injected[key] = values
+	}
+	injected[TraceparentHeader] = []string{sc.Traceparent()}
+	return injected
+}
@@ -0,0 +1,237 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/vault"
+)
+
+var backendErrorsTotal = metrics.NewCounter(
+	"s3_vault_proxy_backend_errors_total",
+	"Backend error responses by the S3 error code returned to the client.",
+	"code",
+)
+
+// maxErrorBody bounds the error bodies parsed. Larger bodies are not errors
+// a backend wrote and are replaced by their status' canonical error.
+const maxErrorBody = 64 * 1024
+
+// s3Error is a canonical S3 error: its status and AWS' message
+type s3Error struct {
+	status  int
+	message string
+}
+
+// s3Errors are the S3 error codes clients and their SDKs know, with the
+// status and message AWS answers them with
+var s3Errors = map[string]s3Error{
+	"AccessDenied":                 {http.StatusForbidden, "Access Denied"},
+	"AuthorizationHeaderMalformed": {http.StatusBadRequest, "The authorization header is malformed"},
+	"BadDigest":                    {http.StatusBadRequest, "The Content-MD5 you specified did not match what we received."},
+	"BucketAlreadyExists":          {http.StatusConflict, "The requested bucket name is not available."},
+	"BucketAlreadyOwnedByYou":      {http.StatusConflict, "Your previous request to create the named bucket succeeded and you already own it."},
+	"BucketNotEmpty":               {http.StatusConflict, "The bucket you tried to delete is not empty"},
+	"EntityTooLarge":               {http.StatusBadRequest, "Your proposed upload exceeds the maximum allowed size"},
+	"EntityTooSmall":               {http.StatusBadRequest, "Your proposed upload is smaller than the minimum allowed object size."},
+	"ExpiredToken":                 {http.StatusBadRequest, "The provided token has expired."},
+	"IncompleteBody":               {http.StatusBadRequest, "You did not provide the number of bytes specified by the Content-Length HTTP header."},
+	"InternalError":                {http.StatusInternalServerError, "We encountered an internal error. Please try again."},
+	"InvalidAccessKeyId":           {http.StatusForbidden, "The AWS access key Id you provided does not exist in our records."},
+	"InvalidArgument":              {http.StatusBadRequest, "Invalid Argument"},
+	"InvalidBucketName":            {http.StatusBadRequest, "The specified bucket is not valid."},
+	"InvalidDigest":                {http.StatusBadRequest, "The Content-MD5 you specified is not valid."},
+	"InvalidPart":                  {http.StatusBadRequest, "One or more of the specified parts could not be found."},
+	"InvalidPartOrder":             {http.StatusBadRequest, "The list of parts was not in ascending order."},
+	"InvalidRange":                 {http.StatusRequestedRangeNotSatisfiable, "The requested range is not satisfiable"},
+	"InvalidRequest":               {http.StatusBadRequest, "Invalid Request"},
+	"InvalidToken":                 {http.StatusBadRequest, "The provided token is malformed or otherwise invalid."},
+	"KMS.KMSInvalidStateException": {http.StatusBadRequest, "The KMS key version that encrypted this object can no longer be used for decryption."},
+	"KeyTooLongError":              {http.StatusBadRequest, "Your key is too long"},
+	"MalformedXML":                 {http.StatusBadRequest, "The XML you provided was not well-formed or did not validate against our published schema."},
+	"MethodNotAllowed":             {http.StatusMethodNotAllowed, "The specified method is not allowed against this resource."},
+	"MissingContentLength":         {http.StatusLengthRequired, "You must provide the Content-Length HTTP header."},
+	"NoSuchBucket":                 {http.StatusNotFound, "The specified bucket does not exist"},
+	"NoSuchKey":                    {http.StatusNotFound, "The specified key does not exist."},
+	"NoSuchUpload":                 {http.StatusNotFound, "The specified multipart upload does not exist."},
+	"NoSuchVersion":                {http.StatusNotFound, "The specified version does not exist."},
+	"NotImplemented":               {http.StatusNotImplemented, "A header you provided implies functionality that is not implemented."},
+	"OperationAborted":             {http.StatusConflict, "A conflicting conditional operation is currently in progress against this resource. Try again."},
+	"PreconditionFailed":           {http.StatusPreconditionFailed, "At least one of the preconditions you specified did not hold."},
+	"RequestTimeTooSkewed":         {http.StatusForbidden, "The difference between the request time and the server's time is too large."},
+	"RequestTimeout":               {http.StatusBadRequest, "Your socket connection to the server was not read from or written to within the timeout period."},
+	"ServiceUnavailable":           {http.StatusServiceUnavailable, "Please reduce your request rate."},
+	"SignatureDoesNotMatch":        {http.StatusForbidden, "The request signature we calculated does not match the signature you provided. Check your key and signing method."},
+	"SlowDown":                     {http.StatusServiceUnavailable, "Please reduce your request rate."},
+	"XAmzContentSHA256Mismatch":    {http.StatusBadRequest, "The provided 'x-amz-content-sha256' header does not match what was computed."},
+}
+
+// backendErrorCodes map codes only some backends use to the S3 code clients
+// expect in their place
+var backendErrorCodes = map[string]string{
+	// MinIO
+	"XMinioInvalidObjectName":    "InvalidArgument",
+	"XMinioStorageFull":          "ServiceUnavailable",
+	"XMinioServerNotInitialized": "ServiceUnavailable",
+	"XMinioReadQuorum":           "ServiceUnavailable",
+	"XMinioWriteQuorum":          "ServiceUnavailable",
+	// Garage
+	"BadRequest":     "InvalidRequest",
+	"Forbidden":      "AccessDenied",
+	"BucketNotFound": "NoSuchBucket",
+	"InvalidXml":     "MalformedXML",
+	"InvalidUtf8Str": "InvalidArgument",
+	"InvalidHeader":  "InvalidArgument",
+}
+
+// statusErrorCodes are the codes of errors known only by their status, such
+// as a load balancer's HTML error page
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:                   "InvalidRequest",
+	http.StatusUnauthorized:                 "AccessDenied",
+	http.StatusForbidden:                    "AccessDenied",
+	http.StatusMethodNotAllowed:             "MethodNotAllowed",
+	http.StatusConflict:                     "OperationAborted",
+	http.StatusLengthRequired:               "MissingContentLength",
+	http.StatusPreconditionFailed:           "PreconditionFailed",
+	http.StatusRequestEntityTooLarge:        "EntityTooLarge",
+	http.StatusRequestedRangeNotSatisfiable: "InvalidRange",
+	http.StatusTooManyRequests:              "SlowDown",
+	http.StatusNotImplemented:               "NotImplemented",
+	http.StatusBadGateway:                   "ServiceUnavailable",
+	http.StatusServiceUnavailable:           "ServiceUnavailable",
+	http.StatusGatewayTimeout:               "ServiceUnavailable",
+	http.StatusInsufficientStorage:          "ServiceUnavailable",
+}
+
+// backendError is an S3 error body. The request details are kept, and
+// SignatureDoesNotMatch keeps what the backend signed for the client to
+// compare.
+type backendError struct {
+	XMLName          xml.Name `xml:"Error"`
+	Code             string   `xml:"Code"`
+	Message          string   `xml:"Message"`
+	BucketName       string   `xml:"BucketName,omitempty"`
+	Key              string   `xml:"Key,omitempty"`
+	Resource         string   `xml:"Resource,omitempty"`
+	RequestID        string   `xml:"RequestId,omitempty"`
+	HostID           string   `xml:"HostId,omitempty"`
+	StringToSign     string   `xml:"StringToSign,omitempty"`
+	CanonicalRequest string   `xml:"CanonicalRequest,omitempty"`
+}
+
+// ErrorTranslatingClient rewrites backend error responses into the codes and
+// statuses AWS S3 answers with. SDKs decide whether to retry by status and
+// code, so backend-specific answers, such as MinIO's 507 when a disk is full,
+// would otherwise be misread. Translated codes get AWS' message. Unknown
+// codes are kept when their status is one S3 uses for client errors and
+// replaced by their status' code otherwise.
+type ErrorTranslatingClient struct {
+	inner Interface
+}
+
+// NewErrorTranslatingClient wraps an S3 client with error translation
+func NewErrorTranslatingClient(inner Interface) *ErrorTranslatingClient {
+	return &ErrorTranslatingClient{inner: inner}
+}
+
+// ForwardRequest forwards a request, translating an error response
+func (c *ErrorTranslatingClient) ForwardRequest(ctx context.Context, method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
+	resp, err := c.inner.ForwardRequest(ctx, method, path, body, headers, queryString)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+	translateError(ctx, resp, method)
+	return resp, nil
+}
+
+// HeadObject performs a HEAD request for an object, translating its status
+func (c *ErrorTranslatingClient) HeadObject(ctx context.Context, bucket, key string, headers http.Header) (*http.Response, error) {
+	resp, err := c.inner.HeadObject(ctx, bucket, key, headers)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+	translateError(ctx, resp, http.MethodHead)
+	return resp, nil
+}
+
+// translateError replaces the status and body of an error response with
+// their canonical S3 form
+func translateError(ctx context.Context, resp *http.Response, method string) {
+	var parsed backendError
+	if method != http.MethodHead {
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody+1))
+		resp.Body.Close()
+		if err != nil || len(data) > maxErrorBody || xml.Unmarshal(data, &parsed) != nil {
+			parsed = backendError{}
+		}
+	}
+
+	code := parsed.Code
+	if canonical, ok := backendErrorCodes[code]; ok {
+		code = canonical
+	}
+	// Retrying a refused key version cannot help, so clients get the code S3
+	// uses for KMS keys that cannot be used in their current state
+	if strings.Contains(parsed.Message, vault.KeyVersionDisallowedMessage) {
+		code = "KMS.KMSInvalidStateException"
+	}
+	canonical, known := s3Errors[code]
+	switch {
+	case known:
+	case code != "" && http.StatusText(resp.StatusCode) != "" && resp.StatusCode < 500:
+		// A code S3 may have added since, with a status it could answer
+		canonical = s3Error{status: resp.StatusCode}
+	default:
+		code = errorCodeForStatus(resp.StatusCode)
+		canonical = s3Errors[code]
+	}
+	if canonical.status != resp.StatusCode || code != parsed.Code {
+		logging.FromContext(ctx).Debug().
+			Int("backend_status", resp.StatusCode).
+			Str("backend_code", parsed.Code).
+			Int("status", canonical.status).
+			Str("code", code).
+			Msg("Translated backend error")
+	}
+	backendErrorsTotal.Inc(code)
+
+	resp.StatusCode = canonical.status
+	resp.Status = strconv.Itoa(canonical.status) + " " + http.StatusText(canonical.status)
+	if method == http.MethodHead {
+		return
+	}
+	// Messages of codes S3 knows are kept, since they often name the problem
+	if code != parsed.Code || parsed.Message == "" {
+		parsed.Message = canonical.message
+	}
+	parsed.Code = code
+	data, _ := xml.Marshal(parsed)
+	data = append([]byte(xml.Header), data...)
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	resp.Header.Set("Content-Type", "application/xml")
+	resp.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	resp.Header.Del("Content-Encoding")
+}
+
+// errorCodeForStatus is the S3 code of an error known only by its status
+func errorCodeForStatus(status int) string {
+	if code, ok := statusErrorCodes[status]; ok {
+		return code
+	}
+	if status == http.StatusNotFound {
+		return "NoSuchKey"
+	}
+	if status >= 500 {
+		return "InternalError"
+	}
+	return "InvalidRequest"
+}
@@ -0,0 +1,119 @@
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorTranslatingClient(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{
+			name:        "S3 codes keep their message",
+			status:      http.StatusNotFound,
+			body:        `<Error><Code>NoSuchKey</Code><Message>Key not found</Message><Resource>/bucket/key</Resource></Error>`,
+			wantStatus:  http.StatusNotFound,
+			wantCode:    "NoSuchKey",
+			wantMessage: "Key not found",
+		},
+		{
+			name:        "MinIO disk full",
+			status:      http.StatusInsufficientStorage,
+			body:        `<Error><Code>XMinioStorageFull</Code><Message>Storage backend has reached its minimum free drive threshold.</Message></Error>`,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantCode:    "ServiceUnavailable",
+			wantMessage: "Please reduce your request rate.",
+		},
+		{
+			name:        "Garage bucket not found",
+			status:      http.StatusNotFound,
+			body:        `<Error><Code>BucketNotFound</Code><Message>Bucket not found: bucket</Message></Error>`,
+			wantStatus:  http.StatusNotFound,
+			wantCode:    "NoSuchBucket",
+			wantMessage: "The specified bucket does not exist",
+		},
+		{
+			name:        "non-XML gateway error",
+			status:      http.StatusBadGateway,
+			body:        `<html><body>502 Bad Gateway</body></html>`,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantCode:    "ServiceUnavailable",
+			wantMessage: "Please reduce your request rate.",
+		},
+		{
+			name:        "unknown client error code",
+			status:      http.StatusBadRequest,
+			body:        `<Error><Code>InvalidStorageClass</Code><Message>The storage class you specified is not valid</Message></Error>`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "InvalidStorageClass",
+			wantMessage: "The storage class you specified is not valid",
+		},
+		{
+			name:        "Vault refused an old key version",
+			status:      http.StatusInternalServerError,
+			body:        `<Error><Code>InternalError</Code><Message>failed to decrypt: ciphertext or signature version is disallowed by policy (too old)</Message></Error>`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "KMS.KMSInvalidStateException",
+			wantMessage: "The KMS key version that encrypted this object can no longer be used for decryption.",
+		},
+		{
+			name:        "unknown server error code",
+			status:      599,
+			body:        `<Error><Code>XBackendPanic</Code><Message>panic</Message></Error>`,
+			wantStatus:  http.StatusInternalServerError,
+			wantCode:    "InternalError",
+			wantMessage: "We encountered an internal error. Please try again.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Amz-Request-Id", "req")
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer backend.Close()
+			client := NewErrorTranslatingClient(NewClient(backend.URL, "", DefaultTransportConfig()))
+
+			resp, err := client.ForwardRequest(context.Background(), http.MethodGet, "/bucket/key", nil, http.Header{}, nil)
+			require.NoError(t, err)
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			var body backendError
+			require.NoError(t, xml.Unmarshal(data, &body), "%s", data)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, tt.wantCode, body.Code)
+			assert.Equal(t, tt.wantMessage, body.Message)
+			assert.Equal(t, strconv.Itoa(len(data)), resp.Header.Get("Content-Length"))
+			assert.Equal(t, "req", resp.Header.Get("X-Amz-Request-Id"))
+		})
+	}
+}
+
+func TestErrorTranslatingClientHead(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInsufficientStorage)
+	}))
+	defer backend.Close()
+	client := NewErrorTranslatingClient(NewClient(backend.URL, "", DefaultTransportConfig()))
+
+	resp, err := client.HeadObject(context.Background(), "bucket", "key", http.Header{})
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
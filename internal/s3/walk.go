@@ -0,0 +1,149 @@
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ObjectInfo is one entry of a ListObjectsV2 page
+type ObjectInfo struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	ETag         string    `xml:"ETag"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+type listObjectsV2Page struct {
+	Contents              []ObjectInfo `xml:"Contents"`
+	IsTruncated           bool         `xml:"IsTruncated"`
+	NextContinuationToken string       `xml:"NextContinuationToken"`
+}
+
+// WalkObjects lists every object in bucket whose key sorts after startAfter,
+// in key order, page by page. The walk stops at the first error fn returns.
+func WalkObjects(ctx context.Context, client Interface, bucket, prefix, startAfter string, fn func(ObjectInfo) error) error {
+	continuation := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if continuation != "" {
+			query.Set("continuation-token", continuation)
+		} else if startAfter != "" {
+			query.Set("start-after", startAfter)
+		}
+
+		page, err := listObjectsV2(ctx, client, bucket, query)
+		if err != nil {
+			return err
+		}
+		for _, object := range page.Contents {
+			if err := fn(object); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		continuation = page.NextContinuationToken
+	}
+}
+
+func listObjectsV2(ctx context.Context, client Interface, bucket string, query url.Values) (*listObjectsV2Page, error) {
+	resp, err := client.ForwardRequest(ctx, "GET", "/"+bucket, nil, http.Header{}, []byte(query.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bucket %s: %w", bucket, err)
+	}
+	defer resp.Body.Close()
+
+	var page listObjectsV2Page
+	if err := decodeListing(resp, &page); err != nil {
+		return nil, fmt.Errorf("failed to list bucket %s: %w", bucket, err)
+	}
+	return &page, nil
+}
+
+// MultipartUpload is one entry of a ListMultipartUploads page
+type MultipartUpload struct {
+	Key       string    `xml:"Key"`
+	UploadID  string    `xml:"UploadId"`
+	Initiated time.Time `xml:"Initiated"`
+}
+
+type listMultipartUploadsPage struct {
+	Uploads            []MultipartUpload `xml:"Upload"`
+	IsTruncated        bool              `xml:"IsTruncated"`
+	NextKeyMarker      string            `xml:"NextKeyMarker"`
+	NextUploadIDMarker string            `xml:"NextUploadIdMarker"`
+}
+
+// WalkMultipartUploads lists every in-progress multipart upload in bucket
+func WalkMultipartUploads(ctx context.Context, client Interface, bucket string, fn func(MultipartUpload) error) error {
+	keyMarker, uploadIDMarker := "", ""
+	for {
+		query := url.Values{"uploads": {""}}
+		if keyMarker != "" {
+			query.Set("key-marker", keyMarker)
+			query.Set("upload-id-marker", uploadIDMarker)
+		}
+
+		resp, err := client.ForwardRequest(ctx, "GET", "/"+bucket, nil, http.Header{}, []byte(query.Encode()))
+		if err != nil {
+			return fmt.Errorf("failed to list multipart uploads in %s: %w", bucket, err)
+		}
+		var page listMultipartUploadsPage
+		err = decodeListing(resp, &page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to list multipart uploads in %s: %w", bucket, err)
+		}
+
+		for _, upload := range page.Uploads {
+			if err := fn(upload); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextKeyMarker == "" {
+			return nil
+		}
+		keyMarker, uploadIDMarker = page.NextKeyMarker, page.NextUploadIDMarker
+	}
+}
+
+// ListBuckets returns the names of every bucket the client's credentials own
+func ListBuckets(ctx context.Context, client Interface) ([]string, error) {
+	resp, err := client.ForwardRequest(ctx, "GET", "/", nil, http.Header{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list buckets: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var page struct {
+		Buckets []struct {
+			Name string `xml:"Name"`
+		} `xml:"Buckets>Bucket"`
+	}
+	if err := decodeListing(resp, &page); err != nil {
+		return nil, fmt.Errorf("failed to list buckets: %w", err)
+	}
+	names := make([]string, 0, len(page.Buckets))
+	for _, bucket := range page.Buckets {
+		names = append(names, bucket.Name)
+	}
+	return names, nil
+}
+
+// decodeListing parses a successful XML listing response into page
+func decodeListing(resp *http.Response, page interface{}) error {
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, body)
+	}
+	return xml.NewDecoder(resp.Body).Decode(page)
+}
@@ -0,0 +1,67 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"s3-vault-proxy/internal/sigv4"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkObjectsPaginates(t *testing.T) {
+	var queries []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=AKID/")
+		if r.URL.Query().Get("continuation-token") == "" {
+			fmt.Fprint(w, `<ListBucketResult><Contents><Key>a b</Key><Size>3</Size></Contents>`+
+				`<IsTruncated>true</IsTruncated><NextContinuationToken>next</NextContinuationToken></ListBucketResult>`)
+			return
+		}
+		fmt.Fprint(w, `<ListBucketResult><Contents><Key>c</Key><Size>5</Size>`+
+			`<LastModified>2024-01-02T03:04:05.000Z</LastModified></Contents><IsTruncated>false</IsTruncated></ListBucketResult>`)
+	}))
+	defer backend.Close()
+
+	client, err := NewSigningClient(NewClient(backend.URL, "", DefaultTransportConfig()), backend.URL,
+		sigv4.Credentials{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1"})
+	require.NoError(t, err)
+
+	var keys []string
+	err = WalkObjects(context.Background(), client, "bucket", "", "a", func(object ObjectInfo) error {
+		keys = append(keys, object.Key)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a b", "c"}, keys)
+	assert.Equal(t, []string{"list-type=2&start-after=a", "continuation-token=next&list-type=2"}, queries)
+}
+
+func TestWalkObjectsReportsBackendErrors(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<Error><Code>AccessDenied</Code></Error>`)
+	}))
+	defer backend.Close()
+
+	err := WalkObjects(context.Background(), NewClient(backend.URL, "", DefaultTransportConfig()), "bucket", "", "", func(ObjectInfo) error { return nil })
+	assert.ErrorContains(t, err, "HTTP 403")
+}
+
+func TestListBuckets(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/", r.URL.Path)
+		fmt.Fprint(w, `<ListAllMyBucketsResult><Buckets><Bucket><Name>a</Name></Bucket>`+
+			`<Bucket><Name>b</Name></Bucket></Buckets></ListAllMyBucketsResult>`)
+	}))
+	defer backend.Close()
+
+	names, err := ListBuckets(context.Background(), NewClient(backend.URL, "", DefaultTransportConfig()))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, names)
+}
@@ -0,0 +1,105 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// errNoProgress is reported when a transfer stalls for longer than the idle timeout
+var errNoProgress = errors.New("transfer made no progress within the idle timeout")
+
+// watchdog cancels a backend request when neither body moves data for the
+// idle timeout. It is paused while the backend processes a fully sent request.
+type watchdog struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	idle    time.Duration
+	stalled bool
+	stopped bool
+}
+
+func newWatchdog(idle time.Duration, cancel context.CancelFunc) *watchdog {
+	w := &watchdog{idle: idle}
+	w.timer = time.AfterFunc(idle, func() {
+		w.mu.Lock()
+		w.stalled = !w.stopped
+		w.mu.Unlock()
+		cancel()
+	})
+	return w
+}
+
+// touch records progress and restarts the idle timer
+func (w *watchdog) touch() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.stopped {
+		w.timer.Reset(w.idle)
+	}
+}
+
+// pause suspends the idle timer until the next touch
+func (w *watchdog) pause() {
+	w.timer.Stop()
+}
+
+// stop disarms the watchdog for good
+func (w *watchdog) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	w.timer.Stop()
+}
+
+// err translates a read error caused by the watchdog into errNoProgress
+func (w *watchdog) err(err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil && err != io.EOF && w.stalled {
+		return errNoProgress
+	}
+	return err
+}
+
+// progressReader feeds the watchdog from the request body, pausing it once
+// the body has been fully sent
+type progressReader struct {
+	io.Reader
+	watchdog *watchdog
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.watchdog.touch()
+	}
+	if err == io.EOF {
+		r.watchdog.pause()
+	}
+	return n, err
+}
+
+// watchedBody feeds the watchdog from the response body and releases it on close
+type watchedBody struct {
+	io.ReadCloser
+	watchdog *watchdog
+	cancel   context.CancelFunc
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.watchdog.touch()
+	}
+	return n, b.watchdog.err(err)
+}
+
+func (b *watchedBody) Close() error {
+	b.watchdog.stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,139 @@
+// Package scopedkeys restricts access keys to buckets, key prefixes and read
+// or write access. Applications then get least-privilege credentials from
+// the proxy without the backend supporting an IAM policy language.
+package scopedkeys
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrOutOfScope is returned for requests a scoped key may not make
+var ErrOutOfScope = errors.New("request is outside the access key's scopes")
+
+// Access levels of a scope
+const (
+	Read      = "read"
+	Write     = "write"
+	ReadWrite = "read-write"
+)
+
+// Scope grants access to the keys of a bucket under a prefix ("" is the
+// whole bucket). Read covers GetObject, HeadObject and listings, write
+// covers uploads, copies into the scope and deletes.
+type Scope struct {
+	Bucket string `json:"bucket"`
+	Prefix string `json:"prefix,omitempty"`
+	Access string `json:"access"`
+}
+
+func (s Scope) allows(write bool) bool {
+	if write {
+		return s.Access == Write || s.Access == ReadWrite
+	}
+	return s.Access == Read || s.Access == ReadWrite
+}
+
+// Key is an access key and the scopes it is limited to
+type Key struct {
+	AccessKey string  `json:"access_key"`
+	Scopes    []Scope `json:"scopes"`
+}
+
+// Set holds the scoped access keys. Access keys it does not list are not
+// restricted by it.
+type Set struct {
+	scopes map[string][]Scope
+}
+
+// Load reads scoped keys from a JSON file of the form {"keys": [...]}
+func Load(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scoped keys file: %w", err)
+	}
+	var file struct {
+		Keys []Key `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse scoped keys file %s: %w", path, err)
+	}
+	return New(file.Keys)
+}
+
+// New validates keys and indexes them by access key
+func New(keys []Key) (*Set, error) {
+	set := &Set{scopes: make(map[string][]Scope, len(keys))}
+	for _, key := range keys {
+		if key.AccessKey == "" {
+			return nil, fmt.Errorf("every scoped key needs an access_key")
+		}
+		if _, ok := set.scopes[key.AccessKey]; ok {
+			return nil, fmt.Errorf("access key %s is listed twice", key.AccessKey)
+		}
+		if len(key.Scopes) == 0 {
+			return nil, fmt.Errorf("access key %s needs at least one scope", key.AccessKey)
+		}
+		for _, scope := range key.Scopes {
+			if scope.Bucket == "" {
+				return nil, fmt.Errorf("every scope of access key %s needs a bucket", key.AccessKey)
+			}
+			switch scope.Access {
+			case Read, Write, ReadWrite:
+			default:
+				return nil, fmt.Errorf("access key %s has invalid access %q for bucket %s (want read, write or read-write)", key.AccessKey, scope.Access, scope.Bucket)
+			}
+		}
+		set.scopes[key.AccessKey] = key.Scopes
+	}
+	return set, nil
+}
+
+// Len returns the number of scoped access keys
+func (s *Set) Len() int {
+	return len(s.scopes)
+}
+
+// Scoped reports whether accessKey is restricted
+func (s *Set) Scoped(accessKey string) bool {
+	_, ok := s.scopes[accessKey]
+	return ok
+}
+
+// AllowObject checks that accessKey may read (or write) an object
+func (s *Set) AllowObject(accessKey, bucket, key string, write bool) error {
+	scopes, ok := s.scopes[accessKey]
+	if !ok {
+		return nil
+	}
+	for _, scope := range scopes {
+		if scope.Bucket == bucket && strings.HasPrefix(key, scope.Prefix) && scope.allows(write) {
+			return nil
+		}
+	}
+	return ErrOutOfScope
+}
+
+// AllowList checks that accessKey may list a bucket under prefix. The prefix
+// must lie inside a readable scope, so listings never reveal other keys.
+func (s *Set) AllowList(accessKey, bucket, prefix string) error {
+	return s.AllowObject(accessKey, bucket, prefix, false)
+}
+
+// AllowBucket checks that accessKey has any scope in bucket, which lets it
+// ask whether the bucket exists and where it is
+func (s *Set) AllowBucket(accessKey, bucket string) error {
+	scopes, ok := s.scopes[accessKey]
+	if !ok {
+		return nil
+	}
+	for _, scope := range scopes {
+		if scope.Bucket == bucket {
+			return nil
+		}
+	}
+	return ErrOutOfScope
+}
@@ -0,0 +1,88 @@
+package scopedkeys
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSet(t *testing.T) *Set {
+	set, err := New([]Key{
+		{AccessKey: "AKUPLOADER", Scopes: []Scope{{Bucket: "photos", Prefix: "uploads/", Access: Write}}},
+		{AccessKey: "AKREADER", Scopes: []Scope{
+			{Bucket: "photos", Prefix: "public/", Access: Read},
+			{Bucket: "logs", Access: ReadWrite},
+		}},
+	})
+	require.NoError(t, err)
+	return set
+}
+
+func TestAllowObject(t *testing.T) {
+	set := testSet(t)
+
+	tests := []struct {
+		accessKey, bucket, key string
+		write                  bool
+		allowed                bool
+	}{
+		{"AKUPLOADER", "photos", "uploads/cat.jpg", true, true},
+		{"AKUPLOADER", "photos", "uploads/cat.jpg", false, false},
+		{"AKUPLOADER", "photos", "public/cat.jpg", true, false},
+		{"AKUPLOADER", "logs", "uploads/app.log", true, false},
+		{"AKREADER", "photos", "public/cat.jpg", false, true},
+		{"AKREADER", "photos", "public/cat.jpg", true, false},
+		{"AKREADER", "logs", "anything", true, true},
+		{"AKOTHER", "private", "anything", true, true},
+	}
+	for _, tt := range tests {
+		err := set.AllowObject(tt.accessKey, tt.bucket, tt.key, tt.write)
+		if tt.allowed {
+			assert.NoError(t, err, "%s %s/%s write=%v", tt.accessKey, tt.bucket, tt.key, tt.write)
+		} else {
+			assert.ErrorIs(t, err, ErrOutOfScope, "%s %s/%s write=%v", tt.accessKey, tt.bucket, tt.key, tt.write)
+		}
+	}
+}
+
+func TestAllowListAndBucket(t *testing.T) {
+	set := testSet(t)
+
+	assert.NoError(t, set.AllowList("AKREADER", "photos", "public/2024/"))
+	assert.ErrorIs(t, set.AllowList("AKREADER", "photos", ""), ErrOutOfScope, "listing the bucket root would reveal other keys")
+	assert.ErrorIs(t, set.AllowList("AKUPLOADER", "photos", "uploads/"), ErrOutOfScope, "write-only keys cannot list")
+
+	assert.NoError(t, set.AllowBucket("AKUPLOADER", "photos"))
+	assert.ErrorIs(t, set.AllowBucket("AKUPLOADER", "logs"), ErrOutOfScope)
+	assert.True(t, set.Scoped("AKREADER"))
+	assert.False(t, set.Scoped("AKOTHER"))
+}
+
+func TestNewRejectsInvalidKeys(t *testing.T) {
+	for name, keys := range map[string][]Key{
+		"no access key": {{Scopes: []Scope{{Bucket: "b", Access: Read}}}},
+		"duplicate":     {{AccessKey: "AK", Scopes: []Scope{{Bucket: "b", Access: Read}}}, {AccessKey: "AK", Scopes: []Scope{{Bucket: "c", Access: Read}}}},
+		"no scopes":     {{AccessKey: "AK"}},
+		"no bucket":     {{AccessKey: "AK", Scopes: []Scope{{Access: Read}}}},
+		"bad access":    {{AccessKey: "AK", Scopes: []Scope{{Bucket: "b", Access: "admin"}}}},
+	} {
+		_, err := New(keys)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"keys": [{"access_key": "AK", "scopes": [{"bucket": "b", "prefix": "p/", "access": "read-write"}]}]}`), 0o600))
+
+	set, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, set.Len())
+	assert.NoError(t, set.AllowObject("AK", "b", "p/key", true))
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
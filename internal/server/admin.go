@@ -0,0 +1,245 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"s3-vault-proxy/internal/admin"
+	"s3-vault-proxy/internal/bucketlimits"
+	"s3-vault-proxy/internal/capture"
+	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/inflight"
+	"s3-vault-proxy/internal/kmsbindings"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/tenancy"
+	"s3-vault-proxy/internal/uploadlimit"
+	"s3-vault-proxy/internal/vault"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// operationalState is data-plane state observed and controlled through the admin API
+type operationalState struct {
+	readOnly atomic.Bool
+	buckets  *admin.BucketTracker
+	inflight *inflight.Tracker
+	tenants  *tenancy.Registry    // nil when tenancy is disabled
+	limits   *bucketlimits.Set    // nil without per-bucket limits
+	uploads  *uploadlimit.Limiter // nil without upload backpressure
+
+	kmsBindings *kmsbindings.Store // nil without KMS_BINDINGS_BUCKET
+}
+
+func newOperationalState(cfg *config.Config) *operationalState {
+	state := &operationalState{
+		buckets:  admin.NewBucketTracker(),
+		inflight: inflight.NewTracker(),
+	}
+	if cfg.MaxConcurrentUploads > 0 || cfg.MaxUploadBytes > 0 {
+		state.uploads = uploadlimit.NewLimiter(cfg.MaxConcurrentUploads, int64(cfg.MaxUploadBytes), cfg.UploadQueueTimeout)
+	}
+	state.readOnly.Store(cfg.ReadOnly)
+	if cfg.ReadOnly {
+		logging.Warn().Msg("Starting in read-only mode, writes will be rejected")
+	}
+	return state
+}
+
+// newAdminServer builds the admin listener and its operational endpoints
+func newAdminServer(cfg *config.Config, vaultClient transit, captureRecorder *capture.Recorder, state *operationalState) *admin.Server {
+	adminServer := admin.NewServer(cfg.AdminAddr)
+	adminServer.RequireToken(cfg.AdminToken)
+	if cfg.PprofEnabled {
+		adminServer.EnableProfiling()
+	}
+
+	adminServer.HandleFunc("/kms/keys", func(w http.ResponseWriter, r *http.Request) {
+		admin.WriteJSON(w, http.StatusOK, vault.KeyUsageReport())
+	})
+	if state.kmsBindings != nil {
+		adminServer.HandleFunc("/kms/bindings", kmsBindingsHandler(state.kmsBindings))
+	}
+	adminServer.HandleFunc("/buckets", func(w http.ResponseWriter, r *http.Request) {
+		admin.WriteJSON(w, http.StatusOK, state.buckets.Report())
+	})
+	if state.limits != nil {
+		adminServer.HandleFunc("/buckets/limits", func(w http.ResponseWriter, r *http.Request) {
+			admin.WriteJSON(w, http.StatusOK, state.limits.Report())
+		})
+	}
+	if state.tenants != nil {
+		adminServer.HandleFunc("/tenants", func(w http.ResponseWriter, r *http.Request) {
+			admin.WriteJSON(w, http.StatusOK, state.tenants.Report())
+		})
+	}
+	adminServer.HandleFunc("/read-only", readOnlyHandler(state))
+	adminServer.HandleFunc("/requests", func(w http.ResponseWriter, r *http.Request) {
+		admin.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"draining":  state.inflight.Draining(),
+			"in_flight": state.inflight.Snapshot(),
+		})
+	})
+	if captureRecorder != nil {
+		adminServer.HandleFunc("/debug/captures", capturesHandler(captureRecorder))
+	}
+	adminServer.HandleFunc("/debug/config", func(w http.ResponseWriter, r *http.Request) {
+		featureSet, _ := cfg.Features()
+		admin.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"config":             cfg.Redacted(),
+			"vault_address":      vaultClient.Address(),
+			"vault_token_source": vaultClient.TokenSource(),
+			"s3_backend":         cfg.S3Endpoint,
+			"kms_keys_seen":      vault.KeyUsageReport(),
+			"read_only":          state.readOnly.Load(),
+			"features":           featureSet.States(),
+		})
+	})
+	return adminServer
+}
+
+// readOnlyHandler reports (GET) or toggles (POST ?enabled=true|false) read-only mode
+func readOnlyHandler(state *operationalState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+			if err != nil {
+				admin.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "enabled must be true or false"})
+				return
+			}
+			if state.readOnly.Swap(enabled) != enabled {
+				logging.Warn().Bool("read_only", enabled).Msg("Read-only mode changed from the admin API")
+			}
+		default:
+			admin.WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, map[string]bool{"read_only": state.readOnly.Load()})
+	}
+}
+
+// kmsBindingsHandler shows (GET), replaces (PUT with {"kms_keys": [...]}) or
+// removes (DELETE) the KMS key binding of ?bucket=
+func kmsBindingsHandler(store *kmsbindings.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucket := r.URL.Query().Get("bucket")
+		if bucket == "" {
+			admin.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "bucket is required"})
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			binding, err := store.Get(r.Context(), bucket)
+			if err != nil {
+				admin.WriteJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+				return
+			}
+			if binding == nil {
+				admin.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "bucket has no KMS binding"})
+				return
+			}
+			admin.WriteJSON(w, http.StatusOK, binding)
+		case http.MethodPut:
+			var request kmsbindings.Binding
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.KMSKeys) == 0 {
+				admin.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": `body must be {"kms_keys": ["arn:aws:kms:..."]}`})
+				return
+			}
+			binding, err := store.Put(r.Context(), bucket, request.KMSKeys)
+			if err != nil {
+				admin.WriteJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+				return
+			}
+			logging.Warn().Str("bucket", bucket).Strs("kms_keys", binding.KMSKeys).Msg("KMS binding changed from the admin API")
+			admin.WriteJSON(w, http.StatusOK, binding)
+		case http.MethodDelete:
+			if err := store.Delete(r.Context(), bucket); err != nil {
+				admin.WriteJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+				return
+			}
+			logging.Warn().Str("bucket", bucket).Msg("KMS binding removed from the admin API")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			admin.WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	}
+}
+
+// capturesHandler lists (GET), arms (POST ?count=N&access_key=AK) or clears (DELETE) request captures
+func capturesHandler(recorder *capture.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			remaining, accessKeys := recorder.Status()
+			admin.WriteJSON(w, http.StatusOK, map[string]interface{}{
+				"remaining":   remaining,
+				"access_keys": accessKeys,
+				"captures":    recorder.List(),
+			})
+		case http.MethodPost:
+			count, err := strconv.Atoi(r.URL.Query().Get("count"))
+			if err != nil || count < 0 {
+				admin.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "count must be a non-negative integer"})
+				return
+			}
+			recorder.Arm(count, r.URL.Query()["access_key"]...)
+			logging.Info().Int("count", count).Strs("access_keys", r.URL.Query()["access_key"]).Msg("Request capture armed")
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			recorder.Clear()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			admin.WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	}
+}
+
+// signatureFailuresHandler lists (GET) or clears (DELETE) requests whose
+// signature the backend rejected
+func signatureFailuresHandler(recorder *capture.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			admin.WriteJSON(w, http.StatusOK, map[string]interface{}{"failures": recorder.List()})
+		case http.MethodDelete:
+			recorder.Clear()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			admin.WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	}
+}
+
+// readOnlyMiddleware rejects writes while read-only mode is enabled
+func readOnlyMiddleware(state *operationalState) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !state.readOnly.Load() {
+			return c.Next()
+		}
+		switch c.Method() {
+		case fiber.MethodPut, fiber.MethodPost, fiber.MethodDelete, fiber.MethodPatch:
+			return c.Status(fiber.StatusServiceUnavailable).XML(types.ErrorResponse{
+				Code:    "ServiceUnavailable",
+				Message: "The proxy is in read-only mode",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// bucketUsageMiddleware records the bucket of each S3 API request once routing has run
+func bucketUsageMiddleware(tracker *admin.BucketTracker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+		if strings.HasPrefix(c.Route().Path, "/:bucket") {
+			write := c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead
+			tracker.Observe(c.Params("bucket"), write)
+		}
+		return err
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"s3-vault-proxy/internal/admin"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/maintenance"
+	"s3-vault-proxy/internal/s3"
+)
+
+// Matches returned by one encryption query by default, and at most with ?limit=
+const (
+	defaultEncryptionQueryLimit = 1000
+	maxEncryptionQueryLimit     = 10000
+)
+
+// encryptionQueryHandler lists the objects of a bucket protected by a KMS key
+// or by no key at all
+// (GET ?bucket=B&kms_key=ARN&unencrypted=true&prefix=P&start_after=K&limit=L).
+// below_version is refused rather than ignored: the backend keeps the data
+// keys, so their transit key versions are not known.
+func encryptionQueryHandler(client s3.Interface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			admin.WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		params := r.URL.Query()
+		query := maintenance.EncryptionQuery{
+			Bucket:     params.Get("bucket"),
+			Prefix:     params.Get("prefix"),
+			StartAfter: params.Get("start_after"),
+			KMSKeyARN:  params.Get("kms_key"),
+			Limit:      defaultEncryptionQueryLimit,
+		}
+		if query.Bucket == "" {
+			admin.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "bucket is required"})
+			return
+		}
+		if params.Has("below_version") {
+			admin.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "below_version is not supported: the backend keeps the data keys, so their key versions are not known"})
+			return
+		}
+		if value := params.Get("unencrypted"); value != "" {
+			var err error
+			if query.Unencrypted, err = strconv.ParseBool(value); err != nil {
+				admin.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "unencrypted must be true or false"})
+				return
+			}
+		}
+		if query.Unencrypted && query.KMSKeyARN != "" {
+			admin.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "unencrypted cannot be combined with kms_key"})
+			return
+		}
+		if value := params.Get("limit"); value != "" {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > maxEncryptionQueryLimit {
+				admin.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be between 1 and " + strconv.Itoa(maxEncryptionQueryLimit)})
+				return
+			}
+			query.Limit = limit
+		}
+
+		result, err := maintenance.FindObjects(r.Context(), client, query)
+		if err != nil {
+			logging.Error().Err(err).Str("bucket", query.Bucket).Msg("Failed to query object encryption")
+			admin.WriteJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, result)
+	}
+}
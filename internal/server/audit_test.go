@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"s3-vault-proxy/internal/maintenance"
+	"s3-vault-proxy/internal/s3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptionQueryHandler(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/data":
+			w.Write([]byte(`<ListBucketResult>` +
+				`<Contents><Key>old.bin</Key><Size>12</Size></Contents>` +
+				`<Contents><Key>old.bin.metadata</Key><Size>80</Size></Contents>` +
+				`<Contents><Key>plain.txt</Key><Size>9</Size></Contents>` +
+				`</ListBucketResult>`))
+		case "/data/old.bin.metadata":
+			w.Write([]byte(`{"content_length":4,"kms_key_arn":"arn:aws:kms:us-east-1:123456789012:key/old"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer backend.Close()
+	handler := encryptionQueryHandler(s3.NewClient(backend.URL, "", s3.DefaultTransportConfig()))
+
+	get := func(target string) (*httptest.ResponseRecorder, maintenance.EncryptionQueryResult) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, target, nil))
+		var result maintenance.EncryptionQueryResult
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		}
+		return w, result
+	}
+
+	w, result := get("/objects/encryption?bucket=data&kms_key=arn:aws:kms:us-east-1:123456789012:key/old")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, result.Objects, 1)
+	assert.Equal(t, maintenance.EncryptedObject{
+		Key:           "old.bin",
+		PlaintextSize: 4,
+		KMSKeyARN:     "arn:aws:kms:us-east-1:123456789012:key/old",
+	}, result.Objects[0])
+	assert.Equal(t, int64(2), result.Scanned)
+
+	_, result = get("/objects/encryption?bucket=data&unencrypted=true")
+	require.Len(t, result.Objects, 1)
+	assert.Equal(t, "plain.txt", result.Objects[0].Key)
+
+	_, result = get("/objects/encryption?bucket=data&kms_key=arn:aws:kms:us-east-1:123456789012:key/new")
+	assert.Empty(t, result.Objects)
+
+	for _, target := range []string{
+		"/objects/encryption",
+		"/objects/encryption?bucket=data&below_version=2",
+		"/objects/encryption?bucket=data&unencrypted=yes",
+		"/objects/encryption?bucket=data&unencrypted=true&kms_key=k",
+		"/objects/encryption?bucket=data&limit=0",
+	} {
+		w, _ := get(target)
+		assert.Equal(t, http.StatusBadRequest, w.Code, target)
+	}
+	w, _ = get("/objects/encryption?bucket=missing")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"errors"
+	"strings"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/uploadlimit"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// uploadLimitMiddleware holds each upload until the limiter admits it, so a
+// burst of large uploads is worked through a few at a time instead of having
+// all their bodies encrypted in memory at once. Uploads still waiting when
+// the queue timeout passes are answered with SlowDown, which SDKs retry with
+// backoff.
+func uploadLimitMiddleware(limiter *uploadlimit.Limiter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		_, key, _ := strings.Cut(strings.TrimPrefix(c.Path(), "/"), "/")
+		if c.Method() != fiber.MethodPut || key == "" || s3.ObjectSubresource(string(c.Request().URI().QueryString())) != "" {
+			return c.Next()
+		}
+
+		size := c.Get("X-Amz-Decoded-Content-Length")
+		if size == "" {
+			size = c.Get("Content-Length")
+		}
+		release, err := limiter.Acquire(c.UserContext(), parseSize(size, -1))
+		if err != nil {
+			logging.FromContext(c.UserContext()).Info().Err(err).Msg("Upload rejected by upload limiter")
+			if !errors.Is(err, uploadlimit.ErrBusy) {
+				// The request was abandoned while it waited
+				return err
+			}
+			c.Set(fiber.HeaderConnection, "close")
+			return c.Status(fiber.StatusServiceUnavailable).XML(types.ErrorResponse{
+				Code:    "SlowDown",
+				Message: "Please reduce your request rate.",
+			})
+		}
+		defer release()
+		return c.Next()
+	}
+}
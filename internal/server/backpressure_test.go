@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"s3-vault-proxy/internal/uploadlimit"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadLimitMiddleware(t *testing.T) {
+	limiter := uploadlimit.NewLimiter(1, 0, 0)
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(uploadLimitMiddleware(limiter))
+	app.Put("/:bucket/*", func(c *fiber.Ctx) error { return c.SendStatus(http.StatusOK) })
+	app.Put("/:bucket", func(c *fiber.Ctx) error { return c.SendStatus(http.StatusOK) })
+
+	put := func(path string) (int, string) {
+		resp, err := app.Test(httptest.NewRequest("PUT", path, strings.NewReader("data")))
+		require.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	status, _ := put("/bucket/key")
+	assert.Equal(t, http.StatusOK, status)
+	uploads, _ := limiter.InProgress()
+	assert.Equal(t, 0, uploads, "finished uploads release their capacity")
+
+	release, err := limiter.Acquire(context.Background(), 4)
+	require.NoError(t, err)
+	status, body := put("/bucket/key")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Contains(t, body, "<Code>SlowDown</Code>")
+
+	status, _ = put("/bucket/key?tagging")
+	assert.Equal(t, http.StatusOK, status, "sub-resources are not uploads")
+	status, _ = put("/bucket")
+	assert.Equal(t, http.StatusOK, status, "bucket requests are not uploads")
+
+	release()
+	status, _ = put("/bucket/key")
+	assert.Equal(t, http.StatusOK, status)
+}
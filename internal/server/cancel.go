@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/timeouts"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var requestsCancelledTotal = metrics.NewCounter(
+	"s3_vault_proxy_requests_cancelled_total",
+	"Requests whose backend and Vault work was cancelled before they finished, by reason (client_gone, deadline, shutdown).",
+	"reason",
+)
+
+var (
+	errClientGone       = errors.New("client disconnected")
+	errRequestDeadline  = errors.New("request exceeded its read and write timeouts")
+	errShutdownDeadline = errors.New("request cut off by shutdown")
+)
+
+// requestContextMiddleware gives each request a context that is cancelled
+// when the client disconnects, when the request outlives its read and write
+// timeouts, or when shutdown gives up on it. Handlers pass it to the backend
+// and Vault so abandoned requests stop consuming them. The context lasts until
+// the response, including a streamed body, has been written.
+func requestContextMiddleware(operation timeouts.Config, readTimeout, writeTimeout, checkInterval time.Duration, shutdown context.Context) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithCancelCause(c.UserContext())
+		stopShutdown := context.AfterFunc(shutdown, func() { cancel(errShutdownDeadline) })
+
+		cancelDeadline := context.CancelFunc(func() {})
+		if deadline := requestDeadline(operation, readTimeout, writeTimeout, c); deadline > 0 {
+			ctx, cancelDeadline = context.WithTimeoutCause(ctx, deadline, errRequestDeadline)
+		}
+
+		// A streamed request body is read during the handler, which a peek at
+		// the connection would contend with
+		stopWatch := func() {}
+		if checkInterval > 0 && !c.Request().IsBodyStream() {
+			stopWatch = watchDisconnect(c.Context().Conn(), checkInterval, cancel)
+		}
+
+		method, path := strings.Clone(c.Method()), strings.Clone(c.Path())
+		c.Context().SetUserValue(requestContextKey{}, releaseFunc(func() {
+			stopWatch()
+			stopShutdown()
+			if ctx.Err() != nil {
+				recordCancellation(ctx, method, path)
+			}
+			cancelDeadline()
+			cancel(nil)
+		}))
+
+		c.SetUserContext(ctx)
+		err := c.Next()
+		// Once the handler returns nothing reads the connection until the next
+		// request, and a client leaving mid-response fails the write instead
+		stopWatch()
+		return err
+	}
+}
+
+type requestContextKey struct{}
+
+// requestCutoff is cancelled when shutdown stops waiting for the requests
+// still in flight
+type requestCutoff struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newRequestCutoff() *requestCutoff {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &requestCutoff{ctx: ctx, cancel: cancel}
+}
+
+// releaseFunc runs when fasthttp discards a request's user values, which is
+// after the response has been written or the connection has failed
+type releaseFunc func()
+
+func (f releaseFunc) Close() error {
+	f()
+	return nil
+}
+
+// requestDeadline returns the longest a request may take, its read and write
+// timeouts back to back, or 0 when either is unlimited
+func requestDeadline(operation timeouts.Config, readTimeout, writeTimeout time.Duration, c *fiber.Ctx) time.Duration {
+	if timeout := operation.For(c.Request().Header.Method(), c.Request().Header.RequestURI()); timeout > 0 {
+		readTimeout, writeTimeout = timeout, timeout
+	}
+	if readTimeout <= 0 || writeTimeout <= 0 {
+		return 0
+	}
+	return readTimeout + writeTimeout
+}
+
+// watchDisconnect checks conn every interval and cancels with errClientGone
+// once the client has closed it. The returned function stops the checks.
+func watchDisconnect(conn net.Conn, interval time.Duration, cancel context.CancelCauseFunc) func() {
+	var (
+		mu      sync.Mutex
+		stopped bool
+		timer   *time.Timer
+	)
+	mu.Lock()
+	defer mu.Unlock()
+	timer = time.AfterFunc(interval, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+		if peerClosed(conn) {
+			cancel(errClientGone)
+			return
+		}
+		timer.Reset(interval)
+	})
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		timer.Stop()
+	}
+}
+
+func recordCancellation(ctx context.Context, method, path string) {
+	cause := context.Cause(ctx)
+	reason := "client_gone"
+	switch {
+	case errors.Is(cause, errRequestDeadline):
+		reason = "deadline"
+	case errors.Is(cause, errShutdownDeadline):
+		reason = "shutdown"
+	}
+	requestsCancelledTotal.Inc(reason)
+	logging.Info().
+		Str("method", method).
+		Str("path", path).
+		Str("reason", reason).
+		Msg("Request cancelled")
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/inflight"
+	"s3-vault-proxy/internal/timeouts"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestContextMiddleware(t *testing.T) {
+	cutoff := newRequestCutoff()
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(requestContextMiddleware(timeouts.Config{Metadata: 20 * time.Millisecond}, time.Minute, time.Minute, 0, cutoff.ctx))
+
+	var causes = make(chan error, 1)
+	app.Head("/:bucket/*", func(c *fiber.Ctx) error {
+		<-c.UserContext().Done()
+		causes <- context.Cause(c.UserContext())
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Get("/:bucket/*", func(c *fiber.Ctx) error {
+		deadline, ok := c.UserContext().Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(2*time.Minute), deadline, time.Second)
+		<-c.UserContext().Done()
+		causes <- context.Cause(c.UserContext())
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	// Metadata operations expire after their read and write timeouts
+	before := requestsCancelledTotal.Value("deadline")
+	_, err := app.Test(httptest.NewRequest("HEAD", "/bucket/key", nil))
+	require.NoError(t, err)
+	assert.ErrorIs(t, <-causes, errRequestDeadline)
+	assert.Eventually(t, func() bool { return requestsCancelledTotal.Value("deadline") == before+1 }, time.Second, 10*time.Millisecond)
+
+	// Downloads use the server timeouts and are cut off by shutdown
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cutoff.cancel()
+	}()
+	_, err = app.Test(httptest.NewRequest("GET", "/bucket/key", nil))
+	require.NoError(t, err)
+	assert.ErrorIs(t, <-causes, errShutdownDeadline)
+}
+
+func TestRequestDeadline(t *testing.T) {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(func(c *fiber.Ctx) error {
+		operation := timeouts.Config{Upload: time.Hour}
+		c.Set("X-Unlimited", requestDeadline(operation, 0, time.Minute, c).String())
+		c.Set("X-Deadline", requestDeadline(operation, time.Minute, 30*time.Second, c).String())
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/bucket/key", nil))
+	require.NoError(t, err)
+	assert.Equal(t, "0s", resp.Header.Get("X-Unlimited"))
+	assert.Equal(t, "1m30s", resp.Header.Get("X-Deadline"))
+
+	resp, err = app.Test(httptest.NewRequest("PUT", "/bucket/key", nil))
+	require.NoError(t, err)
+	assert.Equal(t, "2h0m0s", resp.Header.Get("X-Unlimited"))
+}
+
+func TestStreamedResponseKeepsContext(t *testing.T) {
+	tracker := inflight.NewTracker()
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(inflightMiddleware(tracker))
+	app.Use(requestContextMiddleware(timeouts.Config{}, 0, 0, 0, context.Background()))
+	app.Get("/", func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			assert.NoError(t, ctx.Err(), "the context outlives the handler while the body streams")
+			w.WriteString("streamed body")
+		})
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "streamed body", string(body))
+	assert.Eventually(t, func() bool { return tracker.Count() == 0 }, time.Second, 10*time.Millisecond)
+}
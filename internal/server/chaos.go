@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net"
+	"time"
+
+	"s3-vault-proxy/internal/chaos"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// chaosMiddleware injects latency, Vault outages and truncated responses into
+// S3 requests. Backend resets are injected by chaos.Backend.
+func chaosMiddleware(injector *chaos.Injector) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Path() {
+		case "/health", "/health/dependencies", "/ready", "/metrics", "/version":
+			return c.Next()
+		}
+		if injector.Inject(chaos.FaultLatency) {
+			time.Sleep(injector.Latency())
+		}
+		if injector.Inject(chaos.FaultVaultUnavailable) {
+			return c.Status(fiber.StatusServiceUnavailable).XML(types.ErrorResponse{
+				Code:    "ServiceUnavailable",
+				Message: "Unable to reach Vault (injected fault)",
+			})
+		}
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if c.Method() != fiber.MethodHead && !c.Response().IsBodyStream() && len(c.Response().Body()) > 1 && injector.Inject(chaos.FaultTruncate) {
+			truncateResponse(c)
+		}
+		return nil
+	}
+}
+
+// truncateResponse sends the response headers, including the full
+// Content-Length, and half of the body, then closes the connection
+func truncateResponse(c *fiber.Ctx) {
+	body := append([]byte(nil), c.Response().Body()...)
+	var header fasthttp.ResponseHeader
+	c.Response().Header.CopyTo(&header)
+	header.SetContentLength(len(body))
+
+	c.Context().HijackSetNoResponse(true)
+	c.Context().Hijack(func(conn net.Conn) {
+		_, _ = conn.Write(header.Header())
+		_, _ = conn.Write(body[:len(body)/2])
+	})
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"s3-vault-proxy/internal/chaos"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func chaosTestApp(config chaos.Config) *fiber.App {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(chaosMiddleware(chaos.New(config)))
+	app.Get("/health", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	app.Get("/bucket/key", func(c *fiber.Ctx) error { return c.SendString(strings.Repeat("x", 100)) })
+	return app
+}
+
+func TestChaosVaultUnavailable(t *testing.T) {
+	app := chaosTestApp(chaos.Config{VaultErrorRatio: 1})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/bucket/key", nil))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Contains(t, string(body), "<Code>ServiceUnavailable</Code>")
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/health", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "health checks are never failed")
+}
+
+func TestChaosTruncate(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	app := chaosTestApp(chaos.Config{TruncateRatio: 1})
+	go func() { _ = app.Listener(ln) }()
+	defer app.Shutdown()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/bucket/key")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(100), resp.ContentLength)
+	body, err := io.ReadAll(resp.Body)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Len(t, body, 50)
+}
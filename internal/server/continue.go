@@ -0,0 +1,140 @@
+package server
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/sigv4"
+	"s3-vault-proxy/internal/tenancy"
+	"s3-vault-proxy/internal/vault"
+
+	"github.com/valyala/fasthttp"
+)
+
+var expectContinueRejectionsTotal = metrics.NewCounter(
+	"s3_vault_proxy_expect_continue_rejections_total",
+	"Requests with Expect: 100-continue refused with 417 before their body was sent.",
+	"reason",
+)
+
+// continueHandler answers "Expect: 100-continue" with 100 Continue only for
+// requests the proxy would accept from their headers alone. Others get 417
+// Expectation Failed and their connection is closed, so clients never send a
+// body that would be discarded. fasthttp sends no error body with the 417.
+func continueHandler(state *operationalState, vaultClient vault.Interface, bodyLimit int, defaultKMSKeyARN string) func(*fasthttp.RequestHeader) bool {
+	return func(header *fasthttp.RequestHeader) bool {
+		reason := continueRejection(state, vaultClient, bodyLimit, defaultKMSKeyARN, header)
+		if reason == "" {
+			return true
+		}
+		expectContinueRejectionsTotal.Inc(reason)
+		logging.Info().
+			Str("method", string(header.Method())).
+			Str("uri", string(header.RequestURI())).
+			Str("reason", reason).
+			Msg("Refused Expect: 100-continue request")
+		header.SetConnectionClose()
+		return false
+	}
+}
+
+// continueRejection returns why a request would be rejected once its body
+// arrived, or "" when it may be sent. It repeats the header checks of the
+// middleware and PutObject without consuming rate limits.
+func continueRejection(state *operationalState, vaultClient vault.Interface, bodyLimit int, defaultKMSKeyARN string, header *fasthttp.RequestHeader) string {
+	method := string(header.Method())
+	if method != fasthttp.MethodPut && method != fasthttp.MethodPost {
+		return ""
+	}
+	if state.readOnly.Load() {
+		return "read_only"
+	}
+	if bodyLimit > 0 && header.ContentLength() > bodyLimit {
+		return "body_too_large"
+	}
+
+	uri, err := url.ParseRequestURI(string(header.RequestURI()))
+	if err != nil {
+		return "invalid_uri"
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(uri.Path, "/"), "/")
+
+	accessKey, _, _ := strings.Cut(uri.Query().Get("X-Amz-Credential"), "/")
+	if authorization := headerValue(header, "Authorization"); authorization != "" {
+		parsed, err := sigv4.ParseAuthorization(authorization)
+		if err != nil {
+			return "malformed_authorization"
+		}
+		accessKey = parsed.AccessKey
+	}
+
+	var tenant *tenancy.Tenant
+	if state.tenants != nil {
+		if tenant, err = state.tenants.Resolve(accessKey, bucket); err != nil {
+			return "tenant_denied"
+		}
+	}
+	if method != fasthttp.MethodPut || key == "" || s3.ObjectSubresource(uri.RawQuery) != "" {
+		return ""
+	}
+	size := headerValue(header, "X-Amz-Decoded-Content-Length")
+	if size == "" {
+		size = headerValue(header, "Content-Length")
+	}
+	if err := state.limits.Check(bucket, key, parseSize(size, -1)); err != nil {
+		return "bucket_limit"
+	}
+	operation := s3.SSEUpload
+	if uri.Query().Has("uploadId") {
+		operation = s3.SSEPart
+	}
+	sse, err := s3.ParseSSEHeaders(header)
+	if err == nil {
+		err = sse.Check(operation)
+	}
+	if err != nil {
+		return "invalid_sse_headers"
+	}
+	if operation == s3.SSEPart {
+		// Parts are encrypted under the key their upload was created with
+		return ""
+	}
+
+	kmsKeyARN := sse.KMSKeyID
+	if kmsKeyARN == "" {
+		kmsKeyARN = defaultKMSKeyARN
+	}
+	if kmsKeyARN == "" {
+		return "missing_kms_key"
+	}
+	transitKey, err := vaultClient.ARNToVaultKey(kmsKeyARN)
+	if err != nil {
+		return "invalid_kms_key"
+	}
+	if tenant == nil {
+		return ""
+	}
+	if err := tenant.Authorize(bucket, kmsKeyARN, transitKey, true); errors.Is(err, tenancy.ErrKeyNotAllowed) {
+		return "kms_key_denied"
+	}
+	if err := tenant.CheckQuota(parseSize(size, 0)); err != nil {
+		return "quota_exceeded"
+	}
+	return ""
+}
+
+// headerValue looks a request header up case-insensitively, since header
+// names are not normalized to keep signed requests intact
+func headerValue(header *fasthttp.RequestHeader, name string) string {
+	var value string
+	header.VisitAll(func(key, v []byte) {
+		if value == "" && strings.EqualFold(string(key), name) {
+			value = string(v)
+		}
+	})
+	return value
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"s3-vault-proxy/internal/bucketlimits"
+	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/tenancy"
+	"s3-vault-proxy/internal/vault"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+const continueTestARN = "arn:aws:kms:us-east-1:123456789012:key/12345678-1234-1234-1234-123456789012"
+
+func continueHeader(t *testing.T, method, uri string, headers map[string]string) *fasthttp.RequestHeader {
+	var header fasthttp.RequestHeader
+	header.DisableNormalizing()
+	raw := method + " " + uri + " HTTP/1.1\r\nHost: proxy\r\n"
+	for name, value := range headers {
+		raw += name + ": " + value + "\r\n"
+	}
+	require.NoError(t, header.Read(bufio.NewReader(strings.NewReader(raw+"\r\n"))))
+	return &header
+}
+
+func TestContinueRejection(t *testing.T) {
+	state := newOperationalState(&config.Config{})
+	vaultClient := new(vault.Client)
+	kms := map[string]string{"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": continueTestARN, "Content-Length": "100"}
+
+	assert.Equal(t, "", continueRejection(state, vaultClient, 0, "", continueHeader(t, "PUT", "/bucket/key", kms)))
+	assert.Equal(t, "", continueRejection(state, vaultClient, 0, "", continueHeader(t, "PUT", "/bucket", nil)), "bucket requests carry no KMS key")
+	assert.Equal(t, "missing_kms_key", continueRejection(state, vaultClient, 0, "", continueHeader(t, "PUT", "/bucket/key", nil)))
+	assert.Equal(t, "", continueRejection(state, vaultClient, 0, "", continueHeader(t, "PUT", "/bucket/key?tagging", nil)), "sub-resources carry no object data")
+	assert.Equal(t, "", continueRejection(state, vaultClient, 0, "", continueHeader(t, "PUT", "/bucket/key?partNumber=1&uploadId=abc", nil)), "parts use their upload's key")
+	assert.Equal(t, "", continueRejection(state, vaultClient, 0, continueTestARN, continueHeader(t, "PUT", "/bucket/key", nil)), "the default key stands in for a missing header")
+	assert.Equal(t, "missing_kms_key", continueRejection(state, vaultClient, 0, "", continueHeader(t, "PUT", "/bucket/key", map[string]string{"x-amz-server-side-encryption-aws-kms-key-id": ""})))
+	assert.Equal(t, "", continueRejection(state, vaultClient, 0, "", continueHeader(t, "PUT", "/bucket/key", map[string]string{"x-amz-server-side-encryption-aws-kms-key-id": continueTestARN})), "header names are matched case-insensitively")
+	assert.Equal(t, "invalid_kms_key", continueRejection(state, vaultClient, 0, "", continueHeader(t, "PUT", "/bucket/key", map[string]string{"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": "not-an-arn"})))
+	assert.Equal(t, "invalid_sse_headers", continueRejection(state, vaultClient, 0, "", continueHeader(t, "PUT", "/bucket/key", map[string]string{"X-Amz-Server-Side-Encryption": "AES256"})))
+	assert.Equal(t, "invalid_sse_headers", continueRejection(state, vaultClient, 0, "", continueHeader(t, "PUT", "/bucket/key?partNumber=1&uploadId=abc", map[string]string{"X-Amz-Server-Side-Encryption-Customer-Algorithm": "AES256"})))
+	assert.Equal(t, "malformed_authorization", continueRejection(state, vaultClient, 0, "", continueHeader(t, "PUT", "/bucket/key", map[string]string{"Authorization": "Basic Zm9vOmJhcg=="})))
+	assert.Equal(t, "body_too_large", continueRejection(state, vaultClient, 50, "", continueHeader(t, "PUT", "/bucket/key", kms)))
+
+	state.readOnly.Store(true)
+	assert.Equal(t, "read_only", continueRejection(state, vaultClient, 0, "", continueHeader(t, "PUT", "/bucket/key", kms)))
+	assert.Equal(t, "", continueRejection(state, vaultClient, 0, "", continueHeader(t, "GET", "/bucket/key", nil)))
+}
+
+func TestContinueRejectionTenants(t *testing.T) {
+	tenants, err := tenancy.New([]*tenancy.Tenant{{
+		Name:              "team-a",
+		AccessKeys:        []string{"AKIATEAMA"},
+		BucketPrefixes:    []string{"team-a-"},
+		KMSKeys:           []string{continueTestARN},
+		StorageQuotaBytes: 1000,
+	}})
+	require.NoError(t, err)
+	state := newOperationalState(&config.Config{})
+	state.tenants = tenants
+	vaultClient := new(vault.Client)
+	auth := "AWS4-HMAC-SHA256 Credential=AKIATEAMA/20240101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc"
+
+	header := func(bucket, arn, size string) *fasthttp.RequestHeader {
+		return continueHeader(t, "PUT", "/"+bucket+"/key", map[string]string{
+			"Authorization": auth,
+			"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": arn,
+			"Content-Length": size,
+		})
+	}
+	assert.Equal(t, "", continueRejection(state, vaultClient, 0, "", header("team-a-data", continueTestARN, "100")))
+	assert.Equal(t, "tenant_denied", continueRejection(state, vaultClient, 0, "", header("team-b-data", continueTestARN, "100")))
+	assert.Equal(t, "kms_key_denied", continueRejection(state, vaultClient, 0, "", header("team-a-data", strings.Replace(continueTestARN, "1234-1234-1234", "4321-4321-4321", 1), "100")))
+	assert.Equal(t, "quota_exceeded", continueRejection(state, vaultClient, 0, "", header("team-a-data", continueTestARN, "5000")))
+}
+
+func TestContinueHandlerRefusesBody(t *testing.T) {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true, DisableHeaderNormalizing: true})
+	app.Server().ContinueHandler = continueHandler(newOperationalState(&config.Config{}), new(vault.Client), 0, "")
+	handled := false
+	app.Put("/:bucket/*", func(c *fiber.Ctx) error {
+		handled = true
+		return c.SendStatus(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("PUT", "/bucket/key", strings.NewReader("body"))
+	req.Header.Set("Expect", "100-continue")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusExpectationFailed, resp.StatusCode)
+	assert.False(t, handled)
+
+	req = httptest.NewRequest("PUT", "/bucket/key", strings.NewReader("body"))
+	req.Header.Set("Expect", "100-continue")
+	req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", continueTestARN)
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	// app.Test returns the first response on the connection, here the interim one
+	assert.Equal(t, http.StatusContinue, resp.StatusCode)
+}
+
+func TestContinueRejectionBucketLimits(t *testing.T) {
+	limits, err := bucketlimits.Parse(map[string]string{"small": "50"}, nil, nil)
+	require.NoError(t, err)
+	state := newOperationalState(&config.Config{})
+	state.limits = limits
+	vaultClient := new(vault.Client)
+	kms := map[string]string{"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": continueTestARN, "Content-Length": "100"}
+
+	assert.Equal(t, "bucket_limit", continueRejection(state, vaultClient, 0, "", continueHeader(t, "PUT", "/small/key", kms)))
+	assert.Equal(t, "", continueRejection(state, vaultClient, 0, "", continueHeader(t, "PUT", "/large/key", kms)))
+}
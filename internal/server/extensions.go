@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"s3-vault-proxy/internal/extensions"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/sigv4"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var extensionDecisionsTotal = metrics.NewCounter(
+	"s3_vault_proxy_extension_decisions_total",
+	"S3 requests inspected by WASM extensions, by result (allowed, denied, error).",
+	"result",
+)
+
+// extensionsMiddleware runs the WASM extensions on every S3 request. Denied
+// requests are answered with the extension's error, and failing extensions
+// refuse the request rather than let it through unchecked.
+func extensionsMiddleware(inspector extensions.Inspector) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Path() {
+		case "/health", "/health/dependencies", "/ready", "/metrics", "/version":
+			return c.Next()
+		}
+
+		headers := http.Header{}
+		c.Request().Header.VisitAll(func(key, value []byte) {
+			headers[string(key)] = append(headers[string(key)], string(value))
+		})
+		decision, err := inspector.Inspect(c.UserContext(), extensions.Request{
+			Method:  c.Method(),
+			Path:    string(c.Request().URI().PathOriginal()),
+			Query:   string(c.Request().URI().QueryString()),
+			Headers: headers,
+		})
+		if err != nil {
+			extensionDecisionsTotal.Inc("error")
+			logging.FromContext(c.UserContext()).Error().Err(err).Msg("Request extension failed")
+			return c.Status(fiber.StatusInternalServerError).XML(types.ErrorResponse{
+				Code:    "InternalError",
+				Message: "A request extension failed",
+			})
+		}
+		if decision.Deny {
+			extensionDecisionsTotal.Inc("denied")
+			logging.FromContext(c.UserContext()).Info().Str("extension", decision.Module).Str("method", c.Method()).Str("path", c.Path()).Msg("Request denied by extension")
+			return c.Status(decision.StatusCode()).XML(types.ErrorResponse{
+				Code:    decision.ErrorCode(),
+				Message: decision.Message,
+			})
+		}
+
+		for name, value := range decision.SetHeaders {
+			if !rewritableHeader(c, headers, name) {
+				logging.FromContext(c.UserContext()).Warn().Str("header", name).Msg("Extension may not set a header the request signature may cover")
+				continue
+			}
+			c.Request().Header.Set(name, value)
+		}
+		extensionDecisionsTotal.Inc("allowed")
+		return c.Next()
+	}
+}
+
+// rewritableHeader reports whether an extension may set a request header:
+// changing one the client signed would make the backend reject the request.
+// SigV2 signs Content-MD5, Content-Type, Date and x-amz- headers, which stay
+// off limits whatever the request's signature.
+func rewritableHeader(c *fiber.Ctx, headers http.Header, name string) bool {
+	lower := strings.ToLower(name)
+	switch {
+	case lower == "authorization", lower == "host", lower == "date",
+		strings.HasPrefix(lower, "content-"), strings.HasPrefix(lower, "x-amz-"):
+		return false
+	case sigv4.IsSignedHeader(headers, name):
+		return false
+	}
+	for _, signed := range strings.Split(c.Query("X-Amz-SignedHeaders"), ";") {
+		if strings.EqualFold(signed, name) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"s3-vault-proxy/internal/extensions"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeInspector answers every request with one decision
+type fakeInspector struct {
+	decision *extensions.Decision
+	err      error
+}
+
+func (f fakeInspector) Inspect(ctx context.Context, req extensions.Request) (*extensions.Decision, error) {
+	return f.decision, f.err
+}
+
+func (f fakeInspector) Close(ctx context.Context) error {
+	return nil
+}
+
+func TestExtensionsMiddleware(t *testing.T) {
+	do := func(inspector extensions.Inspector, target string) (*http.Response, string) {
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		app.Use(extensionsMiddleware(inspector))
+		app.Use(func(c *fiber.Ctx) error {
+			return c.SendString(c.Get("X-Team") + "|" + c.Get("X-Amz-Meta-Team") + "|" + c.Get("X-Signed"))
+		})
+		req := httptest.NewRequest("PUT", target, nil)
+		req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/s3/aws4_request, SignedHeaders=host;x-signed, Signature=abc")
+		req.Header.Set("X-Signed", "client")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, body := do(fakeInspector{decision: &extensions.Decision{SetHeaders: map[string]string{
+		"X-Team":          "storage",
+		"X-Amz-Meta-Team": "storage",
+		"X-Signed":        "extension",
+	}}}, "/bucket/key")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "storage||client", body, "signed and x-amz- headers are left alone")
+
+	resp, body = do(fakeInspector{decision: &extensions.Decision{Deny: true, Message: "frozen"}}, "/bucket/key")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Contains(t, body, "<Code>AccessDenied</Code>")
+	assert.Contains(t, body, "frozen")
+
+	resp, _ = do(fakeInspector{err: errors.New("trap")}, "/bucket/key")
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode, "failing extensions refuse requests")
+
+	resp, _ = do(fakeInspector{err: errors.New("trap")}, "/health")
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "health checks are not inspected")
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/maintenance"
+	"s3-vault-proxy/internal/s3"
+)
+
+// inventoryRate bounds the objects per second a scheduled inventory reads so
+// reports don't compete with client traffic
+const inventoryRate = 50
+
+// inventorySchedule writes inventory reports of INVENTORY_BUCKETS every
+// INVENTORY_INTERVAL with the operator credentials
+type inventorySchedule struct {
+	client     s3.Interface
+	buckets    []string
+	destBucket string
+	destPrefix string
+	kmsKeyARN  string
+	interval   time.Duration
+
+	mu     sync.Mutex // serializes scheduled and admin-triggered runs
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newInventorySchedule(cfg *config.Config) (*inventorySchedule, error) {
+	credentials, err := cfg.OperatorCredentials()
+	if err != nil {
+		return nil, err
+	}
+	client, err := s3.NewSigningClient(NewBackend(cfg), cfg.S3Endpoint, credentials)
+	if err != nil {
+		return nil, err
+	}
+	destBucket, destPrefix, _ := strings.Cut(cfg.InventoryDestination, "/")
+	return &inventorySchedule{
+		client:     client,
+		buckets:    cfg.InventoryBuckets,
+		destBucket: destBucket,
+		destPrefix: destPrefix,
+		kmsKeyARN:  cfg.InventoryKMSKeyARN,
+		interval:   cfg.InventoryInterval,
+	}, nil
+}
+
+// Run writes a report of every bucket and returns their manifest keys
+func (s *inventorySchedule) Run(ctx context.Context) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	manifests := make(map[string]string, len(s.buckets))
+	for _, bucket := range s.buckets {
+		manifestKey, err := maintenance.Inventory(ctx, s.client, maintenance.InventoryOptions{
+			Bucket:     bucket,
+			DestBucket: s.destBucket,
+			DestPrefix: s.destPrefix,
+			KMSKeyARN:  s.kmsKeyARN,
+			Rate:       inventoryRate,
+		})
+		if err != nil {
+			return manifests, err
+		}
+		manifests[bucket] = manifestKey
+	}
+	return manifests, nil
+}
+
+// Start runs the inventory every interval until Stop
+func (s *inventorySchedule) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			manifests, err := s.Run(ctx)
+			if err != nil && ctx.Err() == nil {
+				logging.Error().Err(err).Msg("Scheduled inventory failed")
+				continue
+			}
+			logging.Info().Interface("manifests", manifests).Msg("Wrote inventory reports")
+		}
+	}()
+}
+
+// Stop cancels a running inventory and waits for the schedule to exit
+func (s *inventorySchedule) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"s3-vault-proxy/internal/bucketlimits"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var bucketLimitRejectionsTotal = metrics.NewCounter(
+	"s3_vault_proxy_bucket_limit_rejections_total",
+	"Uploads rejected because of a per-bucket limit.",
+	"limit",
+)
+
+// bucketLimitsMiddleware rejects uploads over their bucket's maximum object
+// size or key length, or to a bucket holding its maximum number of keys.
+// Multipart parts are checked like uploads, but only the completion of their
+// upload adds a key.
+func bucketLimitsMiddleware(limits *bucketlimits.Set) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(c.Path(), "/"), "/")
+		part := c.Query("uploadId") != ""
+		if c.Method() == fiber.MethodPost && key != "" && part {
+			// Completing a multipart upload creates its object
+			err := c.Next()
+			if c.Response().StatusCode() < 300 {
+				limits.AddKey(bucket)
+			}
+			return err
+		}
+		if c.Method() != fiber.MethodPut || key == "" || s3.ObjectSubresource(string(c.Request().URI().QueryString())) != "" {
+			return c.Next()
+		}
+
+		size := c.Get("X-Amz-Decoded-Content-Length")
+		if size == "" {
+			size = c.Get("Content-Length")
+		}
+		if err := limits.Check(bucket, key, parseSize(size, -1)); err != nil {
+			return bucketLimitError(c, bucket, err)
+		}
+
+		err := c.Next()
+		if c.Response().StatusCode() < 300 && !part {
+			limits.AddKey(bucket)
+		}
+		return err
+	}
+}
+
+// bucketLimitError answers an upload rejected by limit with S3's error for it
+func bucketLimitError(c *fiber.Ctx, bucket string, limit error) error {
+	logging.Info().Err(limit).Str("bucket", bucket).Msg("Upload rejected by bucket limit")
+	switch {
+	case errors.Is(limit, bucketlimits.ErrObjectTooLarge):
+		bucketLimitRejectionsTotal.Inc("object_size")
+		return c.Status(fiber.StatusBadRequest).XML(types.ErrorResponse{
+			Code:    "EntityTooLarge",
+			Message: "Your proposed upload exceeds the maximum allowed size",
+		})
+	case errors.Is(limit, bucketlimits.ErrKeyTooLong):
+		bucketLimitRejectionsTotal.Inc("key_length")
+		return c.Status(fiber.StatusBadRequest).XML(types.ErrorResponse{
+			Code:    "KeyTooLongError",
+			Message: "Your key is too long",
+		})
+	default:
+		bucketLimitRejectionsTotal.Inc("keys")
+		return c.Status(fiber.StatusForbidden).XML(types.ErrorResponse{
+			Code:    "QuotaExceeded",
+			Message: "The bucket holds its maximum number of objects",
+		})
+	}
+}
+
+// refreshBucketKeys recounts the keys of buckets with a maximum number of keys every interval
+func refreshBucketKeys(limits *bucketlimits.Set, client s3.Interface, interval time.Duration) {
+	for {
+		if err := limits.RefreshKeys(context.Background(), client); err != nil {
+			logging.Warn().Err(err).Msg("Failed to count bucket keys")
+		}
+		time.Sleep(interval)
+	}
+}
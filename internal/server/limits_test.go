@@ -0,0 +1,56 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"s3-vault-proxy/internal/bucketlimits"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketLimitsMiddleware(t *testing.T) {
+	limits, err := bucketlimits.Parse(
+		map[string]string{"small": "10"},
+		map[string]string{"small": "8"},
+		map[string]string{"small": "1"},
+	)
+	require.NoError(t, err)
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(bucketLimitsMiddleware(limits))
+	app.Put("/:bucket/*", func(c *fiber.Ctx) error { return c.SendStatus(http.StatusOK) })
+
+	put := func(path, body string) (int, string) {
+		resp, err := app.Test(httptest.NewRequest("PUT", path, strings.NewReader(body)))
+		require.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	status, body := put("/small/key", strings.Repeat("x", 11))
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "<Code>EntityTooLarge</Code>")
+
+	status, body = put("/small/much-too-long", "x")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "<Code>KeyTooLongError</Code>")
+
+	status, _ = put("/small/key?tagging", strings.Repeat("x", 100))
+	assert.Equal(t, http.StatusOK, status, "sub-resources are not uploads")
+
+	status, _ = put("/small/key?partNumber=1&uploadId=abc", "x")
+	assert.Equal(t, http.StatusOK, status, "parts add no keys")
+	status, _ = put("/small/key", "x")
+	assert.Equal(t, http.StatusOK, status)
+	status, body = put("/small/other", "x")
+	assert.Equal(t, http.StatusForbidden, status, "the first upload filled the bucket")
+	assert.Contains(t, body, "<Code>QuotaExceeded</Code>")
+
+	status, _ = put("/large/much-too-long", strings.Repeat("x", 100))
+	assert.Equal(t, http.StatusOK, status)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/lock"
+	"s3-vault-proxy/internal/maintenance"
+	"s3-vault-proxy/internal/s3"
+)
+
+const (
+	// maintenanceRate bounds the objects re-encrypted or deleted per second, the
+	// default of the rewrap and gc subcommands
+	maintenanceRate = 50
+	// gcUploadsOlderThan is the age past which the gc job aborts multipart uploads
+	gcUploadsOlderThan = 7 * 24 * time.Hour
+)
+
+// maintenanceJobs runs the rewrap and gc subcommands over MAINTENANCE_BUCKETS
+// as admin jobs. With LOCK_BUCKET set they take the same bucket leases, so a
+// job never interleaves with a subcommand run on another host.
+type maintenanceJobs struct {
+	client  s3.Interface
+	locker  *lock.Locker
+	buckets []string
+}
+
+func newMaintenanceJobs(cfg *config.Config) (*maintenanceJobs, error) {
+	credentials, err := cfg.OperatorCredentials()
+	if err != nil {
+		return nil, err
+	}
+	client, err := s3.NewSigningClient(NewBackend(cfg), cfg.S3Endpoint, credentials)
+	if err != nil {
+		return nil, err
+	}
+	jobs := &maintenanceJobs{client: client, buckets: cfg.MaintenanceBuckets}
+	if cfg.LockBucket != "" {
+		jobs.locker = lock.NewLocker(client, cfg.LockBucket, cfg.LockTTL)
+	}
+	return jobs, nil
+}
+
+// Rewrap re-encrypts the stored objects under data keys of the newest transit
+// key version and returns the counts of each outcome
+func (j *maintenanceJobs) Rewrap(ctx context.Context) (interface{}, error) {
+	ctx, release, err := j.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	progress := maintenance.NewProgress(nil, 0)
+	err = maintenance.Rewrap(ctx, j.client, maintenance.RewrapOptions{
+		Buckets:  j.buckets,
+		Rate:     maintenanceRate,
+		Progress: progress,
+	})
+	return progress.Summary(), err
+}
+
+// GC deletes orphaned metadata objects and aborts abandoned multipart uploads,
+// returning the counts of each outcome
+func (j *maintenanceJobs) GC(ctx context.Context) (interface{}, error) {
+	ctx, release, err := j.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	progress := maintenance.NewProgress(nil, 0)
+	err = maintenance.GC(ctx, j.client, maintenance.GCOptions{
+		Buckets:          j.buckets,
+		UploadsOlderThan: gcUploadsOlderThan,
+		Rate:             maintenanceRate,
+		Progress:         progress,
+	})
+	return progress.Summary(), err
+}
+
+func (j *maintenanceJobs) lock(ctx context.Context) (context.Context, func(), error) {
+	if j.locker == nil {
+		return ctx, func() {}, nil
+	}
+	return j.locker.TryAcquireBuckets(ctx, j.buckets...)
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package server
+
+import "net"
+
+// peerClosed cannot peek at connections on this platform, so disconnects are
+// only noticed when the response fails to write
+func peerClosed(conn net.Conn) bool {
+	return false
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin
+
+package server
+
+import (
+	"net"
+	"syscall"
+
+	"s3-vault-proxy/internal/listener"
+)
+
+// peerClosed reports whether the client has closed or reset conn. It peeks
+// without blocking, so pipelined requests stay unread; a client that sent
+// one before closing is only noticed once the response fails to write.
+func peerClosed(conn net.Conn) bool {
+	sc, ok := listener.RawConn(conn).(syscall.Conn)
+	if !ok {
+		return false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	closed := false
+	var buf [1]byte
+	_ = raw.Read(func(fd uintptr) bool {
+		n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		switch {
+		case err == nil:
+			closed = n == 0
+		case err == syscall.EAGAIN || err == syscall.EWOULDBLOCK || err == syscall.EINTR:
+		default:
+			closed = true
+		}
+		return true
+	})
+	return closed
+}
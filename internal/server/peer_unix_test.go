@@ -0,0 +1,74 @@
+//go:build linux || darwin
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/timeouts"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerClosed(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.False(t, peerClosed(conn), "an idle connection is open")
+	_, err = client.Write([]byte("GET / HTTP/1.1\r\n"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return !peerClosed(conn) }, time.Second, 10*time.Millisecond)
+	buf := make([]byte, 16)
+	_, err = conn.Read(buf)
+	require.NoError(t, err, "peeking leaves pipelined data unread")
+
+	client.Close()
+	assert.Eventually(t, func() bool { return peerClosed(conn) }, time.Second, 10*time.Millisecond)
+}
+
+func TestRequestCancelledWhenClientDisconnects(t *testing.T) {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(requestContextMiddleware(timeouts.Config{}, 0, 0, 10*time.Millisecond, context.Background()))
+	causes := make(chan error, 1)
+	app.Get("/", func(c *fiber.Ctx) error {
+		select {
+		case <-c.UserContext().Done():
+			causes <- context.Cause(c.UserContext())
+		case <-time.After(5 * time.Second):
+			causes <- nil
+		}
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(client, "GET / HTTP/1.1\r\nHost: proxy\r\n\r\n")
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	client.Close()
+
+	select {
+	case cause := <-causes:
+		assert.ErrorIs(t, cause, errClientGone)
+	case <-time.After(10 * time.Second):
+		t.Fatal("handler did not finish")
+	}
+}
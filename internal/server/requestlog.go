@@ -0,0 +1,96 @@
+package server
+
+import (
+	"strings"
+	"time"
+
+	"s3-vault-proxy/internal/accesslog"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/tracing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// requestIDHeader carries the correlation ID of a request. An ID set by a load
+// balancer in front of the proxy is kept, otherwise the proxy makes one up,
+// and either way it is returned to the client.
+const requestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds the correlation IDs accepted from clients
+const maxRequestIDLength = 128
+
+// requestLogMiddleware attaches a logger carrying the request's correlation
+// ID, bucket, key and access key to the request context, so every line logged
+// while serving it can be correlated, and logs the request once served
+func requestLogMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		requestID := c.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = accesslog.NewRequestID()
+		}
+		c.Set(requestIDHeader, requestID)
+
+		fields := logging.GetLogger().With().Str("request_id", requestID)
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(c.Path(), "/"), "/")
+		if bucket != "" {
+			fields = fields.Str("bucket", bucket)
+		}
+		if key != "" {
+			fields = fields.Str("key", key)
+		}
+		if accessKey := requestAccessKey(c); accessKey != "" {
+			fields = fields.Str("access_key", accessKey)
+		}
+		if span := tracing.SpanFromContext(c.UserContext()); span != nil {
+			fields = fields.Str("trace_id", span.Context().TraceID.String())
+		}
+		c.SetUserContext(logging.WithContext(c.UserContext(), fields.Logger()))
+
+		err := c.Next()
+
+		// The logger is read back, as later middleware may have added fields
+		logEvent := logging.FromContext(c.UserContext()).Info().
+			Str("method", c.Method()).
+			Str("path", c.Path()).
+			Int("status", c.Response().StatusCode()).
+			Dur("latency", time.Since(start)).
+			Str("ip", c.IP()).
+			Str("user_agent", c.Get("User-Agent"))
+
+		// Reading the body of a streamed response would buffer the whole stream
+		if !c.Response().IsBodyStream() {
+			logEvent = logEvent.Int("bytes_sent", len(c.Response().Body()))
+		}
+		if c.Get("Authorization") != "" {
+			logEvent = logEvent.Str("auth_present", "true")
+		}
+		if sse, _ := s3.ParseSSEHeaders(&c.Request().Header); sse.KMSKeyID != "" {
+			logEvent = logEvent.Str("kms_key", sse.KMSKeyID)
+		}
+		if backendID := c.Response().Header.Peek("x-amz-request-id"); len(backendID) > 0 {
+			logEvent = logEvent.Bytes("backend_request_id", backendID)
+		}
+		if err != nil {
+			logEvent = logEvent.Err(err)
+		}
+		logEvent.Msg("HTTP request processed")
+
+		return err
+	}
+}
+
+// validRequestID reports whether a client's correlation ID is safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
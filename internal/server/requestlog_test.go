@@ -0,0 +1,61 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/tenancy"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLogMiddleware(t *testing.T) {
+	tenants, err := tenancy.New([]*tenancy.Tenant{{Name: "acme", AccessKeys: []string{"AKACME"}}})
+	require.NoError(t, err)
+	var logged bytes.Buffer
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(requestLogMiddleware())
+	app.Use(tenancyMiddleware(tenants))
+	app.Get("/:bucket/*", func(c *fiber.Ctx) error {
+		logger := logging.FromContext(c.UserContext()).Output(&logged)
+		logger.Info().Msg("handler")
+		return c.SendStatus(http.StatusOK)
+	})
+
+	get := func(requestID string) (*http.Response, map[string]interface{}) {
+		logged.Reset()
+		req := httptest.NewRequest("GET", "/photos/2024/cat.jpg", nil)
+		req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKACME/20240101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc")
+		if requestID != "" {
+			req.Header.Set("X-Request-Id", requestID)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var fields map[string]interface{}
+		require.NoError(t, json.Unmarshal(logged.Bytes(), &fields))
+		return resp, fields
+	}
+
+	resp, fields := get("")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	requestID := resp.Header.Get("X-Request-Id")
+	assert.NotEmpty(t, requestID, "a correlation ID is made up and returned")
+	assert.Equal(t, requestID, fields["request_id"])
+	assert.Equal(t, "photos", fields["bucket"])
+	assert.Equal(t, "2024/cat.jpg", fields["key"])
+	assert.Equal(t, "AKACME", fields["access_key"])
+	assert.Equal(t, "acme", fields["tenant"], "fields learned later are added")
+
+	resp, fields = get("lb-1234")
+	assert.Equal(t, "lb-1234", resp.Header.Get("X-Request-Id"), "a load balancer's ID is kept")
+	assert.Equal(t, "lb-1234", fields["request_id"])
+
+	resp, _ = get("bad id\x7f")
+	assert.NotEqual(t, "bad id\x7f", resp.Header.Get("X-Request-Id"))
+}
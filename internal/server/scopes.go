@@ -0,0 +1,144 @@
+package server
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/scopedkeys"
+	"s3-vault-proxy/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// listingParams are the query parameters of ListObjects, ListObjectsV2,
+// ListObjectVersions and ListMultipartUploads. Scoped keys may only send
+// these (and presigned URL parameters) to a bucket, so bucket
+// configuration stays out of their reach.
+var listingParams = map[string]bool{
+	"list-type": true, "prefix": true, "delimiter": true, "max-keys": true,
+	"marker": true, "continuation-token": true, "start-after": true,
+	"fetch-owner": true, "encoding-type": true, "versions": true,
+	"key-marker": true, "version-id-marker": true, "uploads": true,
+	"upload-id-marker": true, "max-uploads": true,
+}
+
+// scopedKeysMiddleware rejects requests outside the buckets, prefixes and
+// access of a scoped access key. Other access keys pass unchecked.
+func scopedKeysMiddleware(keys *scopedkeys.Set) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Path() {
+		case "/health", "/health/dependencies", "/ready", "/metrics", "/version":
+			return c.Next()
+		}
+
+		accessKey := requestAccessKey(c)
+		if accessKey == "" && hasCredentials(c) {
+			// A scoped key could hide behind a signature the proxy cannot read,
+			// such as SigV2, which the backend may still accept
+			logging.Warn().Str("method", c.Method()).Str("path", c.Path()).Msg("Request rejected: credentials cannot be checked against access key scopes")
+			return c.Status(fiber.StatusForbidden).XML(types.ErrorResponse{
+				Code:    "AccessDenied",
+				Message: "Access Denied",
+			})
+		}
+		if !keys.Scoped(accessKey) {
+			return c.Next()
+		}
+		if err := authorizeScopedRequest(c, keys, accessKey); err != nil {
+			logging.Warn().Err(err).Str("access_key", accessKey).Str("method", c.Method()).Str("path", c.Path()).Msg("Request rejected by access key scope")
+			return c.Status(fiber.StatusForbidden).XML(types.ErrorResponse{
+				Code:    "AccessDenied",
+				Message: "Access Denied",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// authorizeScopedRequest checks a request against the scopes of accessKey.
+// GET and HEAD read, every other method writes.
+func authorizeScopedRequest(c *fiber.Ctx, keys *scopedkeys.Set, accessKey string) error {
+	bucket, rawKey, _ := strings.Cut(strings.TrimPrefix(c.Path(), "/"), "/")
+	write := c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead
+	if bucket == "" {
+		// ListBuckets would name every bucket
+		return scopedkeys.ErrOutOfScope
+	}
+	if rawKey == "" {
+		return authorizeScopedBucket(c, keys, accessKey, bucket)
+	}
+
+	key, err := s3.DecodeKey(rawKey)
+	if err != nil {
+		return scopedkeys.ErrOutOfScope
+	}
+	if err := keys.AllowObject(accessKey, bucket, key, write); err != nil {
+		return err
+	}
+	// Copies read their source, which must be in scope too
+	if source := c.Get("X-Amz-Copy-Source"); source != "" {
+		sourceBucket, sourceKey, ok := s3.ParseCopySource(source)
+		if !ok {
+			return scopedkeys.ErrOutOfScope
+		}
+		return keys.AllowObject(accessKey, sourceBucket, sourceKey, false)
+	}
+	return nil
+}
+
+// authorizeScopedBucket checks a bucket-level request. Scoped keys may list
+// inside their prefixes, delete in-scope objects in bulk and ask whether a
+// bucket exists, but never change a bucket or read its configuration.
+func authorizeScopedBucket(c *fiber.Ctx, keys *scopedkeys.Set, accessKey, bucket string) error {
+	args := c.Request().URI().QueryArgs()
+	switch c.Method() {
+	case fiber.MethodHead:
+		return keys.AllowBucket(accessKey, bucket)
+	case fiber.MethodGet:
+		if args.Len() == 1 && (args.Has("location") || args.Has("versioning")) {
+			return keys.AllowBucket(accessKey, bucket)
+		}
+		listing := true
+		args.VisitAll(func(name, _ []byte) {
+			if !listingParams[string(name)] && !strings.HasPrefix(string(name), "X-Amz-") {
+				listing = false
+			}
+		})
+		if !listing {
+			return scopedkeys.ErrOutOfScope
+		}
+		return keys.AllowList(accessKey, bucket, c.Query("prefix"))
+	case fiber.MethodPost:
+		if !args.Has("delete") {
+			return scopedkeys.ErrOutOfScope
+		}
+		var request struct {
+			Objects []struct {
+				Key string `xml:"Key"`
+			} `xml:"Object"`
+		}
+		if err := xml.Unmarshal(c.Body(), &request); err != nil {
+			// Keys that cannot be read cannot be checked
+			return scopedkeys.ErrOutOfScope
+		}
+		for _, object := range request.Objects {
+			if err := keys.AllowObject(accessKey, bucket, object.Key, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return scopedkeys.ErrOutOfScope
+}
+
+// hasCredentials reports whether a request carries any credentials: an
+// Authorization header or presigned URL parameters of SigV2 or SigV4
+func hasCredentials(c *fiber.Ctx) bool {
+	if c.Get("Authorization") != "" {
+		return true
+	}
+	args := c.Request().URI().QueryArgs()
+	return args.Has("X-Amz-Credential") || args.Has("X-Amz-Signature") || args.Has("AWSAccessKeyId") || args.Has("Signature")
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"s3-vault-proxy/internal/scopedkeys"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopedKeysMiddleware(t *testing.T) {
+	keys, err := scopedkeys.New([]scopedkeys.Key{
+		{AccessKey: "AKSCOPED", Scopes: []scopedkeys.Scope{
+			{Bucket: "photos", Prefix: "public/", Access: scopedkeys.Read},
+			{Bucket: "photos", Prefix: "uploads/", Access: scopedkeys.Write},
+		}},
+	})
+	require.NoError(t, err)
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(scopedKeysMiddleware(keys))
+	app.Use(func(c *fiber.Ctx) error { return c.SendStatus(http.StatusOK) })
+
+	do := func(accessKey, method, target, body string, headers map[string]string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/20240101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name, method, target, body string
+		headers                    map[string]string
+		status                     int
+	}{
+		{"read in scope", "GET", "/photos/public/cat.jpg", "", nil, http.StatusOK},
+		{"encoded key in scope", "HEAD", "/photos/public%2Fcat.jpg", "", nil, http.StatusOK},
+		{"write to read scope", "PUT", "/photos/public/cat.jpg", "x", nil, http.StatusForbidden},
+		{"write in scope", "PUT", "/photos/uploads/cat.jpg", "x", nil, http.StatusOK},
+		{"read from write scope", "GET", "/photos/uploads/cat.jpg", "", nil, http.StatusForbidden},
+		{"other bucket", "GET", "/private/public/cat.jpg", "", nil, http.StatusForbidden},
+		{"list buckets", "GET", "/", "", nil, http.StatusForbidden},
+		{"list in scope", "GET", "/photos?list-type=2&prefix=public/", "", nil, http.StatusOK},
+		{"list bucket root", "GET", "/photos?list-type=2", "", nil, http.StatusForbidden},
+		{"bucket configuration", "GET", "/photos?policy", "", nil, http.StatusForbidden},
+		{"bucket location", "GET", "/photos?location", "", nil, http.StatusOK},
+		{"head bucket", "HEAD", "/photos", "", nil, http.StatusOK},
+		{"delete bucket", "DELETE", "/photos", "", nil, http.StatusForbidden},
+		{"copy from readable source", "PUT", "/photos/uploads/copy.jpg", "", map[string]string{"X-Amz-Copy-Source": "/photos/public/cat.jpg"}, http.StatusOK},
+		{"copy from unreadable source", "PUT", "/photos/uploads/copy.jpg", "", map[string]string{"X-Amz-Copy-Source": "private/secret.jpg?versionId=1"}, http.StatusForbidden},
+		{"bulk delete in scope", "POST", "/photos?delete", "<Delete><Object><Key>uploads/a</Key></Object></Delete>", nil, http.StatusOK},
+		{"bulk delete out of scope", "POST", "/photos?delete", "<Delete><Object><Key>uploads/a</Key></Object><Object><Key>public/b</Key></Object></Delete>", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.status, do("AKSCOPED", tt.method, tt.target, tt.body, tt.headers), tt.name)
+	}
+
+	assert.Equal(t, http.StatusForbidden, do("AKSCOPED", "POST", "/photos?delete", "<Delete><Object>", nil), "unreadable bulk deletes are refused")
+
+	unparsed := func(target string, headers map[string]string) int {
+		req := httptest.NewRequest("GET", target, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusForbidden, unparsed("/private/secret.jpg", map[string]string{"Authorization": "AWS AKSCOPED:c2ln"}), "SigV2 requests are refused")
+	assert.Equal(t, http.StatusForbidden, unparsed("/private/secret.jpg?AWSAccessKeyId=AKSCOPED&Signature=c2ln&Expires=1", nil), "SigV2 presigned URLs are refused")
+	assert.Equal(t, http.StatusForbidden, unparsed("/private/secret.jpg", map[string]string{"Authorization": "AWS4-HMAC-SHA256 garbage"}), "malformed SigV4 is refused")
+	assert.Equal(t, http.StatusOK, unparsed("/private/public.jpg", nil), "anonymous requests are left to the backend")
+
+	assert.Equal(t, http.StatusOK, do("AKOTHER", "DELETE", "/photos", "", nil), "unscoped keys are not restricted")
+	assert.Equal(t, http.StatusOK, do("AKSCOPED", "GET", "/health", "", nil))
+}
@@ -1,31 +1,112 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"s3-vault-proxy/internal/accesslog"
+	"s3-vault-proxy/internal/admin"
+	"s3-vault-proxy/internal/cache"
+	"s3-vault-proxy/internal/capture"
+	"s3-vault-proxy/internal/chaos"
 	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/errreport"
+	"s3-vault-proxy/internal/extensions"
+	"s3-vault-proxy/internal/features"
 	"s3-vault-proxy/internal/handlers"
+	"s3-vault-proxy/internal/inflight"
+	"s3-vault-proxy/internal/kmsbindings"
+	"s3-vault-proxy/internal/listener"
+	"s3-vault-proxy/internal/locations"
 	"s3-vault-proxy/internal/logging"
 	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/notify"
+	"s3-vault-proxy/internal/phases"
+	"s3-vault-proxy/internal/provision"
+	"s3-vault-proxy/internal/replication"
 	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/sigv4"
+	"s3-vault-proxy/internal/tenancy"
+	"s3-vault-proxy/internal/timeouts"
+	"s3-vault-proxy/internal/tracing"
 	"s3-vault-proxy/internal/vault"
+	"s3-vault-proxy/pkg/types"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/valyala/fasthttp"
+)
+
+// signatureFailureBufferSize bounds how many rejected signatures are kept for
+// /debug/signature-failures, the newest replacing the oldest
+const signatureFailureBufferSize = 100
+
+// notificationWorkers send bucket events concurrently. Consumers order the
+// events of a key by their sequencer, not by arrival.
+const notificationWorkers = 4
+
+var slowRequestsTotal = metrics.NewCounter(
+	"s3_vault_proxy_slow_requests_total",
+	"Requests that took longer than SLOW_REQUEST_THRESHOLD.",
+	"method",
 )
 
 // Server represents the HTTP server
 type Server struct {
 	app    *fiber.App
 	config *config.Config
+	tracer *tracing.Tracer
+
+	reporter   *errreport.Reporter
+	accessLog  *accesslog.Logger
+	admin      *admin.Server
+	inflight   *inflight.Tracker
+	replicator *replication.Replicator // nil when replication is disabled
+	notifier   *notify.Notifier        // nil without NOTIFICATION_SQS_URL
+	extensions extensions.Inspector    // nil without EXTENSION_MODULES
+	inventory  *inventorySchedule      // nil without INVENTORY_BUCKETS
+	trashPurge *trashSchedule          // nil without TRASH_BUCKETS
+	usage      *usageSchedule          // nil unless USAGE_ENABLED
+	cutoff     *requestCutoff
+
+	abortUploads *abortUploadsSchedule // nil without ABORT_UPLOADS_BUCKETS
+}
+
+// Dependencies replaces subsystems the server otherwise builds from its
+// configuration. Nil fields are built as usual.
+type Dependencies struct {
+	// Transit encrypts and decrypts data keys instead of Vault
+	Transit vault.Interface
+	// Storage receives the proxied requests instead of the S3_ENDPOINT
+	// backend. Maintenance jobs, tenancy quotas and bucket limits still sign
+	// their own requests to S3_ENDPOINT.
+	Storage s3.Interface
+	// Metadata stores object metadata instead of the metadata objects kept
+	// next to each object in Storage
+	Metadata metadata.Interface
 }
 
 // New creates a new server instance
 func New(cfg *config.Config) (*Server, error) {
+	return NewWithDependencies(cfg, Dependencies{})
+}
+
+// NewWithDependencies creates a server using the given subsystems in place
+// of those configured by cfg
+func NewWithDependencies(cfg *config.Config, deps Dependencies) (*Server, error) {
 	// Initialize logging first
 	logging.InitGlobalLogger(logging.Config{
 		Level:      cfg.LogLevel,
@@ -33,20 +114,338 @@ func New(cfg *config.Config) (*Server, error) {
 		TimeFormat: cfg.LogTimeFormat,
 	})
 	// Initialize Vault client
-	vaultClient, err := vault.NewClient(cfg.VaultAddr, cfg.VaultToken, cfg.VaultTokenPath)
+	var vaultClient transit = injectedTransit{deps.Transit}
+	if deps.Transit == nil {
+		var err error
+		if vaultClient, err = newTransit(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	featureSet, err := cfg.Features()
+	if err != nil {
+		return nil, err
+	}
+	if overridden := featureSet.Overridden(); len(overridden) > 0 {
+		logging.Info().Interface("feature_flags", overridden).Msg("Feature flags overridden")
+	}
+
+	tenants, err := cfg.Tenants()
+	if err != nil {
+		return nil, err
+	}
+	if tenants != nil {
+		pathTemplate, err := cfg.TransitPathTemplate()
+		if err != nil {
+			return nil, err
+		}
+		if err := tenants.Connect(cfg.VaultAddr, cfg.VaultTransitMount, pathTemplate); err != nil {
+			return nil, err
+		}
+		logging.Info().Int("tenants", len(tenants.Tenants())).Msg("Tenant isolation enabled")
+		if tenants.HasQuotas() {
+			credentials, err := cfg.OperatorCredentials()
+			if err != nil {
+				return nil, err
+			}
+			usageClient, err := s3.NewSigningClient(NewBackend(cfg), cfg.S3Endpoint, credentials)
+			if err != nil {
+				return nil, err
+			}
+			go refreshTenantUsage(tenants, usageClient, cfg.TenantUsageRefresh)
+		}
+	}
+
+	scopedKeys, err := cfg.ScopedKeys()
 	if err != nil {
 		return nil, err
 	}
+	if scopedKeys != nil {
+		logging.Info().Int("access_keys", scopedKeys.Len()).Msg("Access key scoping enabled")
+	}
+
+	var tracer *tracing.Tracer
+	if cfg.TracingEnabled {
+		exporter := tracing.NewOTLPExporter(cfg.OTLPEndpoint, cfg.OTelServiceName, cfg.OTLPHeaders)
+		tracer = tracing.NewTracer(exporter, cfg.TracingSampleRatio)
+		logging.Info().
+			Str("otlp_endpoint", cfg.OTLPEndpoint).
+			Float64("sample_ratio", cfg.TracingSampleRatio).
+			Msg("Tracing enabled")
+	}
 
 	// Initialize S3 client
-	s3Client := s3.NewClient(cfg.S3Endpoint, cfg.S3CACertPath)
+	var healthOpts []handlers.HealthHandlerOption
+	var s3Client s3.Interface = deps.Storage
+	if s3Client == nil {
+		s3Backend := NewBackend(cfg)
+		s3Client = s3Backend
+		healthOpts = append(healthOpts, handlers.WithBackendProbe("default", s3Backend))
+		if cfg.S3Client == "fasthttp" {
+			// Probes keep the net/http client, which reports more detail
+			if s3Client, err = newFastBackend(cfg); err != nil {
+				return nil, err
+			}
+			logging.Info().Msg("Forwarding requests with the fasthttp backend client")
+		}
+	}
+	if cfg.ShadowEndpoint != "" {
+		shadowCfg := *cfg
+		shadowCfg.S3Endpoint = cfg.ShadowEndpoint
+		shadowCfg.S3CACertPath = cfg.ShadowCACertPath
+		s3Client = s3.NewShadowClient(s3Client, NewBackend(&shadowCfg), s3.ShadowOptions{
+			SampleRatio:  cfg.ShadowSampleRatio,
+			Writes:       cfg.ShadowWrites,
+			MaxWriteSize: int64(cfg.ShadowMaxWriteSize),
+		})
+		logging.Info().
+			Str("shadow_endpoint", cfg.ShadowEndpoint).
+			Float64("sample_ratio", cfg.ShadowSampleRatio).
+			Bool("writes", cfg.ShadowWrites).
+			Msg("Traffic shadowing enabled")
+	}
+	var chaosInjector *chaos.Injector
+	if cfg.ChaosEnabled {
+		chaosInjector = chaos.New(chaos.Config{
+			Latency:           cfg.ChaosLatency,
+			LatencyRatio:      cfg.ChaosLatencyRatio,
+			VaultErrorRatio:   cfg.ChaosVaultErrorRatio,
+			BackendResetRatio: cfg.ChaosBackendResetRatio,
+			TruncateRatio:     cfg.ChaosTruncateRatio,
+		})
+		s3Client = chaos.NewBackend(s3Client, chaosInjector)
+		logging.Warn().
+			Dur("latency", cfg.ChaosLatency).
+			Float64("latency_ratio", cfg.ChaosLatencyRatio).
+			Float64("vault_error_ratio", cfg.ChaosVaultErrorRatio).
+			Float64("backend_reset_ratio", cfg.ChaosBackendResetRatio).
+			Float64("truncate_ratio", cfg.ChaosTruncateRatio).
+			Msg("Fault injection enabled, requests will fail on purpose")
+	}
+	var captureRecorder *capture.Recorder
+	if cfg.CaptureEnabled {
+		captureRecorder = capture.NewRecorder(cfg.CaptureBufferSize, cfg.CaptureHeader)
+		captureRecorder.Arm(cfg.CaptureLimit, cfg.CaptureAccessKeys...)
+		s3Client = s3.NewCapturingClient(s3Client, captureRecorder)
+	}
+	var signatureFailures *capture.Recorder
+	if cfg.SignatureDiagnosticsEnabled {
+		signatureFailures = capture.NewRecorder(signatureFailureBufferSize, "")
+		s3Client = s3.NewSignatureDiagnosticsClient(s3Client, signatureFailures)
+	}
+	if tracer != nil {
+		s3Client = s3.NewTracingClient(s3Client, tracer, featureSet.Enabled(features.InjectTraceparent))
+	}
+	if featureSet.Enabled(features.TranslateBackendErrors) {
+		s3Client = s3.NewErrorTranslatingClient(s3Client)
+	}
+
+	// Metadata objects are read and written with the operator credentials:
+	// clients sign their request for an object, not one for <key>.metadata
+	var sidecars *metadata.Service
+	if credentials, err := cfg.OperatorCredentials(); err == nil {
+		sidecarsClient, err := s3.NewSigningClient(NewBackend(cfg), cfg.S3Endpoint, credentials)
+		if err != nil {
+			return nil, err
+		}
+		sidecars = metadata.NewService(sidecarsClient)
+	} else if featureSet.Enabled(features.EnforceEncryptionContext) {
+		return nil, err
+	}
+	metadataService := deps.Metadata
+	switch {
+	case metadataService != nil:
+	case sidecars != nil:
+		metadataService = sidecars
+	default:
+		logging.Warn().Msg("Without S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY, metadata objects are neither read nor written, so responses report the backend's sizes and ETags")
+		metadataService = metadata.None{}
+	}
+	switch cfg.MetadataCache {
+	case "memory":
+		metadataService = metadata.NewCachedService(metadataService, cache.NewMemoryKV(), cfg.MetadataCacheTTL)
+	case "redis":
+		redis := cache.NewRedis(cache.RedisConfig{
+			Addr:      cfg.RedisAddr,
+			Password:  cfg.RedisPassword,
+			DB:        cfg.RedisDB,
+			KeyPrefix: cfg.RedisKeyPrefix,
+		})
+		if err := redis.Ping(); err != nil {
+			logging.Warn().Err(err).Str("redis_addr", cfg.RedisAddr).Msg("Redis unreachable at startup, metadata cache will retry")
+		}
+		metadataService = metadata.NewCachedService(metadataService, redis, cfg.MetadataCacheTTL)
+	}
 
-	// Initialize metadata service
-	metadataService := metadata.NewService(s3Client)
+	state := newOperationalState(cfg)
+	state.tenants = tenants
+	if state.limits, err = cfg.BucketLimits(); err != nil {
+		return nil, err
+	}
+	if state.limits.CountsKeys() {
+		credentials, err := cfg.OperatorCredentials()
+		if err != nil {
+			return nil, err
+		}
+		keyCountClient, err := s3.NewSigningClient(NewBackend(cfg), cfg.S3Endpoint, credentials)
+		if err != nil {
+			return nil, err
+		}
+		go refreshBucketKeys(state.limits, keyCountClient, cfg.BucketKeyCountRefresh)
+	}
 
 	// Initialize handlers
-	healthHandler := handlers.NewHealthHandler(cfg, vaultClient)
-	s3Handler := handlers.NewS3Handler(s3Client, vaultClient, metadataService)
+	healthOpts = append(healthOpts, handlers.WithDrainer(state.inflight))
+	healthHandler := handlers.NewHealthHandler(cfg, vaultClient, healthOpts...)
+	s3HandlerOpts := []handlers.S3HandlerOption{
+		handlers.WithFeatures(featureSet),
+		handlers.WithDeleteConcurrency(cfg.DeleteObjectsConcurrency),
+	}
+	if cfg.ListMetadataBudget > 0 {
+		s3HandlerOpts = append(s3HandlerOpts, handlers.WithListMetadataBudget(cfg.ListMetadataBudget))
+	}
+	if cfg.ObjectCacheEnabled {
+		objectCache, err := newObjectCache(cfg)
+		if err != nil {
+			return nil, err
+		}
+		s3HandlerOpts = append(s3HandlerOpts, handlers.WithObjectCache(objectCache, objectCache.MaxEntrySize()))
+	}
+	if cfg.SpoolEnabled {
+		s3HandlerOpts = append(s3HandlerOpts, handlers.WithRequestSpooling(int64(cfg.SpoolThreshold), cfg.SpoolDir))
+	}
+	if cfg.DefaultKMSKeyARN != "" {
+		s3HandlerOpts = append(s3HandlerOpts, handlers.WithDefaultKMSKey(cfg.DefaultKMSKeyARN))
+	}
+	switch cfg.LegacyObjects {
+	case "deny":
+		s3HandlerOpts = append(s3HandlerOpts, handlers.WithLegacyObjectsDenied())
+	case "encrypt":
+		credentials, err := cfg.OperatorCredentials()
+		if err != nil {
+			return nil, err
+		}
+		migrationClient, err := s3.NewSigningClient(NewBackend(cfg), cfg.S3Endpoint, credentials)
+		if err != nil {
+			return nil, err
+		}
+		kmsKeyARN := cfg.LegacyKMSKeyARN
+		if kmsKeyARN == "" {
+			kmsKeyARN = cfg.DefaultKMSKeyARN
+		}
+		s3HandlerOpts = append(s3HandlerOpts, handlers.WithLegacyObjectsEncrypted(migrationClient, kmsKeyARN, cfg.LegacyMigrationConcurrency))
+	}
+	if cfg.KMSBindingsBucket != "" {
+		credentials, err := cfg.OperatorCredentials()
+		if err != nil {
+			return nil, err
+		}
+		bindingsClient, err := s3.NewSigningClient(NewBackend(cfg), cfg.S3Endpoint, credentials)
+		if err != nil {
+			return nil, err
+		}
+		state.kmsBindings = kmsbindings.NewStore(bindingsClient, cfg.KMSBindingsBucket)
+		s3HandlerOpts = append(s3HandlerOpts, handlers.WithKMSBindings(state.kmsBindings))
+	}
+	if sidecars != nil {
+		s3HandlerOpts = append(s3HandlerOpts, handlers.WithMetadataSidecars(sidecars))
+		if featureSet.Enabled(features.EnforceEncryptionContext) {
+			s3HandlerOpts = append(s3HandlerOpts, handlers.WithEncryptionContexts(sidecars))
+		}
+	}
+	s3HandlerOpts = append(s3HandlerOpts, handlers.WithRegion(cfg.S3Region))
+	if cfg.BucketLocationsBucket != "" {
+		credentials, err := cfg.OperatorCredentials()
+		if err != nil {
+			return nil, err
+		}
+		locationsClient, err := s3.NewSigningClient(NewBackend(cfg), cfg.S3Endpoint, credentials)
+		if err != nil {
+			return nil, err
+		}
+		s3HandlerOpts = append(s3HandlerOpts, handlers.WithBucketLocations(locations.NewStore(locationsClient, cfg.BucketLocationsBucket)))
+	}
+	if len(cfg.AutoCreateBuckets) > 0 {
+		credentials, err := cfg.OperatorCredentials()
+		if err != nil {
+			return nil, err
+		}
+		provisionClient, err := s3.NewSigningClient(NewBackend(cfg), cfg.S3Endpoint, credentials)
+		if err != nil {
+			return nil, err
+		}
+		s3HandlerOpts = append(s3HandlerOpts, handlers.WithBucketProvisioning(provision.NewProvisioner(provisionClient, cfg.AutoCreateBuckets)))
+		logging.Info().Strs("patterns", cfg.AutoCreateBuckets).Msg("Bucket provisioning on first upload enabled")
+	}
+	var inspector extensions.Inspector
+	if len(cfg.ExtensionModules) > 0 {
+		if inspector, err = extensions.Load(context.Background(), cfg.ExtensionModules, cfg.ExtensionTimeout); err != nil {
+			return nil, err
+		}
+		logging.Info().Strs("modules", cfg.ExtensionModules).Msg("Request extensions loaded")
+	}
+	bucketHeaders, err := cfg.BucketHeaders()
+	if err != nil {
+		return nil, err
+	}
+	if bucketHeaders != nil {
+		s3HandlerOpts = append(s3HandlerOpts, handlers.WithBucketHeaders(bucketHeaders))
+		logging.Info().Int("buckets", bucketHeaders.Len()).Msg("Bucket response headers enabled")
+	}
+	var replicator *replication.Replicator
+	if cfg.ReplicationEndpoint != "" {
+		replicator, err = newReplicator(cfg)
+		if err != nil {
+			return nil, err
+		}
+		replicator.Start()
+		s3HandlerOpts = append(s3HandlerOpts, handlers.WithReplication(replicator))
+		logging.Info().
+			Str("endpoint", cfg.ReplicationEndpoint).
+			Int("workers", cfg.ReplicationWorkers).
+			Msg("Replication enabled")
+	}
+	var notifier *notify.Notifier
+	if cfg.NotificationSQSURL != "" {
+		notifier, err = notify.New(notify.Options{
+			QueueURL:    cfg.NotificationSQSURL,
+			Credentials: cfg.NotificationCredentials(),
+			Buckets:     cfg.NotificationBuckets,
+			Events:      cfg.NotificationEvents,
+			Workers:     notificationWorkers,
+			QueueSize:   cfg.NotificationQueueSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		notifier.Start()
+		s3HandlerOpts = append(s3HandlerOpts, handlers.WithNotifications(notifier))
+		logging.Info().
+			Str("queue", cfg.NotificationSQSURL).
+			Strs("events", cfg.NotificationEvents).
+			Msg("Event notifications enabled")
+	}
+	var usageMeter *usageSchedule
+	if cfg.UsageEnabled {
+		usageMeter, err = newUsageSchedule(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure usage accounting: %w", err)
+		}
+	}
+
+	var trashPurge *trashSchedule
+	if len(cfg.TrashBuckets) > 0 {
+		trashPurge, err = newTrashSchedule(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure trash: %w", err)
+		}
+		s3HandlerOpts = append(s3HandlerOpts, handlers.WithTrash(trashPurge.trash))
+		logging.Info().
+			Strs("buckets", cfg.TrashBuckets).
+			Dur("retention", cfg.TrashRetention).
+			Msg("Trash enabled")
+	}
+	s3Handler := handlers.NewS3Handler(s3Client, vaultClient, metadataService, s3HandlerOpts...)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
@@ -61,6 +460,7 @@ func New(cfg *config.Config) (*Server, error) {
 		StrictRouting:     false,
 		UnescapePath:      false,
 		ReduceMemoryUsage: false,
+		StreamRequestBody: cfg.SpoolEnabled,
 
 		BodyLimit:       cfg.BodyLimit,
 		ReadBufferSize:  cfg.ReadBufferSize,
@@ -75,100 +475,882 @@ func New(cfg *config.Config) (*Server, error) {
 		ErrorHandler: errorHandler,
 	})
 
+	// Per-operation timeouts override the server-wide Read/WriteTimeout once headers arrive
+	operationTimeouts := timeouts.Config{
+		Metadata: cfg.MetadataTimeout,
+		Download: cfg.DownloadTimeout,
+		Upload:   cfg.UploadTimeout,
+	}
+	app.Server().HeaderReceived = func(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+		timeout := operationTimeouts.For(header.Method(), header.RequestURI())
+		return fasthttp.RequestConfig{ReadTimeout: timeout, WriteTimeout: timeout}
+	}
+
+	// Uploads that would be rejected are refused before the client sends the body
+	app.Server().ContinueHandler = continueHandler(state, vaultClient, cfg.BodyLimit, cfg.DefaultKMSKeyARN)
+
+	reporter, err := newErrorReporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	// Add middleware
-	app.Use(recover.New(recover.Config{
+	recoverConfig := recover.Config{
 		EnableStackTrace: true,
-	}))
+	}
+	if reporter != nil {
+		recoverConfig.StackTraceHandler = func(c *fiber.Ctx, e interface{}) {
+			stack := string(debug.Stack())
+			logging.FromContext(c.UserContext()).Error().Str("panic", fmt.Sprint(e)).Str("stack", stack).Msg("Recovered from panic")
+			event := requestEvent(c, fiber.StatusInternalServerError, fmt.Sprintf("panic: %v", e))
+			event.Panic = true
+			event.Stack = stack
+			reporter.Report(event)
+		}
+	}
+	app.Use(recover.New(recoverConfig))
+	app.Use(inflightMiddleware(state.inflight))
+	cutoff := newRequestCutoff()
+	app.Use(requestContextMiddleware(operationTimeouts, cfg.ReadTimeout, cfg.WriteTimeout, cfg.DisconnectCheckInterval, cutoff.ctx))
 
-	// Custom logging middleware using zerolog
-	app.Use(func(c *fiber.Ctx) error {
-		start := time.Now()
-		
-		// Process request
-		err := c.Next()
-		
-		// Log request after processing
-		duration := time.Since(start)
-		
-		logEvent := logging.Info().
-			Str("method", c.Method()).
-			Str("path", c.Path()).
-			Int("status", c.Response().StatusCode()).
-			Dur("latency", duration).
-			Str("ip", c.IP()).
-			Str("user_agent", c.Get("User-Agent")).
-			Int("bytes_sent", len(c.Response().Body()))
-		
-		// Add auth header info for debug level
-		if authHeader := c.Get("Authorization"); authHeader != "" {
-			logEvent = logEvent.Str("auth_present", "true")
-		}
-		
-		if kmsKey := c.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); kmsKey != "" {
-			logEvent = logEvent.Str("kms_key", kmsKey)
-		}
-		
-		if err != nil {
-			logEvent = logEvent.Err(err)
-		}
-		
-		logEvent.Msg("HTTP request processed")
-		
-		return err
-	})
+	if tracer != nil {
+		app.Use(tracingMiddleware(tracer))
+	}
+
+	if reporter != nil {
+		app.Use(errorReportMiddleware(reporter))
+	}
+
+	if cfg.SlowRequestThreshold > 0 {
+		app.Use(slowRequestMiddleware(cfg.SlowRequestThreshold))
+	}
+
+	var accessLog *accesslog.Logger
+	if cfg.AccessLogPath != "" {
+		accessLog, err = accesslog.Open(cfg.AccessLogPath)
+		if err != nil {
+			return nil, err
+		}
+		app.Use(accessLogMiddleware(accessLog))
+	}
+
+	// Request-scoped logger and the request log line
+	app.Use(requestLogMiddleware())
+
+	if chaosInjector != nil {
+		app.Use(chaosMiddleware(chaosInjector))
+	}
 
 	app.Use(cors.New(cors.Config{
 		AllowCredentials: false,
 		AllowOrigins:     "*",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-Amz-Date, X-Amz-Content-Sha256, X-Amz-Security-Token",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-Amz-Date, X-Amz-Content-Sha256, X-Amz-Security-Token, Cache-Control, Content-Disposition, Content-Encoding, Expires",
 		AllowMethods:     "GET, POST, PUT, DELETE, HEAD, OPTIONS",
 		MaxAge:           86400, // Cache preflight for 24 hours
 	}))
 
+	app.Use(requireAuthMiddleware())
+	if tenants != nil {
+		app.Use(tenancyMiddleware(tenants))
+	}
+	if scopedKeys != nil {
+		app.Use(scopedKeysMiddleware(scopedKeys))
+	}
+	app.Use(readOnlyMiddleware(state))
+	if inspector != nil {
+		app.Use(extensionsMiddleware(inspector))
+	}
+	if state.limits != nil {
+		app.Use(bucketLimitsMiddleware(state.limits))
+	}
+	if state.uploads != nil {
+		app.Use(uploadLimitMiddleware(state.uploads))
+	}
+	app.Use(bucketUsageMiddleware(state.buckets))
+	if usageMeter != nil {
+		app.Use(usageMiddleware(usageMeter.meter))
+	}
+
 	// Health check routes
 	app.Get("/health", healthHandler.Health)
+	app.Get("/metrics", metricsHandler)
 	app.Get("/ready", healthHandler.Ready)
+	app.Get("/health/dependencies", healthHandler.Dependencies)
 	app.Get("/version", healthHandler.Version)
 
 	// S3 API routes
 	app.Get("/", s3Handler.ListBuckets)
 	app.Put("/:bucket", s3Handler.CreateBucket)
+	app.Head("/:bucket", s3Handler.HeadBucket)
+	app.Post("/:bucket", s3Handler.PostBucket)
 	app.Get("/:bucket", s3Handler.ListObjects)
+	app.Delete("/:bucket", s3Handler.DeleteBucket)
 	app.Put("/:bucket/*", s3Handler.PutObject)
+	app.Post("/:bucket/*", s3Handler.PostObject)
 	app.Head("/:bucket/*", s3Handler.HeadObject)
 	app.Get("/:bucket/*", s3Handler.GetObject)
 	app.Delete("/:bucket/*", s3Handler.DeleteObject)
 
+	// Admin listener, kept off the S3 port
+	var adminServer *admin.Server
+	if cfg.AdminAddr != "" {
+		adminServer = newAdminServer(cfg, vaultClient, captureRecorder, state)
+		if signatureFailures != nil {
+			adminServer.HandleFunc("/debug/signature-failures", signatureFailuresHandler(signatureFailures))
+		}
+	}
+	// Bucket statistics and encryption queries list buckets with the operator
+	// credentials, and are only served when they are configured
+	if credentials, err := cfg.OperatorCredentials(); err == nil && adminServer != nil {
+		statsClient, err := s3.NewSigningClient(NewBackend(cfg), cfg.S3Endpoint, credentials)
+		if err != nil {
+			return nil, err
+		}
+		adminServer.HandleFunc("/buckets/", bucketStatsHandler(newBucketStatsCache(statsClient, cfg.BucketStatsTTL)))
+		adminServer.HandleFunc("/objects/encryption", encryptionQueryHandler(statsClient))
+	}
+
+	var inventory *inventorySchedule
+	if len(cfg.InventoryBuckets) > 0 {
+		inventory, err = newInventorySchedule(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure inventory: %w", err)
+		}
+		if adminServer != nil {
+			adminServer.RegisterJob("inventory", inventory.Run)
+		}
+	}
+	if usageMeter != nil && adminServer != nil {
+		adminServer.HandleFunc("/usage", usageHandler(usageMeter.meter))
+		if usageMeter.client != nil {
+			adminServer.RegisterJob("usage-storage", usageMeter.CountStorage)
+		}
+	}
+	if trashPurge != nil && adminServer != nil {
+		adminServer.HandleFunc("/trash", trashListHandler(trashPurge.trash))
+		adminServer.HandleFunc("/trash/restore", trashRestoreHandler(trashPurge.trash))
+		adminServer.RegisterJob("trash-purge", trashPurge.Run)
+	}
+
+	var abortUploads *abortUploadsSchedule
+	if len(cfg.AbortUploadsBuckets) > 0 {
+		abortUploads, err = newAbortUploadsSchedule(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure aborting uploads: %w", err)
+		}
+		if adminServer != nil {
+			adminServer.RegisterJob("abort-uploads", abortUploads.Run)
+		}
+		logging.Info().
+			Strs("buckets", cfg.AbortUploadsBuckets).
+			Dur("after", cfg.AbortUploadsAfter).
+			Msg("Abandoned multipart uploads are aborted")
+	}
+
+	if len(cfg.MaintenanceBuckets) > 0 && adminServer != nil {
+		jobs, err := newMaintenanceJobs(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure maintenance jobs: %w", err)
+		}
+		adminServer.RegisterJob("rewrap", jobs.Rewrap)
+		adminServer.RegisterJob("gc", jobs.GC)
+	}
+
 	return &Server{
 		app:    app,
 		config: cfg,
+		tracer: tracer,
+
+		reporter:   reporter,
+		accessLog:  accessLog,
+		admin:      adminServer,
+		inflight:   state.inflight,
+		replicator: replicator,
+		notifier:   notifier,
+		extensions: inspector,
+		inventory:  inventory,
+		trashPurge: trashPurge,
+		usage:      usageMeter,
+		cutoff:     cutoff,
+
+		abortUploads: abortUploads,
 	}, nil
 }
 
 // Start starts the server
 func (s *Server) Start() error {
+	// Files named by the configuration were loaded by New, so a failure leaves it empty
+	configChecksum, _ := s.config.Checksum()
+	featureSet, _ := s.config.Features()
 	logging.Info().
 		Str("version", s.config.Version).
 		Str("commit", s.config.Commit).
 		Str("build_date", s.config.Date).
+		Str("go_version", runtime.Version()).
 		Str("port", s.config.Port).
+		Str("listeners", s.config.Listeners).
 		Str("s3_backend", s.config.S3Endpoint).
+		Str("s3_client", s.config.S3Client).
 		Str("vault_addr", s.config.VaultAddr).
+		Interface("crypto", s.config.CryptoMode()).
+		Strs("subsystems", s.config.Subsystems()).
+		Interface("features", featureSet.States()).
+		Str("config_checksum", configChecksum).
 		Str("log_level", s.config.LogLevel).
 		Str("log_format", s.config.LogFormat).
 		Msg("Starting S3 Vault Proxy")
 
+	if s.admin != nil {
+		if err := s.admin.Start(); err != nil {
+			return fmt.Errorf("failed to start admin listener: %w", err)
+		}
+	}
+	if s.inventory != nil {
+		s.inventory.Start()
+	}
+	if s.trashPurge != nil {
+		s.trashPurge.Start()
+	}
+	if s.abortUploads != nil {
+		s.abortUploads.Start()
+	}
+	if s.usage != nil {
+		s.usage.Start()
+	}
+
 	// Set up graceful shutdown
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
 		<-c
-		logging.Info().Msg("Gracefully shutting down...")
-		_ = s.app.ShutdownWithTimeout(30 * time.Second)
+		s.drain()
+		if s.inventory != nil {
+			s.inventory.Stop()
+		}
+		if s.trashPurge != nil {
+			s.trashPurge.Stop()
+		}
+		if s.abortUploads != nil {
+			s.abortUploads.Stop()
+		}
+		if s.usage != nil {
+			// Save the counters of the requests served during the drain
+			s.usage.Stop()
+		}
+		if s.admin != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			_ = s.admin.Shutdown(ctx)
+			cancel()
+		}
+		s.Close()
 	}()
 
-	return s.app.Listen(":" + s.config.Port)
+	if err := s.serve(); err != nil {
+		return err
+	}
+
+	// Listen returns as soon as the listener closes; wait for the drain to finish
+	<-shutdownDone
+	return nil
+}
+
+// App returns the Fiber app serving the S3 API, for callers that serve it
+// themselves instead of calling Start
+func (s *Server) App() *fiber.App {
+	return s.app
+}
+
+// Close finishes the background work of served requests: queued
+// replication and notifications, traces, error reports and the access log.
+// It also releases the extension runtime.
+func (s *Server) Close() {
+	if s.replicator != nil {
+		// Let queued objects finish replicating before exiting
+		s.replicator.Close()
+	}
+	if s.notifier != nil {
+		// Deliver the events of requests served during the drain
+		s.notifier.Close()
+	}
+	if s.extensions != nil {
+		_ = s.extensions.Close(context.Background())
+	}
+	_ = s.tracer.Shutdown()
+	s.reporter.Shutdown()
+	if s.accessLog != nil {
+		_ = s.accessLog.Close()
+	}
+}
+
+// serve binds every configured listener and serves them until shutdown.
+// Secondary listeners start once the app has built its routes.
+func (s *Server) serve() error {
+	specs, err := s.config.ListenerSpecs()
+	if err != nil {
+		return err
+	}
+
+	listeners := make([]net.Listener, 0, len(specs))
+	for _, spec := range specs {
+		ln, err := listener.Listen(spec)
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return fmt.Errorf("failed to listen on %s: %w", spec.Addr, err)
+		}
+		logging.Info().
+			Str("addr", ln.Addr().String()).
+			Bool("tls", spec.TLS()).
+			Bool("client_cert", spec.ClientCAFile != "").
+			Bool("require_auth", spec.RequireAuth).
+			Msg("S3 listener bound")
+		listeners = append(listeners, ln)
+	}
+
+	s.app.Hooks().OnListen(func(fiber.ListenData) error {
+		for _, ln := range listeners[1:] {
+			go func(ln net.Listener) {
+				if err := s.app.Server().Serve(ln); err != nil {
+					logging.Error().Err(err).Str("addr", ln.Addr().String()).Msg("Listener failed")
+				}
+			}(ln)
+		}
+		return nil
+	})
+	return s.app.Listener(listeners[0])
+}
+
+// drain fails readiness, stops accepting connections and waits for in-flight
+// requests until SHUTDOWN_TIMEOUT, logging any that are cut off
+func (s *Server) drain() {
+	s.inflight.StartDrain()
+	logging.Info().
+		Int("in_flight", s.inflight.Count()).
+		Dur("delay", s.config.ShutdownDelay).
+		Dur("timeout", s.config.ShutdownTimeout).
+		Msg("Gracefully shutting down...")
+
+	// Keep serving while load balancers notice the failing readiness probe
+	time.Sleep(s.config.ShutdownDelay)
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+	go func() {
+		// Stop listening straight away; ShutdownWithContext waits for idle connections
+		_ = s.app.ShutdownWithContext(ctx)
+	}()
+
+	if err := s.inflight.Wait(ctx); err != nil {
+		remaining := s.inflight.Snapshot()
+		logging.Warn().Int("cut_off", len(remaining)).Msg("Shutdown deadline reached with requests in flight")
+		s.cutoff.cancel()
+		for _, r := range remaining {
+			logging.Warn().
+				Str("method", r.Method).
+				Str("path", r.Path).
+				Str("ip", r.RemoteIP).
+				Dur("elapsed", time.Since(r.Started)).
+				Msg("Request cut off by shutdown")
+		}
+		return
+	}
+	logging.Info().Dur("elapsed", time.Since(start)).Msg("All in-flight requests finished")
+}
+
+// inflightMiddleware tracks each request until its response has been written.
+// While draining, responses ask clients to close keep-alive connections.
+func inflightMiddleware(tracker *inflight.Tracker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		done := tracker.Begin(inflight.Request{
+			Method:   c.Method(),
+			Path:     c.OriginalURL(),
+			RemoteIP: c.IP(),
+			Started:  time.Now(),
+		})
+
+		// Streamed bodies are written after the handler returns, so the
+		// request only finishes once fasthttp has written the response
+		c.Context().SetUserValue(inflightKey{}, releaseFunc(done))
+
+		if tracker.Draining() {
+			c.Set(fiber.HeaderConnection, "close")
+		}
+		return c.Next()
+	}
+}
+
+type inflightKey struct{}
+
+// requireAuthMiddleware rejects unsigned S3 requests on listeners configured
+// with require_auth. Health and metrics endpoints stay reachable for probes.
+func requireAuthMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		spec, ok := listener.FromConn(c.Context().Conn())
+		if !ok || !spec.RequireAuth {
+			return c.Next()
+		}
+		switch c.Path() {
+		case "/health", "/health/dependencies", "/ready", "/metrics", "/version":
+			return c.Next()
+		}
+		if c.Get("Authorization") == "" && c.Query("X-Amz-Credential") == "" {
+			return c.Status(fiber.StatusForbidden).XML(types.ErrorResponse{
+				Code:    "AccessDenied",
+				Message: "Anonymous requests are not allowed on this listener",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// tenancyMiddleware resolves the tenant of each S3 request from its access key
+// and bucket, rejecting requests no tenant may make and those over the
+// tenant's rate limit or storage quota, and records per-tenant metrics
+func tenancyMiddleware(registry *tenancy.Registry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Path() {
+		case "/health", "/health/dependencies", "/ready", "/metrics", "/version":
+			return c.Next()
+		}
+
+		accessKey := requestAccessKey(c)
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(c.Path(), "/"), "/")
+
+		tenant, err := registry.Resolve(accessKey, bucket)
+		if err != nil {
+			logging.FromContext(c.UserContext()).Warn().Err(err).Msg("Request rejected by tenant isolation")
+			return c.Status(fiber.StatusForbidden).XML(types.ErrorResponse{
+				Code:    "AccessDenied",
+				Message: "Access Denied",
+			})
+		}
+		if !tenant.Allow() {
+			return c.Status(fiber.StatusServiceUnavailable).XML(types.ErrorResponse{
+				Code:    "SlowDown",
+				Message: "Please reduce your request rate.",
+			})
+		}
+
+		var uploaded int64
+		upload := c.Method() == fiber.MethodPut && key != ""
+		if upload {
+			size := c.Get("X-Amz-Decoded-Content-Length")
+			if size == "" {
+				size = c.Get("Content-Length")
+			}
+			uploaded = parseSize(size, 0)
+			if err := tenant.CheckQuota(uploaded); err != nil {
+				return c.Status(fiber.StatusForbidden).XML(types.ErrorResponse{
+					Code:    "QuotaExceeded",
+					Message: fmt.Sprintf("The upload would exceed tenant %s's storage quota", tenant.Name),
+				})
+			}
+		}
+
+		c.SetUserContext(tenancy.WithTenant(c.UserContext(), tenant))
+		c.SetUserContext(logging.WithField(c.UserContext(), "tenant", tenant.Name))
+		err = c.Next()
+
+		status := c.Response().StatusCode()
+		if upload && status < 300 {
+			tenant.AddUsage(uploaded)
+		} else {
+			uploaded = 0
+		}
+		var downloaded int64
+		if c.Method() == fiber.MethodGet && key != "" && status < 300 {
+			downloaded = int64(c.Response().Header.ContentLength())
+		}
+		tenant.Observe(c.Method(), status, uploaded, downloaded)
+		return err
+	}
+}
+
+// refreshTenantUsage recounts the tenants' stored bytes every interval
+func refreshTenantUsage(registry *tenancy.Registry, client s3.Interface, interval time.Duration) {
+	for {
+		if err := registry.RefreshUsage(context.Background(), client); err != nil {
+			logging.Warn().Err(err).Msg("Failed to refresh tenant storage usage")
+		}
+		time.Sleep(interval)
+	}
+}
+
+// newReplicator reads objects from the backend with the operator credentials
+// and writes replicas to REPLICATION_ENDPOINT
+func newReplicator(cfg *config.Config) (*replication.Replicator, error) {
+	credentials, err := cfg.OperatorCredentials()
+	if err != nil {
+		return nil, err
+	}
+	source, err := s3.NewSigningClient(NewBackend(cfg), cfg.S3Endpoint, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	destCfg := *cfg
+	destCfg.S3Endpoint = cfg.ReplicationEndpoint
+	destCfg.S3CACertPath = cfg.ReplicationCACertPath
+	destCredentials, err := cfg.ReplicationCredentials()
+	if err != nil {
+		return nil, err
+	}
+	dest, err := s3.NewSigningClient(NewBackend(&destCfg), destCfg.S3Endpoint, destCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	return replication.New(source, dest, replication.Options{
+		ConfigBucket: cfg.ReplicationConfigBucket,
+		Workers:      cfg.ReplicationWorkers,
+		QueueSize:    cfg.ReplicationQueueSize,
+	}), nil
+}
+
+// transit is the Vault transit engine the server uses
+type transit interface {
+	vault.Interface
+	TokenSource() string
+}
+
+// injectedTransit is a transit engine passed in Dependencies
+type injectedTransit struct {
+	vault.Interface
+}
+
+// TokenSource reports that the server holds no Vault token of its own
+func (injectedTransit) TokenSource() string {
+	return "injected"
+}
+
+// newTransit connects to Vault, or in dev mode starts the in-process transit engine
+func newTransit(cfg *config.Config) (transit, error) {
+	if cfg.DevMode {
+		logging.Warn().Msg("Running in dev mode: Vault is replaced by an in-memory transit engine whose keys are lost on restart")
+		return vault.NewDevTransit(), nil
+	}
+	vaultClient, err := vault.NewClient(cfg.VaultAddr, cfg.VaultToken, cfg.VaultTokenPath)
+	if err != nil {
+		return nil, err
+	}
+	return vaultClient.WithMount(cfg.VaultTransitMount), nil
+}
+
+// NewBackend creates the S3 backend client from configuration
+func NewBackend(cfg *config.Config) *s3.Client {
+	return s3.NewClient(cfg.S3Endpoint, cfg.S3CACertPath, s3.TransportConfig{
+		MaxIdleConns:          cfg.S3MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.S3MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.S3MaxConnsPerHost,
+		IdleConnTimeout:       cfg.S3IdleConnTimeout,
+		DialTimeout:           cfg.S3DialTimeout,
+		TLSHandshakeTimeout:   cfg.S3TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.S3ResponseHeaderTimeout,
+		RequestTimeout:        cfg.S3RequestTimeout,
+		KeepAlive:             cfg.S3KeepAlive,
+		DisableKeepAlives:     cfg.S3DisableKeepAlives,
+		EnableHTTP2:           cfg.S3EnableHTTP2,
+		BodyIdleTimeout:       cfg.S3BodyIdleTimeout,
+	}, s3ClientOptions(cfg)...)
+}
+
+// newFastBackend creates the fasthttp client for the configured S3 backend
+func newFastBackend(cfg *config.Config) (*s3.FastClient, error) {
+	return s3.NewFastClient(cfg.S3Endpoint, cfg.S3CACertPath, s3.TransportConfig{
+		MaxConnsPerHost:   cfg.S3MaxConnsPerHost,
+		IdleConnTimeout:   cfg.S3IdleConnTimeout,
+		DialTimeout:       cfg.S3DialTimeout,
+		RequestTimeout:    cfg.S3RequestTimeout,
+		KeepAlive:         cfg.S3KeepAlive,
+		DisableKeepAlives: cfg.S3DisableKeepAlives,
+	}, s3ClientOptions(cfg)...)
+}
+
+// s3ClientOptions applies the Host and header forwarding settings
+func s3ClientOptions(cfg *config.Config) []s3.ClientOption {
+	opts := []s3.ClientOption{s3.WithHostMode(s3.HostMode(cfg.S3HostMode))}
+	if cfg.StripHeaders != nil {
+		opts = append(opts, s3.WithStrippedHeaders(cfg.StripHeaders))
+	}
+	if len(cfg.ForwardHeaders) > 0 {
+		opts = append(opts, s3.WithForwardedHeaders(cfg.ForwardHeaders))
+	}
+	return opts
+}
+
+// newObjectCache builds the memory cache and, when enabled, the disk tier behind it
+func newObjectCache(cfg *config.Config) (*cache.Tiered, error) {
+	tiers := []cache.Tier{{
+		Name:         "memory",
+		Store:        cache.NewLRU(int64(cfg.ObjectCacheMaxBytes), cfg.ObjectCacheTTL),
+		MaxEntrySize: int64(cfg.ObjectCacheMaxObjectSize),
+	}}
+
+	if cfg.DiskCacheEnabled {
+		disk, err := cache.NewDisk(cfg.DiskCachePath, int64(cfg.DiskCacheMaxBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize disk cache: %w", err)
+		}
+		tiers = append(tiers, cache.Tier{
+			Name:         "disk",
+			Store:        disk,
+			MaxEntrySize: int64(cfg.DiskCacheMaxObjectSize),
+		})
+	}
+
+	return cache.NewTiered(tiers...), nil
+}
+
+// tracingMiddleware starts a server span for each request, continuing any
+// trace the client propagated in its traceparent header
+func tracingMiddleware(tracer *tracing.Tracer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		parent, _ := tracing.ParseTraceparent(c.Get(s3.TraceparentHeader))
+		span := tracer.Start(parent, c.Method(), tracing.KindServer)
+		defer span.End()
+
+		span.SetAttribute("http.method", c.Method())
+		span.SetAttribute("url.path", c.Path())
+		span.SetAttribute("client.address", c.IP())
+		c.SetUserContext(tracing.ContextWithSpan(c.UserContext(), span))
+
+		err := c.Next()
+
+		// The matched route is only known once routing has run
+		span.SetName(c.Method() + " " + c.Route().Path)
+		status := c.Response().StatusCode()
+		span.SetAttribute("http.status_code", status)
+		if err != nil {
+			span.SetError(err)
+		} else if status >= 500 {
+			span.SetError(fmt.Errorf("status %d", status))
+		}
+		return err
+	}
+}
+
+// newErrorReporter builds the configured error reporter, or nil when disabled
+func newErrorReporter(cfg *config.Config) (*errreport.Reporter, error) {
+	var sender errreport.Sender
+	switch cfg.ErrorReporting {
+	case "sentry":
+		sentry, err := errreport.NewSentrySender(cfg.SentryDSN, cfg.SentryEnvironment, cfg.Version)
+		if err != nil {
+			return nil, err
+		}
+		sender = sentry
+	case "otlp":
+		sender = errreport.NewOTLPLogsSender(cfg.OTLPEndpoint, cfg.OTelServiceName, cfg.OTLPHeaders)
+	default:
+		return nil, nil
+	}
+
+	logging.Info().
+		Str("backend", cfg.ErrorReporting).
+		Float64("sample_rate", cfg.ErrorReportSampleRate).
+		Msg("Error reporting enabled")
+	return errreport.NewReporter(sender, cfg.ErrorReportSampleRate), nil
+}
+
+// errorReportMiddleware reports requests that end in a 5xx response
+func errorReportMiddleware(reporter *errreport.Reporter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		// Returned errors are turned into responses by the error handler later
+		status := c.Response().StatusCode()
+		message := http.StatusText(status)
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			}
+			message = err.Error()
+		} else if status >= 500 && !c.Response().IsBodyStream() {
+			if code := accesslog.ErrorCode(c.Response().Body()); code != "" {
+				message = code
+			}
+		}
+
+		if status >= 500 {
+			reporter.Report(requestEvent(c, status, message))
+		}
+		return err
+	}
+}
+
+// requestEvent captures the request context of an error report
+func requestEvent(c *fiber.Ctx, status int, message string) errreport.Event {
+	event := errreport.Event{
+		Message:   message,
+		Method:    c.Method(),
+		Path:      c.Path(),
+		Status:    status,
+		RemoteIP:  c.IP(),
+		UserAgent: c.Get("User-Agent"),
+		RequestID: string(c.Response().Header.Peek("x-amz-request-id")),
+		Tags:      map[string]string{"route": c.Route().Path},
+	}
+	if span := tracing.SpanFromContext(c.UserContext()); span != nil {
+		event.TraceID = span.Context().TraceID.String()
+		event.SpanID = span.Context().SpanID.String()
+	}
+	return event
+}
+
+// slowRequestMiddleware logs requests exceeding threshold with the time spent in each phase
+func slowRequestMiddleware(threshold time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		recorder := phases.NewRecorder()
+		c.SetUserContext(phases.ContextWithRecorder(c.UserContext(), recorder))
+
+		start := time.Now()
+		err := c.Next()
+		elapsed := time.Since(start)
+		if elapsed < threshold {
+			return err
+		}
+
+		slowRequestsTotal.Inc(c.Method())
+
+		logEvent := logging.FromContext(c.UserContext()).Warn().
+			Str("method", c.Method()).
+			Str("path", c.Path()).
+			Int("status", c.Response().StatusCode()).
+			Dur("latency", elapsed).
+			Dur("threshold", threshold)
+
+		// Whatever no phase accounts for is proxy overhead (routing, middleware, header handling)
+		durations := recorder.Durations()
+		remaining := elapsed
+		for _, phase := range []string{phases.Auth, phases.Vault, phases.RequestBody, phases.Backend, phases.Serialization} {
+			if d, ok := durations[phase]; ok {
+				logEvent = logEvent.Dur(phase+"_latency", d)
+				remaining -= d
+			}
+		}
+		logEvent.Dur("other_latency", remaining).Msg("Slow request")
+
+		return err
+	}
+}
+
+// accessLogMiddleware writes an S3 server access log line for each S3 API request
+func accessLogMiddleware(logger *accesslog.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+		elapsed := time.Since(start)
+
+		// Only S3 API routes belong in the access log, not health or metrics probes
+		route := c.Route().Path
+		if route != "/" && !strings.HasPrefix(route, "/:bucket") {
+			return err
+		}
+
+		resp := c.Response()
+		record := accesslog.Record{
+			Bucket:         c.Params("bucket"),
+			Time:           start,
+			RemoteIP:       c.IP(),
+			RequestID:      string(resp.Header.Peek("x-amz-request-id")),
+			Operation:      accesslog.Operation(c.Method(), c.Params("bucket"), c.Params("*")),
+			Key:            c.Params("*"),
+			RequestURI:     c.Method() + " " + string(c.Request().RequestURI()) + " " + string(c.Request().Header.Protocol()),
+			HTTPStatus:     resp.StatusCode(),
+			BytesSent:      -1,
+			ObjectSize:     -1,
+			TotalTime:      elapsed,
+			TurnAroundTime: elapsed,
+			Referer:        c.Get("Referer"),
+			UserAgent:      c.Get("User-Agent"),
+			HostHeader:     c.Hostname(),
+		}
+		if record.RequestID == "" {
+			record.RequestID = accesslog.NewRequestID()
+		}
+
+		// Streamed bodies are only measurable through their declared length
+		switch {
+		case c.Method() == fiber.MethodHead || resp.StatusCode() == fiber.StatusNotModified:
+			record.BytesSent = 0
+		case !resp.IsBodyStream():
+			record.BytesSent = int64(len(resp.Body()))
+			if resp.StatusCode() >= 400 {
+				record.ErrorCode = accesslog.ErrorCode(resp.Body())
+			}
+		case resp.Header.ContentLength() >= 0:
+			record.BytesSent = int64(resp.Header.ContentLength())
+		}
+
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead:
+			if record.Key != "" && resp.StatusCode() < 300 {
+				record.ObjectSize = parseSize(string(resp.Header.Peek("Content-Length")), int64(resp.Header.ContentLength()))
+			}
+		case fiber.MethodPut:
+			if record.Key != "" {
+				size := c.Get("X-Amz-Decoded-Content-Length")
+				if size == "" {
+					size = c.Get("Content-Length")
+				}
+				record.ObjectSize = parseSize(size, -1)
+			}
+		}
+
+		if auth := c.Get("Authorization"); auth != "" {
+			record.AuthenticationType = "AuthHeader"
+			if parsed, parseErr := sigv4.ParseAuthorization(auth); parseErr == nil {
+				record.Requester = parsed.AccessKey
+				record.SignatureVersion = "SigV4"
+			} else if strings.HasPrefix(auth, "AWS ") {
+				record.SignatureVersion = "SigV2"
+			}
+		} else if credential := c.Query("X-Amz-Credential"); credential != "" {
+			record.AuthenticationType = "QueryString"
+			record.SignatureVersion = "SigV4"
+			record.Requester, _, _ = strings.Cut(credential, "/")
+		}
+
+		if state := c.Context().TLSConnectionState(); state != nil {
+			record.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+			record.TLSVersion = strings.Replace(tls.VersionName(state.Version), "TLS 1.", "TLSv1.", 1)
+		}
+
+		if logErr := logger.Log(record); logErr != nil {
+			logging.Warn().Err(logErr).Msg("Failed to write access log")
+		}
+		return err
+	}
+}
+
+// requestAccessKey returns the access key a request is signed with, from its
+// Authorization header or presigned URL, or "" for unsigned requests
+func requestAccessKey(c *fiber.Ctx) string {
+	if parsed, err := sigv4.ParseAuthorization(c.Get("Authorization")); err == nil {
+		return parsed.AccessKey
+	}
+	accessKey, _, _ := strings.Cut(c.Query("X-Amz-Credential"), "/")
+	return accessKey
+}
+
+// parseSize parses a decimal size header, returning fallback when it is absent or invalid
+func parseSize(value string, fallback int64) int64 {
+	if size, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return size
+	}
+	return fallback
+}
+
+// metricsHandler serves metrics in the Prometheus text format
+func metricsHandler(c *fiber.Ctx) error {
+	c.Set("Content-Type", metrics.ContentType)
+	return metrics.Write(c)
 }
 
 // errorHandler handles application errors
@@ -188,4 +1370,4 @@ func errorHandler(c *fiber.Ctx, err error) error {
 	return c.Status(code).JSON(fiber.Map{
 		"error": err.Error(),
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/admin"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/maintenance"
+	"s3-vault-proxy/internal/s3"
+)
+
+// bucketStatsCache computes bucket statistics with the operator credentials
+// and serves each bucket's until it is older than the TTL
+type bucketStatsCache struct {
+	client s3.Interface
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucketStatsEntry
+}
+
+// bucketStatsEntry serializes the computations of one bucket so concurrent
+// requests share a result instead of listing the bucket in parallel
+type bucketStatsEntry struct {
+	mu    sync.Mutex
+	stats *maintenance.BucketStats
+}
+
+func newBucketStatsCache(client s3.Interface, ttl time.Duration) *bucketStatsCache {
+	return &bucketStatsCache{
+		client:  client,
+		ttl:     ttl,
+		now:     time.Now,
+		buckets: make(map[string]*bucketStatsEntry),
+	}
+}
+
+// Get returns the statistics of bucket, computing them when there are none
+// younger than the TTL or when refresh is set
+func (c *bucketStatsCache) Get(ctx context.Context, bucket string, refresh bool) (*maintenance.BucketStats, error) {
+	c.mu.Lock()
+	entry, ok := c.buckets[bucket]
+	if !ok {
+		entry = &bucketStatsEntry{}
+		c.buckets[bucket] = entry
+	}
+	c.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if !refresh && entry.stats != nil && c.now().Sub(entry.stats.ComputedAt) < c.ttl {
+		return entry.stats, nil
+	}
+	stats, err := maintenance.Stats(ctx, c.client, bucket)
+	if err != nil {
+		return nil, err
+	}
+	entry.stats = stats
+	return stats, nil
+}
+
+// bucketStatsHandler reports the statistics of a bucket (GET /buckets/<bucket>/stats,
+// ?refresh=true to recompute them)
+func bucketStatsHandler(cache *bucketStatsCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucket, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/buckets/"), "/stats")
+		if !ok || bucket == "" || strings.Contains(bucket, "/") {
+			admin.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+			return
+		}
+		if r.Method != http.MethodGet {
+			admin.WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		refresh := false
+		if value := r.URL.Query().Get("refresh"); value != "" {
+			var err error
+			if refresh, err = strconv.ParseBool(value); err != nil {
+				admin.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "refresh must be true or false"})
+				return
+			}
+		}
+
+		stats, err := cache.Get(r.Context(), bucket, refresh)
+		if err != nil {
+			logging.Error().Err(err).Str("bucket", bucket).Msg("Failed to compute bucket statistics")
+			admin.WriteJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, stats)
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/maintenance"
+	"s3-vault-proxy/internal/s3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketStatsHandler(t *testing.T) {
+	var listings atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/data":
+			listings.Add(1)
+			w.Write([]byte(`<ListBucketResult><Contents><Key>plain.txt</Key><Size>9</Size><LastModified>2024-01-01T00:00:00.000Z</LastModified></Contents></ListBucketResult>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer backend.Close()
+
+	cache := newBucketStatsCache(s3.NewClient(backend.URL, "", s3.DefaultTransportConfig()), time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	handler := bucketStatsHandler(cache)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	w := get("/buckets/data/stats")
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats maintenance.BucketStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, int64(1), stats.Objects)
+	assert.Equal(t, int64(9), stats.PlaintextBytes)
+	assert.Equal(t, int64(1), stats.LastModified[len(stats.LastModified)-1].Objects)
+
+	// Served from the cache until the TTL passes or a refresh is asked for
+	get("/buckets/data/stats")
+	assert.Equal(t, int32(1), listings.Load())
+	get("/buckets/data/stats?refresh=true")
+	assert.Equal(t, int32(2), listings.Load())
+	now = now.Add(2 * time.Minute)
+	get("/buckets/data/stats")
+	assert.Equal(t, int32(3), listings.Load())
+
+	assert.Equal(t, http.StatusBadGateway, get("/buckets/missing/stats").Code)
+	assert.Equal(t, http.StatusNotFound, get("/buckets/data").Code)
+	assert.Equal(t, http.StatusNotFound, get("/buckets/a/b/stats").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/buckets/data/stats?refresh=maybe").Code)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/buckets/data/stats", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"s3-vault-proxy/internal/admin"
+	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/trash"
+)
+
+// trashPurgeInterval is how often expired trash entries are deleted
+const trashPurgeInterval = time.Hour
+
+// trashSchedule deletes trash entries of TRASH_BUCKETS once TRASH_RETENTION
+// has passed
+type trashSchedule struct {
+	trash  *trash.Trash
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newTrashSchedule(cfg *config.Config) (*trashSchedule, error) {
+	credentials, err := cfg.OperatorCredentials()
+	if err != nil {
+		return nil, err
+	}
+	client, err := s3.NewSigningClient(NewBackend(cfg), cfg.S3Endpoint, credentials)
+	if err != nil {
+		return nil, err
+	}
+	return &trashSchedule{trash: trash.New(client, cfg.TrashBuckets, cfg.TrashRetention)}, nil
+}
+
+// Run purges expired entries and returns how many were deleted
+func (s *trashSchedule) Run(ctx context.Context) (interface{}, error) {
+	purged, err := s.trash.Purge(ctx)
+	return map[string]int{"purged": purged}, err
+}
+
+// Start purges the trash every trashPurgeInterval until Stop
+func (s *trashSchedule) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			purged, err := s.trash.Purge(ctx)
+			if err != nil && ctx.Err() == nil {
+				logging.Error().Err(err).Msg("Trash purge failed")
+				continue
+			}
+			if purged > 0 {
+				logging.Info().Int("purged", purged).Msg("Purged expired trash entries")
+			}
+		}
+	}()
+}
+
+// Stop cancels a running purge and waits for the schedule to exit
+func (s *trashSchedule) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+// trashListHandler lists the trash of a bucket (GET ?bucket=B)
+func trashListHandler(t *trash.Trash) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			admin.WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		bucket := r.URL.Query().Get("bucket")
+		if !t.Enabled(bucket) {
+			admin.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "bucket has no trash"})
+			return
+		}
+		entries, err := t.List(r.Context(), bucket)
+		if err != nil {
+			logging.Error().Err(err).Str("bucket", bucket).Msg("Failed to list trash")
+			admin.WriteJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+		if entries == nil {
+			entries = []trash.Entry{}
+		}
+		admin.WriteJSON(w, http.StatusOK, map[string]interface{}{"entries": entries})
+	}
+}
+
+// trashRestoreHandler restores a trash entry to its key (POST ?bucket=B&id=ID&key=K)
+func trashRestoreHandler(t *trash.Trash) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			admin.WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		query := r.URL.Query()
+		bucket, id, key := query.Get("bucket"), query.Get("id"), query.Get("key")
+		if !t.Enabled(bucket) {
+			admin.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "bucket has no trash"})
+			return
+		}
+		if id == "" || key == "" {
+			admin.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "id and key are required"})
+			return
+		}
+
+		err := t.Restore(r.Context(), bucket, id, key)
+		switch {
+		case errors.Is(err, trash.ErrNotFound):
+			admin.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, trash.ErrObjectExists):
+			admin.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		case err != nil:
+			logging.Error().Err(err).Str("bucket", bucket).Str("key", key).Msg("Failed to restore from trash")
+			admin.WriteJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		default:
+			logging.Info().Str("bucket", bucket).Str("key", key).Str("id", id).Msg("Restored object from trash")
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/maintenance"
+	"s3-vault-proxy/internal/s3"
+)
+
+const (
+	// abortUploadsInterval is how often abandoned multipart uploads are looked for
+	abortUploadsInterval = time.Hour
+	// abortUploadsRate bounds the uploads aborted per second so the janitor
+	// doesn't compete with client traffic
+	abortUploadsRate = 50
+)
+
+// abortUploadsSchedule aborts multipart uploads of ABORT_UPLOADS_BUCKETS
+// initiated more than ABORT_UPLOADS_AFTER ago, so parts left by crashed
+// clients don't use storage forever
+type abortUploadsSchedule struct {
+	client  s3.Interface
+	buckets []string
+	after   time.Duration
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func newAbortUploadsSchedule(cfg *config.Config) (*abortUploadsSchedule, error) {
+	credentials, err := cfg.OperatorCredentials()
+	if err != nil {
+		return nil, err
+	}
+	client, err := s3.NewSigningClient(NewBackend(cfg), cfg.S3Endpoint, credentials)
+	if err != nil {
+		return nil, err
+	}
+	return &abortUploadsSchedule{client: client, buckets: cfg.AbortUploadsBuckets, after: cfg.AbortUploadsAfter}, nil
+}
+
+// Run aborts abandoned uploads and returns how many were aborted
+func (s *abortUploadsSchedule) Run(ctx context.Context) (interface{}, error) {
+	progress := maintenance.NewProgress(nil, 0)
+	err := maintenance.AbortUploads(ctx, s.client, maintenance.GCOptions{
+		Buckets:          s.buckets,
+		UploadsOlderThan: s.after,
+		Rate:             abortUploadsRate,
+		Progress:         progress,
+	})
+	return map[string]int{
+		"aborted": progress.Count(maintenance.OutcomeUploadAborted),
+		"failed":  progress.Count(maintenance.OutcomeFailed),
+	}, err
+}
+
+// Start aborts abandoned uploads every abortUploadsInterval until Stop
+func (s *abortUploadsSchedule) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(abortUploadsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			result, err := s.Run(ctx)
+			if err != nil && ctx.Err() == nil {
+				logging.Error().Err(err).Msg("Aborting abandoned uploads failed")
+				continue
+			}
+			if counts := result.(map[string]int); counts["aborted"] > 0 || counts["failed"] > 0 {
+				logging.Info().Int("aborted", counts["aborted"]).Int("failed", counts["failed"]).Msg("Aborted abandoned multipart uploads")
+			}
+		}
+	}()
+}
+
+// Stop cancels a running pass and waits for the schedule to exit
+func (s *abortUploadsSchedule) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
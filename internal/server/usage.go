@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"s3-vault-proxy/internal/admin"
+	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/logging"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/usage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// usageMiddleware accounts each S3 API request to its bucket and access key
+func usageMiddleware(meter *usage.Meter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+		if !strings.HasPrefix(c.Route().Path, "/:bucket") && c.Route().Path != "/" {
+			return err
+		}
+
+		key := c.Params("*")
+		status := c.Response().StatusCode()
+		request := usage.Request{
+			Bucket:    c.Params("bucket"),
+			AccessKey: requestAccessKey(c),
+			Class:     usage.Classify(c.Method(), key),
+		}
+		if status < 300 {
+			switch {
+			case c.Method() == fiber.MethodPut && key != "":
+				size := c.Get("X-Amz-Decoded-Content-Length")
+				if size == "" {
+					size = c.Get("Content-Length")
+				}
+				request.Ingress = parseSize(size, 0)
+			case c.Method() == fiber.MethodGet && key != "":
+				request.Egress = responseSize(c)
+			}
+		}
+		meter.Record(request)
+		return err
+	}
+}
+
+// responseSize returns the body size of a response. Streamed bodies are
+// measured by their declared length, since reading them would consume them.
+func responseSize(c *fiber.Ctx) int64 {
+	if c.Response().IsBodyStream() {
+		return max(int64(c.Response().Header.ContentLength()), 0)
+	}
+	return int64(len(c.Response().Body()))
+}
+
+// usageSchedule saves the usage counters every USAGE_FLUSH_INTERVAL and
+// recounts stored bytes every USAGE_STORAGE_REFRESH
+type usageSchedule struct {
+	meter          *usage.Meter
+	client         s3.Interface // nil when storage is not counted
+	flushInterval  time.Duration
+	storageRefresh time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newUsageSchedule(cfg *config.Config) (*usageSchedule, error) {
+	meter, err := usage.New(cfg.UsageFile)
+	if err != nil {
+		return nil, err
+	}
+	schedule := &usageSchedule{
+		meter:          meter,
+		flushInterval:  cfg.UsageFlushInterval,
+		storageRefresh: cfg.UsageStorageRefresh,
+	}
+	if cfg.UsageStorageRefresh > 0 {
+		credentials, err := cfg.OperatorCredentials()
+		if err != nil {
+			return nil, err
+		}
+		if schedule.client, err = s3.NewSigningClient(NewBackend(cfg), cfg.S3Endpoint, credentials); err != nil {
+			return nil, err
+		}
+	}
+	return schedule, nil
+}
+
+// CountStorage recounts the bytes stored in every bucket, including metadata objects
+func (s *usageSchedule) CountStorage(ctx context.Context) (interface{}, error) {
+	buckets, err := s3.ListBuckets(ctx, s.client)
+	if err != nil {
+		return nil, err
+	}
+	storage := make(map[string]int64, len(buckets))
+	for _, bucket := range buckets {
+		var stored int64
+		err := s3.WalkObjects(ctx, s.client, bucket, "", "", func(object s3.ObjectInfo) error {
+			stored += object.Size
+			return ctx.Err()
+		})
+		if err != nil {
+			return storage, err
+		}
+		storage[bucket] = stored
+	}
+	s.meter.SetStorage(storage)
+	return storage, nil
+}
+
+// Start saves and recounts on their intervals until Stop
+func (s *usageSchedule) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		flush := time.NewTicker(s.flushInterval)
+		defer flush.Stop()
+		var storageTick <-chan time.Time
+		if s.client != nil {
+			storage := time.NewTicker(s.storageRefresh)
+			defer storage.Stop()
+			storageTick = storage.C
+			s.countStorage(ctx)
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				s.save()
+				return
+			case <-flush.C:
+				s.save()
+			case <-storageTick:
+				s.countStorage(ctx)
+			}
+		}
+	}()
+}
+
+// Stop ends the schedule and saves the counters a last time
+func (s *usageSchedule) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+func (s *usageSchedule) countStorage(ctx context.Context) {
+	if _, err := s.CountStorage(ctx); err != nil && ctx.Err() == nil {
+		logging.Error().Err(err).Msg("Failed to count stored bytes for usage accounting")
+	}
+}
+
+func (s *usageSchedule) save() {
+	if err := s.meter.Save(); err != nil {
+		logging.Error().Err(err).Msg("Failed to save usage counters")
+	}
+}
+
+// usageHandler exports the usage counters as JSON or, with ?format=csv, CSV
+func usageHandler(meter *usage.Meter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			admin.WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		report := meter.Report()
+		switch r.URL.Query().Get("format") {
+		case "", "json":
+			admin.WriteJSON(w, http.StatusOK, report)
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="usage.csv"`)
+			if err := usage.WriteCSV(w, report); err != nil {
+				logging.Warn().Err(err).Msg("Failed to write usage export")
+			}
+		default:
+			admin.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be json or csv"})
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"s3-vault-proxy/internal/usage"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageMiddleware(t *testing.T) {
+	meter, err := usage.New("")
+	require.NoError(t, err)
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(usageMiddleware(meter))
+	app.Get("/health", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	app.Get("/:bucket", func(c *fiber.Ctx) error { return c.SendString("<ListBucketResult/>") })
+	app.Put("/:bucket/*", func(c *fiber.Ctx) error { return c.SendStatus(200) })
+	app.Get("/:bucket/*", func(c *fiber.Ctx) error { return c.SendString(strings.Repeat("x", 10)) })
+
+	put := httptest.NewRequest("PUT", "/bucket/key", strings.NewReader("12345"))
+	put.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID/20260101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc")
+	_, err = app.Test(put)
+	require.NoError(t, err)
+	_, err = app.Test(httptest.NewRequest("GET", "/bucket/key?X-Amz-Credential=AKID%2F20260101%2Fus-east-1%2Fs3%2Faws4_request", nil))
+	require.NoError(t, err)
+	_, err = app.Test(httptest.NewRequest("GET", "/bucket", nil))
+	require.NoError(t, err)
+	_, err = app.Test(httptest.NewRequest("GET", "/health", nil))
+	require.NoError(t, err)
+
+	report := meter.Report()
+	require.Len(t, report.Buckets, 1)
+	assert.Equal(t, usage.Counters{ClassARequests: 2, ClassBRequests: 1, IngressBytes: 5, EgressBytes: 10}, report.Buckets[0].Counters)
+	require.Len(t, report.AccessKeys, 2)
+	assert.Equal(t, "AKID", report.AccessKeys[0].Name)
+	assert.Equal(t, usage.Counters{ClassARequests: 1, ClassBRequests: 1, IngressBytes: 5, EgressBytes: 10}, report.AccessKeys[0].Counters)
+	assert.Equal(t, usage.Anonymous, report.AccessKeys[1].Name)
+}
@@ -0,0 +1,122 @@
+package sigv4
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// CanonicalRequest reconstructs the SigV4 canonical request the client should
+// have signed. path must be the raw, still-escaped request path as received.
+// The X-Amz-Signature query parameter of presigned URLs is excluded.
+func CanonicalRequest(method, path, rawQuery string, headers http.Header, signedHeaders []string, payloadHash string) string {
+	if path == "" {
+		path = "/"
+	}
+
+	names := make([]string, len(signedHeaders))
+	for i, name := range signedHeaders {
+		names[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name)
+		canonicalHeaders.WriteByte(':')
+		canonicalHeaders.WriteString(canonicalHeaderValue(headers, name))
+		canonicalHeaders.WriteByte('\n')
+	}
+
+	return strings.Join([]string{
+		method,
+		path,
+		canonicalQuery(rawQuery),
+		canonicalHeaders.String(),
+		strings.Join(names, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// StringToSign builds the SigV4 string to sign for a canonical request
+func StringToSign(amzDate, scope, canonicalRequest string) string {
+	return strings.Join([]string{
+		Algorithm,
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+}
+
+// canonicalQuery sorts and re-encodes query parameters using the SigV4 rules
+func canonicalQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	type param struct{ key, value string }
+	var params []param
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		if key == "X-Amz-Signature" {
+			continue
+		}
+		params = append(params, param{uriEncode(key), uriEncode(value)})
+	}
+
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].key != params[j].key {
+			return params[i].key < params[j].key
+		}
+		return params[i].value < params[j].value
+	})
+
+	encoded := make([]string, len(params))
+	for i, p := range params {
+		encoded[i] = p.key + "=" + p.value
+	}
+	return strings.Join(encoded, "&")
+}
+
+// canonicalHeaderValue joins all values of a header with trimmed, collapsed whitespace
+func canonicalHeaderValue(headers http.Header, name string) string {
+	var values []string
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			values = append(values, v...)
+		}
+	}
+	for i, value := range values {
+		values[i] = strings.Join(strings.Fields(value), " ")
+	}
+	return strings.Join(values, ",")
+}
+
+// uriEncode percent-encodes everything except RFC 3986 unreserved characters
+func uriEncode(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteString("%" + strings.ToUpper(hexByte(c)))
+	}
+	return b.String()
+}
+
+func hexByte(c byte) string {
+	const digits = "0123456789abcdef"
+	return string([]byte{digits[c>>4], digits[c&0x0f]})
+}
@@ -0,0 +1,64 @@
+package sigv4
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Credentials sign requests the proxy originates itself, such as those sent
+// by maintenance commands. Proxied client requests are never re-signed.
+type Credentials struct {
+	AccessKey string
+	SecretKey string
+	Region    string
+}
+
+// Sign adds SigV4 authentication for an S3 request with an unsigned payload.
+// path must already be URI-encoded and host is the backend's host[:port].
+// Only host, x-amz-content-sha256 and x-amz-date are signed.
+func (c Credentials) Sign(method, host, path, rawQuery string, headers http.Header, now time.Time) {
+	c.sign("s3", method, host, path, rawQuery, headers, UnsignedPayload, now)
+}
+
+// SignPayload adds SigV4 authentication for a request to an AWS service other
+// than S3, which needs the payload hashed rather than UNSIGNED-PAYLOAD
+func (c Credentials) SignPayload(service, method, host, path, rawQuery string, headers http.Header, payload []byte, now time.Time) {
+	c.sign(service, method, host, path, rawQuery, headers, hashHex(payload), now)
+}
+
+func (c Credentials) sign(service, method, host, path, rawQuery string, headers http.Header, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	headers["Host"] = []string{host}
+	headers["X-Amz-Date"] = []string{amzDate}
+	headers["X-Amz-Content-Sha256"] = []string{payloadHash}
+
+	auth := &Authorization{
+		AccessKey:     c.AccessKey,
+		Date:          amzDate[:8],
+		Region:        c.Region,
+		Service:       service,
+		SignedHeaders: []string{"host", "x-amz-content-sha256", "x-amz-date"},
+	}
+	canonical := CanonicalRequest(method, path, rawQuery, headers, auth.SignedHeaders, payloadHash)
+	signingKey := SigningKey(c.SecretKey, auth.Date, auth.Region, auth.Service)
+	auth.Signature = hex.EncodeToString(hmacSHA256(signingKey, StringToSign(amzDate, auth.Scope(), canonical)))
+	headers["Authorization"] = []string{auth.String()}
+}
+
+// String formats the authorization as an Authorization header value
+func (a *Authorization) String() string {
+	return fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		Algorithm, a.AccessKey, a.Scope(), strings.Join(a.SignedHeaders, ";"), a.Signature)
+}
+
+// EncodePath URI-encodes every segment of an unescaped path, keeping the slashes
+func EncodePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
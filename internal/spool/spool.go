@@ -0,0 +1,112 @@
+package spool
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+
+	"s3-vault-proxy/internal/metrics"
+)
+
+var spilledBodiesTotal = metrics.NewCounter(
+	"s3_vault_proxy_spooled_request_bodies_total",
+	"Request bodies larger than the spool threshold that were written to disk.",
+)
+
+// Body is a request body held in memory or, above a threshold, in a temp file.
+// Spilled data is encrypted with a per-body key that only lives in memory.
+type Body struct {
+	data []byte
+	file *os.File
+	size int64
+
+	block cipher.Block
+	iv    []byte
+}
+
+// FromBytes wraps a body that is already in memory
+func FromBytes(data []byte) *Body {
+	return &Body{data: data, size: int64(len(data))}
+}
+
+// Read consumes r, keeping up to threshold bytes in memory and spilling
+// anything larger to an encrypted temp file in dir (os.TempDir() when empty).
+func Read(r io.Reader, threshold int64, dir string) (*Body, error) {
+	head, err := io.ReadAll(io.LimitReader(r, threshold+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if int64(len(head)) <= threshold {
+		return FromBytes(head), nil
+	}
+
+	key := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate spool key: %w", err)
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("failed to generate spool iv: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool cipher: %w", err)
+	}
+
+	file, err := os.CreateTemp(dir, "s3-vault-proxy-body-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+
+	body := &Body{file: file, block: block, iv: iv}
+	writer := &cipher.StreamWriter{S: cipher.NewCTR(block, iv), W: file}
+	size, err := io.Copy(writer, io.MultiReader(bytes.NewReader(head), r))
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("failed to spool request body: %w", err)
+	}
+	body.size = size
+
+	spilledBodiesTotal.Inc()
+	return body, nil
+}
+
+// Reader returns a new reader positioned at the start of the body.
+// Readers must be consumed one at a time.
+func (b *Body) Reader() (io.Reader, error) {
+	if b.file == nil {
+		return bytes.NewReader(b.data), nil
+	}
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind spool file: %w", err)
+	}
+	return &cipher.StreamReader{S: cipher.NewCTR(b.block, b.iv), R: b.file}, nil
+}
+
+// Size returns the body length in bytes
+func (b *Body) Size() int64 {
+	return b.size
+}
+
+// Spilled reports whether the body was written to disk
+func (b *Body) Spilled() bool {
+	return b.file != nil
+}
+
+// Close removes the spool file, if any
+func (b *Body) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	name := b.file.Name()
+	closeErr := b.file.Close()
+	b.file = nil
+	if err := os.Remove(name); err != nil {
+		return err
+	}
+	return closeErr
+}
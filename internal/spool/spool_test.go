@@ -0,0 +1,65 @@
+package spool
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead_InMemory(t *testing.T) {
+	body, err := Read(bytes.NewReader([]byte("small body")), 64, t.TempDir())
+	require.NoError(t, err)
+	defer body.Close()
+
+	assert.False(t, body.Spilled())
+	assert.Equal(t, int64(10), body.Size())
+
+	reader, err := body.Reader()
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "small body", string(data))
+}
+
+func TestRead_SpillsToDisk(t *testing.T) {
+	dir := t.TempDir()
+	payload := bytes.Repeat([]byte("confidential "), 1000)
+
+	body, err := Read(bytes.NewReader(payload), 1024, dir)
+	require.NoError(t, err)
+
+	assert.True(t, body.Spilled())
+	assert.Equal(t, int64(len(payload)), body.Size())
+
+	// The spool file must not contain plaintext
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	raw, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(raw, []byte("confidential")))
+
+	// The body can be read more than once
+	for i := 0; i < 2; i++ {
+		reader, err := body.Reader()
+		require.NoError(t, err)
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, payload, data)
+	}
+
+	require.NoError(t, body.Close())
+	files, _ = filepath.Glob(filepath.Join(dir, "*"))
+	assert.Empty(t, files)
+}
+
+func TestFromBytes(t *testing.T) {
+	body := FromBytes([]byte("abc"))
+	assert.Equal(t, int64(3), body.Size())
+	assert.NoError(t, body.Close())
+}
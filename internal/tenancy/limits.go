@@ -0,0 +1,156 @@
+package tenancy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"s3-vault-proxy/internal/metrics"
+	"s3-vault-proxy/internal/s3"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrQuotaExceeded is returned when an upload would take a tenant over its storage quota
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+var (
+	tenantRequestsTotal = metrics.NewCounter(
+		"s3_vault_proxy_tenant_requests_total",
+		"S3 requests per tenant, method and status code.",
+		"tenant", "method", "status",
+	)
+	tenantBytesTotal = metrics.NewCounter(
+		"s3_vault_proxy_tenant_bytes_total",
+		"Object bytes uploaded (in) and downloaded (out) per tenant.",
+		"tenant", "direction",
+	)
+	tenantRejectedTotal = metrics.NewCounter(
+		"s3_vault_proxy_tenant_rejected_total",
+		"Requests rejected per tenant because of its rate limit or storage quota.",
+		"tenant", "reason",
+	)
+	tenantStorageBytes = metrics.NewGauge(
+		"s3_vault_proxy_tenant_storage_bytes",
+		"Bytes stored per tenant as of the last usage refresh plus uploads since.",
+		"tenant",
+	)
+)
+
+// Allow takes a token from the tenant's request rate limit
+func (t *Tenant) Allow() bool {
+	if t.limiter == nil || t.limiter.Allow() {
+		return true
+	}
+	tenantRejectedTotal.Inc(t.Name, "rate")
+	return false
+}
+
+// CheckQuota reports whether the tenant can store size more bytes
+func (t *Tenant) CheckQuota(size int64) error {
+	if t.StorageQuotaBytes <= 0 || t.used.Load()+size <= t.StorageQuotaBytes {
+		return nil
+	}
+	tenantRejectedTotal.Inc(t.Name, "quota")
+	return ErrQuotaExceeded
+}
+
+// Usage returns the bytes the tenant is known to store
+func (t *Tenant) Usage() int64 {
+	return t.used.Load()
+}
+
+// AddUsage accounts for an upload until the next refresh recounts the buckets
+func (t *Tenant) AddUsage(bytes int64) {
+	tenantStorageBytes.Set(float64(t.used.Add(bytes)), t.Name)
+}
+
+func (t *Tenant) setUsage(bytes int64) {
+	t.used.Store(bytes)
+	tenantStorageBytes.Set(float64(bytes), t.Name)
+}
+
+// Observe records a finished request in the tenant's metrics
+func (t *Tenant) Observe(method string, status int, bytesIn, bytesOut int64) {
+	tenantRequestsTotal.Inc(t.Name, method, fmt.Sprint(status))
+	if bytesIn > 0 {
+		tenantBytesTotal.Add(float64(bytesIn), t.Name, "in")
+	}
+	if bytesOut > 0 {
+		tenantBytesTotal.Add(float64(bytesOut), t.Name, "out")
+	}
+}
+
+// newLimiter builds the tenant's rate limiter, nil when it is unlimited
+func (t *Tenant) newLimiter() *rate.Limiter {
+	if t.RequestsPerSecond <= 0 {
+		return nil
+	}
+	burst := t.RequestBurst
+	if burst < 1 {
+		burst = int(t.RequestsPerSecond) + 1
+	}
+	return rate.NewLimiter(rate.Limit(t.RequestsPerSecond), burst)
+}
+
+// HasQuotas reports whether any tenant has a storage quota to refresh
+func (r *Registry) HasQuotas() bool {
+	for _, tenant := range r.tenants {
+		if tenant.StorageQuotaBytes > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RefreshUsage recounts the bytes stored in every tenant's buckets. Buckets
+// are attributed by prefix, so tenants without bucket prefixes are skipped.
+func (r *Registry) RefreshUsage(ctx context.Context, client s3.Interface) error {
+	buckets, err := s3.ListBuckets(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	usage := make(map[*Tenant]int64)
+	for _, bucket := range buckets {
+		owner := r.bucketOwner(bucket)
+		if owner == nil {
+			continue
+		}
+		err := s3.WalkObjects(ctx, client, bucket, "", "", func(object s3.ObjectInfo) error {
+			usage[owner] += object.Size
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, tenant := range r.tenants {
+		if len(tenant.BucketPrefixes) > 0 {
+			tenant.setUsage(usage[tenant])
+		}
+	}
+	return nil
+}
+
+// TenantReport summarizes a tenant's limits and usage for the admin API
+type TenantReport struct {
+	Name              string  `json:"name"`
+	StorageBytes      int64   `json:"storage_bytes"`
+	StorageQuotaBytes int64   `json:"storage_quota_bytes,omitempty"`
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+}
+
+// Report lists every tenant's limits and usage
+func (r *Registry) Report() []TenantReport {
+	report := make([]TenantReport, 0, len(r.tenants))
+	for _, tenant := range r.tenants {
+		report = append(report, TenantReport{
+			Name:              tenant.Name,
+			StorageBytes:      tenant.Usage(),
+			StorageQuotaBytes: tenant.StorageQuotaBytes,
+			RequestsPerSecond: tenant.RequestsPerSecond,
+		})
+	}
+	return report
+}
@@ -0,0 +1,77 @@
+package tenancy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"s3-vault-proxy/internal/s3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllow(t *testing.T) {
+	registry, err := New([]*Tenant{
+		{Name: "limited", AccessKeys: []string{"AK1"}, RequestsPerSecond: 0.001, RequestBurst: 2},
+		{Name: "unlimited", AccessKeys: []string{"AK2"}},
+	})
+	require.NoError(t, err)
+	limited, unlimited := registry.Tenants()[0], registry.Tenants()[1]
+
+	assert.True(t, limited.Allow())
+	assert.True(t, limited.Allow())
+	assert.False(t, limited.Allow(), "burst exhausted")
+	for i := 0; i < 10; i++ {
+		assert.True(t, unlimited.Allow())
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	_, err := New([]*Tenant{{Name: "a", AccessKeys: []string{"AK"}, StorageQuotaBytes: 10}})
+	assert.ErrorContains(t, err, "needs bucket_prefixes")
+
+	registry, err := New([]*Tenant{{Name: "a", BucketPrefixes: []string{"a-"}, StorageQuotaBytes: 10}})
+	require.NoError(t, err)
+	tenant := registry.Tenants()[0]
+	assert.True(t, registry.HasQuotas())
+
+	assert.NoError(t, tenant.CheckQuota(10))
+	tenant.AddUsage(6)
+	assert.NoError(t, tenant.CheckQuota(4))
+	assert.ErrorIs(t, tenant.CheckQuota(5), ErrQuotaExceeded)
+}
+
+func TestRefreshUsage(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<ListAllMyBucketsResult><Buckets><Bucket><Name>a-one</Name></Bucket>`+
+				`<Bucket><Name>a-two</Name></Bucket><Bucket><Name>other</Name></Bucket></Buckets></ListAllMyBucketsResult>`)
+		case "/a-one":
+			fmt.Fprint(w, `<ListBucketResult><Contents><Key>x</Key><Size>100</Size></Contents>`+
+				`<Contents><Key>x.metadata</Key><Size>20</Size></Contents></ListBucketResult>`)
+		case "/a-two":
+			fmt.Fprint(w, `<ListBucketResult><Contents><Key>y</Key><Size>5</Size></Contents></ListBucketResult>`)
+		default:
+			t.Errorf("unexpected listing of %s", r.URL.Path)
+		}
+	}))
+	defer backend.Close()
+
+	registry, err := New([]*Tenant{
+		{Name: "a", BucketPrefixes: []string{"a-"}, StorageQuotaBytes: 1000},
+		{Name: "b", AccessKeys: []string{"AKB"}},
+	})
+	require.NoError(t, err)
+	registry.Tenants()[0].AddUsage(999)
+
+	require.NoError(t, registry.RefreshUsage(context.Background(), s3.NewClient(backend.URL, "", s3.DefaultTransportConfig())))
+	assert.Equal(t, int64(125), registry.Tenants()[0].Usage())
+	assert.Equal(t, []TenantReport{
+		{Name: "a", StorageBytes: 125, StorageQuotaBytes: 1000},
+		{Name: "b"},
+	}, registry.Report())
+}
@@ -0,0 +1,247 @@
+// Package tenancy maps requests to tenants by access key or bucket prefix.
+// Each tenant is limited to its own buckets and KMS keys and may carry its
+// own Vault identity, whose policies then decide which transit keys it can use.
+package tenancy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"s3-vault-proxy/internal/vault"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	// ErrUnknownTenant is returned for requests no tenant claims
+	ErrUnknownTenant = errors.New("request does not belong to any tenant")
+
+	// ErrCrossTenant is returned when a tenant's credentials address a bucket outside its prefixes
+	ErrCrossTenant = errors.New("bucket does not belong to this tenant")
+
+	// ErrKeyNotAllowed is returned when a tenant may not use a KMS key
+	ErrKeyNotAllowed = errors.New("KMS key is not allowed for this tenant")
+)
+
+// capabilityTTL bounds how long a Vault capability answer is reused
+const capabilityTTL = time.Minute
+
+// Capabilities reports what a Vault identity may do with a transit key in a request's scope
+type Capabilities interface {
+	ScopedTransitCapabilities(scope vault.Scope, transitKey string) (canEncrypt, canDecrypt bool, err error)
+}
+
+// Tenant is one isolated consumer of the proxy
+type Tenant struct {
+	Name           string          `json:"name"`
+	AccessKeys     []string        `json:"access_keys,omitempty"`
+	BucketPrefixes []string        `json:"bucket_prefixes,omitempty"`
+	KMSKeys        []string        `json:"kms_keys,omitempty"` // empty allows any key Vault allows
+	Vault          *vault.Identity `json:"vault,omitempty"`
+
+	// Fair-use limits (0 is unlimited). Quotas need bucket prefixes, which
+	// attribute stored bytes to the tenant.
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+	RequestBurst      int     `json:"request_burst,omitempty"`
+	StorageQuotaBytes int64   `json:"storage_quota_bytes,omitempty"`
+
+	limiter      *rate.Limiter
+	used         atomic.Int64
+	capabilities Capabilities
+	bucketScoped bool // capabilities depend on the bucket
+	mu           sync.Mutex
+	checked      map[string]capability
+}
+
+type capability struct {
+	encrypt, decrypt bool
+	expires          time.Time
+}
+
+// OwnsBucket reports whether bucket matches one of the tenant's prefixes
+func (t *Tenant) OwnsBucket(bucket string) bool {
+	for _, prefix := range t.BucketPrefixes {
+		if strings.HasPrefix(bucket, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize checks that the tenant may encrypt (or decrypt) with a KMS key in
+// bucket: the key must be listed when KMSKeys is set, and the tenant's Vault
+// identity must hold the matching transit capability
+func (t *Tenant) Authorize(bucket, kmsKeyARN, transitKey string, encrypt bool) error {
+	if len(t.KMSKeys) > 0 && !contains(t.KMSKeys, kmsKeyARN) {
+		return ErrKeyNotAllowed
+	}
+	if t.capabilities == nil {
+		return nil
+	}
+
+	scope := vault.Scope{Tenant: t.Name}
+	cacheKey := transitKey
+	if t.bucketScoped {
+		scope.Bucket = bucket
+		cacheKey = bucket + "/" + transitKey
+	}
+	t.mu.Lock()
+	cached, ok := t.checked[cacheKey]
+	t.mu.Unlock()
+	if !ok || time.Now().After(cached.expires) {
+		canEncrypt, canDecrypt, err := t.capabilities.ScopedTransitCapabilities(scope, transitKey)
+		if err != nil {
+			return fmt.Errorf("failed to check tenant %s's Vault capabilities: %w", t.Name, err)
+		}
+		cached = capability{encrypt: canEncrypt, decrypt: canDecrypt, expires: time.Now().Add(capabilityTTL)}
+		t.mu.Lock()
+		t.checked[cacheKey] = cached
+		t.mu.Unlock()
+	}
+
+	if (encrypt && !cached.encrypt) || (!encrypt && !cached.decrypt) {
+		return ErrKeyNotAllowed
+	}
+	return nil
+}
+
+// Registry resolves requests to tenants
+type Registry struct {
+	tenants     []*Tenant
+	byAccessKey map[string]*Tenant
+}
+
+// Load reads tenants from a JSON file of the form {"tenants": [...]}
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %w", err)
+	}
+	var file struct {
+		Tenants []*Tenant `json:"tenants"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants file %s: %w", path, err)
+	}
+	return New(file.Tenants)
+}
+
+// New validates tenants and indexes them by access key
+func New(tenants []*Tenant) (*Registry, error) {
+	registry := &Registry{byAccessKey: make(map[string]*Tenant)}
+	names := make(map[string]bool)
+	for _, tenant := range tenants {
+		if tenant.Name == "" {
+			return nil, fmt.Errorf("every tenant needs a name")
+		}
+		if names[tenant.Name] {
+			return nil, fmt.Errorf("duplicate tenant %s", tenant.Name)
+		}
+		names[tenant.Name] = true
+		if len(tenant.AccessKeys) == 0 && len(tenant.BucketPrefixes) == 0 {
+			return nil, fmt.Errorf("tenant %s needs access_keys or bucket_prefixes", tenant.Name)
+		}
+		if tenant.StorageQuotaBytes > 0 && len(tenant.BucketPrefixes) == 0 {
+			return nil, fmt.Errorf("tenant %s needs bucket_prefixes for its storage quota", tenant.Name)
+		}
+		for _, accessKey := range tenant.AccessKeys {
+			if other, ok := registry.byAccessKey[accessKey]; ok {
+				return nil, fmt.Errorf("access key %s belongs to tenants %s and %s", accessKey, other.Name, tenant.Name)
+			}
+			registry.byAccessKey[accessKey] = tenant
+		}
+		tenant.checked = make(map[string]capability)
+		tenant.limiter = tenant.newLimiter()
+		registry.tenants = append(registry.tenants, tenant)
+	}
+	return registry, nil
+}
+
+// Connect creates a Vault client for every tenant with its own identity,
+// checking its capabilities at the transit paths pathTemplate renders
+func (r *Registry) Connect(vaultAddr, transitMount string, pathTemplate vault.PathTemplate) error {
+	for _, tenant := range r.tenants {
+		if tenant.Vault == nil {
+			continue
+		}
+		client, err := vault.NewClientWithIdentity(vaultAddr, *tenant.Vault)
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		tenant.capabilities = client.WithMount(transitMount).WithPathTemplate(pathTemplate)
+		tenant.bucketScoped = pathTemplate.UsesBucket()
+	}
+	return nil
+}
+
+// Tenants returns every configured tenant
+func (r *Registry) Tenants() []*Tenant {
+	return r.tenants
+}
+
+// Resolve finds the tenant of a request. A known access key selects its
+// tenant, which must own bucket when it has bucket prefixes. Otherwise the
+// bucket selects a tenant that is not bound to access keys. An empty bucket
+// (ListBuckets) only needs the tenant.
+func (r *Registry) Resolve(accessKey, bucket string) (*Tenant, error) {
+	owner := r.bucketOwner(bucket)
+	if tenant, ok := r.byAccessKey[accessKey]; ok && accessKey != "" {
+		if bucket == "" || (owner == nil && len(tenant.BucketPrefixes) == 0) || owner == tenant {
+			return tenant, nil
+		}
+		return nil, ErrCrossTenant
+	}
+	if owner != nil && len(owner.AccessKeys) == 0 {
+		return owner, nil
+	}
+	if owner != nil {
+		return nil, ErrCrossTenant
+	}
+	return nil, ErrUnknownTenant
+}
+
+// bucketOwner returns the tenant with the longest prefix matching bucket
+func (r *Registry) bucketOwner(bucket string) *Tenant {
+	if bucket == "" {
+		return nil
+	}
+	var owner *Tenant
+	longest := -1
+	for _, tenant := range r.tenants {
+		for _, prefix := range tenant.BucketPrefixes {
+			if strings.HasPrefix(bucket, prefix) && len(prefix) > longest {
+				owner, longest = tenant, len(prefix)
+			}
+		}
+	}
+	return owner
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// WithTenant returns a context carrying tenant
+func WithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenant)
+}
+
+// FromContext returns the request's tenant, or nil when tenancy is disabled
+func FromContext(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Value(contextKey{}).(*Tenant)
+	return tenant
+}
@@ -0,0 +1,147 @@
+package tenancy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"s3-vault-proxy/internal/vault"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	acmeARN  = "arn:aws:kms:us-east-1:111111111111:key/acme"
+	otherARN = "arn:aws:kms:us-east-1:222222222222:key/other"
+)
+
+func testRegistry(t *testing.T) *Registry {
+	registry, err := New([]*Tenant{
+		{Name: "acme", AccessKeys: []string{"AKACME"}, BucketPrefixes: []string{"acme-"}, KMSKeys: []string{acmeARN}},
+		{Name: "globex", AccessKeys: []string{"AKGLOBEX"}},
+		{Name: "shared", BucketPrefixes: []string{"shared-"}},
+	})
+	require.NoError(t, err)
+	return registry
+}
+
+func TestResolve(t *testing.T) {
+	registry := testRegistry(t)
+
+	tests := []struct {
+		accessKey, bucket string
+		tenant            string
+		err               error
+	}{
+		{"AKACME", "acme-data", "acme", nil},
+		{"AKACME", "", "acme", nil},
+		{"AKACME", "globex-data", "", ErrCrossTenant},
+		{"AKACME", "shared-data", "", ErrCrossTenant},
+		{"AKGLOBEX", "anything", "globex", nil},
+		{"AKGLOBEX", "acme-data", "", ErrCrossTenant},
+		{"AKUNKNOWN", "shared-data", "shared", nil},
+		{"AKUNKNOWN", "acme-data", "", ErrCrossTenant},
+		{"AKUNKNOWN", "other", "", ErrUnknownTenant},
+		{"", "", "", ErrUnknownTenant},
+	}
+	for _, tt := range tests {
+		tenant, err := registry.Resolve(tt.accessKey, tt.bucket)
+		if tt.err != nil {
+			assert.ErrorIs(t, err, tt.err, "%s %s", tt.accessKey, tt.bucket)
+			continue
+		}
+		require.NoError(t, err, "%s %s", tt.accessKey, tt.bucket)
+		assert.Equal(t, tt.tenant, tenant.Name, "%s %s", tt.accessKey, tt.bucket)
+	}
+}
+
+func TestNewRejectsAmbiguousTenants(t *testing.T) {
+	_, err := New([]*Tenant{{Name: "a", AccessKeys: []string{"AK"}}, {Name: "b", AccessKeys: []string{"AK"}}})
+	assert.ErrorContains(t, err, "belongs to tenants a and b")
+
+	_, err = New([]*Tenant{{Name: "a", AccessKeys: []string{"AK1"}}, {Name: "a", AccessKeys: []string{"AK2"}}})
+	assert.ErrorContains(t, err, "duplicate tenant")
+
+	_, err = New([]*Tenant{{Name: "a"}})
+	assert.ErrorContains(t, err, "needs access_keys or bucket_prefixes")
+}
+
+// fakeCapabilities allows encrypting with one transit key and counts lookups
+type fakeCapabilities struct {
+	allowed string
+	lookups int
+	scopes  []vault.Scope
+}
+
+func (f *fakeCapabilities) ScopedTransitCapabilities(scope vault.Scope, transitKey string) (bool, bool, error) {
+	f.lookups++
+	f.scopes = append(f.scopes, scope)
+	if transitKey == "broken" {
+		return false, false, errors.New("vault unavailable")
+	}
+	return transitKey == f.allowed, false, nil
+}
+
+func TestAuthorize(t *testing.T) {
+	registry := testRegistry(t)
+	acme, err := registry.Resolve("AKACME", "acme-data")
+	require.NoError(t, err)
+
+	assert.NoError(t, acme.Authorize("acme-data", acmeARN, "acme-key", true))
+	assert.ErrorIs(t, acme.Authorize("acme-data", otherARN, "other-key", true), ErrKeyNotAllowed)
+
+	capabilities := &fakeCapabilities{allowed: "acme-key"}
+	acme.capabilities = capabilities
+	assert.NoError(t, acme.Authorize("acme-data", acmeARN, "acme-key", true))
+	assert.NoError(t, acme.Authorize("acme-data", acmeARN, "acme-key", true))
+	assert.Equal(t, 1, capabilities.lookups, "capabilities are cached")
+	assert.ErrorIs(t, acme.Authorize("acme-data", acmeARN, "acme-key", false), ErrKeyNotAllowed)
+	assert.Error(t, acme.Authorize("acme-data", acmeARN, "broken", true))
+}
+
+func TestAuthorizeScopesCapabilities(t *testing.T) {
+	registry := testRegistry(t)
+	acme, err := registry.Resolve("AKACME", "acme-data")
+	require.NoError(t, err)
+
+	capabilities := &fakeCapabilities{allowed: "acme-key"}
+	acme.capabilities = capabilities
+	require.NoError(t, acme.Authorize("acme-data", acmeARN, "acme-key", true))
+	require.NoError(t, acme.Authorize("acme-logs", acmeARN, "acme-key", true))
+	assert.Equal(t, []vault.Scope{{Tenant: "acme"}}, capabilities.scopes, "paths without {{bucket}} are checked once per key")
+
+	acme.checked = make(map[string]capability)
+	acme.bucketScoped = true
+	capabilities.scopes = nil
+	require.NoError(t, acme.Authorize("acme-data", acmeARN, "acme-key", true))
+	require.NoError(t, acme.Authorize("acme-logs", acmeARN, "acme-key", true))
+	require.NoError(t, acme.Authorize("acme-logs", acmeARN, "acme-key", true))
+	assert.Equal(t, []vault.Scope{
+		{Tenant: "acme", Bucket: "acme-data"},
+		{Tenant: "acme", Bucket: "acme-logs"},
+	}, capabilities.scopes, "paths with {{bucket}} are checked per bucket")
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"tenants": [
+		{"name": "acme", "access_keys": ["AKACME"], "vault": {"namespace": "acme", "token_path": "/vault/acme-token"}}
+	]}`), 0o600))
+
+	registry, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, registry.Tenants(), 1)
+	assert.Equal(t, "acme", registry.Tenants()[0].Vault.Namespace)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestContext(t *testing.T) {
+	assert.Nil(t, FromContext(context.Background()))
+	tenant := &Tenant{Name: "acme"}
+	assert.Same(t, tenant, FromContext(WithTenant(context.Background(), tenant)))
+}
@@ -0,0 +1,57 @@
+package timeouts
+
+import (
+	"bytes"
+	"time"
+)
+
+// Operation classes with independent timeouts
+const (
+	// Metadata covers HEAD, listings, deletes and bucket operations
+	Metadata = "metadata"
+	// Download covers object GETs
+	Download = "download"
+	// Upload covers object PUTs and POSTs, including multipart parts
+	Upload = "upload"
+)
+
+// Config holds the per-class connection timeouts. Zero keeps the server's
+// default READ_TIMEOUT/WRITE_TIMEOUT.
+type Config struct {
+	Metadata time.Duration
+	Download time.Duration
+	Upload   time.Duration
+}
+
+// Classify returns the operation class of a request from its method and URI
+func Classify(method, requestURI []byte) string {
+	path := requestURI
+	if i := bytes.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	_, key, _ := bytes.Cut(bytes.TrimPrefix(path, []byte("/")), []byte("/"))
+	if len(key) == 0 {
+		return Metadata
+	}
+
+	switch string(method) {
+	case "GET":
+		return Download
+	case "PUT", "POST":
+		return Upload
+	default:
+		return Metadata
+	}
+}
+
+// For returns the timeout for a request, or zero to keep the server default
+func (c Config) For(method, requestURI []byte) time.Duration {
+	switch Classify(method, requestURI) {
+	case Download:
+		return c.Download
+	case Upload:
+		return c.Upload
+	default:
+		return c.Metadata
+	}
+}
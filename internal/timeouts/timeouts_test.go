@@ -0,0 +1,34 @@
+package timeouts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		method, uri, class string
+	}{
+		{"GET", "/", Metadata},
+		{"GET", "/bucket?list-type=2", Metadata},
+		{"PUT", "/bucket", Metadata},
+		{"GET", "/bucket/", Metadata},
+		{"HEAD", "/bucket/key", Metadata},
+		{"DELETE", "/bucket/key", Metadata},
+		{"GET", "/bucket/dir/key.bin", Download},
+		{"PUT", "/bucket/key?partNumber=3&uploadId=abc", Upload},
+		{"POST", "/bucket/key?uploads", Upload},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.class, Classify([]byte(tt.method), []byte(tt.uri)), "%s %s", tt.method, tt.uri)
+	}
+}
+
+func TestFor(t *testing.T) {
+	cfg := Config{Metadata: 10 * time.Second, Upload: time.Hour}
+	assert.Equal(t, 10*time.Second, cfg.For([]byte("HEAD"), []byte("/bucket/key")))
+	assert.Equal(t, time.Hour, cfg.For([]byte("PUT"), []byte("/bucket/key")))
+	assert.Equal(t, time.Duration(0), cfg.For([]byte("GET"), []byte("/bucket/key")), "unset class keeps the server default")
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+)
+
+// Exporter receives finished, sampled spans
+type Exporter interface {
+	Export(span *Span)
+	Shutdown() error
+}
+
+// Tracer creates spans. A nil *Tracer is valid and produces no-op spans.
+type Tracer struct {
+	exporter    Exporter
+	sampleRatio float64
+}
+
+// NewTracer creates a tracer that samples new traces at sampleRatio (0..1).
+// Spans continuing a remote trace follow the caller's sampling decision.
+func NewTracer(exporter Exporter, sampleRatio float64) *Tracer {
+	return &Tracer{
+		exporter:    exporter,
+		sampleRatio: sampleRatio,
+	}
+}
+
+// Start begins a span. When parent is valid the span joins its trace.
+func (t *Tracer) Start(parent SpanContext, name string, kind SpanKind) *Span {
+	if t == nil {
+		return nil
+	}
+
+	span := &Span{
+		tracer:     t,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+
+	if parent.IsValid() {
+		span.ctx = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}
+		span.parent = parent.SpanID
+	} else {
+		span.ctx = SpanContext{TraceID: newTraceID()}
+		span.ctx.Sampled = t.shouldSample(span.ctx.TraceID)
+	}
+	span.ctx.SpanID = newSpanID()
+
+	return span
+}
+
+// Shutdown flushes pending spans
+func (t *Tracer) Shutdown() error {
+	if t == nil {
+		return nil
+	}
+	return t.exporter.Shutdown()
+}
+
+// shouldSample makes a deterministic decision from the trace ID, so every
+// replica that sees the same root trace agrees
+func (t *Tracer) shouldSample(id TraceID) bool {
+	if t.sampleRatio >= 1 {
+		return true
+	}
+	if t.sampleRatio <= 0 {
+		return false
+	}
+	bound := uint64(t.sampleRatio * (1 << 63))
+	return binary.BigEndian.Uint64(id[8:])>>1 < bound
+}
+
+// StartChild begins a span under the span carried by ctx. It returns a no-op
+// span when ctx is not part of a trace.
+func StartChild(ctx context.Context, name string, kind SpanKind) *Span {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return nil
+	}
+	return parent.tracer.Start(parent.ctx, name, kind)
+}
@@ -0,0 +1,137 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	t.Run("valid header", func(t *testing.T) {
+		value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		sc, ok := ParseTraceparent(value)
+		require.True(t, ok)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+		assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+		assert.True(t, sc.Sampled)
+		assert.Equal(t, value, sc.Traceparent())
+	})
+
+	t.Run("not sampled", func(t *testing.T) {
+		sc, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+		require.True(t, ok)
+		assert.False(t, sc.Sampled)
+	})
+
+	invalid := []string{
+		"",
+		"garbage",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+	}
+	for _, value := range invalid {
+		_, ok := ParseTraceparent(value)
+		assert.False(t, ok, value)
+	}
+}
+
+type recordingExporter struct {
+	spans []*Span
+}
+
+func (r *recordingExporter) Export(span *Span) { r.spans = append(r.spans, span) }
+func (r *recordingExporter) Shutdown() error   { return nil }
+
+func TestTracerStart(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracer := NewTracer(exporter, 1)
+
+	root := tracer.Start(SpanContext{}, "root", KindServer)
+	ctx := ContextWithSpan(context.Background(), root)
+	child := StartChild(ctx, "child", KindInternal)
+
+	assert.Equal(t, root.Context().TraceID, child.Context().TraceID)
+	assert.NotEqual(t, root.Context().SpanID, child.Context().SpanID)
+	assert.Equal(t, root.Context().SpanID, child.parent)
+
+	child.End()
+	child.End()
+	root.End()
+	assert.Len(t, exporter.spans, 2)
+}
+
+func TestTracerFollowsParentSampling(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracer := NewTracer(exporter, 1)
+
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	span := tracer.Start(parent, "request", KindServer)
+	span.End()
+
+	assert.False(t, span.Context().Sampled)
+	assert.Empty(t, exporter.spans)
+}
+
+func TestTracerSampleRatio(t *testing.T) {
+	never := NewTracer(&recordingExporter{}, 0)
+	always := NewTracer(&recordingExporter{}, 1)
+	for i := 0; i < 20; i++ {
+		assert.False(t, never.Start(SpanContext{}, "span", KindServer).Context().Sampled)
+		assert.True(t, always.Start(SpanContext{}, "span", KindServer).Context().Sampled)
+	}
+}
+
+func TestNilTracerIsNoop(t *testing.T) {
+	var tracer *Tracer
+	span := tracer.Start(SpanContext{}, "span", KindServer)
+	assert.Nil(t, span)
+
+	span.SetAttribute("key", "value")
+	span.End()
+	assert.False(t, span.Context().IsValid())
+	assert.Nil(t, StartChild(context.Background(), "child", KindInternal))
+	assert.NoError(t, tracer.Shutdown())
+}
+
+func TestOTLPExporter(t *testing.T) {
+	received := make(chan otlpRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+
+		body, _ := io.ReadAll(r.Body)
+		var req otlpRequest
+		assert.NoError(t, json.Unmarshal(body, &req))
+		received <- req
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.URL, "test-service", map[string]string{"X-Api-Key": "secret"})
+	tracer := NewTracer(exporter, 1)
+
+	span := tracer.Start(SpanContext{}, "GET /:bucket/*", KindServer)
+	span.SetAttribute("http.status_code", 200)
+	span.End()
+	require.NoError(t, tracer.Shutdown())
+
+	req := <-received
+	require.Len(t, req.ResourceSpans, 1)
+	assert.Equal(t, "service.name", req.ResourceSpans[0].Resource.Attributes[0].Key)
+	assert.Equal(t, "test-service", *req.ResourceSpans[0].Resource.Attributes[0].Value.StringValue)
+
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 1)
+	assert.Equal(t, span.Context().TraceID.String(), spans[0].TraceID)
+	assert.Equal(t, "GET /:bucket/*", spans[0].Name)
+	assert.Equal(t, KindServer, spans[0].Kind)
+	assert.Equal(t, "200", *spans[0].Attributes[0].Value.IntValue)
+}
@@ -0,0 +1,272 @@
+// Package trash keeps deleted objects of selected buckets for a retention
+// period instead of removing them at once. Objects and their metadata are
+// copied by the backend under .trash/<id>/<key>, so the data stays encrypted
+// and never passes through the proxy. The id is the deletion time.
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/s3"
+)
+
+// Prefix is where trashed objects are kept in their bucket
+const Prefix = ".trash/"
+
+// idLayout formats deletion times as sortable entry ids
+const idLayout = "20060102T150405.000000000Z"
+
+var (
+	// ErrNotFound is returned when a trash entry does not exist
+	ErrNotFound = errors.New("trash entry not found")
+
+	// ErrObjectExists is returned when restoring over an existing object
+	ErrObjectExists = errors.New("an object already exists under the key")
+)
+
+// Entry is one trashed object
+type Entry struct {
+	Bucket    string    `json:"bucket"`
+	ID        string    `json:"id"`
+	Key       string    `json:"key"`
+	Size      int64     `json:"size"`
+	DeletedAt time.Time `json:"deleted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Trash moves deleted objects of its buckets aside. Its client must sign
+// requests with the operator credentials.
+type Trash struct {
+	client    s3.Interface
+	metadata  *metadata.Service
+	buckets   map[string]bool
+	retention time.Duration
+	now       func() time.Time
+}
+
+// New keeps objects deleted from buckets for retention
+func New(client s3.Interface, buckets []string, retention time.Duration) *Trash {
+	enabled := make(map[string]bool, len(buckets))
+	for _, bucket := range buckets {
+		enabled[bucket] = true
+	}
+	return &Trash{
+		client:    client,
+		metadata:  metadata.NewService(client),
+		buckets:   enabled,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Enabled reports whether deletes from bucket go to the trash
+func (t *Trash) Enabled(bucket string) bool {
+	return t != nil && t.buckets[bucket]
+}
+
+// Buckets returns the buckets with a trash
+func (t *Trash) Buckets() []string {
+	buckets := make([]string, 0, len(t.buckets))
+	for bucket := range t.buckets {
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+// IsTrashKey reports whether key lies in the trash area
+func IsTrashKey(key string) bool {
+	return strings.HasPrefix(key, Prefix)
+}
+
+func entryKey(id, key string) string {
+	return Prefix + id + "/" + key
+}
+
+// Move copies an object and its metadata into the trash before it is deleted.
+// It returns nil when the object does not exist, so there is nothing to keep.
+func (t *Trash) Move(ctx context.Context, bucket, key string) (*Entry, error) {
+	deletedAt := t.now().UTC()
+	entry := &Entry{
+		Bucket:    bucket,
+		ID:        deletedAt.Format(idLayout),
+		Key:       key,
+		DeletedAt: deletedAt,
+		ExpiresAt: deletedAt.Add(t.retention),
+	}
+
+	size, found, err := t.copy(ctx, bucket, key, entryKey(entry.ID, key))
+	if err != nil || !found {
+		return nil, err
+	}
+	entry.Size = size
+	if _, _, err := t.copy(ctx, bucket, key+".metadata", entryKey(entry.ID, key)+".metadata"); err != nil {
+		t.Discard(ctx, entry)
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Discard removes an entry from the trash, for example because the delete
+// it was made for failed
+func (t *Trash) Discard(ctx context.Context, entry *Entry) error {
+	if entry == nil {
+		return nil
+	}
+	trashKey := entryKey(entry.ID, entry.Key)
+	if err := t.delete(ctx, entry.Bucket, trashKey+".metadata"); err != nil {
+		return err
+	}
+	return t.delete(ctx, entry.Bucket, trashKey)
+}
+
+// List returns the entries in the trash of bucket, oldest first
+func (t *Trash) List(ctx context.Context, bucket string) ([]Entry, error) {
+	var entries []Entry
+	err := s3.WalkObjects(ctx, t.client, bucket, Prefix, "", func(object s3.ObjectInfo) error {
+		if metadata.IsMetadataKey(object.Key) {
+			return nil
+		}
+		entry, ok := t.parse(bucket, object.Key)
+		if !ok {
+			return nil
+		}
+		entry.Size = object.Size
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// Restore copies an entry back to its key and removes it from the trash. It
+// does not overwrite an object written since the delete.
+func (t *Trash) Restore(ctx context.Context, bucket, id, key string) error {
+	resp, err := t.client.HeadObject(ctx, bucket, key, http.Header{})
+	if err != nil {
+		return fmt.Errorf("failed to check %s/%s: %w", bucket, key, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return ErrObjectExists
+	}
+
+	trashKey := entryKey(id, key)
+	_, found, err := t.copy(ctx, bucket, trashKey, key)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	if _, _, err := t.copy(ctx, bucket, trashKey+".metadata", key+".metadata"); err != nil {
+		return err
+	}
+	return t.Discard(ctx, &Entry{Bucket: bucket, ID: id, Key: key})
+}
+
+// Purge deletes the entries of every trash bucket whose retention has passed
+// and returns how many were deleted
+func (t *Trash) Purge(ctx context.Context) (int, error) {
+	purged := 0
+	for bucket := range t.buckets {
+		entries, err := t.List(ctx, bucket)
+		if err != nil {
+			return purged, err
+		}
+		for i := range entries {
+			if err := ctx.Err(); err != nil {
+				return purged, err
+			}
+			if t.now().Before(entries[i].ExpiresAt) {
+				continue
+			}
+			if err := t.Discard(ctx, &entries[i]); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// parse splits a trash object key into its entry
+func (t *Trash) parse(bucket, trashKey string) (Entry, bool) {
+	id, key, ok := strings.Cut(strings.TrimPrefix(trashKey, Prefix), "/")
+	if !ok || key == "" {
+		return Entry{}, false
+	}
+	deletedAt, err := time.Parse(idLayout, id)
+	if err != nil {
+		return Entry{}, false
+	}
+	return Entry{
+		Bucket:    bucket,
+		ID:        id,
+		Key:       key,
+		DeletedAt: deletedAt,
+		ExpiresAt: deletedAt.Add(t.retention),
+	}, true
+}
+
+// copy has the backend copy src to dst within bucket, keeping the SSE-KMS
+// key of the source and the encryption context its metadata records, which
+// the backend's KMS binds to the copy's data key. It reports the size and
+// false when src does not exist.
+func (t *Trash) copy(ctx context.Context, bucket, src, dst string) (int64, bool, error) {
+	head, err := t.client.HeadObject(ctx, bucket, src, http.Header{})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to check %s/%s: %w", bucket, src, err)
+	}
+	head.Body.Close()
+	if head.StatusCode == http.StatusNotFound {
+		return 0, false, nil
+	}
+	if head.StatusCode != http.StatusOK {
+		return 0, false, fmt.Errorf("failed to check %s/%s: backend returned %d", bucket, src, head.StatusCode)
+	}
+
+	headers := http.Header{"X-Amz-Copy-Source": {s3.ObjectPath(bucket, src)}}
+	if kmsKeyARN := head.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); kmsKeyARN != "" {
+		headers.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+		headers.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", kmsKeyARN)
+		if !metadata.IsMetadataKey(src) {
+			meta, err := t.metadata.Get(ctx, bucket, src, http.Header{})
+			if err != nil && !errors.Is(err, metadata.ErrNotFound) {
+				return 0, false, fmt.Errorf("failed to read the metadata of %s/%s: %w", bucket, src, err)
+			}
+			if meta != nil && len(meta.EncryptionContext) > 0 {
+				headers.Set("X-Amz-Server-Side-Encryption-Context", s3.FormatEncryptionContext(meta.EncryptionContext))
+			}
+		}
+	}
+	resp, err := t.client.ForwardRequest(ctx, "PUT", s3.ObjectPath(bucket, dst), nil, headers, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to copy %s/%s: %w", bucket, src, err)
+	}
+	defer resp.Body.Close()
+
+	// CopyObject can fail after answering 200, reporting the error in the body
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || strings.Contains(string(body), "<Error>") {
+		return 0, false, fmt.Errorf("failed to copy %s/%s to %s: backend returned %d", bucket, src, dst, resp.StatusCode)
+	}
+	return head.ContentLength, true, nil
+}
+
+func (t *Trash) delete(ctx context.Context, bucket, key string) error {
+	resp, err := t.client.ForwardRequest(ctx, "DELETE", s3.ObjectPath(bucket, key), nil, http.Header{}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s/%s: %w", bucket, key, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete %s/%s: backend returned %d", bucket, key, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package trash
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/s3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend is an in-memory path-style S3 backend supporting object
+// GET/PUT/HEAD/DELETE, CopyObject and ListObjectsV2
+type fakeBackend struct {
+	mu       sync.Mutex
+	objects  map[string][]byte // "bucket/key"
+	sse      map[string]string // SSE-KMS key of objects written with one
+	contexts map[string]string // encryption context of objects written with one
+}
+
+func newFakeBackend(t *testing.T) (*fakeBackend, s3.Interface) {
+	backend := &fakeBackend{objects: make(map[string][]byte), sse: make(map[string]string), contexts: make(map[string]string)}
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+	return backend, s3.NewClient(server.URL, "", s3.DefaultTransportConfig())
+}
+
+func (b *fakeBackend) put(bucket, key, body, kmsKey string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.objects[bucket+"/"+key] = []byte(body)
+	b.sse[bucket+"/"+key] = kmsKey
+}
+
+func (b *fakeBackend) get(bucket, key string) (string, string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	body, ok := b.objects[bucket+"/"+key]
+	return string(body), b.sse[bucket+"/"+key], ok
+}
+
+func (b *fakeBackend) encryptionContext(bucket, key string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.contexts[bucket+"/"+key]
+}
+
+func (b *fakeBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if key == "" {
+		b.list(w, r, bucket)
+		return
+	}
+
+	name := bucket + "/" + key
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			sourceName, _ := url.PathUnescape(strings.TrimPrefix(source, "/"))
+			var ok bool
+			if body, ok = b.objects[sourceName]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte("<CopyObjectResult></CopyObjectResult>"))
+		}
+		b.objects[name] = body
+		b.sse[name] = r.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id")
+		b.contexts[name] = r.Header.Get("X-Amz-Server-Side-Encryption-Context")
+	case http.MethodGet, http.MethodHead:
+		body, ok := b.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if kmsKey := b.sse[name]; kmsKey != "" {
+			w.Header().Set("X-Amz-Server-Side-Encryption", "aws:kms")
+			w.Header().Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", kmsKey)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	case http.MethodDelete:
+		delete(b.objects, name)
+		delete(b.sse, name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (b *fakeBackend) list(w http.ResponseWriter, r *http.Request, bucket string) {
+	type content struct {
+		Key  string `xml:"Key"`
+		Size int    `xml:"Size"`
+	}
+	var result struct {
+		XMLName  xml.Name  `xml:"ListBucketResult"`
+		Contents []content `xml:"Contents"`
+	}
+
+	query := r.URL.Query()
+	for name, body := range b.objects {
+		key, ok := strings.CutPrefix(name, bucket+"/")
+		if !ok || !strings.HasPrefix(key, query.Get("prefix")) || key <= query.Get("start-after") {
+			continue
+		}
+		result.Contents = append(result.Contents, content{Key: key, Size: len(body)})
+	}
+	sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+	xml.NewEncoder(w).Encode(result)
+}
+
+func newTestTrash(t *testing.T, now time.Time) (*fakeBackend, *Trash) {
+	backend, client := newFakeBackend(t)
+	trash := New(client, []string{"bucket"}, 24*time.Hour)
+	trash.now = func() time.Time { return now }
+	return backend, trash
+}
+
+func TestMoveAndRestore(t *testing.T) {
+	deletedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	backend, trash := newTestTrash(t, deletedAt)
+	backend.put("bucket", "dir/file name.txt", "ciphertext", "arn:aws:kms:us-east-1:123456789012:key/k")
+	backend.put("bucket", "dir/file name.txt.metadata", `{"content_length":4}`, "")
+
+	entry, err := trash.Move(context.Background(), "bucket", "dir/file name.txt")
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.Equal(t, "20260301T120000.000000000Z", entry.ID)
+	assert.Equal(t, int64(len("ciphertext")), entry.Size)
+	assert.Equal(t, deletedAt.Add(24*time.Hour), entry.ExpiresAt)
+
+	// The copy keeps the SSE-KMS key of the original
+	body, kmsKey, ok := backend.get("bucket", ".trash/20260301T120000.000000000Z/dir/file name.txt")
+	require.True(t, ok)
+	assert.Equal(t, "ciphertext", body)
+	assert.Equal(t, "arn:aws:kms:us-east-1:123456789012:key/k", kmsKey)
+	_, _, ok = backend.get("bucket", ".trash/20260301T120000.000000000Z/dir/file name.txt.metadata")
+	assert.True(t, ok)
+
+	// The client's delete removes the originals
+	backend.mu.Lock()
+	delete(backend.objects, "bucket/dir/file name.txt")
+	delete(backend.objects, "bucket/dir/file name.txt.metadata")
+	backend.mu.Unlock()
+
+	entries, err := trash.List(context.Background(), "bucket")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "dir/file name.txt", entries[0].Key)
+	assert.Equal(t, entry.ID, entries[0].ID)
+
+	require.NoError(t, trash.Restore(context.Background(), "bucket", entry.ID, "dir/file name.txt"))
+	body, _, ok = backend.get("bucket", "dir/file name.txt")
+	require.True(t, ok)
+	assert.Equal(t, "ciphertext", body)
+	_, _, ok = backend.get("bucket", "dir/file name.txt.metadata")
+	assert.True(t, ok)
+
+	entries, err = trash.List(context.Background(), "bucket")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestMoveAndRestoreKeepEncryptionContext(t *testing.T) {
+	backend, trash := newTestTrash(t, time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	backend.put("bucket", "file", "ciphertext", "arn:aws:kms:us-east-1:123456789012:key/k")
+	backend.put("bucket", "file.metadata", `{"encryption_context":{"tenant":"a"}}`, "")
+	encryptionContext := s3.FormatEncryptionContext(map[string]string{"tenant": "a"})
+
+	entry, err := trash.Move(context.Background(), "bucket", "file")
+	require.NoError(t, err)
+	assert.Equal(t, encryptionContext, backend.encryptionContext("bucket", entryKey(entry.ID, "file")))
+
+	backend.mu.Lock()
+	delete(backend.objects, "bucket/file")
+	backend.mu.Unlock()
+	require.NoError(t, trash.Restore(context.Background(), "bucket", entry.ID, "file"))
+	assert.Equal(t, encryptionContext, backend.encryptionContext("bucket", "file"))
+}
+
+func TestMoveMissingObject(t *testing.T) {
+	_, trash := newTestTrash(t, time.Now())
+
+	entry, err := trash.Move(context.Background(), "bucket", "missing")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestRestoreRefusesToOverwrite(t *testing.T) {
+	backend, trash := newTestTrash(t, time.Now())
+	backend.put("bucket", "key", "old", "")
+	entry, err := trash.Move(context.Background(), "bucket", "key")
+	require.NoError(t, err)
+	backend.put("bucket", "key", "new", "")
+
+	assert.ErrorIs(t, trash.Restore(context.Background(), "bucket", entry.ID, "key"), ErrObjectExists)
+	assert.ErrorIs(t, trash.Restore(context.Background(), "bucket", "20260301T120000.000000000Z", "other"), ErrNotFound)
+}
+
+func TestPurge(t *testing.T) {
+	now := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	backend, trash := newTestTrash(t, now)
+	backend.put("bucket", ".trash/20260308T000000.000000000Z/expired", "x", "")
+	backend.put("bucket", ".trash/20260308T000000.000000000Z/expired.metadata", "{}", "")
+	backend.put("bucket", ".trash/20260309T120000.000000000Z/kept", "x", "")
+
+	purged, err := trash.Purge(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, _, ok := backend.get("bucket", ".trash/20260308T000000.000000000Z/expired")
+	assert.False(t, ok)
+	_, _, ok = backend.get("bucket", ".trash/20260308T000000.000000000Z/expired.metadata")
+	assert.False(t, ok)
+	_, _, ok = backend.get("bucket", ".trash/20260309T120000.000000000Z/kept")
+	assert.True(t, ok)
+}
+
+func TestEnabled(t *testing.T) {
+	trash := New(nil, []string{"a"}, time.Hour)
+	assert.True(t, trash.Enabled("a"))
+	assert.False(t, trash.Enabled("b"))
+
+	var disabled *Trash
+	assert.False(t, disabled.Enabled("a"))
+}
@@ -0,0 +1,188 @@
+package uploadlimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/metrics"
+)
+
+var (
+	uploadsInProgress = metrics.NewGauge(
+		"s3_vault_proxy_uploads_in_progress",
+		"Uploads admitted by the upload limiter and not yet finished.",
+	)
+	uploadBytesInProgress = metrics.NewGauge(
+		"s3_vault_proxy_upload_bytes_in_progress",
+		"Declared body bytes of the uploads admitted by the upload limiter.",
+	)
+	uploadsQueued = metrics.NewGauge(
+		"s3_vault_proxy_uploads_queued",
+		"Uploads waiting for the upload limiter to admit them.",
+	)
+	uploadRejectionsTotal = metrics.NewCounter(
+		"s3_vault_proxy_upload_limit_rejections_total",
+		"Uploads rejected with SlowDown because the proxy was at its upload capacity.",
+	)
+)
+
+// ErrBusy is returned for uploads that could not be admitted before their
+// queue timeout
+var ErrBusy = errors.New("upload capacity exhausted")
+
+// Limiter bounds the uploads processed at once by count and by the sum of
+// their declared sizes. Uploads beyond either bound wait in arrival order
+// for up to a queue timeout, then are rejected.
+type Limiter struct {
+	maxUploads   int
+	maxBytes     int64
+	queueTimeout time.Duration
+
+	mu      sync.Mutex
+	uploads int
+	bytes   int64
+	waiters []*waiter
+}
+
+// waiter is an upload queued for admission; admitted is closed once it is
+type waiter struct {
+	size     int64
+	admitted chan struct{}
+}
+
+// NewLimiter admits up to maxUploads uploads declaring up to maxBytes in
+// total (either 0 for no bound). Uploads that do not fit wait up to
+// queueTimeout; with 0 they are rejected at once.
+func NewLimiter(maxUploads int, maxBytes int64, queueTimeout time.Duration) *Limiter {
+	return &Limiter{maxUploads: maxUploads, maxBytes: maxBytes, queueTimeout: queueTimeout}
+}
+
+// Acquire admits an upload declaring size bytes (negative when unknown),
+// waiting for capacity if needed. It returns the function that ends the
+// upload, or ErrBusy or ctx's error. An upload larger than the byte bound is
+// admitted once no other upload holds bytes, and unknown sizes only count
+// against the upload bound.
+func (l *Limiter) Acquire(ctx context.Context, size int64) (func(), error) {
+	size = l.charge(size)
+
+	l.mu.Lock()
+	if len(l.waiters) == 0 && l.fits(size) {
+		l.admit(size)
+		l.mu.Unlock()
+		return l.releaseFunc(size), nil
+	}
+	if l.queueTimeout <= 0 {
+		l.mu.Unlock()
+		uploadRejectionsTotal.Inc()
+		return nil, ErrBusy
+	}
+	w := &waiter{size: size, admitted: make(chan struct{})}
+	l.waiters = append(l.waiters, w)
+	uploadsQueued.Add(1)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-w.admitted:
+		return l.releaseFunc(size), nil
+	case <-timer.C:
+		err = ErrBusy
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-w.admitted:
+		// Admitted while giving up; the capacity is handed on instead
+		l.release(size)
+	default:
+		l.remove(w)
+		// Uploads behind this one may fit now that it no longer blocks them
+		l.admitWaiters()
+	}
+	if errors.Is(err, ErrBusy) {
+		uploadRejectionsTotal.Inc()
+	}
+	return nil, err
+}
+
+// charge returns the bytes an upload declaring size holds while admitted
+func (l *Limiter) charge(size int64) int64 {
+	if size < 0 {
+		return 0
+	}
+	if l.maxBytes > 0 && size > l.maxBytes {
+		return l.maxBytes
+	}
+	return size
+}
+
+// fits reports whether an upload holding size bytes may be admitted now
+func (l *Limiter) fits(size int64) bool {
+	if l.maxUploads > 0 && l.uploads >= l.maxUploads {
+		return false
+	}
+	return l.maxBytes <= 0 || l.bytes+size <= l.maxBytes
+}
+
+func (l *Limiter) admit(size int64) {
+	l.uploads++
+	l.bytes += size
+	uploadsInProgress.Add(1)
+	uploadBytesInProgress.Add(float64(size))
+}
+
+// release returns an upload's capacity and admits the waiters it makes room for
+func (l *Limiter) release(size int64) {
+	l.uploads--
+	l.bytes -= size
+	uploadsInProgress.Add(-1)
+	uploadBytesInProgress.Add(-float64(size))
+	l.admitWaiters()
+}
+
+// admitWaiters admits queued uploads in arrival order for as long as they fit
+func (l *Limiter) admitWaiters() {
+	for len(l.waiters) > 0 && l.fits(l.waiters[0].size) {
+		w := l.waiters[0]
+		l.waiters = l.waiters[1:]
+		uploadsQueued.Add(-1)
+		l.admit(w.size)
+		close(w.admitted)
+	}
+}
+
+func (l *Limiter) remove(w *waiter) {
+	for i, queued := range l.waiters {
+		if queued == w {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			uploadsQueued.Add(-1)
+			return
+		}
+	}
+}
+
+func (l *Limiter) releaseFunc(size int64) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.release(size)
+		})
+	}
+}
+
+// InProgress returns the number of admitted uploads and the bytes they hold
+func (l *Limiter) InProgress() (int, int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.uploads, l.bytes
+}
@@ -0,0 +1,102 @@
+package uploadlimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterBoundsUploads(t *testing.T) {
+	limiter := NewLimiter(2, 0, 0)
+
+	first, err := limiter.Acquire(context.Background(), 10)
+	require.NoError(t, err)
+	second, err := limiter.Acquire(context.Background(), -1)
+	require.NoError(t, err)
+
+	_, err = limiter.Acquire(context.Background(), 10)
+	assert.ErrorIs(t, err, ErrBusy, "no queueing without a queue timeout")
+
+	first()
+	first()
+	uploads, bytes := limiter.InProgress()
+	assert.Equal(t, 1, uploads, "releasing twice is harmless")
+	assert.Equal(t, int64(0), bytes, "unknown sizes hold no bytes")
+
+	third, err := limiter.Acquire(context.Background(), 10)
+	require.NoError(t, err)
+	second()
+	third()
+}
+
+func TestLimiterBoundsBytes(t *testing.T) {
+	limiter := NewLimiter(0, 100, 0)
+
+	first, err := limiter.Acquire(context.Background(), 60)
+	require.NoError(t, err)
+	_, err = limiter.Acquire(context.Background(), 60)
+	assert.ErrorIs(t, err, ErrBusy)
+	small, err := limiter.Acquire(context.Background(), 40)
+	require.NoError(t, err)
+	first()
+	small()
+
+	huge, err := limiter.Acquire(context.Background(), 500)
+	require.NoError(t, err, "an upload over the bound is admitted alone")
+	_, bytes := limiter.InProgress()
+	assert.Equal(t, int64(100), bytes)
+	_, err = limiter.Acquire(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrBusy)
+	huge()
+}
+
+func TestLimiterQueues(t *testing.T) {
+	limiter := NewLimiter(1, 0, time.Second)
+
+	first, err := limiter.Acquire(context.Background(), 10)
+	require.NoError(t, err)
+
+	admitted := make(chan func())
+	go func() {
+		release, err := limiter.Acquire(context.Background(), 10)
+		assert.NoError(t, err)
+		admitted <- release
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	first()
+	select {
+	case release := <-admitted:
+		release()
+	case <-time.After(time.Second):
+		t.Fatal("queued upload was not admitted")
+	}
+
+	uploads, _ := limiter.InProgress()
+	assert.Equal(t, 0, uploads)
+}
+
+func TestLimiterQueueTimeout(t *testing.T) {
+	limiter := NewLimiter(1, 0, 20*time.Millisecond)
+
+	first, err := limiter.Acquire(context.Background(), 10)
+	require.NoError(t, err)
+	defer first()
+
+	start := time.Now()
+	_, err = limiter.Acquire(context.Background(), 10)
+	assert.ErrorIs(t, err, ErrBusy)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = limiter.Acquire(ctx, 10)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	limiter.mu.Lock()
+	assert.Empty(t, limiter.waiters, "abandoned uploads leave the queue")
+	limiter.mu.Unlock()
+}
@@ -0,0 +1,273 @@
+// Package usage accounts requests, traffic and stored bytes per bucket and per
+// access key for chargeback. Counters are cumulative from Since and survive
+// restarts when the meter is backed by a file, so a chargeback period is the
+// difference between two exports.
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Request classes, following S3's pricing tiers
+const (
+	// ClassA covers writes and listings: PUT, POST and bucket GETs
+	ClassA = "class_a"
+	// ClassB covers object reads and HEAD requests
+	ClassB = "class_b"
+	// ClassDelete covers DELETE requests, which S3 does not charge for
+	ClassDelete = "delete"
+)
+
+// Anonymous is the access key recorded for unsigned requests
+const Anonymous = "anonymous"
+
+// Counters are the usage of one bucket or access key
+type Counters struct {
+	StorageBytes   int64 `json:"storage_bytes"`
+	ClassARequests int64 `json:"class_a_requests"`
+	ClassBRequests int64 `json:"class_b_requests"`
+	DeleteRequests int64 `json:"delete_requests"`
+	IngressBytes   int64 `json:"ingress_bytes"`
+	EgressBytes    int64 `json:"egress_bytes"`
+}
+
+// Row is the usage of one bucket or access key in a report
+type Row struct {
+	Name string `json:"name"`
+	Counters
+}
+
+// Report is a snapshot of all counters. Storage is only known for buckets
+// and is as of StorageUpdated.
+type Report struct {
+	Since          time.Time `json:"since"`
+	GeneratedAt    time.Time `json:"generated_at"`
+	StorageUpdated time.Time `json:"storage_updated,omitempty"`
+	Buckets        []Row     `json:"buckets"`
+	AccessKeys     []Row     `json:"access_keys"`
+}
+
+// Request describes one served request
+type Request struct {
+	Bucket    string // "" for service-level requests such as ListBuckets
+	AccessKey string // "" for unsigned requests
+	Class     string
+	Ingress   int64
+	Egress    int64
+}
+
+// state is what the meter persists
+type state struct {
+	Since          time.Time            `json:"since"`
+	StorageUpdated time.Time            `json:"storage_updated,omitempty"`
+	Buckets        map[string]*Counters `json:"buckets"`
+	AccessKeys     map[string]*Counters `json:"access_keys"`
+}
+
+// Meter keeps usage counters in memory and saves them to its file
+type Meter struct {
+	mu    sync.Mutex
+	path  string
+	state state
+	now   func() time.Time
+}
+
+// New creates a meter saved to path, resuming from the counters already
+// saved there. An empty path keeps the counters in memory only.
+func New(path string) (*Meter, error) {
+	m := &Meter{
+		path: path,
+		now:  time.Now,
+		state: state{
+			Since:      time.Now().UTC(),
+			Buckets:    make(map[string]*Counters),
+			AccessKeys: make(map[string]*Counters),
+		},
+	}
+	if path == "" {
+		return m, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage file: %w", err)
+	}
+	var saved state
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse usage file %s: %w", path, err)
+	}
+	if saved.Buckets == nil {
+		saved.Buckets = make(map[string]*Counters)
+	}
+	if saved.AccessKeys == nil {
+		saved.AccessKeys = make(map[string]*Counters)
+	}
+	m.state = saved
+	return m, nil
+}
+
+// Classify returns the request class of a method on a bucket (key "") or object
+func Classify(method, key string) string {
+	switch method {
+	case "DELETE":
+		return ClassDelete
+	case "PUT", "POST":
+		return ClassA
+	case "GET":
+		if key == "" {
+			return ClassA
+		}
+	}
+	return ClassB
+}
+
+// Record accounts for a request
+func (m *Meter) Record(r Request) {
+	accessKey := r.AccessKey
+	if accessKey == "" {
+		accessKey = Anonymous
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r.Bucket != "" {
+		counters(m.state.Buckets, r.Bucket).add(r)
+	}
+	counters(m.state.AccessKeys, accessKey).add(r)
+}
+
+// SetStorage replaces the stored bytes of every bucket with a new count
+func (m *Meter) SetStorage(buckets map[string]int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.state.Buckets {
+		c.StorageBytes = 0
+	}
+	for bucket, bytes := range buckets {
+		counters(m.state.Buckets, bucket).StorageBytes = bytes
+	}
+	m.state.StorageUpdated = m.now().UTC()
+}
+
+// Report returns a snapshot of the counters sorted by name
+func (m *Meter) Report() Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Report{
+		Since:          m.state.Since,
+		GeneratedAt:    m.now().UTC(),
+		StorageUpdated: m.state.StorageUpdated,
+		Buckets:        rows(m.state.Buckets),
+		AccessKeys:     rows(m.state.AccessKeys),
+	}
+}
+
+// Save writes the counters to the meter's file, replacing it atomically
+func (m *Meter) Save() error {
+	if m.path == "" {
+		return nil
+	}
+	m.mu.Lock()
+	data, err := json.Marshal(m.state)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), filepath.Base(m.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save usage: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save usage: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save usage: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.path); err != nil {
+		return fmt.Errorf("failed to save usage: %w", err)
+	}
+	return nil
+}
+
+// csvHeader names the columns WriteCSV writes
+var csvHeader = []string{
+	"dimension", "name", "since", "generated_at", "storage_bytes",
+	"class_a_requests", "class_b_requests", "delete_requests", "ingress_bytes", "egress_bytes",
+}
+
+// WriteCSV writes a report as CSV, one row per bucket and access key. The
+// dimension column tells them apart.
+func WriteCSV(w io.Writer, report Report) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	since := report.Since.Format(time.RFC3339)
+	generatedAt := report.GeneratedAt.Format(time.RFC3339)
+	for _, dimension := range []struct {
+		name string
+		rows []Row
+	}{{"bucket", report.Buckets}, {"access_key", report.AccessKeys}} {
+		for _, row := range dimension.rows {
+			record := []string{dimension.name, row.Name, since, generatedAt}
+			for _, value := range []int64{
+				row.StorageBytes, row.ClassARequests, row.ClassBRequests,
+				row.DeleteRequests, row.IngressBytes, row.EgressBytes,
+			} {
+				record = append(record, strconv.FormatInt(value, 10))
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (c *Counters) add(r Request) {
+	switch r.Class {
+	case ClassA:
+		c.ClassARequests++
+	case ClassB:
+		c.ClassBRequests++
+	case ClassDelete:
+		c.DeleteRequests++
+	}
+	c.IngressBytes += r.Ingress
+	c.EgressBytes += r.Egress
+}
+
+func counters(m map[string]*Counters, name string) *Counters {
+	c, ok := m[name]
+	if !ok {
+		c = &Counters{}
+		m[name] = c
+	}
+	return c
+}
+
+func rows(m map[string]*Counters) []Row {
+	result := make([]Row, 0, len(m))
+	for name, c := range m {
+		result = append(result, Row{Name: name, Counters: *c})
+	}
+	sort.Slice(result, func(i, k int) bool { return result[i].Name < result[k].Name })
+	return result
+}
@@ -0,0 +1,77 @@
+package usage
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	assert.Equal(t, ClassA, Classify("PUT", "key"))
+	assert.Equal(t, ClassA, Classify("POST", ""))
+	assert.Equal(t, ClassA, Classify("GET", ""))
+	assert.Equal(t, ClassB, Classify("GET", "key"))
+	assert.Equal(t, ClassB, Classify("HEAD", ""))
+	assert.Equal(t, ClassDelete, Classify("DELETE", "key"))
+}
+
+func TestRecord(t *testing.T) {
+	m, err := New("")
+	require.NoError(t, err)
+
+	m.Record(Request{Bucket: "b", AccessKey: "AK", Class: ClassA, Ingress: 100})
+	m.Record(Request{Bucket: "b", AccessKey: "AK", Class: ClassB, Egress: 40})
+	m.Record(Request{AccessKey: "AK", Class: ClassA})
+	m.Record(Request{Bucket: "b", Class: ClassDelete})
+	m.SetStorage(map[string]int64{"b": 1000, "c": 5})
+
+	report := m.Report()
+	require.Len(t, report.Buckets, 2)
+	assert.Equal(t, Row{Name: "b", Counters: Counters{
+		StorageBytes: 1000, ClassARequests: 1, ClassBRequests: 1, DeleteRequests: 1, IngressBytes: 100, EgressBytes: 40,
+	}}, report.Buckets[0])
+	assert.Equal(t, int64(5), report.Buckets[1].StorageBytes)
+
+	require.Len(t, report.AccessKeys, 2)
+	assert.Equal(t, "AK", report.AccessKeys[0].Name)
+	assert.Equal(t, int64(2), report.AccessKeys[0].ClassARequests)
+	assert.Equal(t, Anonymous, report.AccessKeys[1].Name)
+	assert.Equal(t, int64(1), report.AccessKeys[1].DeleteRequests)
+	assert.False(t, report.StorageUpdated.IsZero())
+}
+
+func TestSaveAndResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	m, err := New(path)
+	require.NoError(t, err)
+	m.Record(Request{Bucket: "b", AccessKey: "AK", Class: ClassA, Ingress: 7})
+	require.NoError(t, m.Save())
+
+	resumed, err := New(path)
+	require.NoError(t, err)
+	resumed.Record(Request{Bucket: "b", AccessKey: "AK", Class: ClassA, Ingress: 3})
+
+	report := resumed.Report()
+	assert.Equal(t, m.Report().Since, report.Since)
+	assert.Equal(t, int64(2), report.Buckets[0].ClassARequests)
+	assert.Equal(t, int64(10), report.Buckets[0].IngressBytes)
+}
+
+func TestWriteCSV(t *testing.T) {
+	m, err := New("")
+	require.NoError(t, err)
+	m.Record(Request{Bucket: "b", AccessKey: "AK", Class: ClassB, Egress: 12})
+
+	var out strings.Builder
+	require.NoError(t, WriteCSV(&out, m.Report()))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, strings.Join(csvHeader, ","), lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "bucket,b,"))
+	assert.True(t, strings.HasSuffix(lines[1], ",0,0,1,0,0,12"))
+	assert.True(t, strings.HasPrefix(lines[2], "access_key,AK,"))
+}
@@ -0,0 +1,87 @@
+package vault
+
+import (
+	"context"
+	"math/rand"
+
+	"s3-vault-proxy/internal/metrics"
+)
+
+// Canary variants
+const (
+	VariantPrimary = "primary"
+	VariantCanary  = "canary"
+)
+
+var variantOperationsTotal = metrics.NewCounter(
+	"s3_vault_proxy_transit_variant_operations_total",
+	"Transit operations per routing variant (primary or canary), operation and result.",
+	"variant", "operation", "result",
+)
+
+// Canary routes a share of encrypt operations to a second transit mount, for
+// example one holding keys of a new type, while the rest use the primary
+// mount. Ciphertexts carry no mount, so decrypt and rewrap try the primary
+// mount first and fall back to the canary mount.
+type Canary struct {
+	*Client
+	canary *Client
+	ratio  float64
+	sample func() float64
+}
+
+// NewCanary sends ratio (0 to 1) of encrypt operations through canary
+// instead of primary. A nil canary sends everything to primary.
+func NewCanary(primary, canary *Client, ratio float64) *Canary {
+	return &Canary{
+		Client: primary,
+		canary: canary,
+		ratio:  ratio,
+		sample: rand.Float64,
+	}
+}
+
+// Encrypt encrypts data on the mount the sample selects
+func (c *Canary) Encrypt(ctx context.Context, data []byte, transitKey string) (string, error) {
+	variant, client := VariantPrimary, c.Client
+	if c.canary != nil && c.sample() < c.ratio {
+		variant, client = VariantCanary, c.canary
+	}
+	ciphertext, err := client.Encrypt(ctx, data, transitKey)
+	recordVariant(variant, OperationEncrypt, err)
+	return ciphertext, err
+}
+
+// Decrypt decrypts a ciphertext produced by either mount
+func (c *Canary) Decrypt(ctx context.Context, ciphertext string, transitKey string) ([]byte, error) {
+	data, err := c.Client.Decrypt(ctx, ciphertext, transitKey)
+	if err != nil && c.canary != nil {
+		if canaryData, canaryErr := c.canary.Decrypt(ctx, ciphertext, transitKey); canaryErr == nil {
+			recordVariant(VariantCanary, OperationDecrypt, nil)
+			return canaryData, nil
+		}
+	}
+	recordVariant(VariantPrimary, OperationDecrypt, err)
+	return data, err
+}
+
+// Rewrap rewraps a ciphertext on whichever mount produced it
+func (c *Canary) Rewrap(ctx context.Context, ciphertext string, transitKey string) (string, error) {
+	rewrapped, err := c.Client.Rewrap(ctx, ciphertext, transitKey)
+	if err != nil && c.canary != nil {
+		if canaryRewrapped, canaryErr := c.canary.Rewrap(ctx, ciphertext, transitKey); canaryErr == nil {
+			recordVariant(VariantCanary, "rewrap", nil)
+			return canaryRewrapped, nil
+		}
+	}
+	recordVariant(VariantPrimary, "rewrap", err)
+	return rewrapped, err
+}
+
+func recordVariant(variant, operation string, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	variantOperationsTotal.Inc(variant, operation, result)
+}
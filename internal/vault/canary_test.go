@@ -0,0 +1,109 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMountsServer fakes transit engines on several mounts. Ciphertexts name
+// the mount that produced them, so only that mount can decrypt them.
+func newMountsServer(t *testing.T, mounts ...string) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v1/"), "/", 3)
+		known := false
+		for _, mount := range mounts {
+			known = known || parts[0] == mount
+		}
+		if len(parts) != 3 || !known {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		switch parts[1] {
+		case "encrypt":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{
+				"ciphertext": "vault:v1:" + parts[0] + ":" + body["plaintext"],
+			}})
+		case "decrypt", "rewrap":
+			plaintext, ok := strings.CutPrefix(body["ciphertext"], "vault:v1:"+parts[0]+":")
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors":["cipher: message authentication failed"]}`))
+				return
+			}
+			data := map[string]string{"plaintext": plaintext}
+			if parts[1] == "rewrap" {
+				data = map[string]string{"ciphertext": "vault:v2:" + parts[0] + ":" + plaintext}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL, "token", "")
+	require.NoError(t, err)
+	return client
+}
+
+func TestCanaryRoutesEncrypts(t *testing.T) {
+	primary := newMountsServer(t, "transit", "transit-v2")
+	canary := NewCanary(primary, primary.WithMount("transit-v2"), 0.25)
+	samples := []float64{0.1, 0.9}
+	canary.sample = func() float64 {
+		sample := samples[0]
+		samples = samples[1:]
+		return sample
+	}
+	canaryErrors := variantOperationsTotal.Value(VariantCanary, OperationEncrypt, "error")
+
+	routed, err := canary.Encrypt(context.Background(), []byte("a"), "key")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(routed, "vault:v1:transit-v2:"), "sampled below the ratio goes to the canary mount")
+
+	kept, err := canary.Encrypt(context.Background(), []byte("b"), "key")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(kept, "vault:v1:transit:"))
+	assert.Equal(t, canaryErrors, variantOperationsTotal.Value(VariantCanary, OperationEncrypt, "error"))
+
+	// Ciphertexts of either mount decrypt and rewrap through the same client
+	data, err := canary.Decrypt(context.Background(), routed, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+	data, err = canary.Decrypt(context.Background(), kept, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "b", string(data))
+
+	rewrapped, err := canary.Rewrap(context.Background(), routed, "key")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rewrapped, "vault:v2:transit-v2:"))
+
+	_, err = canary.Decrypt(context.Background(), "vault:v1:other:Yw==", "key")
+	assert.Error(t, err)
+}
+
+func TestCanaryRecordsCanaryErrors(t *testing.T) {
+	primary := newMountsServer(t, "transit")
+	canary := NewCanary(primary, primary.WithMount("missing"), 1)
+	before := variantOperationsTotal.Value(VariantCanary, OperationEncrypt, "error")
+
+	_, err := canary.Encrypt(context.Background(), []byte("a"), "key")
+	assert.Error(t, err)
+	assert.Equal(t, before+1, variantOperationsTotal.Value(VariantCanary, OperationEncrypt, "error"))
+}
+
+func TestWithMount(t *testing.T) {
+	client := &Client{}
+	assert.Equal(t, DefaultTransitMount, client.Mount())
+	assert.Equal(t, "transit/encrypt/key", client.transitPath("encrypt", "key"))
+	assert.Equal(t, "transit-v2/encrypt/key", client.WithMount("transit-v2").transitPath("encrypt", "key"))
+	assert.Equal(t, DefaultTransitMount, client.Mount(), "the original client keeps its mount")
+}
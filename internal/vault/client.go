@@ -1,9 +1,11 @@
 package vault
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,15 +16,22 @@ import (
 
 // Client wraps Vault operations for encryption/decryption
 type Client struct {
-	client        *api.Client
-	tokenPath     string
+	client         *api.Client
+	tokenPath      string
 	usingTokenFile bool
+	tokenContent   string // token file content the current token was read from
+	tokenSource    string
+	mount          string // transit secrets engine path, "" is DefaultTransitMount
+	pathTemplate   PathTemplate
 }
 
+// DefaultTransitMount is where the transit secrets engine is mounted unless configured otherwise
+const DefaultTransitMount = "transit"
+
 // Interface defines operations for Vault client
 type Interface interface {
-	Encrypt(data []byte, transitKey string) (string, error)
-	Decrypt(ciphertext string, transitKey string) ([]byte, error)
+	Encrypt(ctx context.Context, data []byte, transitKey string) (string, error)
+	Decrypt(ctx context.Context, ciphertext string, transitKey string) ([]byte, error)
 	ARNToVaultKey(arn string) (string, error)
 	Address() string
 	HealthCheck() error
@@ -60,20 +69,24 @@ func NewClient(vaultAddr, vaultToken, tokenPath string) (*Client, error) {
 // setToken sets the Vault token from various sources and tracks which source was used
 func (c *Client) setToken(vaultToken, tokenPath string) error {
 	// Try token file first
-	if tokenBytes, err := os.ReadFile(tokenPath); err == nil {
-		token := strings.TrimSpace(string(tokenBytes))
-		if token != "" {
-			c.client.SetToken(token)
-			c.usingTokenFile = true
-			logging.Info().Str("token_path", tokenPath).Msg("Using Vault token from file")
-			return nil
-		}
+	file, err := c.readTokenFile(tokenPath)
+	if err != nil {
+		return err
+	}
+	if file.token != "" {
+		c.client.SetToken(file.token)
+		c.usingTokenFile = true
+		c.tokenSource = "file"
+		c.tokenContent = file.content
+		logging.Info().Str("token_path", tokenPath).Str("format", file.format).Msg("Using Vault token from file")
+		return nil
 	}
 
 	// Fall back to environment variable or direct token
 	if vaultToken != "" {
 		c.client.SetToken(vaultToken)
 		c.usingTokenFile = false
+		c.tokenSource = "config"
 		logging.Info().Msg("Using Vault token from environment variable")
 		return nil
 	}
@@ -81,6 +94,7 @@ func (c *Client) setToken(vaultToken, tokenPath string) error {
 	if token := os.Getenv("VAULT_TOKEN"); token != "" {
 		c.client.SetToken(token)
 		c.usingTokenFile = false
+		c.tokenSource = "env"
 		logging.Info().Msg("Using Vault token from VAULT_TOKEN environment variable")
 		return nil
 	}
@@ -99,29 +113,46 @@ func (c *Client) watchTokenFile() {
 	ticker := time.NewTicker(60 * time.Second)
 	defer ticker.Stop()
 
-	var lastToken string
-
 	for range ticker.C {
-		if tokenBytes, err := os.ReadFile(c.tokenPath); err == nil {
-			newToken := strings.TrimSpace(string(tokenBytes))
-			if newToken != "" && newToken != lastToken {
-				c.client.SetToken(newToken)
-				logging.Info().Msg("Updated Vault token from file")
-				lastToken = newToken
-			}
-		}
+		c.reloadTokenFile()
+	}
+}
+
+// reloadTokenFile switches to the token in the token file when the file was
+// rewritten. A wrapped token can only be unwrapped once, so an unchanged file
+// is not read again.
+func (c *Client) reloadTokenFile() {
+	data, err := os.ReadFile(c.tokenPath)
+	if err != nil || string(data) == c.tokenContent {
+		return
+	}
+	file, err := c.readTokenFile(c.tokenPath)
+	if err != nil {
+		logging.Warn().Err(err).Msg("Failed to read Vault token file")
+		return
+	}
+	if file.token != "" {
+		c.client.SetToken(file.token)
+		c.tokenContent = file.content
+		logging.Info().Str("format", file.format).Msg("Updated Vault token from file")
 	}
 }
 
 // Encrypt encrypts data using Vault's transit engine
-func (c *Client) Encrypt(data []byte, transitKey string) (string, error) {
+func (c *Client) Encrypt(ctx context.Context, data []byte, transitKey string) (string, error) {
+	ciphertext, err := c.encrypt(ctx, data, transitKey)
+	RecordKeyUsage("", transitKey, OperationEncrypt, int64(len(data)), err)
+	return ciphertext, err
+}
+
+func (c *Client) encrypt(ctx context.Context, data []byte, transitKey string) (string, error) {
 	if c.client == nil {
 		return "", fmt.Errorf("vault client not configured")
 	}
 
 	plaintext := base64.StdEncoding.EncodeToString(data)
 
-	resp, err := c.client.Logical().Write(fmt.Sprintf("transit/encrypt/%s", transitKey), map[string]interface{}{
+	resp, err := c.client.Logical().WriteWithContext(ctx, c.transitPath("encrypt", transitKey), map[string]interface{}{
 		"plaintext": plaintext,
 	})
 	if err != nil {
@@ -140,16 +171,26 @@ func (c *Client) Encrypt(data []byte, transitKey string) (string, error) {
 	return ciphertext, nil
 }
 
-// Decrypt decrypts data using Vault's transit engine
-func (c *Client) Decrypt(ciphertext string, transitKey string) ([]byte, error) {
+// Decrypt decrypts data using Vault's transit engine. Errors of ciphertexts
+// refused for their key version wrap ErrKeyVersionDisallowed.
+func (c *Client) Decrypt(ctx context.Context, ciphertext string, transitKey string) ([]byte, error) {
+	data, err := c.decrypt(ctx, ciphertext, transitKey)
+	RecordKeyUsage("", transitKey, OperationDecrypt, int64(len(data)), err)
+	return data, err
+}
+
+func (c *Client) decrypt(ctx context.Context, ciphertext string, transitKey string) ([]byte, error) {
 	if c.client == nil {
 		return nil, fmt.Errorf("vault client not configured")
 	}
 
-	resp, err := c.client.Logical().Write(fmt.Sprintf("transit/decrypt/%s", transitKey), map[string]interface{}{
+	resp, err := c.client.Logical().WriteWithContext(ctx, c.transitPath("decrypt", transitKey), map[string]interface{}{
 		"ciphertext": ciphertext,
 	})
 	if err != nil {
+		if IsKeyVersionDisallowed(err) {
+			return nil, fmt.Errorf("vault decryption failed for key %s: %w: %w", transitKey, ErrKeyVersionDisallowed, err)
+		}
 		return nil, fmt.Errorf("vault decryption failed for key %s: %w", transitKey, err)
 	}
 
@@ -170,8 +211,87 @@ func (c *Client) Decrypt(ciphertext string, transitKey string) ([]byte, error) {
 	return data, nil
 }
 
+// Rewrap re-encrypts a transit ciphertext under the newest version of transitKey
+// without the plaintext leaving Vault
+func (c *Client) Rewrap(ctx context.Context, ciphertext string, transitKey string) (string, error) {
+	if c.client == nil {
+		return "", fmt.Errorf("vault client not configured")
+	}
+
+	resp, err := c.client.Logical().WriteWithContext(ctx, c.transitPath("rewrap", transitKey), map[string]interface{}{
+		"ciphertext": ciphertext,
+	})
+	if err != nil {
+		return "", fmt.Errorf("vault rewrap failed for key %s: %w", transitKey, err)
+	}
+
+	if resp == nil || resp.Data == nil {
+		return "", fmt.Errorf("empty response from vault")
+	}
+
+	rewrapped, ok := resp.Data["ciphertext"].(string)
+	if !ok {
+		return "", fmt.Errorf("invalid ciphertext response from vault")
+	}
+
+	return rewrapped, nil
+}
+
+// WithMount returns a client sharing this client's connection and token that
+// uses the transit engine mounted at mount
+func (c *Client) WithMount(mount string) *Client {
+	clone := *c
+	clone.usingTokenFile = false // the original already watches the token file
+	clone.mount = mount
+	return &clone
+}
+
+// Mount returns the path of the transit engine the client uses
+func (c *Client) Mount() string {
+	if c.mount == "" {
+		return DefaultTransitMount
+	}
+	return c.mount
+}
+
+// WithPathTemplate returns a client sharing this client's connection and
+// token whose transit paths are rendered from template
+func (c *Client) WithPathTemplate(template PathTemplate) *Client {
+	clone := *c
+	clone.usingTokenFile = false // the original already watches the token file
+	clone.pathTemplate = template
+	return &clone
+}
+
+// transitPath returns the path of a transit operation on a key
+func (c *Client) transitPath(operation, transitKey string) string {
+	return c.scopedTransitPath(operation, transitKey, Scope{})
+}
+
+// scopedTransitPath returns the path of a transit operation on a key for a request's scope
+func (c *Client) scopedTransitPath(operation, transitKey string, scope Scope) string {
+	return c.pathTemplate.Render(c.Mount(), operation, transitKey, scope)
+}
+
+// CiphertextVersion returns the key version of a vault:v<N>:... transit ciphertext
+func CiphertextVersion(ciphertext string) (int, error) {
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" || !strings.HasPrefix(parts[1], "v") {
+		return 0, fmt.Errorf("not a vault transit ciphertext")
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(parts[1], "v"))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid transit ciphertext version %q", parts[1])
+	}
+	return version, nil
+}
+
 // ARNToVaultKey converts KMS ARN to Vault transit key format
 func (c *Client) ARNToVaultKey(arn string) (string, error) {
+	return arnToVaultKey(arn)
+}
+
+func arnToVaultKey(arn string) (string, error) {
 	if arn == "" {
 		return "", fmt.Errorf("KMS key ARN is required")
 	}
@@ -208,6 +328,11 @@ func (c *Client) ARNToVaultKey(arn string) (string, error) {
 	return vaultKey, nil
 }
 
+// TokenSource reports where the Vault token was loaded from: file, config or env
+func (c *Client) TokenSource() string {
+	return c.tokenSource
+}
+
 // Address returns the Vault server address
 func (c *Client) Address() string {
 	if c.client == nil {
@@ -224,4 +349,90 @@ func (c *Client) HealthCheck() error {
 
 	_, err := c.client.Sys().Health()
 	return err
-}
\ No newline at end of file
+}
+
+// DependencyStatus describes Vault as seen from the proxy
+type DependencyStatus struct {
+	Address        string
+	Reachable      bool
+	Initialized    bool
+	Sealed         bool
+	Standby        bool
+	Version        string
+	TokenSource    string
+	TokenTTL       time.Duration
+	TokenRenewable bool
+	Latency        time.Duration
+	Error          string
+}
+
+// Healthy reports whether Vault can serve transit requests with the current token
+func (s DependencyStatus) Healthy() bool {
+	return s.Reachable && s.Initialized && !s.Sealed && s.Error == ""
+}
+
+// DependencyStatus checks Vault health and looks up the proxy's own token
+func (c *Client) DependencyStatus() DependencyStatus {
+	status := DependencyStatus{
+		Address:     c.Address(),
+		TokenSource: c.tokenSource,
+	}
+	if c.client == nil {
+		status.Error = "vault client not configured"
+		return status
+	}
+
+	start := time.Now()
+	health, err := c.client.Sys().Health()
+	status.Latency = time.Since(start)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Reachable = true
+	status.Initialized = health.Initialized
+	status.Sealed = health.Sealed
+	status.Standby = health.Standby
+	status.Version = health.Version
+
+	token, err := c.client.Auth().Token().LookupSelf()
+	if err != nil {
+		status.Error = fmt.Sprintf("token lookup failed: %v", err)
+		return status
+	}
+	status.TokenTTL, _ = token.TokenTTL()
+	status.TokenRenewable, _ = token.TokenIsRenewable()
+	return status
+}
+
+// TransitCapabilities reports whether the proxy's token may encrypt and decrypt with transitKey
+func (c *Client) TransitCapabilities(transitKey string) (canEncrypt, canDecrypt bool, err error) {
+	return c.ScopedTransitCapabilities(Scope{}, transitKey)
+}
+
+// ScopedTransitCapabilities reports whether the token may encrypt and decrypt
+// with transitKey at the paths the client's template renders for scope
+func (c *Client) ScopedTransitCapabilities(scope Scope, transitKey string) (canEncrypt, canDecrypt bool, err error) {
+	if c.client == nil {
+		return false, false, fmt.Errorf("vault client not configured")
+	}
+
+	allowed := func(path string) (bool, error) {
+		capabilities, err := c.client.Sys().CapabilitiesSelf(path)
+		if err != nil {
+			return false, fmt.Errorf("capability lookup for %s failed: %w", path, err)
+		}
+		for _, capability := range capabilities {
+			if capability == "update" || capability == "root" {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	if canEncrypt, err = allowed(c.scopedTransitPath("encrypt", transitKey, scope)); err != nil {
+		return false, false, err
+	}
+	canDecrypt, err = allowed(c.scopedTransitPath("decrypt", transitKey, scope))
+	return canEncrypt, canDecrypt, err
+}
@@ -1,6 +1,7 @@
 package vault
 
 import (
+	"context"
 	"os"
 	"testing"
 	"time"
@@ -136,13 +137,13 @@ func TestEncryptDecryptErrors(t *testing.T) {
 	client := &Client{}
 
 	t.Run("Encrypt with nil client", func(t *testing.T) {
-		_, err := client.Encrypt([]byte("test"), "key")
+		_, err := client.Encrypt(context.Background(), []byte("test"), "key")
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "vault client not configured")
 	})
 
 	t.Run("Decrypt with nil client", func(t *testing.T) {
-		_, err := client.Decrypt("ciphertext", "key")
+		_, err := client.Decrypt(context.Background(), "ciphertext", "key")
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "vault client not configured")
 	})
@@ -177,4 +178,14 @@ func TestNewClientValidation(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to set vault token")
 	})
-}
\ No newline at end of file
+}
+func TestCiphertextVersion(t *testing.T) {
+	version, err := CiphertextVersion("vault:v3:AbCd==")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, version)
+
+	for _, invalid := range []string{"", "AbCd==", "vault:3:AbCd", "vault:v0:AbCd", "vault:vx:AbCd"} {
+		_, err := CiphertextVersion(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
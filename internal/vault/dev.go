@@ -0,0 +1,133 @@
+package vault
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DevAddress is the address reported by the development transit engine
+const DevAddress = "dev://in-memory"
+
+// DevTransit emulates Vault's transit engine in process for development and
+// CI. Every transit key gets a random AES-256-GCM key the first time it is
+// used, held only in memory, so ciphertexts cannot be decrypted after a
+// restart. Ciphertexts use transit's vault:v1: format.
+type DevTransit struct {
+	mu   sync.Mutex
+	keys map[string]cipher.AEAD
+}
+
+// NewDevTransit creates a development transit engine without any keys
+func NewDevTransit() *DevTransit {
+	return &DevTransit{keys: make(map[string]cipher.AEAD)}
+}
+
+// key returns the AEAD of transitKey, generating it on first use
+func (d *DevTransit) key(transitKey string) (cipher.AEAD, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if aead, ok := d.keys[transitKey]; ok {
+		return aead, nil
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate key %s: %w", transitKey, err)
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	d.keys[transitKey] = aead
+	return aead, nil
+}
+
+// Encrypt seals data with transitKey
+func (d *DevTransit) Encrypt(ctx context.Context, data []byte, transitKey string) (string, error) {
+	ciphertext, err := d.encrypt(ctx, data, transitKey)
+	RecordKeyUsage("", transitKey, OperationEncrypt, int64(len(data)), err)
+	return ciphertext, err
+}
+
+func (d *DevTransit) encrypt(ctx context.Context, data []byte, transitKey string) (string, error) {
+	aead, err := d.key(transitKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, data, []byte(transitKey))
+	return "vault:v1:" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a ciphertext produced by Encrypt with the same transitKey
+func (d *DevTransit) Decrypt(ctx context.Context, ciphertext string, transitKey string) ([]byte, error) {
+	data, err := d.decrypt(ctx, ciphertext, transitKey)
+	RecordKeyUsage("", transitKey, OperationDecrypt, int64(len(data)), err)
+	return data, err
+}
+
+func (d *DevTransit) decrypt(ctx context.Context, ciphertext string, transitKey string) ([]byte, error) {
+	encoded := strings.TrimPrefix(ciphertext, "vault:v1:")
+	if encoded == ciphertext {
+		return nil, fmt.Errorf("dev transit decryption failed for key %s: unsupported ciphertext", transitKey)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("dev transit decryption failed for key %s: %w", transitKey, err)
+	}
+
+	d.mu.Lock()
+	aead, ok := d.keys[transitKey]
+	d.mu.Unlock()
+	if !ok || len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("dev transit decryption failed for key %s: unknown key or ciphertext", transitKey)
+	}
+	data, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(transitKey))
+	if err != nil {
+		return nil, fmt.Errorf("dev transit decryption failed for key %s: %w", transitKey, err)
+	}
+	return data, nil
+}
+
+// Rewrap re-encrypts a ciphertext. Keys have a single version, so it only
+// replaces the nonce.
+func (d *DevTransit) Rewrap(ctx context.Context, ciphertext string, transitKey string) (string, error) {
+	data, err := d.decrypt(ctx, ciphertext, transitKey)
+	if err != nil {
+		return "", err
+	}
+	return d.encrypt(ctx, data, transitKey)
+}
+
+// ARNToVaultKey converts a KMS ARN to a transit key name like the Vault client
+func (d *DevTransit) ARNToVaultKey(arn string) (string, error) {
+	return arnToVaultKey(arn)
+}
+
+// Address returns DevAddress
+func (d *DevTransit) Address() string {
+	return DevAddress
+}
+
+// TokenSource reports that no token is used
+func (d *DevTransit) TokenSource() string {
+	return "dev"
+}
+
+// HealthCheck always succeeds
+func (d *DevTransit) HealthCheck() error {
+	return nil
+}
@@ -0,0 +1,43 @@
+package vault
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevTransit(t *testing.T) {
+	transit := NewDevTransit()
+
+	ciphertext, err := transit.Encrypt(context.Background(), []byte("data key"), "us-east-1_123456789012_a")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(ciphertext, "vault:v1:"))
+	version, err := CiphertextVersion(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	plaintext, err := transit.Decrypt(context.Background(), ciphertext, "us-east-1_123456789012_a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data key"), plaintext)
+
+	_, err = transit.Decrypt(context.Background(), ciphertext, "us-east-1_123456789012_b")
+	assert.Error(t, err, "ciphertexts are bound to their key")
+
+	rewrapped, err := transit.Rewrap(context.Background(), ciphertext, "us-east-1_123456789012_a")
+	require.NoError(t, err)
+	assert.NotEqual(t, ciphertext, rewrapped)
+	plaintext, err = transit.Decrypt(context.Background(), rewrapped, "us-east-1_123456789012_a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data key"), plaintext)
+
+	_, err = NewDevTransit().Decrypt(context.Background(), ciphertext, "us-east-1_123456789012_a")
+	assert.Error(t, err, "keys do not survive a restart")
+
+	key, err := transit.ARNToVaultKey("arn:aws:kms:us-east-1:123456789012:key/a")
+	require.NoError(t, err)
+	assert.Equal(t, "us-east-1_123456789012_a", key)
+	assert.NoError(t, transit.HealthCheck())
+}
@@ -0,0 +1,100 @@
+package vault
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"s3-vault-proxy/internal/logging"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// Identity selects the Vault namespace and credentials of a client. AppRole
+// credentials take precedence over a token; a token file takes precedence
+// over a literal token, as for the proxy's own client.
+type Identity struct {
+	Namespace    string `json:"namespace,omitempty"`
+	Token        string `json:"token,omitempty"`
+	TokenPath    string `json:"token_path,omitempty"`
+	RoleID       string `json:"role_id,omitempty"`
+	SecretIDPath string `json:"secret_id_path,omitempty"`
+	AppRolePath  string `json:"approle_path,omitempty"` // auth mount, default "approle"
+}
+
+// NewClientWithIdentity creates a Vault client that authenticates as identity
+func NewClientWithIdentity(vaultAddr string, identity Identity) (*Client, error) {
+	if identity.RoleID == "" {
+		client, err := NewClient(vaultAddr, identity.Token, identity.TokenPath)
+		if err != nil {
+			return nil, err
+		}
+		client.client.SetNamespace(identity.Namespace)
+		return client, nil
+	}
+
+	config := api.DefaultConfig()
+	if vaultAddr != "" {
+		config.Address = vaultAddr
+	}
+	vaultClient, err := api.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault client: %w", err)
+	}
+	vaultClient.SetNamespace(identity.Namespace)
+
+	client := &Client{client: vaultClient, tokenSource: "approle"}
+	ttl, err := client.loginAppRole(identity)
+	if err != nil {
+		return nil, err
+	}
+	go client.renewAppRole(identity, ttl)
+	return client, nil
+}
+
+// loginAppRole exchanges the role and secret IDs for a token, returning its TTL
+func (c *Client) loginAppRole(identity Identity) (time.Duration, error) {
+	secretID, err := os.ReadFile(identity.SecretIDPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read AppRole secret ID: %w", err)
+	}
+	mount := identity.AppRolePath
+	if mount == "" {
+		mount = "approle"
+	}
+
+	resp, err := c.client.Logical().Write(fmt.Sprintf("auth/%s/login", mount), map[string]interface{}{
+		"role_id":   identity.RoleID,
+		"secret_id": strings.TrimSpace(string(secretID)),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("vault AppRole login failed: %w", err)
+	}
+	if resp == nil || resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return 0, fmt.Errorf("vault AppRole login returned no token")
+	}
+	c.client.SetToken(resp.Auth.ClientToken)
+	return time.Duration(resp.Auth.LeaseDuration) * time.Second, nil
+}
+
+// renewAppRole logs in again at two thirds of each token's TTL, retrying
+// every minute while Vault is unavailable
+func (c *Client) renewAppRole(identity Identity, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	for {
+		time.Sleep(ttl * 2 / 3)
+		next, err := c.loginAppRole(identity)
+		for err != nil {
+			logging.Warn().Err(err).Str("role_id", identity.RoleID).Msg("Vault AppRole login failed, retrying")
+			time.Sleep(time.Minute)
+			next, err = c.loginAppRole(identity)
+		}
+		if next <= 0 {
+			return
+		}
+		ttl = next
+	}
+}
@@ -0,0 +1,56 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClientWithIdentity_AppRole(t *testing.T) {
+	var login map[string]string
+	var namespace, token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/tenants/login":
+			json.NewDecoder(r.Body).Decode(&login)
+			namespace = r.Header.Get("X-Vault-Namespace")
+			w.Write([]byte(`{"auth":{"client_token":"tenant-token","lease_duration":0}}`))
+		case "/v1/sys/capabilities-self":
+			token = r.Header.Get("X-Vault-Token")
+			w.Write([]byte(`{"capabilities":["update"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	secretIDPath := filepath.Join(t.TempDir(), "secret-id")
+	require.NoError(t, os.WriteFile(secretIDPath, []byte("secret\n"), 0o600))
+
+	client, err := NewClientWithIdentity(server.URL, Identity{
+		Namespace:    "acme",
+		RoleID:       "role",
+		SecretIDPath: secretIDPath,
+		AppRolePath:  "tenants",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"role_id": "role", "secret_id": "secret"}, login)
+	assert.Equal(t, "acme", namespace)
+	assert.Equal(t, "approle", client.TokenSource())
+
+	canEncrypt, _, err := client.TransitCapabilities("key")
+	require.NoError(t, err)
+	assert.True(t, canEncrypt)
+	assert.Equal(t, "tenant-token", token)
+}
+
+func TestNewClientWithIdentity_MissingSecretID(t *testing.T) {
+	_, err := NewClientWithIdentity("http://127.0.0.1:1", Identity{RoleID: "role", SecretIDPath: "/nonexistent"})
+	assert.ErrorContains(t, err, "secret ID")
+}
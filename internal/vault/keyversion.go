@@ -0,0 +1,22 @@
+package vault
+
+import (
+	"errors"
+	"strings"
+)
+
+// KeyVersionDisallowedMessage is how Vault's transit engine refuses a
+// ciphertext whose key version is below the key's min_decryption_version.
+// Backends decrypting through Vault pass it on in their error messages.
+const KeyVersionDisallowedMessage = "disallowed by policy (too old)"
+
+// ErrKeyVersionDisallowed is returned when Vault refuses to decrypt a
+// ciphertext because its key version is no longer allowed, typically because
+// min_decryption_version was raised before every data key was rewrapped
+var ErrKeyVersionDisallowed = errors.New("transit key version is disallowed for decryption")
+
+// IsKeyVersionDisallowed reports whether err is Vault refusing a ciphertext
+// for its key version
+func IsKeyVersionDisallowed(err error) bool {
+	return err != nil && (errors.Is(err, ErrKeyVersionDisallowed) || strings.Contains(err.Error(), KeyVersionDisallowedMessage))
+}
@@ -0,0 +1,49 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMinVersionServer fakes a transit key whose min_decryption_version is 2
+func newMinVersionServer(t *testing.T) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if strings.HasPrefix(body["ciphertext"], "vault:v1:") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["ciphertext or signature version is disallowed by policy (too old)"]}`))
+			return
+		}
+		plaintext := strings.TrimPrefix(body["ciphertext"], "vault:v2:")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"plaintext": plaintext}})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL, "token", "")
+	require.NoError(t, err)
+	return client
+}
+
+func TestDecryptReportsDisallowedKeyVersion(t *testing.T) {
+	client := newMinVersionServer(t)
+	_, err := client.Decrypt(context.Background(), "vault:v1:ZGF0YQ==", "key")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrKeyVersionDisallowed))
+	assert.True(t, IsKeyVersionDisallowed(err))
+
+	data, err := client.Decrypt(context.Background(), "vault:v2:ZGF0YQ==", "key")
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+
+	assert.False(t, IsKeyVersionDisallowed(errors.New("cipher: message authentication failed")))
+	assert.False(t, IsKeyVersionDisallowed(nil))
+}
@@ -0,0 +1,99 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sinkToken is a token read from a Vault Agent sink file
+type sinkToken struct {
+	token   string
+	wrapped bool // token is a response-wrapping token to unwrap first
+	format  string
+}
+
+// sinkFile is the union of the JSON sink formats: a wrapped token written by
+// an Agent sink with wrap_ttl, a login response rendered by a template, and
+// the envelope of an encrypted (dh_type) sink
+type sinkFile struct {
+	Token           string `json:"token"`
+	WrappedAccessor string `json:"wrapped_accessor"`
+	CreationPath    string `json:"creation_path"`
+	Auth            *struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	EncryptedPayload string `json:"encrypted_payload"`
+}
+
+// parseSink detects the format of a token file. Plain files hold the token
+// itself; JSON files hold a wrapped token, a login response or a bare token
+// object. Encrypted sinks cannot be read without the Agent's key exchange.
+func parseSink(data []byte) (sinkToken, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return sinkToken{}, nil
+	}
+	if data[0] != '{' {
+		return sinkToken{token: string(data), format: "raw"}, nil
+	}
+
+	var sink sinkFile
+	if err := json.Unmarshal(data, &sink); err != nil {
+		return sinkToken{}, fmt.Errorf("invalid JSON token file: %w", err)
+	}
+	switch {
+	case sink.EncryptedPayload != "":
+		return sinkToken{}, fmt.Errorf("encrypted Vault Agent sinks (dh_type) are not supported")
+	case sink.Auth != nil && sink.Auth.ClientToken != "":
+		return sinkToken{token: sink.Auth.ClientToken, format: "json"}, nil
+	case sink.Token != "" && (sink.WrappedAccessor != "" || sink.CreationPath != ""):
+		return sinkToken{token: sink.Token, wrapped: true, format: "wrapped"}, nil
+	case sink.Token != "":
+		return sinkToken{token: sink.Token, format: "json"}, nil
+	}
+	return sinkToken{}, fmt.Errorf("JSON token file has no token")
+}
+
+// tokenFile is the token read from a token file
+type tokenFile struct {
+	token   string // "" when the file is missing or empty
+	format  string
+	content string // what the file held, to notice when it is rewritten
+}
+
+// readTokenFile returns the token held by the file at path, unwrapping
+// wrapped tokens
+func (c *Client) readTokenFile(path string) (tokenFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tokenFile{}, nil
+	}
+	sink, err := parseSink(data)
+	if err != nil {
+		return tokenFile{}, fmt.Errorf("%s: %w", path, err)
+	}
+	file := tokenFile{token: sink.token, format: sink.format, content: string(data)}
+	if !sink.wrapped {
+		return file, nil
+	}
+
+	// Unwrapped with the wrapping token itself, which needs no other token and
+	// leaves the current one in place until the unwrapped one replaces it
+	unwrapper, err := c.client.Clone()
+	if err != nil {
+		return tokenFile{}, err
+	}
+	unwrapper.SetToken(sink.token)
+	secret, err := unwrapper.Logical().Unwrap("")
+	if err != nil {
+		return tokenFile{}, fmt.Errorf("failed to unwrap token from %s: %w", path, err)
+	}
+	if secret == nil || secret.Auth == nil || strings.TrimSpace(secret.Auth.ClientToken) == "" {
+		return tokenFile{}, fmt.Errorf("wrapped response in %s holds no token", path)
+	}
+	file.token = secret.Auth.ClientToken
+	return file, nil
+}
@@ -0,0 +1,88 @@
+package vault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    sinkToken
+		wantErr string
+	}{
+		{name: "empty", content: "\n", want: sinkToken{}},
+		{name: "raw", content: "hvs.raw\n", want: sinkToken{token: "hvs.raw", format: "raw"}},
+		{
+			name:    "wrapped",
+			content: `{"token":"hvs.wrapping","accessor":"acc","ttl":300,"creation_time":"2024-01-01T00:00:00Z","creation_path":"auth/kubernetes/login","wrapped_accessor":"wacc"}`,
+			want:    sinkToken{token: "hvs.wrapping", wrapped: true, format: "wrapped"},
+		},
+		{
+			name:    "login response",
+			content: `{"request_id":"r","auth":{"client_token":"hvs.login","accessor":"acc","lease_duration":3600}}`,
+			want:    sinkToken{token: "hvs.login", format: "json"},
+		},
+		{name: "token object", content: `{"token":"hvs.object"}`, want: sinkToken{token: "hvs.object", format: "json"}},
+		{name: "encrypted", content: `{"curve25519_public_key":"k","encrypted_payload":"p"}`, wantErr: "not supported"},
+		{name: "no token", content: `{"auth":null}`, wantErr: "no token"},
+		{name: "truncated JSON", content: `{"token":`, wantErr: "invalid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSink([]byte(tt.content))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadTokenFileUnwraps(t *testing.T) {
+	unwraps := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/sys/wrapping/unwrap" || r.Header.Get("X-Vault-Token") != "hvs.wrapping" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		unwraps++
+		w.Write([]byte(`{"auth":{"client_token":"hvs.unwrapped"}}`))
+	}))
+	defer server.Close()
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	apiClient, err := api.NewClient(config)
+	require.NoError(t, err)
+	apiClient.SetToken("hvs.current")
+	client := &Client{client: apiClient}
+
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte(`{"token":"hvs.wrapping","wrapped_accessor":"wacc"}`), 0600))
+	file, err := client.readTokenFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "hvs.unwrapped", file.token)
+	assert.Equal(t, "wrapped", file.format)
+	assert.Equal(t, "hvs.current", apiClient.Token(), "the current token is kept until replaced")
+
+	client.tokenPath = path
+	client.tokenContent = file.content
+	client.reloadTokenFile()
+	assert.Equal(t, 1, unwraps, "an unchanged wrapped token is not unwrapped again")
+
+	require.NoError(t, os.WriteFile(path, []byte("hvs.rotated"), 0600))
+	client.reloadTokenFile()
+	assert.Equal(t, "hvs.rotated", apiClient.Token())
+}
@@ -0,0 +1,81 @@
+package vault
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultPathTemplate is the transit path Vault itself uses
+const DefaultPathTemplate PathTemplate = "{{mount}}/{{operation}}/{{key}}"
+
+// placeholderPattern matches the {{name}} placeholders of a path template
+var placeholderPattern = regexp.MustCompile(`{{\s*([^{}]*?)\s*}}`)
+
+// Scope is the request a transit path is rendered for
+type Scope struct {
+	Tenant string
+	Bucket string
+}
+
+// PathTemplate renders the Vault path of a transit operation from the
+// {{mount}}, {{operation}}, {{key}}, {{tenant}} and {{bucket}} placeholders,
+// so Vault policies can isolate tenants or buckets by path, e.g.
+// transit/{{tenant}}/{{operation}}/{{key}} with one transit mount per tenant
+type PathTemplate string
+
+// ParsePathTemplate validates a path template. It must name the operation and
+// the key, and "" is DefaultPathTemplate.
+func ParsePathTemplate(template string) (PathTemplate, error) {
+	if template == "" {
+		return DefaultPathTemplate, nil
+	}
+	seen := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		switch match[1] {
+		case "mount", "operation", "key", "tenant", "bucket":
+			seen[match[1]] = true
+		default:
+			return "", fmt.Errorf("unknown placeholder {{%s}} in transit path template %q", match[1], template)
+		}
+	}
+	if !seen["operation"] || !seen["key"] {
+		return "", fmt.Errorf("transit path template %q needs {{operation}} and {{key}}", template)
+	}
+	if strings.HasPrefix(template, "/") || strings.HasSuffix(template, "/") {
+		return "", fmt.Errorf("transit path template %q must not start or end with /", template)
+	}
+	return PathTemplate(template), nil
+}
+
+// UsesBucket reports whether rendered paths depend on the request's bucket
+func (t PathTemplate) UsesBucket() bool {
+	for _, match := range placeholderPattern.FindAllStringSubmatch(string(t), -1) {
+		if match[1] == "bucket" {
+			return true
+		}
+	}
+	return false
+}
+
+// Render returns the path of operation on transitKey in mount for scope
+func (t PathTemplate) Render(mount, operation, transitKey string, scope Scope) string {
+	if t == "" {
+		t = DefaultPathTemplate
+	}
+	return placeholderPattern.ReplaceAllStringFunc(string(t), func(placeholder string) string {
+		switch placeholderPattern.FindStringSubmatch(placeholder)[1] {
+		case "mount":
+			return mount
+		case "operation":
+			return operation
+		case "key":
+			return transitKey
+		case "tenant":
+			return scope.Tenant
+		case "bucket":
+			return scope.Bucket
+		}
+		return placeholder
+	})
+}
@@ -0,0 +1,40 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePathTemplate(t *testing.T) {
+	template, err := ParsePathTemplate("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPathTemplate, template)
+
+	template, err = ParsePathTemplate("transit/{{tenant}}/{{ operation }}/{{key}}")
+	require.NoError(t, err)
+	assert.False(t, template.UsesBucket())
+
+	template, err = ParsePathTemplate("{{mount}}/{{operation}}/{{bucket}}-{{key}}")
+	require.NoError(t, err)
+	assert.True(t, template.UsesBucket())
+
+	_, err = ParsePathTemplate("transit/{{tenant}}/encrypt/{{key}}")
+	assert.ErrorContains(t, err, "needs {{operation}} and {{key}}")
+	_, err = ParsePathTemplate("{{mount}}/{{operation}}/{{team}}")
+	assert.ErrorContains(t, err, "unknown placeholder {{team}}")
+	_, err = ParsePathTemplate("/{{mount}}/{{operation}}/{{key}}")
+	assert.Error(t, err)
+}
+
+func TestPathTemplateRender(t *testing.T) {
+	scope := Scope{Tenant: "acme", Bucket: "acme-data"}
+	assert.Equal(t, "transit/encrypt/key", DefaultPathTemplate.Render("transit", "encrypt", "key", scope))
+	assert.Equal(t, "transit/encrypt/key", PathTemplate("").Render("transit", "encrypt", "key", scope))
+	assert.Equal(t, "transit/acme/decrypt/key", PathTemplate("transit/{{tenant}}/{{operation}}/{{key}}").Render("transit", "decrypt", "key", scope))
+	assert.Equal(t, "kv/encrypt/acme-data-key", PathTemplate("kv/{{operation}}/{{bucket}}-{{key}}").Render("transit", "encrypt", "key", scope))
+
+	client := (&Client{}).WithMount("transit-v2").WithPathTemplate("{{mount}}/{{tenant}}/{{operation}}/{{key}}")
+	assert.Equal(t, "transit-v2/acme/encrypt/key", client.scopedTransitPath("encrypt", "key", scope))
+}
@@ -0,0 +1,102 @@
+package vault
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"s3-vault-proxy/internal/metrics"
+)
+
+// Key operations recorded by RecordKeyUsage
+const (
+	OperationEncrypt = "encrypt"
+	OperationDecrypt = "decrypt"
+)
+
+var (
+	keyOperationsTotal = metrics.NewCounter(
+		"s3_vault_proxy_kms_key_operations_total",
+		"Encrypt and decrypt operations per KMS key and transit key.",
+		"kms_arn", "transit_key", "operation", "success",
+	)
+	keyBytesTotal = metrics.NewCounter(
+		"s3_vault_proxy_kms_key_bytes_total",
+		"Bytes protected by each KMS key and transit key.",
+		"kms_arn", "transit_key", "operation",
+	)
+)
+
+// KeyUsage summarizes how a key has been used since the process started
+type KeyUsage struct {
+	KMSKeyARN    string    `json:"kms_arn,omitempty"`
+	TransitKey   string    `json:"transit_key,omitempty"`
+	Encrypts     int64     `json:"encrypts"`
+	Decrypts     int64     `json:"decrypts"`
+	Errors       int64     `json:"errors"`
+	BytesEncrypt int64     `json:"bytes_encrypted"`
+	BytesDecrypt int64     `json:"bytes_decrypted"`
+	LastUsed     time.Time `json:"last_used"`
+}
+
+type usageKey struct {
+	arn, transitKey string
+}
+
+var usage = struct {
+	sync.Mutex
+	keys map[usageKey]*KeyUsage
+}{keys: make(map[usageKey]*KeyUsage)}
+
+// RecordKeyUsage counts one operation against a key. Either identifier may be
+// empty when only one is known at the call site.
+func RecordKeyUsage(kmsKeyARN, transitKey, operation string, bytes int64, err error) {
+	success := err == nil
+	if bytes < 0 {
+		bytes = 0
+	}
+	keyOperationsTotal.Inc(kmsKeyARN, transitKey, operation, strconv.FormatBool(success))
+	if success {
+		keyBytesTotal.Add(float64(bytes), kmsKeyARN, transitKey, operation)
+	}
+
+	usage.Lock()
+	defer usage.Unlock()
+
+	id := usageKey{arn: kmsKeyARN, transitKey: transitKey}
+	entry, ok := usage.keys[id]
+	if !ok {
+		entry = &KeyUsage{KMSKeyARN: kmsKeyARN, TransitKey: transitKey}
+		usage.keys[id] = entry
+	}
+	entry.LastUsed = time.Now().UTC()
+
+	if !success {
+		entry.Errors++
+		return
+	}
+	switch operation {
+	case OperationEncrypt:
+		entry.Encrypts++
+		entry.BytesEncrypt += bytes
+	case OperationDecrypt:
+		entry.Decrypts++
+		entry.BytesDecrypt += bytes
+	}
+}
+
+// KeyUsageReport returns usage for every key seen, most recently used first
+func KeyUsageReport() []KeyUsage {
+	usage.Lock()
+	report := make([]KeyUsage, 0, len(usage.keys))
+	for _, entry := range usage.keys {
+		report = append(report, *entry)
+	}
+	usage.Unlock()
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].LastUsed.After(report[j].LastUsed)
+	})
+	return report
+}
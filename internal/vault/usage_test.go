@@ -0,0 +1,50 @@
+package vault
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func findUsage(report []KeyUsage, arn, transitKey string) *KeyUsage {
+	for i := range report {
+		if report[i].KMSKeyARN == arn && report[i].TransitKey == transitKey {
+			return &report[i]
+		}
+	}
+	return nil
+}
+
+func TestRecordKeyUsage(t *testing.T) {
+	arn := "arn:aws:kms:us-east-1:123456789012:key/usage-test"
+	transitKey := "us-east-1_123456789012_usage-test"
+	before := keyOperationsTotal.Value(arn, transitKey, OperationEncrypt, "true")
+
+	RecordKeyUsage(arn, transitKey, OperationEncrypt, 100, nil)
+	RecordKeyUsage(arn, transitKey, OperationEncrypt, 50, nil)
+	RecordKeyUsage(arn, transitKey, OperationDecrypt, -1, nil)
+	RecordKeyUsage(arn, transitKey, OperationDecrypt, 0, errors.New("backend failure"))
+
+	entry := findUsage(KeyUsageReport(), arn, transitKey)
+	require.NotNil(t, entry)
+	assert.Equal(t, int64(2), entry.Encrypts)
+	assert.Equal(t, int64(150), entry.BytesEncrypt)
+	assert.Equal(t, int64(1), entry.Decrypts)
+	assert.Equal(t, int64(0), entry.BytesDecrypt)
+	assert.Equal(t, int64(1), entry.Errors)
+	assert.False(t, entry.LastUsed.IsZero())
+
+	assert.Equal(t, before+2, keyOperationsTotal.Value(arn, transitKey, OperationEncrypt, "true"))
+	assert.Equal(t, float64(1), keyOperationsTotal.Value(arn, transitKey, OperationDecrypt, "false"))
+}
+
+func TestKeyUsageReportOrdering(t *testing.T) {
+	RecordKeyUsage("", "older-key", OperationEncrypt, 1, nil)
+	RecordKeyUsage("", "newer-key", OperationEncrypt, 1, nil)
+
+	report := KeyUsageReport()
+	require.NotEmpty(t, report)
+	assert.Equal(t, "newer-key", report[0].TransitKey)
+}
@@ -0,0 +1,48 @@
+package workpool
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	t.Run("Respects concurrency limit", func(t *testing.T) {
+		var running, peak int32
+		errs := Run(20, 3, func(i int) error {
+			current := atomic.AddInt32(&running, 1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+					break
+				}
+			}
+			atomic.AddInt32(&running, -1)
+			return nil
+		})
+
+		assert.Len(t, errs, 20)
+		assert.NoError(t, FirstError(errs))
+		assert.LessOrEqual(t, atomic.LoadInt32(&peak), int32(3))
+	})
+
+	t.Run("Collects errors by index", func(t *testing.T) {
+		failure := errors.New("part failed")
+		errs := Run(5, 2, func(i int) error {
+			if i == 3 {
+				return failure
+			}
+			return nil
+		})
+
+		assert.Nil(t, errs[2])
+		assert.Equal(t, failure, errs[3])
+		assert.Equal(t, failure, FirstError(errs))
+	})
+
+	t.Run("Handles empty input", func(t *testing.T) {
+		assert.Empty(t, Run(0, 4, func(i int) error { return nil }))
+	})
+}
@@ -0,0 +1,94 @@
+// Package proxy embeds the encrypting S3 endpoint in another Go program.
+// A Proxy serves the same S3 API as the standalone server, resolving SSE-KMS
+// keys to Vault transit keys, but leaves listening to the caller: mount
+// Handler on an http.Server or serve App with Fiber. The transit engine, the
+// storage backend and the metadata store can each be replaced.
+package proxy
+
+import (
+	"net/http"
+
+	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/metadata"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/server"
+	"s3-vault-proxy/internal/vault"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+type (
+	// Config is the proxy configuration, with the settings the server reads
+	// from its environment variables
+	Config = config.Config
+
+	// Transit encrypts and decrypts the data keys of objects
+	Transit = vault.Interface
+
+	// Storage forwards requests to the S3 backend holding the ciphertext
+	Storage = s3.Interface
+
+	// Metadata stores the encryption metadata of objects
+	Metadata = metadata.Interface
+)
+
+// Options replaces subsystems the proxy otherwise builds from its Config.
+// Nil fields are built from the Config.
+type Options struct {
+	// Transit replaces Vault, which makes VAULT_ADDR and the token unnecessary
+	Transit Transit
+	// Storage replaces the backend at Config.S3Endpoint
+	Storage Storage
+	// Metadata replaces the metadata objects stored next to each object
+	Metadata Metadata
+}
+
+// ConfigFromEnv reads the configuration from the environment variables the
+// server uses, with the same defaults. It is not validated, since injected
+// subsystems make some required settings unnecessary.
+func ConfigFromEnv() *Config {
+	return config.FromEnv()
+}
+
+// NewDevTransit returns an in-memory transit engine whose keys are lost when
+// the program exits. It suits tests and local development only.
+func NewDevTransit() Transit {
+	return vault.NewDevTransit()
+}
+
+// Proxy is an embedded S3 encryption proxy
+type Proxy struct {
+	server *server.Server
+}
+
+// New builds a proxy from cfg, using the subsystems in opts in place of the
+// configured ones. Close it when done to flush its background work.
+func New(cfg *Config, opts Options) (*Proxy, error) {
+	srv, err := server.NewWithDependencies(cfg, server.Dependencies{
+		Transit:  opts.Transit,
+		Storage:  opts.Storage,
+		Metadata: opts.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Proxy{server: srv}, nil
+}
+
+// App returns the Fiber app serving the S3 API
+func (p *Proxy) App() *fiber.App {
+	return p.server.App()
+}
+
+// Handler returns the S3 API as an http.Handler. Requests are converted to
+// Fiber's, so App serves faster when the caller can use Fiber directly.
+func (p *Proxy) Handler() http.Handler {
+	return adaptor.FiberApp(p.server.App())
+}
+
+// Close waits for queued replication and event notifications and flushes
+// traces, error reports and the access log. Stop serving requests first.
+func (p *Proxy) Close() {
+	p.server.Close()
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKMSKey = "arn:aws:kms:us-east-1:123456789012:key/embedded"
+
+// memoryBackend is an S3 backend keeping objects in memory by path
+type memoryBackend struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (b *memoryBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		b.objects[r.URL.Path] = data
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet, http.MethodHead:
+		data, ok := b.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "<Error><Code>NoSuchKey</Code></Error>")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestProxyServesHandler(t *testing.T) {
+	backend := &memoryBackend{objects: make(map[string][]byte)}
+	backendServer := httptest.NewServer(backend)
+	defer backendServer.Close()
+
+	cfg := ConfigFromEnv()
+	cfg.S3Endpoint = backendServer.URL
+	cfg.DisableStartupMsg = true
+	p, err := New(cfg, Options{Transit: NewDevTransit()})
+	require.NoError(t, err)
+	defer p.Close()
+
+	server := httptest.NewServer(p.Handler())
+	defer server.Close()
+
+	put := func(kmsKey string) *http.Response {
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/bucket/report.txt", strings.NewReader("quarterly numbers"))
+		require.NoError(t, err)
+		req.Header.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+		req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", kmsKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := put("not-an-arn")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "the injected transit engine resolves KMS keys")
+
+	resp = put(testKMSKey)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, testKMSKey, resp.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+
+	resp, err = http.Get(server.URL + "/bucket/report.txt")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "quarterly numbers", string(body))
+}
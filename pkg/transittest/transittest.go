@@ -0,0 +1,361 @@
+// Package transittest runs a fake Vault transit engine over HTTP, so code
+// that talks to Vault, including an embedded or standalone proxy, can be
+// integration-tested without a Vault server. It serves the transit encrypt,
+// decrypt, rewrap and key endpoints plus the health, token lookup and
+// capability endpoints the proxy's health checks use.
+//
+// Keys are created on first encrypt, as transit does by default, and can be
+// rotated. Ciphertexts use transit's vault:vN: format but are sealed with
+// in-memory AES-256-GCM keys, so they are only valid for the server's
+// lifetime.
+package transittest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Defaults of a Server
+const (
+	DefaultMount = "transit"
+	DefaultToken = "transittest-token"
+)
+
+// Server is a fake Vault server. URL is its address and Token the only
+// token it accepts.
+type Server struct {
+	URL   string
+	Token string
+	Mount string
+
+	server *httptest.Server
+
+	mu         sync.Mutex
+	keys       map[string][]cipher.AEAD // versions, oldest first
+	operations map[string]int
+	sealed     bool
+}
+
+// NewServer starts a fake Vault with its transit engine at DefaultMount,
+// accepting DefaultToken. Close it when done.
+func NewServer() *Server {
+	return NewServerWithMount(DefaultMount)
+}
+
+// NewServerWithMount starts a fake Vault with its transit engine at mount
+func NewServerWithMount(mount string) *Server {
+	s := &Server{
+		Token:      DefaultToken,
+		Mount:      mount,
+		keys:       make(map[string][]cipher.AEAD),
+		operations: make(map[string]int),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	return s
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// CreateKey creates a transit key with one version, doing nothing if it exists
+func (s *Server) CreateKey(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.key(name, true)
+	return err
+}
+
+// RotateKey adds a version to a transit key, which later encrypts and
+// rewraps use. It returns the new version.
+func (s *Server) RotateKey(name string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rotate(name)
+}
+
+// LatestVersion returns the newest version of a transit key, 0 if it does not exist
+func (s *Server) LatestVersion(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.keys[name])
+}
+
+// Operations returns how many encrypt, decrypt or rewrap requests succeeded
+func (s *Server) Operations(operation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.operations[operation]
+}
+
+// Seal makes every request but the health check fail as a sealed Vault's
+// do, until Unseal
+func (s *Server) Seal() {
+	s.mu.Lock()
+	s.sealed = true
+	s.mu.Unlock()
+}
+
+// Unseal undoes Seal
+func (s *Server) Unseal() {
+	s.mu.Lock()
+	s.sealed = false
+	s.mu.Unlock()
+}
+
+// key returns the versions of a transit key, creating it when create is set.
+// The caller holds s.mu.
+func (s *Server) key(name string, create bool) ([]cipher.AEAD, error) {
+	if versions, ok := s.keys[name]; ok || !create {
+		return versions, nil
+	}
+	aead, err := newAEAD()
+	if err != nil {
+		return nil, err
+	}
+	s.keys[name] = []cipher.AEAD{aead}
+	return s.keys[name], nil
+}
+
+// rotate adds a version to a key, creating the key first if needed. The
+// caller holds s.mu.
+func (s *Server) rotate(name string) (int, error) {
+	if _, err := s.key(name, true); err != nil {
+		return 0, err
+	}
+	aead, err := newAEAD()
+	if err != nil {
+		return 0, err
+	}
+	s.keys[name] = append(s.keys[name], aead)
+	return len(s.keys[name]), nil
+}
+
+func newAEAD() (cipher.AEAD, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	if path == "sys/health" {
+		s.mu.Lock()
+		sealed := s.sealed
+		s.mu.Unlock()
+		// Clients pick the status of a sealed Vault with ?sealedcode=, as with Vault
+		status := http.StatusOK
+		if sealed {
+			status = http.StatusServiceUnavailable
+			if code, err := strconv.Atoi(r.URL.Query().Get("sealedcode")); err == nil {
+				status = code
+			}
+		}
+		writeJSON(w, status, map[string]interface{}{"initialized": true, "sealed": sealed, "standby": false, "version": "transittest"})
+		return
+	}
+	if r.Header.Get("X-Vault-Token") != s.Token {
+		writeErrors(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sealed {
+		writeErrors(w, http.StatusServiceUnavailable, "Vault is sealed")
+		return
+	}
+
+	switch {
+	case path == "auth/token/lookup-self":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"ttl": 0, "renewable": false, "policies": []string{"root"}}})
+	case path == "sys/capabilities-self":
+		var request struct {
+			Paths []string `json:"paths"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		response := map[string]interface{}{"capabilities": []string{"root"}}
+		for _, p := range request.Paths {
+			response[p] = []string{"root"}
+		}
+		writeJSON(w, http.StatusOK, response)
+	case strings.HasPrefix(path, s.Mount+"/"):
+		s.serveTransit(w, r, strings.TrimPrefix(path, s.Mount+"/"))
+	default:
+		writeErrors(w, http.StatusNotFound, "no handler for route "+strconv.Quote(path))
+	}
+}
+
+// serveTransit handles a transit request. The caller holds s.mu.
+func (s *Server) serveTransit(w http.ResponseWriter, r *http.Request, path string) {
+	operation, name, _ := strings.Cut(path, "/")
+	if operation == "keys" {
+		s.serveKeys(w, r, name)
+		return
+	}
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		writeErrors(w, http.StatusMethodNotAllowed, "unsupported operation")
+		return
+	}
+	var request struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeErrors(w, http.StatusBadRequest, "failed to parse JSON input: "+err.Error())
+		return
+	}
+
+	var data map[string]interface{}
+	var err error
+	switch operation {
+	case "encrypt":
+		data, err = s.encrypt(name, request.Plaintext)
+	case "decrypt":
+		data, err = s.decrypt(name, request.Ciphertext)
+	case "rewrap":
+		data, err = s.rewrap(name, request.Ciphertext)
+	default:
+		writeErrors(w, http.StatusNotFound, "unsupported transit operation "+strconv.Quote(operation))
+		return
+	}
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.operations[operation]++
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+// serveKeys creates, rotates and reads keys
+func (s *Server) serveKeys(w http.ResponseWriter, r *http.Request, path string) {
+	name, action, _ := strings.Cut(path, "/")
+	switch {
+	case r.Method == http.MethodGet && action == "":
+		versions, _ := s.key(name, false)
+		if versions == nil {
+			writeErrors(w, http.StatusNotFound, "")
+			return
+		}
+		keys := make(map[string]int, len(versions))
+		for i := range versions {
+			keys[strconv.Itoa(i+1)] = 0
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+			"name": name, "type": "aes256-gcm96", "latest_version": len(versions), "min_decryption_version": 1, "keys": keys,
+		}})
+	case action == "":
+		if _, err := s.key(name, true); err != nil {
+			writeErrors(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case action == "rotate":
+		if _, err := s.rotate(name); err != nil {
+			writeErrors(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeErrors(w, http.StatusNotFound, "unsupported key operation "+strconv.Quote(action))
+	}
+}
+
+func (s *Server) encrypt(name, plaintext string) (map[string]interface{}, error) {
+	data, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to base64-decode plaintext")
+	}
+	versions, err := s.key(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return s.seal(name, versions, data)
+}
+
+func (s *Server) decrypt(name, ciphertext string) (map[string]interface{}, error) {
+	data, err := s.open(name, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"plaintext": base64.StdEncoding.EncodeToString(data)}, nil
+}
+
+func (s *Server) rewrap(name, ciphertext string) (map[string]interface{}, error) {
+	data, err := s.open(name, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return s.seal(name, s.keys[name], data)
+}
+
+// seal encrypts data with the newest version of a key
+func (s *Server) seal(name string, versions []cipher.AEAD, data []byte) (map[string]interface{}, error) {
+	aead := versions[len(versions)-1]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, data, []byte(name))
+	return map[string]interface{}{
+		"ciphertext":  fmt.Sprintf("vault:v%d:%s", len(versions), base64.StdEncoding.EncodeToString(sealed)),
+		"key_version": len(versions),
+	}, nil
+}
+
+// open decrypts a ciphertext with the key version it names
+func (s *Server) open(name, ciphertext string) ([]byte, error) {
+	versions, _ := s.key(name, false)
+	if versions == nil {
+		return nil, fmt.Errorf("encryption key not found")
+	}
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" || !strings.HasPrefix(parts[1], "v") {
+		return nil, fmt.Errorf("invalid ciphertext: no prefix")
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(parts[1], "v"))
+	if err != nil || version < 1 || version > len(versions) {
+		return nil, fmt.Errorf("invalid ciphertext: unknown key version")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[2])
+	aead := versions[version-1]
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid ciphertext: could not decode")
+	}
+	data, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("cipher: message authentication failed")
+	}
+	return data, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeErrors(w http.ResponseWriter, status int, message string) {
+	errors := []string{}
+	if message != "" {
+		errors = append(errors, message)
+	}
+	writeJSON(w, status, map[string]interface{}{"errors": errors})
+}
@@ -0,0 +1,86 @@
+package transittest
+
+import (
+	"context"
+	"testing"
+
+	"s3-vault-proxy/internal/vault"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newClient(t *testing.T, server *Server) *vault.Client {
+	// A sealed server answers 503, which the client would otherwise retry
+	t.Setenv("VAULT_MAX_RETRIES", "0")
+	client, err := vault.NewClient(server.URL, server.Token, "")
+	require.NoError(t, err)
+	return client.WithMount(server.Mount)
+}
+
+func TestServerWithVaultClient(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := newClient(t, server)
+	ctx := context.Background()
+
+	ciphertext, err := client.Encrypt(ctx, []byte("data key"), "orders")
+	require.NoError(t, err)
+	version, err := vault.CiphertextVersion(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	data, err := client.Decrypt(ctx, ciphertext, "orders")
+	require.NoError(t, err)
+	assert.Equal(t, "data key", string(data))
+
+	_, err = client.Decrypt(ctx, ciphertext, "other")
+	assert.Error(t, err, "ciphertexts are bound to their key")
+
+	newVersion, err := server.RotateKey("orders")
+	require.NoError(t, err)
+	assert.Equal(t, 2, newVersion)
+	rewrapped, err := client.Rewrap(ctx, ciphertext, "orders")
+	require.NoError(t, err)
+	version, err = vault.CiphertextVersion(rewrapped)
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+	data, err = client.Decrypt(ctx, ciphertext, "orders")
+	require.NoError(t, err, "old versions still decrypt")
+	assert.Equal(t, "data key", string(data))
+
+	assert.Equal(t, 1, server.Operations("encrypt"))
+	assert.Equal(t, 1, server.Operations("rewrap"))
+	assert.Equal(t, 2, server.LatestVersion("orders"))
+}
+
+func TestServerHealthAndCapabilities(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := newClient(t, server)
+
+	require.NoError(t, client.HealthCheck())
+	status := client.DependencyStatus()
+	assert.True(t, status.Reachable)
+	assert.Empty(t, status.Error)
+
+	canEncrypt, canDecrypt, err := client.TransitCapabilities("orders")
+	require.NoError(t, err)
+	assert.True(t, canEncrypt)
+	assert.True(t, canDecrypt)
+
+	server.Seal()
+	assert.True(t, client.DependencyStatus().Sealed)
+	server.Unseal()
+	assert.False(t, client.DependencyStatus().Sealed)
+}
+
+func TestServerRejectsOtherTokens(t *testing.T) {
+	server := NewServerWithMount("kms")
+	defer server.Close()
+	client, err := vault.NewClient(server.URL, "wrong", "")
+	require.NoError(t, err)
+
+	_, err = client.WithMount("kms").Encrypt(context.Background(), []byte("x"), "orders")
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"net/http"
+	"time"
+)
+
+// listTimeLayout is how S3 renders timestamps in XML listings
+const listTimeLayout = "2006-01-02T15:04:05.000Z"
+
+// FormatLastModified formats a storage timestamp for ObjectMetadata.LastModified,
+// which holds RFC 3339 in UTC
+func FormatLastModified(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ParseLastModified parses ObjectMetadata.LastModified. Metadata written by
+// older versions holds the HTTP date of a Last-Modified header instead.
+func ParseLastModified(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// NormalizeLastModified converts a timestamp ParseLastModified accepts, such as
+// a Last-Modified header, to the stored format. Other values are returned unchanged.
+func NormalizeLastModified(value string) string {
+	if t, err := ParseLastModified(value); err == nil {
+		return FormatLastModified(t)
+	}
+	return value
+}
+
+// HTTPLastModified renders a stored timestamp as a Last-Modified header
+// (RFC 1123 in GMT), or "" when it can't be parsed
+func HTTPLastModified(value string) string {
+	t, err := ParseLastModified(value)
+	if err != nil {
+		return ""
+	}
+	return t.Format(http.TimeFormat)
+}
+
+// ListLastModified renders a stored timestamp as the LastModified of an XML
+// listing entry, or "" when it can't be parsed
+func ListLastModified(value string) string {
+	t, err := ParseLastModified(value)
+	if err != nil {
+		return ""
+	}
+	return t.Format(listTimeLayout)
+}
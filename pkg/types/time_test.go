@@ -0,0 +1,29 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastModified(t *testing.T) {
+	stored := FormatLastModified(time.Date(2024, 3, 5, 14, 30, 0, 0, time.FixedZone("CET", 3600)))
+	assert.Equal(t, "2024-03-05T13:30:00Z", stored)
+
+	for _, value := range []string{stored, "Tue, 05 Mar 2024 13:30:00 GMT", "Tuesday, 05-Mar-24 13:30:00 GMT", "2024-03-05T14:30:00+01:00"} {
+		parsed, err := ParseLastModified(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, time.Date(2024, 3, 5, 13, 30, 0, 0, time.UTC), parsed, value)
+		assert.Equal(t, stored, NormalizeLastModified(value))
+	}
+
+	assert.Equal(t, "Tue, 05 Mar 2024 13:30:00 GMT", HTTPLastModified(stored))
+	assert.Equal(t, "2024-03-05T13:30:00.000Z", ListLastModified(stored))
+
+	_, err := ParseLastModified("yesterday")
+	assert.Error(t, err)
+	assert.Equal(t, "yesterday", NormalizeLastModified("yesterday"))
+	assert.Equal(t, "", HTTPLastModified("yesterday"))
+}
@@ -2,6 +2,8 @@ package types
 
 import (
 	"encoding/xml"
+	"net/http"
+	"strings"
 	"time"
 )
 
@@ -9,7 +11,7 @@ import (
 type S3Time time.Time
 
 func (t S3Time) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
-	return e.EncodeElement(time.Time(t).UTC().Format("2006-01-02T15:04:05.000Z"), start)
+	return e.EncodeElement(time.Time(t).UTC().Format(listTimeLayout), start)
 }
 
 // S3 XML response structures
@@ -50,6 +52,15 @@ type Content struct {
 	StorageClass string `xml:"StorageClass"`
 }
 
+type OwnershipControls struct {
+	XMLName xml.Name                `xml:"http://s3.amazonaws.com/doc/2006-03-01/ OwnershipControls"`
+	Rules   []OwnershipControlsRule `xml:"Rule"`
+}
+
+type OwnershipControlsRule struct {
+	ObjectOwnership string `xml:"ObjectOwnership"`
+}
+
 type ErrorResponse struct {
 	XMLName xml.Name `xml:"Error"`
 	Code    string   `xml:"Code"`
@@ -64,4 +75,50 @@ type ObjectMetadata struct {
 	LastModified  string            `json:"last_modified"`
 	KMSKeyARN     string            `json:"kms_key_arn"`
 	CustomMeta    map[string]string `json:"custom_meta,omitempty"`
-}
\ No newline at end of file
+
+	// EncryptionContext is the x-amz-server-side-encryption-context the object
+	// was uploaded with, which reads must repeat when enforce_encryption_context is enabled
+	EncryptionContext map[string]string `json:"encryption_context,omitempty"`
+
+	// HTTP headers the object was uploaded with, returned on GET and HEAD
+	// so caches such as CDNs honour them
+	CacheControl       string `json:"cache_control,omitempty"`
+	ContentDisposition string `json:"content_disposition,omitempty"`
+	ContentEncoding    string `json:"content_encoding,omitempty"`
+	Expires            string `json:"expires,omitempty"`
+
+	// ReplicationStatus is PENDING, COMPLETED or FAILED on replicated objects and REPLICA on their copies
+	ReplicationStatus string `json:"replication_status,omitempty"`
+}
+
+// ReadHTTPHeaders records the Cache-Control, Content-Disposition,
+// Content-Encoding and Expires headers of an upload or a backend response.
+// The aws-chunked upload framing is not part of the object's encoding.
+func (m *ObjectMetadata) ReadHTTPHeaders(header http.Header) {
+	m.CacheControl = header.Get("Cache-Control")
+	m.ContentDisposition = header.Get("Content-Disposition")
+	m.Expires = header.Get("Expires")
+
+	var encodings []string
+	for _, encoding := range strings.Split(header.Get("Content-Encoding"), ",") {
+		if encoding = strings.TrimSpace(encoding); encoding != "" && encoding != "aws-chunked" {
+			encodings = append(encodings, encoding)
+		}
+	}
+	m.ContentEncoding = strings.Join(encodings, ",")
+}
+
+// WriteHTTPHeaders sets the recorded HTTP headers on header, leaving headers
+// the object was not uploaded with untouched
+func (m *ObjectMetadata) WriteHTTPHeaders(header http.Header) {
+	for name, value := range map[string]string{
+		"Cache-Control":       m.CacheControl,
+		"Content-Disposition": m.ContentDisposition,
+		"Content-Encoding":    m.ContentEncoding,
+		"Expires":             m.Expires,
+	} {
+		if value != "" {
+			header.Set(name, value)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/json"
 	"encoding/xml"
+	"net/http"
 	"testing"
 	"time"
 
@@ -123,4 +124,27 @@ func TestObjectMetadata_JSON(t *testing.T) {
 	assert.Equal(t, metadata.ContentType, unmarshaled.ContentType)
 	assert.Equal(t, metadata.KMSKeyARN, unmarshaled.KMSKeyARN)
 	assert.Equal(t, metadata.CustomMeta["custom-key"], unmarshaled.CustomMeta["custom-key"])
-}
\ No newline at end of file
+}
+
+func TestObjectMetadata_HTTPHeaders(t *testing.T) {
+	var metadata ObjectMetadata
+	metadata.ReadHTTPHeaders(http.Header{
+		"Cache-Control":       {"public, max-age=3600"},
+		"Content-Disposition": {`attachment; filename="report.pdf"`},
+		"Content-Encoding":    {"aws-chunked,gzip"},
+		"Expires":             {"Thu, 01 Dec 2044 16:00:00 GMT"},
+	})
+	assert.Equal(t, "gzip", metadata.ContentEncoding, "the upload framing is dropped")
+
+	header := http.Header{"Cache-Control": {"no-cache"}, "Content-Type": {"application/pdf"}}
+	metadata.WriteHTTPHeaders(header)
+	assert.Equal(t, "public, max-age=3600", header.Get("Cache-Control"))
+	assert.Equal(t, `attachment; filename="report.pdf"`, header.Get("Content-Disposition"))
+	assert.Equal(t, "gzip", header.Get("Content-Encoding"))
+	assert.Equal(t, "Thu, 01 Dec 2044 16:00:00 GMT", header.Get("Expires"))
+	assert.Equal(t, "application/pdf", header.Get("Content-Type"))
+
+	header = http.Header{"Cache-Control": {"no-cache"}}
+	(&ObjectMetadata{}).WriteHTTPHeaders(header)
+	assert.Equal(t, "no-cache", header.Get("Cache-Control"), "unset headers are left alone")
+}
@@ -0,0 +1,280 @@
+//go:build compat
+
+// Package compat runs rclone, restic and Kopia against an in-process proxy. It needs
+// a real backend and Vault, configured through the proxy's usual environment,
+// plus COMPAT_BUCKET, an existing bucket with default SSE-KMS encryption.
+// S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY sign the clients' requests and
+// DEFAULT_KMS_KEY_ARN is the key rclone sends and restic's and Kopia's uploads
+// fall back to.
+//
+//	go test -tags compat -v ./tests/compat
+package compat
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/server"
+
+	"github.com/stretchr/testify/require"
+)
+
+// compatFiles are written under the source tree; the names exercise key encoding
+var compatFiles = map[string]int{
+	"plain.txt":                   1024,
+	"empty":                       0,
+	"with space.txt":              2048,
+	"plus+sign&equals=.txt":       512,
+	"percent%20literal.txt":       512,
+	"ünïcødé/日本語.bin":             4096,
+	"nested/deeper/large.bin":     6 * 1024 * 1024,
+	"nested/deeper/tilde~(1).txt": 100,
+}
+
+type harness struct {
+	endpoint string
+	bucket   string
+	kmsKey   string
+	cfg      *config.Config
+}
+
+func setup(t *testing.T) *harness {
+	t.Helper()
+
+	bucket := os.Getenv("COMPAT_BUCKET")
+	if bucket == "" {
+		t.Skip("COMPAT_BUCKET is not set")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := fmt.Sprint(ln.Addr().(*net.TCPAddr).Port)
+	require.NoError(t, ln.Close())
+	t.Setenv("PORT", port)
+	t.Setenv("LISTENERS", "")
+	t.Setenv("ADMIN_ADDR", "")
+
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+	if _, err := cfg.OperatorCredentials(); err != nil {
+		t.Skip(err.Error())
+	}
+	if cfg.DefaultKMSKeyARN == "" {
+		t.Skip("DEFAULT_KMS_KEY_ARN is not set")
+	}
+	cfg.DisableStartupMsg = true
+
+	srv, err := server.New(cfg)
+	require.NoError(t, err)
+	go func() {
+		_ = srv.Start()
+	}()
+
+	h := &harness{
+		endpoint: "http://127.0.0.1:" + port,
+		bucket:   bucket,
+		kmsKey:   cfg.DefaultKMSKeyARN,
+		cfg:      cfg,
+	}
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(h.endpoint + "/health")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 10*time.Second, 100*time.Millisecond, "proxy did not start")
+	return h
+}
+
+// removePrefix deletes the objects a test left under prefix, using rclone when available
+func (h *harness) removePrefix(t *testing.T, prefix string) {
+	if _, err := exec.LookPath("rclone"); err != nil {
+		t.Logf("rclone is not installed; remove %s/%s by hand", h.bucket, prefix)
+		return
+	}
+	cmd := exec.Command("rclone", "delete", "--rmdirs", fmt.Sprintf(":s3:%s/%s", h.bucket, prefix),
+		"--s3-provider", "Other", "--s3-endpoint", h.endpoint,
+		"--s3-access-key-id", h.cfg.S3AccessKeyID, "--s3-secret-access-key", h.cfg.S3SecretAccessKey)
+	_ = cmd.Run()
+}
+
+// writeTree fills a temporary directory with compatFiles of random content
+func writeTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, size := range compatFiles {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		data := make([]byte, size)
+		_, err := rand.Read(data)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+	}
+	return dir
+}
+
+// requireSameTree checks that every file of compatFiles has the same content under both roots
+func requireSameTree(t *testing.T, want, got string) {
+	t.Helper()
+	for name := range compatFiles {
+		expected, err := os.ReadFile(filepath.Join(want, filepath.FromSlash(name)))
+		require.NoError(t, err)
+		actual, err := os.ReadFile(filepath.Join(got, filepath.FromSlash(name)))
+		require.NoError(t, err, name)
+		require.True(t, bytes.Equal(expected, actual), "%s differs after the round trip", name)
+	}
+}
+
+// run executes a client binary, failing the test with its output on error
+func run(t *testing.T, env []string, name string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, "%s %s\n%s", name, strings.Join(args, " "), output)
+	return string(output)
+}
+
+func lookPath(t *testing.T, name string) {
+	t.Helper()
+	if _, err := exec.LookPath(name); err != nil {
+		t.Skipf("%s is not installed", name)
+	}
+}
+
+func TestRclone(t *testing.T) {
+	lookPath(t, "rclone")
+	h := setup(t)
+
+	// rclone sends the SSE-KMS headers itself. The upload cutoff keeps it on
+	// single PUTs, whose ETags it compares with the plaintext.
+	env := []string{
+		"RCLONE_CONFIG=" + filepath.Join(t.TempDir(), "rclone.conf"),
+		"RCLONE_S3_PROVIDER=Other",
+		"RCLONE_S3_ENDPOINT=" + h.endpoint,
+		"RCLONE_S3_REGION=" + h.cfg.S3Region,
+		"RCLONE_S3_ACCESS_KEY_ID=" + h.cfg.S3AccessKeyID,
+		"RCLONE_S3_SECRET_ACCESS_KEY=" + h.cfg.S3SecretAccessKey,
+		"RCLONE_S3_FORCE_PATH_STYLE=true",
+		"RCLONE_S3_LIST_VERSION=2",
+		"RCLONE_S3_SERVER_SIDE_ENCRYPTION=aws:kms",
+		"RCLONE_S3_SSE_KMS_KEY_ID=" + h.kmsKey,
+		"RCLONE_S3_UPLOAD_CUTOFF=5G",
+	}
+	remote := fmt.Sprintf(":s3:%s/compat-rclone-%d", h.bucket, time.Now().UnixNano())
+	t.Cleanup(func() {
+		cmd := exec.Command("rclone", "delete", "--rmdirs", remote)
+		cmd.Env = append(os.Environ(), env...)
+		_ = cmd.Run()
+	})
+
+	source := writeTree(t)
+	run(t, env, "rclone", "sync", source, remote)
+
+	// check compares sizes and MD5s, which the proxy reports for the plaintext
+	run(t, env, "rclone", "check", source, remote)
+
+	// A second sync must find everything up to date
+	output := run(t, env, "rclone", "sync", "-v", source, remote)
+	require.NotContains(t, output, "Copied", "second sync re-uploaded files")
+
+	target := t.TempDir()
+	run(t, env, "rclone", "copy", remote, target)
+	requireSameTree(t, source, target)
+
+	run(t, env, "rclone", "deletefile", remote+"/with space.txt")
+	listing := run(t, env, "rclone", "lsf", "-R", remote)
+	require.NotContains(t, listing, "with space.txt")
+	require.NotContains(t, listing, ".metadata", "listings must hide metadata objects")
+}
+
+func TestRestic(t *testing.T) {
+	lookPath(t, "restic")
+	h := setup(t)
+
+	// restic cannot send SSE-KMS headers, so its uploads use DEFAULT_KMS_KEY_ARN.
+	// 4 MiB packs stay below minio-go's multipart threshold.
+	prefix := fmt.Sprintf("compat-restic-%d", time.Now().UnixNano())
+	env := []string{
+		fmt.Sprintf("RESTIC_REPOSITORY=s3:%s/%s/%s", h.endpoint, h.bucket, prefix),
+		"RESTIC_PASSWORD=compat",
+		"RESTIC_CACHE_DIR=" + t.TempDir(),
+		"RESTIC_PACK_SIZE=4",
+		"AWS_ACCESS_KEY_ID=" + h.cfg.S3AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY=" + h.cfg.S3SecretAccessKey,
+		"AWS_DEFAULT_REGION=" + h.cfg.S3Region,
+	}
+	t.Cleanup(func() { h.removePrefix(t, prefix) })
+
+	source := writeTree(t)
+	run(t, env, "restic", "init")
+	run(t, env, "restic", "backup", "--host", "compat", source)
+	run(t, env, "restic", "check", "--read-data")
+
+	target := t.TempDir()
+	run(t, env, "restic", "restore", "latest", "--target", target)
+	requireSameTree(t, source, filepath.Join(target, source))
+
+	// Pruning rewrites packs and deletes the old ones
+	run(t, env, "restic", "forget", "--keep-last", "1", "--prune")
+	run(t, env, "restic", "check")
+}
+
+// TestKopia follows the calls Velero's file system backups make through Kopia:
+// small blob uploads, ranged reads of pack files and blob deletion on maintenance.
+func TestKopia(t *testing.T) {
+	lookPath(t, "kopia")
+	h := setup(t)
+
+	// Kopia cannot send SSE-KMS headers either, so its uploads use DEFAULT_KMS_KEY_ARN
+	prefix := fmt.Sprintf("compat-kopia-%d/", time.Now().UnixNano())
+	configDir := t.TempDir()
+	env := []string{
+		"KOPIA_PASSWORD=compat",
+		"KOPIA_CHECK_FOR_UPDATES=false",
+		"KOPIA_CONFIG_PATH=" + filepath.Join(configDir, "repository.config"),
+		"KOPIA_CACHE_DIRECTORY=" + filepath.Join(configDir, "cache"),
+		"KOPIA_LOG_DIR=" + filepath.Join(configDir, "logs"),
+	}
+	run(t, env, "kopia", "repository", "create", "s3",
+		"--bucket", h.bucket,
+		"--prefix", prefix,
+		"--endpoint", strings.TrimPrefix(h.endpoint, "http://"),
+		"--disable-tls",
+		"--region", h.cfg.S3Region,
+		"--access-key", h.cfg.S3AccessKeyID,
+		"--secret-access-key", h.cfg.S3SecretAccessKey)
+	t.Cleanup(func() { h.removePrefix(t, prefix) })
+
+	source := writeTree(t)
+	run(t, env, "kopia", "snapshot", "create", source)
+	run(t, env, "kopia", "snapshot", "verify", "--verify-files-percent", "100")
+
+	var snapshots []struct {
+		RootEntry struct {
+			Object string `json:"obj"`
+		} `json:"rootEntry"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(run(t, env, "kopia", "snapshot", "list", source, "--json")), &snapshots))
+	require.Len(t, snapshots, 1)
+
+	target := t.TempDir()
+	run(t, env, "kopia", "snapshot", "restore", snapshots[0].RootEntry.Object, target)
+	requireSameTree(t, source, target)
+
+	// Maintenance rewrites and deletes blobs, as Velero's repository maintenance job does
+	run(t, env, "kopia", "snapshot", "delete", snapshots[0].RootEntry.Object, "--delete")
+	run(t, env, "kopia", "maintenance", "run", "--full", "--safety", "none")
+}
@@ -0,0 +1,279 @@
+//go:build e2e
+
+// Package e2e runs the proxy against Vault (in dev mode) and MinIO in Docker
+// containers and drives it with signed S3 requests, covering what the unit
+// tests' mocks cannot: real signatures, real SSE-KMS at rest and real Vault.
+// It needs a docker CLI that can pull hashicorp/vault and minio/minio.
+//
+//	go test -tags e2e -v ./tests/e2e
+//
+// E2E_VAULT_IMAGE and E2E_MINIO_IMAGE override the images.
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"s3-vault-proxy/internal/config"
+	"s3-vault-proxy/internal/s3"
+	"s3-vault-proxy/internal/server"
+	"s3-vault-proxy/internal/sigv4"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	vaultToken  = "e2e-root"
+	minioUser   = "e2e-access"
+	minioSecret = "e2e-secret-key"
+	region      = "us-east-1"
+	// minioKMSKey names MinIO's static KMS key. Buckets encrypt with it by
+	// default, since a plain MinIO has no key named after the proxy's ARNs.
+	minioKMSKey = "e2e-key"
+	kmsKeyARN   = "arn:aws:kms:us-east-1:123456789012:key/e2e-transit"
+	bucket      = "e2e"
+)
+
+// environment is the containers and the proxy of one test run
+type environment struct {
+	proxy    *s3.SigningClient // signed requests through the proxy
+	backend  *s3.SigningClient // signed requests straight to MinIO
+	endpoint string
+}
+
+func setup(t *testing.T) *environment {
+	t.Helper()
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker is not installed")
+	}
+
+	vaultAddr := "http://" + startContainer(t, image("E2E_VAULT_IMAGE", "hashicorp/vault:1.15"), "8200",
+		[]string{"VAULT_DEV_ROOT_TOKEN_ID=" + vaultToken, "VAULT_DEV_LISTEN_ADDRESS=0.0.0.0:8200"})
+	waitFor(t, vaultAddr+"/v1/sys/health")
+	vaultRequest(t, vaultAddr, "sys/mounts/transit", map[string]string{"type": "transit"})
+	vaultRequest(t, vaultAddr, "transit/keys/e2e-transit", nil)
+
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	require.NoError(t, err)
+	minioAddr := "http://" + startContainer(t, image("E2E_MINIO_IMAGE", "minio/minio:latest"), "9000",
+		[]string{
+			"MINIO_ROOT_USER=" + minioUser,
+			"MINIO_ROOT_PASSWORD=" + minioSecret,
+			"MINIO_KMS_SECRET_KEY=" + minioKMSKey + ":" + base64.StdEncoding.EncodeToString(secret),
+		}, "server", "/data")
+	waitFor(t, minioAddr+"/minio/health/live")
+
+	credentials := sigv4.Credentials{AccessKey: minioUser, SecretKey: minioSecret, Region: region}
+	backend, err := s3.NewSigningClient(s3.NewClient(minioAddr, "", s3.DefaultTransportConfig()), minioAddr, credentials)
+	require.NoError(t, err)
+	ctx := context.Background()
+	expectStatus(t, http.StatusOK)(backend.ForwardRequest(ctx, "PUT", "/"+bucket, nil, http.Header{}, nil))
+	encryption := fmt.Sprintf(`<ServerSideEncryptionConfiguration><Rule><ApplyServerSideEncryptionByDefault>`+
+		`<SSEAlgorithm>aws:kms</SSEAlgorithm><KMSMasterKeyID>%s</KMSMasterKeyID>`+
+		`</ApplyServerSideEncryptionByDefault></Rule></ServerSideEncryptionConfiguration>`, minioKMSKey)
+	expectStatus(t, http.StatusOK)(backend.ForwardRequest(ctx, "PUT", "/"+bucket, strings.NewReader(encryption),
+		http.Header{"Content-Length": {fmt.Sprint(len(encryption))}}, []byte("encryption")))
+
+	endpoint := startProxy(t, vaultAddr, minioAddr)
+	proxy, err := s3.NewSigningClient(s3.NewClient(endpoint, "", s3.DefaultTransportConfig()), endpoint, credentials)
+	require.NoError(t, err)
+	return &environment{proxy: proxy, backend: backend, endpoint: endpoint}
+}
+
+// startProxy serves the proxy in process on a free port
+func startProxy(t *testing.T, vaultAddr, minioAddr string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := fmt.Sprint(ln.Addr().(*net.TCPAddr).Port)
+	require.NoError(t, ln.Close())
+
+	cfg := config.FromEnv()
+	cfg.Port = port
+	cfg.Listeners = ""
+	cfg.AdminAddr = ""
+	cfg.S3Endpoint = minioAddr
+	cfg.VaultAddr = vaultAddr
+	cfg.VaultToken = vaultToken
+	cfg.DevMode = false
+	cfg.DefaultKMSKeyARN = kmsKeyARN
+	cfg.DisableStartupMsg = true
+	require.NoError(t, cfg.Validate())
+
+	srv, err := server.New(cfg)
+	require.NoError(t, err)
+	go func() {
+		_ = srv.Start()
+	}()
+	endpoint := "http://127.0.0.1:" + port
+	waitFor(t, endpoint+"/ready")
+	return endpoint
+}
+
+// startContainer runs image detached with its port published on a random
+// host port, removing it when the test ends, and returns host:port
+func startContainer(t *testing.T, image, port string, env []string, args ...string) string {
+	t.Helper()
+	run := []string{"run", "-d", "--rm", "-p", "127.0.0.1::" + port}
+	for _, e := range env {
+		run = append(run, "-e", e)
+	}
+	run = append(append(run, image), args...)
+	id := strings.TrimSpace(docker(t, run...))
+	t.Cleanup(func() {
+		_, _ = dockerOutput("rm", "-f", id)
+	})
+
+	mapping := strings.TrimSpace(docker(t, "port", id, port+"/tcp"))
+	// One line per address family; the first is the IPv4 binding
+	mapping, _, _ = strings.Cut(mapping, "\n")
+	_, hostPort, err := net.SplitHostPort(mapping)
+	require.NoError(t, err, "unexpected docker port output %q", mapping)
+	return "127.0.0.1:" + hostPort
+}
+
+func docker(t *testing.T, args ...string) string {
+	t.Helper()
+	output, err := dockerOutput(args...)
+	require.NoError(t, err, "docker %s\n%s", strings.Join(args, " "), output)
+	return output
+}
+
+func dockerOutput(args ...string) (string, error) {
+	output, err := exec.Command("docker", args...).CombinedOutput()
+	return string(output), err
+}
+
+func image(env, fallback string) string {
+	if value := os.Getenv(env); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// waitFor polls url until it answers 200
+func waitFor(t *testing.T, url string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 60*time.Second, 250*time.Millisecond, "%s did not become ready", url)
+}
+
+// vaultRequest POSTs body to a Vault API path with the root token
+func vaultRequest(t *testing.T, vaultAddr, path string, body interface{}) {
+	t.Helper()
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, vaultAddr+"/v1/"+path, bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header.Set("X-Vault-Token", vaultToken)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	detail, _ := io.ReadAll(resp.Body)
+	require.Less(t, resp.StatusCode, 300, "vault %s: %s", path, detail)
+}
+
+// expectStatus checks a response's status and closes it
+func expectStatus(t *testing.T, status int) func(*http.Response, error) {
+	return func(resp *http.Response, err error) {
+		t.Helper()
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		detail, _ := io.ReadAll(resp.Body)
+		require.Equal(t, status, resp.StatusCode, "%s", detail)
+	}
+}
+
+func TestEncryptStoreListDecrypt(t *testing.T) {
+	env := setup(t)
+	ctx := context.Background()
+
+	objects := map[string][]byte{
+		"plain.txt":          []byte("hello from the proxy"),
+		"with space/ünï.bin": make([]byte, 256*1024),
+		"empty":              {},
+	}
+	_, err := rand.Read(objects["with space/ünï.bin"])
+	require.NoError(t, err)
+
+	for key, data := range objects {
+		headers := http.Header{"Content-Length": {fmt.Sprint(len(data))}}
+		expectStatus(t, http.StatusOK)(env.proxy.ForwardRequest(ctx, "PUT", s3.ObjectPath(bucket, key), bytes.NewReader(data), headers, nil))
+	}
+
+	// MinIO holds every object under its KMS key
+	for key := range objects {
+		resp, err := env.backend.HeadObject(ctx, bucket, key, http.Header{})
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, key)
+		assert.Equal(t, "aws:kms", resp.Header.Get("X-Amz-Server-Side-Encryption"), "%s is not encrypted at rest", key)
+	}
+
+	var listed []string
+	require.NoError(t, s3.WalkObjects(ctx, env.proxy, bucket, "", "", func(object s3.ObjectInfo) error {
+		listed = append(listed, object.Key)
+		return nil
+	}))
+	want := make([]string, 0, len(objects))
+	for key := range objects {
+		want = append(want, key)
+	}
+	sort.Strings(want)
+	assert.Equal(t, want, listed, "listings hide metadata objects")
+
+	for key, data := range objects {
+		resp, err := env.proxy.ForwardRequest(ctx, "GET", s3.ObjectPath(bucket, key), nil, http.Header{}, nil)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode, key)
+		assert.True(t, bytes.Equal(data, body), "%s differs after the round trip", key)
+	}
+
+	expectStatus(t, http.StatusNoContent)(env.proxy.ForwardRequest(ctx, "DELETE", s3.ObjectPath(bucket, "plain.txt"), nil, http.Header{}, nil))
+	resp, err := env.proxy.HeadObject(ctx, bucket, "plain.txt", http.Header{})
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestDependencies(t *testing.T) {
+	env := setup(t)
+
+	resp, err := http.Get(env.endpoint + "/health/dependencies")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var status struct {
+		Vault struct {
+			Reachable bool `json:"reachable"`
+			Sealed    bool `json:"sealed"`
+		} `json:"vault"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, status.Vault.Reachable)
+	assert.False(t, status.Vault.Sealed)
+}
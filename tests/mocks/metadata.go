@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"net/http"
 
 	"s3-vault-proxy/pkg/types"
@@ -15,7 +16,7 @@ type MetadataService struct {
 }
 
 // Store mocks the Store method
-func (m *MetadataService) Store(bucket, key string, metadata *types.ObjectMetadata, headers http.Header) error {
+func (m *MetadataService) Store(ctx context.Context, bucket, key string, metadata *types.ObjectMetadata, headers http.Header) error {
 	args := m.Called(bucket, key, metadata, headers)
 	
 	// Store in memory for later retrieval
@@ -29,7 +30,7 @@ func (m *MetadataService) Store(bucket, key string, metadata *types.ObjectMetada
 }
 
 // Get mocks the Get method
-func (m *MetadataService) Get(bucket, key string, headers http.Header) (*types.ObjectMetadata, error) {
+func (m *MetadataService) Get(ctx context.Context, bucket, key string, headers http.Header) (*types.ObjectMetadata, error) {
 	args := m.Called(bucket, key, headers)
 	
 	// Try to return stored metadata first
@@ -44,7 +45,7 @@ func (m *MetadataService) Get(bucket, key string, headers http.Header) (*types.O
 }
 
 // Exists mocks the Exists method
-func (m *MetadataService) Exists(bucket, key string, headers http.Header) bool {
+func (m *MetadataService) Exists(ctx context.Context, bucket, key string, headers http.Header) bool {
 	args := m.Called(bucket, key, headers)
 	return args.Bool(0)
 }
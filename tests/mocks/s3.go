@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -16,13 +17,13 @@ type S3Client struct {
 }
 
 // ForwardRequest mocks the ForwardRequest method
-func (m *S3Client) ForwardRequest(method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
+func (m *S3Client) ForwardRequest(ctx context.Context, method, path string, body io.Reader, headers http.Header, queryString []byte) (*http.Response, error) {
 	args := m.Called(method, path, body, headers, queryString)
 	return args.Get(0).(*http.Response), args.Error(1)
 }
 
 // HeadObject mocks the HeadObject method
-func (m *S3Client) HeadObject(bucket, key string, headers http.Header) (*http.Response, error) {
+func (m *S3Client) HeadObject(ctx context.Context, bucket, key string, headers http.Header) (*http.Response, error) {
 	args := m.Called(bucket, key, headers)
 	return args.Get(0).(*http.Response), args.Error(1)
 }
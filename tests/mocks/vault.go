@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 
@@ -13,13 +14,13 @@ type VaultClient struct {
 }
 
 // Encrypt mocks the Encrypt method
-func (m *VaultClient) Encrypt(data []byte, transitKey string) (string, error) {
+func (m *VaultClient) Encrypt(ctx context.Context, data []byte, transitKey string) (string, error) {
 	args := m.Called(data, transitKey)
 	return args.String(0), args.Error(1)
 }
 
 // Decrypt mocks the Decrypt method
-func (m *VaultClient) Decrypt(ciphertext string, transitKey string) ([]byte, error) {
+func (m *VaultClient) Decrypt(ctx context.Context, ciphertext string, transitKey string) ([]byte, error) {
 	args := m.Called(ciphertext, transitKey)
 	return args.Get(0).([]byte), args.Error(1)
 }